@@ -0,0 +1,82 @@
+terraform {
+  backend "s3" {
+    bucket = "nameslol-deployments"
+    key    = "terraform/api-admin"
+    region = "us-east-1"
+  }
+}
+
+provider "aws" {
+  region = "us-east-1"
+}
+
+data "aws_dynamodb_table" "nameslol" {
+  name = "nameslol"
+}
+
+data "aws_sqs_queue" "name-update-queue" {
+  name = "NameUpdateQueue"
+}
+
+data "aws_ssm_parameter" "riot-api-token" {
+  name = "/riot-api-token"
+}
+
+data "aws_ssm_parameter" "admin-auth-secret" {
+  name = "/nameslol-admin-auth-secret"
+}
+
+module "lambda" {
+  source = "../../infrastructure/modules/lambda"
+  app_name = "api-admin"
+  bootstrap_file_path = "${path.module}/bootstrap"
+  timeout = 60
+  memory_size = 256
+  iam_policy_statements = [
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "dynamodb:PutItem",
+        "dynamodb:GetItem",
+        "dynamodb:UpdateItem",
+        "dynamodb:BatchWriteItem",
+        "dynamodb:DeleteItem",
+        "dynamodb:Query",
+      ],
+      "Resource" : [
+        data.aws_dynamodb_table.nameslol.arn,
+        "${data.aws_dynamodb_table.nameslol.arn}/index/*"
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "sqs:SendMessage",
+      ],
+      "Resource" : [
+        data.aws_sqs_queue.name-update-queue.arn
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "ssm:GetParameter"
+      ],
+      "Resource" : [
+        data.aws_ssm_parameter.riot-api-token.arn,
+        data.aws_ssm_parameter.admin-auth-secret.arn
+      ]
+    },
+  ]
+  environment_variables = {
+    DYNAMODB_TABLE         = data.aws_dynamodb_table.nameslol.name
+    QUEUE_URL              = data.aws_sqs_queue.name-update-queue.url
+    RIOT_API_KEY_SOURCE    = "ssm"
+    RIOT_API_KEY_NAMES     = data.aws_ssm_parameter.riot-api-token.name
+    RIOT_RATE_LIMIT_STORE  = "dynamodb"
+    ADMIN_AUTH_SECRET_NAME = data.aws_ssm_parameter.admin-auth-secret.name
+    ADMIN_JWT_ISSUER       = "nameslol"
+    CORS_ORIGINS           = "http://localhost:3000"
+    CORS_METHODS           = "POST, OPTIONS"
+  }
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/bricefrisco/nameslol/shared"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+type SummonersService interface {
+	Fetch(region string, name string) (*shared.SummonerDTO, error)
+	Save(summoner *shared.SummonerDTO) error
+	Delete(region string, name string) (bool, error)
+	SetHidden(region string, name string, hidden bool) error
+	GetBetweenDate(region string, limit int32, start int64, end int64) (*shared.SummonersPage, error)
+}
+
+type RegionsService interface {
+	Validate(region string) bool
+}
+
+type NameValidatorService interface {
+	Validate(region string, name string) shared.ValidationErrors
+}
+
+type QueueService interface {
+	SendBatch(ctx context.Context, region string, names []string) (int, error)
+}
+
+type AuthService interface {
+	Authenticate(ctx context.Context, headers map[string]string) (*shared.AdminPrincipal, error)
+}
+
+type AuditService interface {
+	Record(ctx context.Context, entry *shared.AuditEntry) error
+	Complete(ctx context.Context, entry *shared.AuditEntry, outcome string) error
+}
+
+type HttpResponsesService interface {
+	Success(responseObj any) events.APIGatewayProxyResponse
+	Error(statusCode int, message string) events.APIGatewayProxyResponse
+	ValidationError(errs shared.ValidationErrors) events.APIGatewayProxyResponse
+}
+
+// AdminRequest is the body of every admin request. Name is required by every action except enqueue,
+// which re-runs the producer's refreshType window for the region instead.
+type AdminRequest struct {
+	Action      string `json:"action"`
+	Region      string `json:"region"`
+	Name        string `json:"name"`
+	RefreshType string `json:"refreshType"`
+}
+
+type AdminResponse struct {
+	Action    string              `json:"action"`
+	Target    string              `json:"target"`
+	Summoner  *shared.SummonerDTO `json:"summoner,omitempty"`
+	Deleted   bool                `json:"deleted,omitempty"`
+	Enqueued  int                 `json:"enqueued,omitempty"`
+	Skipped   int                 `json:"skipped,omitempty"`
+	Truncated bool                `json:"truncated,omitempty"`
+}
+
+// enqueuePageSize is how many summoners enqueue reads at a time.
+const enqueuePageSize int32 = 1000
+
+// enqueueDeadlineMargin is the time left at the end of an invocation to respond once enqueue stops early.
+const enqueueDeadlineMargin = 10 * time.Second
+
+// adminError carries the status and message to respond with when an action fails.
+type adminError struct {
+	statusCode int
+	message    string
+	err        error
+}
+
+func (e *adminError) Error() string {
+	return e.err.Error()
+}
+
+var summoners SummonersService
+var regions RegionsService
+var validator NameValidatorService
+var queue QueueService
+var auth AuthService
+var audit AuditService
+var responses HttpResponsesService
+
+func init() {
+	log.SetFlags(0)
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Error loading AWS config: %v\n", err)
+	}
+
+	tableName := os.Getenv("DYNAMODB_TABLE")
+	s, err := shared.NewSummoners(tableName, shared.WorkloadInteractive)
+	if err != nil {
+		log.Fatalf("Error creating summoners: %v\n", err)
+	}
+	s.IndexNames(shared.NewNameSearch(shared.NewDynamoDbClient(cfg), tableName))
+	summoners = s
+
+	regions = shared.NewRegions()
+	validator = shared.NewNameValidator()
+	queue = shared.NewNameUpdateQueue(sqs.NewFromConfig(cfg), os.Getenv("QUEUE_URL"))
+	a := shared.NewAdminAuthFromEnv(cfg)
+	a.CheckRevocations(shared.NewAdminKeyRevocations(shared.NewDynamoDbClient(cfg), tableName))
+	auth = a
+	audit = shared.NewAuditLog(shared.NewDynamoDbClient(cfg), tableName)
+	responses = shared.NewHttpResponses(os.Getenv("CORS_ORIGINS"), os.Getenv("CORS_METHODS"))
+}
+
+func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod == "OPTIONS" {
+		return responses.Success(nil), nil
+	}
+
+	if request.HTTPMethod != "POST" {
+		return responses.Error(405, "Method not allowed"), nil
+	}
+
+	principal, err := auth.Authenticate(ctx, request.Headers)
+	if err != nil {
+		log.Printf("Rejected admin request: %v\n", err)
+		return responses.Error(401, "Unauthorized"), nil
+	}
+
+	var body AdminRequest
+	if err = json.Unmarshal([]byte(request.Body), &body); err != nil {
+		return responses.Error(400, "Invalid request body"), nil
+	}
+
+	body.Region = strings.ToUpper(body.Region)
+	if !regions.Validate(body.Region) {
+		return responses.Error(400, "Invalid 'region' field"), nil
+	}
+
+	if body.Action != "enqueue" && body.Name == "" {
+		return responses.Error(400, "Invalid 'name' field"), nil
+	}
+
+	var action func(body AdminRequest) (*AdminResponse, error)
+	switch body.Action {
+	case "refresh":
+		if errs := validator.Validate(body.Region, body.Name); len(errs) > 0 {
+			return responses.ValidationError(errs), nil
+		}
+
+		action = refresh
+	case "delete":
+		action = deleteSummoner
+	case "hide", "unhide":
+		action = setHidden
+	case "enqueue":
+		if _, _, err = shared.RefreshWindow(body.RefreshType, time.Now()); err != nil {
+			return responses.Error(400, "Invalid 'refreshType' field"), nil
+		}
+
+		action = func(body AdminRequest) (*AdminResponse, error) {
+			return enqueue(ctx, body)
+		}
+	default:
+		return responses.Error(400, "Invalid 'action' field"), nil
+	}
+
+	entry := &shared.AuditEntry{
+		Actor:   principal.Subject,
+		Method:  principal.Method,
+		Action:  body.Action,
+		Target:  target(body),
+		Outcome: shared.AuditPending,
+	}
+
+	if body.RefreshType != "" {
+		entry.Details = map[string]string{"refreshType": body.RefreshType}
+	}
+
+	// The entry is written before the action runs, so no action goes unaudited when the outcome cannot be.
+	if err = audit.Record(ctx, entry); err != nil {
+		log.Printf("Error writing audit log entry %+v: %v\n", entry, err)
+		return responses.Error(500, "Internal server error"), nil
+	}
+
+	result, err := action(body)
+
+	outcome := "ok"
+	if err != nil {
+		outcome = err.Error()
+	}
+
+	if auditErr := audit.Complete(ctx, entry, outcome); auditErr != nil {
+		log.Printf("Error writing outcome '%s' of audit log entry %s: %v\n", outcome, entry.Key, auditErr)
+	}
+
+	if err != nil {
+		var actionErr *adminError
+		if errors.As(err, &actionErr) {
+			log.Printf("Admin %s of %s failed: %v\n", body.Action, entry.Target, actionErr.err)
+			return responses.Error(actionErr.statusCode, actionErr.message), nil
+		}
+
+		log.Printf("Admin %s of %s failed: %v\n", body.Action, entry.Target, err)
+		return responses.Error(500, "Internal server error"), nil
+	}
+
+	log.Printf("%s ran admin %s of %s\n", principal.Subject, body.Action, entry.Target)
+	return responses.Success(result), nil
+}
+
+// refresh fetches a summoner from the Riot API and saves it, deleting it when Riot no longer knows the
+// name, the same way the name update consumer does.
+func refresh(body AdminRequest) (*AdminResponse, error) {
+	result := &AdminResponse{Action: body.Action, Target: target(body)}
+
+	summoner, err := summoners.Fetch(body.Region, body.Name)
+	if err != nil {
+		if err.Error() == "summoner not found" {
+			result.Deleted, err = summoners.Delete(body.Region, body.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			return result, nil
+		}
+
+		if shared.IsThrottled(err) {
+			return nil, &adminError{statusCode: 429, message: "Too many requests, please try again later", err: err}
+		}
+
+		var circuitErr *shared.CircuitOpenError
+		if errors.As(err, &circuitErr) {
+			return nil, &adminError{statusCode: 503, message: "Riot API is temporarily unavailable, please try again later", err: err}
+		}
+
+		return nil, err
+	}
+
+	if err = summoners.Save(summoner); err != nil {
+		if shared.IsSuppressed(err) {
+			return nil, &adminError{statusCode: 409, message: "Summoner belongs to an erased account", err: err}
+		}
+
+		return nil, err
+	}
+
+	result.Summoner = summoner
+	return result, nil
+}
+
+func deleteSummoner(body AdminRequest) (*AdminResponse, error) {
+	deleted, err := summoners.Delete(body.Region, body.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminResponse{Action: body.Action, Target: target(body), Deleted: deleted}, nil
+}
+
+func setHidden(body AdminRequest) (*AdminResponse, error) {
+	err := summoners.SetHidden(body.Region, body.Name, body.Action == "hide")
+	if err != nil {
+		if err.Error() == "summoner not found" {
+			return nil, &adminError{statusCode: 404, message: "Summoner not found", err: err}
+		}
+
+		return nil, err
+	}
+
+	return &AdminResponse{Action: body.Action, Target: target(body)}, nil
+}
+
+// enqueue sends the region's summoners in the refreshType window to the name update queue, as the
+// producer would on its schedule. It reads the window a page at a time and stops early, with Truncated
+// set, when the invocation is about to time out.
+func enqueue(ctx context.Context, body AdminRequest) (*AdminResponse, error) {
+	from, to, err := shared.RefreshWindow(body.RefreshType, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AdminResponse{Action: body.Action, Target: target(body)}
+	seen := make(map[string]bool)
+
+	limit := enqueuePageSize
+	for from <= to {
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < enqueueDeadlineMargin {
+			result.Truncated = true
+			break
+		}
+
+		page, err := summoners.GetBetweenDate(body.Region, limit, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		// Pages start at the last availability date read, so names on it are read twice.
+		names := make([]string, 0, len(page.Summoners))
+		for _, s := range page.Summoners {
+			if key := shared.NameKey(body.Region, s.Name); !seen[key] {
+				seen[key] = true
+				names = append(names, s.Name)
+			}
+		}
+
+		for _, skipped := range page.Skipped {
+			if !seen[skipped.Key] {
+				seen[skipped.Key] = true
+				result.Skipped++
+			}
+		}
+
+		sent, err := queue.SendBatch(ctx, body.Region, names)
+		result.Enqueued += sent
+		if err != nil {
+			return nil, &adminError{
+				statusCode: 500,
+				message:    fmt.Sprintf("Enqueued %d names before the queue failed", result.Enqueued),
+				err:        fmt.Errorf("enqueued %d names: %v", result.Enqueued, err),
+			}
+		}
+
+		if len(page.Summoners)+len(page.Skipped)+page.Filtered < int(limit) || len(page.Summoners) == 0 {
+			break
+		}
+
+		last := page.Summoners[len(page.Summoners)-1].AvailabilityDate
+		if last == page.Summoners[0].AvailabilityDate {
+			limit *= 2
+			continue
+		}
+
+		from, limit = last, enqueuePageSize
+	}
+
+	return result, nil
+}
+
+func target(body AdminRequest) string {
+	if body.Action == "enqueue" {
+		return body.Region
+	}
+
+	return shared.NameKey(body.Region, body.Name)
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
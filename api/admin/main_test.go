@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bricefrisco/nameslol/shared"
+	"strings"
+	"testing"
+)
+
+type SummonersServiceMock struct {
+	ShouldFail           bool
+	ShouldReturnNotFound bool
+	ShouldExhaustBudget  bool
+	ShouldBeSuppressed   bool
+	Saved                []*shared.SummonerDTO
+	Deleted              []string
+	HiddenCalls          []bool
+	GetBetweenCalls      int
+	GetBetweenStarts     []int64
+	Pages                []*shared.SummonersPage
+}
+
+func (s *SummonersServiceMock) Fetch(region string, name string) (*shared.SummonerDTO, error) {
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	if s.ShouldReturnNotFound {
+		return nil, fmt.Errorf("summoner not found")
+	}
+
+	if s.ShouldExhaustBudget {
+		return nil, &shared.BudgetExhaustedError{Workload: shared.WorkloadInteractive}
+	}
+
+	return &shared.SummonerDTO{Name: name, Region: region, Level: 30}, nil
+}
+
+func (s *SummonersServiceMock) Save(summoner *shared.SummonerDTO) error {
+	if s.ShouldBeSuppressed {
+		return &shared.SuppressedError{Name: summoner.Name}
+	}
+
+	s.Saved = append(s.Saved, summoner)
+	return nil
+}
+
+func (s *SummonersServiceMock) Delete(region string, name string) (bool, error) {
+	if s.ShouldFail {
+		return false, fmt.Errorf("error")
+	}
+
+	s.Deleted = append(s.Deleted, region+"#"+name)
+	return true, nil
+}
+
+func (s *SummonersServiceMock) SetHidden(_ string, _ string, hidden bool) error {
+	if s.ShouldReturnNotFound {
+		return fmt.Errorf("summoner not found")
+	}
+
+	s.HiddenCalls = append(s.HiddenCalls, hidden)
+	return nil
+}
+
+func (s *SummonersServiceMock) GetBetweenDate(region string, _ int32, start int64, _ int64) (*shared.SummonersPage, error) {
+	s.GetBetweenCalls++
+	s.GetBetweenStarts = append(s.GetBetweenStarts, start)
+	if s.Pages != nil {
+		return s.Pages[s.GetBetweenCalls-1], nil
+	}
+
+	return &shared.SummonersPage{
+		Summoners: []*shared.SummonerDTO{{Name: "a", Region: region}, {Name: "b", Region: region}},
+		Skipped:   []shared.SkippedItem{{Key: "NA#BROKEN", Reason: "missing attribute 'rd'"}},
+	}, nil
+}
+
+type QueueServiceMock struct {
+	FailAfter int
+	Sent      []string
+}
+
+func (q *QueueServiceMock) SendBatch(_ context.Context, region string, names []string) (int, error) {
+	for i, name := range names {
+		if q.FailAfter > 0 && len(q.Sent) == q.FailAfter {
+			return i, fmt.Errorf("error")
+		}
+
+		q.Sent = append(q.Sent, region+"#"+name)
+	}
+
+	return len(names), nil
+}
+
+type AuthServiceMock struct {
+	ShouldReject bool
+}
+
+func (a *AuthServiceMock) Authenticate(_ context.Context, _ map[string]string) (*shared.AdminPrincipal, error) {
+	if a.ShouldReject {
+		return nil, fmt.Errorf("invalid api key")
+	}
+
+	return &shared.AdminPrincipal{Subject: "brice", Method: shared.AuthMethodApiKey}, nil
+}
+
+type AuditServiceMock struct {
+	ShouldFail bool
+	Entries    []*shared.AuditEntry
+}
+
+func (a *AuditServiceMock) Record(_ context.Context, entry *shared.AuditEntry) error {
+	if a.ShouldFail {
+		return fmt.Errorf("error")
+	}
+
+	a.Entries = append(a.Entries, entry)
+	return nil
+}
+
+func (a *AuditServiceMock) Complete(_ context.Context, entry *shared.AuditEntry, outcome string) error {
+	if entry.Outcome != shared.AuditPending {
+		return fmt.Errorf("entry %+v was not recorded as pending", entry)
+	}
+
+	entry.Outcome = outcome
+	return nil
+}
+
+func setup() {
+	summoners = &SummonersServiceMock{}
+	regions = shared.NewRegions()
+	validator = shared.NewNameValidator()
+	queue = &QueueServiceMock{}
+	auth = &AuthServiceMock{}
+	audit = &AuditServiceMock{}
+	responses = shared.NewHttpResponses("test-origin", "test-methods")
+}
+
+func post(body string) events.APIGatewayProxyResponse {
+	response, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Headers:    map[string]string{"X-Api-Key": "brice.signature"},
+		Body:       body,
+	})
+	return response
+}
+
+func auditEntries() []*shared.AuditEntry {
+	return audit.(*AuditServiceMock).Entries
+}
+
+func TestHandleRequest_ReturnsSuccessForOptions(t *testing.T) {
+	setup()
+
+	response, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "OPTIONS"})
+	if response.StatusCode != 200 {
+		t.Errorf("expected 200, got %d", response.StatusCode)
+	}
+}
+
+func TestHandleRequest_RejectsOtherMethods(t *testing.T) {
+	setup()
+
+	response, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	if response.StatusCode != 405 {
+		t.Errorf("expected 405, got %d", response.StatusCode)
+	}
+}
+
+func TestHandleRequest_RejectsUnauthenticatedRequests(t *testing.T) {
+	setup()
+	auth.(*AuthServiceMock).ShouldReject = true
+
+	response := post(`{"action":"delete","region":"NA","name":"test"}`)
+	if response.StatusCode != 401 {
+		t.Errorf("expected 401, got %d", response.StatusCode)
+	}
+
+	if len(summoners.(*SummonersServiceMock).Deleted) != 0 || len(auditEntries()) != 0 {
+		t.Errorf("expected nothing to be deleted or audited")
+	}
+}
+
+func TestHandleRequest_ValidatesBody(t *testing.T) {
+	setup()
+
+	tests := map[string]string{
+		"not json":        `not json`,
+		"invalid region":  `{"action":"delete","region":"mars","name":"test"}`,
+		"missing name":    `{"action":"delete","region":"NA"}`,
+		"unknown action":  `{"action":"rename","region":"NA","name":"test"}`,
+		"bad refreshType": `{"action":"enqueue","region":"NA","refreshType":"daily"}`,
+		"invalid name":    `{"action":"refresh","region":"NA","name":"x"}`,
+	}
+
+	for name, body := range tests {
+		if response := post(body); response.StatusCode != 400 {
+			t.Errorf("%s: expected 400, got %d", name, response.StatusCode)
+		}
+	}
+
+	if len(auditEntries()) != 0 {
+		t.Errorf("expected invalid requests not to be audited")
+	}
+}
+
+func TestHandleRequest_RefreshSavesAndAudits(t *testing.T) {
+	setup()
+
+	response := post(`{"action":"refresh","region":"na","name":"Test"}`)
+	if response.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d: %s", response.StatusCode, response.Body)
+	}
+
+	if len(summoners.(*SummonersServiceMock).Saved) != 1 {
+		t.Errorf("expected summoner to be saved")
+	}
+
+	var body AdminResponse
+	_ = json.Unmarshal([]byte(response.Body), &body)
+	if body.Summoner == nil || body.Target != "NA#TEST" {
+		t.Errorf("unexpected response %s", response.Body)
+	}
+
+	entries := auditEntries()
+	if len(entries) != 1 || entries[0].Actor != "brice" || entries[0].Action != "refresh" || entries[0].Outcome != "ok" {
+		t.Errorf("unexpected audit entries %+v", entries)
+	}
+}
+
+func TestHandleRequest_RefreshDeletesUnknownSummoner(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).ShouldReturnNotFound = true
+
+	response := post(`{"action":"refresh","region":"NA","name":"test"}`)
+	if response.StatusCode != 200 || !strings.Contains(response.Body, `"deleted":true`) {
+		t.Errorf("expected deletion, got %d: %s", response.StatusCode, response.Body)
+	}
+}
+
+func TestHandleRequest_RefreshReturns429WhenBudgetIsExhausted(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).ShouldExhaustBudget = true
+
+	response := post(`{"action":"refresh","region":"NA","name":"test"}`)
+	if response.StatusCode != 429 {
+		t.Errorf("expected 429, got %d", response.StatusCode)
+	}
+
+	if entries := auditEntries(); len(entries) != 1 || entries[0].Outcome == "ok" {
+		t.Errorf("expected failed refresh to be audited, got %+v", entries)
+	}
+}
+
+func TestHandleRequest_RefreshReturns409ForErasedAccount(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).ShouldBeSuppressed = true
+
+	response := post(`{"action":"refresh","region":"NA","name":"test"}`)
+	if response.StatusCode != 409 {
+		t.Errorf("expected 409, got %d", response.StatusCode)
+	}
+}
+
+func TestHandleRequest_DeletesSummoner(t *testing.T) {
+	setup()
+
+	response := post(`{"action":"delete","region":"NA","name":"test"}`)
+	if response.StatusCode != 200 {
+		t.Errorf("expected 200, got %d", response.StatusCode)
+	}
+
+	if deleted := summoners.(*SummonersServiceMock).Deleted; len(deleted) != 1 || deleted[0] != "NA#test" {
+		t.Errorf("expected NA#test to be deleted, got %v", deleted)
+	}
+}
+
+func TestHandleRequest_DeleteReturns500AndAuditsFailure(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).ShouldFail = true
+
+	response := post(`{"action":"delete","region":"NA","name":"test"}`)
+	if response.StatusCode != 500 {
+		t.Errorf("expected 500, got %d", response.StatusCode)
+	}
+
+	if entries := auditEntries(); len(entries) != 1 || entries[0].Outcome != "error" {
+		t.Errorf("expected failure outcome, got %+v", entries)
+	}
+}
+
+func TestHandleRequest_HidesAndUnhidesSummoner(t *testing.T) {
+	setup()
+
+	post(`{"action":"hide","region":"NA","name":"test"}`)
+	post(`{"action":"unhide","region":"NA","name":"test"}`)
+
+	calls := summoners.(*SummonersServiceMock).HiddenCalls
+	if len(calls) != 2 || !calls[0] || calls[1] {
+		t.Errorf("expected hide then unhide, got %v", calls)
+	}
+
+	if len(auditEntries()) != 2 {
+		t.Errorf("expected 2 audit entries, got %d", len(auditEntries()))
+	}
+}
+
+func TestHandleRequest_HideReturns404ForUnknownSummoner(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).ShouldReturnNotFound = true
+
+	response := post(`{"action":"hide","region":"NA","name":"test"}`)
+	if response.StatusCode != 404 {
+		t.Errorf("expected 404, got %d", response.StatusCode)
+	}
+}
+
+func TestHandleRequest_EnqueuesRegionWindow(t *testing.T) {
+	setup()
+
+	response := post(`{"action":"enqueue","region":"EUW","refreshType":"weekly"}`)
+	if response.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", response.StatusCode)
+	}
+
+	if sent := queue.(*QueueServiceMock).Sent; len(sent) != 2 || sent[0] != "EUW#a" {
+		t.Errorf("expected 2 EUW names to be sent, got %v", sent)
+	}
+
+	if !strings.Contains(response.Body, `"enqueued":2`) || !strings.Contains(response.Body, `"skipped":1`) {
+		t.Errorf("unexpected response %s", response.Body)
+	}
+
+	entries := auditEntries()
+	if len(entries) != 1 || entries[0].Target != "EUW" || entries[0].Details["refreshType"] != "weekly" {
+		t.Errorf("unexpected audit entries %+v", entries)
+	}
+}
+
+// summonersPage returns a page of count summoners, the first becoming available at start and each
+// following one a millisecond later.
+func summonersPage(region string, start int64, count int) *shared.SummonersPage {
+	page := &shared.SummonersPage{}
+	for i := 0; i < count; i++ {
+		page.Summoners = append(page.Summoners, &shared.SummonerDTO{Name: fmt.Sprintf("name%d", start+int64(i)), Region: region, AvailabilityDate: start + int64(i)})
+	}
+
+	return page
+}
+
+func TestHandleRequest_EnqueuesEveryPageOfTheWindow(t *testing.T) {
+	setup()
+	mock := summoners.(*SummonersServiceMock)
+	mock.Pages = []*shared.SummonersPage{summonersPage("NA", 1, int(enqueuePageSize)), summonersPage("NA", int64(enqueuePageSize), 500)}
+
+	response := post(`{"action":"enqueue","region":"NA","refreshType":"hourly"}`)
+	if response.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", response.StatusCode)
+	}
+
+	if sent := queue.(*QueueServiceMock).Sent; len(sent) != int(enqueuePageSize)+499 {
+		t.Errorf("expected each name to be sent once, got %d", len(sent))
+	}
+
+	if len(mock.GetBetweenStarts) != 2 || mock.GetBetweenStarts[1] != int64(enqueuePageSize) {
+		t.Errorf("expected the second page to start at the last date read, got %v", mock.GetBetweenStarts)
+	}
+
+	if !strings.Contains(response.Body, fmt.Sprintf(`"enqueued":%d`, enqueuePageSize+499)) || strings.Contains(response.Body, "truncated") {
+		t.Errorf("unexpected response %s", response.Body)
+	}
+}
+
+func TestHandleRequest_EnqueueStopsBeforeTheDeadline(t *testing.T) {
+	setup()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), enqueueDeadlineMargin/2)
+	defer cancel()
+
+	response, _ := HandleRequest(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Headers:    map[string]string{"X-Api-Key": "brice.signature"},
+		Body:       `{"action":"enqueue","region":"NA","refreshType":"hourly"}`,
+	})
+	if response.StatusCode != 200 || !strings.Contains(response.Body, `"truncated":true`) {
+		t.Errorf("expected a truncated run, got %d %s", response.StatusCode, response.Body)
+	}
+}
+
+func TestHandleRequest_EnqueueReportsNamesSentBeforeQueueFails(t *testing.T) {
+	setup()
+	queue.(*QueueServiceMock).FailAfter = 1
+
+	response := post(`{"action":"enqueue","region":"NA","refreshType":"hourly"}`)
+	if response.StatusCode != 500 || !strings.Contains(response.Body, "Enqueued 1 names") {
+		t.Errorf("expected the sent count in the error, got %d %s", response.StatusCode, response.Body)
+	}
+
+	entries := auditEntries()
+	if len(entries) != 1 || !strings.Contains(entries[0].Outcome, "enqueued 1 names") {
+		t.Errorf("unexpected audit entries %+v", entries)
+	}
+}
+
+func TestHandleRequest_Returns500WhenAuditFails(t *testing.T) {
+	setup()
+	audit.(*AuditServiceMock).ShouldFail = true
+
+	response := post(`{"action":"hide","region":"NA","name":"test"}`)
+	if response.StatusCode != 500 {
+		t.Errorf("expected 500, got %d", response.StatusCode)
+	}
+
+	if calls := summoners.(*SummonersServiceMock).HiddenCalls; len(calls) != 0 {
+		t.Errorf("expected nothing to be hidden without an audit entry, got %v", calls)
+	}
+}
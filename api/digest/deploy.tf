@@ -0,0 +1,63 @@
+terraform {
+  backend "s3" {
+    bucket = "nameslol-deployments"
+    key    = "terraform/api-digest"
+    region = "us-east-1"
+  }
+}
+
+provider "aws" {
+  region = "us-east-1"
+}
+
+data "aws_dynamodb_table" "nameslol" {
+  name = "nameslol"
+}
+
+data "aws_ssm_parameter" "smtp-username" {
+  name = "/smtp-username"
+}
+
+data "aws_ssm_parameter" "smtp-password" {
+  name = "/smtp-password"
+}
+
+module "lambda" {
+  source = "../../infrastructure/modules/lambda"
+  app_name = "api-digest"
+  bootstrap_file_path = "${path.module}/bootstrap"
+  timeout = 15
+  memory_size = 256
+  iam_policy_statements = [
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "dynamodb:PutItem",
+        "dynamodb:GetItem",
+      ],
+      "Resource" : [
+        data.aws_dynamodb_table.nameslol.arn,
+        "${data.aws_dynamodb_table.nameslol.arn}/index/*"
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "ssm:GetParameter"
+      ],
+      "Resource" : [
+        data.aws_ssm_parameter.smtp-password.arn
+      ]
+    },
+  ]
+  environment_variables = {
+    DYNAMODB_TABLE     = data.aws_dynamodb_table.nameslol.name
+    CORS_ORIGINS       = "http://localhost:3000"
+    CORS_METHODS       = "POST, PUT, DELETE, OPTIONS"
+    SMTP_ADDR          = "email-smtp.us-east-1.amazonaws.com:587"
+    SMTP_FROM          = "alerts@names.lol"
+    SMTP_USERNAME      = data.aws_ssm_parameter.smtp-username.value
+    SMTP_PASSWORD_NAME = data.aws_ssm_parameter.smtp-password.name
+    DIGEST_CONFIRM_URL = "https://names.lol/digest/confirm"
+  }
+}
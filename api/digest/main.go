@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/bricefrisco/nameslol/shared"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+)
+
+type DigestsService interface {
+	Subscribe(ctx context.Context, contact string, regions []string) (*shared.DigestSubscriber, error)
+	Confirm(ctx context.Context, contact string, token string) error
+	Unsubscribe(ctx context.Context, contact string, token string) error
+}
+
+type RegionsService interface {
+	Validate(region string) bool
+}
+
+type HttpResponsesService interface {
+	Success(responseObj any) events.APIGatewayProxyResponse
+	Error(statusCode int, message string) events.APIGatewayProxyResponse
+}
+
+// maxContactLength is the longest email address SMTP allows.
+const maxContactLength = 254
+
+// subscription has the contact to email the digest to, for the given regions or every region when empty.
+type subscription struct {
+	Contact string   `json:"contact"`
+	Regions []string `json:"regions"`
+}
+
+var digests DigestsService
+var regions RegionsService
+var responses HttpResponsesService
+
+func init() {
+	log.SetFlags(0)
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Error loading AWS config: %v\n", err)
+	}
+
+	regions = shared.NewRegions()
+	responses = shared.NewHttpResponses(os.Getenv("CORS_ORIGINS"), os.Getenv("CORS_METHODS"))
+
+	client := shared.NewDynamoDbClient(cfg)
+	d := shared.NewDigests(client, os.Getenv("DYNAMODB_TABLE"), nil, nil, "", nil, "", "")
+	digests = d
+
+	// Without a mailer to confirm them, subscriptions are refused.
+	if os.Getenv("SMTP_ADDR") == "" {
+		log.Printf("email confirmations are not configured, set SMTP_ADDR")
+		return
+	}
+
+	confirmations, err := shared.NewConfirmationsFromEnv(context.TODO(), client, os.Getenv("DYNAMODB_TABLE"), shared.NewSSMSecretsSource(cfg))
+	if err != nil {
+		log.Fatalf("Error configuring confirmations: %v\n", err)
+	}
+
+	d.ConfirmWith(confirmations, os.Getenv("DIGEST_CONFIRM_URL"))
+}
+
+// HandleRequest subscribes a contact to the daily digest with POST. They confirm their subscription with
+// PUT and the token they were sent, and unsubscribe with DELETE and the same token.
+func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch request.HTTPMethod {
+	case "OPTIONS":
+		return responses.Success(nil), nil
+	case "POST":
+		return subscribe(ctx, request.Body), nil
+	case "PUT", "DELETE":
+		return withToken(ctx, request.HTTPMethod, request.QueryStringParameters), nil
+	default:
+		return responses.Error(405, "Method not allowed"), nil
+	}
+}
+
+func subscribe(ctx context.Context, requestBody string) events.APIGatewayProxyResponse {
+	var body subscription
+	if err := json.Unmarshal([]byte(requestBody), &body); err != nil {
+		return responses.Error(400, "Invalid request body")
+	}
+
+	address, err := mail.ParseAddress(body.Contact)
+	if err != nil || address.Address != strings.TrimSpace(body.Contact) || len(address.Address) > maxContactLength {
+		return responses.Error(400, "Invalid 'contact', expected an email address")
+	}
+
+	for i, region := range body.Regions {
+		body.Regions[i] = strings.ToUpper(region)
+		if !regions.Validate(body.Regions[i]) {
+			return responses.Error(400, "Invalid 'regions'")
+		}
+	}
+
+	result, err := digests.Subscribe(ctx, address.Address, body.Regions)
+	if err != nil && err.Error() == "digest is full" {
+		return responses.Error(409, "Too many digest subscribers")
+	}
+
+	var rateLimitedErr *shared.RateLimitedError
+	if errors.As(err, &rateLimitedErr) {
+		return responses.Error(429, "Too many subscriptions, please try again later")
+	}
+
+	if err != nil {
+		log.Printf("Error subscribing: %v\n", err)
+		return responses.Error(500, "Internal server error")
+	}
+
+	return responses.Success(result)
+}
+
+// withToken confirms a subscription with PUT or removes it with DELETE, given the token sent to its contact.
+func withToken(ctx context.Context, method string, query map[string]string) events.APIGatewayProxyResponse {
+	if query["contact"] == "" || query["token"] == "" {
+		return responses.Error(400, "'contact' and 'token' are required")
+	}
+
+	action, apply := "unsubscribing", digests.Unsubscribe
+	if method == "PUT" {
+		action, apply = "confirming", digests.Confirm
+	}
+
+	err := apply(ctx, query["contact"], query["token"])
+	if err != nil && err.Error() == "subscription not found" {
+		return responses.Error(404, "Subscription not found")
+	}
+
+	if err != nil {
+		log.Printf("Error %s: %v\n", action, err)
+		return responses.Error(500, "Internal server error")
+	}
+
+	return responses.Success(nil)
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
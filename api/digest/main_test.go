@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bricefrisco/nameslol/shared"
+	"strings"
+	"testing"
+	"time"
+)
+
+type DigestsServiceMock struct {
+	ShouldFail       bool
+	Full             bool
+	Limited          bool
+	NotFound         bool
+	SubscribeCalls   []string
+	ConfirmCalls     []string
+	UnsubscribeCalls []string
+}
+
+func (d *DigestsServiceMock) Subscribe(_ context.Context, contact string, regions []string) (*shared.DigestSubscriber, error) {
+	d.SubscribeCalls = append(d.SubscribeCalls, fmt.Sprintf("%s%v", contact, regions))
+	if d.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	if d.Full {
+		return nil, fmt.Errorf("digest is full")
+	}
+
+	if d.Limited {
+		return nil, &shared.RateLimitedError{Key: "subscribe#" + contact, RetryAfter: time.Minute}
+	}
+
+	return &shared.DigestSubscriber{Contact: contact, Regions: regions, Token: "secret-token", Pending: true}, nil
+}
+
+func (d *DigestsServiceMock) Confirm(_ context.Context, contact string, token string) error {
+	d.ConfirmCalls = append(d.ConfirmCalls, contact+"#"+token)
+	if d.NotFound {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func (d *DigestsServiceMock) Unsubscribe(_ context.Context, contact string, token string) error {
+	d.UnsubscribeCalls = append(d.UnsubscribeCalls, contact+"#"+token)
+	if d.ShouldFail {
+		return fmt.Errorf("error")
+	}
+
+	if d.NotFound {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func setup() {
+	digests = &DigestsServiceMock{}
+	regions = shared.NewRegions()
+	responses = shared.NewHttpResponses("test-origin", "test-methods")
+}
+
+func subscribeRequest(body string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: body}
+}
+
+func TestHandleRequest_Subscribes(t *testing.T) {
+	setup()
+
+	res, err := HandleRequest(context.TODO(), subscribeRequest(`{"contact":"player@example.com","regions":["euw","na"]}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d", res.StatusCode)
+	}
+
+	calls := digests.(*DigestsServiceMock).SubscribeCalls
+	if len(calls) != 1 || calls[0] != "player@example.com[EUW NA]" {
+		t.Errorf("Expected subscribe to be called with the request, got %v", calls)
+	}
+
+	if strings.Contains(res.Body, "secret-token") {
+		t.Errorf("Expected the subscriber without its token, got %s", res.Body)
+	}
+}
+
+func TestHandleRequest_RejectsInvalidSubscriptions(t *testing.T) {
+	bodies := []string{
+		`not json`,
+		`{"contact":"not an email"}`,
+		`{"contact":"player@example.com","regions":["mars"]}`,
+	}
+
+	for _, body := range bodies {
+		setup()
+
+		res, _ := HandleRequest(context.TODO(), subscribeRequest(body))
+		if res.StatusCode != 400 {
+			t.Errorf("%s: expected status code 400, got %d", body, res.StatusCode)
+		}
+
+		if calls := digests.(*DigestsServiceMock).SubscribeCalls; len(calls) != 0 {
+			t.Errorf("%s: expected no subscription, got %v", body, calls)
+		}
+	}
+}
+
+func TestHandleRequest_Returns409WhenFull(t *testing.T) {
+	setup()
+	digests.(*DigestsServiceMock).Full = true
+
+	res, _ := HandleRequest(context.TODO(), subscribeRequest(`{"contact":"player@example.com"}`))
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status code 409, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_Returns500WhenSubscribeFails(t *testing.T) {
+	setup()
+	digests.(*DigestsServiceMock).ShouldFail = true
+
+	res, _ := HandleRequest(context.TODO(), subscribeRequest(`{"contact":"player@example.com"}`))
+	if res.StatusCode != 500 {
+		t.Errorf("Expected status code 500, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_Returns429WhenRateLimited(t *testing.T) {
+	setup()
+	digests.(*DigestsServiceMock).Limited = true
+
+	res, _ := HandleRequest(context.TODO(), subscribeRequest(`{"contact":"player@example.com"}`))
+	if res.StatusCode != 429 {
+		t.Errorf("Expected status code 429, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_ConfirmsWithToken(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "PUT",
+		QueryStringParameters: map[string]string{"contact": "player@example.com", "token": "secret-token"},
+	})
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d", res.StatusCode)
+	}
+
+	calls := digests.(*DigestsServiceMock).ConfirmCalls
+	if len(calls) != 1 || calls[0] != "player@example.com#secret-token" {
+		t.Errorf("Expected confirm to be called with the query, got %v", calls)
+	}
+}
+
+func TestHandleRequest_Unsubscribes(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "DELETE",
+		QueryStringParameters: map[string]string{"contact": "player@example.com", "token": "secret-token"},
+	})
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d", res.StatusCode)
+	}
+
+	calls := digests.(*DigestsServiceMock).UnsubscribeCalls
+	if len(calls) != 1 || calls[0] != "player@example.com#secret-token" {
+		t.Errorf("Expected unsubscribe to be called with the query, got %v", calls)
+	}
+}
+
+func TestHandleRequest_Returns404ForUnknownSubscription(t *testing.T) {
+	setup()
+	digests.(*DigestsServiceMock).NotFound = true
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "DELETE",
+		QueryStringParameters: map[string]string{"contact": "player@example.com", "token": "wrong"},
+	})
+	if res.StatusCode != 404 {
+		t.Errorf("Expected status code 404, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_RequiresContactAndToken(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "DELETE",
+		QueryStringParameters: map[string]string{"contact": "player@example.com"},
+	})
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code 400, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_RejectsUnsupportedMethods(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	if res.StatusCode != 405 {
+		t.Errorf("Expected status code 405, got %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/bricefrisco/nameslol/shared"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+)
+
+type PatternsService interface {
+	Subscribe(ctx context.Context, subscription shared.PatternSubscription) (*shared.PatternSubscription, error)
+	Confirm(ctx context.Context, region string, id string, token string) error
+	Unsubscribe(ctx context.Context, region string, id string, token string) error
+}
+
+type RegionsService interface {
+	Validate(region string) bool
+}
+
+type HttpResponsesService interface {
+	Success(responseObj any) events.APIGatewayProxyResponse
+	Error(statusCode int, message string) events.APIGatewayProxyResponse
+}
+
+// maxContactLength is the longest email address SMTP allows.
+const maxContactLength = 254
+
+// subscription has either an email contact or, from a browser, its push subscription.
+type subscription struct {
+	Region       string                   `json:"region"`
+	MinLength    int                      `json:"minLength"`
+	MaxLength    int                      `json:"maxLength"`
+	Kind         string                   `json:"kind"`
+	Pattern      string                   `json:"pattern"`
+	HorizonHours int                      `json:"horizonHours"`
+	Contact      string                   `json:"contact"`
+	Push         *shared.PushSubscription `json:"push,omitempty"`
+}
+
+var patterns PatternsService
+var regions RegionsService
+var responses HttpResponsesService
+
+func init() {
+	log.SetFlags(0)
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Error loading AWS config: %v\n", err)
+	}
+
+	regions = shared.NewRegions()
+	responses = shared.NewHttpResponses(os.Getenv("CORS_ORIGINS"), os.Getenv("CORS_METHODS"))
+
+	client := shared.NewDynamoDbClient(cfg)
+	alerter := shared.NewPatternAlerter(client, os.Getenv("DYNAMODB_TABLE"), nil, nil, "")
+	patterns = alerter
+
+	// Without a mailer to confirm them, email subscriptions are refused.
+	if os.Getenv("SMTP_ADDR") == "" {
+		log.Printf("email confirmations are not configured, set SMTP_ADDR")
+		return
+	}
+
+	confirmations, err := shared.NewConfirmationsFromEnv(context.TODO(), client, os.Getenv("DYNAMODB_TABLE"), shared.NewSSMSecretsSource(cfg))
+	if err != nil {
+		log.Fatalf("Error configuring confirmations: %v\n", err)
+	}
+
+	alerter.ConfirmWith(confirmations, os.Getenv("PATTERN_CONFIRM_URL"))
+}
+
+// HandleRequest subscribes a contact or browser to names matching a pattern with POST. Email contacts
+// confirm their subscription with PUT and the id and token they were sent, and unsubscribe with DELETE
+// and the same id and token.
+func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch request.HTTPMethod {
+	case "OPTIONS":
+		return responses.Success(nil), nil
+	case "POST":
+		return subscribe(ctx, request.Body), nil
+	case "PUT":
+		return withToken(ctx, request.QueryStringParameters, "confirming", patterns.Confirm), nil
+	case "DELETE":
+		return withToken(ctx, request.QueryStringParameters, "unsubscribing", patterns.Unsubscribe), nil
+	default:
+		return responses.Error(405, "Method not allowed"), nil
+	}
+}
+
+func subscribe(ctx context.Context, requestBody string) events.APIGatewayProxyResponse {
+	var body subscription
+	if err := json.Unmarshal([]byte(requestBody), &body); err != nil {
+		return responses.Error(400, "Invalid request body")
+	}
+
+	body.Region = strings.ToUpper(body.Region)
+	if !regions.Validate(body.Region) {
+		return responses.Error(400, "Invalid 'region'")
+	}
+
+	if body.Push != nil {
+		if err := body.Push.Validate(); err != nil {
+			return responses.Error(400, "Invalid 'push', "+err.Error())
+		}
+	} else {
+		address, err := mail.ParseAddress(body.Contact)
+		if err != nil || address.Address != strings.TrimSpace(body.Contact) || len(address.Address) > maxContactLength {
+			return responses.Error(400, "Invalid 'contact', expected an email address")
+		}
+		body.Contact = address.Address
+	}
+
+	pattern := shared.PatternSubscription{
+		Region:       body.Region,
+		MinLength:    body.MinLength,
+		MaxLength:    body.MaxLength,
+		Kind:         body.Kind,
+		Pattern:      body.Pattern,
+		HorizonHours: body.HorizonHours,
+		Contact:      body.Contact,
+		Push:         body.Push,
+	}
+	if err := pattern.Validate(); err != nil {
+		return responses.Error(400, "Invalid pattern subscription, "+err.Error())
+	}
+
+	result, err := patterns.Subscribe(ctx, pattern)
+	if err != nil && err.Error() == "too many pattern alerts for this contact" {
+		return responses.Error(409, "Too many pattern subscriptions for this contact")
+	}
+
+	var rateLimitedErr *shared.RateLimitedError
+	if errors.As(err, &rateLimitedErr) {
+		return responses.Error(429, "Too many subscriptions, please try again later")
+	}
+
+	if err != nil {
+		log.Printf("Error subscribing: %v\n", err)
+		return responses.Error(500, "Internal server error")
+	}
+
+	return responses.Success(result)
+}
+
+// withToken applies a confirmation or unsubscription authorized by the id and token sent to a contact.
+func withToken(ctx context.Context, query map[string]string, action string, apply func(ctx context.Context, region string, id string, token string) error) events.APIGatewayProxyResponse {
+	region := strings.ToUpper(query["region"])
+	if !regions.Validate(region) {
+		return responses.Error(400, "Invalid 'region'")
+	}
+
+	if query["pattern"] == "" || query["token"] == "" {
+		return responses.Error(400, "'pattern' and 'token' are required")
+	}
+
+	err := apply(ctx, region, query["pattern"], query["token"])
+	if err != nil && err.Error() == "subscription not found" {
+		return responses.Error(404, "Subscription not found")
+	}
+
+	if err != nil {
+		log.Printf("Error %s: %v\n", action, err)
+		return responses.Error(500, "Internal server error")
+	}
+
+	return responses.Success(nil)
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
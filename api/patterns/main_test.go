@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bricefrisco/nameslol/shared"
+	"strings"
+	"testing"
+)
+
+type PatternsServiceMock struct {
+	ShouldFail       bool
+	Full             bool
+	Limited          bool
+	NotFound         bool
+	SubscribeCalls   []shared.PatternSubscription
+	ConfirmCalls     []string
+	UnsubscribeCalls []string
+}
+
+func (p *PatternsServiceMock) Subscribe(_ context.Context, subscription shared.PatternSubscription) (*shared.PatternSubscription, error) {
+	p.SubscribeCalls = append(p.SubscribeCalls, subscription)
+	if p.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	if p.Full {
+		return nil, fmt.Errorf("too many pattern alerts for this contact")
+	}
+
+	if p.Limited {
+		return nil, &shared.RateLimitedError{Key: "subscribe#" + subscription.Contact}
+	}
+
+	subscription.ID = "pattern-id"
+	subscription.Token = "secret-token"
+	return &subscription, nil
+}
+
+func (p *PatternsServiceMock) Confirm(_ context.Context, region string, id string, token string) error {
+	p.ConfirmCalls = append(p.ConfirmCalls, region+"#"+id+"#"+token)
+	if p.NotFound {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func (p *PatternsServiceMock) Unsubscribe(_ context.Context, region string, id string, token string) error {
+	p.UnsubscribeCalls = append(p.UnsubscribeCalls, region+"#"+id+"#"+token)
+	if p.ShouldFail {
+		return fmt.Errorf("error")
+	}
+
+	if p.NotFound {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func setup() {
+	patterns = &PatternsServiceMock{}
+	regions = shared.NewRegions()
+	responses = shared.NewHttpResponses("test-origin", "test-methods")
+}
+
+func subscribeRequest(body string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: body}
+}
+
+func TestHandleRequest_Subscribes(t *testing.T) {
+	setup()
+
+	res, err := HandleRequest(context.TODO(), subscribeRequest(`{"region":"euw","minLength":3,"maxLength":4,"kind":"glob","pattern":"dark*","contact":"player@example.com"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d", res.StatusCode)
+	}
+
+	calls := patterns.(*PatternsServiceMock).SubscribeCalls
+	if len(calls) != 1 || calls[0].Region != "EUW" || calls[0].MaxLength != 4 || calls[0].Pattern != "dark*" || calls[0].Contact != "player@example.com" {
+		t.Errorf("Expected subscribe to be called with the request, got %+v", calls)
+	}
+
+	if strings.Contains(res.Body, "secret-token") || !strings.Contains(res.Body, `"id":"pattern-id"`) {
+		t.Errorf("Expected the subscription without its token, got %s", res.Body)
+	}
+}
+
+func TestHandleRequest_SubscribesPushSubscriptions(t *testing.T) {
+	setup()
+
+	body := `{"region":"na","push":{"endpoint":"https://push.example.com/abc","keys":{"p256dh":"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4","auth":"BTBZMqHH6r4Tts7J_aSIgg"}}}`
+	res, _ := HandleRequest(context.TODO(), subscribeRequest(body))
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code 200, got %d: %s", res.StatusCode, res.Body)
+	}
+
+	calls := patterns.(*PatternsServiceMock).SubscribeCalls
+	if len(calls) != 1 || calls[0].Push == nil || calls[0].Push.Endpoint != "https://push.example.com/abc" {
+		t.Errorf("Expected a push subscription, got %+v", calls)
+	}
+}
+
+func TestHandleRequest_RejectsInvalidSubscriptions(t *testing.T) {
+	bodies := []string{
+		`not json`,
+		`{"region":"mars","contact":"player@example.com"}`,
+		`{"region":"na","contact":"not an email"}`,
+		`{"region":"na","contact":"player@example.com","minLength":2}`,
+		`{"region":"na","contact":"player@example.com","pattern":"(unclosed"}`,
+		`{"region":"na","contact":"player@example.com","kind":"sql"}`,
+		`{"region":"na","push":{"endpoint":"http://push.example.com/abc","keys":{"p256dh":"","auth":""}}}`,
+	}
+
+	for _, body := range bodies {
+		setup()
+
+		res, _ := HandleRequest(context.TODO(), subscribeRequest(body))
+		if res.StatusCode != 400 {
+			t.Errorf("%s: expected status code 400, got %d", body, res.StatusCode)
+		}
+
+		if calls := patterns.(*PatternsServiceMock).SubscribeCalls; len(calls) != 0 {
+			t.Errorf("%s: expected no subscription, got %v", body, calls)
+		}
+	}
+}
+
+func TestHandleRequest_Returns409WhenFull(t *testing.T) {
+	setup()
+	patterns.(*PatternsServiceMock).Full = true
+
+	res, _ := HandleRequest(context.TODO(), subscribeRequest(`{"region":"na","contact":"player@example.com"}`))
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status code 409, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_Returns429WhenContactSubscribesTooOften(t *testing.T) {
+	setup()
+	patterns.(*PatternsServiceMock).Limited = true
+
+	res, _ := HandleRequest(context.TODO(), subscribeRequest(`{"region":"na","contact":"player@example.com"}`))
+	if res.StatusCode != 429 {
+		t.Errorf("Expected status code 429, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_Returns500WhenSubscribeFails(t *testing.T) {
+	setup()
+	patterns.(*PatternsServiceMock).ShouldFail = true
+
+	res, _ := HandleRequest(context.TODO(), subscribeRequest(`{"region":"na","contact":"player@example.com"}`))
+	if res.StatusCode != 500 {
+		t.Errorf("Expected status code 500, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_Unsubscribes(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "DELETE",
+		QueryStringParameters: map[string]string{"region": "euw", "pattern": "pattern-id", "token": "secret-token"},
+	})
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d", res.StatusCode)
+	}
+
+	calls := patterns.(*PatternsServiceMock).UnsubscribeCalls
+	if len(calls) != 1 || calls[0] != "EUW#pattern-id#secret-token" {
+		t.Errorf("Expected unsubscribe to be called with the query, got %v", calls)
+	}
+}
+
+func TestHandleRequest_Confirms(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "PUT",
+		QueryStringParameters: map[string]string{"region": "euw", "pattern": "pattern-id", "token": "secret-token"},
+	})
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d", res.StatusCode)
+	}
+
+	calls := patterns.(*PatternsServiceMock).ConfirmCalls
+	if len(calls) != 1 || calls[0] != "EUW#pattern-id#secret-token" {
+		t.Errorf("Expected confirm to be called with the query, got %v", calls)
+	}
+}
+
+func TestHandleRequest_Returns404ForUnknownSubscription(t *testing.T) {
+	setup()
+	patterns.(*PatternsServiceMock).NotFound = true
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "DELETE",
+		QueryStringParameters: map[string]string{"region": "na", "pattern": "pattern-id", "token": "wrong"},
+	})
+	if res.StatusCode != 404 {
+		t.Errorf("Expected status code 404, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_RequiresPatternAndToken(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "DELETE",
+		QueryStringParameters: map[string]string{"region": "na", "pattern": "pattern-id"},
+	})
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code 400, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_RejectsUnsupportedMethods(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	if res.StatusCode != 405 {
+		t.Errorf("Expected status code 405, got %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,42 @@
+terraform {
+  backend "s3" {
+    bucket = "nameslol-deployments"
+    key    = "terraform/api-search"
+    region = "us-east-1"
+  }
+}
+
+provider "aws" {
+  region = "us-east-1"
+}
+
+data "aws_dynamodb_table" "nameslol" {
+  name = "nameslol"
+}
+
+module "lambda" {
+  source = "../../infrastructure/modules/lambda"
+  app_name = "api-search"
+  bootstrap_file_path = "${path.module}/bootstrap"
+  timeout = 15
+  memory_size = 256
+  iam_policy_statements = [
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "dynamodb:Query",
+        "dynamodb:GetItem",
+        "dynamodb:BatchGetItem"
+      ],
+      "Resource" : [
+        data.aws_dynamodb_table.nameslol.arn,
+        "${data.aws_dynamodb_table.nameslol.arn}/index/*"
+      ]
+    },
+  ]
+  environment_variables = {
+    DYNAMODB_TABLE = data.aws_dynamodb_table.nameslol.name
+    CORS_ORIGINS   = "http://localhost:3000"
+    CORS_METHODS   = "GET, OPTIONS"
+  }
+}
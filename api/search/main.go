@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/bricefrisco/nameslol/shared"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type HttpResponsesService interface {
+	Success(responseObj any) events.APIGatewayProxyResponse
+	Error(statusCode int, message string) events.APIGatewayProxyResponse
+}
+
+type SearchService interface {
+	Search(ctx context.Context, query *shared.SearchQuery, limit int32, t1 int64, backwards bool) (*shared.SearchPage, error)
+	Fuzzy(ctx context.Context, query *shared.FuzzyQuery, limit int32) (*shared.SearchPage, error)
+}
+
+// defaultFuzzyDays and maxFuzzyDays bound the window fuzzy searches find names freeing within.
+const defaultFuzzyDays = 30
+const maxFuzzyDays = 365
+
+// SearchResponse carries a page of matching summoners, how many malformed items were left out of it and
+// the timestamp to continue from, which is 0 once there are no more matches.
+type SearchResponse struct {
+	Summoners []*shared.SummonerDTO `json:"summoners"`
+	Warnings  int                   `json:"warnings"`
+	Next      int64                 `json:"next"`
+}
+
+var responses HttpResponsesService
+var search SearchService
+var now = time.Now
+
+func init() {
+	log.SetFlags(0)
+
+	responses = shared.NewHttpResponses(os.Getenv("CORS_ORIGINS"), os.Getenv("CORS_METHODS"))
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Error loading AWS config: %v\n", err)
+	}
+
+	tableName := os.Getenv("DYNAMODB_TABLE")
+	client := shared.NewDynamoDbClient(cfg)
+
+	s := shared.NewNameSearch(client, tableName)
+	s.FilterResults(shared.NewBlocklist(client, tableName, nil).Allows)
+	search = s
+}
+
+// HandleRequest searches the names of a region by prefix, substring or wildcard pattern, in availability
+// date order from the timestamp like the summoners listing. Fuzzy searches instead rank the names that
+// look like the query or are close to it, among those available or freeing within withinDays.
+func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod == "OPTIONS" {
+		return responses.Success(nil), nil
+	}
+
+	if request.HTTPMethod != "GET" {
+		return responses.Error(405, "Method not allowed"), nil
+	}
+
+	params := request.QueryStringParameters
+	mode := params["mode"]
+	if mode == "" {
+		mode = shared.SearchContains
+	}
+
+	if mode == shared.SearchFuzzy {
+		return fuzzy(ctx, params), nil
+	}
+
+	query := &shared.SearchQuery{Region: strings.ToUpper(params["region"]), Mode: mode, Query: params["q"]}
+	if err := query.Validate(); err != nil {
+		return responses.Error(400, "Invalid search, "+err.Error()), nil
+	}
+
+	t1, err := strconv.ParseInt(params["timestamp"], 10, 64)
+	if err != nil || t1 <= 0 {
+		return responses.Error(400, "Invalid 'timestamp' query parameter"), nil
+	}
+
+	var backwards bool
+	if params["backwards"] != "" {
+		backwards, err = strconv.ParseBool(params["backwards"])
+		if err != nil {
+			return responses.Error(400, "Invalid 'backwards' query parameter"), nil
+		}
+	}
+
+	page, err := search.Search(ctx, query, 35, t1, backwards)
+	if err != nil {
+		log.Printf("Error searching: %v\n", err)
+		return responses.Error(500, "Internal server error"), nil
+	}
+
+	return respond(page), nil
+}
+
+func fuzzy(ctx context.Context, params map[string]string) events.APIGatewayProxyResponse {
+	days := defaultFuzzyDays
+	if params["withinDays"] != "" {
+		var err error
+		days, err = strconv.Atoi(params["withinDays"])
+		if err != nil || days < 0 || days > maxFuzzyDays {
+			return responses.Error(400, "Invalid 'withinDays' query parameter")
+		}
+	}
+
+	distance := 2
+	if params["distance"] != "" {
+		var err error
+		if distance, err = strconv.Atoi(params["distance"]); err != nil {
+			return responses.Error(400, "Invalid 'distance' query parameter")
+		}
+	}
+
+	query := &shared.FuzzyQuery{
+		Region:      strings.ToUpper(params["region"]),
+		Query:       params["q"],
+		MaxDistance: distance,
+		Until:       now().AddDate(0, 0, days).UnixMilli(),
+	}
+	if err := query.Validate(); err != nil {
+		return responses.Error(400, "Invalid search, "+err.Error())
+	}
+
+	page, err := search.Fuzzy(ctx, query, 35)
+	if err != nil {
+		log.Printf("Error searching: %v\n", err)
+		return responses.Error(500, "Internal server error")
+	}
+
+	return respond(page)
+}
+
+func respond(page *shared.SearchPage) events.APIGatewayProxyResponse {
+	for _, skipped := range page.Skipped {
+		log.Printf("Skipped malformed summoner '%s': %s\n", skipped.Key, skipped.Reason)
+	}
+
+	if page.Filtered > 0 {
+		log.Printf("Left %d blocked summoners out of the page\n", page.Filtered)
+	}
+
+	return responses.Success(&SearchResponse{Summoners: page.Summoners, Warnings: len(page.Skipped), Next: page.Next})
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
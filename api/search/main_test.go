@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bricefrisco/nameslol/shared"
+	"strings"
+	"testing"
+	"time"
+)
+
+type SearchServiceMock struct {
+	ShouldFail bool
+	Calls      []string
+}
+
+func (s *SearchServiceMock) Search(_ context.Context, query *shared.SearchQuery, limit int32, t1 int64, backwards bool) (*shared.SearchPage, error) {
+	s.Calls = append(s.Calls, fmt.Sprintf("%s %s %s %d %d %t", query.Region, query.Mode, query.Query, limit, t1, backwards))
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &shared.SearchPage{Summoners: []*shared.SummonerDTO{{Name: "darkwolf", Region: query.Region}}, Next: 42}, nil
+}
+
+func (s *SearchServiceMock) Fuzzy(_ context.Context, query *shared.FuzzyQuery, limit int32) (*shared.SearchPage, error) {
+	s.Calls = append(s.Calls, fmt.Sprintf("%s fuzzy %s %d %d %d", query.Region, query.Query, query.MaxDistance, query.Until, limit))
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &shared.SearchPage{Summoners: []*shared.SummonerDTO{{Name: "fɑker", Region: query.Region}}}, nil
+}
+
+func setup() {
+	search = &SearchServiceMock{}
+	responses = shared.NewHttpResponses("test-origin", "test-methods")
+	now = func() time.Time { return time.UnixMilli(1000) }
+}
+
+func searchRequest(params map[string]string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{HTTPMethod: "GET", QueryStringParameters: params}
+}
+
+func TestHandleRequest_Searches(t *testing.T) {
+	setup()
+
+	res, err := HandleRequest(context.TODO(), searchRequest(map[string]string{"region": "euw", "q": "Wolf", "mode": "prefix", "timestamp": "100", "backwards": "true"}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if res.StatusCode != 200 || !strings.Contains(res.Body, `"next":42`) || !strings.Contains(res.Body, `"name":"darkwolf"`) {
+		t.Errorf("Expected the page and its cursor, got %d: %s", res.StatusCode, res.Body)
+	}
+
+	calls := search.(*SearchServiceMock).Calls
+	if len(calls) != 1 || calls[0] != "EUW prefix wolf 35 100 true" {
+		t.Errorf("Expected search to be called with the normalized query, got %v", calls)
+	}
+}
+
+func TestHandleRequest_DefaultsToContains(t *testing.T) {
+	setup()
+
+	_, _ = HandleRequest(context.TODO(), searchRequest(map[string]string{"region": "na", "q": "wolf", "timestamp": "1"}))
+	if calls := search.(*SearchServiceMock).Calls; len(calls) != 1 || calls[0] != "NA contains wolf 35 1 false" {
+		t.Errorf("Expected a contains search, got %v", calls)
+	}
+}
+
+func TestHandleRequest_RejectsInvalidSearches(t *testing.T) {
+	params := []map[string]string{
+		{"region": "mars", "q": "wolf", "timestamp": "1"},
+		{"region": "na", "q": "", "timestamp": "1"},
+		{"region": "na", "q": "wo", "mode": "contains", "timestamp": "1"},
+		{"region": "na", "q": "wolf", "mode": "regex", "timestamp": "1"},
+		{"region": "na", "q": "wolf", "timestamp": "abc"},
+		{"region": "na", "q": "wolf", "timestamp": "0"},
+		{"region": "na", "q": "wolf", "timestamp": "1", "backwards": "maybe"},
+	}
+
+	for _, p := range params {
+		setup()
+
+		res, _ := HandleRequest(context.TODO(), searchRequest(p))
+		if res.StatusCode != 400 {
+			t.Errorf("%v: expected status code 400, got %d", p, res.StatusCode)
+		}
+
+		if calls := search.(*SearchServiceMock).Calls; len(calls) != 0 {
+			t.Errorf("%v: expected no search, got %v", p, calls)
+		}
+	}
+}
+
+func TestHandleRequest_Returns500WhenSearchFails(t *testing.T) {
+	setup()
+	search.(*SearchServiceMock).ShouldFail = true
+
+	res, _ := HandleRequest(context.TODO(), searchRequest(map[string]string{"region": "na", "q": "wolf", "timestamp": "1"}))
+	if res.StatusCode != 500 {
+		t.Errorf("Expected status code 500, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_RejectsUnsupportedMethods(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "POST"})
+	if res.StatusCode != 405 {
+		t.Errorf("Expected status code 405, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_SearchesFuzzily(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), searchRequest(map[string]string{"region": "euw", "q": "Faker", "mode": "fuzzy", "withinDays": "1", "distance": "1"}))
+	if res.StatusCode != 200 || !strings.Contains(res.Body, `"name":"fɑker"`) {
+		t.Errorf("Expected the ranked names, got %d: %s", res.StatusCode, res.Body)
+	}
+
+	calls := search.(*SearchServiceMock).Calls
+	if len(calls) != 1 || calls[0] != "EUW fuzzy Faker 1 86401000 35" {
+		t.Errorf("Expected a fuzzy search within a day, got %v", calls)
+	}
+}
+
+func TestHandleRequest_DefaultsFuzzySearches(t *testing.T) {
+	setup()
+
+	_, _ = HandleRequest(context.TODO(), searchRequest(map[string]string{"region": "euw", "q": "Faker", "mode": "fuzzy"}))
+	if calls := search.(*SearchServiceMock).Calls; len(calls) != 1 || calls[0] != "EUW fuzzy Faker 2 2592001000 35" {
+		t.Errorf("Expected two edits within 30 days, got %v", calls)
+	}
+}
+
+func TestHandleRequest_RejectsInvalidFuzzySearches(t *testing.T) {
+	params := []map[string]string{
+		{"region": "euw", "q": "faker", "mode": "fuzzy", "withinDays": "366"},
+		{"region": "euw", "q": "faker", "mode": "fuzzy", "withinDays": "soon"},
+		{"region": "euw", "q": "faker", "mode": "fuzzy", "distance": "3"},
+		{"region": "euw", "q": "faker", "mode": "fuzzy", "distance": "far"},
+		{"region": "euw", "q": "", "mode": "fuzzy"},
+	}
+
+	for _, p := range params {
+		setup()
+
+		res, _ := HandleRequest(context.TODO(), searchRequest(p))
+		if res.StatusCode != 400 || len(search.(*SearchServiceMock).Calls) != 0 {
+			t.Errorf("%v: expected status code 400 without a search, got %d", p, res.StatusCode)
+		}
+	}
+}
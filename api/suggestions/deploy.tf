@@ -0,0 +1,74 @@
+terraform {
+  backend "s3" {
+    bucket = "nameslol-deployments"
+    key    = "terraform/api-suggestions"
+    region = "us-east-1"
+  }
+}
+
+provider "aws" {
+  region = "us-east-1"
+}
+
+data "aws_dynamodb_table" "nameslol" {
+  name = "nameslol"
+}
+
+data "aws_sqs_queue" "webhook-delivery-queue" {
+  name = "WebhookDeliveryQueue"
+}
+
+data "aws_ssm_parameter" "riot-api-token" {
+  name = "/riot-api-token"
+}
+
+module "lambda" {
+  source = "../../infrastructure/modules/lambda"
+  app_name = "api-suggestions"
+  bootstrap_file_path = "${path.module}/bootstrap"
+  timeout = 30
+  memory_size = 256
+  iam_policy_statements = [
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "dynamodb:PutItem",
+        "dynamodb:GetItem",
+        "dynamodb:UpdateItem",
+        "dynamodb:DeleteItem",
+        "dynamodb:BatchWriteItem",
+      ],
+      "Resource" : [
+        data.aws_dynamodb_table.nameslol.arn,
+        "${data.aws_dynamodb_table.nameslol.arn}/index/*"
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "sqs:SendMessage"
+      ],
+      "Resource" : [
+        data.aws_sqs_queue.webhook-delivery-queue.arn
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "ssm:GetParameter"
+      ],
+      "Resource" : [
+        data.aws_ssm_parameter.riot-api-token.arn
+      ]
+    },
+  ]
+  environment_variables = {
+    DYNAMODB_TABLE        = data.aws_dynamodb_table.nameslol.name
+    RIOT_API_KEY_SOURCE   = "ssm"
+    RIOT_API_KEY_NAMES    = data.aws_ssm_parameter.riot-api-token.name
+    RIOT_RATE_LIMIT_STORE = "dynamodb"
+    CORS_ORIGINS          = "http://localhost:3000"
+    CORS_METHODS          = "GET, OPTIONS"
+    WEBHOOK_QUEUE_URL     = data.aws_sqs_queue.webhook-delivery-queue.url
+  }
+}
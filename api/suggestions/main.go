@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/bricefrisco/nameslol/shared"
+	"log"
+	"os"
+	"strings"
+)
+
+type SuggesterService interface {
+	Suggest(ctx context.Context, region string, name string) ([]shared.Suggestion, error)
+}
+
+type RegionsService interface {
+	Validate(region string) bool
+}
+
+type NameValidatorService interface {
+	Validate(region string, name string) shared.ValidationErrors
+}
+
+type HttpResponsesService interface {
+	Success(responseObj any) events.APIGatewayProxyResponse
+	Error(statusCode int, message string) events.APIGatewayProxyResponse
+	ValidationError(errs shared.ValidationErrors) events.APIGatewayProxyResponse
+}
+
+// maxLookups is how many variants of a name are checked with Riot per request.
+const maxLookups = 5
+
+var suggester SuggesterService
+var regions RegionsService
+var validator NameValidatorService
+var responses HttpResponsesService
+
+func init() {
+	log.SetFlags(0)
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Error loading AWS config: %v\n", err)
+	}
+
+	tableName := os.Getenv("DYNAMODB_TABLE")
+	s, err := shared.NewSummoners(tableName, shared.WorkloadSuggestions)
+	if err != nil {
+		log.Fatalf("Error creating summoners: %v\n", err)
+	}
+	s.IndexNames(shared.NewNameSearch(shared.NewDynamoDbClient(cfg), tableName))
+
+	if queueUrl := os.Getenv("WEBHOOK_QUEUE_URL"); queueUrl != "" {
+		s.PublishEvents(shared.NewWebhooks(shared.NewDynamoDbClient(cfg), tableName, sqs.NewFromConfig(cfg), queueUrl))
+	}
+
+	regions = shared.NewRegions()
+	validator = shared.NewNameValidator()
+	blocklist := shared.NewBlocklist(shared.NewDynamoDbClient(cfg), tableName, nil)
+	suggester = shared.NewNameSuggester(s, blocklist, validator, maxLookups)
+	responses = shared.NewHttpResponses(os.Getenv("CORS_ORIGINS"), os.Getenv("CORS_METHODS"))
+}
+
+// HandleRequest suggests variants of a name with whether each is available, expired or taken.
+func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod == "OPTIONS" {
+		return responses.Success(nil), nil
+	}
+
+	if request.HTTPMethod != "GET" {
+		return responses.Error(405, "Method not allowed"), nil
+	}
+
+	region := strings.ToUpper(request.QueryStringParameters["region"])
+	if !regions.Validate(region) {
+		return responses.Error(400, "Invalid 'region' query parameter"), nil
+	}
+
+	name := request.QueryStringParameters["name"]
+	if errs := validator.Validate(region, name); len(errs) > 0 {
+		return responses.ValidationError(errs), nil
+	}
+
+	suggestions, err := suggester.Suggest(ctx, region, name)
+	if err != nil {
+		log.Printf("Error suggesting names: %v\n", err)
+		return responses.Error(500, "Internal server error"), nil
+	}
+
+	return responses.Success(suggestions), nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
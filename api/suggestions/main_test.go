@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bricefrisco/nameslol/shared"
+	"strings"
+	"testing"
+)
+
+type SuggesterServiceMock struct {
+	ShouldFail bool
+	Calls      []string
+}
+
+func (s *SuggesterServiceMock) Suggest(_ context.Context, region string, name string) ([]shared.Suggestion, error) {
+	s.Calls = append(s.Calls, region+" "+name)
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return []shared.Suggestion{
+		{NameVariant: shared.NameVariant{Name: name + "s", Kind: shared.VariantPlural}, Verdict: shared.VerdictTaken, Source: "table", AvailabilityDate: 42},
+		{NameVariant: shared.NameVariant{Name: "x" + name, Kind: shared.VariantPrefix}, Verdict: shared.VerdictAvailable, Source: "riot"},
+	}, nil
+}
+
+func setup() {
+	suggester = &SuggesterServiceMock{}
+	regions = shared.NewRegions()
+	validator = shared.NewNameValidator()
+	responses = shared.NewHttpResponses("test-origin", "test-methods")
+}
+
+func suggestionsRequest(region string, name string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{HTTPMethod: "GET", QueryStringParameters: map[string]string{"region": region, "name": name}}
+}
+
+func TestHandleRequest_Suggests(t *testing.T) {
+	setup()
+
+	res, err := HandleRequest(context.TODO(), suggestionsRequest("na", "Faker"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := `[{"name":"Fakers","kind":"plural","verdict":"taken","source":"table","availabilityDate":42},{"name":"xFaker","kind":"prefix","verdict":"available","source":"riot"}]`
+	if res.StatusCode != 200 || res.Body != expected {
+		t.Errorf("Expected the suggestions, got %d: %s", res.StatusCode, res.Body)
+	}
+
+	if calls := suggester.(*SuggesterServiceMock).Calls; len(calls) != 1 || calls[0] != "NA Faker" {
+		t.Errorf("Expected suggestions for the name in its region, got %v", calls)
+	}
+}
+
+func TestHandleRequest_RejectsInvalidRegion(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), suggestionsRequest("mars", "Faker"))
+	if res.StatusCode != 400 || len(suggester.(*SuggesterServiceMock).Calls) != 0 {
+		t.Errorf("Expected status code 400 without suggestions, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_RejectsInvalidName(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), suggestionsRequest("na", "ab"))
+	if res.StatusCode != 400 || !strings.Contains(res.Body, "name") || len(suggester.(*SuggesterServiceMock).Calls) != 0 {
+		t.Errorf("Expected a validation error without suggestions, got %d: %s", res.StatusCode, res.Body)
+	}
+}
+
+func TestHandleRequest_Returns500WhenSuggestingFails(t *testing.T) {
+	setup()
+	suggester.(*SuggesterServiceMock).ShouldFail = true
+
+	res, _ := HandleRequest(context.TODO(), suggestionsRequest("na", "Faker"))
+	if res.StatusCode != 500 {
+		t.Errorf("Expected status code 500, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_RejectsUnsupportedMethods(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "POST"})
+	if res.StatusCode != 405 {
+		t.Errorf("Expected status code 405, got %d", res.StatusCode)
+	}
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/bricefrisco/nameslol/shared"
 	"log"
 	"os"
@@ -19,29 +22,53 @@ type RegionsService interface {
 	Validate(region string) bool
 }
 
+type NameValidatorService interface {
+	Validate(region string, name string) shared.ValidationErrors
+}
+
+type BlocklistService interface {
+	Match(ctx context.Context, region string, name string) (*shared.BlockMatch, error)
+}
+
 type HttpResponsesService interface {
 	Success(responseObj any) events.APIGatewayProxyResponse
 	Error(statusCode int, message string) events.APIGatewayProxyResponse
+	ValidationError(errs shared.ValidationErrors) events.APIGatewayProxyResponse
 }
 
 var summoners SummonersService
 var regions RegionsService
+var validator NameValidatorService
+var blocklist BlocklistService
 var responses HttpResponsesService
 
 func init() {
 	log.SetFlags(0)
 
-	var err error
-	summoners, err = shared.NewSummoners(os.Getenv("DYNAMODB_TABLE"), os.Getenv("RIOT_API_TOKEN"))
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Error loading AWS config: %v\n", err)
+	}
+
+	tableName := os.Getenv("DYNAMODB_TABLE")
+	s, err := shared.NewSummoners(tableName, shared.WorkloadInteractive)
 	if err != nil {
 		log.Fatalf("Error creating summoners: %v\n", err)
 	}
+	summoners = s
+	s.IndexNames(shared.NewNameSearch(shared.NewDynamoDbClient(cfg), tableName))
+
+	if queueUrl := os.Getenv("WEBHOOK_QUEUE_URL"); queueUrl != "" {
+		s.PublishEvents(shared.NewWebhooks(shared.NewDynamoDbClient(cfg), tableName, sqs.NewFromConfig(cfg), queueUrl))
+	}
 
 	regions = shared.NewRegions()
+	validator = shared.NewNameValidator()
+	blocklist = shared.NewBlocklist(shared.NewDynamoDbClient(cfg), tableName, nil)
 	responses = shared.NewHttpResponses(os.Getenv("CORS_ORIGINS"), os.Getenv("CORS_METHODS"))
 }
 
-func HandleRequest(_ context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	if request.HTTPMethod == "OPTIONS" {
 		return responses.Success(nil), nil
 	}
@@ -50,37 +77,56 @@ func HandleRequest(_ context.Context, request events.APIGatewayProxyRequest) (ev
 		return responses.Error(405, "Method not allowed"), nil
 	}
 
-	name := request.QueryStringParameters["name"]
-	if len(name) < 3 {
-		return responses.Error(400, "Query parameter 'name' must be at least 3 characters"), nil
-	}
-
-	if len(name) > 16 {
-		return responses.Error(400, "Query parameter 'name' must be at most 16 characters"), nil
-	}
-
 	region := strings.ToUpper(request.QueryStringParameters["region"])
 	if !regions.Validate(region) {
 		return responses.Error(400, "Invalid 'region' query parameter"), nil
 	}
 
+	name := request.QueryStringParameters["name"]
+	if errs := validator.Validate(region, name); len(errs) > 0 {
+		return responses.ValidationError(errs), nil
+	}
+
 	result, err := summoners.Fetch(region, name)
 	if err != nil {
 		if err.Error() == "summoner not found" {
 			return responses.Error(404, "Summoner not found"), nil
 		}
 
+		if shared.IsThrottled(err) {
+			log.Printf("Riot API budget exhausted: %v\n", err)
+			return responses.Error(429, "Too many requests, please try again later"), nil
+		}
+
+		var circuitErr *shared.CircuitOpenError
+		if errors.As(err, &circuitErr) {
+			log.Printf("Riot API unavailable: %v\n", err)
+			return responses.Error(503, "Riot API is temporarily unavailable, please try again later"), nil
+		}
+
 		log.Printf("Error fetching summoner: %v\n", err)
 		return responses.Error(500, "Internal server error"), nil
 	}
 
 	err = summoners.Save(result)
-	if err != nil {
+	if shared.IsSuppressed(err) {
+		log.Printf("Not returning summoner of erased account: %v\n", err)
+		return responses.Error(404, "Summoner not found"), nil
+	} else if err != nil {
 		log.Printf("Error saving summoner: %v\n", err)
 	} else {
 		log.Printf("Successfully saved summoner: %v\n", result)
 	}
 
+	// Blocked names are still saved so they keep being tracked, but are not shown publicly.
+	match, err := blocklist.Match(ctx, region, result.Name)
+	if err != nil {
+		log.Printf("Error checking blocklist: %v\n", err)
+	} else if match != nil {
+		log.Printf("Blocked summoner '%s' by %s rule '%s'\n", result.Name, match.Rule.Kind, match.Term)
+		return responses.Error(404, "Summoner not found"), nil
+	}
+
 	return responses.Success(result), nil
 }
 
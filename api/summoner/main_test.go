@@ -14,6 +14,10 @@ type SummonersServiceMock struct {
 	ShouldFetchFail      bool
 	ShouldSaveFail       bool
 	ShouldReturnNotFound bool
+	ShouldCircuitBeOpen  bool
+	ShouldExhaustBudget  bool
+	ShouldBeSuppressed   bool
+	SaveCalls            int
 	Calls                []struct {
 		Region string
 		Name   string
@@ -25,6 +29,23 @@ type RegionsServiceMock struct {
 	Calls      []string
 }
 
+type BlocklistServiceMock struct {
+	Blocked    bool
+	ShouldFail bool
+}
+
+func (b *BlocklistServiceMock) Match(_ context.Context, _ string, _ string) (*shared.BlockMatch, error) {
+	if b.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	if b.Blocked {
+		return &shared.BlockMatch{Rule: shared.BlockRule{Kind: shared.BlockExact, Value: "test"}, Term: "test"}, nil
+	}
+
+	return nil, nil
+}
+
 var summonerDto *shared.SummonerDTO
 var corsOrigins = "test-origin"
 var corsMethods = "test-methods"
@@ -49,6 +70,8 @@ func setup() {
 
 	summoners = &SummonersServiceMock{}
 	regions = &RegionsServiceMock{}
+	validator = shared.NewNameValidator()
+	blocklist = &BlocklistServiceMock{}
 	responses = shared.NewHttpResponses(corsOrigins, corsMethods)
 }
 
@@ -66,14 +89,28 @@ func (s *SummonersServiceMock) Fetch(region string, name string) (*shared.Summon
 		return nil, fmt.Errorf("summoner not found")
 	}
 
+	if s.ShouldExhaustBudget {
+		return nil, &shared.BudgetExhaustedError{Workload: shared.WorkloadInteractive}
+	}
+
+	if s.ShouldCircuitBeOpen {
+		return nil, &shared.CircuitOpenError{Reason: "test"}
+	}
+
 	return summonerDto, nil
 }
 
 func (s *SummonersServiceMock) Save(_ *shared.SummonerDTO) error {
+	s.SaveCalls++
+
 	if s.ShouldSaveFail {
 		return fmt.Errorf("error")
 	}
 
+	if s.ShouldBeSuppressed {
+		return &shared.SuppressedError{Name: "test"}
+	}
+
 	return nil
 }
 
@@ -133,6 +170,59 @@ func TestHandleRequest_ValidatesNameTooLong(t *testing.T) {
 	}
 }
 
+func TestHandleRequest_ValidatesNameCharacters(t *testing.T) {
+	setup()
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		QueryStringParameters: map[string]string{
+			"region": "NA",
+			"name":   "Test/../x",
+		},
+	}
+
+	res, err := HandleRequest(context.TODO(), request)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code 400, got %d", res.StatusCode)
+	}
+
+	var body shared.ErrResponse
+	_ = json.Unmarshal([]byte(res.Body), &body)
+
+	if len(body.Errors) != 1 || body.Errors[0].Field != "name" {
+		t.Errorf("Expected a field error for 'name', got %v", body.Errors)
+	}
+
+	if len(summoners.(*SummonersServiceMock).Calls) != 0 {
+		t.Errorf("Expected no calls to Fetch, got %d", len(summoners.(*SummonersServiceMock).Calls))
+	}
+}
+
+func TestHandleRequest_CountsNameCharactersNotBytes(t *testing.T) {
+	setup()
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		QueryStringParameters: map[string]string{
+			"region": "EUW",
+			"name":   "ÀÁÂÃÄÅÆÇÈÉÊËÌÍÎÏ",
+		},
+	}
+
+	res, err := HandleRequest(context.TODO(), request)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d: %s", res.StatusCode, res.Body)
+	}
+}
+
 func TestHandleRequest_ValidatesRegion(t *testing.T) {
 	setup()
 
@@ -257,6 +347,58 @@ func TestHandleRequest_Returns404WhenSummonerNotFound(t *testing.T) {
 	}
 }
 
+func TestHandleRequest_Returns503WhenRiotCircuitIsOpen(t *testing.T) {
+	setup()
+
+	mockSummoners := summoners.(*SummonersServiceMock)
+	mockSummoners.ShouldCircuitBeOpen = true
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		QueryStringParameters: map[string]string{
+			"region": "NA",
+			"name":   "Test",
+		},
+	}
+
+	res, err := HandleRequest(context.TODO(), request)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if res.StatusCode != 503 {
+		t.Errorf("Expected status code 503, got %d", res.StatusCode)
+	}
+
+	if !strings.Contains(res.Body, "temporarily unavailable") {
+		t.Errorf("Expected body to contain 'temporarily unavailable', got %s", res.Body)
+	}
+}
+
+func TestHandleRequest_Returns429WhenRiotBudgetIsExhausted(t *testing.T) {
+	setup()
+
+	mockSummoners := summoners.(*SummonersServiceMock)
+	mockSummoners.ShouldExhaustBudget = true
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		QueryStringParameters: map[string]string{
+			"region": "NA",
+			"name":   "Test",
+		},
+	}
+
+	res, err := HandleRequest(context.TODO(), request)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if res.StatusCode != 429 {
+		t.Errorf("Expected status code 429, got %d", res.StatusCode)
+	}
+}
+
 func TestHandleRequest_ReturnsSummonerOnSuccess(t *testing.T) {
 	setup()
 
@@ -416,3 +558,52 @@ func TestHandleRequest_Returns405OnInvalidMethod(t *testing.T) {
 		t.Errorf("Expected body to contain 'Method not allowed', got %s", res.Body)
 	}
 }
+
+func TestHandleRequest_Returns404ForBlockedSummonerButStillSavesIt(t *testing.T) {
+	setup()
+	blocklist.(*BlocklistServiceMock).Blocked = true
+
+	response, err := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"region": "NA", "name": "test"},
+	})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if response.StatusCode != 404 {
+		t.Errorf("expected 404, got %d", response.StatusCode)
+	}
+
+	if summoners.(*SummonersServiceMock).SaveCalls != 1 {
+		t.Errorf("expected blocked summoner to be saved")
+	}
+}
+
+func TestHandleRequest_Returns404ForSummonerOfErasedAccount(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).ShouldBeSuppressed = true
+
+	response, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"region": "NA", "name": "test"},
+	})
+
+	if response.StatusCode != 404 {
+		t.Errorf("expected 404, got %d", response.StatusCode)
+	}
+}
+
+func TestHandleRequest_ReturnsSummonerWhenBlocklistFails(t *testing.T) {
+	setup()
+	blocklist.(*BlocklistServiceMock).ShouldFail = true
+
+	response, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"region": "NA", "name": "test"},
+	})
+
+	if response.StatusCode != 200 {
+		t.Errorf("expected 200, got %d", response.StatusCode)
+	}
+}
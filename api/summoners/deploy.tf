@@ -14,10 +14,6 @@ data "aws_dynamodb_table" "nameslol" {
   name = "nameslol"
 }
 
-data "aws_ssm_parameter" "riot-api-token" {
-  name = "/riot-api-token"
-}
-
 module "lambda" {
   source = "../../infrastructure/modules/lambda"
   app_name = "api-summoners"
@@ -28,7 +24,8 @@ module "lambda" {
     {
       "Effect" : "Allow",
       "Action" : [
-        "dynamodb:Query"
+        "dynamodb:Query",
+        "dynamodb:GetItem"
       ],
       "Resource" : [
         data.aws_dynamodb_table.nameslol.arn,
@@ -38,7 +35,6 @@ module "lambda" {
   ]
   environment_variables = {
     DYNAMODB_TABLE = data.aws_dynamodb_table.nameslol.name
-    RIOT_API_TOKEN = data.aws_ssm_parameter.riot-api-token.value
     CORS_ORIGINS   = "http://localhost:3000"
     CORS_METHODS   = "GET, OPTIONS"
   }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/bricefrisco/nameslol/shared"
 	"log"
 	"os"
@@ -21,8 +22,28 @@ type HttpResponsesService interface {
 }
 
 type SummonersService interface {
-	GetByNameLength(region string, limit int32, nameLength int32, t1 int64, backwards bool) ([]*shared.SummonerDTO, error)
-	GetAfter(region string, limit int32, t1 int64, backwards bool) ([]*shared.SummonerDTO, error)
+	GetByNameLength(region string, limit int32, nameLength int32, t1 int64, backwards bool, filter shared.ListingFilter) (*shared.SummonersPage, error)
+	GetAfter(region string, limit int32, t1 int64, backwards bool, filter shared.ListingFilter) (*shared.SummonersPage, error)
+	GetByNameLengths(region string, limit int32, minLength int32, maxLength int32, t1 int64, backwards bool, filter shared.ListingFilter) (*shared.SummonersPage, error)
+	GetByScore(region string, limit int32, minLength int32, maxLength int32, t1 int64, t2 int64, filter shared.ListingFilter) (*shared.SummonersPage, error)
+}
+
+// minNameLength and maxNameLength bound the name lengths the listing can be narrowed to.
+const minNameLength = 3
+const maxNameLength = 16
+
+// maxScoreWindow is the widest date window, in milliseconds, that summoners can be sorted by score within.
+const maxScoreWindow = 7 * 24 * 60 * 60 * 1000
+
+// SummonersResponse carries the summoners that could be read along with how many malformed items were
+// left out of the page, and for pages sorted by score whether only part of the window was ranked. Next is
+// the timestamp to continue from, which may be past the last summoner when filters dropped the names
+// after it, and is 0 once there are no more names.
+type SummonersResponse struct {
+	Summoners []*shared.SummonerDTO `json:"summoners"`
+	Warnings  int                   `json:"warnings"`
+	Truncated bool                  `json:"truncated,omitempty"`
+	Next      int64                 `json:"next"`
 }
 
 var regions RegionsService
@@ -31,14 +52,24 @@ var summoners SummonersService
 
 func init() {
 	log.SetFlags(0)
-	var err error
 
 	regions = shared.NewRegions()
 	responses = shared.NewHttpResponses(os.Getenv("CORS_ORIGINS"), os.Getenv("CORS_METHODS"))
-	summoners, err = shared.NewSummoners(os.Getenv("DYNAMODB_TABLE"), os.Getenv("RIOT_API_TOKEN"))
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Error loading AWS config: %v\n", err)
+	}
+
+	tableName := os.Getenv("DYNAMODB_TABLE")
+	s, err := shared.NewSummoners(tableName, shared.WorkloadInteractive)
 	if err != nil {
 		log.Fatalf("Error creating summoners service: %v\n", err)
 	}
+
+	blocklist := shared.NewBlocklist(shared.NewDynamoDbClient(cfg), tableName, nil)
+	s.FilterListings(blocklist.Allows)
+	summoners = s
 }
 
 func HandleRequest(_ context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
@@ -64,17 +95,35 @@ func HandleRequest(_ context.Context, request events.APIGatewayProxyRequest) (ev
 		return responses.Error(400, "Invalid 'timestamp' query parameter"), nil
 	}
 
-	var nameLength int
+	// nameLength is a shorthand for a range of one length, and cannot be combined with a range.
+	var minLength, maxLength int
 	nameLengthStr := request.QueryStringParameters["nameLength"]
 	if nameLengthStr != "" {
-		nameLength, err = strconv.Atoi(nameLengthStr)
+		nameLength, err := strconv.Atoi(nameLengthStr)
 		if err != nil {
 			return responses.Error(400, "Invalid 'nameLength' query parameter"), nil
 		}
 
-		if nameLength < 3 || nameLength > 16 {
+		if nameLength < minNameLength || nameLength > maxNameLength {
 			return responses.Error(400, "Invalid 'nameLength' query parameter"), nil
 		}
+
+		if request.QueryStringParameters["minLength"] != "" || request.QueryStringParameters["maxLength"] != "" {
+			return responses.Error(400, "Invalid 'nameLength' query parameter, cannot be combined with a length range"), nil
+		}
+
+		minLength, maxLength = nameLength, nameLength
+	}
+
+	if request.QueryStringParameters["minLength"] != "" || request.QueryStringParameters["maxLength"] != "" {
+		var ok bool
+		if minLength, ok = optionalInt(request, "minLength", minNameLength); !ok || minLength < minNameLength || minLength > maxNameLength {
+			return responses.Error(400, "Invalid 'minLength' query parameter"), nil
+		}
+
+		if maxLength, ok = optionalInt(request, "maxLength", maxNameLength); !ok || maxLength < minLength || maxLength > maxNameLength {
+			return responses.Error(400, "Invalid 'maxLength' query parameter"), nil
+		}
 	}
 
 	var backwards bool
@@ -85,18 +134,90 @@ func HandleRequest(_ context.Context, request events.APIGatewayProxyRequest) (ev
 		}
 	}
 
-	var response []*shared.SummonerDTO
-	if nameLength == 0 {
-		response, err = summoners.GetAfter(region, 35, int64(t1), backwards)
-	} else {
-		response, err = summoners.GetByNameLength(region, 35, int32(nameLength), int64(t1), backwards)
+	filter, param := listingFilter(request)
+	if param != "" {
+		return responses.Error(400, "Invalid '"+param+"' query parameter"), nil
+	}
+
+	var page *shared.SummonersPage
+	switch request.QueryStringParameters["sort"] {
+	case "", "date":
+		switch {
+		case minLength == 0:
+			page, err = summoners.GetAfter(region, 35, int64(t1), backwards, filter)
+		case minLength == maxLength:
+			page, err = summoners.GetByNameLength(region, 35, int32(minLength), int64(t1), backwards, filter)
+		default:
+			page, err = summoners.GetByNameLengths(region, 35, int32(minLength), int32(maxLength), int64(t1), backwards, filter)
+		}
+	case "score":
+		// Sorting by score ranks the names freeing between timestamp and until rather than paging on.
+		t2, parseErr := strconv.ParseInt(request.QueryStringParameters["until"], 10, 64)
+		if parseErr != nil || t2 <= int64(t1) || t2-int64(t1) > maxScoreWindow {
+			return responses.Error(400, "Invalid 'until' query parameter"), nil
+		}
+
+		page, err = summoners.GetByScore(region, 35, int32(minLength), int32(maxLength), int64(t1), t2, filter)
+	default:
+		return responses.Error(400, "Invalid 'sort' query parameter"), nil
 	}
 
 	if err != nil {
 		return responses.Error(500, "Internal server error"), nil
 	}
 
-	return responses.Success(response), nil
+	for _, skipped := range page.Skipped {
+		log.Printf("Skipped malformed summoner '%s': %s\n", skipped.Key, skipped.Reason)
+	}
+
+	if page.Filtered > 0 {
+		log.Printf("Left %d blocked summoners out of the page\n", page.Filtered)
+	}
+
+	return responses.Success(&SummonersResponse{Summoners: page.Summoners, Warnings: len(page.Skipped), Truncated: page.Truncated, Next: page.Next}), nil
+}
+
+// listingFilter reads the score, level and revision date filters, returning the name of the first invalid
+// parameter if any.
+func listingFilter(request events.APIGatewayProxyRequest) (shared.ListingFilter, string) {
+	var filter shared.ListingFilter
+	var ok bool
+
+	if filter.MinScore, ok = optionalInt(request, "minScore", 0); !ok || filter.MinScore < 0 || filter.MinScore > 100 {
+		return filter, "minScore"
+	}
+
+	if filter.MinLevel, ok = optionalInt(request, "minLevel", 0); !ok || filter.MinLevel < 0 {
+		return filter, "minLevel"
+	}
+
+	if filter.MaxLevel, ok = optionalInt(request, "maxLevel", 0); !ok || filter.MaxLevel < 0 || (filter.MaxLevel > 0 && filter.MaxLevel < filter.MinLevel) {
+		return filter, "maxLevel"
+	}
+
+	revisionBefore, ok := optionalInt(request, "revisionBefore", 0)
+	if !ok || revisionBefore < 0 {
+		return filter, "revisionBefore"
+	}
+
+	revisionAfter, ok := optionalInt(request, "revisionAfter", 0)
+	if !ok || revisionAfter < 0 || (revisionBefore > 0 && revisionAfter >= revisionBefore) {
+		return filter, "revisionAfter"
+	}
+
+	filter.RevisionBefore, filter.RevisionAfter = int64(revisionBefore), int64(revisionAfter)
+	return filter, ""
+}
+
+// optionalInt parses a query parameter, which defaults to fallback when it is not passed.
+func optionalInt(request events.APIGatewayProxyRequest, name string, fallback int) (int, bool) {
+	value := request.QueryStringParameters[name]
+	if value == "" {
+		return fallback, true
+	}
+
+	parsed, err := strconv.Atoi(value)
+	return parsed, err == nil
 }
 
 func main() {
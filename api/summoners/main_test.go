@@ -29,14 +29,34 @@ type SummonersMock struct {
 		NameLength int32
 		T1         int64
 		Backwards  bool
+		Filter     shared.ListingFilter
 	}
 	GetAfterCalls []struct {
 		Region    string
 		Limit     int32
 		T1        int64
 		Backwards bool
+		Filter    shared.ListingFilter
+	}
+	GetByNameLengthsCalls []struct {
+		MinLength int32
+		MaxLength int32
+		T1        int64
+		Backwards bool
+		Filter    shared.ListingFilter
+	}
+	GetByScoreCalls []struct {
+		Region    string
+		MinLength int32
+		MaxLength int32
+		T1        int64
+		T2        int64
+		Filter    shared.ListingFilter
 	}
 	ReturnError bool
+	Truncated   bool
+	Next        int64
+	Skipped     []shared.SkippedItem
 }
 
 func (r *RegionMock) Validate(region string) bool {
@@ -62,35 +82,70 @@ func (h *HttpResponsesMock) Error(statusCode int, message string) events.APIGate
 	}
 }
 
-func (s *SummonersMock) GetByNameLength(region string, limit int32, nameLength int32, t1 int64, backwards bool) ([]*shared.SummonerDTO, error) {
+func (s *SummonersMock) GetByNameLength(region string, limit int32, nameLength int32, t1 int64, backwards bool, filter shared.ListingFilter) (*shared.SummonersPage, error) {
 	s.GetByNameLengthCalls = append(s.GetByNameLengthCalls, struct {
 		Region     string
 		Limit      int32
 		NameLength int32
 		T1         int64
 		Backwards  bool
-	}{region, limit, nameLength, t1, backwards})
+		Filter     shared.ListingFilter
+	}{region, limit, nameLength, t1, backwards, filter})
 
 	if s.ReturnError {
 		return nil, errors.New("error")
 	}
 
-	return []*shared.SummonerDTO{}, nil
+	return &shared.SummonersPage{Summoners: []*shared.SummonerDTO{}, Skipped: s.Skipped}, nil
 }
 
-func (s *SummonersMock) GetAfter(region string, limit int32, t1 int64, backwards bool) ([]*shared.SummonerDTO, error) {
+func (s *SummonersMock) GetAfter(region string, limit int32, t1 int64, backwards bool, filter shared.ListingFilter) (*shared.SummonersPage, error) {
 	s.GetAfterCalls = append(s.GetAfterCalls, struct {
 		Region    string
 		Limit     int32
 		T1        int64
 		Backwards bool
-	}{region, limit, t1, backwards})
+		Filter    shared.ListingFilter
+	}{region, limit, t1, backwards, filter})
+
+	if s.ReturnError {
+		return nil, errors.New("error")
+	}
+
+	return &shared.SummonersPage{Summoners: []*shared.SummonerDTO{}, Skipped: s.Skipped}, nil
+}
+
+func (s *SummonersMock) GetByNameLengths(_ string, _ int32, minLength int32, maxLength int32, t1 int64, backwards bool, filter shared.ListingFilter) (*shared.SummonersPage, error) {
+	s.GetByNameLengthsCalls = append(s.GetByNameLengthsCalls, struct {
+		MinLength int32
+		MaxLength int32
+		T1        int64
+		Backwards bool
+		Filter    shared.ListingFilter
+	}{minLength, maxLength, t1, backwards, filter})
 
 	if s.ReturnError {
 		return nil, errors.New("error")
 	}
 
-	return []*shared.SummonerDTO{}, nil
+	return &shared.SummonersPage{Summoners: []*shared.SummonerDTO{}, Next: s.Next}, nil
+}
+
+func (s *SummonersMock) GetByScore(region string, _ int32, minLength int32, maxLength int32, t1 int64, t2 int64, filter shared.ListingFilter) (*shared.SummonersPage, error) {
+	s.GetByScoreCalls = append(s.GetByScoreCalls, struct {
+		Region    string
+		MinLength int32
+		MaxLength int32
+		T1        int64
+		T2        int64
+		Filter    shared.ListingFilter
+	}{region, minLength, maxLength, t1, t2, filter})
+
+	if s.ReturnError {
+		return nil, errors.New("error")
+	}
+
+	return &shared.SummonersPage{Summoners: []*shared.SummonerDTO{}, Truncated: s.Truncated}, nil
 }
 
 func setup() {
@@ -514,3 +569,193 @@ func TestHandleRequest_Returns405ErrorWhenMethodIsNotGet(t *testing.T) {
 		t.Errorf("Expected status code 405, got %d", responses.(*HttpResponsesMock).ErrorCalls[0].StatusCode)
 	}
 }
+
+func TestHandleRequest_ReturnsWarningCountForSkippedItems(t *testing.T) {
+	setup()
+	summoners = &SummonersMock{Skipped: []shared.SkippedItem{{Key: "NA#BROKEN", Reason: "missing attribute 'rd'"}}}
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		QueryStringParameters: map[string]string{
+			"region":    "na",
+			"timestamp": "1",
+		},
+	}
+
+	_, err := HandleRequest(context.TODO(), request)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	response := responses.(*HttpResponsesMock).SuccessCalls[0].(*SummonersResponse)
+	if response.Warnings != 1 {
+		t.Errorf("Expected 1 warning, got %d", response.Warnings)
+	}
+
+	if response.Summoners == nil {
+		t.Errorf("Expected summoners to not be nil")
+	}
+}
+
+func TestHandleRequest_PassesMinScore(t *testing.T) {
+	setup()
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"region": "na", "timestamp": "1", "minScore": "60"},
+	}
+
+	_, _ = HandleRequest(context.TODO(), request)
+
+	calls := summoners.(*SummonersMock).GetAfterCalls
+	if len(calls) != 1 || calls[0].Filter.MinScore != 60 {
+		t.Errorf("Expected GetAfter to filter by score, got %+v", calls)
+	}
+}
+
+func TestHandleRequest_Returns400ErrorWhenMinScoreIsInvalid(t *testing.T) {
+	for _, minScore := range []string{"high", "-1", "101"} {
+		setup()
+		request := events.APIGatewayProxyRequest{
+			HTTPMethod:            "GET",
+			QueryStringParameters: map[string]string{"region": "na", "timestamp": "1", "minScore": minScore},
+		}
+
+		_, _ = HandleRequest(context.TODO(), request)
+
+		errs := responses.(*HttpResponsesMock).ErrorCalls
+		if len(errs) != 1 || errs[0].StatusCode != 400 || errs[0].Message != "Invalid 'minScore' query parameter" {
+			t.Errorf("%s: expected a 400 error, got %+v", minScore, errs)
+		}
+	}
+}
+
+func TestHandleRequest_SortsByScoreWithinWindow(t *testing.T) {
+	setup()
+	summoners.(*SummonersMock).Truncated = true
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"region": "na", "timestamp": "1000", "until": "5000", "sort": "score", "nameLength": "5", "minScore": "40"},
+	}
+
+	_, _ = HandleRequest(context.TODO(), request)
+
+	calls := summoners.(*SummonersMock).GetByScoreCalls
+	if len(calls) != 1 || calls[0].Region != "NA" || calls[0].MinLength != 5 || calls[0].MaxLength != 5 || calls[0].T1 != 1000 || calls[0].T2 != 5000 || calls[0].Filter.MinScore != 40 {
+		t.Fatalf("Expected GetByScore to rank the window, got %+v", calls)
+	}
+
+	res := responses.(*HttpResponsesMock).SuccessCalls[0].(*SummonersResponse)
+	if !res.Truncated {
+		t.Errorf("Expected the response to be marked truncated")
+	}
+}
+
+func TestHandleRequest_Returns400ErrorWhenScoreWindowIsInvalid(t *testing.T) {
+	params := []map[string]string{
+		{"region": "na", "timestamp": "1000", "sort": "score"},
+		{"region": "na", "timestamp": "1000", "sort": "score", "until": "1000"},
+		{"region": "na", "timestamp": "1000", "sort": "score", "until": "700000000"},
+		{"region": "na", "timestamp": "1000", "sort": "name"},
+	}
+
+	for _, p := range params {
+		setup()
+
+		_, _ = HandleRequest(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "GET", QueryStringParameters: p})
+
+		errs := responses.(*HttpResponsesMock).ErrorCalls
+		if len(errs) != 1 || errs[0].StatusCode != 400 || len(summoners.(*SummonersMock).GetByScoreCalls) != 0 {
+			t.Errorf("%v: expected a 400 error, got %+v", p, errs)
+		}
+	}
+}
+
+func TestHandleRequest_MergesLengthRange(t *testing.T) {
+	setup()
+	summoners.(*SummonersMock).Next = 77
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"region": "na", "timestamp": "1", "minLength": "3", "maxLength": "5", "backwards": "true"},
+	}
+
+	_, _ = HandleRequest(context.TODO(), request)
+
+	calls := summoners.(*SummonersMock).GetByNameLengthsCalls
+	if len(calls) != 1 || calls[0].MinLength != 3 || calls[0].MaxLength != 5 || !calls[0].Backwards {
+		t.Fatalf("Expected GetByNameLengths to be called for lengths 3 to 5, got %+v", calls)
+	}
+
+	if res := responses.(*HttpResponsesMock).SuccessCalls[0].(*SummonersResponse); res.Next != 77 {
+		t.Errorf("Expected the page cursor, got %d", res.Next)
+	}
+}
+
+func TestHandleRequest_DefaultsOpenLengthRange(t *testing.T) {
+	setup()
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"region": "na", "timestamp": "1", "maxLength": "4"},
+	}
+
+	_, _ = HandleRequest(context.TODO(), request)
+
+	calls := summoners.(*SummonersMock).GetByNameLengthsCalls
+	if len(calls) != 1 || calls[0].MinLength != 3 || calls[0].MaxLength != 4 {
+		t.Errorf("Expected lengths 3 to 4, got %+v", calls)
+	}
+}
+
+func TestHandleRequest_UsesSingleIndexForOneLengthRange(t *testing.T) {
+	setup()
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: map[string]string{"region": "na", "timestamp": "1", "minLength": "6", "maxLength": "6"},
+	}
+
+	_, _ = HandleRequest(context.TODO(), request)
+
+	if calls := summoners.(*SummonersMock).GetByNameLengthCalls; len(calls) != 1 || calls[0].NameLength != 6 {
+		t.Errorf("Expected GetByNameLength for length 6, got %+v", calls)
+	}
+}
+
+func TestHandleRequest_PassesLevelAndRevisionFilters(t *testing.T) {
+	setup()
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		QueryStringParameters: map[string]string{
+			"region": "na", "timestamp": "1", "minLevel": "1", "maxLevel": "10", "revisionBefore": "1640995200000", "revisionAfter": "1000",
+		},
+	}
+
+	_, _ = HandleRequest(context.TODO(), request)
+
+	expected := shared.ListingFilter{MinLevel: 1, MaxLevel: 10, RevisionBefore: 1640995200000, RevisionAfter: 1000}
+	if calls := summoners.(*SummonersMock).GetAfterCalls; len(calls) != 1 || calls[0].Filter != expected {
+		t.Errorf("Expected GetAfter to filter by %+v, got %+v", expected, calls)
+	}
+}
+
+func TestHandleRequest_Returns400ErrorWhenFiltersAreInvalid(t *testing.T) {
+	cases := map[string]map[string]string{
+		"nameLength":     {"nameLength": "4", "minLength": "3"},
+		"minLength":      {"minLength": "2"},
+		"maxLength":      {"minLength": "5", "maxLength": "4"},
+		"minLevel":       {"minLevel": "low"},
+		"maxLevel":       {"minLevel": "20", "maxLevel": "10"},
+		"revisionBefore": {"revisionBefore": "-5"},
+		"revisionAfter":  {"revisionBefore": "10", "revisionAfter": "10"},
+	}
+
+	for param, params := range cases {
+		setup()
+		params["region"], params["timestamp"] = "na", "1"
+
+		_, _ = HandleRequest(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "GET", QueryStringParameters: params})
+
+		errs := responses.(*HttpResponsesMock).ErrorCalls
+		if len(errs) != 1 || errs[0].StatusCode != 400 || !strings.Contains(errs[0].Message, "'"+param+"'") {
+			t.Errorf("%v: expected a 400 error about %s, got %+v", params, param, errs)
+		}
+	}
+}
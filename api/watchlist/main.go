@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/bricefrisco/nameslol/shared"
+	"log"
+	"net/mail"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type WatchlistService interface {
+	Subscribe(ctx context.Context, region string, name string, contact string) (*shared.WatchSubscription, error)
+	SubscribePush(ctx context.Context, region string, name string, push *shared.PushSubscription) (*shared.WatchSubscription, error)
+	Confirm(ctx context.Context, region string, name string, contact string, token string) error
+	Unsubscribe(ctx context.Context, region string, name string, contact string, token string) error
+}
+
+type RegionsService interface {
+	Validate(region string) bool
+}
+
+type NameValidatorService interface {
+	Validate(region string, name string) shared.ValidationErrors
+}
+
+type HttpResponsesService interface {
+	Success(responseObj any) events.APIGatewayProxyResponse
+	Error(statusCode int, message string) events.APIGatewayProxyResponse
+	ValidationError(errs shared.ValidationErrors) events.APIGatewayProxyResponse
+}
+
+// maxContactLength is the longest email address SMTP allows.
+const maxContactLength = 254
+
+// subscription has either an email contact or, from a browser, its push subscription. Push subscriptions
+// are unsubscribed with their endpoint as the contact.
+type subscription struct {
+	Region  string                   `json:"region"`
+	Name    string                   `json:"name"`
+	Contact string                   `json:"contact"`
+	Push    *shared.PushSubscription `json:"push,omitempty"`
+	Token   string                   `json:"token,omitempty"`
+}
+
+type subscriptionResponse struct {
+	Region    string `json:"region"`
+	Name      string `json:"name"`
+	Contact   string `json:"contact"`
+	CreatedAt int64  `json:"createdAt"`
+	Pending   bool   `json:"pending"`
+}
+
+var watchlist WatchlistService
+var regions RegionsService
+var validator NameValidatorService
+var responses HttpResponsesService
+
+func init() {
+	log.SetFlags(0)
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("Error loading AWS config: %v\n", err)
+	}
+
+	regions = shared.NewRegions()
+	validator = shared.NewNameValidator()
+	responses = shared.NewHttpResponses(os.Getenv("CORS_ORIGINS"), os.Getenv("CORS_METHODS"))
+
+	client := shared.NewDynamoDbClient(cfg)
+	w := shared.NewWatchlist(client, os.Getenv("DYNAMODB_TABLE"))
+	watchlist = w
+
+	// Without a mailer to confirm them, email subscriptions are refused.
+	if os.Getenv("SMTP_ADDR") == "" {
+		log.Printf("email confirmations are not configured, set SMTP_ADDR")
+		return
+	}
+
+	confirmations, err := shared.NewConfirmationsFromEnv(context.TODO(), client, os.Getenv("DYNAMODB_TABLE"), shared.NewSSMSecretsSource(cfg))
+	if err != nil {
+		log.Fatalf("Error configuring confirmations: %v\n", err)
+	}
+
+	w.ConfirmWith(confirmations, os.Getenv("WATCH_CONFIRM_URL"))
+}
+
+// HandleRequest subscribes a contact or browser to a name with POST. Email contacts confirm their
+// subscription with PUT and the token they were sent, and unsubscribe with DELETE and the same token.
+func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var body subscription
+
+	switch request.HTTPMethod {
+	case "OPTIONS":
+		return responses.Success(nil), nil
+	case "POST":
+		if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+			return responses.Error(400, "Invalid request body"), nil
+		}
+	case "PUT", "DELETE":
+		body = subscription{
+			Region:  request.QueryStringParameters["region"],
+			Name:    request.QueryStringParameters["name"],
+			Contact: request.QueryStringParameters["contact"],
+			Token:   request.QueryStringParameters["token"],
+		}
+	default:
+		return responses.Error(405, "Method not allowed"), nil
+	}
+
+	body.Region = strings.ToUpper(body.Region)
+	if !regions.Validate(body.Region) {
+		return responses.Error(400, "Invalid 'region'"), nil
+	}
+
+	if errs := validator.Validate(body.Region, body.Name); len(errs) > 0 {
+		return responses.ValidationError(errs), nil
+	}
+
+	if request.HTTPMethod == "POST" && body.Push != nil {
+		return subscribePush(ctx, &body), nil
+	}
+
+	contact, ok := parseContact(body.Contact, request.HTTPMethod == "DELETE")
+	if !ok {
+		return responses.Error(400, "Invalid 'contact', expected an email address"), nil
+	}
+
+	if request.HTTPMethod == "PUT" {
+		err := watchlist.Confirm(ctx, body.Region, body.Name, contact, body.Token)
+		return tokenResponse(err, "confirming"), nil
+	}
+
+	if request.HTTPMethod == "DELETE" {
+		err := watchlist.Unsubscribe(ctx, body.Region, body.Name, contact, body.Token)
+		return tokenResponse(err, "unsubscribing"), nil
+	}
+
+	result, err := watchlist.Subscribe(ctx, body.Region, body.Name, contact)
+	return subscribed(&body, result, err), nil
+}
+
+func subscribePush(ctx context.Context, body *subscription) events.APIGatewayProxyResponse {
+	if err := body.Push.Validate(); err != nil {
+		return responses.Error(400, "Invalid 'push', "+err.Error())
+	}
+
+	result, err := watchlist.SubscribePush(ctx, body.Region, body.Name, body.Push)
+	return subscribed(body, result, err)
+}
+
+// tokenResponse answers a request authorized by the token sent to a subscription's contact.
+func tokenResponse(err error, action string) events.APIGatewayProxyResponse {
+	if err != nil && err.Error() == "subscription not found" {
+		return responses.Error(404, "Subscription not found")
+	}
+
+	if err != nil {
+		log.Printf("Error %s: %v\n", action, err)
+		return responses.Error(500, "Internal server error")
+	}
+
+	return responses.Success(nil)
+}
+
+func subscribed(body *subscription, result *shared.WatchSubscription, err error) events.APIGatewayProxyResponse {
+	if err != nil && err.Error() == "watchlist is full" {
+		return responses.Error(409, "Too many subscriptions for this name")
+	}
+
+	var rateLimitedErr *shared.RateLimitedError
+	if errors.As(err, &rateLimitedErr) {
+		return responses.Error(429, "Too many subscriptions, please try again later")
+	}
+
+	if err != nil {
+		log.Printf("Error subscribing: %v\n", err)
+		return responses.Error(500, "Internal server error")
+	}
+
+	return responses.Success(&subscriptionResponse{
+		Region:    body.Region,
+		Name:      body.Name,
+		Contact:   result.Contact,
+		CreatedAt: result.CreatedAt,
+		Pending:   result.Pending,
+	})
+}
+
+// parseContact returns the email address a contact names. Push endpoints are also accepted when
+// unsubscribing.
+func parseContact(contact string, allowPush bool) (string, bool) {
+	if allowPush {
+		if endpoint, err := url.Parse(contact); err == nil && endpoint.Scheme == "https" && endpoint.Host != "" {
+			return contact, true
+		}
+	}
+
+	address, err := mail.ParseAddress(contact)
+	if err != nil || address.Address != strings.TrimSpace(contact) || len(address.Address) > maxContactLength {
+		return "", false
+	}
+
+	return address.Address, true
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bricefrisco/nameslol/shared"
+	"strings"
+	"testing"
+)
+
+type WatchlistServiceMock struct {
+	ShouldFail       bool
+	Full             bool
+	Limited          bool
+	NotFound         bool
+	SubscribeCalls   []string
+	ConfirmCalls     []string
+	UnsubscribeCalls []string
+}
+
+func (w *WatchlistServiceMock) Subscribe(_ context.Context, region string, name string, contact string) (*shared.WatchSubscription, error) {
+	w.SubscribeCalls = append(w.SubscribeCalls, region+"#"+name+"#"+contact)
+	if w.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	if w.Full {
+		return nil, fmt.Errorf("watchlist is full")
+	}
+
+	if w.Limited {
+		return nil, &shared.RateLimitedError{Key: "subscribe#" + contact}
+	}
+
+	return &shared.WatchSubscription{Contact: contact, Token: "secret-token", CreatedAt: 1234567890}, nil
+}
+
+func (w *WatchlistServiceMock) SubscribePush(ctx context.Context, region string, name string, push *shared.PushSubscription) (*shared.WatchSubscription, error) {
+	return w.Subscribe(ctx, region, name, push.Endpoint)
+}
+
+func (w *WatchlistServiceMock) Confirm(_ context.Context, region string, name string, contact string, token string) error {
+	w.ConfirmCalls = append(w.ConfirmCalls, region+"#"+name+"#"+contact+"#"+token)
+	if w.NotFound {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func (w *WatchlistServiceMock) Unsubscribe(_ context.Context, region string, name string, contact string, token string) error {
+	w.UnsubscribeCalls = append(w.UnsubscribeCalls, region+"#"+name+"#"+contact+"#"+token)
+	if w.ShouldFail {
+		return fmt.Errorf("error")
+	}
+
+	if w.NotFound {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func setup() {
+	watchlist = &WatchlistServiceMock{}
+	regions = shared.NewRegions()
+	validator = shared.NewNameValidator()
+	responses = shared.NewHttpResponses("test-origin", "test-methods")
+}
+
+func subscribeRequest(body string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{HTTPMethod: "POST", Body: body}
+}
+
+func TestHandleRequest_Subscribes(t *testing.T) {
+	setup()
+
+	res, err := HandleRequest(context.TODO(), subscribeRequest(`{"region":"na","name":"Some Name","contact":"player@example.com"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d", res.StatusCode)
+	}
+
+	calls := watchlist.(*WatchlistServiceMock).SubscribeCalls
+	if len(calls) != 1 || calls[0] != "NA#Some Name#player@example.com" {
+		t.Errorf("Expected subscribe to be called with the request, got %v", calls)
+	}
+
+	if strings.Contains(res.Body, "secret-token") || !strings.Contains(res.Body, `"createdAt":1234567890`) {
+		t.Errorf("Expected the subscription without its token, got %s", res.Body)
+	}
+}
+
+func TestHandleRequest_ValidatesSubscription(t *testing.T) {
+	bodies := map[string]string{
+		"invalid json":    `{`,
+		"invalid region":  `{"region":"BR","name":"name","contact":"player@example.com"}`,
+		"invalid name":    `{"region":"NA","name":"","contact":"player@example.com"}`,
+		"invalid contact": `{"region":"NA","name":"name","contact":"not an email"}`,
+		"display name":    `{"region":"NA","name":"name","contact":"Player <player@example.com>"}`,
+		"push endpoint":   `{"region":"NA","name":"name","contact":"https://push.example.com/abc"}`,
+	}
+
+	for test, body := range bodies {
+		setup()
+
+		res, _ := HandleRequest(context.TODO(), subscribeRequest(body))
+		if res.StatusCode != 400 {
+			t.Errorf("%s: Expected status code 400, got %d", test, res.StatusCode)
+		}
+
+		if len(watchlist.(*WatchlistServiceMock).SubscribeCalls) != 0 {
+			t.Errorf("%s: Expected subscribe not to be called", test)
+		}
+	}
+}
+
+func TestHandleRequest_SubscribesPushSubscription(t *testing.T) {
+	setup()
+
+	// The keys are from the example in RFC 8291.
+	res, _ := HandleRequest(context.TODO(), subscribeRequest(`{"region":"NA","name":"name","push":{"endpoint":"https://push.example.com/abc","expirationTime":null,`+
+		`"keys":{"p256dh":"BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4","auth":"BTBZMqHH6r4Tts7J_aSIgg"}}}`))
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d: %s", res.StatusCode, res.Body)
+	}
+
+	calls := watchlist.(*WatchlistServiceMock).SubscribeCalls
+	if len(calls) != 1 || calls[0] != "NA#name#https://push.example.com/abc" {
+		t.Errorf("Expected the push subscription to be subscribed, got %v", calls)
+	}
+
+	res, _ = HandleRequest(context.TODO(), subscribeRequest(`{"region":"NA","name":"name","push":{"endpoint":"https://push.example.com/abc","keys":{"p256dh":"AAAA","auth":"AAAA"}}}`))
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code 400 for invalid keys, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_UnsubscribesPushEndpoint(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "DELETE",
+		QueryStringParameters: map[string]string{"region": "NA", "name": "name", "contact": "https://push.example.com/abc", "token": "secret-token"},
+	})
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d", res.StatusCode)
+	}
+
+	calls := watchlist.(*WatchlistServiceMock).UnsubscribeCalls
+	if len(calls) != 1 || calls[0] != "NA#name#https://push.example.com/abc#secret-token" {
+		t.Errorf("Expected unsubscribe to be called with the endpoint, got %v", calls)
+	}
+}
+
+func TestHandleRequest_Returns409WhenWatchlistIsFull(t *testing.T) {
+	setup()
+	watchlist.(*WatchlistServiceMock).Full = true
+
+	res, _ := HandleRequest(context.TODO(), subscribeRequest(`{"region":"NA","name":"name","contact":"player@example.com"}`))
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status code 409, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_Returns500OnSubscribeError(t *testing.T) {
+	setup()
+	watchlist.(*WatchlistServiceMock).ShouldFail = true
+
+	res, _ := HandleRequest(context.TODO(), subscribeRequest(`{"region":"NA","name":"name","contact":"player@example.com"}`))
+	if res.StatusCode != 500 {
+		t.Errorf("Expected status code 500, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_UnsubscribesWithToken(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "DELETE",
+		QueryStringParameters: map[string]string{"region": "NA", "name": "name", "contact": "player@example.com", "token": "secret-token"},
+	})
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d", res.StatusCode)
+	}
+
+	calls := watchlist.(*WatchlistServiceMock).UnsubscribeCalls
+	if len(calls) != 1 || calls[0] != "NA#name#player@example.com#secret-token" {
+		t.Errorf("Expected unsubscribe to be called with the request, got %v", calls)
+	}
+}
+
+func TestHandleRequest_ConfirmsWithToken(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "PUT",
+		QueryStringParameters: map[string]string{"region": "na", "name": "name", "contact": "player@example.com", "token": "secret-token"},
+	})
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code 200, got %d", res.StatusCode)
+	}
+
+	calls := watchlist.(*WatchlistServiceMock).ConfirmCalls
+	if len(calls) != 1 || calls[0] != "NA#name#player@example.com#secret-token" {
+		t.Errorf("Expected confirm to be called with the request, got %v", calls)
+	}
+}
+
+func TestHandleRequest_Returns429WhenContactSubscribesTooOften(t *testing.T) {
+	setup()
+	watchlist.(*WatchlistServiceMock).Limited = true
+
+	res, _ := HandleRequest(context.TODO(), subscribeRequest(`{"region":"NA","name":"name","contact":"player@example.com"}`))
+	if res.StatusCode != 429 {
+		t.Errorf("Expected status code 429, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_Returns404WhenSubscriptionNotFound(t *testing.T) {
+	setup()
+	watchlist.(*WatchlistServiceMock).NotFound = true
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{
+		HTTPMethod:            "DELETE",
+		QueryStringParameters: map[string]string{"region": "NA", "name": "name", "contact": "player@example.com", "token": "wrong"},
+	})
+	if res.StatusCode != 404 {
+		t.Errorf("Expected status code 404, got %d", res.StatusCode)
+	}
+}
+
+func TestHandleRequest_Returns405OnInvalidMethod(t *testing.T) {
+	setup()
+
+	res, _ := HandleRequest(context.TODO(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	if res.StatusCode != 405 {
+		t.Errorf("Expected status code 405, got %d", res.StatusCode)
+	}
+}
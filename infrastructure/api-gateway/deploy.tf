@@ -21,9 +21,15 @@ resource "aws_api_gateway_rest_api" "default" {
 resource "aws_api_gateway_deployment" "deployment" {
   depends_on  = [
     module.summoner-apigw-endpoint,
-    module.summoners-apigw-endpoint
+    module.summoners-apigw-endpoint,
+    module.admin-apigw-endpoint,
+    module.watchlist-apigw-endpoint,
+    module.patterns-apigw-endpoint,
+    module.digest-apigw-endpoint,
+    module.search-apigw-endpoint,
+    module.suggestions-apigw-endpoint
   ]
-  stage_description = "Deployment: #3"
+  stage_description = "Deployment: #9"
   rest_api_id = aws_api_gateway_rest_api.default.id
   stage_name  = "prod"
 }
@@ -48,3 +54,57 @@ module "summoners-apigw-endpoint" {
   function_name = "api-summoners"
   path = "summoners"
 }
+
+module "admin-apigw-endpoint" {
+  source = "../modules/apigw-endpoint"
+  api_gateway_id = aws_api_gateway_rest_api.default.id
+  api_gateway_root_resource_id = aws_api_gateway_rest_api.default.root_resource_id
+  api_gateway_execution_arn = aws_api_gateway_rest_api.default.execution_arn
+  function_name = "api-admin"
+  path = "admin"
+}
+
+module "watchlist-apigw-endpoint" {
+  source = "../modules/apigw-endpoint"
+  api_gateway_id = aws_api_gateway_rest_api.default.id
+  api_gateway_root_resource_id = aws_api_gateway_rest_api.default.root_resource_id
+  api_gateway_execution_arn = aws_api_gateway_rest_api.default.execution_arn
+  function_name = "api-watchlist"
+  path = "watchlist"
+}
+
+module "patterns-apigw-endpoint" {
+  source = "../modules/apigw-endpoint"
+  api_gateway_id = aws_api_gateway_rest_api.default.id
+  api_gateway_root_resource_id = aws_api_gateway_rest_api.default.root_resource_id
+  api_gateway_execution_arn = aws_api_gateway_rest_api.default.execution_arn
+  function_name = "api-patterns"
+  path = "patterns"
+}
+
+module "digest-apigw-endpoint" {
+  source = "../modules/apigw-endpoint"
+  api_gateway_id = aws_api_gateway_rest_api.default.id
+  api_gateway_root_resource_id = aws_api_gateway_rest_api.default.root_resource_id
+  api_gateway_execution_arn = aws_api_gateway_rest_api.default.execution_arn
+  function_name = "api-digest"
+  path = "digest"
+}
+
+module "search-apigw-endpoint" {
+  source = "../modules/apigw-endpoint"
+  api_gateway_id = aws_api_gateway_rest_api.default.id
+  api_gateway_root_resource_id = module.summoners-apigw-endpoint.resource_id
+  api_gateway_execution_arn = aws_api_gateway_rest_api.default.execution_arn
+  function_name = "api-search"
+  path = "search"
+}
+
+module "suggestions-apigw-endpoint" {
+  source = "../modules/apigw-endpoint"
+  api_gateway_id = aws_api_gateway_rest_api.default.id
+  api_gateway_root_resource_id = module.summoner-apigw-endpoint.resource_id
+  api_gateway_execution_arn = aws_api_gateway_rest_api.default.execution_arn
+  function_name = "api-suggestions"
+  path = "suggestions"
+}
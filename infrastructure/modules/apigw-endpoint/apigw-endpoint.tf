@@ -4,7 +4,7 @@ variable "api_gateway_id" {
 }
 
 variable "api_gateway_root_resource_id" {
-  description = "The ID of the root resource."
+  description = "The ID of the resource the path is added under, usually the root resource."
   type        = string
 }
 
@@ -56,3 +56,8 @@ resource "aws_lambda_permission" "api-permission" {
   principal = "apigateway.amazonaws.com"
   source_arn = "${var.api_gateway_execution_arn}/*/*"
 }
+
+output "resource_id" {
+  description = "The ID of the path's resource, to add paths under it."
+  value = aws_api_gateway_resource.api-resource.id
+}
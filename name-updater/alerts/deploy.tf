@@ -0,0 +1,129 @@
+terraform {
+  backend "s3" {
+    bucket = "nameslol-deployments"
+    key    = "terraform/name-updater-alerts"
+    region = "us-east-1"
+  }
+}
+
+provider "aws" {
+  region = "us-east-1"
+}
+
+data "aws_dynamodb_table" "nameslol" {
+  name = "nameslol"
+}
+
+data "aws_ssm_parameter" "smtp-username" {
+  name = "/smtp-username"
+}
+
+data "aws_ssm_parameter" "smtp-password" {
+  name = "/smtp-password"
+}
+
+data "aws_ssm_parameter" "vapid-private-key" {
+  name = "/vapid-private-key"
+}
+
+module "lambda" {
+  source                = "../../infrastructure/modules/lambda"
+  app_name              = "name-updater-alerts"
+  bootstrap_file_path   = "${path.module}/bootstrap"
+  timeout               = 300
+  memory_size           = 256
+  iam_policy_statements = [
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "dynamodb:GetItem",
+        "dynamodb:PutItem",
+        "dynamodb:UpdateItem",
+        "dynamodb:DeleteItem",
+        "dynamodb:BatchGetItem",
+        "dynamodb:Query",
+      ],
+      "Resource" : [
+        data.aws_dynamodb_table.nameslol.arn,
+        "${data.aws_dynamodb_table.nameslol.arn}/index/*"
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "ssm:GetParameter"
+      ],
+      "Resource" : [
+        data.aws_ssm_parameter.smtp-password.arn,
+        data.aws_ssm_parameter.vapid-private-key.arn
+      ]
+    },
+  ]
+  environment_variables = {
+    DYNAMODB_TABLE                 = data.aws_dynamodb_table.nameslol.name
+    SMTP_ADDR                      = "email-smtp.us-east-1.amazonaws.com:587"
+    SMTP_FROM                      = "alerts@names.lol"
+    SMTP_USERNAME                  = data.aws_ssm_parameter.smtp-username.value
+    SMTP_PASSWORD_NAME             = data.aws_ssm_parameter.smtp-password.name
+    WEBPUSH_SUBJECT                = "mailto:alerts@names.lol"
+    WEBPUSH_VAPID_PRIVATE_KEY_NAME = data.aws_ssm_parameter.vapid-private-key.name
+    WATCH_ALERT_LEAD               = "24h"
+    WATCH_UNSUBSCRIBE_URL          = "https://names.lol/unsubscribe"
+    PATTERN_UNSUBSCRIBE_URL        = "https://names.lol/patterns/unsubscribe"
+  }
+}
+
+resource "aws_iam_role" "scheduler_exec" {
+  name = "name-updater-alerts-scheduler-role"
+  assume_role_policy = jsonencode({
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Effect": "Allow",
+        "Principal": {
+          "Service": "scheduler.amazonaws.com"
+        },
+        "Action": "sts:AssumeRole"
+      }
+    ]
+  })
+}
+
+resource "aws_iam_role_policy" "scheduler_exec_policy" {
+  role = aws_iam_role.scheduler_exec.id
+  policy = jsonencode({
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Action": [
+          "lambda:InvokeFunction"
+        ],
+        "Effect": "Allow",
+        "Resource": [
+          module.lambda.lambda_function_arn
+        ]
+      }
+    ]
+  })
+}
+
+resource "aws_scheduler_schedule" "watch-alerts" {
+  name = "name-updater-watch-alerts"
+  schedule_expression = "rate(1 hour)"
+  state = "DISABLED"
+
+  flexible_time_window {
+    mode = "OFF"
+  }
+
+  target {
+    arn = module.lambda.lambda_function_arn
+    role_arn = aws_iam_role.scheduler_exec.arn
+
+    input = jsonencode({})
+
+    retry_policy {
+      maximum_retry_attempts = 0
+    }
+  }
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/bricefrisco/nameslol/shared"
+	"log"
+	"os"
+)
+
+// Event optionally limits a run to one region. Every region is checked when it is empty.
+type Event struct {
+	Region string `json:"region"`
+}
+
+type alerterService interface {
+	AlertApproaching(ctx context.Context, region string) (*shared.WatchAlertResult, error)
+}
+
+type patternsService interface {
+	Sweep(ctx context.Context, region string) (*shared.PatternSweepResult, error)
+}
+
+var alerter alerterService
+var patterns patternsService
+
+func init() {
+	log.SetFlags(0)
+
+	tableName := os.Getenv("DYNAMODB_TABLE")
+	summoners, err := shared.NewSummoners(tableName, shared.WorkloadBackground)
+	if err != nil {
+		log.Fatalf("could not create summoners service, %v", err)
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("could not load AWS config, %v", err)
+	}
+
+	if !shared.WatchAlertsConfigured() {
+		log.Printf("watch alerts are not configured, set SMTP_ADDR or WEBPUSH_VAPID_PRIVATE_KEY_NAME")
+		return
+	}
+
+	alerter, err = shared.NewWatchAlerterFromEnv(context.TODO(), shared.NewDynamoDbClient(cfg), tableName, summoners, shared.NewSSMSecretsSource(cfg))
+	if err != nil {
+		log.Fatalf("could not create watch alerts, %v", err)
+	}
+
+	patterns, err = shared.NewPatternAlerterFromEnv(context.TODO(), shared.NewDynamoDbClient(cfg), tableName, summoners, shared.NewSSMSecretsSource(cfg))
+	if err != nil {
+		log.Fatalf("could not create pattern alerts, %v", err)
+	}
+}
+
+func HandleRequest(ctx context.Context, event *Event) error {
+	if alerter == nil {
+		return fmt.Errorf("watch alerts are not configured")
+	}
+
+	regions := shared.NewRegions().Sorted()
+	if event.Region != "" {
+		if !shared.NewRegions().Validate(event.Region) {
+			return fmt.Errorf("invalid region '%s'", event.Region)
+		}
+
+		regions = []string{event.Region}
+	}
+
+	// A failing region is retried on the next run, so it does not hold up the others.
+	var errs []error
+	for _, region := range regions {
+		result, err := alerter.AlertApproaching(ctx, region)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not alert watchers in region '%s': %v", region, err))
+		} else {
+			log.Printf("checked %d upcoming names in region '%s': %d watched, %d alerts sent, %d watchers dropped", result.Checked, region, result.Watched, result.Sent, result.Dropped)
+		}
+
+		swept, err := patterns.Sweep(ctx, region)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not alert pattern subscribers in region '%s': %v", region, err))
+			continue
+		}
+
+		log.Printf("swept %d pattern subscriptions in region '%s': %d names checked, %d matched, %d alerts sent, %d subscribers dropped", swept.Subscriptions, region, swept.Checked, swept.Matched, swept.Sent, swept.Dropped)
+	}
+
+	return errors.Join(errs...)
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
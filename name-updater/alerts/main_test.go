@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"testing"
+)
+
+type AlerterServiceMock struct {
+	FailRegion string
+	Calls      []string
+}
+
+func (a *AlerterServiceMock) AlertApproaching(_ context.Context, region string) (*shared.WatchAlertResult, error) {
+	a.Calls = append(a.Calls, region)
+	if region == a.FailRegion {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &shared.WatchAlertResult{Region: region}, nil
+}
+
+type PatternsServiceMock struct {
+	FailRegion string
+	Calls      []string
+}
+
+func (p *PatternsServiceMock) Sweep(_ context.Context, region string) (*shared.PatternSweepResult, error) {
+	p.Calls = append(p.Calls, region)
+	if region == p.FailRegion {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &shared.PatternSweepResult{Region: region}, nil
+}
+
+func setup() {
+	alerter = &AlerterServiceMock{}
+	patterns = &PatternsServiceMock{}
+}
+
+func TestHandleRequest_AlertsEveryRegion(t *testing.T) {
+	setup()
+
+	if err := HandleRequest(context.TODO(), &Event{}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if calls := alerter.(*AlerterServiceMock).Calls; fmt.Sprint(calls) != "[EUNE EUW LAS NA OCE]" {
+		t.Errorf("expected every region to be checked, got %v", calls)
+	}
+
+	if calls := patterns.(*PatternsServiceMock).Calls; fmt.Sprint(calls) != "[EUNE EUW LAS NA OCE]" {
+		t.Errorf("expected every region to be swept, got %v", calls)
+	}
+}
+
+func TestHandleRequest_AlertsOneRegion(t *testing.T) {
+	setup()
+
+	_ = HandleRequest(context.TODO(), &Event{Region: "EUW"})
+
+	if calls := alerter.(*AlerterServiceMock).Calls; len(calls) != 1 || calls[0] != "EUW" {
+		t.Errorf("expected only EUW to be checked, got %v", calls)
+	}
+}
+
+func TestHandleRequest_RejectsInvalidRegion(t *testing.T) {
+	setup()
+
+	if err := HandleRequest(context.TODO(), &Event{Region: "BR"}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestHandleRequest_ContinuesAfterFailingRegion(t *testing.T) {
+	setup()
+	alerter.(*AlerterServiceMock).FailRegion = "EUW"
+
+	if err := HandleRequest(context.TODO(), &Event{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if calls := alerter.(*AlerterServiceMock).Calls; len(calls) != 5 {
+		t.Errorf("expected every region to be checked, got %v", calls)
+	}
+}
+
+func TestHandleRequest_SweepsPatternsWhenWatchAlertsFail(t *testing.T) {
+	setup()
+	alerter.(*AlerterServiceMock).FailRegion = "EUW"
+	patterns.(*PatternsServiceMock).FailRegion = "NA"
+
+	if err := HandleRequest(context.TODO(), &Event{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if calls := patterns.(*PatternsServiceMock).Calls; len(calls) != 5 {
+		t.Errorf("expected every region to be swept, got %v", calls)
+	}
+}
@@ -18,10 +18,26 @@ data "aws_sqs_queue" "name-update-queue" {
   name = "NameUpdateQueue"
 }
 
+data "aws_sqs_queue" "webhook-delivery-queue" {
+  name = "WebhookDeliveryQueue"
+}
+
 data "aws_ssm_parameter" "riot-api-token" {
   name = "/riot-api-token"
 }
 
+data "aws_ssm_parameter" "smtp-username" {
+  name = "/smtp-username"
+}
+
+data "aws_ssm_parameter" "smtp-password" {
+  name = "/smtp-password"
+}
+
+data "aws_ssm_parameter" "vapid-private-key" {
+  name = "/vapid-private-key"
+}
+
 module "lambda" {
   source                = "../../infrastructure/modules/lambda"
   app_name              = "name-updater-consumer"
@@ -34,6 +50,10 @@ module "lambda" {
       "Action" : [
         "dynamodb:PutItem",
         "dynamodb:DeleteItem",
+        "dynamodb:GetItem",
+        "dynamodb:UpdateItem",
+        "dynamodb:BatchWriteItem",
+        "dynamodb:Query",
       ],
       "Resource" : [
         data.aws_dynamodb_table.nameslol.arn,
@@ -50,19 +70,51 @@ module "lambda" {
       "Resource" : [
         data.aws_sqs_queue.name-update-queue.arn
       ]
-    }
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "sqs:SendMessage"
+      ],
+      "Resource" : [
+        data.aws_sqs_queue.webhook-delivery-queue.arn
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "ssm:GetParameter"
+      ],
+      "Resource" : [
+        data.aws_ssm_parameter.riot-api-token.arn,
+        data.aws_ssm_parameter.smtp-password.arn,
+        data.aws_ssm_parameter.vapid-private-key.arn
+      ]
+    },
   ]
   environment_variables = {
-    DYNAMODB_TABLE = data.aws_dynamodb_table.nameslol.name
-    RIOT_API_TOKEN = data.aws_ssm_parameter.riot-api-token.value
+    DYNAMODB_TABLE                 = data.aws_dynamodb_table.nameslol.name
+    RIOT_API_KEY_SOURCE            = "ssm"
+    RIOT_API_KEY_NAMES             = data.aws_ssm_parameter.riot-api-token.name
+    RIOT_RATE_LIMIT_STORE          = "dynamodb"
+    SMTP_ADDR                      = "email-smtp.us-east-1.amazonaws.com:587"
+    SMTP_FROM                      = "alerts@names.lol"
+    SMTP_USERNAME                  = data.aws_ssm_parameter.smtp-username.value
+    SMTP_PASSWORD_NAME             = data.aws_ssm_parameter.smtp-password.name
+    WEBPUSH_SUBJECT                = "mailto:alerts@names.lol"
+    WEBPUSH_VAPID_PRIVATE_KEY_NAME = data.aws_ssm_parameter.vapid-private-key.name
+    WATCH_UNSUBSCRIBE_URL          = "https://names.lol/unsubscribe"
+    PATTERN_UNSUBSCRIBE_URL        = "https://names.lol/patterns/unsubscribe"
+    WEBHOOK_QUEUE_URL              = data.aws_sqs_queue.webhook-delivery-queue.url
   }
 }
 
 resource "aws_lambda_event_source_mapping" "default" {
-  event_source_arn = data.aws_sqs_queue.name-update-queue.arn
-  function_name    = module.lambda.lambda_function_arn
-  batch_size       = 5
+  event_source_arn        = data.aws_sqs_queue.name-update-queue.arn
+  function_name           = module.lambda.lambda_function_arn
+  batch_size              = 5
+  function_response_types = ["ReportBatchItemFailures"]
   scaling_config {
-    maximum_concurrency = 10 // Keep below 10 to avoid exceeding Riot API rate limits
+    maximum_concurrency = 10 // Riot API usage is throttled by the shared budget, this only bounds Lambda scaling
   }
 }
@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/bricefrisco/nameslol/shared"
 	"log"
 	"os"
@@ -13,56 +16,153 @@ import (
 type summonersService interface {
 	Fetch(region string, name string) (*shared.SummonerDTO, error)
 	Save(summoner *shared.SummonerDTO) error
-	Delete(region string, name string) error
+	Delete(region string, name string) (bool, error)
+	RiotHealth() shared.CircuitHealth
+	RiotBudgetUsage() (*shared.BudgetUsage, error)
+}
+
+type eventsService interface {
+	Publish(ctx context.Context, event *shared.NameEvent) error
+}
+
+type watchAlertsService interface {
+	AlertFreed(ctx context.Context, region string, name string) (int, error)
 }
 
 var summoners summonersService
+var alerts watchAlertsService
+var publishers []eventsService
 
 func init() {
 	log.SetFlags(0)
 
-	var err error
-	summoners, err = shared.NewSummoners(os.Getenv("DYNAMODB_TABLE"), os.Getenv("RIOT_API_TOKEN"))
+	tableName := os.Getenv("DYNAMODB_TABLE")
+	s, err := shared.NewSummoners(tableName, shared.WorkloadBackground)
 	if err != nil {
 		log.Fatalf("could not create summoners service, %v", err)
 	}
-}
+	summoners = s
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("could not load AWS config, %v", err)
+	}
+
+	s.IndexNames(shared.NewNameSearch(shared.NewDynamoDbClient(cfg), tableName))
+
+	if queueUrl := os.Getenv("WEBHOOK_QUEUE_URL"); queueUrl != "" {
+		webhooks := shared.NewWebhooks(shared.NewDynamoDbClient(cfg), tableName, sqs.NewFromConfig(cfg), queueUrl)
+		s.PublishEvents(webhooks)
+		publishers = append(publishers, webhooks)
+	}
+
+	if !shared.WatchAlertsConfigured() {
+		log.Printf("neither SMTP_ADDR nor a VAPID key is set, watchers will not be alerted")
+		return
+	}
+
+	alerts, err = shared.NewWatchAlerterFromEnv(context.TODO(), shared.NewDynamoDbClient(cfg), tableName, s, shared.NewSSMSecretsSource(cfg))
+	if err != nil {
+		log.Fatalf("could not create watch alerts, %v", err)
+	}
 
-type SQSMessage struct {
-	Region string `json:"region"`
-	Name   string `json:"name"`
+	// Saves publish changed availability dates to pattern subscribers, and deletes below publish freed names.
+	patterns, err := shared.NewPatternAlerterFromEnv(context.TODO(), shared.NewDynamoDbClient(cfg), tableName, s, shared.NewSSMSecretsSource(cfg))
+	if err != nil {
+		log.Fatalf("could not create pattern alerts, %v", err)
+	}
+
+	s.PublishEvents(patterns)
+	publishers = append(publishers, patterns)
 }
 
-func HandleRequest(_ context.Context, event events.SQSEvent) error {
-	for _, message := range event.Records {
-		var sqsMessage SQSMessage
+func HandleRequest(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	var response events.SQSEventResponse
+
+	health := summoners.RiotHealth()
+	if health.State == shared.CircuitOpen {
+		return response, fmt.Errorf("riot api is unavailable (%s), leaving %d messages on the queue", health.Reason, len(event.Records))
+	}
+
+	for i, message := range event.Records {
+		var sqsMessage shared.NameUpdateMessage
 		err := json.Unmarshal([]byte(message.Body), &sqsMessage)
 		if err != nil {
-			return err
+			return response, err
 		}
 
 		summoner, err := summoners.Fetch(sqsMessage.Region, sqsMessage.Name)
 		if err != nil {
 			if err.Error() == "summoner not found" {
 				log.Printf("summoner '%v' was not found in region '%v', deleting...", sqsMessage.Name, sqsMessage.Region)
-				err = summoners.Delete(sqsMessage.Region, sqsMessage.Name)
+				existed, err := summoners.Delete(sqsMessage.Region, sqsMessage.Name)
+				if err != nil {
+					return response, err
+				}
+
+				// The name is only freed when it was stored. Once deleted it is not stored anymore, so the
+				// event is not published again when the message is retried after a failed alert.
+				if existed {
+					event := shared.NewNameEvent(shared.EventNameAvailable, sqsMessage.Region, sqsMessage.Name)
+					for _, publisher := range publishers {
+						if err := publisher.Publish(ctx, event); err != nil {
+							log.Printf("could not publish '%v' event for '%v' in region '%v': %v", event.Type, sqsMessage.Name, sqsMessage.Region, err)
+						}
+					}
+				}
+
+				if alerts == nil {
+					continue
+				}
+
+				// A failed alert returns the message to the queue, and watchers already alerted are not
+				// alerted again when it is retried.
+				sent, err := alerts.AlertFreed(ctx, sqsMessage.Region, sqsMessage.Name)
 				if err != nil {
-					return err
+					log.Printf("could not alert watchers of '%v' in region '%v', returning message to the queue: %v", sqsMessage.Name, sqsMessage.Region, err)
+					response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+				} else if sent > 0 {
+					log.Printf("alerted %d watchers that '%v' is available in region '%v'", sent, sqsMessage.Name, sqsMessage.Region)
+				}
+
+				continue
+			}
+
+			if shared.IsThrottled(err) {
+				log.Printf("riot api throttled, returning %d messages to the queue: %v", len(event.Records)-i, err)
+				for _, remaining := range event.Records[i:] {
+					response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: remaining.MessageId})
 				}
+
+				break
 			}
 
-			return err
+			return response, err
 		}
 
 		err = summoners.Save(summoner)
+		if shared.IsSuppressed(err) {
+			log.Printf("summoner '%v' in region '%v' belongs to an erased account, deleting...", sqsMessage.Name, sqsMessage.Region)
+			if _, err = summoners.Delete(sqsMessage.Region, sqsMessage.Name); err != nil {
+				return response, err
+			}
+
+			continue
+		}
+
 		if err != nil {
-			return err
+			return response, err
 		}
 
 		log.Printf("summoner '%v' updated in region '%v'", summoner.Name, summoner.Region)
 	}
 
-	return nil
+	usage, err := summoners.RiotBudgetUsage()
+	if err == nil {
+		log.Printf("riot api budget: interactive %d (reserved %d), background %d of %d, limit %d", usage.InteractiveUsed, usage.Reserved, usage.BackgroundUsed, usage.BackgroundAllowance, usage.Limit)
+	}
+
+	return response, nil
 }
 
 func main() {
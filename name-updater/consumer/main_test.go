@@ -12,6 +12,10 @@ import (
 type SummonersServiceMock struct {
 	ShouldFail       bool
 	SummonerNotFound bool
+	CircuitOpen      bool
+	BudgetExhausted  bool
+	Suppressed       bool
+	NotStored        bool
 	FetchCalls       []struct {
 		Region string
 		Name   string
@@ -39,6 +43,10 @@ func (s *SummonersServiceMock) Fetch(region string, name string) (*shared.Summon
 		return nil, fmt.Errorf("summoner not found")
 	}
 
+	if s.BudgetExhausted && len(s.FetchCalls) > 1 {
+		return nil, &shared.RateLimitedError{Key: "na1#summoner-v4-by-name"}
+	}
+
 	return summonerDto, nil
 }
 
@@ -51,16 +59,60 @@ func (s *SummonersServiceMock) Save(summoner *shared.SummonerDTO) error {
 		return fmt.Errorf("error")
 	}
 
+	if s.Suppressed {
+		return &shared.SuppressedError{Name: summoner.Name}
+	}
+
 	return nil
 }
 
-func (s *SummonersServiceMock) Delete(region string, name string) error {
+func (s *SummonersServiceMock) Delete(region string, name string) (bool, error) {
 	s.DeleteCalls = append(s.DeleteCalls, struct {
 		Region string
 		Name   string
 	}{region, name})
 
 	if s.ShouldFail {
+		return false, fmt.Errorf("error")
+	}
+
+	return !s.NotStored, nil
+}
+
+func (s *SummonersServiceMock) RiotHealth() shared.CircuitHealth {
+	if s.CircuitOpen {
+		return shared.CircuitHealth{State: shared.CircuitOpen, Reason: "test"}
+	}
+
+	return shared.CircuitHealth{State: shared.CircuitClosed}
+}
+
+func (s *SummonersServiceMock) RiotBudgetUsage() (*shared.BudgetUsage, error) {
+	return &shared.BudgetUsage{Limit: 100}, nil
+}
+
+type WatchAlertsServiceMock struct {
+	ShouldFail bool
+	Calls      []string
+}
+
+func (w *WatchAlertsServiceMock) AlertFreed(_ context.Context, region string, name string) (int, error) {
+	w.Calls = append(w.Calls, region+"#"+name)
+	if w.ShouldFail {
+		return 0, fmt.Errorf("error")
+	}
+
+	return 1, nil
+}
+
+type EventsServiceMock struct {
+	ShouldFail bool
+	Events     []*shared.NameEvent
+}
+
+func (e *EventsServiceMock) Publish(_ context.Context, event *shared.NameEvent) error {
+	e.Events = append(e.Events, event)
+	if e.ShouldFail {
 		return fmt.Errorf("error")
 	}
 
@@ -82,6 +134,8 @@ func setup() {
 	}
 
 	summoners = &SummonersServiceMock{}
+	alerts = &WatchAlertsServiceMock{}
+	publishers = []eventsService{&EventsServiceMock{}, &EventsServiceMock{}}
 }
 
 func TestHandleRequest_CallsFetchWithCorrectParams(t *testing.T) {
@@ -95,7 +149,7 @@ func TestHandleRequest_CallsFetchWithCorrectParams(t *testing.T) {
 		},
 	}
 
-	err := HandleRequest(context.Background(), event)
+	_, err := HandleRequest(context.Background(), event)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -120,7 +174,7 @@ func TestHandleRequest_CallsSaveWithCorrectParams(t *testing.T) {
 		},
 	}
 
-	err := HandleRequest(context.Background(), event)
+	_, err := HandleRequest(context.Background(), event)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -143,7 +197,7 @@ func TestHandleRequest_CallsDeleteWithCorrectParams(t *testing.T) {
 		},
 	}
 
-	err := HandleRequest(context.Background(), event)
+	_, err := HandleRequest(context.Background(), event)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -175,7 +229,7 @@ func TestHandleRequest_IteratesOverAllMessages(t *testing.T) {
 		},
 	}
 
-	err := HandleRequest(context.Background(), event)
+	_, err := HandleRequest(context.Background(), event)
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -198,8 +252,268 @@ func TestHandleRequest_ReturnsError_WhenFail(t *testing.T) {
 		},
 	}
 
-	err := HandleRequest(context.Background(), event)
+	_, err := HandleRequest(context.Background(), event)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestHandleRequest_ReturnsErrorWithoutFetching_WhenRiotCircuitIsOpen(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).CircuitOpen = true
+
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				Body: `{"region":"NA","name":"test"}`,
+			},
+		},
+	}
+
+	_, err := HandleRequest(context.Background(), event)
 	if err == nil {
 		t.Errorf("expected error, got nil")
 	}
+
+	if len(summoners.(*SummonersServiceMock).FetchCalls) != 0 {
+		t.Errorf("expected 0 fetch calls, got %v", len(summoners.(*SummonersServiceMock).FetchCalls))
+	}
+}
+
+func TestHandleRequest_ReturnsRemainingMessagesAsFailures_WhenBudgetIsExhausted(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).BudgetExhausted = true
+
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				MessageId: "1",
+				Body:      `{"region":"NA","name":"first"}`,
+			},
+			{
+				MessageId: "2",
+				Body:      `{"region":"NA","name":"second"}`,
+			},
+			{
+				MessageId: "3",
+				Body:      `{"region":"NA","name":"third"}`,
+			},
+		},
+	}
+
+	response, err := HandleRequest(context.Background(), event)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if len(summoners.(*SummonersServiceMock).SaveCalls) != 1 {
+		t.Errorf("expected 1 save call, got %v", len(summoners.(*SummonersServiceMock).SaveCalls))
+	}
+
+	if len(response.BatchItemFailures) != 2 {
+		t.Fatalf("expected 2 batch item failures, got %v", len(response.BatchItemFailures))
+	}
+
+	if response.BatchItemFailures[0].ItemIdentifier != "2" || response.BatchItemFailures[1].ItemIdentifier != "3" {
+		t.Errorf("expected failures for messages 2 and 3, got %v", response.BatchItemFailures)
+	}
+}
+
+func TestHandleRequest_ContinuesWithNextMessage_AfterDeletingMissingSummoner(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).SummonerNotFound = true
+
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				Body: `{"region":"NA","name":"first"}`,
+			},
+			{
+				Body: `{"region":"NA","name":"second"}`,
+			},
+		},
+	}
+
+	_, err := HandleRequest(context.Background(), event)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if len(summoners.(*SummonersServiceMock).DeleteCalls) != 2 {
+		t.Errorf("expected 2 delete calls, got %v", len(summoners.(*SummonersServiceMock).DeleteCalls))
+	}
+}
+
+func TestHandleRequest_DeletesSummonerOfErasedAccountAndContinues(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).Suppressed = true
+
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				Body: `{"region":"NA","name":"first"}`,
+			},
+			{
+				Body: `{"region":"NA","name":"second"}`,
+			},
+		},
+	}
+
+	_, err := HandleRequest(context.Background(), event)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	deleteCalls := summoners.(*SummonersServiceMock).DeleteCalls
+	if len(deleteCalls) != 2 || deleteCalls[0].Name != "first" {
+		t.Errorf("expected both names to be deleted, got %v", deleteCalls)
+	}
+}
+
+func TestHandleRequest_AlertsWatchersOfMissingSummoner(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).SummonerNotFound = true
+
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				Body: `{"region":"NA","name":"freed"}`,
+			},
+		},
+	}
+
+	_, err := HandleRequest(context.Background(), event)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if calls := alerts.(*WatchAlertsServiceMock).Calls; len(calls) != 1 || calls[0] != "NA#freed" {
+		t.Errorf("expected watchers of 'freed' to be alerted, got %v", calls)
+	}
+}
+
+func TestHandleRequest_PublishesAvailableEventForMissingSummoner(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).SummonerNotFound = true
+	publishers[0].(*EventsServiceMock).ShouldFail = true
+
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				Body: `{"region":"NA","name":"freed"}`,
+			},
+		},
+	}
+
+	response, err := HandleRequest(context.Background(), event)
+	if err != nil || len(response.BatchItemFailures) != 0 {
+		t.Errorf("expected a failed publish not to fail the message, got %v, %v", response.BatchItemFailures, err)
+	}
+
+	for _, publisher := range publishers {
+		published := publisher.(*EventsServiceMock).Events
+		if len(published) != 1 || published[0].Type != shared.EventNameAvailable || published[0].Region != "NA" || published[0].Name != "freed" {
+			t.Errorf("expected each publisher to get a %s event for 'freed', got %v", shared.EventNameAvailable, published)
+		}
+	}
+}
+
+func TestHandleRequest_PublishesAvailableEventOnlyForStoredSummoner(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).SummonerNotFound = true
+	summoners.(*SummonersServiceMock).NotStored = true
+
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				Body: `{"region":"NA","name":"freed"}`,
+			},
+		},
+	}
+
+	if _, err := HandleRequest(context.Background(), event); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if published := publishers[0].(*EventsServiceMock).Events; len(published) != 0 {
+		t.Errorf("expected no event for a name that was not stored, got %v", published)
+	}
+
+	if calls := alerts.(*WatchAlertsServiceMock).Calls; len(calls) != 1 {
+		t.Errorf("expected watchers to still be alerted, got %v", calls)
+	}
+}
+
+func TestHandleRequest_DoesNotPublishAgain_WhenAlertFailsAndMessageIsRetried(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).SummonerNotFound = true
+	alerts.(*WatchAlertsServiceMock).ShouldFail = true
+
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				MessageId: "1",
+				Body:      `{"region":"NA","name":"freed"}`,
+			},
+		},
+	}
+
+	response, _ := HandleRequest(context.Background(), event)
+	if len(response.BatchItemFailures) != 1 {
+		t.Fatalf("expected the message to be returned, got %v", response.BatchItemFailures)
+	}
+
+	summoners.(*SummonersServiceMock).NotStored = true
+	_, _ = HandleRequest(context.Background(), event)
+
+	if published := publishers[0].(*EventsServiceMock).Events; len(published) != 1 {
+		t.Errorf("expected the event to be published once, got %v", published)
+	}
+}
+
+func TestHandleRequest_ReturnsMessageToQueue_WhenAlertFails(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).SummonerNotFound = true
+	alerts.(*WatchAlertsServiceMock).ShouldFail = true
+
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				MessageId: "1",
+				Body:      `{"region":"NA","name":"first"}`,
+			},
+			{
+				MessageId: "2",
+				Body:      `{"region":"NA","name":"second"}`,
+			},
+		},
+	}
+
+	response, err := HandleRequest(context.Background(), event)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if len(response.BatchItemFailures) != 2 || response.BatchItemFailures[1].ItemIdentifier != "2" {
+		t.Errorf("expected both messages to be returned, got %v", response.BatchItemFailures)
+	}
+}
+
+func TestHandleRequest_DoesNotAlertWatchersOfErasedAccount(t *testing.T) {
+	setup()
+	summoners.(*SummonersServiceMock).Suppressed = true
+
+	event := events.SQSEvent{
+		Records: []events.SQSMessage{
+			{
+				Body: `{"region":"NA","name":"erased"}`,
+			},
+		},
+	}
+
+	_, _ = HandleRequest(context.Background(), event)
+
+	if calls := alerts.(*WatchAlertsServiceMock).Calls; len(calls) != 0 {
+		t.Errorf("expected no alerts, got %v", calls)
+	}
 }
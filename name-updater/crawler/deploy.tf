@@ -0,0 +1,130 @@
+terraform {
+  backend "s3" {
+    bucket = "nameslol-deployments"
+    key    = "terraform/name-updater-crawler"
+    region = "us-east-1"
+  }
+}
+
+provider "aws" {
+  region = "us-east-1"
+}
+
+data "aws_dynamodb_table" "nameslol" {
+  name = "nameslol"
+}
+
+data "aws_sqs_queue" "name-update-queue" {
+  name = "NameUpdateQueue"
+}
+
+data "aws_ssm_parameter" "riot-api-token" {
+  name = "/riot-api-token"
+}
+
+module "lambda" {
+  source                = "../../infrastructure/modules/lambda"
+  app_name              = "name-updater-crawler"
+  bootstrap_file_path   = "${path.module}/bootstrap"
+  timeout               = 300
+  memory_size           = 256
+  iam_policy_statements = [
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "dynamodb:GetItem",
+        "dynamodb:PutItem",
+        "dynamodb:UpdateItem",
+        "dynamodb:DeleteItem",
+        "dynamodb:Query",
+      ],
+      "Resource" : [
+        data.aws_dynamodb_table.nameslol.arn,
+        "${data.aws_dynamodb_table.nameslol.arn}/index/*"
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "sqs:SendMessage",
+      ],
+      "Resource" : [
+        data.aws_sqs_queue.name-update-queue.arn
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "ssm:GetParameter"
+      ],
+      "Resource" : [
+        data.aws_ssm_parameter.riot-api-token.arn
+      ]
+    },
+  ]
+  environment_variables = {
+    QUEUE_URL             = data.aws_sqs_queue.name-update-queue.url
+    DYNAMODB_TABLE        = data.aws_dynamodb_table.nameslol.name
+    RIOT_API_KEY_SOURCE   = "ssm"
+    RIOT_API_KEY_NAMES    = data.aws_ssm_parameter.riot-api-token.name
+    RIOT_RATE_LIMIT_STORE = "dynamodb"
+    CRAWL_MAX_DEPTH       = "2"
+    CRAWL_MAX_MATCHES     = "50"
+    CRAWL_REGION_LIMITS   = "NA=3:100,EUW=3:100"
+  }
+}
+
+resource "aws_iam_role" "scheduler_exec" {
+  name = "name-updater-crawler-scheduler-role"
+  assume_role_policy = jsonencode({
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Effect": "Allow",
+        "Principal": {
+          "Service": "scheduler.amazonaws.com"
+        },
+        "Action": "sts:AssumeRole"
+      }
+    ]
+  })
+}
+
+resource "aws_iam_role_policy" "scheduler_exec_policy" {
+  role = aws_iam_role.scheduler_exec.id
+  policy = jsonencode({
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Action": [
+          "lambda:InvokeFunction"
+        ],
+        "Effect": "Allow",
+        "Resource": [
+          module.lambda.lambda_function_arn
+        ]
+      }
+    ]
+  })
+}
+
+resource "aws_scheduler_schedule" "crawl" {
+  name = "name-updater-crawl"
+  schedule_expression = "rate(15 minutes)"
+  state = "DISABLED"
+
+  flexible_time_window {
+    mode = "OFF"
+  }
+
+  target {
+    arn = module.lambda.lambda_function_arn
+    role_arn = aws_iam_role.scheduler_exec.arn
+
+    input = jsonencode({})
+
+    retry_policy {
+      maximum_retry_attempts = 0
+    }
+  }
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/bricefrisco/nameslol/shared"
+	"log"
+	"os"
+)
+
+// Event optionally limits a run to one region. Every region is crawled when it is empty.
+type Event struct {
+	Region string `json:"region"`
+}
+
+type crawlerService interface {
+	Crawl(ctx context.Context, region string, limits shared.CrawlLimits) (*shared.CrawlResult, error)
+}
+
+var crawler crawlerService
+var limits map[string]shared.CrawlLimits
+
+func init() {
+	log.SetFlags(0)
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("could not load AWS config, %v", err)
+	}
+
+	tableName := os.Getenv("DYNAMODB_TABLE")
+	summoners, err := shared.NewSummoners(tableName, shared.WorkloadBackground)
+	if err != nil {
+		log.Fatalf("could not create summoners service, %v", err)
+	}
+
+	queue := shared.NewNameUpdateQueue(sqs.NewFromConfig(cfg), os.Getenv("QUEUE_URL"))
+	crawler = shared.NewCrawler(shared.NewDynamoDbClient(cfg), tableName, summoners, summoners, queue)
+
+	limits, err = shared.CrawlLimitsFromEnv(shared.NewRegions().Sorted())
+	if err != nil {
+		log.Fatalf("could not read crawl limits, %v", err)
+	}
+}
+
+func HandleRequest(ctx context.Context, event *Event) error {
+	regions := shared.NewRegions().Sorted()
+	if event.Region != "" {
+		if _, ok := limits[event.Region]; !ok {
+			return fmt.Errorf("invalid region '%s'", event.Region)
+		}
+
+		regions = []string{event.Region}
+	}
+
+	for _, region := range regions {
+		if limits[region].MaxMatches == 0 {
+			log.Printf("crawling is disabled in region '%s'", region)
+			continue
+		}
+
+		result, err := crawler.Crawl(ctx, region, limits[region])
+		if err != nil {
+			return fmt.Errorf("could not crawl region '%s': %v", region, err)
+		}
+
+		log.Printf("crawled region '%s': seeded %d, crawled %d players and %d matches, enqueued %d names, %d players left on the frontier",
+			region, result.Seeded, result.Players, result.Matches, result.Enqueued, result.Frontier)
+
+		// The budget is shared by every region, so there is nothing left for the others either.
+		if result.Throttled {
+			log.Printf("riot api budget exhausted, stopping the crawl at region '%s'", region)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
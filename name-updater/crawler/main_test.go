@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"testing"
+)
+
+type CrawlerServiceMock struct {
+	ShouldFail     bool
+	ThrottleRegion string
+	Calls          []string
+}
+
+func (c *CrawlerServiceMock) Crawl(_ context.Context, region string, _ shared.CrawlLimits) (*shared.CrawlResult, error) {
+	c.Calls = append(c.Calls, region)
+	if c.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &shared.CrawlResult{Region: region, Throttled: region == c.ThrottleRegion}, nil
+}
+
+func setup() {
+	crawler = &CrawlerServiceMock{}
+	limits = map[string]shared.CrawlLimits{}
+	for _, region := range shared.NewRegions().Sorted() {
+		limits[region] = shared.CrawlLimits{MaxDepth: 2, MaxMatches: 10, MatchesPerPlayer: 5}
+	}
+}
+
+func TestHandleRequest_CrawlsEveryEnabledRegion(t *testing.T) {
+	setup()
+	limits["OCE"] = shared.CrawlLimits{}
+
+	if err := HandleRequest(context.TODO(), &Event{}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	calls := crawler.(*CrawlerServiceMock).Calls
+	if fmt.Sprint(calls) != "[EUNE EUW LAS NA]" {
+		t.Errorf("expected every region but OCE to be crawled, got %v", calls)
+	}
+}
+
+func TestHandleRequest_CrawlsRequestedRegion(t *testing.T) {
+	setup()
+
+	if err := HandleRequest(context.TODO(), &Event{Region: "NA"}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if calls := crawler.(*CrawlerServiceMock).Calls; len(calls) != 1 || calls[0] != "NA" {
+		t.Errorf("expected only NA to be crawled, got %v", calls)
+	}
+}
+
+func TestHandleRequest_RejectsInvalidRegion(t *testing.T) {
+	setup()
+
+	if err := HandleRequest(context.TODO(), &Event{Region: "BR"}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestHandleRequest_StopsWhenBudgetIsExhausted(t *testing.T) {
+	setup()
+	crawler.(*CrawlerServiceMock).ThrottleRegion = "EUW"
+
+	if err := HandleRequest(context.TODO(), &Event{}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if calls := crawler.(*CrawlerServiceMock).Calls; len(calls) != 2 {
+		t.Errorf("expected the crawl to stop after EUW, got %v", calls)
+	}
+}
+
+func TestHandleRequest_ReturnsCrawlErrors(t *testing.T) {
+	setup()
+	crawler.(*CrawlerServiceMock).ShouldFail = true
+
+	if err := HandleRequest(context.TODO(), &Event{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,132 @@
+terraform {
+  backend "s3" {
+    bucket = "nameslol-deployments"
+    key    = "terraform/name-updater-digest"
+    region = "us-east-1"
+  }
+}
+
+provider "aws" {
+  region = "us-east-1"
+}
+
+data "aws_dynamodb_table" "nameslol" {
+  name = "nameslol"
+}
+
+data "aws_s3_bucket" "digests" {
+  bucket = "nameslol-digests"
+}
+
+data "aws_ssm_parameter" "smtp-username" {
+  name = "/smtp-username"
+}
+
+data "aws_ssm_parameter" "smtp-password" {
+  name = "/smtp-password"
+}
+
+module "lambda" {
+  source                = "../../infrastructure/modules/lambda"
+  app_name              = "name-updater-digest"
+  bootstrap_file_path   = "${path.module}/bootstrap"
+  timeout               = 300
+  memory_size           = 512
+  iam_policy_statements = [
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "dynamodb:GetItem",
+        "dynamodb:PutItem",
+        "dynamodb:Query",
+      ],
+      "Resource" : [
+        data.aws_dynamodb_table.nameslol.arn,
+        "${data.aws_dynamodb_table.nameslol.arn}/index/*"
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "s3:PutObject"
+      ],
+      "Resource" : [
+        "${data.aws_s3_bucket.digests.arn}/digests/*"
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "ssm:GetParameter"
+      ],
+      "Resource" : [
+        data.aws_ssm_parameter.smtp-password.arn
+      ]
+    },
+  ]
+  environment_variables = {
+    DYNAMODB_TABLE         = data.aws_dynamodb_table.nameslol.name
+    DIGEST_BUCKET          = data.aws_s3_bucket.digests.bucket
+    DIGEST_URL             = "https://${data.aws_s3_bucket.digests.bucket_regional_domain_name}"
+    DIGEST_UNSUBSCRIBE_URL = "https://names.lol/digest/unsubscribe"
+    SMTP_ADDR              = "email-smtp.us-east-1.amazonaws.com:587"
+    SMTP_FROM              = "digest@names.lol"
+    SMTP_USERNAME          = data.aws_ssm_parameter.smtp-username.value
+    SMTP_PASSWORD_NAME     = data.aws_ssm_parameter.smtp-password.name
+  }
+}
+
+resource "aws_iam_role" "scheduler_exec" {
+  name = "name-updater-digest-scheduler-role"
+  assume_role_policy = jsonencode({
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Effect": "Allow",
+        "Principal": {
+          "Service": "scheduler.amazonaws.com"
+        },
+        "Action": "sts:AssumeRole"
+      }
+    ]
+  })
+}
+
+resource "aws_iam_role_policy" "scheduler_exec_policy" {
+  role = aws_iam_role.scheduler_exec.id
+  policy = jsonencode({
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Action": [
+          "lambda:InvokeFunction"
+        ],
+        "Effect": "Allow",
+        "Resource": [
+          module.lambda.lambda_function_arn
+        ]
+      }
+    ]
+  })
+}
+
+resource "aws_scheduler_schedule" "daily-digest" {
+  name = "name-updater-daily-digest"
+  schedule_expression = "cron(0 6 * * ? *)"
+  state = "DISABLED"
+
+  flexible_time_window {
+    mode = "OFF"
+  }
+
+  target {
+    arn = module.lambda.lambda_function_arn
+    role_arn = aws_iam_role.scheduler_exec.arn
+
+    input = jsonencode({})
+
+    retry_policy {
+      maximum_retry_attempts = 2
+    }
+  }
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bricefrisco/nameslol/shared"
+	"log"
+	"os"
+)
+
+type digestsService interface {
+	Run(ctx context.Context) (*shared.DigestResult, error)
+}
+
+var digests digestsService
+
+func init() {
+	log.SetFlags(0)
+
+	tableName := os.Getenv("DYNAMODB_TABLE")
+	summoners, err := shared.NewSummoners(tableName, shared.WorkloadBackground)
+	if err != nil {
+		log.Fatalf("could not create summoners service, %v", err)
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("could not load AWS config, %v", err)
+	}
+
+	if os.Getenv("DIGEST_BUCKET") == "" {
+		log.Printf("digests are not configured, set DIGEST_BUCKET")
+		return
+	}
+
+	d, err := shared.NewDigestsFromEnv(context.TODO(), shared.NewDynamoDbClient(cfg), tableName, summoners, s3.NewFromConfig(cfg), shared.NewSSMSecretsSource(cfg))
+	if err != nil {
+		log.Fatalf("could not create digests, %v", err)
+	}
+
+	// The digest is public, so it leaves out blocked names like the summoners listings do.
+	d.FilterNames(shared.NewBlocklist(shared.NewDynamoDbClient(cfg), tableName, nil).Allows)
+	digests = d
+}
+
+// HandleRequest publishes the digest of the names freeing over the next 24 hours and emails it to digest
+// subscribers. A failed run can be retried without emailing anyone twice.
+func HandleRequest(ctx context.Context) error {
+	if digests == nil {
+		return fmt.Errorf("digests are not configured")
+	}
+
+	result, err := digests.Run(ctx)
+	if result != nil {
+		log.Printf("published %d names freeing on %s to '%s', emailed %d of %d subscribers, %d dropped", result.Names, result.Date, result.Key, result.Sent, result.Subscribers, result.Dropped)
+	}
+
+	return err
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
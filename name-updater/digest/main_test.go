@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"testing"
+)
+
+type DigestsServiceMock struct {
+	ShouldFail bool
+	Calls      int
+}
+
+func (d *DigestsServiceMock) Run(_ context.Context) (*shared.DigestResult, error) {
+	d.Calls++
+	if d.ShouldFail {
+		return &shared.DigestResult{Date: "2024-02-12"}, fmt.Errorf("error")
+	}
+
+	return &shared.DigestResult{Date: "2024-02-12", Names: 3, Key: "digests/2024-02-12.json"}, nil
+}
+
+func TestHandleRequest_RunsDigest(t *testing.T) {
+	digests = &DigestsServiceMock{}
+
+	if err := HandleRequest(context.TODO()); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if calls := digests.(*DigestsServiceMock).Calls; calls != 1 {
+		t.Errorf("expected one run, got %d", calls)
+	}
+}
+
+func TestHandleRequest_ReturnsErrorWhenRunFails(t *testing.T) {
+	digests = &DigestsServiceMock{ShouldFail: true}
+
+	if err := HandleRequest(context.TODO()); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestHandleRequest_ReturnsErrorWhenNotConfigured(t *testing.T) {
+	digests = nil
+
+	if err := HandleRequest(context.TODO()); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
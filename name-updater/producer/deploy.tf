@@ -18,10 +18,6 @@ data "aws_sqs_queue" "name-update-queue" {
   name = "NameUpdateQueue"
 }
 
-data "aws_ssm_parameter" "riot-api-token" {
-  name = "/riot-api-token"
-}
-
 module "lambda" {
   source = "../../infrastructure/modules/lambda"
   app_name = "name-updater-producer"
@@ -53,7 +49,6 @@ module "lambda" {
   environment_variables = {
     QUEUE_URL      = data.aws_sqs_queue.name-update-queue.url
     DYNAMODB_TABLE = data.aws_dynamodb_table.nameslol.name
-    RIOT_API_TOKEN = data.aws_ssm_parameter.riot-api-token.value
   }
 }
 
@@ -20,7 +20,7 @@ type MockSummonerService struct {
 	}
 }
 
-func (m *MockSummonerService) GetBetweenDate(region string, limit int32, start int64, end int64) ([]*shared.SummonerDTO, error) {
+func (m *MockSummonerService) GetBetweenDate(region string, limit int32, start int64, end int64) (*shared.SummonersPage, error) {
 	if m.ShouldFail {
 		return nil, fmt.Errorf("error")
 	}
@@ -44,7 +44,7 @@ func (m *MockSummonerService) GetBetweenDate(region string, limit int32, start i
 		SummonerIcon:     123,
 	}
 
-	return summonerDtos, nil
+	return &shared.SummonersPage{Summoners: summonerDtos}, nil
 }
 
 type MockRegionService struct {
@@ -80,6 +80,10 @@ func (m *MockSQSService) SendMessage(ctx context.Context, params *sqs.SendMessag
 	return &sqs.SendMessageOutput{}, nil
 }
 
+func (m *MockSQSService) SendMessageBatch(_ context.Context, _ *sqs.SendMessageBatchInput, _ ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	return &sqs.SendMessageBatchOutput{}, nil
+}
+
 func setup() {
 	summoners = &MockSummonerService{}
 	regions = &MockRegionService{}
@@ -292,7 +296,7 @@ func TestHandleRequest_CallsSendToQueueWithCorrectBody(t *testing.T) {
 
 	// map doesn't retain order, so we need to check both calls
 	mockQueue := queue.(*MockSQSService)
-	body := &SQSMessage{
+	body := &shared.NameUpdateMessage{
 		Region: "NA",
 		Name:   "Testing",
 	}
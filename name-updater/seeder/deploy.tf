@@ -0,0 +1,152 @@
+terraform {
+  backend "s3" {
+    bucket = "nameslol-deployments"
+    key    = "terraform/name-updater-seeder"
+    region = "us-east-1"
+  }
+}
+
+provider "aws" {
+  region = "us-east-1"
+}
+
+data "aws_dynamodb_table" "nameslol" {
+  name = "nameslol"
+}
+
+data "aws_sqs_queue" "webhook-delivery-queue" {
+  name = "WebhookDeliveryQueue"
+}
+
+data "aws_ssm_parameter" "riot-api-token" {
+  name = "/riot-api-token"
+}
+
+data "aws_ssm_parameter" "smtp-username" {
+  name = "/smtp-username"
+}
+
+data "aws_ssm_parameter" "smtp-password" {
+  name = "/smtp-password"
+}
+
+data "aws_ssm_parameter" "vapid-private-key" {
+  name = "/vapid-private-key"
+}
+
+module "lambda" {
+  source                = "../../infrastructure/modules/lambda"
+  app_name              = "name-updater-seeder"
+  bootstrap_file_path   = "${path.module}/bootstrap"
+  timeout               = 900
+  memory_size           = 256
+  iam_policy_statements = [
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "dynamodb:GetItem",
+        "dynamodb:PutItem",
+        "dynamodb:UpdateItem",
+        "dynamodb:DeleteItem",
+        "dynamodb:Query",
+        "dynamodb:BatchWriteItem",
+      ],
+      "Resource" : [
+        data.aws_dynamodb_table.nameslol.arn,
+        "${data.aws_dynamodb_table.nameslol.arn}/index/*"
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "ssm:GetParameter"
+      ],
+      "Resource" : [
+        data.aws_ssm_parameter.riot-api-token.arn,
+        data.aws_ssm_parameter.smtp-password.arn,
+        data.aws_ssm_parameter.vapid-private-key.arn
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "sqs:SendMessage"
+      ],
+      "Resource" : [
+        data.aws_sqs_queue.webhook-delivery-queue.arn
+      ]
+    },
+  ]
+  environment_variables = {
+    DYNAMODB_TABLE                 = data.aws_dynamodb_table.nameslol.name
+    RIOT_API_KEY_SOURCE            = "ssm"
+    RIOT_API_KEY_NAMES             = data.aws_ssm_parameter.riot-api-token.name
+    RIOT_RATE_LIMIT_STORE          = "dynamodb"
+    LEAGUE_QUEUES                  = "RANKED_SOLO_5x5"
+    LEAGUE_TIERS                   = "CHALLENGER,GRANDMASTER,MASTER,DIAMOND,EMERALD"
+    LEAGUE_SWEEP_INTERVAL          = "24h"
+    SMTP_ADDR                      = "email-smtp.us-east-1.amazonaws.com:587"
+    SMTP_FROM                      = "alerts@names.lol"
+    SMTP_USERNAME                  = data.aws_ssm_parameter.smtp-username.value
+    SMTP_PASSWORD_NAME             = data.aws_ssm_parameter.smtp-password.name
+    WEBPUSH_SUBJECT                = "mailto:alerts@names.lol"
+    WEBPUSH_VAPID_PRIVATE_KEY_NAME = data.aws_ssm_parameter.vapid-private-key.name
+    PATTERN_UNSUBSCRIBE_URL        = "https://names.lol/patterns/unsubscribe"
+    WEBHOOK_QUEUE_URL              = data.aws_sqs_queue.webhook-delivery-queue.url
+  }
+}
+
+resource "aws_iam_role" "scheduler_exec" {
+  name = "name-updater-seeder-scheduler-role"
+  assume_role_policy = jsonencode({
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Effect": "Allow",
+        "Principal": {
+          "Service": "scheduler.amazonaws.com"
+        },
+        "Action": "sts:AssumeRole"
+      }
+    ]
+  })
+}
+
+resource "aws_iam_role_policy" "scheduler_exec_policy" {
+  role = aws_iam_role.scheduler_exec.id
+  policy = jsonencode({
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Action": [
+          "lambda:InvokeFunction"
+        ],
+        "Effect": "Allow",
+        "Resource": [
+          module.lambda.lambda_function_arn
+        ]
+      }
+    ]
+  })
+}
+
+resource "aws_scheduler_schedule" "league-sweep" {
+  name = "name-updater-league-sweep"
+  schedule_expression = "rate(1 hour)"
+  state = "DISABLED"
+
+  flexible_time_window {
+    mode = "OFF"
+  }
+
+  target {
+    arn = module.lambda.lambda_function_arn
+    role_arn = aws_iam_role.scheduler_exec.arn
+
+    input = jsonencode({})
+
+    retry_policy {
+      maximum_retry_attempts = 0
+    }
+  }
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/bricefrisco/nameslol/shared"
+	"log"
+	"os"
+	"time"
+)
+
+// Event optionally limits a run to one region. Every region is swept when it is empty.
+type Event struct {
+	Region string `json:"region"`
+}
+
+type sweeperService interface {
+	Sweep(ctx context.Context, region string, deadline time.Time) (*shared.LeagueSweepResult, error)
+}
+
+// deadlineMargin is the time left at the end of an invocation to save the sweep progress.
+const deadlineMargin = 20 * time.Second
+
+var sweeper sweeperService
+var now = time.Now
+
+func init() {
+	log.SetFlags(0)
+
+	tableName := os.Getenv("DYNAMODB_TABLE")
+	summoners, err := shared.NewSummoners(tableName, shared.WorkloadBackground)
+	if err != nil {
+		log.Fatalf("could not create summoners service, %v", err)
+	}
+
+	leagues, interval, err := shared.LeaguesFromEnv()
+	if err != nil {
+		log.Fatalf("could not read leagues, %v", err)
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("could not load AWS config, %v", err)
+	}
+
+	// Swept summoners are saved like updated ones, so they are indexed and their events published.
+	summoners.IndexNames(shared.NewNameSearch(shared.NewDynamoDbClient(cfg), tableName))
+
+	if queueUrl := os.Getenv("WEBHOOK_QUEUE_URL"); queueUrl != "" {
+		summoners.PublishEvents(shared.NewWebhooks(shared.NewDynamoDbClient(cfg), tableName, sqs.NewFromConfig(cfg), queueUrl))
+	}
+
+	if shared.WatchAlertsConfigured() {
+		patterns, err := shared.NewPatternAlerterFromEnv(context.TODO(), shared.NewDynamoDbClient(cfg), tableName, summoners, shared.NewSSMSecretsSource(cfg))
+		if err != nil {
+			log.Fatalf("could not create pattern alerts, %v", err)
+		}
+
+		summoners.PublishEvents(patterns)
+	}
+
+	sweeper = shared.NewLeagueSweeper(shared.NewDynamoDbClient(cfg), tableName, summoners, leagues, interval)
+}
+
+func HandleRequest(ctx context.Context, event *Event) error {
+	deadline := now().Add(10 * time.Minute)
+	if lambdaDeadline, ok := ctx.Deadline(); ok {
+		deadline = lambdaDeadline.Add(-deadlineMargin)
+	}
+
+	regions := shared.NewRegions().Sorted()
+	if event.Region != "" {
+		if !shared.NewRegions().Validate(event.Region) {
+			return fmt.Errorf("invalid region '%s'", event.Region)
+		}
+
+		regions = []string{event.Region}
+	}
+
+	for _, region := range regions {
+		result, err := sweeper.Sweep(ctx, region, deadline)
+		if err != nil {
+			return fmt.Errorf("could not sweep region '%s': %v", region, err)
+		}
+
+		log.Printf("swept region '%s': saved %d summoners, skipped %d, completed leagues %v", region, result.Saved, result.Skipped, result.Completed)
+
+		if result.Throttled {
+			log.Printf("riot api budget exhausted, stopping the sweep at region '%s'", region)
+			return nil
+		}
+
+		if result.OutOfTime {
+			log.Printf("out of time, stopping the sweep at region '%s'", region)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"testing"
+	"time"
+)
+
+type SweeperServiceMock struct {
+	ShouldFail      bool
+	ThrottleRegion  string
+	OutOfTimeRegion string
+	Calls           []string
+	Deadlines       []time.Time
+}
+
+func (s *SweeperServiceMock) Sweep(_ context.Context, region string, deadline time.Time) (*shared.LeagueSweepResult, error) {
+	s.Calls = append(s.Calls, region)
+	s.Deadlines = append(s.Deadlines, deadline)
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &shared.LeagueSweepResult{Region: region, Throttled: region == s.ThrottleRegion, OutOfTime: region == s.OutOfTimeRegion}, nil
+}
+
+func setup() {
+	sweeper = &SweeperServiceMock{}
+	now = func() time.Time { return time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC) }
+}
+
+func TestHandleRequest_SweepsEveryRegion(t *testing.T) {
+	setup()
+
+	if err := HandleRequest(context.TODO(), &Event{}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if calls := sweeper.(*SweeperServiceMock).Calls; fmt.Sprint(calls) != "[EUNE EUW LAS NA OCE]" {
+		t.Errorf("expected every region to be swept, got %v", calls)
+	}
+}
+
+func TestHandleRequest_LeavesMarginBeforeLambdaDeadline(t *testing.T) {
+	setup()
+	deadline := time.Date(2024, time.February, 12, 8, 15, 0, 0, time.UTC)
+	ctx, cancel := context.WithDeadline(context.TODO(), deadline)
+	defer cancel()
+
+	_ = HandleRequest(ctx, &Event{Region: "NA"})
+
+	deadlines := sweeper.(*SweeperServiceMock).Deadlines
+	if len(deadlines) != 1 || !deadlines[0].Equal(deadline.Add(-deadlineMargin)) {
+		t.Errorf("expected deadline %s, got %v", deadline.Add(-deadlineMargin), deadlines)
+	}
+}
+
+func TestHandleRequest_RejectsInvalidRegion(t *testing.T) {
+	setup()
+
+	if err := HandleRequest(context.TODO(), &Event{Region: "BR"}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestHandleRequest_StopsWhenThrottledOrOutOfTime(t *testing.T) {
+	setup()
+	sweeper.(*SweeperServiceMock).ThrottleRegion = "EUW"
+
+	_ = HandleRequest(context.TODO(), &Event{})
+	if calls := sweeper.(*SweeperServiceMock).Calls; len(calls) != 2 {
+		t.Errorf("expected the sweep to stop after EUW, got %v", calls)
+	}
+
+	setup()
+	sweeper.(*SweeperServiceMock).OutOfTimeRegion = "EUNE"
+
+	_ = HandleRequest(context.TODO(), &Event{})
+	if calls := sweeper.(*SweeperServiceMock).Calls; len(calls) != 1 {
+		t.Errorf("expected the sweep to stop after EUNE, got %v", calls)
+	}
+}
+
+func TestHandleRequest_ReturnsSweepErrors(t *testing.T) {
+	setup()
+	sweeper.(*SweeperServiceMock).ShouldFail = true
+
+	if err := HandleRequest(context.TODO(), &Event{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,65 @@
+terraform {
+  backend "s3" {
+    bucket = "nameslol-deployments"
+    key    = "terraform/name-updater-webhooks"
+    region = "us-east-1"
+  }
+}
+
+provider "aws" {
+  region = "us-east-1"
+}
+
+data "aws_dynamodb_table" "nameslol" {
+  name = "nameslol"
+}
+
+data "aws_sqs_queue" "webhook-delivery-queue" {
+  name = "WebhookDeliveryQueue"
+}
+
+module "lambda" {
+  source                = "../../infrastructure/modules/lambda"
+  app_name              = "name-updater-webhooks"
+  bootstrap_file_path   = "${path.module}/bootstrap"
+  timeout               = 60
+  memory_size           = 128
+  iam_policy_statements = [
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "dynamodb:GetItem",
+        "dynamodb:PutItem",
+      ],
+      "Resource" : [
+        data.aws_dynamodb_table.nameslol.arn
+      ]
+    },
+    {
+      "Effect" : "Allow",
+      "Action" : [
+        "sqs:ReceiveMessage",
+        "sqs:DeleteMessage",
+        "sqs:GetQueueAttributes",
+        "sqs:SendMessage",
+      ],
+      "Resource" : [
+        data.aws_sqs_queue.webhook-delivery-queue.arn
+      ]
+    },
+  ]
+  environment_variables = {
+    DYNAMODB_TABLE    = data.aws_dynamodb_table.nameslol.name
+    WEBHOOK_QUEUE_URL = data.aws_sqs_queue.webhook-delivery-queue.url
+  }
+}
+
+resource "aws_lambda_event_source_mapping" "default" {
+  event_source_arn        = data.aws_sqs_queue.webhook-delivery-queue.arn
+  function_name           = module.lambda.lambda_function_arn
+  batch_size              = 10
+  function_response_types = ["ReportBatchItemFailures"]
+  scaling_config {
+    maximum_concurrency = 5
+  }
+}
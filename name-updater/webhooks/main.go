@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/bricefrisco/nameslol/shared"
+	"log"
+	"os"
+)
+
+type deliveryService interface {
+	Deliver(ctx context.Context, delivery *shared.WebhookDelivery) error
+}
+
+var webhooks deliveryService
+
+func init() {
+	log.SetFlags(0)
+
+	cfg, err := config.LoadDefaultConfig(context.TODO())
+	if err != nil {
+		log.Fatalf("could not load AWS config, %v", err)
+	}
+
+	webhooks = shared.NewWebhooks(shared.NewDynamoDbClient(cfg), os.Getenv("DYNAMODB_TABLE"), sqs.NewFromConfig(cfg), os.Getenv("WEBHOOK_QUEUE_URL"))
+}
+
+// HandleRequest delivers each message's event to its endpoint. Failed attempts are re-queued with a delay
+// by Deliver itself, so only deliveries whose outcome could not be recorded are returned to the queue.
+func HandleRequest(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	var response events.SQSEventResponse
+
+	for _, message := range event.Records {
+		var delivery shared.WebhookDelivery
+		if err := json.Unmarshal([]byte(message.Body), &delivery); err != nil {
+			log.Printf("dropping unreadable delivery '%v': %v", message.MessageId, err)
+			continue
+		}
+
+		if err := webhooks.Deliver(ctx, &delivery); err != nil {
+			log.Printf("could not deliver '%v' to endpoint '%v', returning message to the queue: %v", delivery.ID, delivery.EndpointID, err)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+			continue
+		}
+
+		log.Printf("processed delivery '%v' of '%v' to endpoint '%v'", delivery.ID, delivery.Event.Type, delivery.EndpointID)
+	}
+
+	return response, nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
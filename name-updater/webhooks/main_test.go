@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/bricefrisco/nameslol/shared"
+	"testing"
+)
+
+type DeliveryServiceMock struct {
+	FailDelivery string
+	Calls        []*shared.WebhookDelivery
+}
+
+func (d *DeliveryServiceMock) Deliver(_ context.Context, delivery *shared.WebhookDelivery) error {
+	d.Calls = append(d.Calls, delivery)
+	if delivery.ID == d.FailDelivery {
+		return fmt.Errorf("error")
+	}
+
+	return nil
+}
+
+func setup() {
+	webhooks = &DeliveryServiceMock{}
+}
+
+func message(id string, body string) events.SQSMessage {
+	return events.SQSMessage{MessageId: id, Body: body}
+}
+
+func TestHandleRequest_DeliversEveryMessage(t *testing.T) {
+	setup()
+
+	response, err := HandleRequest(context.TODO(), events.SQSEvent{Records: []events.SQSMessage{
+		message("1", `{"id":"a","endpointId":"e1","event":{"type":"name.available","region":"NA","name":"name"}}`),
+		message("2", `{"id":"b","endpointId":"e2","event":{"type":"name.taken","region":"EUW","name":"name"},"attempt":2}`),
+	}})
+	if err != nil || len(response.BatchItemFailures) != 0 {
+		t.Fatalf("expected no failures, got %v, %v", response.BatchItemFailures, err)
+	}
+
+	calls := webhooks.(*DeliveryServiceMock).Calls
+	if len(calls) != 2 || calls[0].EndpointID != "e1" || calls[1].Attempt != 2 || calls[1].Event.Region != "EUW" {
+		t.Errorf("expected both deliveries to be made, got %v", calls)
+	}
+}
+
+func TestHandleRequest_ReturnsFailedDeliveriesToTheQueue(t *testing.T) {
+	setup()
+	webhooks.(*DeliveryServiceMock).FailDelivery = "a"
+
+	response, _ := HandleRequest(context.TODO(), events.SQSEvent{Records: []events.SQSMessage{
+		message("1", `{"id":"a","endpointId":"e1","event":{"type":"name.available"}}`),
+		message("2", `{"id":"b","endpointId":"e1","event":{"type":"name.available"}}`),
+	}})
+
+	if len(response.BatchItemFailures) != 1 || response.BatchItemFailures[0].ItemIdentifier != "1" {
+		t.Errorf("expected only the failed delivery to be returned, got %v", response.BatchItemFailures)
+	}
+}
+
+func TestHandleRequest_DropsUnreadableMessages(t *testing.T) {
+	setup()
+
+	response, err := HandleRequest(context.TODO(), events.SQSEvent{Records: []events.SQSMessage{message("1", "not json")}})
+	if err != nil || len(response.BatchItemFailures) != 0 || len(webhooks.(*DeliveryServiceMock).Calls) != 0 {
+		t.Errorf("expected the message to be dropped, got %v, %v", response.BatchItemFailures, err)
+	}
+}
@@ -0,0 +1,272 @@
+package shared
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodApiKey = "api-key"
+)
+
+// AdminPrincipal is the operator an admin request was authenticated as.
+type AdminPrincipal struct {
+	Subject string `json:"subject"`
+	Method  string `json:"method"`
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Subject   string `json:"sub"`
+	Issuer    string `json:"iss"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+}
+
+type adminKeyRevocations interface {
+	IsRevoked(ctx context.Context, key string) (bool, error)
+}
+
+// AdminAuth verifies admin credentials against a shared signing secret. Operators send either an HS256
+// JWT as "Authorization: Bearer <token>" or a signed API key as "X-Api-Key: <key>".
+type AdminAuth struct {
+	mu          sync.Mutex
+	source      SecretsSource
+	secretName  string
+	secret      []byte
+	issuer      string
+	revocations adminKeyRevocations
+	now         func() time.Time
+}
+
+func NewAdminAuth(source SecretsSource, secretName string, issuer string) *AdminAuth {
+	return &AdminAuth{
+		source:     source,
+		secretName: secretName,
+		issuer:     issuer,
+		now:        time.Now,
+	}
+}
+
+// NewAdminAuthFromEnv loads the signing secret from the SSM parameter named by ADMIN_AUTH_SECRET_NAME,
+// falling back to the ADMIN_AUTH_SECRET variable. When ADMIN_JWT_ISSUER is set, JWTs must carry it as
+// their "iss" claim.
+func NewAdminAuthFromEnv(cfg aws.Config) *AdminAuth {
+	issuer := os.Getenv("ADMIN_JWT_ISSUER")
+	if name := os.Getenv("ADMIN_AUTH_SECRET_NAME"); name != "" {
+		return NewAdminAuth(NewSSMSecretsSource(cfg), name, issuer)
+	}
+
+	return NewAdminAuth(StaticSecretsSource{"ADMIN_AUTH_SECRET": os.Getenv("ADMIN_AUTH_SECRET")}, "ADMIN_AUTH_SECRET", issuer)
+}
+
+// NewAdminApiKey signs an API key for an operator that is valid until expiresAt. Operator names may not
+// contain dots.
+func NewAdminApiKey(secret string, operator string, expiresAt time.Time) (string, error) {
+	if operator == "" || strings.Contains(operator, ".") {
+		return "", fmt.Errorf("invalid operator name '%s'", operator)
+	}
+
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return operator + "." + expires + "." + sign([]byte(secret), apiKeyMessage(operator, expires)), nil
+}
+
+// CheckRevocations rejects API keys that were revoked, see AdminKeyRevocations.
+func (a *AdminAuth) CheckRevocations(revocations adminKeyRevocations) {
+	a.revocations = revocations
+}
+
+// Authenticate checks the credentials in a request's headers. Header names are matched case-insensitively
+// since API Gateway passes them through as the client sent them.
+func (a *AdminAuth) Authenticate(ctx context.Context, headers map[string]string) (*AdminPrincipal, error) {
+	var authorization, apiKey string
+	for name, value := range headers {
+		switch strings.ToLower(name) {
+		case "authorization":
+			authorization = value
+		case "x-api-key":
+			apiKey = value
+		}
+	}
+
+	secret, err := a.loadSecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if token, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+		return a.verifyJWT(secret, token)
+	}
+
+	if apiKey != "" {
+		return a.verifyApiKey(ctx, secret, apiKey)
+	}
+
+	return nil, fmt.Errorf("missing credentials")
+}
+
+func (a *AdminAuth) loadSecret(ctx context.Context) ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.secret != nil {
+		return a.secret, nil
+	}
+
+	secret, err := a.source.GetSecret(ctx, a.secretName)
+	if err != nil {
+		return nil, fmt.Errorf("could not load admin signing secret: %v", err)
+	}
+
+	if secret == "" {
+		return nil, fmt.Errorf("admin signing secret '%s' is empty", a.secretName)
+	}
+
+	a.secret = []byte(secret)
+	return a.secret, nil
+}
+
+func (a *AdminAuth) verifyJWT(secret []byte, token string) (*AdminPrincipal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %v", err)
+	}
+
+	if header.Algorithm != "HS256" {
+		return nil, fmt.Errorf("unsupported token algorithm '%s'", header.Algorithm)
+	}
+
+	if !verify(secret, parts[0]+"."+parts[1], parts[2]) {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %v", err)
+	}
+
+	now := a.now().Unix()
+	if claims.ExpiresAt == 0 || now >= claims.ExpiresAt {
+		return nil, fmt.Errorf("token has expired")
+	}
+
+	if claims.NotBefore != 0 && now < claims.NotBefore {
+		return nil, fmt.Errorf("token is not valid yet")
+	}
+
+	if a.issuer != "" && claims.Issuer != a.issuer {
+		return nil, fmt.Errorf("unexpected token issuer '%s'", claims.Issuer)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("token has no subject")
+	}
+
+	return &AdminPrincipal{Subject: claims.Subject, Method: AuthMethodJWT}, nil
+}
+
+func (a *AdminAuth) verifyApiKey(ctx context.Context, secret []byte, key string) (*AdminPrincipal, error) {
+	parsed, err := parseAdminApiKey(key)
+	if err != nil || !verify(secret, apiKeyMessage(parsed.operator, strconv.FormatInt(parsed.expiresAt, 10)), parsed.signature) {
+		return nil, fmt.Errorf("invalid api key")
+	}
+
+	if a.now().Unix() >= parsed.expiresAt {
+		return nil, fmt.Errorf("api key of '%s' has expired", parsed.operator)
+	}
+
+	if a.revocations != nil {
+		revoked, err := a.revocations.IsRevoked(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("could not check api key revocations: %v", err)
+		}
+
+		if revoked {
+			return nil, fmt.Errorf("api key of '%s' has been revoked", parsed.operator)
+		}
+	}
+
+	return &AdminPrincipal{Subject: parsed.operator, Method: AuthMethodApiKey}, nil
+}
+
+type adminApiKey struct {
+	operator  string
+	expiresAt int64
+	signature string
+}
+
+// parseAdminApiKey splits an "<operator>.<expiry>.<signature>" API key. Older keys without an expiry
+// are rejected, as are keys not spelled the way NewAdminApiKey writes them, so a key has a single spelling
+// to revoke.
+func parseAdminApiKey(key string) (*adminApiKey, error) {
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return nil, fmt.Errorf("malformed api key")
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || strconv.FormatInt(expiresAt, 10) != parts[1] {
+		return nil, fmt.Errorf("malformed api key expiry '%s'", parts[1])
+	}
+
+	signature, err := base64.RawURLEncoding.Strict().DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed api key signature: %v", err)
+	}
+
+	return &adminApiKey{operator: parts[0], expiresAt: expiresAt, signature: base64.RawURLEncoding.EncodeToString(signature)}, nil
+}
+
+// String returns the key as NewAdminApiKey writes it.
+func (k *adminApiKey) String() string {
+	return k.operator + "." + strconv.FormatInt(k.expiresAt, 10) + "." + k.signature
+}
+
+func apiKeyMessage(operator string, expires string) string {
+	return "api-key:" + operator + ":" + expires
+}
+
+func sign(secret []byte, message string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(message))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func verify(secret []byte, message string, signature string) bool {
+	expected, err := base64.RawURLEncoding.Strict().DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(message))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
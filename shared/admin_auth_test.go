@@ -0,0 +1,172 @@
+package shared
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAdminSecret = "test-secret"
+
+func newTestAdminAuth(issuer string) *AdminAuth {
+	auth := NewAdminAuth(StaticSecretsSource{"secret": testAdminSecret}, "secret", issuer)
+	auth.now = func() time.Time {
+		return time.Unix(1700000000, 0)
+	}
+	return auth
+}
+
+func testJWT(secret string, header string, claims string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	return encoded + "." + sign([]byte(secret), encoded)
+}
+
+func TestAdminAuth_AcceptsValidJWT(t *testing.T) {
+	token := testJWT(testAdminSecret, `{"alg":"HS256","typ":"JWT"}`, `{"sub":"brice","iss":"nameslol","exp":1700000060}`)
+
+	principal, err := newTestAdminAuth("nameslol").Authenticate(context.TODO(), map[string]string{"authorization": "Bearer " + token})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if principal.Subject != "brice" || principal.Method != AuthMethodJWT {
+		t.Errorf("unexpected principal %+v", principal)
+	}
+}
+
+func TestAdminAuth_RejectsInvalidJWTs(t *testing.T) {
+	tests := map[string]string{
+		"bad signature":  testJWT("other-secret", `{"alg":"HS256"}`, `{"sub":"brice","exp":1700000060}`),
+		"expired":        testJWT(testAdminSecret, `{"alg":"HS256"}`, `{"sub":"brice","exp":1700000000}`),
+		"no expiry":      testJWT(testAdminSecret, `{"alg":"HS256"}`, `{"sub":"brice"}`),
+		"not yet valid":  testJWT(testAdminSecret, `{"alg":"HS256"}`, `{"sub":"brice","exp":1700000060,"nbf":1700000030}`),
+		"none algorithm": testJWT(testAdminSecret, `{"alg":"none"}`, `{"sub":"brice","exp":1700000060}`),
+		"wrong issuer":   testJWT(testAdminSecret, `{"alg":"HS256"}`, `{"sub":"brice","iss":"other","exp":1700000060}`),
+		"no subject":     testJWT(testAdminSecret, `{"alg":"HS256"}`, `{"iss":"nameslol","exp":1700000060}`),
+		"malformed":      "not-a-token",
+	}
+
+	for name, token := range tests {
+		_, err := newTestAdminAuth("nameslol").Authenticate(context.TODO(), map[string]string{"Authorization": "Bearer " + token})
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+var testKeyExpiry = time.Unix(1700000060, 0)
+
+type KeyRevocationsMock struct {
+	ShouldFail bool
+	Revoked    []string
+}
+
+func (k *KeyRevocationsMock) IsRevoked(_ context.Context, key string) (bool, error) {
+	if k.ShouldFail {
+		return false, fmt.Errorf("error")
+	}
+
+	for _, revoked := range k.Revoked {
+		if revoked == key {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func TestAdminAuth_AcceptsSignedApiKey(t *testing.T) {
+	key, err := NewAdminApiKey(testAdminSecret, "brice", testKeyExpiry)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	principal, err := newTestAdminAuth("").Authenticate(context.TODO(), map[string]string{"X-Api-Key": key})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if principal.Subject != "brice" || principal.Method != AuthMethodApiKey {
+		t.Errorf("unexpected principal %+v", principal)
+	}
+}
+
+func TestAdminAuth_RejectsTamperedApiKey(t *testing.T) {
+	key, _ := NewAdminApiKey(testAdminSecret, "brice", testKeyExpiry)
+	parts := strings.Split(key, ".")
+
+	tests := map[string]string{
+		"other operator": "admin." + parts[1] + "." + parts[2],
+		"later expiry":   "brice.1800000000." + parts[2],
+		"no expiry":      "brice." + sign([]byte(testAdminSecret), "api-key:brice"),
+	}
+
+	for name, tampered := range tests {
+		_, err := newTestAdminAuth("").Authenticate(context.TODO(), map[string]string{"x-api-key": tampered})
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestAdminAuth_RejectsExpiredApiKey(t *testing.T) {
+	key, _ := NewAdminApiKey(testAdminSecret, "brice", time.Unix(1700000000, 0))
+
+	_, err := newTestAdminAuth("").Authenticate(context.TODO(), map[string]string{"x-api-key": key})
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expected expired error, got %v", err)
+	}
+}
+
+func TestAdminAuth_RejectsRevokedApiKey(t *testing.T) {
+	key, _ := NewAdminApiKey(testAdminSecret, "brice", testKeyExpiry)
+	other, _ := NewAdminApiKey(testAdminSecret, "alice", testKeyExpiry)
+
+	auth := newTestAdminAuth("")
+	auth.CheckRevocations(&KeyRevocationsMock{Revoked: []string{key}})
+
+	if _, err := auth.Authenticate(context.TODO(), map[string]string{"x-api-key": key}); err == nil || !strings.Contains(err.Error(), "revoked") {
+		t.Errorf("expected revoked error, got %v", err)
+	}
+
+	if _, err := auth.Authenticate(context.TODO(), map[string]string{"x-api-key": other}); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestAdminAuth_FailsWhenRevocationsCannotBeChecked(t *testing.T) {
+	key, _ := NewAdminApiKey(testAdminSecret, "brice", testKeyExpiry)
+
+	auth := newTestAdminAuth("")
+	auth.CheckRevocations(&KeyRevocationsMock{ShouldFail: true})
+
+	if _, err := auth.Authenticate(context.TODO(), map[string]string{"x-api-key": key}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestAdminAuth_RejectsMissingCredentials(t *testing.T) {
+	_, err := newTestAdminAuth("").Authenticate(context.TODO(), map[string]string{})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestAdminAuth_FailsWhenSecretIsMissing(t *testing.T) {
+	auth := NewAdminAuth(StaticSecretsSource{"secret": ""}, "secret", "")
+	key, _ := NewAdminApiKey("", "brice", testKeyExpiry)
+
+	_, err := auth.Authenticate(context.TODO(), map[string]string{"x-api-key": key})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestNewAdminApiKey_RejectsOperatorWithDot(t *testing.T) {
+	if _, err := NewAdminApiKey(testAdminSecret, "a.b", testKeyExpiry); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,85 @@
+package shared
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"time"
+)
+
+type revocationsDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+type keyRevocation struct {
+	Key      string `dynamodbav:"n"`
+	Operator string `dynamodbav:"op"`
+	Time     int64  `dynamodbav:"ts"`
+	Expiry   int64  `dynamodbav:"ttl"`
+}
+
+// AdminKeyRevocations lists revoked admin API keys. Revocations are stored in the summoners table under
+// "revoked#<key hash>" keys, and expire along with the key they revoke.
+type AdminKeyRevocations struct {
+	dynamodb  revocationsDynamoDbService
+	tableName string
+	now       func() time.Time
+}
+
+func NewAdminKeyRevocations(client revocationsDynamoDbService, tableName string) *AdminKeyRevocations {
+	return &AdminKeyRevocations{dynamodb: client, tableName: tableName, now: time.Now}
+}
+
+// Revoke stops an API key from authenticating before it expires.
+func (r *AdminKeyRevocations) Revoke(ctx context.Context, key string) error {
+	parsed, err := parseAdminApiKey(key)
+	if err != nil {
+		return err
+	}
+
+	item, err := attributevalue.MarshalMap(&keyRevocation{
+		Key:      revocationKey(parsed),
+		Operator: parsed.operator,
+		Time:     r.now().UnixMilli(),
+		Expiry:   parsed.expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.dynamodb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      item,
+	})
+
+	return err
+}
+
+func (r *AdminKeyRevocations) IsRevoked(ctx context.Context, key string) (bool, error) {
+	parsed, err := parseAdminApiKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	output, err := r.dynamodb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: revocationKey(parsed)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return len(output.Item) > 0, nil
+}
+
+// revocationKey hashes the canonical spelling of a key, so the table never holds usable API keys.
+func revocationKey(key *adminApiKey) string {
+	hash := sha256.Sum256([]byte(key.String()))
+	return "revoked#" + hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,113 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+type RevocationsDynamoDBServiceMock struct {
+	ShouldFail bool
+	Items      map[string]map[string]types.AttributeValue
+}
+
+func (r *RevocationsDynamoDBServiceMock) GetItem(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if r.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	key := input.Key["n"].(*types.AttributeValueMemberS).Value
+	return &dynamodb.GetItemOutput{Item: r.Items[key]}, nil
+}
+
+func (r *RevocationsDynamoDBServiceMock) PutItem(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if r.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	if r.Items == nil {
+		r.Items = map[string]map[string]types.AttributeValue{}
+	}
+
+	r.Items[input.Item["n"].(*types.AttributeValueMemberS).Value] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestAdminKeyRevocations_RevokesKeyUntilItExpires(t *testing.T) {
+	mock := &RevocationsDynamoDBServiceMock{}
+	revocations := NewAdminKeyRevocations(mock, "test-table")
+	key, _ := NewAdminApiKey(testAdminSecret, "brice", time.Unix(1700000060, 0))
+	other, _ := NewAdminApiKey(testAdminSecret, "alice", time.Unix(1700000060, 0))
+
+	if err := revocations.Revoke(context.TODO(), key); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if revoked, err := revocations.IsRevoked(context.TODO(), key); err != nil || !revoked {
+		t.Errorf("expected key to be revoked, got %v, %v", revoked, err)
+	}
+
+	if revoked, _ := revocations.IsRevoked(context.TODO(), other); revoked {
+		t.Errorf("expected other key not to be revoked")
+	}
+
+	for k, item := range mock.Items {
+		if !strings.HasPrefix(k, "revoked#") || strings.Contains(k, "brice") {
+			t.Errorf("expected a hashed revocation key, got %s", k)
+		}
+
+		if numberValue(item["ttl"]) != 1700000060 {
+			t.Errorf("expected revocation to expire with the key, got %v", item["ttl"])
+		}
+	}
+}
+
+func TestAdminKeyRevocations_RejectsMalformedKey(t *testing.T) {
+	revocations := NewAdminKeyRevocations(&RevocationsDynamoDBServiceMock{}, "test-table")
+
+	if err := revocations.Revoke(context.TODO(), "not-a-key"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestAdminKeyRevocations_ReturnsErrorWhenCheckFails(t *testing.T) {
+	revocations := NewAdminKeyRevocations(&RevocationsDynamoDBServiceMock{ShouldFail: true}, "test-table")
+
+	key, _ := NewAdminApiKey(testAdminSecret, "brice", time.Unix(1700000060, 0))
+	if _, err := revocations.IsRevoked(context.TODO(), key); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestAdminAuth_RejectsOtherSpellingsOfRevokedKey(t *testing.T) {
+	revocations := NewAdminKeyRevocations(&RevocationsDynamoDBServiceMock{}, "test-table")
+	key, _ := NewAdminApiKey(testAdminSecret, "brice", time.Unix(1700000060, 0))
+	if err := revocations.Revoke(context.TODO(), key); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	auth := newTestAdminAuth("")
+	auth.CheckRevocations(revocations)
+
+	parts := strings.Split(key, ".")
+	signature := []byte(parts[2])
+	signature[len(signature)-1] = base64Alphabet[strings.IndexByte(base64Alphabet, signature[len(signature)-1])|1]
+
+	spellings := map[string]string{
+		"plus sign":       "brice.+1700000060." + parts[2],
+		"leading zero":    "brice.01700000060." + parts[2],
+		"unused sig bits": "brice.1700000060." + string(signature),
+	}
+
+	for name, spelling := range spellings {
+		if _, err := auth.Authenticate(context.TODO(), map[string]string{"x-api-key": spelling}); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+const base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
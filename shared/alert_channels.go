@@ -0,0 +1,106 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+type mailerService interface {
+	Send(ctx context.Context, to string, subject string, body string) error
+}
+
+type pushService interface {
+	Push(ctx context.Context, subscription *PushSubscription, payload []byte) error
+}
+
+// alertPushPayload is the JSON a subscriber's service worker receives. Body is the first paragraph of the
+// email alert.
+type alertPushPayload struct {
+	Title          string `json:"title"`
+	Body           string `json:"body"`
+	Region         string `json:"region"`
+	Name           string `json:"name,omitempty"`
+	UnsubscribeUrl string `json:"unsubscribeUrl,omitempty"`
+}
+
+// alertChannels sends alerts by email, or by push to subscribers who subscribed from a browser. Either
+// channel may be missing, and its subscribers are then not alerted.
+type alertChannels struct {
+	mailer mailerService
+	pusher pushService
+}
+
+// alertChannelsFromEnv configures email when SMTP_ADDR is set and push notifications when a VAPID key is,
+// see SMTPMailerFromEnv and WebPushFromEnv.
+func alertChannelsFromEnv(ctx context.Context, secrets SecretsSource) (alertChannels, error) {
+	var channels alertChannels
+	if !WatchAlertsConfigured() {
+		return channels, errors.New("no alert channel is configured, set SMTP_ADDR or WEBPUSH_VAPID_PRIVATE_KEY_NAME")
+	}
+
+	if os.Getenv("SMTP_ADDR") != "" {
+		mailer, err := SMTPMailerFromEnv(ctx, secrets)
+		if err != nil {
+			return channels, err
+		}
+		channels.mailer = mailer
+	}
+
+	if webPushConfigured() {
+		pusher, err := WebPushFromEnv(ctx, secrets)
+		if err != nil {
+			return channels, err
+		}
+		channels.pusher = pusher
+	}
+
+	return channels, nil
+}
+
+// WatchAlertsConfigured reports whether the environment configures email or push alerts.
+func WatchAlertsConfigured() bool {
+	return os.Getenv("SMTP_ADDR") != "" || webPushConfigured()
+}
+
+// UsePush sends push subscriptions' alerts with the pusher. Without one they are not alerted.
+func (c *alertChannels) UsePush(pusher pushService) {
+	c.pusher = pusher
+}
+
+// handles reports whether the channel for a subscriber, by push when push is set and otherwise by email,
+// is configured.
+func (c *alertChannels) handles(push *PushSubscription) bool {
+	if push != nil {
+		return c.pusher != nil
+	}
+
+	return c.mailer != nil
+}
+
+func (c *alertChannels) send(ctx context.Context, contact string, push *PushSubscription, subject string, body string, payload alertPushPayload) error {
+	if push == nil {
+		return c.mailer.Send(ctx, contact, subject, body)
+	}
+
+	paragraph, _, _ := strings.Cut(body, "\n\n")
+	payload.Title, payload.Body = subject, strings.TrimSpace(paragraph)
+
+	encoded, err := json.Marshal(&payload)
+	if err != nil {
+		return err
+	}
+
+	return c.pusher.Push(ctx, push, encoded)
+}
+
+// isPermanentAlertError reports whether a subscriber can no longer be reached, so retrying would not help:
+// the mail server rejected their address for good, such as for an unknown recipient, or the push service
+// no longer knows their subscription.
+func isPermanentAlertError(err error) bool {
+	var protocolErr *textproto.Error
+	return (errors.As(err, &protocolErr) && protocolErr.Code >= 500) || IsPushGone(err)
+}
@@ -0,0 +1,89 @@
+package shared
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"time"
+)
+
+type auditDynamoDbService interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+}
+
+// AuditPending is the outcome of an entry recorded before its mutation ran. It stays pending when the
+// mutation's outcome could not be written.
+const AuditPending = "pending"
+
+// AuditEntry records one admin mutation. Entries are stored in the summoners table under
+// "audit#<timestamp>#<id>" keys, which have no region attribute so they never appear in summoner
+// queries or scans.
+type AuditEntry struct {
+	Key     string            `dynamodbav:"n" json:"-"`
+	Actor   string            `dynamodbav:"act" json:"actor"`
+	Method  string            `dynamodbav:"am" json:"method"`
+	Action  string            `dynamodbav:"ac" json:"action"`
+	Target  string            `dynamodbav:"tg" json:"target"`
+	Details map[string]string `dynamodbav:"dt,omitempty" json:"details,omitempty"`
+	Outcome string            `dynamodbav:"oc" json:"outcome"`
+	Time    int64             `dynamodbav:"ts" json:"time"`
+}
+
+type AuditLog struct {
+	dynamodb  auditDynamoDbService
+	tableName string
+	now       func() time.Time
+}
+
+func NewAuditLog(client auditDynamoDbService, tableName string) *AuditLog {
+	return &AuditLog{dynamodb: client, tableName: tableName, now: time.Now}
+}
+
+// Record stamps an entry with the current time and a unique key and writes it.
+func (a *AuditLog) Record(ctx context.Context, entry *AuditEntry) error {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	entry.Time = a.now().UnixMilli()
+	entry.Key = fmt.Sprintf("audit#%d#%s", entry.Time, hex.EncodeToString(id))
+
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.dynamodb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(a.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(n)"),
+	})
+
+	return err
+}
+
+// Complete writes the outcome of a recorded entry.
+func (a *AuditLog) Complete(ctx context.Context, entry *AuditEntry, outcome string) error {
+	_, err := a.dynamodb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(a.tableName),
+		Key:                 map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: entry.Key}},
+		UpdateExpression:    aws.String("SET oc = :oc"),
+		ConditionExpression: aws.String("attribute_exists(n)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":oc": &types.AttributeValueMemberS{Value: outcome},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	entry.Outcome = outcome
+	return nil
+}
@@ -0,0 +1,101 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+type AuditDynamoDBServiceMock struct {
+	ShouldFail      bool
+	PutItemCalls    []*dynamodb.PutItemInput
+	UpdateItemCalls []*dynamodb.UpdateItemInput
+}
+
+func (a *AuditDynamoDBServiceMock) PutItem(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	a.PutItemCalls = append(a.PutItemCalls, input)
+
+	if a.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (a *AuditDynamoDBServiceMock) UpdateItem(_ context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	a.UpdateItemCalls = append(a.UpdateItemCalls, input)
+
+	if a.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func newTestAuditLog(mock *AuditDynamoDBServiceMock) *AuditLog {
+	auditLog := NewAuditLog(mock, "test-table")
+	auditLog.now = func() time.Time {
+		return time.UnixMilli(1700000000000)
+	}
+	return auditLog
+}
+
+func TestAuditLog_WritesEntry(t *testing.T) {
+	mock := &AuditDynamoDBServiceMock{}
+
+	err := newTestAuditLog(mock).Record(context.TODO(), &AuditEntry{
+		Actor:   "brice",
+		Method:  AuthMethodJWT,
+		Action:  "hide",
+		Target:  "NA#TEST",
+		Outcome: "ok",
+	})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	item := mock.PutItemCalls[0].Item
+	if key := item["n"].(*types.AttributeValueMemberS).Value; !strings.HasPrefix(key, "audit#1700000000000#") {
+		t.Errorf("expected audit key, got %s", key)
+	}
+
+	if item["act"].(*types.AttributeValueMemberS).Value != "brice" || item["ts"].(*types.AttributeValueMemberN).Value != "1700000000000" {
+		t.Errorf("unexpected item %v", item)
+	}
+
+	if item["r"] != nil {
+		t.Errorf("expected audit entries to have no region attribute")
+	}
+}
+
+func TestAuditLog_ReturnsErrorWhenPutFails(t *testing.T) {
+	mock := &AuditDynamoDBServiceMock{ShouldFail: true}
+
+	if err := newTestAuditLog(mock).Record(context.TODO(), &AuditEntry{Action: "delete"}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestAuditLog_CompletesRecordedEntry(t *testing.T) {
+	mock := &AuditDynamoDBServiceMock{}
+	auditLog := newTestAuditLog(mock)
+	entry := &AuditEntry{Action: "delete", Outcome: AuditPending}
+	_ = auditLog.Record(context.TODO(), entry)
+
+	if err := auditLog.Complete(context.TODO(), entry, "ok"); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	input := mock.UpdateItemCalls[0]
+	if input.Key["n"].(*types.AttributeValueMemberS).Value != entry.Key || input.ExpressionAttributeValues[":oc"].(*types.AttributeValueMemberS).Value != "ok" {
+		t.Errorf("unexpected update %+v", input)
+	}
+
+	if entry.Outcome != "ok" {
+		t.Errorf("expected the entry's outcome to be set, got %s", entry.Outcome)
+	}
+}
@@ -0,0 +1,325 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"golang.org/x/text/unicode/norm"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Blocklist rule kinds. Exact rules match the name as written, ignoring case. Normalized rules match every
+// name Riot treats as the same, ignoring whitespace too. Pattern rules are case-insensitive regular
+// expressions matched against the normalized name. Word rules add terms to the profanity dictionary.
+const (
+	BlockExact      = "exact"
+	BlockNormalized = "normalized"
+	BlockPattern    = "pattern"
+	BlockWord       = "word"
+)
+
+// blocklistKey holds every rule in a single item, which is small enough to load on each cache refresh.
+const blocklistKey = "blocklist#rules"
+
+type blocklistDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// BlockRule blocks matching names from the public listings and detail responses. A rule without a region
+// applies to every region.
+type BlockRule struct {
+	Kind    string `dynamodbav:"k" json:"kind"`
+	Value   string `dynamodbav:"v" json:"value"`
+	Region  string `dynamodbav:"rg,omitempty" json:"region,omitempty"`
+	Reason  string `dynamodbav:"rs,omitempty" json:"reason,omitempty"`
+	AddedAt int64  `dynamodbav:"at" json:"addedAt"`
+}
+
+type BlockMatch struct {
+	Rule BlockRule `json:"rule"`
+	Term string    `json:"term"`
+}
+
+type blocklistItem struct {
+	Key       string      `dynamodbav:"n"`
+	Rules     []BlockRule `dynamodbav:"rules"`
+	UpdatedAt int64       `dynamodbav:"ua"`
+}
+
+func (i *blocklistItem) updatedAt() *int64 {
+	return &i.UpdatedAt
+}
+
+// ProfanityDictionary finds offensive terms in names that have been folded with FoldName.
+type ProfanityDictionary interface {
+	Match(folded string) (string, bool)
+}
+
+// WordListDictionary matches names containing any of its words.
+type WordListDictionary struct {
+	words []string
+}
+
+func NewWordListDictionary(words []string) *WordListDictionary {
+	folded := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = FoldName(word); word != "" {
+			folded = append(folded, word)
+		}
+	}
+
+	return &WordListDictionary{words: folded}
+}
+
+func (w *WordListDictionary) Match(folded string) (string, bool) {
+	for _, word := range w.words {
+		if strings.Contains(folded, word) {
+			return word, true
+		}
+	}
+
+	return "", false
+}
+
+var leetReplacer = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b",
+	"@", "a", "$", "s", "!", "i", ".", "", "_", "",
+)
+
+// FoldName normalizes a name and undoes common character substitutions, so dictionary words also match
+// spellings like "n4m3" or "n.a.m.e".
+func FoldName(name string) string {
+	return leetReplacer.Replace(NormalizeName(name))
+}
+
+type compiledRule struct {
+	rule    BlockRule
+	value   string
+	pattern *regexp.Regexp
+}
+
+type Blocklist struct {
+	mu         sync.Mutex
+	dynamodb   blocklistDynamoDbService
+	tableName  string
+	dictionary ProfanityDictionary
+	ttl        time.Duration
+	rules      []compiledRule
+	loadedAt   time.Time
+	now        func() time.Time
+}
+
+// NewBlocklist reads rules from the table, caching them for a minute. The dictionary, which may be nil,
+// is consulted in addition to the table's word rules.
+func NewBlocklist(client blocklistDynamoDbService, tableName string, dictionary ProfanityDictionary) *Blocklist {
+	return &Blocklist{
+		dynamodb:   client,
+		tableName:  tableName,
+		dictionary: dictionary,
+		ttl:        time.Minute,
+		now:        time.Now,
+	}
+}
+
+// Match returns the first rule a name matches, or nil if it is not blocked.
+func (b *Blocklist) Match(ctx context.Context, region string, name string) (*BlockMatch, error) {
+	rules, err := b.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	written := strings.ToLower(norm.NFC.String(name))
+	normalized := NormalizeName(name)
+
+	for _, compiled := range rules {
+		if compiled.rule.Region != "" && compiled.rule.Region != region {
+			continue
+		}
+
+		var matched bool
+		switch compiled.rule.Kind {
+		case BlockExact:
+			matched = written == compiled.value
+		case BlockNormalized:
+			matched = normalized == compiled.value
+		case BlockPattern:
+			matched = compiled.pattern.MatchString(normalized)
+		}
+
+		if matched {
+			return &BlockMatch{Rule: compiled.rule, Term: compiled.rule.Value}, nil
+		}
+	}
+
+	folded := FoldName(name)
+	for _, compiled := range rules {
+		if compiled.rule.Kind != BlockWord || (compiled.rule.Region != "" && compiled.rule.Region != region) {
+			continue
+		}
+
+		if strings.Contains(folded, compiled.value) {
+			return &BlockMatch{Rule: compiled.rule, Term: compiled.value}, nil
+		}
+	}
+
+	if b.dictionary != nil {
+		if term, ok := b.dictionary.Match(folded); ok {
+			return &BlockMatch{Rule: BlockRule{Kind: BlockWord, Reason: "dictionary"}, Term: term}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Allows reports whether a summoner may be shown publicly. Names are allowed when the rules cannot be
+// loaded, so an outage of the blocklist does not take the listings down with it.
+func (b *Blocklist) Allows(summoner *SummonerDTO) bool {
+	match, err := b.Match(context.TODO(), summoner.Region, summoner.Name)
+	if err != nil {
+		log.Printf("could not check blocklist for '%s': %v", summoner.Name, err)
+		return true
+	}
+
+	if match != nil {
+		log.Printf("blocked '%s' in region '%s' by %s rule '%s'", summoner.Name, summoner.Region, match.Rule.Kind, match.Term)
+		return false
+	}
+
+	return true
+}
+
+func (b *Blocklist) Rules(ctx context.Context) ([]BlockRule, error) {
+	item, err := b.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return item.Rules, nil
+}
+
+func (b *Blocklist) AddRule(ctx context.Context, rule BlockRule) error {
+	if _, err := compileRule(rule); err != nil {
+		return err
+	}
+
+	return b.update(ctx, func(item *blocklistItem) error {
+		for _, existing := range item.Rules {
+			if sameRule(existing, rule) {
+				return fmt.Errorf("%s rule '%s' already exists", rule.Kind, rule.Value)
+			}
+		}
+
+		rule.AddedAt = b.now().UnixMilli()
+		item.Rules = append(item.Rules, rule)
+		return nil
+	})
+}
+
+func (b *Blocklist) RemoveRule(ctx context.Context, rule BlockRule) error {
+	return b.update(ctx, func(item *blocklistItem) error {
+		for i, existing := range item.Rules {
+			if sameRule(existing, rule) {
+				item.Rules = append(item.Rules[:i], item.Rules[i+1:]...)
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%s rule '%s' does not exist", rule.Kind, rule.Value)
+	})
+}
+
+func sameRule(a BlockRule, b BlockRule) bool {
+	return a.Kind == b.Kind && a.Value == b.Value && a.Region == b.Region
+}
+
+func compileRule(rule BlockRule) (compiledRule, error) {
+	compiled := compiledRule{rule: rule}
+	if strings.TrimSpace(rule.Value) == "" {
+		return compiled, fmt.Errorf("rule value is required")
+	}
+
+	switch rule.Kind {
+	case BlockExact:
+		compiled.value = strings.ToLower(norm.NFC.String(rule.Value))
+	case BlockNormalized:
+		compiled.value = NormalizeName(rule.Value)
+	case BlockPattern:
+		pattern, err := regexp.Compile("(?i)" + rule.Value)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid pattern '%s': %v", rule.Value, err)
+		}
+		compiled.pattern = pattern
+	case BlockWord:
+		compiled.value = FoldName(rule.Value)
+	default:
+		return compiled, fmt.Errorf("unknown rule kind '%s'", rule.Kind)
+	}
+
+	return compiled, nil
+}
+
+// load returns the cached rules, reloading them once the cache expires. If reloading fails the previous
+// rules are kept.
+func (b *Blocklist) load(ctx context.Context) ([]compiledRule, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.loadedAt.IsZero() && b.now().Sub(b.loadedAt) < b.ttl {
+		return b.rules, nil
+	}
+
+	item, err := b.get(ctx)
+	if err != nil {
+		if !b.loadedAt.IsZero() {
+			log.Printf("could not reload blocklist, keeping previous rules: %v", err)
+			return b.rules, nil
+		}
+
+		return nil, err
+	}
+
+	rules := make([]compiledRule, 0, len(item.Rules))
+	for _, rule := range item.Rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			log.Printf("skipping invalid blocklist rule: %v", err)
+			continue
+		}
+
+		rules = append(rules, compiled)
+	}
+
+	b.rules = rules
+	b.loadedAt = b.now()
+	return b.rules, nil
+}
+
+func (b *Blocklist) get(ctx context.Context) (*blocklistItem, error) {
+	item := &blocklistItem{Key: blocklistKey}
+	return item, getSingleItem(ctx, b.dynamodb, b.tableName, blocklistKey, item)
+}
+
+// update applies change to the stored rules, failing if another writer changed them in the meantime.
+func (b *Blocklist) update(ctx context.Context, change func(item *blocklistItem) error) error {
+	item, err := b.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = updateSingleItem(ctx, b.dynamodb, b.tableName, item, b.now(), func() error {
+		return change(item)
+	})
+
+	if err == nil {
+		b.mu.Lock()
+		b.loadedAt = time.Time{}
+		b.mu.Unlock()
+	}
+
+	return err
+}
@@ -0,0 +1,188 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"testing"
+	"time"
+)
+
+type BlocklistTableMock struct {
+	Item         map[string]types.AttributeValue
+	ShouldFail   bool
+	GetItemCalls int
+	Concurrent   bool
+}
+
+func (b *BlocklistTableMock) GetItem(_ context.Context, _ *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	b.GetItemCalls++
+	if b.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &dynamodb.GetItemOutput{Item: b.Item}, nil
+}
+
+func (b *BlocklistTableMock) PutItem(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if b.Concurrent {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	b.Item = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func newTestBlocklist(dictionary ProfanityDictionary, rules ...BlockRule) (*Blocklist, *BlocklistTableMock) {
+	mock := &BlocklistTableMock{}
+	blocklist := NewBlocklist(mock, "test-table", dictionary)
+
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	blocklist.now = clock.Now
+
+	for _, rule := range rules {
+		if err := blocklist.AddRule(context.TODO(), rule); err != nil {
+			panic(err)
+		}
+	}
+
+	return blocklist, mock
+}
+
+func TestBlocklist_MatchesRuleKinds(t *testing.T) {
+	blocklist, _ := newTestBlocklist(nil,
+		BlockRule{Kind: BlockExact, Value: "Bad Name"},
+		BlockRule{Kind: BlockNormalized, Value: "worse name"},
+		BlockRule{Kind: BlockPattern, Value: "^x+y+z+$"},
+		BlockRule{Kind: BlockWord, Value: "rude"},
+		BlockRule{Kind: BlockExact, Value: "local", Region: "EUW"},
+	)
+
+	tests := map[string]bool{
+		"bad name":    true,
+		"badname":     false,
+		"WorseName":   true,
+		"W o r s e N": false,
+		"XXYYZ":       true,
+		"xyzw":        false,
+		"so rud3":     true,
+		"r.u.d.e guy": true,
+		"crude":       true,
+		"polite":      false,
+	}
+
+	for name, blocked := range tests {
+		match, err := blocklist.Match(context.TODO(), "NA", name)
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+
+		if (match != nil) != blocked {
+			t.Errorf("%s: expected blocked %v, got %v", name, blocked, match)
+		}
+	}
+}
+
+func TestBlocklist_RegionRulesOnlyApplyToTheirRegion(t *testing.T) {
+	blocklist, _ := newTestBlocklist(nil, BlockRule{Kind: BlockExact, Value: "local", Region: "EUW"})
+
+	if match, _ := blocklist.Match(context.TODO(), "NA", "local"); match != nil {
+		t.Errorf("expected NA name to be allowed")
+	}
+
+	if match, _ := blocklist.Match(context.TODO(), "EUW", "local"); match == nil {
+		t.Errorf("expected EUW name to be blocked")
+	}
+}
+
+func TestBlocklist_ConsultsDictionary(t *testing.T) {
+	blocklist, _ := newTestBlocklist(NewWordListDictionary([]string{"Meanie"}))
+
+	match, err := blocklist.Match(context.TODO(), "NA", "big m3anie")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if match == nil || match.Term != "meanie" {
+		t.Errorf("expected dictionary match, got %v", match)
+	}
+}
+
+func TestBlocklist_CachesRules(t *testing.T) {
+	blocklist, mock := newTestBlocklist(nil, BlockRule{Kind: BlockExact, Value: "bad"})
+	calls := mock.GetItemCalls
+
+	_, _ = blocklist.Match(context.TODO(), "NA", "a")
+	_, _ = blocklist.Match(context.TODO(), "NA", "b")
+
+	if mock.GetItemCalls != calls+1 {
+		t.Errorf("expected rules to be loaded once, got %d loads", mock.GetItemCalls-calls)
+	}
+}
+
+func TestBlocklist_KeepsPreviousRulesWhenReloadFails(t *testing.T) {
+	blocklist, mock := newTestBlocklist(nil, BlockRule{Kind: BlockExact, Value: "bad"})
+	_, _ = blocklist.Match(context.TODO(), "NA", "bad")
+
+	mock.ShouldFail = true
+	blocklist.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	match, err := blocklist.Match(context.TODO(), "NA", "bad")
+	if err != nil || match == nil {
+		t.Errorf("expected cached rule to still match, got %v %v", match, err)
+	}
+}
+
+func TestBlocklist_AllowsWhenRulesCannotBeLoaded(t *testing.T) {
+	blocklist, mock := newTestBlocklist(nil)
+	mock.ShouldFail = true
+
+	if !blocklist.Allows(&SummonerDTO{Name: "anything", Region: "NA"}) {
+		t.Errorf("expected name to be allowed")
+	}
+}
+
+func TestBlocklist_AddAndRemoveRules(t *testing.T) {
+	blocklist, _ := newTestBlocklist(nil, BlockRule{Kind: BlockExact, Value: "bad", Reason: "harassment"})
+
+	if err := blocklist.AddRule(context.TODO(), BlockRule{Kind: BlockExact, Value: "bad"}); err == nil {
+		t.Errorf("expected duplicate rule to be rejected")
+	}
+
+	rules, err := blocklist.Rules(context.TODO())
+	if err != nil || len(rules) != 1 || rules[0].Reason != "harassment" || rules[0].AddedAt == 0 {
+		t.Fatalf("unexpected rules %v %v", rules, err)
+	}
+
+	if err = blocklist.RemoveRule(context.TODO(), BlockRule{Kind: BlockExact, Value: "bad"}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if match, _ := blocklist.Match(context.TODO(), "NA", "bad"); match != nil {
+		t.Errorf("expected removed rule to stop matching")
+	}
+
+	if err = blocklist.RemoveRule(context.TODO(), BlockRule{Kind: BlockExact, Value: "bad"}); err == nil {
+		t.Errorf("expected error removing a missing rule")
+	}
+}
+
+func TestBlocklist_RejectsInvalidRules(t *testing.T) {
+	blocklist, _ := newTestBlocklist(nil)
+
+	for _, rule := range []BlockRule{{Kind: BlockPattern, Value: "(["}, {Kind: "fuzzy", Value: "x"}, {Kind: BlockExact, Value: " "}} {
+		if err := blocklist.AddRule(context.TODO(), rule); err == nil {
+			t.Errorf("expected %v to be rejected", rule)
+		}
+	}
+}
+
+func TestBlocklist_FailsOnConcurrentUpdate(t *testing.T) {
+	blocklist, mock := newTestBlocklist(nil)
+	mock.Concurrent = true
+
+	if err := blocklist.AddRule(context.TODO(), BlockRule{Kind: BlockExact, Value: "bad"}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
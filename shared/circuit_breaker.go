@@ -0,0 +1,206 @@
+package shared
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+type CircuitOpenError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("riot api circuit is open (%s), retry after %s", e.Reason, e.RetryAfter)
+}
+
+type CircuitHealth struct {
+	State     CircuitState `json:"state"`
+	Reason    string       `json:"reason,omitempty"`
+	OpenedAt  int64        `json:"openedAt,omitempty"`
+	Requests  int          `json:"requests"`
+	Failures  int          `json:"failures"`
+	ErrorRate float64      `json:"errorRate"`
+}
+
+type circuitOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+type CircuitBreaker struct {
+	mu             sync.Mutex
+	window         time.Duration
+	minRequests    int
+	errorThreshold float64
+	openDuration   time.Duration
+	state          CircuitState
+	reason         string
+	openedAt       time.Time
+	probeInFlight  bool
+	outcomes       []circuitOutcome
+	now            func() time.Time
+}
+
+// NewCircuitBreaker opens once at least minRequests calls were made within window and the share of
+// failures among them reaches errorThreshold. While open, a single probe is let through every openDuration.
+func NewCircuitBreaker(window time.Duration, minRequests int, errorThreshold float64, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		window:         window,
+		minRequests:    minRequests,
+		errorThreshold: errorThreshold,
+		openDuration:   openDuration,
+		state:          CircuitClosed,
+		now:            time.Now,
+	}
+}
+
+func (c *CircuitBreaker) Allow() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == CircuitClosed {
+		return nil
+	}
+
+	elapsed := c.now().Sub(c.openedAt)
+	if c.state == CircuitOpen && elapsed >= c.openDuration {
+		c.state = CircuitHalfOpen
+	}
+
+	if c.state == CircuitHalfOpen && !c.probeInFlight {
+		c.probeInFlight = true
+		return nil
+	}
+
+	retryAfter := c.openDuration - elapsed
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+
+	return &CircuitOpenError{Reason: c.reason, RetryAfter: retryAfter}
+}
+
+// Release gives back the probe let through by Allow when the request was not made after all, so the next
+// caller can probe instead.
+func (c *CircuitBreaker) Release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == CircuitHalfOpen {
+		c.probeInFlight = false
+	}
+}
+
+func (c *CircuitBreaker) RecordSuccess() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == CircuitHalfOpen {
+		c.state = CircuitClosed
+		c.reason = ""
+		c.probeInFlight = false
+		c.outcomes = nil
+		return
+	}
+
+	c.record(false)
+}
+
+func (c *CircuitBreaker) RecordFailure() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == CircuitHalfOpen {
+		c.open(c.reason)
+		return
+	}
+
+	c.record(true)
+
+	requests, failures := c.counts()
+	if requests >= c.minRequests && float64(failures)/float64(requests) >= c.errorThreshold {
+		c.open(fmt.Sprintf("%d of %d requests failed within %s", failures, requests, c.window))
+	}
+}
+
+// Trip opens the circuit immediately, regardless of the current error rate.
+func (c *CircuitBreaker) Trip(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.open(reason)
+}
+
+func (c *CircuitBreaker) Health() CircuitHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune()
+	requests, failures := c.counts()
+
+	health := CircuitHealth{
+		State:    c.state,
+		Reason:   c.reason,
+		Requests: requests,
+		Failures: failures,
+	}
+
+	if c.state == CircuitOpen && c.now().Sub(c.openedAt) >= c.openDuration {
+		health.State = CircuitHalfOpen
+	}
+
+	if c.state != CircuitClosed {
+		health.OpenedAt = c.openedAt.UnixMilli()
+	}
+
+	if requests > 0 {
+		health.ErrorRate = float64(failures) / float64(requests)
+	}
+
+	return health
+}
+
+func (c *CircuitBreaker) open(reason string) {
+	c.state = CircuitOpen
+	c.reason = reason
+	c.openedAt = c.now()
+	c.probeInFlight = false
+	c.outcomes = nil
+}
+
+func (c *CircuitBreaker) record(failed bool) {
+	c.outcomes = append(c.outcomes, circuitOutcome{at: c.now(), failed: failed})
+	c.prune()
+}
+
+func (c *CircuitBreaker) prune() {
+	cutoff := c.now().Add(-c.window)
+
+	i := 0
+	for i < len(c.outcomes) && c.outcomes[i].at.Before(cutoff) {
+		i++
+	}
+
+	c.outcomes = c.outcomes[i:]
+}
+
+func (c *CircuitBreaker) counts() (int, int) {
+	failures := 0
+	for _, o := range c.outcomes {
+		if o.failed {
+			failures++
+		}
+	}
+
+	return len(c.outcomes), failures
+}
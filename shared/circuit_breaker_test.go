@@ -0,0 +1,198 @@
+package shared
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	current time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.current
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.current = f.current.Add(d)
+}
+
+func newTestCircuitBreaker() (*CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	breaker := NewCircuitBreaker(time.Minute, 4, 0.5, 30*time.Second)
+	breaker.now = clock.Now
+	return breaker, clock
+}
+
+func TestCircuitBreaker_StartsClosed(t *testing.T) {
+	breaker, _ := newTestCircuitBreaker()
+
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if breaker.Health().State != CircuitClosed {
+		t.Errorf("expected %s, got %s", CircuitClosed, breaker.Health().State)
+	}
+}
+
+func TestCircuitBreaker_StaysClosedBelowMinRequests(t *testing.T) {
+	breaker, _ := newTestCircuitBreaker()
+
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_OpensWhenErrorRateExceedsThreshold(t *testing.T) {
+	breaker, _ := newTestCircuitBreaker()
+
+	breaker.RecordSuccess()
+	breaker.RecordSuccess()
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+
+	err := breaker.Allow()
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("expected CircuitOpenError, got %v", err)
+	}
+
+	if openErr.RetryAfter != 30*time.Second {
+		t.Errorf("expected 30s, got %s", openErr.RetryAfter)
+	}
+
+	if breaker.Health().State != CircuitOpen {
+		t.Errorf("expected %s, got %s", CircuitOpen, breaker.Health().State)
+	}
+}
+
+func TestCircuitBreaker_IgnoresOutcomesOutsideWindow(t *testing.T) {
+	breaker, clock := newTestCircuitBreaker()
+
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	breaker.RecordFailure()
+	clock.Advance(2 * time.Minute)
+	breaker.RecordFailure()
+
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if breaker.Health().Requests != 1 {
+		t.Errorf("expected 1, got %d", breaker.Health().Requests)
+	}
+}
+
+func TestCircuitBreaker_TripOpensImmediately(t *testing.T) {
+	breaker, _ := newTestCircuitBreaker()
+
+	breaker.Trip("unauthorized")
+
+	err := breaker.Allow()
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if breaker.Health().Reason != "unauthorized" {
+		t.Errorf("expected unauthorized, got %s", breaker.Health().Reason)
+	}
+}
+
+func TestCircuitBreaker_AllowsSingleProbeAfterOpenDuration(t *testing.T) {
+	breaker, clock := newTestCircuitBreaker()
+
+	breaker.Trip("unauthorized")
+	clock.Advance(30 * time.Second)
+
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("expected probe to be allowed, got %v", err)
+	}
+
+	if err := breaker.Allow(); err == nil {
+		t.Errorf("expected second request to fail fast while probing")
+	}
+}
+
+func TestCircuitBreaker_ClosesWhenProbeSucceeds(t *testing.T) {
+	breaker, clock := newTestCircuitBreaker()
+
+	breaker.Trip("unauthorized")
+	clock.Advance(30 * time.Second)
+	_ = breaker.Allow()
+	breaker.RecordSuccess()
+
+	if breaker.Health().State != CircuitClosed {
+		t.Errorf("expected %s, got %s", CircuitClosed, breaker.Health().State)
+	}
+
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_ReopensWhenProbeFails(t *testing.T) {
+	breaker, clock := newTestCircuitBreaker()
+
+	breaker.Trip("unauthorized")
+	clock.Advance(30 * time.Second)
+	_ = breaker.Allow()
+	breaker.RecordFailure()
+
+	if breaker.Health().State != CircuitOpen {
+		t.Errorf("expected %s, got %s", CircuitOpen, breaker.Health().State)
+	}
+
+	if err := breaker.Allow(); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	clock.Advance(30 * time.Second)
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("expected another probe to be allowed, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_HealthReportsErrorRate(t *testing.T) {
+	breaker, _ := newTestCircuitBreaker()
+
+	breaker.RecordSuccess()
+	breaker.RecordSuccess()
+	breaker.RecordSuccess()
+	breaker.RecordFailure()
+
+	health := breaker.Health()
+	if health.Requests != 4 {
+		t.Errorf("expected 4, got %d", health.Requests)
+	}
+
+	if health.Failures != 1 {
+		t.Errorf("expected 1, got %d", health.Failures)
+	}
+
+	if health.ErrorRate != 0.25 {
+		t.Errorf("expected 0.25, got %f", health.ErrorRate)
+	}
+}
+
+func TestCircuitBreaker_ReleaseLetsAnotherProbeThrough(t *testing.T) {
+	breaker, clock := newTestCircuitBreaker()
+	breaker.Trip("test")
+	clock.Advance(30 * time.Second)
+
+	_ = breaker.Allow()
+	if err := breaker.Allow(); err == nil {
+		t.Fatalf("expected a second probe to be rejected")
+	}
+
+	breaker.Release()
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
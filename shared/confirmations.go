@@ -0,0 +1,70 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// confirmationTtl is how long a subscription waits for its contact to confirm it before it is dropped.
+const confirmationTtl = 48 * time.Hour
+
+type subscribeLimiter interface {
+	Take(ctx context.Context, key string) error
+}
+
+// Confirmations emails contacts a link confirming their subscription, so alerts only reach addresses whose
+// owner asked for them. Subscribes are rate limited per contact, which also bounds how many confirmations
+// one address is sent.
+type Confirmations struct {
+	mailer  mailerService
+	limiter subscribeLimiter
+}
+
+func NewConfirmations(mailer mailerService, limiter subscribeLimiter) *Confirmations {
+	return &Confirmations{mailer: mailer, limiter: limiter}
+}
+
+// NewConfirmationsFromEnv sends confirmations through the mailer SMTPMailerFromEnv configures, and allows
+// each contact SUBSCRIBE_RATE_LIMIT subscribes an hour, 5 by default. Subscribes are counted in the table,
+// so the limit holds across Lambda instances.
+func NewConfirmationsFromEnv(ctx context.Context, client limiterDynamoDbService, tableName string, secrets SecretsSource) (*Confirmations, error) {
+	mailer, err := SMTPMailerFromEnv(ctx, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := 5
+	if limitStr := os.Getenv("SUBSCRIBE_RATE_LIMIT"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid SUBSCRIBE_RATE_LIMIT '%s'", limitStr)
+		}
+	}
+
+	limiter := NewTokenBucketLimiter(NewDynamoDBLimiterStore(client, tableName), limit, time.Hour)
+	return NewConfirmations(mailer, limiter), nil
+}
+
+// Allow takes one of a contact's subscribes, returning a RateLimitedError once they are used up.
+func (c *Confirmations) Allow(ctx context.Context, contact string) error {
+	return c.limiter.Take(ctx, "subscribe#"+subscriptionId(contact))
+}
+
+// Send emails a contact the link to confirmUrl, with query as its parameters, that confirms their
+// subscription to the alerts what describes.
+func (c *Confirmations) Send(ctx context.Context, contact string, what string, confirmUrl string, query url.Values) error {
+	body := fmt.Sprintf("Someone asked for alerts about %s to be sent to this address. To start receiving them, "+
+		"confirm within %d hours by visiting %s?%s\n\nIf this was not you, ignore this email and no alerts will be "+
+		"sent.\n", what, int(confirmationTtl.Hours()), confirmUrl, query.Encode())
+
+	return c.mailer.Send(ctx, contact, "Confirm your alerts", body)
+}
+
+// confirmationExpired reports whether a subscription created at createdAt was left unconfirmed for too long.
+func confirmationExpired(createdAt int64, now time.Time) bool {
+	return now.Sub(time.UnixMilli(createdAt)) > confirmationTtl
+}
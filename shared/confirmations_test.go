@@ -0,0 +1,66 @@
+package shared
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type SubscribeLimiterMock struct {
+	Limited bool
+	Keys    []string
+}
+
+func (l *SubscribeLimiterMock) Take(_ context.Context, key string) error {
+	l.Keys = append(l.Keys, key)
+	if l.Limited {
+		return &RateLimitedError{Key: key, RetryAfter: time.Minute}
+	}
+
+	return nil
+}
+
+func newTestConfirmations() (*Confirmations, *MailerMock, *SubscribeLimiterMock) {
+	mailer, limiter := &MailerMock{}, &SubscribeLimiterMock{}
+	return NewConfirmations(mailer, limiter), mailer, limiter
+}
+
+// subscribeConfirmed subscribes a contact to a name and confirms it as the contact would.
+func subscribeConfirmed(watchlist *Watchlist, region string, name string, contact string) (*WatchSubscription, error) {
+	subscription, err := watchlist.Subscribe(context.TODO(), region, name, contact)
+	if err != nil {
+		return nil, err
+	}
+
+	return subscription, watchlist.Confirm(context.TODO(), region, name, contact, subscription.Token)
+}
+
+func TestConfirmations_RateLimitsPerContact(t *testing.T) {
+	confirmations, _, limiter := newTestConfirmations()
+
+	_ = confirmations.Allow(context.TODO(), "Player@Example.com")
+	_ = confirmations.Allow(context.TODO(), " player@example.com")
+
+	if len(limiter.Keys) != 2 || limiter.Keys[0] != limiter.Keys[1] || !strings.HasPrefix(limiter.Keys[0], "subscribe#") {
+		t.Errorf("expected both subscribes to count against one contact, got %v", limiter.Keys)
+	}
+
+	if strings.Contains(limiter.Keys[0], "example") {
+		t.Errorf("expected the contact to be hashed, got %s", limiter.Keys[0])
+	}
+}
+
+func TestConfirmations_SendsLinkWithQuery(t *testing.T) {
+	confirmations, mailer, _ := newTestConfirmations()
+
+	err := confirmations.Send(context.TODO(), "player@example.com", "the summoner name Test in NA", "https://names.lol/confirm", url.Values{"token": {"secret"}})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(mailer.Bodies) != 1 || !strings.Contains(mailer.Bodies[0], "https://names.lol/confirm?token=secret") {
+		t.Errorf("expected the confirmation link to be sent, got %v", mailer.Bodies)
+	}
+}
@@ -0,0 +1,77 @@
+package shared
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"strings"
+)
+
+// confusables maps characters to the Latin prototype they are mistaken for, after Unicode TR39's
+// confusables data. It covers the Cyrillic, Greek, IPA and small capital letters, digits and symbols
+// that look like the Latin letters names are mostly written in. Fullwidth and other compatibility forms
+// are decomposed before the mapping applies.
+var confusables = map[rune]string{
+	// Cyrillic
+	'а': "a", 'в': "b", 'ԁ': "d", 'е': "e", 'ё': "ë", 'һ': "h", 'і': "i", 'ї': "ï", 'ј': "j",
+	'к': "k", 'ӏ': "l", 'м': "rn", 'н': "h", 'о': "o", 'р': "p", 'ԛ': "q", 'г': "r", 'ѕ': "s", 'т': "t",
+	'ц': "u", 'ѵ': "v", 'ԝ': "w", 'х': "x", 'у': "y", 'ү': "y", 'з': "3", 'ь': "b", 'ч': "4", 'ш': "w",
+	// Greek
+	'α': "a", 'β': "b", 'δ': "d", 'ε': "e", 'η': "n", 'ι': "i", 'κ': "k", 'μ': "u", 'ν': "v", 'ο': "o",
+	'ρ': "p", 'σ': "o", 'τ': "t", 'υ': "u", 'χ': "x", 'γ': "y", 'ω': "w",
+	// IPA, Latin extensions and small capitals
+	'ɑ': "a", 'ɓ': "b", 'ƅ': "b", 'ɗ': "d", 'ɛ': "e", 'ɡ': "g", 'ɦ': "h", 'ı': "i", 'ɩ': "i", 'ɪ': "i",
+	'ȷ': "j", 'ĸ': "k", 'ʟ': "l", 'ł': "l", 'ɴ': "n", 'ɵ': "o", 'ø': "o", 'ʀ': "r", 'ꜱ': "s", 'ſ': "f",
+	'ᴄ': "c", 'ᴅ': "d", 'ᴇ': "e", 'ᴊ': "j", 'ᴋ': "k", 'ᴍ': "rn", 'ᴏ': "o", 'ᴘ': "p", 'ᴛ': "t", 'ᴜ': "u",
+	'ᴠ': "v", 'ᴡ': "w", 'ᴢ': "z", 'ʏ': "y", 'ʜ': "h", 'ʙ': "b", 'ɢ': "g",
+	// Digits and symbols
+	'0': "o", '1': "l", '|': "l", 'ǀ': "l", '$': "s", '@': "a",
+	// Latin letters TR39 treats as sequences
+	'm': "rn",
+}
+
+// Skeleton maps a name to its TR39 style skeleton: the normalized name decomposed, with each character
+// replaced by the prototype it is confused with. Names that look alike, such as "Faker" and "Fɑker",
+// share a skeleton.
+func Skeleton(name string) string {
+	decomposed := norm.NFKD.String(NormalizeName(name))
+
+	var skeleton strings.Builder
+	for _, r := range decomposed {
+		if prototype, ok := confusables[r]; ok {
+			skeleton.WriteString(prototype)
+		} else {
+			skeleton.WriteRune(r)
+		}
+	}
+
+	return norm.NFD.String(strings.ToLower(skeleton.String()))
+}
+
+// EditDistance counts the insertions, deletions, substitutions and transpositions of adjacent
+// characters that turn a into b.
+func EditDistance(a string, b string) int {
+	x, y := []rune(a), []rune(b)
+	rows := make([][]int, len(x)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(y)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(x); i++ {
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && x[i-1] == y[j-2] && x[i-2] == y[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return rows[len(x)][len(y)]
+}
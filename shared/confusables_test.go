@@ -0,0 +1,42 @@
+package shared
+
+import "testing"
+
+func TestSkeleton_MapsLookAlikesToTheSameSkeleton(t *testing.T) {
+	names := []string{"Faker", "FaKer", "Fɑker", "Fаkеr", "ＦＡＫＥＲ", "Fa ker"}
+	for _, name := range names {
+		if skeleton := Skeleton(name); skeleton != "faker" {
+			t.Errorf("%s: expected faker, got %s", name, skeleton)
+		}
+	}
+
+	if Skeleton("Doublelift") != Skeleton("Doub1e|ift") || Skeleton("rnoon") != Skeleton("moon") {
+		t.Errorf("expected l, 1 and |, and rn and m, to be confused")
+	}
+
+	if Skeleton("Faker") == Skeleton("Faked") {
+		t.Errorf("expected different names to have different skeletons")
+	}
+}
+
+func TestEditDistance_CountsEditsAndTranspositions(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		distance int
+	}{
+		{"faker", "faker", 0},
+		{"faker", "fakerr", 1},
+		{"faker", "faer", 1},
+		{"faker", "fakex", 1},
+		{"faker", "fakre", 1},
+		{"faker", "xakre", 2},
+		{"", "abc", 3},
+		{"привет", "привёт", 1},
+	}
+
+	for _, c := range cases {
+		if distance := EditDistance(c.a, c.b); distance != c.distance {
+			t.Errorf("%s, %s: expected %d, got %d", c.a, c.b, c.distance, distance)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package shared
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	crawlFrontierPrefix = "crawl#frontier#"
+	crawlSeenPrefix     = "crawl#seen#"
+
+	// maxFrontierSize bounds the frontier item well below DynamoDB's 400KB item limit.
+	maxFrontierSize = 2000
+
+	// crawlSeenTTL is how long a visited player or match is skipped before it can be crawled again.
+	crawlSeenTTL = 30 * 24 * time.Hour
+
+	// crawlSeedLimit is how many summoners seed an empty frontier.
+	crawlSeedLimit = 100
+)
+
+type crawlerDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+type matchService interface {
+	FetchMatchIds(region string, puuid string, count int) ([]string, error)
+	FetchMatch(region string, matchId string) (*RiotMatchDTO, error)
+}
+
+type crawlSeedService interface {
+	GetBetweenDate(region string, limit int32, t1 int64, t2 int64) (*SummonersPage, error)
+}
+
+type crawlQueueService interface {
+	Send(ctx context.Context, region string, name string) error
+}
+
+// CrawlLimits bounds a crawl of one region. Players MaxDepth or more hops from a seed are enqueued but
+// their match histories are not crawled, and a single run fetches at most MaxMatches matches.
+type CrawlLimits struct {
+	MaxDepth         int `json:"maxDepth"`
+	MaxMatches       int `json:"maxMatches"`
+	MatchesPerPlayer int `json:"matchesPerPlayer"`
+}
+
+type CrawlEntry struct {
+	Puuid string `dynamodbav:"p"`
+	Depth int    `dynamodbav:"d"`
+}
+
+type crawlFrontierItem struct {
+	Key       string       `dynamodbav:"n"`
+	Entries   []CrawlEntry `dynamodbav:"q"`
+	UpdatedAt int64        `dynamodbav:"ua"`
+}
+
+func (i *crawlFrontierItem) updatedAt() *int64 {
+	return &i.UpdatedAt
+}
+
+type CrawlResult struct {
+	Region    string `json:"region"`
+	Seeded    int    `json:"seeded"`
+	Players   int    `json:"players"`
+	Matches   int    `json:"matches"`
+	Enqueued  int    `json:"enqueued"`
+	Frontier  int    `json:"frontier"`
+	Throttled bool   `json:"throttled"`
+}
+
+// Crawler discovers names by walking the match histories of known players. Each region keeps a
+// breadth-first frontier of players to crawl, and players and matches already visited are recorded so
+// they are not crawled or enqueued twice.
+type Crawler struct {
+	dynamodb  crawlerDynamoDbService
+	matches   matchService
+	seeds     crawlSeedService
+	queue     crawlQueueService
+	regions   regionsService
+	tableName string
+	now       func() time.Time
+}
+
+func NewCrawler(client crawlerDynamoDbService, tableName string, matches matchService, seeds crawlSeedService, queue crawlQueueService) *Crawler {
+	return &Crawler{
+		dynamodb:  client,
+		matches:   matches,
+		seeds:     seeds,
+		queue:     queue,
+		regions:   NewRegions(),
+		tableName: tableName,
+		now:       time.Now,
+	}
+}
+
+// Crawl runs one bounded crawl of a region. When the Riot API budget runs out the crawl stops early
+// without an error, and the frontier is saved so the next run carries on where this one stopped.
+func (c *Crawler) Crawl(ctx context.Context, region string, limits CrawlLimits) (*CrawlResult, error) {
+	platform, err := c.regions.Get(region)
+	if err != nil {
+		return nil, err
+	}
+
+	frontier, err := c.loadFrontier(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := frontier.UpdatedAt
+	result := &CrawlResult{Region: region}
+
+	if len(frontier.Entries) == 0 {
+		if err = c.seed(ctx, region, frontier, result); err != nil {
+			return result, err
+		}
+	}
+
+	var crawlErr error
+	for len(frontier.Entries) > 0 && result.Matches < limits.MaxMatches {
+		entry := frontier.Entries[0]
+		frontier.Entries = frontier.Entries[1:]
+
+		finished, err := c.crawlPlayer(ctx, region, platform, entry, limits, frontier, result)
+		if !finished || err != nil {
+			frontier.Entries = append([]CrawlEntry{entry}, frontier.Entries...)
+		}
+
+		if IsThrottled(err) || isCircuitOpen(err) {
+			result.Throttled = true
+			break
+		}
+
+		if err != nil {
+			crawlErr = err
+			break
+		}
+
+		if finished {
+			result.Players++
+		}
+	}
+
+	result.Frontier = len(frontier.Entries)
+	if err = putSingleItem(ctx, c.dynamodb, c.tableName, frontier, previous, c.now()); err != nil {
+		return result, err
+	}
+
+	return result, crawlErr
+}
+
+// crawlPlayer fetches a player's recent matches, enqueueing the participants not seen before. It returns
+// false if the run's match limit was reached before every match was crawled.
+func (c *Crawler) crawlPlayer(ctx context.Context, region string, platform string, entry CrawlEntry, limits CrawlLimits, frontier *crawlFrontierItem, result *CrawlResult) (bool, error) {
+	ids, err := c.matches.FetchMatchIds(region, entry.Puuid, limits.MatchesPerPlayer)
+	if err != nil {
+		if err.Error() == "not found" {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	for _, id := range ids {
+		matchKey := crawlSeenKey("match", id)
+
+		seen, err := c.seen(ctx, matchKey)
+		if err != nil {
+			return false, err
+		}
+
+		if seen {
+			continue
+		}
+
+		if result.Matches >= limits.MaxMatches {
+			return false, nil
+		}
+
+		match, err := c.matches.FetchMatch(region, id)
+		if err != nil && err.Error() != "not found" {
+			return false, err
+		}
+
+		result.Matches++
+		if _, err = c.markSeen(ctx, matchKey); err != nil {
+			return false, err
+		}
+
+		// Regional routes serve every platform in the region, so only follow matches played on this one.
+		if match == nil || !strings.EqualFold(match.Info.PlatformId, platform) {
+			continue
+		}
+
+		for _, participant := range match.Info.Participants {
+			if participant.Puuid == "" || participant.Puuid == entry.Puuid || participant.SummonerName == "" {
+				continue
+			}
+
+			playerKey := crawlSeenKey("player", participant.Puuid)
+			seen, err := c.seen(ctx, playerKey)
+			if err != nil {
+				return false, err
+			}
+
+			if seen {
+				continue
+			}
+
+			// The player is only marked seen once enqueued, so a failed send is retried on the next visit.
+			if err = c.queue.Send(ctx, region, participant.SummonerName); err != nil {
+				return false, err
+			}
+
+			result.Enqueued++
+
+			first, err := c.markSeen(ctx, playerKey)
+			if err != nil {
+				return false, err
+			}
+
+			if !first {
+				continue
+			}
+
+			if entry.Depth+1 < limits.MaxDepth && len(frontier.Entries) < maxFrontierSize {
+				frontier.Entries = append(frontier.Entries, CrawlEntry{Puuid: participant.Puuid, Depth: entry.Depth + 1})
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// seed starts an empty frontier from summoners whose names become available within the next 30 days,
+// skipping players crawled recently.
+func (c *Crawler) seed(ctx context.Context, region string, frontier *crawlFrontierItem, result *CrawlResult) error {
+	now := c.now()
+	page, err := c.seeds.GetBetweenDate(region, crawlSeedLimit, now.UnixMilli(), now.Add(30*24*time.Hour).UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	for _, summoner := range page.Summoners {
+		if summoner.Puuid == "" {
+			continue
+		}
+
+		first, err := c.markSeen(ctx, crawlSeenKey("player", summoner.Puuid))
+		if err != nil {
+			return err
+		}
+
+		if first {
+			frontier.Entries = append(frontier.Entries, CrawlEntry{Puuid: summoner.Puuid})
+			result.Seeded++
+		}
+	}
+
+	return nil
+}
+
+// crawlSeenKey hashes the id, so the crawl state holds no PUUIDs after they expire from the frontier.
+func crawlSeenKey(kind string, id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return crawlSeenPrefix + kind + "#" + hex.EncodeToString(sum[:])
+}
+
+func (c *Crawler) seen(ctx context.Context, key string) (bool, error) {
+	output, err := c.dynamodb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(c.tableName),
+		Key:       map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: key}},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if output.Item == nil {
+		return false, nil
+	}
+
+	// DynamoDB deletes expired items some time after they expire, so visits are checked for expiry too.
+	var visit struct {
+		TTL int64 `dynamodbav:"ttl"`
+	}
+	if err = attributevalue.UnmarshalMap(output.Item, &visit); err != nil {
+		return false, err
+	}
+
+	return visit.TTL >= c.now().Unix(), nil
+}
+
+// markSeen records a visit, returning false if an unexpired one had already been recorded.
+func (c *Crawler) markSeen(ctx context.Context, key string) (bool, error) {
+	now := c.now()
+	_, err := c.dynamodb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(c.tableName),
+		Item: map[string]types.AttributeValue{
+			"n":   &types.AttributeValueMemberS{Value: key},
+			"ttl": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(crawlSeenTTL).Unix(), 10)},
+		},
+		ConditionExpression:      aws.String("attribute_not_exists(n) OR #ttl < :now"),
+		ExpressionAttributeNames: map[string]string{"#ttl": "ttl"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (c *Crawler) loadFrontier(ctx context.Context, region string) (*crawlFrontierItem, error) {
+	frontier := &crawlFrontierItem{Key: crawlFrontierPrefix + region}
+	return frontier, getSingleItem(ctx, c.dynamodb, c.tableName, frontier.Key, frontier)
+}
+
+func isCircuitOpen(err error) bool {
+	var circuitErr *CircuitOpenError
+	return errors.As(err, &circuitErr)
+}
+
+// CrawlLimitsFromEnv reads the default limits from CRAWL_MAX_DEPTH, CRAWL_MAX_MATCHES and
+// CRAWL_MATCHES_PER_PLAYER, then applies CRAWL_REGION_LIMITS overrides written as
+// "REGION=depth:matches,...". A region limited to 0 matches is not crawled.
+func CrawlLimitsFromEnv(regions []string) (map[string]CrawlLimits, error) {
+	defaults := CrawlLimits{MaxDepth: 2, MaxMatches: 50, MatchesPerPlayer: 5}
+
+	for name, value := range map[string]*int{
+		"CRAWL_MAX_DEPTH":          &defaults.MaxDepth,
+		"CRAWL_MAX_MATCHES":        &defaults.MaxMatches,
+		"CRAWL_MATCHES_PER_PLAYER": &defaults.MatchesPerPlayer,
+	} {
+		if str := os.Getenv(name); str != "" {
+			parsed, err := strconv.Atoi(str)
+			if err != nil || parsed < 0 {
+				return nil, fmt.Errorf("invalid %s '%s'", name, str)
+			}
+			*value = parsed
+		}
+	}
+
+	limits := make(map[string]CrawlLimits, len(regions))
+	for _, region := range regions {
+		limits[region] = defaults
+	}
+
+	for _, override := range splitList(os.Getenv("CRAWL_REGION_LIMITS")) {
+		region, value, _ := strings.Cut(override, "=")
+		region = strings.ToUpper(strings.TrimSpace(region))
+
+		regionLimits, ok := limits[region]
+		if !ok {
+			return nil, fmt.Errorf("invalid CRAWL_REGION_LIMITS region '%s'", region)
+		}
+
+		depth, matches, ok := strings.Cut(value, ":")
+		var depthErr, matchesErr error
+		regionLimits.MaxDepth, depthErr = strconv.Atoi(depth)
+		regionLimits.MaxMatches, matchesErr = strconv.Atoi(matches)
+		if !ok || depthErr != nil || matchesErr != nil || regionLimits.MaxDepth < 0 || regionLimits.MaxMatches < 0 {
+			return nil, fmt.Errorf("invalid CRAWL_REGION_LIMITS entry '%s', expected REGION=depth:matches", override)
+		}
+
+		limits[region] = regionLimits
+	}
+
+	return limits, nil
+}
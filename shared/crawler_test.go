@@ -0,0 +1,253 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"testing"
+	"time"
+)
+
+type CrawlerDynamoDBServiceMock struct {
+	Items map[string]map[string]types.AttributeValue
+}
+
+func (c *CrawlerDynamoDBServiceMock) GetItem(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: c.Items[input.Key["n"].(*types.AttributeValueMemberS).Value]}, nil
+}
+
+func (c *CrawlerDynamoDBServiceMock) PutItem(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	key := input.Item["n"].(*types.AttributeValueMemberS).Value
+	if existing, ok := c.Items[key]; ok && *input.ConditionExpression == "attribute_not_exists(n) OR #ttl < :now" {
+		if numberValue(existing["ttl"]) >= numberValue(input.ExpressionAttributeValues[":now"]) {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	}
+
+	c.Items[key] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// MatchServiceMock serves match histories where every match of a player is shared with the players in
+// Opponents, forming a chain a -> b -> c -> d.
+type MatchServiceMock struct {
+	Opponents       map[string][]string
+	Unnamed         string
+	ThrottleAfter   int
+	FetchMatchCalls int
+}
+
+func (m *MatchServiceMock) FetchMatchIds(_ string, puuid string, count int) ([]string, error) {
+	ids := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		ids = append(ids, fmt.Sprintf("NA1_%s%d", puuid, i))
+	}
+
+	return ids, nil
+}
+
+func (m *MatchServiceMock) FetchMatch(_ string, matchId string) (*RiotMatchDTO, error) {
+	m.FetchMatchCalls++
+	if m.ThrottleAfter > 0 && m.FetchMatchCalls > m.ThrottleAfter {
+		return nil, &BudgetExhaustedError{Workload: WorkloadBackground}
+	}
+
+	puuid := matchId[4 : len(matchId)-1]
+	match := &RiotMatchDTO{Info: RiotMatchInfoDTO{PlatformId: "NA1", Participants: []RiotParticipantDTO{{Puuid: puuid, SummonerName: puuid}}}}
+	for _, opponent := range m.Opponents[puuid] {
+		participant := RiotParticipantDTO{Puuid: opponent, SummonerName: "name-" + opponent}
+		if opponent == m.Unnamed {
+			participant.SummonerName = ""
+		}
+
+		match.Info.Participants = append(match.Info.Participants, participant)
+	}
+
+	return match, nil
+}
+
+type CrawlSeedServiceMock struct {
+	Puuids []string
+}
+
+func (c *CrawlSeedServiceMock) GetBetweenDate(region string, _ int32, _ int64, _ int64) (*SummonersPage, error) {
+	page := &SummonersPage{}
+	for _, puuid := range c.Puuids {
+		page.Summoners = append(page.Summoners, &SummonerDTO{Name: puuid, Region: region, Puuid: puuid})
+	}
+
+	return page, nil
+}
+
+type CrawlQueueServiceMock struct {
+	ShouldFail bool
+	Sent       []string
+}
+
+func (c *CrawlQueueServiceMock) Send(_ context.Context, region string, name string) error {
+	if c.ShouldFail {
+		return fmt.Errorf("error")
+	}
+
+	c.Sent = append(c.Sent, region+"#"+name)
+	return nil
+}
+
+func newTestCrawler(matches *MatchServiceMock, seeds ...string) (*Crawler, *CrawlQueueServiceMock) {
+	queue := &CrawlQueueServiceMock{}
+	crawler := NewCrawler(&CrawlerDynamoDBServiceMock{Items: make(map[string]map[string]types.AttributeValue)}, "test-table", matches, &CrawlSeedServiceMock{Puuids: seeds}, queue)
+	crawler.now = func() time.Time { return time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC) }
+	return crawler, queue
+}
+
+func chain() map[string][]string {
+	return map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"d"}}
+}
+
+func TestCrawler_EnqueuesParticipantsUpToMaxDepth(t *testing.T) {
+	crawler, queue := newTestCrawler(&MatchServiceMock{Opponents: chain()}, "a")
+
+	result, err := crawler.Crawl(context.TODO(), "NA", CrawlLimits{MaxDepth: 2, MaxMatches: 100, MatchesPerPlayer: 2})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	// a is a seed at depth 0 and b is at depth 1, so both are crawled; c is only enqueued.
+	if len(queue.Sent) != 2 || queue.Sent[0] != "NA#name-b" || queue.Sent[1] != "NA#name-c" {
+		t.Errorf("expected b and c to be enqueued once each, got %v", queue.Sent)
+	}
+
+	if result.Seeded != 1 || result.Players != 2 || result.Matches != 4 || result.Frontier != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestCrawler_StopsAtMaxMatchesAndResumesNextRun(t *testing.T) {
+	matches := &MatchServiceMock{Opponents: chain()}
+	crawler, queue := newTestCrawler(matches, "a")
+	limits := CrawlLimits{MaxDepth: 5, MaxMatches: 1, MatchesPerPlayer: 2}
+
+	result, err := crawler.Crawl(context.TODO(), "NA", limits)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if result.Matches != 1 || result.Players != 0 || result.Frontier != 2 {
+		t.Errorf("expected a to stay on the frontier after one match, got %+v", result)
+	}
+
+	if _, err = crawler.Crawl(context.TODO(), "NA", limits); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if matches.FetchMatchCalls != 2 || len(queue.Sent) != 1 {
+		t.Errorf("expected the second run to fetch only a's unseen match, got %d fetches and %v", matches.FetchMatchCalls, queue.Sent)
+	}
+}
+
+func TestCrawler_SavesFrontierWhenThrottled(t *testing.T) {
+	crawler, _ := newTestCrawler(&MatchServiceMock{Opponents: chain(), ThrottleAfter: 1}, "a")
+
+	result, err := crawler.Crawl(context.TODO(), "NA", CrawlLimits{MaxDepth: 5, MaxMatches: 100, MatchesPerPlayer: 2})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !result.Throttled || result.Frontier != 2 {
+		t.Errorf("expected a throttled run with a and b on the frontier, got %+v", result)
+	}
+
+	frontier, _ := crawler.loadFrontier(context.TODO(), "NA")
+	if len(frontier.Entries) != 2 || frontier.Entries[0].Puuid != "a" || frontier.Entries[1].Depth != 1 {
+		t.Errorf("unexpected saved frontier %+v", frontier.Entries)
+	}
+}
+
+func TestCrawler_SkipsMatchesFromOtherPlatforms(t *testing.T) {
+	crawler, queue := newTestCrawler(&MatchServiceMock{Opponents: chain()}, "a")
+
+	_, err := crawler.Crawl(context.TODO(), "LAS", CrawlLimits{MaxDepth: 2, MaxMatches: 100, MatchesPerPlayer: 1})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(queue.Sent) != 0 {
+		t.Errorf("expected NA1 participants not to be enqueued for LAS, got %v", queue.Sent)
+	}
+}
+
+func TestCrawler_SkipsParticipantsWithoutSummonerName(t *testing.T) {
+	crawler, queue := newTestCrawler(&MatchServiceMock{Opponents: chain(), Unnamed: "b"}, "a")
+
+	result, err := crawler.Crawl(context.TODO(), "NA", CrawlLimits{MaxDepth: 2, MaxMatches: 100, MatchesPerPlayer: 1})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(queue.Sent) != 0 || result.Players != 1 {
+		t.Errorf("expected b to be neither enqueued nor crawled, got %v and %+v", queue.Sent, result)
+	}
+}
+
+func TestCrawler_MarksPlayersSeenOnlyOnceEnqueued(t *testing.T) {
+	crawler, queue := newTestCrawler(&MatchServiceMock{Opponents: chain()}, "a")
+	queue.ShouldFail = true
+
+	if _, err := crawler.Crawl(context.TODO(), "NA", CrawlLimits{MaxDepth: 2, MaxMatches: 100, MatchesPerPlayer: 1}); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if seen, _ := crawler.seen(context.TODO(), crawlSeenKey("player", "b")); seen {
+		t.Errorf("expected b not to be marked seen when it could not be enqueued")
+	}
+}
+
+func TestCrawler_VisitsPlayersAgainOnceSeenExpires(t *testing.T) {
+	crawler, _ := newTestCrawler(&MatchServiceMock{}, "a")
+	key := crawlSeenKey("player", "b")
+
+	if first, _ := crawler.markSeen(context.TODO(), key); !first {
+		t.Fatalf("expected the first visit to be recorded")
+	}
+
+	if first, _ := crawler.markSeen(context.TODO(), key); first {
+		t.Errorf("expected a second visit within the TTL not to be recorded")
+	}
+
+	crawler.now = func() time.Time { return time.Date(2024, time.March, 14, 8, 0, 0, 0, time.UTC) }
+	if seen, _ := crawler.seen(context.TODO(), key); seen {
+		t.Errorf("expected an expired visit not to count as seen")
+	}
+
+	if first, _ := crawler.markSeen(context.TODO(), key); !first {
+		t.Errorf("expected a visit after the TTL to be recorded again")
+	}
+}
+
+func TestCrawlLimitsFromEnv_AppliesRegionOverrides(t *testing.T) {
+	t.Setenv("CRAWL_MAX_MATCHES", "20")
+	t.Setenv("CRAWL_REGION_LIMITS", "euw=3:200, OCE=0:0")
+
+	limits, err := CrawlLimitsFromEnv([]string{"NA", "EUW", "OCE"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if limits["NA"] != (CrawlLimits{MaxDepth: 2, MaxMatches: 20, MatchesPerPlayer: 5}) {
+		t.Errorf("unexpected NA limits %+v", limits["NA"])
+	}
+
+	if limits["EUW"].MaxDepth != 3 || limits["EUW"].MaxMatches != 200 || limits["OCE"].MaxMatches != 0 {
+		t.Errorf("unexpected overrides %+v", limits)
+	}
+}
+
+func TestCrawlLimitsFromEnv_RejectsInvalidOverrides(t *testing.T) {
+	for _, value := range []string{"NA=3", "BR=1:1", "NA=x:1"} {
+		t.Setenv("CRAWL_REGION_LIMITS", value)
+		if _, err := CrawlLimitsFromEnv([]string{"NA"}); err == nil {
+			t.Errorf("%s: expected error, got nil", value)
+		}
+	}
+}
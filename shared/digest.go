@@ -0,0 +1,593 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	htmltemplate "html/template"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// digestSubscribersKey holds every digest subscriber in a single item, like the webhook endpoints.
+const digestSubscribersKey = "digest#subscribers"
+
+// maxDigestSubscribers bounds the size of the subscribers item.
+const maxDigestSubscribers = 2000
+
+// digestEmailNames is how many of each region's best names an email lists. The published JSON lists all.
+const digestEmailNames = 25
+
+type digestDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+type digestSummonersService interface {
+	GetBetweenDate(region string, limit int32, t1 int64, t2 int64) (*SummonersPage, error)
+}
+
+type digestStorageService interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+type digestMailerService interface {
+	SendHTML(ctx context.Context, to string, subject string, text string, html string) error
+}
+
+// DigestSubscriber receives the daily digest for Regions, or for every region when it is empty, once they
+// confirm their subscription and it is no longer Pending. SentFor is the date of the last digest they were
+// sent, so a retried run does not send it twice.
+type DigestSubscriber struct {
+	Contact   string   `dynamodbav:"c" json:"contact"`
+	Regions   []string `dynamodbav:"rs,omitempty" json:"regions,omitempty"`
+	Token     string   `dynamodbav:"t" json:"-"`
+	CreatedAt int64    `dynamodbav:"ca" json:"createdAt"`
+	SentFor   string   `dynamodbav:"sf,omitempty" json:"-"`
+	Pending   bool     `dynamodbav:"pd,omitempty" json:"pending,omitempty"`
+}
+
+type digestSubscribersItem struct {
+	Key         string                       `dynamodbav:"n"`
+	Subscribers map[string]*DigestSubscriber `dynamodbav:"s"`
+	UpdatedAt   int64                        `dynamodbav:"ua"`
+}
+
+func (i *digestSubscribersItem) updatedAt() *int64 {
+	return &i.UpdatedAt
+}
+
+type DigestName struct {
+	Name             string `json:"name"`
+	Length           int    `json:"length"`
+	Score            int    `json:"score"`
+	Level            int    `json:"level"`
+	AvailabilityDate int64  `json:"availabilityDate"`
+}
+
+// RegionDigest lists every name in a region freeing within the digest's window, shortest and best first.
+type RegionDigest struct {
+	Region string       `json:"region"`
+	Total  int          `json:"total"`
+	Names  []DigestName `json:"names"`
+}
+
+type Digest struct {
+	Date        string          `json:"date"`
+	From        int64           `json:"from"`
+	To          int64           `json:"to"`
+	GeneratedAt int64           `json:"generatedAt"`
+	Regions     []*RegionDigest `json:"regions"`
+}
+
+type DigestResult struct {
+	Date        string `json:"date"`
+	Names       int    `json:"names"`
+	Key         string `json:"key"`
+	Subscribers int    `json:"subscribers"`
+	Sent        int    `json:"sent"`
+	Dropped     int    `json:"dropped"`
+}
+
+// Digests summarises the names freeing over the next day in each region, publishes the summary as JSON
+// and emails it to digest subscribers.
+type Digests struct {
+	dynamodb       digestDynamoDbService
+	summoners      digestSummonersService
+	storage        digestStorageService
+	mailer         digestMailerService
+	confirmations  *Confirmations
+	regions        *Regions
+	filter         func(summoner *SummonerDTO) bool
+	tableName      string
+	bucket         string
+	publicUrl      string
+	unsubscribeUrl string
+	confirmUrl     string
+	pageSize       int32
+	now            func() time.Time
+}
+
+// NewDigests publishes digests to the bucket and emails them through the mailer, which may be nil to
+// only publish them. Emails link to the published digest under publicUrl and to unsubscribeUrl, with the
+// subscriber's contact and token as query parameters, when those are set.
+func NewDigests(client digestDynamoDbService, tableName string, summoners digestSummonersService, storage digestStorageService, bucket string, mailer digestMailerService, publicUrl string, unsubscribeUrl string) *Digests {
+	return &Digests{
+		dynamodb:       client,
+		summoners:      summoners,
+		storage:        storage,
+		mailer:         mailer,
+		regions:        NewRegions(),
+		tableName:      tableName,
+		bucket:         bucket,
+		publicUrl:      strings.TrimSuffix(publicUrl, "/"),
+		unsubscribeUrl: unsubscribeUrl,
+		pageSize:       watchPageSize,
+		now:            time.Now,
+	}
+}
+
+// NewDigestsFromEnv reads the bucket from DIGEST_BUCKET, the links from DIGEST_URL and
+// DIGEST_UNSUBSCRIBE_URL, and emails digests when SMTP_ADDR is set, see SMTPMailerFromEnv.
+func NewDigestsFromEnv(ctx context.Context, client digestDynamoDbService, tableName string, summoners digestSummonersService, storage digestStorageService, secrets SecretsSource) (*Digests, error) {
+	bucket := os.Getenv("DIGEST_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("DIGEST_BUCKET is required")
+	}
+
+	digests := NewDigests(client, tableName, summoners, storage, bucket, nil, os.Getenv("DIGEST_URL"), os.Getenv("DIGEST_UNSUBSCRIBE_URL"))
+	if os.Getenv("SMTP_ADDR") != "" {
+		mailer, err := SMTPMailerFromEnv(ctx, secrets)
+		if err != nil {
+			return nil, err
+		}
+		digests.mailer = mailer
+	}
+
+	return digests, nil
+}
+
+// FilterNames leaves out of digests the names the filter rejects, in addition to hidden names.
+func (d *Digests) FilterNames(filter func(summoner *SummonerDTO) bool) {
+	d.filter = filter
+}
+
+// ConfirmWith rate limits subscribes and sends new subscribers a link to confirmUrl, with their contact
+// and token as query parameters, that confirms their subscription.
+func (d *Digests) ConfirmWith(confirmations *Confirmations, confirmUrl string) {
+	d.confirmations = confirmations
+	d.confirmUrl = confirmUrl
+}
+
+// Subscribe adds a pending contact to the digest for the given regions, or for every region when none are
+// given, and emails them a link to confirm it. Subscribing again updates the regions, and sends the link
+// again while the subscription is pending.
+func (d *Digests) Subscribe(ctx context.Context, contact string, regions []string) (*DigestSubscriber, error) {
+	for _, region := range regions {
+		if !d.regions.Validate(region) {
+			return nil, fmt.Errorf("invalid region '%s'", region)
+		}
+	}
+
+	if d.confirmations == nil {
+		return nil, errors.New("digest subscriptions need confirmations, see ConfirmWith")
+	}
+
+	if err := d.confirmations.Allow(ctx, contact); err != nil {
+		return nil, err
+	}
+
+	subscriber, err := d.subscribe(ctx, contact, regions)
+	if err != nil || !subscriber.Pending {
+		return subscriber, err
+	}
+
+	query := url.Values{"contact": {subscriber.Contact}, "token": {subscriber.Token}}
+	err = d.confirmations.Send(ctx, subscriber.Contact, "the names freeing each day", d.confirmUrl, query)
+	return subscriber, err
+}
+
+func (d *Digests) subscribe(ctx context.Context, contact string, regions []string) (*DigestSubscriber, error) {
+	var subscriber *DigestSubscriber
+	err := d.update(ctx, func(item *digestSubscribersItem) error {
+		id := subscriptionId(contact)
+		if existing, ok := item.Subscribers[id]; ok {
+			existing.Regions = regions
+			subscriber = existing
+			return nil
+		}
+
+		if len(item.Subscribers) >= maxDigestSubscribers {
+			return fmt.Errorf("digest is full")
+		}
+
+		token := make([]byte, 16)
+		if _, err := rand.Read(token); err != nil {
+			return err
+		}
+
+		subscriber = &DigestSubscriber{
+			Contact:   strings.TrimSpace(contact),
+			Regions:   regions,
+			Token:     hex.EncodeToString(token),
+			CreatedAt: d.now().UnixMilli(),
+			Pending:   true,
+		}
+		item.Subscribers[id] = subscriber
+		return nil
+	})
+
+	return subscriber, err
+}
+
+// Confirm activates a pending subscription, provided the token matches the one the contact was sent.
+func (d *Digests) Confirm(ctx context.Context, contact string, token string) error {
+	return d.update(ctx, func(item *digestSubscribersItem) error {
+		subscriber, ok := item.Subscribers[subscriptionId(contact)]
+		if !ok || token == "" || subscriber.Token != token {
+			return fmt.Errorf("subscription not found")
+		}
+
+		subscriber.Pending = false
+		return nil
+	})
+}
+
+// Unsubscribe removes a contact from the digest, provided the token matches the one the contact was sent.
+func (d *Digests) Unsubscribe(ctx context.Context, contact string, token string) error {
+	return d.update(ctx, func(item *digestSubscribersItem) error {
+		id := subscriptionId(contact)
+		if subscriber, ok := item.Subscribers[id]; !ok || token == "" || subscriber.Token != token {
+			return fmt.Errorf("subscription not found")
+		}
+
+		delete(item.Subscribers, id)
+		return nil
+	})
+}
+
+// Run builds the digest of the next 24 hours for every region, publishes it and sends it to subscribers
+// who have not been sent it yet.
+func (d *Digests) Run(ctx context.Context) (*DigestResult, error) {
+	digest, err := d.Build(d.regions.Sorted())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DigestResult{Date: digest.Date}
+	for _, region := range digest.Regions {
+		result.Names += region.Total
+	}
+
+	if result.Key, err = d.Publish(ctx, digest); err != nil {
+		return result, err
+	}
+
+	return result, d.Send(ctx, digest, result)
+}
+
+// Build lists the names freeing in each region over the next 24 hours.
+func (d *Digests) Build(regions []string) (*Digest, error) {
+	now := d.now()
+	digest := &Digest{
+		Date:        now.UTC().Format(time.DateOnly),
+		From:        now.UnixMilli(),
+		To:          now.Add(24 * time.Hour).UnixMilli(),
+		GeneratedAt: now.UnixMilli(),
+	}
+
+	for _, region := range regions {
+		names, err := d.collect(region, digest.From, digest.To)
+		if err != nil {
+			return nil, fmt.Errorf("could not collect names in region '%s': %v", region, err)
+		}
+
+		sort.Slice(names, func(i, j int) bool {
+			a, b := names[i], names[j]
+			if a.Length != b.Length {
+				return a.Length < b.Length
+			}
+			if a.Score != b.Score {
+				return a.Score > b.Score
+			}
+			if a.AvailabilityDate != b.AvailabilityDate {
+				return a.AvailabilityDate < b.AvailabilityDate
+			}
+			return a.Name < b.Name
+		})
+
+		digest.Regions = append(digest.Regions, &RegionDigest{Region: region, Total: len(names), Names: names})
+	}
+
+	return digest, nil
+}
+
+// collect pages through every name in a region freeing between from and to. Pages restart from their
+// last date, since more names may share it, and a full page sharing one date is read again with a larger
+// limit, so no name is left out however many free at once.
+func (d *Digests) collect(region string, from int64, to int64) ([]DigestName, error) {
+	names := make([]DigestName, 0)
+	seen := make(map[string]bool)
+
+	limit := d.pageSize
+	for from <= to {
+		page, err := d.summoners.GetBetweenDate(region, limit, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, summoner := range page.Summoners {
+			key := NameKey(region, summoner.Name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if summoner.Hidden || (d.filter != nil && !d.filter(summoner)) {
+				continue
+			}
+
+			names = append(names, DigestName{
+				Name:             summoner.Name,
+				Length:           NameLength(summoner.Name),
+				Score:            QualityScore(summoner.Name),
+				Level:            summoner.Level,
+				AvailabilityDate: summoner.AvailabilityDate,
+			})
+		}
+
+		if len(page.Summoners)+len(page.Skipped)+page.Filtered < int(limit) || len(page.Summoners) == 0 {
+			break
+		}
+
+		last := page.Summoners[len(page.Summoners)-1].AvailabilityDate
+		if last == page.Summoners[0].AvailabilityDate {
+			limit *= 2
+			continue
+		}
+
+		from, limit = last, d.pageSize
+	}
+
+	return names, nil
+}
+
+// Publish writes the digest as JSON under digests/<date>.json and digests/latest.json, returning the
+// dated key.
+func (d *Digests) Publish(ctx context.Context, digest *Digest) (string, error) {
+	body, err := json.Marshal(digest)
+	if err != nil {
+		return "", err
+	}
+
+	key := "digests/" + digest.Date + ".json"
+	for _, objectKey := range []string{key, "digests/latest.json"} {
+		_, err = d.storage.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:       aws.String(d.bucket),
+			Key:          aws.String(objectKey),
+			Body:         bytes.NewReader(body),
+			ContentType:  aws.String("application/json"),
+			CacheControl: aws.String("public, max-age=300"),
+		})
+		if err != nil {
+			return key, fmt.Errorf("could not publish digest to '%s': %v", objectKey, err)
+		}
+	}
+
+	return key, nil
+}
+
+// Send emails the digest to each confirmed subscriber not yet sent it. Subscribers whose address the mail server
+// permanently rejects are dropped, and the others are retried on the next run when sending fails.
+func (d *Digests) Send(ctx context.Context, digest *Digest, result *DigestResult) error {
+	if d.mailer == nil {
+		return nil
+	}
+
+	item, err := d.get(ctx)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(item.Subscribers))
+	for id, subscriber := range item.Subscribers {
+		if !subscriber.Pending {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	result.Subscribers = len(ids)
+
+	sent := make(map[string]bool)
+	dropped := make(map[string]bool)
+	var errs []error
+	for _, id := range ids {
+		subscriber := item.Subscribers[id]
+		if subscriber.SentFor == digest.Date {
+			continue
+		}
+
+		text, html, err := d.render(digest, subscriber)
+		if err != nil {
+			return err
+		}
+
+		subject := fmt.Sprintf("Names freeing on %s", time.UnixMilli(digest.From).UTC().Format("Monday, January 2"))
+		err = d.mailer.SendHTML(ctx, subscriber.Contact, subject, text, html)
+		switch {
+		case isPermanentAlertError(err):
+			dropped[id] = true
+			result.Dropped++
+		case err != nil:
+			errs = append(errs, fmt.Errorf("could not send digest to subscriber %s: %v", id, err))
+		default:
+			sent[id] = true
+			result.Sent++
+		}
+	}
+
+	if len(sent) > 0 || len(dropped) > 0 {
+		errs = append(errs, d.update(ctx, func(item *digestSubscribersItem) error {
+			for id := range sent {
+				if subscriber, ok := item.Subscribers[id]; ok {
+					subscriber.SentFor = digest.Date
+				}
+			}
+
+			for id := range dropped {
+				delete(item.Subscribers, id)
+			}
+			return nil
+		}))
+	}
+
+	return errors.Join(errs...)
+}
+
+type digestView struct {
+	Day            string
+	Regions        []digestRegionView
+	DigestUrl      string
+	UnsubscribeUrl string
+}
+
+type digestRegionView struct {
+	Region string
+	Total  int
+	Names  []digestNameView
+	More   int
+}
+
+type digestNameView struct {
+	Name   string
+	Length int
+	Score  int
+	Level  int
+	At     string
+}
+
+func (d *Digests) render(digest *Digest, subscriber *DigestSubscriber) (string, string, error) {
+	view := digestView{
+		Day:            time.UnixMilli(digest.From).UTC().Format("Monday, January 2"),
+		UnsubscribeUrl: d.unsubscribeLink(subscriber),
+	}
+	if d.publicUrl != "" {
+		view.DigestUrl = d.publicUrl + "/digests/" + digest.Date + ".json"
+	}
+
+	for _, region := range digest.Regions {
+		if len(subscriber.Regions) > 0 && !slices.Contains(subscriber.Regions, region.Region) {
+			continue
+		}
+
+		regionView := digestRegionView{Region: region.Region, Total: region.Total}
+		for i, name := range region.Names {
+			if i == digestEmailNames {
+				regionView.More = region.Total - digestEmailNames
+				break
+			}
+
+			regionView.Names = append(regionView.Names, digestNameView{
+				Name:   name.Name,
+				Length: name.Length,
+				Score:  name.Score,
+				Level:  name.Level,
+				At:     time.UnixMilli(name.AvailabilityDate).UTC().Format("15:04 MST"),
+			})
+		}
+
+		view.Regions = append(view.Regions, regionView)
+	}
+
+	var text, html strings.Builder
+	if err := digestTextTemplate.Execute(&text, view); err != nil {
+		return "", "", err
+	}
+
+	if err := digestHtmlTemplate.Execute(&html, view); err != nil {
+		return "", "", err
+	}
+
+	return text.String(), html.String(), nil
+}
+
+func (d *Digests) unsubscribeLink(subscriber *DigestSubscriber) string {
+	if d.unsubscribeUrl == "" {
+		return ""
+	}
+
+	query := url.Values{
+		"contact": {subscriber.Contact},
+		"token":   {subscriber.Token},
+	}
+
+	return d.unsubscribeUrl + "?" + query.Encode()
+}
+
+var digestTextTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{"plural": pluralize}).Parse(`Names freeing on {{.Day}}
+{{range .Regions}}
+{{.Region}}: {{.Total}} {{plural .Total "name" "names"}}
+{{range .Names}}  {{.Name}} ({{.Length}} characters, score {{.Score}}, level {{.Level}}) at {{.At}}
+{{end}}{{if .More}}  and {{.More}} more
+{{end}}{{end}}{{if .DigestUrl}}
+Every name is listed at {{.DigestUrl}}
+{{end}}{{if .UnsubscribeUrl}}
+To stop receiving this digest, visit {{.UnsubscribeUrl}}
+{{end}}`))
+
+var digestHtmlTemplate = htmltemplate.Must(htmltemplate.New("digest").Funcs(htmltemplate.FuncMap{"plural": pluralize}).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif">
+<h1>Names freeing on {{.Day}}</h1>
+{{range .Regions}}<h2>{{.Region}}: {{.Total}} {{plural .Total "name" "names"}}</h2>
+<table cellpadding="4">
+<tr><th align="left">Name</th><th>Length</th><th>Score</th><th>Level</th><th>Frees at</th></tr>
+{{range .Names}}<tr><td>{{.Name}}</td><td align="center">{{.Length}}</td><td align="center">{{.Score}}</td><td align="center">{{.Level}}</td><td>{{.At}}</td></tr>
+{{end}}</table>
+{{if .More}}<p>and {{.More}} more</p>
+{{end}}{{end}}{{if .DigestUrl}}<p><a href="{{.DigestUrl}}">See every name</a></p>
+{{end}}{{if .UnsubscribeUrl}}<p style="font-size: small"><a href="{{.UnsubscribeUrl}}">Unsubscribe</a></p>
+{{end}}</body>
+</html>
+`))
+
+func (d *Digests) get(ctx context.Context) (*digestSubscribersItem, error) {
+	item := &digestSubscribersItem{Key: digestSubscribersKey}
+	if err := getSingleItem(ctx, d.dynamodb, d.tableName, digestSubscribersKey, item); err != nil {
+		return nil, err
+	}
+
+	if item.Subscribers == nil {
+		item.Subscribers = make(map[string]*DigestSubscriber)
+	}
+
+	return item, nil
+}
+
+func (d *Digests) update(ctx context.Context, change func(item *digestSubscribersItem) error) error {
+	item, err := d.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	return updateSingleItem(ctx, d.dynamodb, d.tableName, item, d.now(), func() error {
+		for id, subscriber := range item.Subscribers {
+			if subscriber.Pending && confirmationExpired(subscriber.CreatedAt, d.now()) {
+				delete(item.Subscribers, id)
+			}
+		}
+
+		return change(item)
+	})
+}
@@ -0,0 +1,311 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"io"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type StorageServiceMock struct {
+	ShouldFail bool
+	Objects    map[string]string
+}
+
+func (s *StorageServiceMock) PutObject(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	body, _ := io.ReadAll(input.Body)
+	if s.Objects == nil {
+		s.Objects = make(map[string]string)
+	}
+
+	s.Objects[*input.Bucket+"/"+*input.Key] = string(body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+type HTMLMailerMock struct {
+	FailFor   string
+	RejectFor string
+	Sent      []string
+	Texts     []string
+	Htmls     []string
+}
+
+func (m *HTMLMailerMock) SendHTML(_ context.Context, to string, subject string, text string, html string) error {
+	if to == m.FailFor {
+		return fmt.Errorf("connection refused")
+	}
+
+	if to == m.RejectFor {
+		return &textproto.Error{Code: 550, Msg: "No such user"}
+	}
+
+	m.Sent = append(m.Sent, to+": "+subject)
+	m.Texts = append(m.Texts, text)
+	m.Htmls = append(m.Htmls, html)
+	return nil
+}
+
+func newTestDigests(upcoming *UpcomingSummonersMock) (*Digests, *StorageServiceMock, *HTMLMailerMock) {
+	storage := &StorageServiceMock{}
+	mailer := &HTMLMailerMock{}
+	digests := NewDigests(newWatchlistDynamoDBServiceMock(), "test-table", upcoming, storage, "test-bucket", mailer, "https://static.names.lol/", "https://names.lol/digest/unsubscribe")
+	digests.now = func() time.Time { return watchTestNow }
+	confirmations, _, _ := newTestConfirmations()
+	digests.ConfirmWith(confirmations, "https://names.lol/digest/confirm")
+
+	return digests, storage, mailer
+}
+
+// subscribeDigest subscribes a contact to the digest and confirms it as the contact would.
+func subscribeDigest(digests *Digests, contact string, regions []string) (*DigestSubscriber, error) {
+	subscriber, err := digests.Subscribe(context.TODO(), contact, regions)
+	if err != nil {
+		return nil, err
+	}
+
+	return subscriber, digests.Confirm(context.TODO(), contact, subscriber.Token)
+}
+
+func TestDigests_BuildRanksByLengthThenScore(t *testing.T) {
+	summoners := &UpcomingSummonersMock{Summoners: []*SummonerDTO{
+		upcoming("Xkcdq", time.Hour),
+		upcoming("Lumen", 2*time.Hour),
+		upcoming("Sky", 3*time.Hour),
+		upcoming("Hidden", 4*time.Hour),
+		upcoming("Tomorrow", 25*time.Hour),
+	}}
+	summoners.Summoners[3].Hidden = true
+	digests, _, _ := newTestDigests(summoners)
+
+	digest, err := digests.Build([]string{"NA"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	names := make([]string, 0)
+	for _, name := range digest.Regions[0].Names {
+		names = append(names, name.Name)
+	}
+
+	if fmt.Sprint(names) != "[Sky Lumen Xkcdq]" || digest.Regions[0].Total != 3 || digest.Date != "2024-02-12" {
+		t.Errorf("expected visible names within 24 hours by length then score, got %v in %+v", names, digest)
+	}
+}
+
+func TestDigests_BuildCollectsEveryNameAcrossPagesAndSharedDates(t *testing.T) {
+	summoners := &UpcomingSummonersMock{}
+	for i := 0; i < 8500; i++ {
+		// Names free in groups of 1500 sharing a date, more than fit on one page.
+		in := time.Duration(1+i/1500) * time.Hour
+		summoners.Summoners = append(summoners.Summoners, upcoming(fmt.Sprintf("name%05d", i), in))
+	}
+	digests, _, _ := newTestDigests(summoners)
+
+	digest, err := digests.Build([]string{"NA"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if digest.Regions[0].Total != 8500 || len(digest.Regions[0].Names) != 8500 {
+		t.Errorf("expected all 8500 names, got %d", digest.Regions[0].Total)
+	}
+}
+
+func TestDigests_BuildAppliesNameFilter(t *testing.T) {
+	summoners := &UpcomingSummonersMock{Summoners: []*SummonerDTO{upcoming("Blocked", time.Hour), upcoming("Allowed", time.Hour)}}
+	digests, _, _ := newTestDigests(summoners)
+	digests.FilterNames(func(summoner *SummonerDTO) bool { return summoner.Name != "Blocked" })
+
+	digest, _ := digests.Build([]string{"NA"})
+	if digest.Regions[0].Total != 1 || digest.Regions[0].Names[0].Name != "Allowed" {
+		t.Errorf("expected only the allowed name, got %+v", digest.Regions[0].Names)
+	}
+}
+
+func TestDigests_RunPublishesJSONAndEmailsSubscribersOnce(t *testing.T) {
+	summoners := &UpcomingSummonersMock{Summoners: []*SummonerDTO{upcoming("Sky", time.Hour)}}
+	digests, storage, mailer := newTestDigests(summoners)
+	_, _ = subscribeDigest(digests, "all@example.com", nil)
+	_, _ = subscribeDigest(digests, "euw@example.com", []string{"EUW"})
+
+	result, err := digests.Run(context.TODO())
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if result.Key != "digests/2024-02-12.json" || result.Names != 1 || result.Sent != 2 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	var published Digest
+	if err = json.Unmarshal([]byte(storage.Objects["test-bucket/digests/latest.json"]), &published); err != nil || len(published.Regions) != 5 {
+		t.Fatalf("expected the digest of every region to be published, got %v, %s", err, storage.Objects)
+	}
+
+	if storage.Objects["test-bucket/digests/2024-02-12.json"] != storage.Objects["test-bucket/digests/latest.json"] {
+		t.Errorf("expected the dated and latest digests to match")
+	}
+
+	all, euw := slices.Index(mailer.Sent, "all@example.com: Names freeing on Monday, February 12"), slices.Index(mailer.Sent, "euw@example.com: Names freeing on Monday, February 12")
+	if len(mailer.Sent) != 2 || all == -1 || euw == -1 {
+		t.Fatalf("unexpected emails %v", mailer.Sent)
+	}
+
+	if text := mailer.Texts[all]; !strings.Contains(text, "NA: 1 name\n") || !strings.Contains(text, "Sky (3 characters, score 100, level 0) at 09:00 UTC") ||
+		!strings.Contains(text, "https://static.names.lol/digests/2024-02-12.json") || !strings.Contains(text, "https://names.lol/digest/unsubscribe?contact=all%40example.com&token=") {
+		t.Errorf("unexpected text %s", text)
+	}
+
+	if !strings.Contains(mailer.Htmls[all], "<td>Sky</td>") {
+		t.Errorf("unexpected html %s", mailer.Htmls[all])
+	}
+
+	if text := mailer.Texts[euw]; strings.Contains(text, "NA:") || !strings.Contains(text, "EUW: 0 names") {
+		t.Errorf("expected only the subscribed region, got %s", text)
+	}
+
+	if _, err = digests.Run(context.TODO()); err != nil || len(mailer.Sent) != 2 {
+		t.Errorf("expected a second run not to resend the digest, got %v, %v", err, mailer.Sent)
+	}
+}
+
+func TestDigests_SendRetriesFailuresAndDropsRejectedSubscribers(t *testing.T) {
+	digests, _, mailer := newTestDigests(&UpcomingSummonersMock{})
+	mailer.FailFor = "later@example.com"
+	mailer.RejectFor = "gone@example.com"
+	_, _ = subscribeDigest(digests, "later@example.com", nil)
+	_, _ = subscribeDigest(digests, "gone@example.com", nil)
+
+	result, err := digests.Run(context.TODO())
+	if err == nil || result.Dropped != 1 {
+		t.Fatalf("expected an error and a dropped subscriber, got %v, %+v", err, result)
+	}
+
+	mailer.FailFor = ""
+	if result, err = digests.Run(context.TODO()); err != nil || result.Sent != 1 || result.Subscribers != 1 {
+		t.Errorf("expected the failed subscriber to be retried, got %v, %+v", err, result)
+	}
+}
+
+func TestDigests_EscapesNamesInHTML(t *testing.T) {
+	summoners := &UpcomingSummonersMock{Summoners: []*SummonerDTO{upcoming("<b>x</b>", time.Hour)}}
+	digests, _, mailer := newTestDigests(summoners)
+	_, _ = subscribeDigest(digests, "a@example.com", nil)
+
+	_, _ = digests.Run(context.TODO())
+	if len(mailer.Htmls) != 1 || strings.Contains(mailer.Htmls[0], "<b>x</b>") {
+		t.Errorf("expected the name to be escaped, got %v", mailer.Htmls)
+	}
+}
+
+func TestDigests_SubscribeAndUnsubscribe(t *testing.T) {
+	digests, _, _ := newTestDigests(&UpcomingSummonersMock{})
+
+	if _, err := digests.Subscribe(context.TODO(), "a@example.com", []string{"MARS"}); err == nil {
+		t.Errorf("expected error for invalid region, got nil")
+	}
+
+	subscriber, err := digests.Subscribe(context.TODO(), "a@example.com", []string{"NA"})
+	if err != nil || subscriber.Token == "" {
+		t.Fatalf("expected a subscriber with a token, got %v, %v", err, subscriber)
+	}
+
+	if err = digests.Unsubscribe(context.TODO(), "a@example.com", "wrong"); err == nil || err.Error() != "subscription not found" {
+		t.Errorf("expected subscription not found, got %v", err)
+	}
+
+	if err = digests.Unsubscribe(context.TODO(), "a@example.com", subscriber.Token); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestDigests_SubscribeStaysPendingUntilConfirmed(t *testing.T) {
+	digests, _, mailer := newTestDigests(&UpcomingSummonersMock{})
+	confirmations, confirmationMailer, _ := newTestConfirmations()
+	digests.ConfirmWith(confirmations, "https://names.lol/digest/confirm")
+
+	subscriber, err := digests.Subscribe(context.TODO(), "a@example.com", nil)
+	if err != nil || !subscriber.Pending {
+		t.Fatalf("expected a pending subscriber, got %v, %+v", err, subscriber)
+	}
+
+	if len(confirmationMailer.Bodies) != 1 || !strings.Contains(confirmationMailer.Bodies[0], "https://names.lol/digest/confirm?contact=a%40example.com&token="+subscriber.Token) {
+		t.Errorf("expected a confirmation link, got %v", confirmationMailer.Bodies)
+	}
+
+	result, err := digests.Run(context.TODO())
+	if err != nil || result.Subscribers != 0 || len(mailer.Sent) != 0 {
+		t.Errorf("expected pending subscribers not to be sent the digest, got %v, %+v, %v", err, result, mailer.Sent)
+	}
+
+	if err = digests.Confirm(context.TODO(), "a@example.com", "wrong"); err == nil || err.Error() != "subscription not found" {
+		t.Errorf("expected subscription not found, got %v", err)
+	}
+
+	if err = digests.Confirm(context.TODO(), "a@example.com", subscriber.Token); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if result, err = digests.Run(context.TODO()); err != nil || result.Sent != 1 {
+		t.Errorf("expected the confirmed subscriber to be sent the digest, got %v, %+v", err, result)
+	}
+}
+
+func TestDigests_SubscribeIsRateLimitedPerContact(t *testing.T) {
+	digests, _, _ := newTestDigests(&UpcomingSummonersMock{})
+	confirmations, confirmationMailer, limiter := newTestConfirmations()
+	digests.ConfirmWith(confirmations, "https://names.lol/digest/confirm")
+	limiter.Limited = true
+
+	var rateLimitedErr *RateLimitedError
+	if _, err := digests.Subscribe(context.TODO(), "a@example.com", nil); !errors.As(err, &rateLimitedErr) {
+		t.Errorf("expected a rate limited error, got %v", err)
+	}
+
+	if len(confirmationMailer.Bodies) != 0 {
+		t.Errorf("expected no confirmation to be sent, got %v", confirmationMailer.Bodies)
+	}
+}
+
+func TestDigests_SubscribeRequiresConfirmations(t *testing.T) {
+	digests, _, _ := newTestDigests(&UpcomingSummonersMock{})
+	digests.ConfirmWith(nil, "")
+
+	if _, err := digests.Subscribe(context.TODO(), "a@example.com", nil); err == nil {
+		t.Errorf("expected an error without confirmations, got nil")
+	}
+}
+
+func TestDigests_DropsExpiredPendingSubscribers(t *testing.T) {
+	digests, _, _ := newTestDigests(&UpcomingSummonersMock{})
+	_, _ = digests.Subscribe(context.TODO(), "late@example.com", nil)
+
+	digests.now = func() time.Time { return watchTestNow.Add(confirmationTtl + time.Minute) }
+	_, _ = subscribeDigest(digests, "a@example.com", nil)
+
+	item, err := digests.get(context.TODO())
+	if err != nil || len(item.Subscribers) != 1 || item.Subscribers[subscriptionId("a@example.com")] == nil {
+		t.Errorf("expected only the new subscriber to be kept, got %v, %v", err, item)
+	}
+}
+
+func TestDigests_RunFailsWhenPublishingFails(t *testing.T) {
+	digests, storage, mailer := newTestDigests(&UpcomingSummonersMock{})
+	storage.ShouldFail = true
+	_, _ = subscribeDigest(digests, "a@example.com", nil)
+
+	if _, err := digests.Run(context.TODO()); err == nil || len(mailer.Sent) != 0 {
+		t.Errorf("expected an error before sending, got %v, %v", err, mailer.Sent)
+	}
+}
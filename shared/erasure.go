@@ -0,0 +1,306 @@
+package shared
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+	"time"
+)
+
+// Identifier kinds a player can be suppressed by.
+const (
+	SuppressPuuid   = "puuid"
+	SuppressAccount = "account"
+)
+
+type suppressionGetter interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+}
+
+type erasureDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// SuppressedError is returned when writing a summoner whose player asked to be erased.
+type SuppressedError struct {
+	Name string
+}
+
+func (e *SuppressedError) Error() string {
+	return fmt.Sprintf("summoner '%s' belongs to a suppressed account", e.Name)
+}
+
+func IsSuppressed(err error) bool {
+	var suppressedErr *SuppressedError
+	return errors.As(err, &suppressedErr)
+}
+
+// SuppressionKey returns the key of a suppression entry. Identifiers are hashed so the table keeps no
+// trace of the erased account, while still letting writes be checked against it.
+func SuppressionKey(kind string, id string) string {
+	sum := sha256.Sum256([]byte(kind + ":" + id))
+	return "suppressed#" + hex.EncodeToString(sum[:])
+}
+
+type suppressionItem struct {
+	Key          string `dynamodbav:"n"`
+	Kind         string `dynamodbav:"k"`
+	SuppressedAt int64  `dynamodbav:"ts"`
+}
+
+// isSuppressed reports whether either of a summoner's identifiers has been suppressed.
+func isSuppressed(ctx context.Context, client suppressionGetter, tableName string, summoner *SummonerDTO) (bool, error) {
+	identifiers := map[string]string{SuppressPuuid: summoner.Puuid, SuppressAccount: summoner.AccountID}
+
+	for _, kind := range []string{SuppressPuuid, SuppressAccount} {
+		if identifiers[kind] == "" {
+			continue
+		}
+
+		output, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(tableName),
+			Key: map[string]types.AttributeValue{
+				"n": &types.AttributeValueMemberS{Value: SuppressionKey(kind, identifiers[kind])},
+			},
+		})
+		if err != nil {
+			return false, err
+		}
+
+		if output.Item != nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+type ErasureRequest struct {
+	Puuid     string
+	AccountID string
+}
+
+type ErasureResult struct {
+	Deleted    []string `json:"deleted"`
+	Suppressed int      `json:"suppressed"`
+	Scrubbed   int      `json:"scrubbed"`
+}
+
+type Erasure struct {
+	dynamodb  erasureDynamoDbService
+	index     nameIndex
+	tableName string
+	now       func() time.Time
+}
+
+func NewErasure(client erasureDynamoDbService, tableName string) *Erasure {
+	return &Erasure{
+		dynamodb:  client,
+		tableName: tableName,
+		now:       time.Now,
+	}
+}
+
+// IndexNames sets the index the postings of erased names are removed from, like Summoners.IndexNames.
+func (e *Erasure) IndexNames(index nameIndex) {
+	e.index = index
+}
+
+// Erase suppresses a player's identifiers, then deletes every name the account has held along with its
+// search postings, and hashes the targets of audit entries about those names. Suppression comes first so a
+// name update running at the same time cannot write the summoner back. Items saved before PUUIDs were
+// stored only match by account ID, so pass both when they are known.
+func (e *Erasure) Erase(ctx context.Context, request ErasureRequest) (*ErasureResult, error) {
+	if request.Puuid == "" && request.AccountID == "" {
+		return nil, fmt.Errorf("a puuid or account id is required")
+	}
+
+	result := &ErasureResult{Deleted: make([]string, 0)}
+	suppressed := make(map[string]bool)
+
+	suppress := func(kind string, id string) error {
+		key := SuppressionKey(kind, id)
+		if id == "" || suppressed[key] {
+			return nil
+		}
+
+		if err := e.suppress(ctx, kind, key); err != nil {
+			return err
+		}
+
+		suppressed[key] = true
+		result.Suppressed++
+		return nil
+	}
+
+	if err := suppress(SuppressPuuid, request.Puuid); err != nil {
+		return result, err
+	}
+
+	if err := suppress(SuppressAccount, request.AccountID); err != nil {
+		return result, err
+	}
+
+	filter, values := "attribute_exists(r) and (", map[string]types.AttributeValue{}
+	if request.Puuid != "" {
+		filter += "pid = :pid"
+		values[":pid"] = &types.AttributeValueMemberS{Value: request.Puuid}
+	}
+	if request.AccountID != "" {
+		if request.Puuid != "" {
+			filter += " or "
+		}
+		filter += "aid = :aid"
+		values[":aid"] = &types.AttributeValueMemberS{Value: request.AccountID}
+	}
+
+	var startKey map[string]types.AttributeValue
+	for {
+		output, err := e.dynamodb.Scan(ctx, &dynamodb.ScanInput{
+			TableName:                 aws.String(e.tableName),
+			FilterExpression:          aws.String(filter + ")"),
+			ExpressionAttributeValues: values,
+			ProjectionExpression:      aws.String("n, aid, pid"),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return result, err
+		}
+
+		for _, item := range output.Items {
+			var matched summonerItem
+			if err = attributevalue.UnmarshalMap(item, &matched); err != nil {
+				return result, err
+			}
+
+			// The account's other identifier may not have been passed, so suppress it as well.
+			if err = suppress(SuppressPuuid, matched.Puuid); err != nil {
+				return result, err
+			}
+
+			if err = suppress(SuppressAccount, matched.AccountID); err != nil {
+				return result, err
+			}
+
+			_, err = e.dynamodb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(e.tableName),
+				Key:       map[string]types.AttributeValue{"n": item["n"]},
+			})
+			if err != nil {
+				return result, err
+			}
+
+			if region, name, ok := strings.Cut(matched.Key, "#"); ok && e.index != nil {
+				if err = e.index.Remove(ctx, region, name); err != nil {
+					return result, err
+				}
+			}
+
+			result.Deleted = append(result.Deleted, matched.Key)
+		}
+
+		if len(output.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		startKey = output.LastEvaluatedKey
+	}
+
+	scrubbed, err := e.scrubAudit(ctx, result.Deleted)
+	result.Scrubbed = scrubbed
+	return result, err
+}
+
+// ErasedTarget returns the hash audit entries about an erased name keep as their target.
+func ErasedTarget(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return "erased#" + hex.EncodeToString(sum[:])
+}
+
+// scrubAudit replaces the targets of audit entries about erased names with their hash, keeping the record
+// of what was done without the name.
+func (e *Erasure) scrubAudit(ctx context.Context, names []string) (int, error) {
+	if len(names) == 0 {
+		return 0, nil
+	}
+
+	erased := make(map[string]bool, len(names))
+	for _, name := range names {
+		erased[name] = true
+	}
+
+	scrubbed := 0
+	var startKey map[string]types.AttributeValue
+	for {
+		output, err := e.dynamodb.Scan(ctx, &dynamodb.ScanInput{
+			TableName:                 aws.String(e.tableName),
+			FilterExpression:          aws.String("begins_with(n, :audit)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":audit": &types.AttributeValueMemberS{Value: "audit#"}},
+			ProjectionExpression:      aws.String("n, tg"),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return scrubbed, err
+		}
+
+		for _, item := range output.Items {
+			var entry AuditEntry
+			if err = attributevalue.UnmarshalMap(item, &entry); err != nil {
+				return scrubbed, err
+			}
+
+			if !erased[entry.Target] {
+				continue
+			}
+
+			_, err = e.dynamodb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:                 aws.String(e.tableName),
+				Key:                       map[string]types.AttributeValue{"n": item["n"]},
+				UpdateExpression:          aws.String("SET tg = :tg"),
+				ConditionExpression:       aws.String("attribute_exists(n)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{":tg": &types.AttributeValueMemberS{Value: ErasedTarget(entry.Target)}},
+			})
+			if err != nil {
+				return scrubbed, err
+			}
+
+			scrubbed++
+		}
+
+		if len(output.LastEvaluatedKey) == 0 {
+			return scrubbed, nil
+		}
+
+		startKey = output.LastEvaluatedKey
+	}
+}
+
+func (e *Erasure) suppress(ctx context.Context, kind string, key string) error {
+	item, err := attributevalue.MarshalMap(&suppressionItem{Key: key, Kind: kind, SuppressedAt: e.now().UnixMilli()})
+	if err != nil {
+		return err
+	}
+
+	_, err = e.dynamodb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(e.tableName),
+		Item:      item,
+	})
+
+	return err
+}
+
+// IsSuppressed reports whether a summoner belongs to an erased account.
+func (e *Erasure) IsSuppressed(ctx context.Context, summoner *SummonerDTO) (bool, error) {
+	return isSuppressed(ctx, e.dynamodb, e.tableName, summoner)
+}
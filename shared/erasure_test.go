@@ -0,0 +1,194 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"testing"
+)
+
+type ErasureDynamoDBServiceMock struct {
+	Pages      [][]map[string]types.AttributeValue
+	Audit      []map[string]types.AttributeValue
+	ShouldFail bool
+	Stored     map[string]bool
+	Events     []string
+	ScanCalls  []*dynamodb.ScanInput
+	AuditScans []*dynamodb.ScanInput
+}
+
+func (e *ErasureDynamoDBServiceMock) GetItem(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if e.Stored[input.Key["n"].(*types.AttributeValueMemberS).Value] {
+		return &dynamodb.GetItemOutput{Item: input.Key}, nil
+	}
+
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (e *ErasureDynamoDBServiceMock) PutItem(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if e.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	key := input.Item["n"].(*types.AttributeValueMemberS).Value
+	if e.Stored == nil {
+		e.Stored = make(map[string]bool)
+	}
+
+	e.Stored[key] = true
+	e.Events = append(e.Events, "put "+key)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (e *ErasureDynamoDBServiceMock) Scan(_ context.Context, input *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if *input.FilterExpression == "begins_with(n, :audit)" {
+		e.AuditScans = append(e.AuditScans, input)
+		return &dynamodb.ScanOutput{Items: e.Audit}, nil
+	}
+
+	e.ScanCalls = append(e.ScanCalls, input)
+
+	page := len(e.ScanCalls) - 1
+	output := &dynamodb.ScanOutput{}
+	if page < len(e.Pages) {
+		output.Items = e.Pages[page]
+	}
+
+	if page < len(e.Pages)-1 {
+		output.LastEvaluatedKey = map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: "next"}}
+	}
+
+	return output, nil
+}
+
+func (e *ErasureDynamoDBServiceMock) UpdateItem(_ context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	key := input.Key["n"].(*types.AttributeValueMemberS).Value
+	for _, item := range e.Audit {
+		if item["n"].(*types.AttributeValueMemberS).Value == key {
+			item["tg"] = input.ExpressionAttributeValues[":tg"]
+		}
+	}
+
+	e.Events = append(e.Events, "update "+key)
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (e *ErasureDynamoDBServiceMock) DeleteItem(_ context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	e.Events = append(e.Events, "delete "+input.Key["n"].(*types.AttributeValueMemberS).Value)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func erasureItem(key string, accountId string, puuid string) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		"n":   &types.AttributeValueMemberS{Value: key},
+		"aid": &types.AttributeValueMemberS{Value: accountId},
+	}
+
+	if puuid != "" {
+		item["pid"] = &types.AttributeValueMemberS{Value: puuid}
+	}
+
+	return item
+}
+
+func TestErasure_SuppressesBeforeDeletingEveryName(t *testing.T) {
+	mock := &ErasureDynamoDBServiceMock{Pages: [][]map[string]types.AttributeValue{
+		{erasureItem("NA#CURRENT", "aid", "puuid")},
+		{erasureItem("NA#OLDNAME", "aid", "")},
+	}}
+
+	result, err := NewErasure(mock, "test-table").Erase(context.TODO(), ErasureRequest{Puuid: "puuid"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(result.Deleted) != 2 || result.Deleted[1] != "NA#OLDNAME" {
+		t.Errorf("expected both names to be deleted, got %v", result.Deleted)
+	}
+
+	if result.Suppressed != 2 || !mock.Stored[SuppressionKey(SuppressAccount, "aid")] {
+		t.Errorf("expected the discovered account id to be suppressed too, got %+v", result)
+	}
+
+	if mock.Events[0] != "put "+SuppressionKey(SuppressPuuid, "puuid") {
+		t.Errorf("expected suppression to be recorded first, got %v", mock.Events)
+	}
+
+	if *mock.ScanCalls[1].FilterExpression != "attribute_exists(r) and (pid = :pid)" || mock.ScanCalls[1].ExclusiveStartKey == nil {
+		t.Errorf("unexpected scan %+v", mock.ScanCalls[1])
+	}
+}
+
+func TestErasure_RemovesPostingsAndScrubsAuditTargets(t *testing.T) {
+	auditEntry := func(key string, target string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"n":  &types.AttributeValueMemberS{Value: key},
+			"tg": &types.AttributeValueMemberS{Value: target},
+		}
+	}
+
+	mock := &ErasureDynamoDBServiceMock{
+		Pages: [][]map[string]types.AttributeValue{{erasureItem("NA#CURRENT", "aid", "puuid")}},
+		Audit: []map[string]types.AttributeValue{auditEntry("audit#1#a", "NA#CURRENT"), auditEntry("audit#2#b", "NA#OTHER")},
+	}
+	index := &NameIndexMock{}
+	erasure := NewErasure(mock, "test-table")
+	erasure.IndexNames(index)
+
+	result, err := erasure.Erase(context.TODO(), ErasureRequest{Puuid: "puuid"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(index.Removed) != 1 || index.Removed[0] != "NA#CURRENT" {
+		t.Errorf("expected the name's postings to be removed, got %v", index.Removed)
+	}
+
+	if target := mock.Audit[0]["tg"].(*types.AttributeValueMemberS).Value; result.Scrubbed != 1 || target != ErasedTarget("NA#CURRENT") {
+		t.Errorf("expected the audit target to be hashed, got %s and %+v", target, result)
+	}
+
+	if target := mock.Audit[1]["tg"].(*types.AttributeValueMemberS).Value; target != "NA#OTHER" {
+		t.Errorf("expected other audit entries to be kept, got %s", target)
+	}
+}
+
+func TestErasure_StopsWhenSuppressionCannotBeRecorded(t *testing.T) {
+	mock := &ErasureDynamoDBServiceMock{ShouldFail: true, Pages: [][]map[string]types.AttributeValue{{erasureItem("NA#CURRENT", "aid", "")}}}
+
+	_, err := NewErasure(mock, "test-table").Erase(context.TODO(), ErasureRequest{AccountID: "aid"})
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if len(mock.Events) != 0 || len(mock.ScanCalls) != 0 {
+		t.Errorf("expected nothing to be deleted, got %v", mock.Events)
+	}
+}
+
+func TestErasure_RequiresAnIdentifier(t *testing.T) {
+	if _, err := NewErasure(&ErasureDynamoDBServiceMock{}, "test-table").Erase(context.TODO(), ErasureRequest{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestErasure_IsSuppressedChecksEitherIdentifier(t *testing.T) {
+	mock := &ErasureDynamoDBServiceMock{Stored: map[string]bool{SuppressionKey(SuppressAccount, "aid"): true}}
+	erasure := NewErasure(mock, "test-table")
+
+	if suppressed, _ := erasure.IsSuppressed(context.TODO(), &SummonerDTO{Puuid: "other", AccountID: "aid"}); !suppressed {
+		t.Errorf("expected summoner to be suppressed")
+	}
+
+	if suppressed, _ := erasure.IsSuppressed(context.TODO(), &SummonerDTO{Puuid: "other", AccountID: "other"}); suppressed {
+		t.Errorf("expected summoner not to be suppressed")
+	}
+}
+
+func TestSuppressionKey_DoesNotContainIdentifier(t *testing.T) {
+	key := SuppressionKey(SuppressPuuid, "my-puuid")
+	if key == SuppressionKey(SuppressAccount, "my-puuid") || len(key) != len("suppressed#")+64 {
+		t.Errorf("unexpected key %s", key)
+	}
+}
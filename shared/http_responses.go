@@ -12,7 +12,8 @@ type HttpResponses struct {
 }
 
 type ErrResponse struct {
-	Message string `json:"message"`
+	Message string           `json:"message"`
+	Errors  ValidationErrors `json:"errors,omitempty"`
 }
 
 func NewHttpResponses(corsOrigins string, corsMethods string) *HttpResponses {
@@ -33,9 +34,21 @@ func (h *HttpResponses) Success(responseObj any) events.APIGatewayProxyResponse
 }
 
 func (h *HttpResponses) Error(statusCode int, message string) events.APIGatewayProxyResponse {
-	jsonBody, err := json.Marshal(&ErrResponse{
+	return h.errorResponse(statusCode, &ErrResponse{
 		Message: message,
 	})
+}
+
+// ValidationError responds with a 400 that lists every invalid field alongside the summary message.
+func (h *HttpResponses) ValidationError(errs ValidationErrors) events.APIGatewayProxyResponse {
+	return h.errorResponse(400, &ErrResponse{
+		Message: "Invalid query parameters: " + errs.Error(),
+		Errors:  errs,
+	})
+}
+
+func (h *HttpResponses) errorResponse(statusCode int, body *ErrResponse) events.APIGatewayProxyResponse {
+	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		log.Fatalf("Error marshalling error response: %v\n", err)
 	}
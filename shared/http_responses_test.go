@@ -105,3 +105,24 @@ func TestError_HasCorrectHeaders(t *testing.T) {
 		}
 	}
 }
+
+func TestValidationError_HasCorrectStatusCode(t *testing.T) {
+	responses := NewHttpResponses(origins, methods)
+
+	response := responses.ValidationError(ValidationErrors{{Field: "name", Message: "must be at least 3 characters"}})
+
+	if response.StatusCode != 400 {
+		t.Errorf("Expected status code to be 400, got %v", response.StatusCode)
+	}
+}
+
+func TestValidationError_HasFieldErrorsInJsonBody(t *testing.T) {
+	responses := NewHttpResponses(origins, methods)
+
+	response := responses.ValidationError(ValidationErrors{{Field: "name", Message: "must be at least 3 characters"}})
+
+	expected := "{\"message\":\"Invalid query parameters: 'name' must be at least 3 characters\",\"errors\":[{\"field\":\"name\",\"message\":\"must be at least 3 characters\"}]}"
+	if response.Body != expected {
+		t.Errorf("Expected body to be %v, got %v", expected, response.Body)
+	}
+}
@@ -0,0 +1,250 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const leagueSweepPrefix = "league#sweep#"
+
+type leagueSweepDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+type leagueSummonersService interface {
+	FetchLeagueEntries(region string, league League, page int) ([]RiotLeagueEntryDTO, error)
+	FetchBySummonerId(region string, summonerId string) (*SummonerDTO, error)
+	Save(summoner *SummonerDTO) error
+}
+
+// LeagueProgress records how far a league has been swept. Page and Index point at the next entry to
+// resolve while a sweep is in progress; SweptAt is when the last complete sweep finished.
+type LeagueProgress struct {
+	Page    int   `dynamodbav:"pg" json:"page"`
+	Index   int   `dynamodbav:"ix" json:"index"`
+	SweptAt int64 `dynamodbav:"sa" json:"sweptAt"`
+}
+
+func (p *LeagueProgress) inProgress() bool {
+	return p.Page > 1 || p.Index > 0
+}
+
+type leagueSweepItem struct {
+	Key       string                     `dynamodbav:"n"`
+	Leagues   map[string]*LeagueProgress `dynamodbav:"lg"`
+	UpdatedAt int64                      `dynamodbav:"ua"`
+}
+
+func (i *leagueSweepItem) updatedAt() *int64 {
+	return &i.UpdatedAt
+}
+
+type LeagueSweepResult struct {
+	Region    string   `json:"region"`
+	Completed []string `json:"completed"`
+	Saved     int      `json:"saved"`
+	Skipped   int      `json:"skipped"`
+	Throttled bool     `json:"throttled"`
+	OutOfTime bool     `json:"outOfTime"`
+}
+
+// LeagueSweeper saves every summoner on the ranked ladders, a page at a time. Progress is stored per
+// region so a sweep interrupted by the deadline or the Riot API budget resumes where it stopped.
+type LeagueSweeper struct {
+	dynamodb  leagueSweepDynamoDbService
+	summoners leagueSummonersService
+	leagues   []League
+	interval  time.Duration
+	tableName string
+	now       func() time.Time
+}
+
+// NewLeagueSweeper sweeps the given leagues, sweeping each again once interval has passed since its last
+// complete sweep.
+func NewLeagueSweeper(client leagueSweepDynamoDbService, tableName string, summoners leagueSummonersService, leagues []League, interval time.Duration) *LeagueSweeper {
+	return &LeagueSweeper{
+		dynamodb:  client,
+		summoners: summoners,
+		leagues:   leagues,
+		interval:  interval,
+		tableName: tableName,
+		now:       time.Now,
+	}
+}
+
+// Sweep resolves and saves league entries of a region until every league is up to date, the deadline
+// passes or the Riot API budget runs out. Interrupted sweeps are finished first, then the leagues swept
+// longest ago.
+func (l *LeagueSweeper) Sweep(ctx context.Context, region string, deadline time.Time) (*LeagueSweepResult, error) {
+	state, err := l.load(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := state.UpdatedAt
+	result := &LeagueSweepResult{Region: region, Completed: make([]string, 0)}
+
+	sweepErr := l.sweepLeagues(region, state, deadline, result)
+
+	if err = putSingleItem(ctx, l.dynamodb, l.tableName, state, previous, l.now()); err != nil {
+		return result, err
+	}
+
+	return result, sweepErr
+}
+
+func (l *LeagueSweeper) sweepLeagues(region string, state *leagueSweepItem, deadline time.Time, result *LeagueSweepResult) error {
+	for _, league := range l.due(state) {
+		progress := state.Leagues[league.String()]
+
+		for {
+			if l.now().After(deadline) {
+				result.OutOfTime = true
+				return nil
+			}
+
+			done, err := l.sweepPage(region, league, progress, deadline, result)
+			if err != nil || result.Throttled || result.OutOfTime {
+				return err
+			}
+
+			if done {
+				*progress = LeagueProgress{SweptAt: l.now().UnixMilli()}
+				result.Completed = append(result.Completed, league.String())
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// due returns the leagues to sweep in the order to sweep them.
+func (l *LeagueSweeper) due(state *leagueSweepItem) []League {
+	due := make([]League, 0, len(l.leagues))
+	for _, league := range l.leagues {
+		progress, ok := state.Leagues[league.String()]
+		if !ok {
+			progress = &LeagueProgress{}
+			state.Leagues[league.String()] = progress
+		}
+
+		if progress.inProgress() || l.now().Sub(time.UnixMilli(progress.SweptAt)) >= l.interval {
+			due = append(due, league)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		a, b := state.Leagues[due[i].String()], state.Leagues[due[j].String()]
+		if a.inProgress() != b.inProgress() {
+			return a.inProgress()
+		}
+
+		return a.SweptAt < b.SweptAt
+	})
+
+	return due
+}
+
+// sweepPage saves the remaining entries of the league's current page, returning true once the league
+// has no more pages.
+func (l *LeagueSweeper) sweepPage(region string, league League, progress *LeagueProgress, deadline time.Time, result *LeagueSweepResult) (bool, error) {
+	if progress.Page == 0 {
+		progress.Page = 1
+	}
+
+	entries, err := l.summoners.FetchLeagueEntries(region, league, progress.Page)
+	if IsThrottled(err) || isCircuitOpen(err) {
+		result.Throttled = true
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("could not fetch page %d of league %s: %v", progress.Page, league, err)
+	}
+
+	if len(entries) == 0 {
+		return true, nil
+	}
+
+	for progress.Index < len(entries) {
+		if l.now().After(deadline) {
+			result.OutOfTime = true
+			return false, nil
+		}
+
+		summoner, err := l.summoners.FetchBySummonerId(region, entries[progress.Index].SummonerId)
+		switch {
+		case IsThrottled(err) || isCircuitOpen(err):
+			result.Throttled = true
+			return false, nil
+		case err != nil && err.Error() == "summoner not found":
+			result.Skipped++
+		case err != nil:
+			return false, err
+		default:
+			err = l.summoners.Save(summoner)
+			if IsSuppressed(err) {
+				result.Skipped++
+			} else if err != nil {
+				return false, err
+			} else {
+				result.Saved++
+			}
+		}
+
+		progress.Index++
+	}
+
+	progress.Page++
+	progress.Index = 0
+	return league.IsApex(), nil
+}
+
+func (l *LeagueSweeper) load(ctx context.Context, region string) (*leagueSweepItem, error) {
+	state := &leagueSweepItem{Key: leagueSweepPrefix + region}
+	if err := getSingleItem(ctx, l.dynamodb, l.tableName, state.Key, state); err != nil {
+		return nil, err
+	}
+
+	if state.Leagues == nil {
+		state.Leagues = make(map[string]*LeagueProgress)
+	}
+
+	return state, nil
+}
+
+// LeaguesFromEnv reads the leagues to sweep from LEAGUE_QUEUES and LEAGUE_TIERS, defaulting to the solo
+// queue's tiers from Emerald up, and the interval between sweeps from LEAGUE_SWEEP_INTERVAL.
+func LeaguesFromEnv() ([]League, time.Duration, error) {
+	queues := []string{QueueRankedSolo}
+	if value := os.Getenv("LEAGUE_QUEUES"); value != "" {
+		queues = splitList(value)
+	}
+
+	tiers := LeagueTiers[:5]
+	if value := os.Getenv("LEAGUE_TIERS"); value != "" {
+		tiers = splitList(strings.ToUpper(value))
+	}
+
+	leagues, err := Leagues(queues, tiers)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	interval := 24 * time.Hour
+	if value := os.Getenv("LEAGUE_SWEEP_INTERVAL"); value != "" {
+		interval, err = time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return nil, 0, fmt.Errorf("invalid LEAGUE_SWEEP_INTERVAL '%s'", value)
+		}
+	}
+
+	return leagues, interval, nil
+}
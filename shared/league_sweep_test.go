@@ -0,0 +1,145 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"testing"
+	"time"
+)
+
+// LeagueSummonersServiceMock serves leagues with Pages pages of two entries each.
+type LeagueSummonersServiceMock struct {
+	Pages         int
+	ThrottleAfter int
+	Suppressed    string
+	FetchCalls    []string
+	Saved         []string
+}
+
+func (l *LeagueSummonersServiceMock) FetchLeagueEntries(_ string, league League, page int) ([]RiotLeagueEntryDTO, error) {
+	if page > l.Pages || (league.IsApex() && page > 1) {
+		return []RiotLeagueEntryDTO{}, nil
+	}
+
+	prefix := fmt.Sprintf("%s-%d-", league.Tier+league.Division, page)
+	return []RiotLeagueEntryDTO{{SummonerId: prefix + "a"}, {SummonerId: prefix + "b"}}, nil
+}
+
+func (l *LeagueSummonersServiceMock) FetchBySummonerId(region string, summonerId string) (*SummonerDTO, error) {
+	l.FetchCalls = append(l.FetchCalls, summonerId)
+	if l.ThrottleAfter > 0 && len(l.FetchCalls) > l.ThrottleAfter {
+		return nil, &BudgetExhaustedError{Workload: WorkloadBackground}
+	}
+
+	return &SummonerDTO{Name: summonerId, Region: region}, nil
+}
+
+func (l *LeagueSummonersServiceMock) Save(summoner *SummonerDTO) error {
+	if summoner.Name == l.Suppressed {
+		return &SuppressedError{Name: summoner.Name}
+	}
+
+	l.Saved = append(l.Saved, summoner.Name)
+	return nil
+}
+
+func newTestLeagueSweeper(summoners *LeagueSummonersServiceMock, leagues ...League) (*LeagueSweeper, *fakeClock) {
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	sweeper := NewLeagueSweeper(&CrawlerDynamoDBServiceMock{Items: make(map[string]map[string]types.AttributeValue)}, "test-table", summoners, leagues, 24*time.Hour)
+	sweeper.now = clock.Now
+	return sweeper, clock
+}
+
+func TestLeagueSweeper_SavesEveryEntryOfEveryPage(t *testing.T) {
+	summoners := &LeagueSummonersServiceMock{Pages: 2, Suppressed: "GOLDI-2-b"}
+	sweeper, clock := newTestLeagueSweeper(summoners,
+		League{Queue: QueueRankedSolo, Tier: "MASTER"},
+		League{Queue: QueueRankedSolo, Tier: "GOLD", Division: "I"},
+	)
+
+	result, err := sweeper.Sweep(context.TODO(), "NA", clock.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(result.Completed) != 2 || result.Saved != 5 || result.Skipped != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	if len(summoners.Saved) != 5 || summoners.Saved[0] != "MASTER-1-a" || summoners.Saved[4] != "GOLDI-2-a" {
+		t.Errorf("unexpected saved summoners %v", summoners.Saved)
+	}
+}
+
+func TestLeagueSweeper_SkipsLeaguesSweptWithinInterval(t *testing.T) {
+	summoners := &LeagueSummonersServiceMock{Pages: 1}
+	sweeper, clock := newTestLeagueSweeper(summoners, League{Queue: QueueRankedSolo, Tier: "MASTER"})
+
+	_, _ = sweeper.Sweep(context.TODO(), "NA", clock.Now().Add(time.Minute))
+	clock.Advance(time.Hour)
+	result, _ := sweeper.Sweep(context.TODO(), "NA", clock.Now().Add(time.Minute))
+
+	if len(result.Completed) != 0 || len(summoners.FetchCalls) != 2 {
+		t.Errorf("expected the league not to be swept again, got %+v", result)
+	}
+
+	clock.Advance(24 * time.Hour)
+	if result, _ = sweeper.Sweep(context.TODO(), "NA", clock.Now().Add(time.Minute)); len(result.Completed) != 1 {
+		t.Errorf("expected the league to be swept again after the interval, got %+v", result)
+	}
+}
+
+func TestLeagueSweeper_ResumesInterruptedSweepFirst(t *testing.T) {
+	summoners := &LeagueSummonersServiceMock{Pages: 2, ThrottleAfter: 3}
+	leagues := []League{{Queue: QueueRankedSolo, Tier: "GOLD", Division: "I"}, {Queue: QueueRankedSolo, Tier: "GOLD", Division: "II"}}
+	sweeper, clock := newTestLeagueSweeper(summoners, leagues...)
+
+	result, err := sweeper.Sweep(context.TODO(), "NA", clock.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !result.Throttled || result.Saved != 3 {
+		t.Errorf("expected a throttled sweep after 3 entries, got %+v", result)
+	}
+
+	summoners.ThrottleAfter = 0
+	summoners.FetchCalls = nil
+	if _, err = sweeper.Sweep(context.TODO(), "NA", clock.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if summoners.FetchCalls[0] != "GOLDI-2-b" {
+		t.Errorf("expected the sweep to resume at the entry it stopped at, got %v", summoners.FetchCalls)
+	}
+}
+
+func TestLeagueSweeper_StopsAtDeadline(t *testing.T) {
+	summoners := &LeagueSummonersServiceMock{Pages: 1}
+	sweeper, clock := newTestLeagueSweeper(summoners, League{Queue: QueueRankedSolo, Tier: "MASTER"})
+
+	result, err := sweeper.Sweep(context.TODO(), "NA", clock.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !result.OutOfTime || len(summoners.FetchCalls) != 0 {
+		t.Errorf("expected nothing to be swept, got %+v", result)
+	}
+}
+
+func TestLeaguesFromEnv_ReadsQueuesTiersAndInterval(t *testing.T) {
+	t.Setenv("LEAGUE_QUEUES", "RANKED_FLEX_SR")
+	t.Setenv("LEAGUE_TIERS", "challenger,diamond")
+	t.Setenv("LEAGUE_SWEEP_INTERVAL", "6h")
+
+	leagues, interval, err := LeaguesFromEnv()
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(leagues) != 5 || leagues[0].Queue != QueueRankedFlex || interval != 6*time.Hour {
+		t.Errorf("unexpected leagues %v every %s", leagues, interval)
+	}
+}
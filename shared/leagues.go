@@ -0,0 +1,138 @@
+package shared
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// Ranked queues a league sweep can cover.
+const (
+	QueueRankedSolo = "RANKED_SOLO_5x5"
+	QueueRankedFlex = "RANKED_FLEX_SR"
+)
+
+// apexTiers have a single league per queue, served whole by their own endpoint rather than paginated by
+// division.
+var apexTiers = map[string]string{
+	"CHALLENGER":  "challengerleagues",
+	"GRANDMASTER": "grandmasterleagues",
+	"MASTER":      "masterleagues",
+}
+
+// LeagueTiers lists every tier, highest first.
+var LeagueTiers = []string{"CHALLENGER", "GRANDMASTER", "MASTER", "DIAMOND", "EMERALD", "PLATINUM", "GOLD", "SILVER", "BRONZE", "IRON"}
+
+var leagueDivisions = []string{"I", "II", "III", "IV"}
+
+// League identifies one ladder: a queue, a tier and, below the apex tiers, a division.
+type League struct {
+	Queue    string
+	Tier     string
+	Division string
+}
+
+func (l League) String() string {
+	if l.Division == "" {
+		return l.Queue + "#" + l.Tier
+	}
+
+	return l.Queue + "#" + l.Tier + "#" + l.Division
+}
+
+func (l League) IsApex() bool {
+	_, ok := apexTiers[l.Tier]
+	return ok
+}
+
+type RiotLeagueEntryDTO struct {
+	SummonerId   string `json:"summonerId"`
+	SummonerName string `json:"summonerName"`
+	Rank         string `json:"rank"`
+	LeaguePoints int    `json:"leaguePoints"`
+}
+
+type riotLeagueListDTO struct {
+	Entries []RiotLeagueEntryDTO `json:"entries"`
+}
+
+// Leagues expands queues and tiers into every league to sweep, in the order given.
+func Leagues(queues []string, tiers []string) ([]League, error) {
+	leagues := make([]League, 0)
+	for _, queue := range queues {
+		if queue != QueueRankedSolo && queue != QueueRankedFlex {
+			return nil, fmt.Errorf("invalid queue '%s'", queue)
+		}
+
+		for _, tier := range tiers {
+			if _, ok := apexTiers[tier]; ok {
+				leagues = append(leagues, League{Queue: queue, Tier: tier})
+				continue
+			}
+
+			if !slices.Contains(LeagueTiers, tier) {
+				return nil, fmt.Errorf("invalid tier '%s'", tier)
+			}
+
+			for _, division := range leagueDivisions {
+				leagues = append(leagues, League{Queue: queue, Tier: tier, Division: division})
+			}
+		}
+	}
+
+	return leagues, nil
+}
+
+// FetchLeagueEntries returns one page of a league's entries, starting from page 1. Apex leagues are
+// returned whole as page 1, and every later page of a league is empty.
+func (s *Summoners) FetchLeagueEntries(region string, league League, page int) ([]RiotLeagueEntryDTO, error) {
+	platform, err := s.regions.Get(region)
+	if err != nil {
+		return nil, err
+	}
+
+	if league.IsApex() {
+		if page > 1 {
+			return []RiotLeagueEntryDTO{}, nil
+		}
+
+		requestUrl := fmt.Sprintf("https://%s.api.riotgames.com/lol/league/v4/%s/by-queue/%s", platform, apexTiers[league.Tier], url.PathEscape(league.Queue))
+
+		var list riotLeagueListDTO
+		if err = s.riotGetJSON(platform, "league-v4-"+apexTiers[league.Tier], requestUrl, &list); err != nil {
+			return nil, err
+		}
+
+		return list.Entries, nil
+	}
+
+	requestUrl := fmt.Sprintf("https://%s.api.riotgames.com/lol/league/v4/entries/%s/%s/%s?page=%d", platform, url.PathEscape(league.Queue), league.Tier, league.Division, page)
+
+	var entries []RiotLeagueEntryDTO
+	if err = s.riotGetJSON(platform, "league-v4-entries", requestUrl, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// FetchBySummonerId fetches a summoner by the encrypted summoner id league entries refer to them by.
+func (s *Summoners) FetchBySummonerId(region string, summonerId string) (*SummonerDTO, error) {
+	platform, err := s.regions.Get(region)
+	if err != nil {
+		return nil, err
+	}
+
+	requestUrl := fmt.Sprintf("https://%s.api.riotgames.com/lol/summoner/v4/summoners/%s", platform, url.PathEscape(summonerId))
+
+	var riotSummoner RiotSummonerDTO
+	if err = s.riotGetJSON(platform, "summoner-v4-by-id", requestUrl, &riotSummoner); err != nil {
+		if err.Error() == "not found" {
+			return nil, fmt.Errorf("summoner not found")
+		}
+
+		return nil, err
+	}
+
+	return s.summonerFromRiotSummoner(&riotSummoner, region)
+}
@@ -0,0 +1,76 @@
+package shared
+
+import "testing"
+
+func TestLeagues_ExpandsDivisionsBelowApexTiers(t *testing.T) {
+	leagues, err := Leagues([]string{QueueRankedSolo}, []string{"MASTER", "DIAMOND"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(leagues) != 5 || leagues[0].String() != "RANKED_SOLO_5x5#MASTER" || leagues[4].String() != "RANKED_SOLO_5x5#DIAMOND#IV" {
+		t.Errorf("unexpected leagues %v", leagues)
+	}
+}
+
+func TestLeagues_RejectsUnknownQueuesAndTiers(t *testing.T) {
+	if _, err := Leagues([]string{"ARAM"}, []string{"MASTER"}); err == nil {
+		t.Errorf("expected error for queue, got nil")
+	}
+
+	if _, err := Leagues([]string{QueueRankedSolo}, []string{"WOOD"}); err == nil {
+		t.Errorf("expected error for tier, got nil")
+	}
+}
+
+func TestFetchLeagueEntries_CallsApexEndpointOnlyForFirstPage(t *testing.T) {
+	setup()
+	league := League{Queue: QueueRankedSolo, Tier: "CHALLENGER"}
+
+	_, _ = summoners.FetchLeagueEntries("NA", league, 1)
+	entries, err := summoners.FetchLeagueEntries("NA", league, 2)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected no entries after the first page, got %v %v", entries, err)
+	}
+
+	calls := summoners.http.(*MockHttpClient).Calls
+	expected := "https://NA.api.riotgames.com/lol/league/v4/challengerleagues/by-queue/RANKED_SOLO_5x5"
+	if len(calls) != 1 || calls[0].Request.URL.String() != expected {
+		t.Errorf("expected %s, got %v", expected, calls)
+	}
+}
+
+func TestFetchLeagueEntries_CallsPaginatedEntriesEndpoint(t *testing.T) {
+	setup()
+
+	_, _ = summoners.FetchLeagueEntries("NA", League{Queue: QueueRankedFlex, Tier: "GOLD", Division: "II"}, 3)
+
+	calls := summoners.http.(*MockHttpClient).Calls
+	expected := "https://NA.api.riotgames.com/lol/league/v4/entries/RANKED_FLEX_SR/GOLD/II?page=3"
+	if len(calls) != 1 || calls[0].Request.URL.String() != expected {
+		t.Errorf("expected %s, got %v", expected, calls)
+	}
+}
+
+func TestFetchBySummonerId_ReturnsSummoner(t *testing.T) {
+	setup()
+
+	summoner, err := summoners.FetchBySummonerId("NA", "test-id")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if summoner.Name != "Test" || summoner.Puuid != "test-puuid" {
+		t.Errorf("unexpected summoner %+v", summoner)
+	}
+}
+
+func TestFetchBySummonerId_WhenHttpClientReturns404_ReturnsSummonerNotFound(t *testing.T) {
+	setup()
+	summoners.http.(*MockHttpClient).ShouldReturn404 = true
+
+	_, err := summoners.FetchBySummonerId("NA", "test-id")
+	if err == nil || err.Error() != "summoner not found" {
+		t.Errorf("expected summoner not found, got %v", err)
+	}
+}
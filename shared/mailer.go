@@ -0,0 +1,184 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SMTPMailer sends plain text or HTML email through an SMTP server, upgrading to TLS when the server offers it.
+// Locally it can be pointed at any SMTP stand-in, such as MailHog on localhost:1025.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+	now  func() time.Time
+}
+
+// NewSMTPMailer sends from the given address through the server at addr (host:port). auth may be nil
+// for servers that do not require authentication.
+func NewSMTPMailer(addr string, from string, auth smtp.Auth) *SMTPMailer {
+	return &SMTPMailer{
+		addr: addr,
+		from: from,
+		auth: auth,
+		now:  time.Now,
+	}
+}
+
+// SMTPMailerFromEnv reads the server from SMTP_ADDR and the sender from SMTP_FROM. When SMTP_USERNAME is
+// set, the password is read from the secret named by SMTP_PASSWORD_NAME, or from SMTP_PASSWORD.
+func SMTPMailerFromEnv(ctx context.Context, secrets SecretsSource) (*SMTPMailer, error) {
+	addr, from := os.Getenv("SMTP_ADDR"), os.Getenv("SMTP_FROM")
+	if addr == "" || from == "" {
+		return nil, fmt.Errorf("SMTP_ADDR and SMTP_FROM are required")
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_ADDR '%s'", addr)
+	}
+
+	if _, err = mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid SMTP_FROM '%s'", from)
+	}
+
+	var auth smtp.Auth
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		password := os.Getenv("SMTP_PASSWORD")
+		if name := os.Getenv("SMTP_PASSWORD_NAME"); name != "" {
+			password, err = secrets.GetSecret(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return NewSMTPMailer(addr, from, auth), nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, to string, subject string, body string) error {
+	return m.deliver(ctx, to, subject, "text/plain; charset=utf-8", crlf(body))
+}
+
+// SendHTML sends an email with a plain text and an HTML version of the same content, for clients to
+// choose between.
+func (m *SMTPMailer) SendHTML(ctx context.Context, to string, subject string, text string, html string) error {
+	boundary := "nameslol-" + randomId()
+
+	var body strings.Builder
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		body.WriteString("--" + boundary + "\r\n")
+		body.WriteString("Content-Type: " + part.contentType + "\r\n")
+		body.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+		body.WriteString(crlf(part.content) + "\r\n")
+	}
+	body.WriteString("--" + boundary + "--\r\n")
+
+	return m.deliver(ctx, to, subject, "multipart/alternative; boundary=\""+boundary+"\"", body.String())
+}
+
+func (m *SMTPMailer) deliver(ctx context.Context, to string, subject string, contentType string, body string) error {
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient '%s'", to)
+	}
+
+	message, err := m.message(recipient.Address, subject, contentType, body)
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(smtpTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = conn.SetDeadline(deadline)
+
+	host, _, _ := net.SplitHostPort(m.addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err = client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if m.auth != nil {
+		if err = client.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(m.from); err != nil {
+		return err
+	}
+
+	if err = client.Rcpt(recipient.Address); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = writer.Write(message); err != nil {
+		return err
+	}
+
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (m *SMTPMailer) message(to string, subject string, contentType string, body string) ([]byte, error) {
+	if strings.ContainsAny(subject, "\r\n") {
+		return nil, fmt.Errorf("invalid subject")
+	}
+
+	var message bytes.Buffer
+	message.WriteString("From: " + m.from + "\r\n")
+	message.WriteString("To: " + to + "\r\n")
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	message.WriteString("Date: " + m.now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: " + contentType + "\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(body)
+
+	return message.Bytes(), nil
+}
+
+func crlf(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+}
@@ -0,0 +1,193 @@
+package shared
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStandIn is a minimal local SMTP server that accepts every message, or rejects every recipient when
+// RejectRecipients is set.
+type smtpStandIn struct {
+	listener         net.Listener
+	RejectRecipients bool
+	Recipients       []string
+	Messages         []string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not listen, %v", err)
+	}
+
+	server := &smtpStandIn{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			server.serve(conn)
+		}
+	}()
+
+	return server
+}
+
+func (s *smtpStandIn) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost stand-in")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM"):
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO"):
+			if s.RejectRecipients {
+				reply("550 No such user")
+				continue
+			}
+
+			s.Recipients = append(s.Recipients, strings.TrimSpace(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+
+			var message strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				if dataLine == ".\r\n" {
+					break
+				}
+				message.WriteString(dataLine)
+			}
+
+			s.Messages = append(s.Messages, message.String())
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func newTestMailer(addr string) *SMTPMailer {
+	mailer := NewSMTPMailer(addr, "alerts@names.lol", nil)
+	mailer.now = func() time.Time { return time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC) }
+	return mailer
+}
+
+func TestSMTPMailer_SendsThroughLocalServer(t *testing.T) {
+	server := newSMTPStandIn(t)
+
+	err := newTestMailer(server.Addr()).Send(context.TODO(), "Player <player@example.com>", "Namé is available", "line one\nline two")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(server.Recipients) != 1 || server.Recipients[0] != "<player@example.com>" {
+		t.Errorf("unexpected recipients %v", server.Recipients)
+	}
+
+	message := server.Messages[0]
+	if !strings.Contains(message, "Subject: =?utf-8?q?Nam=C3=A9_is_available?=\r\n") {
+		t.Errorf("expected an encoded subject, got %q", message)
+	}
+
+	if !strings.Contains(message, "To: player@example.com\r\n") || !strings.HasSuffix(message, "\r\n\r\nline one\r\nline two\r\n") {
+		t.Errorf("unexpected message %q", message)
+	}
+}
+
+func TestSMTPMailer_SendsHTMLWithPlainTextAlternative(t *testing.T) {
+	server := newSMTPStandIn(t)
+
+	err := newTestMailer(server.Addr()).SendHTML(context.TODO(), "player@example.com", "Digest", "plain\nbody", "<p>html</p>")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	message := server.Messages[0]
+	_, boundary, _ := strings.Cut(message, `Content-Type: multipart/alternative; boundary="`)
+	boundary, _, _ = strings.Cut(boundary, `"`)
+	if boundary == "" {
+		t.Fatalf("expected a multipart message, got %q", message)
+	}
+
+	parts := strings.Split(message, "--"+boundary)
+	if len(parts) != 4 || !strings.HasSuffix(parts[3], "--\r\n") ||
+		!strings.Contains(parts[1], "Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\nplain\r\nbody\r\n") ||
+		!strings.Contains(parts[2], "Content-Type: text/html; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n<p>html</p>\r\n") {
+		t.Errorf("unexpected parts %q", parts)
+	}
+}
+
+func TestSMTPMailer_RejectedRecipientIsPermanent(t *testing.T) {
+	server := newSMTPStandIn(t)
+	server.RejectRecipients = true
+
+	err := newTestMailer(server.Addr()).Send(context.TODO(), "player@example.com", "subject", "body")
+	if !isPermanentAlertError(err) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+}
+
+func TestSMTPMailer_RejectsHeaderInjection(t *testing.T) {
+	mailer := newTestMailer("127.0.0.1:1")
+
+	if err := mailer.Send(context.TODO(), "player@example.com\r\nBcc: x@example.com", "subject", "body"); err == nil {
+		t.Errorf("expected error for recipient, got nil")
+	}
+
+	if err := mailer.Send(context.TODO(), "player@example.com", "subject\r\nBcc: x@example.com", "body"); err == nil {
+		t.Errorf("expected error for subject, got nil")
+	}
+}
+
+func TestSMTPMailerFromEnv_ReadsPasswordSecret(t *testing.T) {
+	t.Setenv("SMTP_ADDR", "smtp.example.com:587")
+	t.Setenv("SMTP_FROM", "alerts@names.lol")
+	t.Setenv("SMTP_USERNAME", "user")
+	t.Setenv("SMTP_PASSWORD_NAME", "/smtp-password")
+
+	if _, err := SMTPMailerFromEnv(context.TODO(), StaticSecretsSource{}); err == nil {
+		t.Errorf("expected a missing secret to fail, got nil")
+	}
+
+	mailer, err := SMTPMailerFromEnv(context.TODO(), StaticSecretsSource{"/smtp-password": "secret"})
+	if err != nil || mailer.auth == nil {
+		t.Errorf("expected an authenticating mailer, got %+v, %v", mailer, err)
+	}
+
+	t.Setenv("SMTP_ADDR", "")
+	if _, err = SMTPMailerFromEnv(context.TODO(), StaticSecretsSource{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,106 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// matchRoutes maps each region to the regional route serving its match-v5 data.
+var matchRoutes = map[string]string{
+	"NA":   "americas",
+	"LAS":  "americas",
+	"EUW":  "europe",
+	"EUNE": "europe",
+	"OCE":  "sea",
+}
+
+type RiotMatchDTO struct {
+	Metadata RiotMatchMetadataDTO `json:"metadata"`
+	Info     RiotMatchInfoDTO     `json:"info"`
+}
+
+type RiotMatchMetadataDTO struct {
+	MatchId string `json:"matchId"`
+}
+
+type RiotMatchInfoDTO struct {
+	PlatformId   string               `json:"platformId"`
+	Participants []RiotParticipantDTO `json:"participants"`
+}
+
+type RiotParticipantDTO struct {
+	Puuid        string `json:"puuid"`
+	SummonerName string `json:"summonerName"`
+}
+
+func MatchRoute(region string) (string, error) {
+	if route, ok := matchRoutes[region]; ok {
+		return route, nil
+	}
+
+	return "", fmt.Errorf("region not found")
+}
+
+// FetchMatchIds returns the ids of a player's most recent matches, newest first.
+func (s *Summoners) FetchMatchIds(region string, puuid string, count int) ([]string, error) {
+	route, err := MatchRoute(region)
+	if err != nil {
+		return nil, err
+	}
+
+	requestUrl := fmt.Sprintf("https://%s.api.riotgames.com/lol/match/v5/matches/by-puuid/%s/ids?start=0&count=%d", route, url.PathEscape(puuid), count)
+
+	var ids []string
+	if err = s.riotGetJSON(route, "match-v5-ids-by-puuid", requestUrl, &ids); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (s *Summoners) FetchMatch(region string, matchId string) (*RiotMatchDTO, error) {
+	route, err := MatchRoute(region)
+	if err != nil {
+		return nil, err
+	}
+
+	requestUrl := fmt.Sprintf("https://%s.api.riotgames.com/lol/match/v5/matches/%s", route, url.PathEscape(matchId))
+
+	var match RiotMatchDTO
+	if err = s.riotGetJSON(route, "match-v5-match", requestUrl, &match); err != nil {
+		return nil, err
+	}
+
+	return &match, nil
+}
+
+func (s *Summoners) riotGetJSON(route string, method string, requestUrl string, v any) error {
+	resp, err := s.riotGet(route, method, requestUrl)
+	if err != nil {
+		return err
+	}
+
+	if resp.Body != nil {
+		defer resp.Body.Close()
+	}
+
+	if resp.StatusCode == 404 {
+		return fmt.Errorf("not found")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		responseBody := "(unknown)"
+		if resp.Body != nil {
+			if bodyBytes, err := io.ReadAll(resp.Body); err == nil {
+				responseBody = string(bodyBytes)
+			}
+		}
+
+		return fmt.Errorf("riot api returned status code %d with body %s", resp.StatusCode, responseBody)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
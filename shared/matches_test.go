@@ -0,0 +1,40 @@
+package shared
+
+import "testing"
+
+func TestMatchRoute_RoutesRegionsToTheirRegionalCluster(t *testing.T) {
+	tests := map[string]string{"NA": "americas", "LAS": "americas", "EUW": "europe", "EUNE": "europe", "OCE": "sea"}
+
+	for region, expected := range tests {
+		route, err := MatchRoute(region)
+		if err != nil || route != expected {
+			t.Errorf("%s: expected %s, got %s (%v)", region, expected, route, err)
+		}
+	}
+
+	if _, err := MatchRoute("BR"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestFetchMatchIds_CallsRegionalRoute(t *testing.T) {
+	setup()
+
+	_, _ = summoners.FetchMatchIds("EUW", "some/puuid", 5)
+
+	calls := summoners.http.(*MockHttpClient).Calls
+	expected := "https://europe.api.riotgames.com/lol/match/v5/matches/by-puuid/some%2Fpuuid/ids?start=0&count=5"
+	if len(calls) != 1 || calls[0].Request.URL.String() != expected {
+		t.Errorf("expected %s, got %v", expected, calls)
+	}
+}
+
+func TestFetchMatch_WhenHttpClientReturns404_ReturnsNotFound(t *testing.T) {
+	setup()
+	summoners.http.(*MockHttpClient).ShouldReturn404 = true
+
+	_, err := summoners.FetchMatch("NA", "NA1_1")
+	if err == nil || err.Error() != "not found" {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,427 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const migrationStateKey = "migration#state"
+
+// ItemRewriter returns a rewritten copy of an item and whether it changed.
+type ItemRewriter func(item map[string]types.AttributeValue) (map[string]types.AttributeValue, bool, error)
+
+// Migration rewrites summoner items one at a time. Rewrite must be idempotent: it is called again for
+// items it has already rewritten when a run is resumed, and must then report no change. Rewrite may
+// change the item's key, in which case the old item is deleted once the new one is written. Migrations
+// that index names write the postings of each rewritten item before the item, and need a name index.
+type Migration struct {
+	Version      int          `json:"version"`
+	Name         string       `json:"name"`
+	Description  string       `json:"description"`
+	IndexesNames bool         `json:"indexesNames,omitempty"`
+	Rewrite      ItemRewriter `json:"-"`
+}
+
+// Migrations is the ordered list of migrations for the summoners table. Append new migrations with the
+// next version number, and never reorder or remove applied ones.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Name:        "normalize-name-keys",
+		Description: "rewrite n and nl keys written before names were normalized",
+		Rewrite:     NormalizeNameKeys,
+	},
+	{
+		Version:     2,
+		Name:        "upgrade-items-to-v1",
+		Description: "add the item version and default the summoner icon on legacy items",
+		Rewrite:     UpgradeSummonerItem,
+	},
+	{
+		Version:     3,
+		Name:        "add-name-skeletons",
+		Description: "add the sk skeleton of each name that fuzzy search ranks look-alike names by",
+		Rewrite:     AddNameSkeleton,
+	},
+	{
+		Version:     4,
+		Name:        "add-quality-scores",
+		Description: "add the q quality score of each name that the summoners listing filters and sorts by",
+		Rewrite:     AddQualityScore,
+	},
+	{
+		Version:      5,
+		Name:         "index-names",
+		Description:  "write the search postings of names saved before search or never saved since, and mark them sx",
+		IndexesNames: true,
+		Rewrite:      MarkNameIndexed,
+	},
+}
+
+// MigrationState is stored as a single item in the table. Version is the last fully applied migration.
+// While a migration is running, Running holds its version and Cursor the key the scan will resume from.
+type MigrationState struct {
+	Key       string `dynamodbav:"n" json:"-"`
+	Version   int    `dynamodbav:"ver" json:"version"`
+	Running   int    `dynamodbav:"run,omitempty" json:"running,omitempty"`
+	Cursor    string `dynamodbav:"cur,omitempty" json:"cursor,omitempty"`
+	Scanned   int    `dynamodbav:"sc" json:"scanned"`
+	Rewritten int    `dynamodbav:"rw" json:"rewritten"`
+	Merged    int    `dynamodbav:"mg" json:"merged"`
+	UpdatedAt int64  `dynamodbav:"ua" json:"updatedAt"`
+}
+
+func (s *MigrationState) updatedAt() *int64 {
+	return &s.UpdatedAt
+}
+
+type MigrationResult struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	DryRun    bool   `json:"dryRun"`
+	Scanned   int    `json:"scanned"`
+	Rewritten int    `json:"rewritten"`
+	Merged    int    `json:"merged"`
+	Unchanged int    `json:"unchanged"`
+}
+
+// migrationUpdateTries bounds how often an item that keeps being saved while it is migrated is read and
+// rewritten again.
+const migrationUpdateTries = 3
+
+type migrationDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+type Migrator struct {
+	dynamodb   migrationDynamoDbService
+	index      nameIndex
+	tableName  string
+	migrations []Migration
+	pageSize   int32
+	now        func() time.Time
+}
+
+func NewMigrator(client migrationDynamoDbService, tableName string, migrations []Migration) *Migrator {
+	return &Migrator{
+		dynamodb:   client,
+		tableName:  tableName,
+		migrations: migrations,
+		pageSize:   500,
+		now:        time.Now,
+	}
+}
+
+// IndexNames sets the index migrations that index names write postings to.
+func (m *Migrator) IndexNames(index nameIndex) {
+	m.index = index
+}
+
+func (m *Migrator) Status(ctx context.Context) (*MigrationState, error) {
+	state := &MigrationState{Key: migrationStateKey}
+	return state, getSingleItem(ctx, m.dynamodb, m.tableName, migrationStateKey, state)
+}
+
+// Plan returns the migrations that have not been applied yet, in the order they will run.
+func (m *Migrator) Plan(ctx context.Context) ([]Migration, error) {
+	state, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.pending(state), nil
+}
+
+// Apply runs every pending migration up to and including target, or all of them when target is 0. A run
+// that was interrupted resumes from its last saved cursor. Dry runs scan and count without writing.
+func (m *Migrator) Apply(ctx context.Context, target int, dryRun bool) ([]*MigrationResult, error) {
+	state, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*MigrationResult
+	for _, migration := range m.pending(state) {
+		if target > 0 && migration.Version > target {
+			break
+		}
+
+		result, err := m.apply(ctx, state, migration, dryRun)
+		results = append(results, result)
+		if err != nil {
+			return results, fmt.Errorf("migration %d (%s) failed: %v", migration.Version, migration.Name, err)
+		}
+
+		if dryRun {
+			// Later migrations may depend on the writes this one would have made.
+			break
+		}
+	}
+
+	return results, nil
+}
+
+func (m *Migrator) pending(state *MigrationState) []Migration {
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if migration.Version > state.Version {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending
+}
+
+func (m *Migrator) apply(ctx context.Context, state *MigrationState, migration Migration, dryRun bool) (*MigrationResult, error) {
+	result := &MigrationResult{Version: migration.Version, Name: migration.Name, DryRun: dryRun}
+
+	var startKey map[string]types.AttributeValue
+	if state.Running == migration.Version && state.Cursor != "" {
+		log.Printf("resuming migration %d (%s) from '%s'", migration.Version, migration.Name, state.Cursor)
+		startKey = map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: state.Cursor}}
+	} else {
+		state.Running, state.Cursor, state.Scanned, state.Rewritten, state.Merged = migration.Version, "", 0, 0, 0
+	}
+
+	for {
+		output, err := m.dynamodb.Scan(ctx, &dynamodb.ScanInput{
+			TableName:         aws.String(m.tableName),
+			FilterExpression:  aws.String("attribute_exists(r)"),
+			ExclusiveStartKey: startKey,
+			Limit:             aws.Int32(m.pageSize),
+		})
+		if err != nil {
+			return result, err
+		}
+
+		pageRewritten, pageMerged := 0, 0
+		for _, item := range output.Items {
+			changed, merged, err := m.rewrite(ctx, migration, item, dryRun)
+			if err != nil {
+				return result, err
+			}
+
+			if !changed {
+				result.Unchanged++
+			} else if merged {
+				pageMerged++
+			} else {
+				pageRewritten++
+			}
+		}
+
+		result.Scanned += len(output.Items)
+		result.Rewritten += pageRewritten
+		result.Merged += pageMerged
+
+		if dryRun {
+			if len(output.LastEvaluatedKey) == 0 {
+				return result, nil
+			}
+
+			startKey = output.LastEvaluatedKey
+			continue
+		}
+
+		state.Scanned += len(output.Items)
+		state.Rewritten += pageRewritten
+		state.Merged += pageMerged
+
+		if len(output.LastEvaluatedKey) == 0 {
+			state.Version, state.Running, state.Cursor = migration.Version, 0, ""
+			return result, putSingleItem(ctx, m.dynamodb, m.tableName, state, state.UpdatedAt, m.now())
+		}
+
+		cursor, ok := output.LastEvaluatedKey["n"].(*types.AttributeValueMemberS)
+		if !ok {
+			return result, fmt.Errorf("scan returned a last evaluated key without 'n'")
+		}
+
+		state.Cursor = cursor.Value
+		if err = putSingleItem(ctx, m.dynamodb, m.tableName, state, state.UpdatedAt, m.now()); err != nil {
+			return result, err
+		}
+
+		startKey = output.LastEvaluatedKey
+	}
+}
+
+func (m *Migrator) rewrite(ctx context.Context, migration Migration, item map[string]types.AttributeValue, dryRun bool) (bool, bool, error) {
+	rewritten, changed, err := migration.Rewrite(item)
+	if err != nil || !changed || dryRun {
+		return changed, false, err
+	}
+
+	oldKey, _ := item["n"].(*types.AttributeValueMemberS)
+	newKey, ok := rewritten["n"].(*types.AttributeValueMemberS)
+	if oldKey == nil || !ok {
+		return true, false, fmt.Errorf("migration %d returned an item without a string 'n' attribute", migration.Version)
+	}
+
+	if migration.IndexesNames {
+		summoner, err := SummonerFromItem(rewritten)
+		if err != nil {
+			log.Printf("not indexing malformed summoner item '%s': %v", oldKey.Value, err)
+			return false, false, nil
+		}
+
+		if err = m.indexName(ctx, summoner); err != nil {
+			return true, false, err
+		}
+	}
+
+	if oldKey.Value == newKey.Value {
+		return true, false, m.updateInPlace(ctx, migration, item, rewritten)
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(m.tableName),
+		Item:      rewritten,
+	}
+
+	// A moved item must not overwrite a newer item already stored under the new key.
+	if item["ld"] != nil {
+		input.ConditionExpression = aws.String("attribute_not_exists(n) OR ld < :ld")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{":ld": item["ld"]}
+	} else {
+		input.ConditionExpression = aws.String("attribute_not_exists(n)")
+	}
+
+	merged := false
+	_, err = m.dynamodb.PutItem(ctx, input)
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		merged = true
+	} else if err != nil {
+		return true, false, err
+	}
+
+	_, err = m.dynamodb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(m.tableName),
+		Key:       map[string]types.AttributeValue{"n": oldKey},
+	})
+	if err != nil {
+		return true, merged, err
+	}
+
+	return true, merged, nil
+}
+
+// updateInPlace writes a rewrite that keeps the item's key. An item saved again since it was scanned is
+// read and rewritten again, so the migration never reverts a save or hide made while it runs.
+func (m *Migrator) updateInPlace(ctx context.Context, migration Migration, item map[string]types.AttributeValue, rewritten map[string]types.AttributeValue) error {
+	for try := 1; ; try++ {
+		err := m.updateChanged(ctx, item, rewritten)
+
+		var conditionErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionErr) {
+			return err
+		}
+
+		if try == migrationUpdateTries {
+			return fmt.Errorf("item '%s' kept changing while it was migrated", item["n"].(*types.AttributeValueMemberS).Value)
+		}
+
+		output, err := m.dynamodb.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(m.tableName),
+			Key:            map[string]types.AttributeValue{"n": item["n"]},
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil || output.Item == nil {
+			return err
+		}
+
+		var changed bool
+		item = output.Item
+		if rewritten, changed, err = migration.Rewrite(item); err != nil || !changed {
+			return err
+		}
+	}
+}
+
+// updateChanged sets the attributes a rewrite added or changed and removes those it dropped, on the
+// condition that the item was not saved since it was read.
+func (m *Migrator) updateChanged(ctx context.Context, item map[string]types.AttributeValue, rewritten map[string]types.AttributeValue) error {
+	attributes := make([]string, 0, len(rewritten))
+	for name := range rewritten {
+		attributes = append(attributes, name)
+	}
+	for name := range item {
+		if _, ok := rewritten[name]; !ok {
+			attributes = append(attributes, name)
+		}
+	}
+	sort.Strings(attributes)
+
+	var set, remove []string
+	names := make(map[string]string)
+	values := make(map[string]types.AttributeValue)
+	for i, name := range attributes {
+		placeholder := strconv.Itoa(i)
+		value, ok := rewritten[name]
+		if !ok {
+			names["#a"+placeholder] = name
+			remove = append(remove, "#a"+placeholder)
+		} else if !reflect.DeepEqual(item[name], value) {
+			names["#a"+placeholder] = name
+			values[":a"+placeholder] = value
+			set = append(set, "#a"+placeholder+" = :a"+placeholder)
+		}
+	}
+
+	if len(set) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	var expression []string
+	if len(set) > 0 {
+		expression = append(expression, "SET "+strings.Join(set, ", "))
+	}
+	if len(remove) > 0 {
+		expression = append(expression, "REMOVE "+strings.Join(remove, ", "))
+	}
+
+	condition := "attribute_exists(n) AND attribute_not_exists(ld)"
+	if ld, ok := item["ld"]; ok {
+		condition = "attribute_exists(n) AND ld = :scannedLd"
+		values[":scannedLd"] = ld
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                aws.String(m.tableName),
+		Key:                      map[string]types.AttributeValue{"n": item["n"]},
+		UpdateExpression:         aws.String(strings.Join(expression, " ")),
+		ConditionExpression:      aws.String(condition),
+		ExpressionAttributeNames: names,
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+
+	_, err := m.dynamodb.UpdateItem(ctx, input)
+	return err
+}
+
+// indexName writes the postings of a name before its item is marked indexed.
+func (m *Migrator) indexName(ctx context.Context, summoner *SummonerDTO) error {
+	if m.index == nil {
+		return fmt.Errorf("migration needs a name index")
+	}
+
+	return m.index.Index(ctx, summoner)
+}
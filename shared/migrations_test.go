@@ -0,0 +1,408 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MigrationTableMock is an in-memory table that supports the scans and conditional writes the
+// migrator makes.
+type MigrationTableMock struct {
+	Items        map[string]map[string]types.AttributeValue
+	ScanCalls    []*dynamodb.ScanInput
+	PutItemCalls []*dynamodb.PutItemInput
+	FailOnScan   int
+
+	UpdateItemCalls []*dynamodb.UpdateItemInput
+	// BeforeUpdate runs before each update is applied, standing in for a save made while it was in flight.
+	BeforeUpdate func(item map[string]types.AttributeValue)
+}
+
+func (m *MigrationTableMock) UpdateItem(_ context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	m.UpdateItemCalls = append(m.UpdateItemCalls, input)
+
+	key := input.Key["n"].(*types.AttributeValueMemberS).Value
+	existing, exists := m.Items[key]
+	if exists && m.BeforeUpdate != nil {
+		m.BeforeUpdate(existing)
+	}
+
+	var ok bool
+	switch *input.ConditionExpression {
+	case "attribute_exists(n) AND attribute_not_exists(ld)":
+		ok = exists && existing["ld"] == nil
+	case "attribute_exists(n) AND ld = :scannedLd":
+		ok = exists && existing["ld"] != nil && numberValue(existing["ld"]) == numberValue(input.ExpressionAttributeValues[":scannedLd"])
+	default:
+		return nil, fmt.Errorf("unsupported condition %s", *input.ConditionExpression)
+	}
+
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	setClause, removeClause, _ := strings.Cut(*input.UpdateExpression, "REMOVE ")
+	for _, assignment := range strings.Split(strings.TrimPrefix(strings.TrimSpace(setClause), "SET "), ", ") {
+		if name, value, ok := strings.Cut(assignment, " = "); ok {
+			existing[input.ExpressionAttributeNames[name]] = input.ExpressionAttributeValues[value]
+		}
+	}
+	for _, name := range strings.Split(removeClause, ", ") {
+		delete(existing, input.ExpressionAttributeNames[strings.TrimSpace(name)])
+	}
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (m *MigrationTableMock) GetItem(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.Items[input.Key["n"].(*types.AttributeValueMemberS).Value]}, nil
+}
+
+func (m *MigrationTableMock) PutItem(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.PutItemCalls = append(m.PutItemCalls, input)
+
+	key := input.Item["n"].(*types.AttributeValueMemberS).Value
+	existing, exists := m.Items[key]
+
+	if input.ConditionExpression != nil {
+		var ok bool
+		switch *input.ConditionExpression {
+		case "attribute_not_exists(n)":
+			ok = !exists
+		case "attribute_not_exists(n) OR ld < :ld":
+			ok = !exists || numberValue(existing["ld"]) < numberValue(input.ExpressionAttributeValues[":ld"])
+		case "attribute_not_exists(n) OR ua = :ua":
+			ok = !exists || numberValue(existing["ua"]) == numberValue(input.ExpressionAttributeValues[":ua"])
+		default:
+			return nil, fmt.Errorf("unsupported condition %s", *input.ConditionExpression)
+		}
+
+		if !ok {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+	}
+
+	m.Items[key] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *MigrationTableMock) DeleteItem(_ context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	delete(m.Items, input.Key["n"].(*types.AttributeValueMemberS).Value)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m *MigrationTableMock) Scan(_ context.Context, input *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	m.ScanCalls = append(m.ScanCalls, input)
+	if len(m.ScanCalls) == m.FailOnScan {
+		return nil, fmt.Errorf("error")
+	}
+
+	keys := make([]string, 0, len(m.Items))
+	for key := range m.Items {
+		if input.ExclusiveStartKey == nil || key > input.ExclusiveStartKey["n"].(*types.AttributeValueMemberS).Value {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	output := &dynamodb.ScanOutput{}
+	if input.Limit != nil && len(keys) > int(*input.Limit) {
+		keys = keys[:*input.Limit]
+		output.LastEvaluatedKey = map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: keys[len(keys)-1]}}
+	}
+
+	for _, key := range keys {
+		if m.Items[key]["r"] != nil {
+			output.Items = append(output.Items, m.Items[key])
+		}
+	}
+
+	return output, nil
+}
+
+func numberValue(value types.AttributeValue) int64 {
+	n, _ := strconv.ParseInt(value.(*types.AttributeValueMemberN).Value, 10, 64)
+	return n
+}
+
+func newTestMigrator(items ...map[string]types.AttributeValue) (*Migrator, *MigrationTableMock) {
+	mock := &MigrationTableMock{Items: make(map[string]map[string]types.AttributeValue)}
+	for _, item := range items {
+		mock.Items[item["n"].(*types.AttributeValueMemberS).Value] = item
+	}
+
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	migrator := NewMigrator(mock, "test-table", Migrations)
+	migrator.IndexNames(&NameIndexMock{})
+	migrator.now = func() time.Time {
+		clock.Advance(time.Millisecond)
+		return clock.Now()
+	}
+
+	return migrator, mock
+}
+
+func TestMigrator_AppliesPendingMigrationsInOrder(t *testing.T) {
+	migrator, mock := newTestMigrator(legacyItem("EUNE#HIDE ON BUSH", "EUNE#12"), legacyItem("EUNE#TEST", "EUNE#4"))
+
+	results, err := migrator.Apply(context.TODO(), 0, false)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(results) != 5 || results[0].Rewritten != 1 || results[0].Unchanged != 1 || results[1].Rewritten != 2 || results[2].Rewritten != 2 || results[3].Rewritten != 2 || results[4].Rewritten != 2 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	if _, ok := mock.Items["EUNE#HIDE ON BUSH"]; ok {
+		t.Errorf("expected old key to be deleted")
+	}
+
+	item := mock.Items["EUNE#HIDEONBUSH"]
+	if item == nil || item["v"].(*types.AttributeValueMemberN).Value != "1" || item["si"] == nil || item["sk"].(*types.AttributeValueMemberS).Value != "hideonbush" {
+		t.Errorf("expected upgraded item under the normalized key, got %v", item)
+	}
+
+	state, _ := migrator.Status(context.TODO())
+	if state.Version != 5 || state.Running != 0 || state.Cursor != "" {
+		t.Errorf("unexpected state %+v", state)
+	}
+}
+
+func TestMigrator_IsIdempotent(t *testing.T) {
+	migrator, mock := newTestMigrator(legacyItem("EUNE#HIDE ON BUSH", "EUNE#12"))
+	_, _ = migrator.Apply(context.TODO(), 0, false)
+	writes := len(mock.PutItemCalls)
+
+	results, err := migrator.Apply(context.TODO(), 0, false)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(results) != 0 || len(mock.PutItemCalls) != writes {
+		t.Errorf("expected nothing to run, got %v", results)
+	}
+}
+
+func TestMigrator_ResumesFromSavedCursor(t *testing.T) {
+	migrator, mock := newTestMigrator(legacyItem("EUNE#A A A", "EUNE#5"), legacyItem("EUNE#B B B", "EUNE#5"), legacyItem("EUNE#C C C", "EUNE#5"))
+	migrator.pageSize = 1
+	mock.FailOnScan = 2
+
+	_, err := migrator.Apply(context.TODO(), 0, false)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	state, _ := migrator.Status(context.TODO())
+	if state.Running != 1 || state.Cursor != "EUNE#A A A" || state.Scanned != 1 {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	_, err = migrator.Apply(context.TODO(), 0, false)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	resumed := mock.ScanCalls[2].ExclusiveStartKey
+	if resumed == nil || resumed["n"].(*types.AttributeValueMemberS).Value != "EUNE#A A A" {
+		t.Errorf("expected scan to resume from the cursor, got %v", resumed)
+	}
+
+	for _, key := range []string{"EUNE#AAA", "EUNE#BBB", "EUNE#CCC"} {
+		if mock.Items[key] == nil {
+			t.Errorf("expected %s to exist", key)
+		}
+	}
+}
+
+func TestMigrator_KeepsNewerItemWhenKeysCollide(t *testing.T) {
+	newer := legacyItem("EUNE#HIDEONBUSH", "EUNE#10")
+	newer["ld"] = &types.AttributeValueMemberN{Value: "999"}
+	migrator, mock := newTestMigrator(legacyItem("EUNE#HIDE ON BUSH", "EUNE#12"), newer)
+
+	results, err := migrator.Apply(context.TODO(), 1, false)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if results[0].Merged != 1 {
+		t.Errorf("expected 1 merged item, got %+v", results[0])
+	}
+
+	if mock.Items["EUNE#HIDEONBUSH"]["ld"].(*types.AttributeValueMemberN).Value != "999" {
+		t.Errorf("expected newer item to be kept")
+	}
+
+	if _, ok := mock.Items["EUNE#HIDE ON BUSH"]; ok {
+		t.Errorf("expected old key to be deleted")
+	}
+}
+
+func TestMigrator_KeepsHideMadeWhileRewriting(t *testing.T) {
+	migrator, mock := newTestMigrator(legacyItem("EUNE#TEST", "EUNE#4"))
+	mock.BeforeUpdate = func(item map[string]types.AttributeValue) {
+		item["h"] = &types.AttributeValueMemberBOOL{Value: true}
+	}
+
+	if _, err := migrator.Apply(context.TODO(), 0, false); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	item := mock.Items["EUNE#TEST"]
+	if item["h"] == nil || item["v"] == nil || item["sx"] == nil {
+		t.Errorf("expected the hide to be kept alongside the migrated attributes, got %v", item)
+	}
+}
+
+func TestMigrator_RewritesItemSavedWhileRewriting(t *testing.T) {
+	migrator, mock := newTestMigrator(legacyItem("EUNE#TEST", "EUNE#4"))
+	saves := 0
+	mock.BeforeUpdate = func(item map[string]types.AttributeValue) {
+		if saves == 0 {
+			item["ld"] = &types.AttributeValueMemberN{Value: "789"}
+			item["l"] = &types.AttributeValueMemberN{Value: "31"}
+		}
+		saves++
+	}
+
+	if _, err := migrator.Apply(context.TODO(), 2, false); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	item := mock.Items["EUNE#TEST"]
+	if numberValue(item["ld"]) != 789 || numberValue(item["l"]) != 31 || item["v"] == nil {
+		t.Errorf("expected the save to be kept and the item upgraded, got %v", item)
+	}
+
+	if len(mock.UpdateItemCalls) != 2 {
+		t.Errorf("expected the update to be retried once, got %d updates", len(mock.UpdateItemCalls))
+	}
+}
+
+func TestMigrator_FailsWhenItemKeepsChanging(t *testing.T) {
+	migrator, mock := newTestMigrator(legacyItem("EUNE#TEST", "EUNE#4"))
+	mock.BeforeUpdate = func(item map[string]types.AttributeValue) {
+		item["ld"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(numberValue(item["ld"])+1, 10)}
+	}
+
+	if _, err := migrator.Apply(context.TODO(), 2, false); err == nil || !strings.Contains(err.Error(), "kept changing") {
+		t.Errorf("expected error, got %v", err)
+	}
+}
+
+func TestMigrator_StopsAtTargetVersion(t *testing.T) {
+	migrator, _ := newTestMigrator(legacyItem("EUNE#TEST", "EUNE#4"))
+
+	results, _ := migrator.Apply(context.TODO(), 1, false)
+	if len(results) != 1 {
+		t.Errorf("expected 1 result, got %d", len(results))
+	}
+
+	pending, err := migrator.Plan(context.TODO())
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(pending) != 4 || pending[0].Version != 2 {
+		t.Errorf("expected migrations 2 to 5 to be pending, got %v", pending)
+	}
+}
+
+func TestMigrator_IndexesNamesOnce(t *testing.T) {
+	migrator, mock := newTestMigrator(legacyItem("EUNE#HIDE ON BUSH", "EUNE#12"))
+	index := &NameIndexMock{}
+	migrator.IndexNames(index)
+
+	if _, err := migrator.Apply(context.TODO(), 0, false); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(index.Indexed) != 1 || index.Indexed[0] != "EUNE#hideonbush@123" {
+		t.Errorf("expected the name to be indexed once, got %v", index.Indexed)
+	}
+
+	if sx, ok := mock.Items["EUNE#HIDEONBUSH"]["sx"].(*types.AttributeValueMemberN); !ok || sx.Value != strconv.Itoa(searchIndexVersion) {
+		t.Errorf("expected the item to be marked indexed, got %v", mock.Items["EUNE#HIDEONBUSH"])
+	}
+
+	if changed, _, _ := migrator.rewrite(context.TODO(), Migrations[4], mock.Items["EUNE#HIDEONBUSH"], false); changed || len(index.Indexed) != 1 {
+		t.Errorf("expected an indexed name not to be indexed again, got %v", index.Indexed)
+	}
+}
+
+func TestMigrator_FailsWhenNamesCannotBeIndexed(t *testing.T) {
+	migrator, mock := newTestMigrator(legacyItem("EUNE#TEST", "EUNE#4"))
+	migrator.IndexNames(&NameIndexMock{ShouldFail: true})
+
+	if _, err := migrator.Apply(context.TODO(), 0, false); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if _, ok := mock.Items["EUNE#TEST"]["sx"]; ok {
+		t.Errorf("expected the item not to be marked indexed when indexing fails")
+	}
+
+	migrator.IndexNames(nil)
+	if _, err := migrator.Apply(context.TODO(), 0, false); err == nil {
+		t.Errorf("expected an error without a name index")
+	}
+}
+
+func TestMigrator_DoesNotWriteOnDryRun(t *testing.T) {
+	migrator, mock := newTestMigrator(legacyItem("EUNE#HIDE ON BUSH", "EUNE#12"))
+
+	results, err := migrator.Apply(context.TODO(), 0, true)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(results) != 1 || results[0].Rewritten != 1 || !results[0].DryRun {
+		t.Errorf("unexpected results %v", results)
+	}
+
+	if len(mock.PutItemCalls) != 0 {
+		t.Errorf("expected no writes on dry run, got %d", len(mock.PutItemCalls))
+	}
+}
+
+func TestMigrator_FailsWhenStateChangedByAnotherRun(t *testing.T) {
+	migrator, mock := newTestMigrator(legacyItem("EUNE#A A A", "EUNE#5"), legacyItem("EUNE#B B B", "EUNE#5"))
+	migrator.pageSize = 1
+
+	mock.Items[migrationStateKey] = map[string]types.AttributeValue{
+		"n":   &types.AttributeValueMemberS{Value: migrationStateKey},
+		"ver": &types.AttributeValueMemberN{Value: "0"},
+		"ua":  &types.AttributeValueMemberN{Value: "1"},
+	}
+
+	migrator.dynamodb = &stateStealingTable{MigrationTableMock: mock}
+
+	_, err := migrator.Apply(context.TODO(), 0, false)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+// stateStealingTable simulates another run updating the state item after it was read.
+type stateStealingTable struct {
+	*MigrationTableMock
+}
+
+func (s *stateStealingTable) GetItem(ctx context.Context, input *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	output, err := s.MigrationTableMock.GetItem(ctx, input, optFns...)
+	s.Items[migrationStateKey] = map[string]types.AttributeValue{
+		"n":   &types.AttributeValueMemberS{Value: migrationStateKey},
+		"ver": &types.AttributeValueMemberN{Value: "0"},
+		"ua":  &types.AttributeValueMemberN{Value: "2"},
+	}
+
+	return output, err
+}
@@ -0,0 +1,80 @@
+package shared
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"time"
+)
+
+// Name event types. A name is available once Riot no longer knows it, taken when a name that was available
+// or held by another account is saved again, and date changed when its holder's availability date moves.
+const (
+	EventNameAvailable   = "name.available"
+	EventNameDateChanged = "name.date_changed"
+	EventNameTaken       = "name.taken"
+)
+
+var nameEventTypes = []string{EventNameAvailable, EventNameDateChanged, EventNameTaken}
+
+type eventPublisher interface {
+	Publish(ctx context.Context, event *NameEvent) error
+}
+
+type NameEvent struct {
+	ID                       string `json:"id"`
+	Type                     string `json:"type"`
+	CreatedAt                int64  `json:"createdAt"`
+	Region                   string `json:"region"`
+	Name                     string `json:"name"`
+	AvailabilityDate         int64  `json:"availabilityDate,omitempty"`
+	PreviousAvailabilityDate int64  `json:"previousAvailabilityDate,omitempty"`
+}
+
+func NewNameEvent(eventType string, region string, name string) *NameEvent {
+	return &NameEvent{
+		ID:        randomId(),
+		Type:      eventType,
+		CreatedAt: time.Now().UnixMilli(),
+		Region:    region,
+		Name:      name,
+	}
+}
+
+// nameEventFromSave returns the event a save observed given the item it replaced, or nil if it observed
+// none. Names saved for the first time raise no event, since they were only discovered, not taken.
+func nameEventFromSave(old map[string]types.AttributeValue, summoner *SummonerDTO, now time.Time) *NameEvent {
+	if len(old) == 0 {
+		return nil
+	}
+
+	var previous summonerItem
+	if err := attributevalue.UnmarshalMap(old, &previous); err != nil {
+		return nil
+	}
+
+	changedHands := previous.AccountID != "" && summoner.AccountID != "" && previous.AccountID != summoner.AccountID
+	wasAvailable := previous.AvailabilityDate <= now.UnixMilli() && summoner.AvailabilityDate > now.UnixMilli()
+
+	var event *NameEvent
+	switch {
+	case changedHands || wasAvailable:
+		event = NewNameEvent(EventNameTaken, summoner.Region, summoner.Name)
+	case previous.AvailabilityDate != summoner.AvailabilityDate:
+		event = NewNameEvent(EventNameDateChanged, summoner.Region, summoner.Name)
+	default:
+		return nil
+	}
+
+	event.AvailabilityDate = summoner.AvailabilityDate
+	event.PreviousAvailabilityDate = previous.AvailabilityDate
+	return event
+}
+
+func randomId() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
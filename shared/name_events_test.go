@@ -0,0 +1,49 @@
+package shared
+
+import (
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"testing"
+	"time"
+)
+
+func previousItem(t *testing.T, accountId string, availabilityDate time.Time) *summonerItem {
+	t.Helper()
+	return &summonerItem{Key: "NA#NAME", Region: "NA", AccountID: accountId, AvailabilityDate: availabilityDate.UnixMilli()}
+}
+
+func TestNameEventFromSave(t *testing.T) {
+	now := time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)
+	later := now.Add(30 * 24 * time.Hour)
+	summoner := &SummonerDTO{Name: "Name", Region: "NA", AccountID: "aid", AvailabilityDate: later.UnixMilli()}
+
+	tests := map[string]struct {
+		previous *summonerItem
+		expected string
+	}{
+		"first save":           {nil, ""},
+		"unchanged":            {previousItem(t, "aid", later), ""},
+		"date changed":         {previousItem(t, "aid", now.Add(time.Hour)), EventNameDateChanged},
+		"was available":        {previousItem(t, "aid", now.Add(-time.Hour)), EventNameTaken},
+		"held by another user": {previousItem(t, "other", later), EventNameTaken},
+	}
+
+	for test, tt := range tests {
+		var old map[string]types.AttributeValue
+		if tt.previous != nil {
+			old, _ = attributevalue.MarshalMap(tt.previous)
+		}
+
+		event := nameEventFromSave(old, summoner, now)
+		if tt.expected == "" {
+			if event != nil {
+				t.Errorf("%s: expected no event, got %+v", test, event)
+			}
+			continue
+		}
+
+		if event == nil || event.Type != tt.expected || event.AvailabilityDate != later.UnixMilli() || event.PreviousAvailabilityDate != tt.previous.AvailabilityDate {
+			t.Errorf("%s: expected %s event, got %+v", test, tt.expected, event)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package shared
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+)
+
+// NormalizeNameKeys returns a copy of a summoner item with its n and nl keys recomputed using
+// NormalizeName and NameLength, and whether either key changed. The length is taken from the item's display
+// name when it has one.
+func NormalizeNameKeys(item map[string]types.AttributeValue) (map[string]types.AttributeValue, bool, error) {
+	key, ok := item["n"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, false, fmt.Errorf("item has no string 'n' attribute")
+	}
+
+	region, name, ok := strings.Cut(key.Value, "#")
+	if !ok {
+		return nil, false, fmt.Errorf("item key '%s' is not in the region#name format", key.Value)
+	}
+
+	newKey := NameKey(region, name)
+	displayName := name
+	if dn, ok := item["dn"].(*types.AttributeValueMemberS); ok && dn.Value != "" {
+		displayName = dn.Value
+	}
+	newLengthKey := NameLengthKey(region, displayName)
+
+	lengthKey, _ := item["nl"].(*types.AttributeValueMemberS)
+	if newKey == key.Value && lengthKey != nil && newLengthKey == lengthKey.Value {
+		return item, false, nil
+	}
+
+	rewritten := make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		rewritten[k] = v
+	}
+
+	rewritten["n"] = &types.AttributeValueMemberS{Value: newKey}
+	rewritten["nl"] = &types.AttributeValueMemberS{Value: newLengthKey}
+	return rewritten, true, nil
+}
@@ -0,0 +1,86 @@
+package shared
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"testing"
+)
+
+func legacyItem(key string, lengthKey string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"n":   &types.AttributeValueMemberS{Value: key},
+		"r":   &types.AttributeValueMemberS{Value: "EUNE"},
+		"nl":  &types.AttributeValueMemberS{Value: lengthKey},
+		"ad":  &types.AttributeValueMemberN{Value: "123"},
+		"ld":  &types.AttributeValueMemberN{Value: "456"},
+		"rd":  &types.AttributeValueMemberN{Value: "100"},
+		"l":   &types.AttributeValueMemberN{Value: "30"},
+		"aid": &types.AttributeValueMemberS{Value: "aid"},
+	}
+}
+
+func TestNormalizeNameKeys_RecomputesByteBasedLength(t *testing.T) {
+	item, changed, err := NormalizeNameKeys(legacyItem("EUNE#ПРИВЕТ", "EUNE#12"))
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !changed {
+		t.Errorf("expected item to change")
+	}
+
+	if item["nl"].(*types.AttributeValueMemberS).Value != "EUNE#6" {
+		t.Errorf("expected EUNE#6, got %s", item["nl"].(*types.AttributeValueMemberS).Value)
+	}
+
+	if item["n"].(*types.AttributeValueMemberS).Value != "EUNE#ПРИВЕТ" {
+		t.Errorf("expected EUNE#ПРИВЕТ, got %s", item["n"].(*types.AttributeValueMemberS).Value)
+	}
+}
+
+func TestNormalizeNameKeys_RemovesSpacesFromKey(t *testing.T) {
+	item, changed, _ := NormalizeNameKeys(legacyItem("EUNE#HIDE ON BUSH", "EUNE#12"))
+
+	if !changed {
+		t.Errorf("expected item to change")
+	}
+
+	if item["n"].(*types.AttributeValueMemberS).Value != "EUNE#HIDEONBUSH" {
+		t.Errorf("expected EUNE#HIDEONBUSH, got %s", item["n"].(*types.AttributeValueMemberS).Value)
+	}
+
+	if item["nl"].(*types.AttributeValueMemberS).Value != "EUNE#10" {
+		t.Errorf("expected EUNE#10, got %s", item["nl"].(*types.AttributeValueMemberS).Value)
+	}
+}
+
+func TestNormalizeNameKeys_LeavesNormalizedItemsUnchanged(t *testing.T) {
+	_, changed, err := NormalizeNameKeys(legacyItem("EUNE#TEST", "EUNE#4"))
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if changed {
+		t.Errorf("expected item to be unchanged")
+	}
+}
+
+func TestNormalizeNameKeys_ReturnsErrorForMalformedKey(t *testing.T) {
+	_, _, err := NormalizeNameKeys(legacyItem("TEST", "EUNE#4"))
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestNormalizeNameKeys_TakesLengthFromDisplayName(t *testing.T) {
+	item := legacyItem("EUNE#STRASSE", "EUNE#7")
+	item["dn"] = &types.AttributeValueMemberS{Value: "Straße"}
+
+	rewritten, changed, err := NormalizeNameKeys(item)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !changed || rewritten["nl"].(*types.AttributeValueMemberS).Value != "EUNE#6" {
+		t.Errorf("expected EUNE#6, got %s", rewritten["nl"].(*types.AttributeValueMemberS).Value)
+	}
+}
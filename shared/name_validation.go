@@ -0,0 +1,135 @@
+package shared
+
+import (
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, fieldError := range v {
+		messages[i] = fmt.Sprintf("'%s' %s", fieldError.Field, fieldError.Message)
+	}
+
+	return strings.Join(messages, ", ")
+}
+
+type NameRules struct {
+	MinLength int
+	MaxLength int
+	Allowed   []*unicode.RangeTable
+}
+
+var basicLatinNameCharacters = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: ' ', Hi: ' ', Stride: 1},
+		{Lo: '.', Hi: '.', Stride: 1},
+		{Lo: '0', Hi: '9', Stride: 1},
+		{Lo: 'A', Hi: 'Z', Stride: 1},
+		{Lo: '_', Hi: '_', Stride: 1},
+		{Lo: 'a', Hi: 'z', Stride: 1},
+	},
+	LatinOffset: 6,
+}
+
+var latin1NameCharacters = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00c0, Hi: 0x00d6, Stride: 1},
+		{Lo: 0x00d8, Hi: 0x00f6, Stride: 1},
+		{Lo: 0x00f8, Hi: 0x00ff, Stride: 1},
+	},
+}
+
+var latinExtendedANameCharacters = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x0100, Hi: 0x017f, Stride: 1},
+	},
+}
+
+var greekNameCharacters = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x0386, Hi: 0x0386, Stride: 1},
+		{Lo: 0x0388, Hi: 0x038a, Stride: 1},
+		{Lo: 0x038c, Hi: 0x038c, Stride: 1},
+		{Lo: 0x038e, Hi: 0x03a1, Stride: 1},
+		{Lo: 0x03a3, Hi: 0x03ce, Stride: 1},
+	},
+}
+
+type NameValidator struct {
+	rules map[string]NameRules
+}
+
+// NewNameValidator applies the character classes Riot accepts in summoner names on each platform. NA
+// and OCE only allow basic Latin, EUW and LAS add Western European accents, and EUNE also allows Central
+// European and Greek letters. All regions allow 3 to 16 characters.
+func NewNameValidator() *NameValidator {
+	westernEuropean := []*unicode.RangeTable{basicLatinNameCharacters, latin1NameCharacters}
+	easternEuropean := []*unicode.RangeTable{basicLatinNameCharacters, latin1NameCharacters, latinExtendedANameCharacters, greekNameCharacters}
+
+	return &NameValidator{
+		rules: map[string]NameRules{
+			"NA":   {MinLength: 3, MaxLength: 16, Allowed: []*unicode.RangeTable{basicLatinNameCharacters}},
+			"OCE":  {MinLength: 3, MaxLength: 16, Allowed: []*unicode.RangeTable{basicLatinNameCharacters}},
+			"EUW":  {MinLength: 3, MaxLength: 16, Allowed: westernEuropean},
+			"LAS":  {MinLength: 3, MaxLength: 16, Allowed: westernEuropean},
+			"EUNE": {MinLength: 3, MaxLength: 16, Allowed: easternEuropean},
+		},
+	}
+}
+
+func (v *NameValidator) Validate(region string, name string) ValidationErrors {
+	rules, ok := v.rules[region]
+	if !ok {
+		return ValidationErrors{{Field: "region", Message: "must be one of the supported regions"}}
+	}
+
+	var errs ValidationErrors
+
+	name = norm.NFC.String(name)
+	if strings.TrimSpace(name) != name {
+		errs = append(errs, FieldError{Field: "name", Message: "must not start or end with a space"})
+	}
+
+	length := utf8.RuneCountInString(name)
+	if length < rules.MinLength {
+		errs = append(errs, FieldError{Field: "name", Message: fmt.Sprintf("must be at least %d characters", rules.MinLength)})
+	}
+
+	if length > rules.MaxLength {
+		errs = append(errs, FieldError{Field: "name", Message: fmt.Sprintf("must be at most %d characters", rules.MaxLength)})
+	}
+
+	var invalid []string
+	for _, r := range name {
+		if !unicode.IsOneOf(rules.Allowed, r) && !containsString(invalid, string(r)) {
+			invalid = append(invalid, string(r))
+		}
+	}
+
+	if len(invalid) > 0 {
+		errs = append(errs, FieldError{Field: "name", Message: fmt.Sprintf("contains characters not allowed in %s: %s", region, strings.Join(invalid, " "))})
+	}
+
+	return errs
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
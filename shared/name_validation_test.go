@@ -0,0 +1,101 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNameValidator_AcceptsValidNames(t *testing.T) {
+	validator := NewNameValidator()
+
+	names := map[string]string{
+		"NA":   "Hide on bush",
+		"OCE":  "test_name.1",
+		"EUW":  "Ëlodie",
+		"LAS":  "Niño Señor",
+		"EUNE": "Łukasz Ωμέγα",
+	}
+
+	for region, name := range names {
+		if errs := validator.Validate(region, name); len(errs) != 0 {
+			t.Errorf("expected '%s' to be valid in %s, got %v", name, region, errs)
+		}
+	}
+}
+
+func TestNameValidator_CountsCharactersNotBytes(t *testing.T) {
+	validator := NewNameValidator()
+
+	name := "ÀÁÂÃÄÅÆÇÈÉÊËÌÍÎÏ"
+	if len(name) <= 16 {
+		t.Fatalf("expected test name to be longer than 16 bytes")
+	}
+
+	if errs := validator.Validate("EUW", name); len(errs) != 0 {
+		t.Errorf("expected nil, got %v", errs)
+	}
+}
+
+func TestNameValidator_ValidatesLength(t *testing.T) {
+	validator := NewNameValidator()
+
+	errs := validator.Validate("NA", "ab")
+	if len(errs) != 1 || errs[0].Field != "name" || errs[0].Message != "must be at least 3 characters" {
+		t.Errorf("expected too short error, got %v", errs)
+	}
+
+	errs = validator.Validate("NA", "abcdefghijklmnopq")
+	if len(errs) != 1 || errs[0].Message != "must be at most 16 characters" {
+		t.Errorf("expected too long error, got %v", errs)
+	}
+}
+
+func TestNameValidator_RejectsCharactersNotAllowedInRegion(t *testing.T) {
+	validator := NewNameValidator()
+
+	errs := validator.Validate("NA", "Ëlodie")
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "Ë") {
+		t.Errorf("expected invalid character error, got %v", errs)
+	}
+
+	errs = validator.Validate("EUW", "Łukasz")
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "Ł") {
+		t.Errorf("expected invalid character error, got %v", errs)
+	}
+}
+
+func TestNameValidator_RejectsUrlCharacters(t *testing.T) {
+	validator := NewNameValidator()
+
+	for _, name := range []string{"a/b/c", "abc?d", "abc#d", "100%"} {
+		if errs := validator.Validate("NA", name); len(errs) == 0 {
+			t.Errorf("expected '%s' to be invalid", name)
+		}
+	}
+}
+
+func TestNameValidator_RejectsLeadingAndTrailingSpaces(t *testing.T) {
+	validator := NewNameValidator()
+
+	errs := validator.Validate("NA", " Test")
+	if len(errs) != 1 || errs[0].Message != "must not start or end with a space" {
+		t.Errorf("expected space error, got %v", errs)
+	}
+}
+
+func TestNameValidator_RejectsUnknownRegion(t *testing.T) {
+	validator := NewNameValidator()
+
+	errs := validator.Validate("KR", "Test")
+	if len(errs) != 1 || errs[0].Field != "region" {
+		t.Errorf("expected region error, got %v", errs)
+	}
+}
+
+func TestValidationErrors_Error(t *testing.T) {
+	errs := ValidationErrors{{Field: "name", Message: "is bad"}, {Field: "region", Message: "is worse"}}
+
+	if errs.Error() != "'name' is bad, 'region' is worse" {
+		t.Errorf("expected joined message, got %s", errs.Error())
+	}
+}
@@ -0,0 +1,41 @@
+package shared
+
+import (
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var nameFolder = cases.Fold()
+
+// NormalizeName applies Riot's name normalization: whitespace is ignored and letters are case-folded,
+// so names that Riot treats as the same account name normalize to the same string.
+func NormalizeName(name string) string {
+	return norm.NFC.String(nameFolder.String(norm.NFC.String(removeSpaces(name))))
+}
+
+// NameLength counts the characters, not bytes, of a name as it is displayed, ignoring whitespace. Names
+// are not case-folded first since folding can change their length, "ß" folding to "ss".
+func NameLength(name string) int {
+	return utf8.RuneCountInString(norm.NFC.String(removeSpaces(name)))
+}
+
+func removeSpaces(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, name)
+}
+
+func NameKey(region string, name string) string {
+	return region + "#" + strings.ToUpper(NormalizeName(name))
+}
+
+func NameLengthKey(region string, name string) string {
+	return region + "#" + strconv.Itoa(NameLength(name))
+}
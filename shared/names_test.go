@@ -0,0 +1,67 @@
+package shared
+
+import "testing"
+
+func TestNormalizeName_RemovesWhitespace(t *testing.T) {
+	if NormalizeName(" Hide on  bush ") != "hideonbush" {
+		t.Errorf("expected hideonbush, got %s", NormalizeName(" Hide on  bush "))
+	}
+}
+
+func TestNormalizeName_CaseFoldsUnicode(t *testing.T) {
+	tests := map[string]string{
+		"ÄÖÜ":     "äöü",
+		"ΣΟΦΊΑ":   "σοφία",
+		"Привет":  "привет",
+		"Straße":  "strasse",
+		"페이커":     "페이커",
+		"ﬁre":     "fire",
+		"ŞÉÑÕR 1": "şéñõr1",
+	}
+
+	for input, expected := range tests {
+		if actual := NormalizeName(input); actual != expected {
+			t.Errorf("expected %s for %s, got %s", expected, input, actual)
+		}
+	}
+}
+
+func TestNormalizeName_ComposesDecomposedCharacters(t *testing.T) {
+	if NormalizeName("José") != "josé" {
+		t.Errorf("expected composed é, got %q", NormalizeName("José"))
+	}
+}
+
+func TestNameLength_CountsCharactersNotBytes(t *testing.T) {
+	tests := map[string]int{
+		"test":      4,
+		"Ébène":     5,
+		"Привет":    6,
+		"페이커":       3,
+		"Σοφία":     5,
+		"hide bush": 8,
+		"José":     4,
+	}
+
+	for input, expected := range tests {
+		if actual := NameLength(input); actual != expected {
+			t.Errorf("expected %d for %s, got %d", expected, input, actual)
+		}
+	}
+}
+
+func TestNameKey_UsesRegionAndNormalizedName(t *testing.T) {
+	if NameKey("EUW", "Hide on bush") != "EUW#HIDEONBUSH" {
+		t.Errorf("expected EUW#HIDEONBUSH, got %s", NameKey("EUW", "Hide on bush"))
+	}
+
+	if NameKey("EUNE", "Привет") != NameKey("EUNE", "привет") {
+		t.Errorf("expected keys to match regardless of case")
+	}
+}
+
+func TestNameLengthKey_UsesRegionAndCharacterCount(t *testing.T) {
+	if NameLengthKey("EUNE", "Привет") != "EUNE#6" {
+		t.Errorf("expected EUNE#6, got %s", NameLengthKey("EUNE", "Привет"))
+	}
+}
@@ -0,0 +1,814 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Each pattern subscription is stored in its own item, keyed pattern#<region>#<id>. Once confirmed, it
+// carries its region in "pr", which keys the sparse index a region's subscriptions are listed from.
+// Unconfirmed subscriptions expire.
+const patternPrefix = "pattern#"
+
+const patternRegionIndex = "pattern-region-index"
+
+// patternContactPrefix keys the item listing a contact's subscriptions, which bounds how many they have.
+const patternContactPrefix = "pattern#contact#"
+
+const maxContactPatterns = 10
+
+const maxPatternLength = 100
+
+const (
+	defaultPatternHorizon = 24
+	maxPatternHorizon     = 30 * 24
+)
+
+// maxPatternDigestNames is how many names one sweep alert lists before summarising the rest.
+const maxPatternDigestNames = 50
+
+const (
+	PatternRegex = "regex"
+	PatternGlob  = "glob"
+)
+
+type patternsDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+}
+
+type patternSummonersService interface {
+	GetBetweenDateByNameLength(region string, limit int32, nameLength int, t1 int64, t2 int64) (*SummonersPage, error)
+}
+
+// PatternSubscription alerts a contact about any name in a region whose length is within MinLength and
+// MaxLength and which matches Pattern, a case-insensitive regular expression or glob, once it frees within
+// HorizonHours. An empty pattern matches every name. Email subscriptions stay Pending, and are not
+// alerted, until the contact confirms them. Through is the availability date up to which the subscription
+// has been swept.
+type PatternSubscription struct {
+	ID           string            `dynamodbav:"id" json:"id"`
+	Region       string            `dynamodbav:"r" json:"region"`
+	MinLength    int               `dynamodbav:"mn" json:"minLength"`
+	MaxLength    int               `dynamodbav:"mx" json:"maxLength"`
+	Kind         string            `dynamodbav:"k" json:"kind"`
+	Pattern      string            `dynamodbav:"p" json:"pattern"`
+	HorizonHours int               `dynamodbav:"hz" json:"horizonHours"`
+	Contact      string            `dynamodbav:"c" json:"contact"`
+	Push         *PushSubscription `dynamodbav:"ps,omitempty" json:"-"`
+	Token        string            `dynamodbav:"t" json:"-"`
+	CreatedAt    int64             `dynamodbav:"ca" json:"createdAt"`
+	Pending      bool              `dynamodbav:"-" json:"pending"`
+	Through      int64             `dynamodbav:"th,omitempty" json:"-"`
+	matcher      *regexp.Regexp
+}
+
+// patternItem stores one subscription. Region is only set once the subscription is confirmed, and Expiry,
+// in seconds, only until then.
+type patternItem struct {
+	Key          string               `dynamodbav:"n"`
+	Subscription *PatternSubscription `dynamodbav:"s"`
+	Region       string               `dynamodbav:"pr,omitempty"`
+	Expiry       int64                `dynamodbav:"ttl,omitempty"`
+}
+
+// patternContactItem maps the ids of a contact's subscriptions to the time, in milliseconds, they expire
+// unless confirmed, or to 0 once they are.
+type patternContactItem struct {
+	Key           string           `dynamodbav:"n"`
+	Subscriptions map[string]int64 `dynamodbav:"s"`
+	UpdatedAt     int64            `dynamodbav:"ua"`
+}
+
+func (i *patternContactItem) updatedAt() *int64 {
+	return &i.UpdatedAt
+}
+
+func patternKey(region string, id string) string {
+	return patternPrefix + region + "#" + id
+}
+
+type PatternSweepResult struct {
+	Region        string `json:"region"`
+	Subscriptions int    `json:"subscriptions"`
+	Checked       int    `json:"checked"`
+	Matched       int    `json:"matched"`
+	Sent          int    `json:"sent"`
+	Dropped       int    `json:"dropped"`
+}
+
+// Validate fills in the default length range, kind and horizon, and checks the subscription's region,
+// lengths and pattern.
+func (s *PatternSubscription) Validate() error {
+	if !NewRegions().Validate(s.Region) {
+		return fmt.Errorf("invalid region '%s'", s.Region)
+	}
+
+	if s.MinLength == 0 {
+		s.MinLength = 3
+	}
+	if s.MaxLength == 0 {
+		s.MaxLength = 16
+	}
+	if s.MinLength < 3 || s.MaxLength > 16 || s.MinLength > s.MaxLength {
+		return fmt.Errorf("invalid length range %d to %d, expected lengths between 3 and 16", s.MinLength, s.MaxLength)
+	}
+
+	if s.HorizonHours == 0 {
+		s.HorizonHours = defaultPatternHorizon
+	}
+	if s.HorizonHours < 0 || s.HorizonHours > maxPatternHorizon {
+		return fmt.Errorf("invalid horizon of %d hours, expected at most %d", s.HorizonHours, maxPatternHorizon)
+	}
+
+	if s.Kind == "" {
+		s.Kind = PatternRegex
+	}
+	if len(s.Pattern) > maxPatternLength {
+		return fmt.Errorf("pattern is longer than %d characters", maxPatternLength)
+	}
+
+	matcher, err := compilePattern(s.Kind, s.Pattern)
+	if err != nil {
+		return err
+	}
+
+	s.matcher = matcher
+	return nil
+}
+
+// compilePattern compiles a regular expression as is, and a glob, where * matches any characters and ? any
+// one character, into an anchored one. Both are matched against normalized names.
+func compilePattern(kind string, pattern string) (*regexp.Regexp, error) {
+	switch kind {
+	case PatternRegex:
+	case PatternGlob:
+		glob := regexp.QuoteMeta(NormalizeName(pattern))
+		pattern = "^" + strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(glob) + "$"
+	default:
+		return nil, fmt.Errorf("invalid kind '%s', expected %s or %s", kind, PatternRegex, PatternGlob)
+	}
+
+	compiled, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %v", pattern, err)
+	}
+
+	return compiled, nil
+}
+
+// Matches reports whether a name is within the subscription's length range and matches its pattern. The
+// subscription must have been validated or loaded.
+func (s *PatternSubscription) Matches(name string) bool {
+	length := NameLength(name)
+	if length < s.MinLength || length > s.MaxLength {
+		return false
+	}
+
+	return s.matcher != nil && s.matcher.MatchString(NormalizeName(name))
+}
+
+func (s *PatternSubscription) horizon() time.Duration {
+	return time.Duration(s.HorizonHours) * time.Hour
+}
+
+func (s *PatternSubscription) describe() string {
+	lengths := fmt.Sprintf("%d to %d characters", s.MinLength, s.MaxLength)
+	if s.MinLength == s.MaxLength {
+		lengths = fmt.Sprintf("%d characters", s.MinLength)
+	}
+
+	if s.Pattern == "" {
+		return fmt.Sprintf("names of %s in %s", lengths, s.Region)
+	}
+
+	return fmt.Sprintf("names of %s in %s matching the %s '%s'", lengths, s.Region, s.Kind, s.Pattern)
+}
+
+// PatternAlerter alerts pattern subscribers about matching names when the consumer saves or tombstones
+// them, and in scheduled sweeps of the names freeing within each subscriber's horizon.
+type PatternAlerter struct {
+	mu        sync.Mutex
+	dynamodb  patternsDynamoDbService
+	summoners patternSummonersService
+	alertChannels
+	confirmations  *Confirmations
+	tableName      string
+	unsubscribeUrl string
+	confirmUrl     string
+	pageSize       int32
+	ttl            time.Duration
+	cached         map[string][]*PatternSubscription
+	loadedAt       map[string]time.Time
+	now            func() time.Time
+}
+
+// NewPatternAlerter alerts pattern subscribers through the mailer, which may be nil when only push
+// notifications are sent. Alerts link to unsubscribeUrl, when set, with the subscription's region, id and
+// token as query parameters. Subscriptions are cached for a minute when checking saves.
+func NewPatternAlerter(client patternsDynamoDbService, tableName string, summoners patternSummonersService, mailer mailerService, unsubscribeUrl string) *PatternAlerter {
+	return &PatternAlerter{
+		dynamodb:       client,
+		summoners:      summoners,
+		alertChannels:  alertChannels{mailer: mailer},
+		tableName:      tableName,
+		unsubscribeUrl: unsubscribeUrl,
+		pageSize:       watchPageSize,
+		ttl:            time.Minute,
+		cached:         make(map[string][]*PatternSubscription),
+		loadedAt:       make(map[string]time.Time),
+		now:            time.Now,
+	}
+}
+
+// NewPatternAlerterFromEnv reads the unsubscribe link from PATTERN_UNSUBSCRIBE_URL and configures its
+// channels with alertChannelsFromEnv.
+func NewPatternAlerterFromEnv(ctx context.Context, client patternsDynamoDbService, tableName string, summoners patternSummonersService, secrets SecretsSource) (*PatternAlerter, error) {
+	channels, err := alertChannelsFromEnv(ctx, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	alerter := NewPatternAlerter(client, tableName, summoners, nil, os.Getenv("PATTERN_UNSUBSCRIBE_URL"))
+	alerter.alertChannels = channels
+	return alerter, nil
+}
+
+// ConfirmWith rate limits subscribes and sends new email subscribers a link to confirmUrl, with the
+// subscription's region, id and token as query parameters. Email subscriptions are only accepted once it
+// is set.
+func (a *PatternAlerter) ConfirmWith(confirmations *Confirmations, confirmUrl string) {
+	a.confirmations = confirmations
+	a.confirmUrl = confirmUrl
+}
+
+// Subscribe validates and saves a pattern subscription, returning it with its id and token. Email
+// subscriptions stay pending until confirmed with the link their contact is emailed. Push subscriptions
+// are active at once, since only the browser knows its endpoint, and are alerted with it as the contact.
+// Each contact may have maxContactPatterns subscriptions, counting pending ones.
+func (a *PatternAlerter) Subscribe(ctx context.Context, subscription PatternSubscription) (*PatternSubscription, error) {
+	if err := subscription.Validate(); err != nil {
+		return nil, err
+	}
+
+	if subscription.Push != nil {
+		if err := subscription.Push.Validate(); err != nil {
+			return nil, err
+		}
+		subscription.Contact = subscription.Push.Endpoint
+	} else if a.confirmations == nil {
+		return nil, errors.New("email subscriptions need confirmations, see ConfirmWith")
+	}
+
+	if a.confirmations != nil {
+		if err := a.confirmations.Allow(ctx, subscription.Contact); err != nil {
+			return nil, err
+		}
+	}
+
+	now := a.now()
+	subscription.ID = randomId()[:12]
+	subscription.Token = randomId()
+	subscription.CreatedAt = now.UnixMilli()
+	subscription.Pending = subscription.Push == nil
+	subscription.Through = 0
+
+	item := &patternItem{Key: patternKey(subscription.Region, subscription.ID), Subscription: &subscription}
+	if subscription.Pending {
+		item.Expiry = now.Add(confirmationTtl).Unix()
+	} else {
+		item.Region = subscription.Region
+	}
+
+	err := a.updateContact(ctx, subscription.Contact, func(contact *patternContactItem) error {
+		if len(contact.Subscriptions) >= maxContactPatterns {
+			return fmt.Errorf("too many pattern alerts for this contact")
+		}
+
+		contact.Subscriptions[subscription.ID] = item.Expiry * 1000
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = a.put(ctx, item); err != nil {
+		return nil, errors.Join(err, a.releaseContact(ctx, subscription.Contact, subscription.ID))
+	}
+
+	if !subscription.Pending {
+		a.invalidate(subscription.Region)
+		return &subscription, nil
+	}
+
+	query := url.Values{"region": {subscription.Region}, "pattern": {subscription.ID}, "token": {subscription.Token}}
+	return &subscription, a.confirmations.Send(ctx, subscription.Contact, subscription.describe(), a.confirmUrl, query)
+}
+
+// Confirm activates a pending subscription, provided the token matches the one its contact was sent and
+// the subscription has not expired. Confirming again has no effect.
+func (a *PatternAlerter) Confirm(ctx context.Context, region string, id string, token string) error {
+	if token == "" {
+		return fmt.Errorf("subscription not found")
+	}
+
+	output, err := a.dynamodb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(a.tableName),
+		Key:                 map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: patternKey(region, id)}},
+		UpdateExpression:    aws.String("SET pr = :pr REMOVE #ttl"),
+		ConditionExpression: aws.String("#s.#t = :token AND (attribute_not_exists(#ttl) OR #ttl > :now)"),
+		ExpressionAttributeNames: map[string]string{
+			"#s":   "s",
+			"#t":   "t",
+			"#ttl": "ttl",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pr":    &types.AttributeValueMemberS{Value: region},
+			":token": &types.AttributeValueMemberS{Value: token},
+			":now":   &types.AttributeValueMemberN{Value: strconv.FormatInt(a.now().Unix(), 10)},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return fmt.Errorf("subscription not found")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	a.invalidate(region)
+
+	item := &patternItem{}
+	if err = attributevalue.UnmarshalMap(output.Attributes, item); err != nil {
+		return err
+	}
+
+	return a.updateContact(ctx, item.Subscription.Contact, func(contact *patternContactItem) error {
+		contact.Subscriptions[id] = 0
+		return nil
+	})
+}
+
+// Unsubscribe removes a pattern subscription, provided the token matches the one its contact was sent.
+func (a *PatternAlerter) Unsubscribe(ctx context.Context, region string, id string, token string) error {
+	item, err := a.getItem(ctx, region, id)
+	if err != nil {
+		return err
+	}
+
+	if item == nil || token == "" || item.Subscription.Token != token {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return a.remove(ctx, item.Subscription)
+}
+
+// Publish alerts the subscribers a name event concerns: every matching subscriber once a name is freed,
+// and matching subscribers already swept past a name's new availability date when it changes, since their
+// next sweep would not see it.
+func (a *PatternAlerter) Publish(ctx context.Context, event *NameEvent) error {
+	if event.Type != EventNameAvailable && event.Type != EventNameDateChanged {
+		return nil
+	}
+
+	subscriptions, err := a.load(ctx, event.Region)
+	if err != nil {
+		return err
+	}
+
+	now := a.now().UnixMilli()
+	date := time.UnixMilli(event.AvailabilityDate).UTC().Format("Monday, January 2 at 15:04 MST")
+
+	var errs []error
+	for _, subscription := range subscriptions {
+		if !a.handles(subscription.Push) || !subscription.Matches(event.Name) {
+			continue
+		}
+
+		var subject, body string
+		switch {
+		case event.Type == EventNameAvailable:
+			subject = fmt.Sprintf("%s (%s) is now available", event.Name, event.Region)
+			body = fmt.Sprintf("The summoner name %s in %s has been freed and matches your alert for %s. Claim it in "+
+				"the League of Legends client before someone else does.\n%s", event.Name, event.Region, subscription.describe(), a.unsubscribeText(subscription))
+		case event.AvailabilityDate > now && event.AvailabilityDate <= subscription.Through:
+			subject = fmt.Sprintf("%s (%s) is becoming available", event.Name, event.Region)
+			body = fmt.Sprintf("The summoner name %s in %s, which matches your alert for %s, is now expected to become "+
+				"available on %s.\n%s", event.Name, event.Region, subscription.describe(), date, a.unsubscribeText(subscription))
+		default:
+			continue
+		}
+
+		err = a.send(ctx, subscription.Contact, subscription.Push, subject, body, alertPushPayload{
+			Region:         event.Region,
+			Name:           event.Name,
+			UnsubscribeUrl: a.unsubscribeLink(subscription),
+		})
+		if isPermanentAlertError(err) {
+			errs = append(errs, a.remove(ctx, subscription))
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("could not alert pattern subscriber %s of '%s': %v", subscription.ID, event.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Sweep sends each subscriber in a region one alert listing the matching names that became due within
+// their horizon since their last sweep. Subscribers are only swept forward once their alert is sent, and
+// the sweep stops at the first alert that fails.
+func (a *PatternAlerter) Sweep(ctx context.Context, region string) (*PatternSweepResult, error) {
+	result := &PatternSweepResult{Region: region}
+
+	listed, err := a.list(ctx, region)
+	if err != nil {
+		return result, err
+	}
+
+	now := a.now()
+	swept := make(map[string]int64)
+	var dropped []*PatternSubscription
+
+	subscriptions := make([]*PatternSubscription, 0, len(listed))
+	for _, subscription := range listed {
+		if subscription.Push != nil && subscription.Push.Expired(now) {
+			dropped = append(dropped, subscription)
+			continue
+		}
+
+		if a.handles(subscription.Push) {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	result.Subscriptions = len(subscriptions)
+
+	matches, err := a.sweepMatches(region, subscriptions, now, result)
+	if err == nil {
+		for _, subscription := range subscriptions {
+			to := now.Add(subscription.horizon()).UnixMilli()
+
+			err = a.sendDigest(ctx, subscription, matches[subscription.ID])
+			if isPermanentAlertError(err) {
+				dropped = append(dropped, subscription)
+				err = nil
+				continue
+			}
+
+			if err != nil {
+				err = fmt.Errorf("could not alert pattern subscriber %s: %v", subscription.ID, err)
+				break
+			}
+
+			if len(matches[subscription.ID]) > 0 {
+				result.Sent++
+			}
+			swept[subscription.ID] = to
+		}
+	}
+	result.Dropped = len(dropped)
+
+	errs := []error{err}
+	for _, subscription := range subscriptions {
+		if through, ok := swept[subscription.ID]; ok {
+			errs = append(errs, a.setThrough(ctx, region, subscription.ID, through))
+		}
+	}
+
+	for _, subscription := range dropped {
+		errs = append(errs, a.remove(ctx, subscription))
+	}
+
+	if len(swept) > 0 || len(dropped) > 0 {
+		a.invalidate(region)
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// sweepMatches queries each name length the subscriptions cover once, from the earliest date any of them
+// was swept through to the furthest horizon, and returns the matches of each subscription by id.
+func (a *PatternAlerter) sweepMatches(region string, subscriptions []*PatternSubscription, now time.Time, result *PatternSweepResult) (map[string][]*SummonerDTO, error) {
+	matches := make(map[string][]*SummonerDTO)
+
+	for length := 3; length <= 16; length++ {
+		var covering []*PatternSubscription
+		from, to := int64(-1), int64(0)
+		for _, subscription := range subscriptions {
+			if length < subscription.MinLength || length > subscription.MaxLength {
+				continue
+			}
+
+			covering = append(covering, subscription)
+			start := max(subscription.Through, now.UnixMilli()) + 1
+			if from == -1 || start < from {
+				from = start
+			}
+			to = max(to, now.Add(subscription.horizon()).UnixMilli())
+		}
+
+		seen := make(map[string]bool)
+		for len(covering) > 0 && from <= to {
+			page, err := a.summoners.GetBetweenDateByNameLength(region, a.pageSize, length, from, to)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, summoner := range page.Summoners {
+				key := NameKey(region, summoner.Name)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				result.Checked++
+
+				matched := false
+				for _, subscription := range covering {
+					if summoner.AvailabilityDate > max(subscription.Through, now.UnixMilli()) &&
+						summoner.AvailabilityDate <= now.Add(subscription.horizon()).UnixMilli() && subscription.Matches(summoner.Name) {
+						matches[subscription.ID] = append(matches[subscription.ID], summoner)
+						matched = true
+					}
+				}
+
+				if matched {
+					result.Matched++
+				}
+			}
+
+			if len(page.Summoners)+len(page.Skipped)+page.Filtered < int(a.pageSize) || len(page.Summoners) == 0 {
+				break
+			}
+
+			// As in AlertApproaching, the last date is queried again in case more names share it.
+			from = page.Summoners[len(page.Summoners)-1].AvailabilityDate
+			if from == page.Summoners[0].AvailabilityDate {
+				from++
+			}
+		}
+	}
+
+	return matches, nil
+}
+
+func (a *PatternAlerter) sendDigest(ctx context.Context, subscription *PatternSubscription, summoners []*SummonerDTO) error {
+	if len(summoners) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(summoners, func(i, j int) bool {
+		return summoners[i].AvailabilityDate < summoners[j].AvailabilityDate
+	})
+
+	payload := alertPushPayload{Region: subscription.Region, UnsubscribeUrl: a.unsubscribeLink(subscription)}
+	subject := fmt.Sprintf("%d names are becoming available (%s)", len(summoners), subscription.Region)
+	if len(summoners) == 1 {
+		subject = fmt.Sprintf("%s (%s) is becoming available", summoners[0].Name, subscription.Region)
+		payload.Name = summoners[0].Name
+	}
+
+	var body strings.Builder
+	_, _ = fmt.Fprintf(&body, "%d %s matching your alert for %s will become available within %d hours.\n\n",
+		len(summoners), pluralize(len(summoners), "name", "names"), subscription.describe(), subscription.HorizonHours)
+
+	for i, summoner := range summoners {
+		if i == maxPatternDigestNames {
+			_, _ = fmt.Fprintf(&body, "and %d more\n", len(summoners)-maxPatternDigestNames)
+			break
+		}
+
+		date := time.UnixMilli(summoner.AvailabilityDate).UTC().Format("Monday, January 2 at 15:04 MST")
+		_, _ = fmt.Fprintf(&body, "%s: %s\n", summoner.Name, date)
+	}
+
+	body.WriteString(a.unsubscribeText(subscription))
+	return a.send(ctx, subscription.Contact, subscription.Push, subject, body.String(), payload)
+}
+
+func pluralize(count int, singular string, plural string) string {
+	if count == 1 {
+		return singular
+	}
+
+	return plural
+}
+
+func (a *PatternAlerter) unsubscribeText(subscription *PatternSubscription) string {
+	if a.unsubscribeUrl == "" {
+		return ""
+	}
+
+	return "\nTo stop alerts for these names, visit " + a.unsubscribeLink(subscription) + "\n"
+}
+
+func (a *PatternAlerter) unsubscribeLink(subscription *PatternSubscription) string {
+	if a.unsubscribeUrl == "" {
+		return ""
+	}
+
+	query := url.Values{
+		"region":  {subscription.Region},
+		"pattern": {subscription.ID},
+		"token":   {subscription.Token},
+	}
+
+	return a.unsubscribeUrl + "?" + query.Encode()
+}
+
+// load returns a region's subscriptions, cached for the ttl. If reloading fails the previous
+// subscriptions are kept.
+func (a *PatternAlerter) load(ctx context.Context, region string) ([]*PatternSubscription, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if loadedAt, ok := a.loadedAt[region]; ok && a.now().Sub(loadedAt) < a.ttl {
+		return a.cached[region], nil
+	}
+
+	subscriptions, err := a.list(ctx, region)
+	if err != nil {
+		if cached, ok := a.cached[region]; ok {
+			log.Printf("could not reload pattern alerts of '%s', keeping previous subscriptions: %v", region, err)
+			return cached, nil
+		}
+
+		return nil, err
+	}
+
+	a.cached[region] = subscriptions
+	a.loadedAt[region] = a.now()
+	return subscriptions, nil
+}
+
+func (a *PatternAlerter) invalidate(region string) {
+	a.mu.Lock()
+	delete(a.loadedAt, region)
+	a.mu.Unlock()
+}
+
+// list queries a region's confirmed subscriptions, sorted by id.
+func (a *PatternAlerter) list(ctx context.Context, region string) ([]*PatternSubscription, error) {
+	subscriptions := make([]*PatternSubscription, 0)
+
+	var startKey map[string]types.AttributeValue
+	for {
+		output, err := a.dynamodb.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(a.tableName),
+			IndexName:              aws.String(patternRegionIndex),
+			KeyConditionExpression: aws.String("pr = :pr"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pr": &types.AttributeValueMemberS{Value: region},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, attributes := range output.Items {
+			item := &patternItem{}
+			if err = attributevalue.UnmarshalMap(attributes, item); err != nil {
+				return nil, err
+			}
+
+			item.Subscription.matcher, _ = compilePattern(item.Subscription.Kind, item.Subscription.Pattern)
+			subscriptions = append(subscriptions, item.Subscription)
+		}
+
+		if len(output.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = output.LastEvaluatedKey
+	}
+
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+
+	return subscriptions, nil
+}
+
+// getItem returns a subscription's item, or nil when it does not exist or expired unconfirmed.
+func (a *PatternAlerter) getItem(ctx context.Context, region string, id string) (*patternItem, error) {
+	output, err := a.dynamodb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(a.tableName),
+		Key:            map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: patternKey(region, id)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || output.Item == nil {
+		return nil, err
+	}
+
+	item := &patternItem{}
+	if err = attributevalue.UnmarshalMap(output.Item, item); err != nil {
+		return nil, err
+	}
+
+	if item.Subscription == nil || (item.Expiry != 0 && item.Expiry <= a.now().Unix()) {
+		return nil, nil
+	}
+
+	item.Subscription.Pending = item.Region == ""
+	return item, nil
+}
+
+func (a *PatternAlerter) put(ctx context.Context, item *patternItem) error {
+	marshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.dynamodb.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(a.tableName),
+		Item:                marshalled,
+		ConditionExpression: aws.String("attribute_not_exists(n)"),
+	})
+
+	return err
+}
+
+// setThrough records the date up to which a subscription was swept, unless it was removed meanwhile.
+func (a *PatternAlerter) setThrough(ctx context.Context, region string, id string, through int64) error {
+	_, err := a.dynamodb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(a.tableName),
+		Key:                 map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: patternKey(region, id)}},
+		UpdateExpression:    aws.String("SET #s.th = :th"),
+		ConditionExpression: aws.String("attribute_exists(n)"),
+		ExpressionAttributeNames: map[string]string{
+			"#s": "s",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":th": &types.AttributeValueMemberN{Value: strconv.FormatInt(through, 10)},
+		},
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return nil
+	}
+
+	return err
+}
+
+// remove deletes a subscription and frees its place among its contact's subscriptions.
+func (a *PatternAlerter) remove(ctx context.Context, subscription *PatternSubscription) error {
+	_, err := a.dynamodb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(a.tableName),
+		Key:       map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: patternKey(subscription.Region, subscription.ID)}},
+	})
+	if err != nil {
+		return err
+	}
+
+	a.invalidate(subscription.Region)
+	return a.releaseContact(ctx, subscription.Contact, subscription.ID)
+}
+
+func (a *PatternAlerter) releaseContact(ctx context.Context, contact string, id string) error {
+	return a.updateContact(ctx, contact, func(item *patternContactItem) error {
+		delete(item.Subscriptions, id)
+		return nil
+	})
+}
+
+// updateContact changes the list of a contact's subscriptions, leaving out those that expired unconfirmed.
+func (a *PatternAlerter) updateContact(ctx context.Context, contact string, change func(item *patternContactItem) error) error {
+	item := &patternContactItem{Key: patternContactPrefix + subscriptionId(contact)}
+	if err := getSingleItem(ctx, a.dynamodb, a.tableName, item.Key, item); err != nil {
+		return err
+	}
+
+	if item.Subscriptions == nil {
+		item.Subscriptions = make(map[string]int64)
+	}
+
+	now := a.now()
+	for id, expiresAt := range item.Subscriptions {
+		if expiresAt != 0 && expiresAt <= now.UnixMilli() {
+			delete(item.Subscriptions, id)
+		}
+	}
+
+	return updateSingleItem(ctx, a.dynamodb, a.tableName, item, now, func() error {
+		return change(item)
+	})
+}
@@ -0,0 +1,417 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// PatternsDynamoDBServiceMock stores items in memory, enforcing the conditions the pattern alerter writes
+// with, and serves the pattern region index.
+type PatternsDynamoDBServiceMock struct {
+	*SingleItemTableMock
+	Queries int
+}
+
+func newPatternsDynamoDBServiceMock() *PatternsDynamoDBServiceMock {
+	return &PatternsDynamoDBServiceMock{SingleItemTableMock: &SingleItemTableMock{Items: make(map[string]map[string]types.AttributeValue)}}
+}
+
+func (p *PatternsDynamoDBServiceMock) PutItem(ctx context.Context, input *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	key := input.Item["n"].(*types.AttributeValueMemberS).Value
+	if _, ok := p.Items[key]; ok && *input.ConditionExpression == "attribute_not_exists(n)" {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	return p.SingleItemTableMock.PutItem(ctx, input, optFns...)
+}
+
+func (p *PatternsDynamoDBServiceMock) UpdateItem(_ context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	item, ok := p.Items[input.Key["n"].(*types.AttributeValueMemberS).Value]
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	subscription := item["s"].(*types.AttributeValueMemberM).Value
+
+	if through, ok := input.ExpressionAttributeValues[":th"]; ok {
+		subscription["th"] = through
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	token := input.ExpressionAttributeValues[":token"].(*types.AttributeValueMemberS).Value
+	expiry, pending := item["ttl"]
+	if subscription["t"].(*types.AttributeValueMemberS).Value != token || (pending && numberValue(expiry) <= numberValue(input.ExpressionAttributeValues[":now"])) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	item["pr"] = input.ExpressionAttributeValues[":pr"]
+	delete(item, "ttl")
+	return &dynamodb.UpdateItemOutput{Attributes: item}, nil
+}
+
+func (p *PatternsDynamoDBServiceMock) DeleteItem(_ context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	delete(p.Items, input.Key["n"].(*types.AttributeValueMemberS).Value)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (p *PatternsDynamoDBServiceMock) Query(_ context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	p.Queries++
+	if *input.IndexName != patternRegionIndex {
+		return nil, fmt.Errorf("unexpected index %s", *input.IndexName)
+	}
+
+	output := &dynamodb.QueryOutput{}
+	region := input.ExpressionAttributeValues[":pr"].(*types.AttributeValueMemberS).Value
+	for _, item := range p.Items {
+		if pr, ok := item["pr"].(*types.AttributeValueMemberS); ok && pr.Value == region {
+			output.Items = append(output.Items, item)
+		}
+	}
+
+	return output, nil
+}
+
+func newTestPatternAlerter(upcoming *UpcomingSummonersMock) (*PatternAlerter, *MailerMock, *fakeClock) {
+	mailer := &MailerMock{}
+	clock := &fakeClock{current: watchTestNow}
+	alerter := NewPatternAlerter(newPatternsDynamoDBServiceMock(), "test-table", upcoming, mailer, "https://names.lol/patterns/unsubscribe")
+	confirmations, _, _ := newTestConfirmations()
+	alerter.ConfirmWith(confirmations, "https://names.lol/patterns/confirm")
+	alerter.now = clock.Now
+
+	return alerter, mailer, clock
+}
+
+// subscribePattern subscribes and, for email subscriptions, confirms as the contact would.
+func subscribePattern(t *testing.T, alerter *PatternAlerter, subscription PatternSubscription) *PatternSubscription {
+	saved, err := alerter.Subscribe(context.TODO(), subscription)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if saved.Pending {
+		if err = alerter.Confirm(context.TODO(), saved.Region, saved.ID, saved.Token); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+	}
+
+	return saved
+}
+
+func TestPatternSubscription_MatchesGlobsAndRegexesCaseInsensitively(t *testing.T) {
+	cases := []struct {
+		subscription PatternSubscription
+		name         string
+		expected     bool
+	}{
+		{PatternSubscription{Region: "NA", Kind: PatternRegex, Pattern: "^dark.*"}, "Dark Knight", true},
+		{PatternSubscription{Region: "NA", Kind: PatternRegex, Pattern: "^dark.*"}, "The Dark", false},
+		{PatternSubscription{Region: "NA", Kind: PatternGlob, Pattern: "Dark*"}, "darkness", true},
+		{PatternSubscription{Region: "NA", Kind: PatternGlob, Pattern: "d?rk"}, "Dirk", true},
+		{PatternSubscription{Region: "NA", Kind: PatternGlob, Pattern: "d?rk"}, "Dirks", false},
+		{PatternSubscription{Region: "NA", Kind: PatternGlob, Pattern: "a.b"}, "axb", false},
+		{PatternSubscription{Region: "NA", MinLength: 3, MaxLength: 4}, "Abc", true},
+		{PatternSubscription{Region: "NA", MinLength: 3, MaxLength: 4}, "Abcde", false},
+	}
+
+	for _, c := range cases {
+		if err := c.subscription.Validate(); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+
+		if c.subscription.Matches(c.name) != c.expected {
+			t.Errorf("%s '%s' matching '%s': expected %v", c.subscription.Kind, c.subscription.Pattern, c.name, c.expected)
+		}
+	}
+}
+
+func TestPatternSubscription_ValidateRejectsInvalidSubscriptions(t *testing.T) {
+	invalid := []PatternSubscription{
+		{Region: "MARS"},
+		{Region: "NA", MinLength: 2},
+		{Region: "NA", MinLength: 5, MaxLength: 4},
+		{Region: "NA", MaxLength: 17},
+		{Region: "NA", Kind: "sql", Pattern: "a"},
+		{Region: "NA", Pattern: "(unclosed"},
+		{Region: "NA", Pattern: strings.Repeat("a", maxPatternLength+1)},
+		{Region: "NA", HorizonHours: maxPatternHorizon + 1},
+	}
+
+	for _, subscription := range invalid {
+		if err := subscription.Validate(); err == nil {
+			t.Errorf("%+v: expected error, got nil", subscription)
+		}
+	}
+}
+
+func TestPatternAlerter_SubscribeAndUnsubscribe(t *testing.T) {
+	alerter, _, _ := newTestPatternAlerter(&UpcomingSummonersMock{})
+	client := alerter.dynamodb.(*PatternsDynamoDBServiceMock)
+
+	saved := subscribePattern(t, alerter, PatternSubscription{Region: "EUW", Kind: PatternGlob, Pattern: "dark*", Contact: "a@example.com"})
+	if saved.ID == "" || saved.Token == "" || saved.HorizonHours != defaultPatternHorizon || saved.MaxLength != 16 {
+		t.Errorf("expected an id, token and defaults, got %+v", saved)
+	}
+
+	if _, ok := client.Items["pattern#EUW#"+saved.ID]; !ok {
+		t.Errorf("expected the subscription to be stored in its own item, got %v", client.Items)
+	}
+
+	if err := alerter.Unsubscribe(context.TODO(), "EUW", saved.ID, "wrong"); err == nil || err.Error() != "subscription not found" {
+		t.Errorf("expected subscription not found, got %v", err)
+	}
+
+	if err := alerter.Unsubscribe(context.TODO(), "EUW", saved.ID, saved.Token); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if subscriptions, _ := alerter.list(context.TODO(), "EUW"); len(subscriptions) != 0 {
+		t.Errorf("expected the subscription to be removed, got %v", subscriptions)
+	}
+}
+
+func TestPatternAlerter_EmailSubscriptionsStayPendingUntilConfirmed(t *testing.T) {
+	alerter, mailer, _ := newTestPatternAlerter(&UpcomingSummonersMock{})
+	confirmationMailer := alerter.confirmations.mailer.(*MailerMock)
+
+	saved, err := alerter.Subscribe(context.TODO(), PatternSubscription{Region: "NA", Contact: "a@example.com"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !saved.Pending || len(confirmationMailer.Bodies) != 1 || !strings.Contains(confirmationMailer.Bodies[0], "token="+saved.Token) {
+		t.Fatalf("expected a pending subscription and a confirmation link, got %+v and %v", saved, confirmationMailer.Bodies)
+	}
+
+	_ = alerter.Publish(context.TODO(), NewNameEvent(EventNameAvailable, "NA", "Freed"))
+	if len(mailer.Sent) != 0 {
+		t.Errorf("expected pending subscriptions not to be alerted, got %v", mailer.Sent)
+	}
+
+	if err = alerter.Confirm(context.TODO(), "NA", saved.ID, "wrong"); err == nil || err.Error() != "subscription not found" {
+		t.Errorf("expected subscription not found, got %v", err)
+	}
+
+	if err = alerter.Confirm(context.TODO(), "NA", saved.ID, saved.Token); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	_ = alerter.Publish(context.TODO(), NewNameEvent(EventNameAvailable, "NA", "Freed"))
+	if len(mailer.Sent) != 1 {
+		t.Errorf("expected the confirmed subscription to be alerted, got %v", mailer.Sent)
+	}
+}
+
+func TestPatternAlerter_ExpiredSubscriptionsCannotBeConfirmed(t *testing.T) {
+	alerter, _, clock := newTestPatternAlerter(&UpcomingSummonersMock{})
+
+	saved, _ := alerter.Subscribe(context.TODO(), PatternSubscription{Region: "NA", Contact: "a@example.com"})
+	clock.Advance(confirmationTtl + time.Minute)
+
+	if err := alerter.Confirm(context.TODO(), "NA", saved.ID, saved.Token); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestPatternAlerter_LimitsSubscriptionsPerContact(t *testing.T) {
+	alerter, _, clock := newTestPatternAlerter(&UpcomingSummonersMock{})
+	for i := 0; i < maxContactPatterns; i++ {
+		if _, err := alerter.Subscribe(context.TODO(), PatternSubscription{Region: "NA", Contact: "a@example.com"}); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+	}
+
+	_, err := alerter.Subscribe(context.TODO(), PatternSubscription{Region: "EUW", Contact: " A@example.com"})
+	if err == nil || err.Error() != "too many pattern alerts for this contact" {
+		t.Errorf("expected too many pattern alerts for this contact, got %v", err)
+	}
+
+	if _, err = alerter.Subscribe(context.TODO(), PatternSubscription{Region: "NA", Contact: "b@example.com"}); err != nil {
+		t.Errorf("expected other contacts to subscribe, got %v", err)
+	}
+
+	clock.Advance(confirmationTtl + time.Minute)
+	if _, err = alerter.Subscribe(context.TODO(), PatternSubscription{Region: "NA", Contact: "a@example.com"}); err != nil {
+		t.Errorf("expected expired pending subscriptions to free their place, got %v", err)
+	}
+}
+
+func TestPatternAlerter_SubscribeIsRateLimitedPerContact(t *testing.T) {
+	alerter, _, _ := newTestPatternAlerter(&UpcomingSummonersMock{})
+	alerter.confirmations.limiter.(*SubscribeLimiterMock).Limited = true
+
+	_, err := alerter.Subscribe(context.TODO(), PatternSubscription{Region: "NA", Contact: "a@example.com"})
+
+	var rateLimitedErr *RateLimitedError
+	if !errors.As(err, &rateLimitedErr) || len(alerter.dynamodb.(*PatternsDynamoDBServiceMock).Items) != 0 {
+		t.Errorf("expected RateLimitedError without writes, got %v", err)
+	}
+}
+
+func TestPatternAlerter_PublishAlertsMatchingSubscribersWhenNamesAreFreed(t *testing.T) {
+	alerter, mailer, _ := newTestPatternAlerter(&UpcomingSummonersMock{})
+	subscribePattern(t, alerter, PatternSubscription{Region: "NA", Kind: PatternRegex, Pattern: "^dark", Contact: "a@example.com"})
+	subscribePattern(t, alerter, PatternSubscription{Region: "NA", MaxLength: 4, Contact: "b@example.com"})
+	subscribePattern(t, alerter, PatternSubscription{Region: "EUW", Contact: "c@example.com"})
+
+	err := alerter.Publish(context.TODO(), NewNameEvent(EventNameAvailable, "NA", "Darkness"))
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(mailer.Sent) != 1 || mailer.Sent[0] != "a@example.com: Darkness (NA) is now available" {
+		t.Errorf("expected only the matching subscriber in the region to be alerted, got %v", mailer.Sent)
+	}
+
+	if !strings.Contains(mailer.Bodies[0], "https://names.lol/patterns/unsubscribe?pattern=") {
+		t.Errorf("expected an unsubscribe link, got %s", mailer.Bodies[0])
+	}
+
+	_ = alerter.Publish(context.TODO(), NewNameEvent(EventNameTaken, "NA", "Darkness"))
+	if len(mailer.Sent) != 1 {
+		t.Errorf("expected taken names not to alert, got %v", mailer.Sent)
+	}
+}
+
+func TestPatternAlerter_PublishAlertsSweptSubscribersAboutChangedDates(t *testing.T) {
+	summoners := &UpcomingSummonersMock{}
+	alerter, mailer, _ := newTestPatternAlerter(summoners)
+	subscribePattern(t, alerter, PatternSubscription{Region: "NA", Kind: PatternGlob, Pattern: "dark*", Contact: "a@example.com"})
+
+	event := NewNameEvent(EventNameDateChanged, "NA", "Darkness")
+	event.AvailabilityDate = watchTestNow.Add(time.Hour).UnixMilli()
+
+	_ = alerter.Publish(context.TODO(), event)
+	if len(mailer.Sent) != 0 {
+		t.Errorf("expected subscribers not yet swept to wait for their sweep, got %v", mailer.Sent)
+	}
+
+	if _, err := alerter.Sweep(context.TODO(), "NA"); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	_ = alerter.Publish(context.TODO(), event)
+	if len(mailer.Sent) != 1 || mailer.Sent[0] != "a@example.com: Darkness (NA) is becoming available" {
+		t.Errorf("expected the swept subscriber to be alerted, got %v", mailer.Sent)
+	}
+}
+
+func TestPatternAlerter_PublishDropsRejectedSubscribers(t *testing.T) {
+	alerter, mailer, _ := newTestPatternAlerter(&UpcomingSummonersMock{})
+	mailer.RejectFor = "gone@example.com"
+	subscribePattern(t, alerter, PatternSubscription{Region: "NA", Contact: "gone@example.com"})
+
+	if err := alerter.Publish(context.TODO(), NewNameEvent(EventNameAvailable, "NA", "Freed")); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if subscriptions, _ := alerter.list(context.TODO(), "NA"); len(subscriptions) != 0 {
+		t.Errorf("expected the rejected subscriber to be dropped, got %v", subscriptions)
+	}
+}
+
+func TestPatternAlerter_SweepSendsOneDigestPerSubscriberWithinTheirHorizon(t *testing.T) {
+	summoners := &UpcomingSummonersMock{Summoners: []*SummonerDTO{
+		upcoming("Abc", time.Hour),
+		upcoming("Abcd", 2*time.Hour),
+		upcoming("Abcde", 3*time.Hour),
+		upcoming("Xyz", 30*time.Hour),
+		upcoming("Past", -time.Hour),
+	}}
+	alerter, mailer, clock := newTestPatternAlerter(summoners)
+	subscribePattern(t, alerter, PatternSubscription{Region: "NA", MinLength: 3, MaxLength: 4, Contact: "short@example.com"})
+	subscribePattern(t, alerter, PatternSubscription{Region: "NA", Kind: PatternGlob, Pattern: "xyz", HorizonHours: 48, Contact: "xyz@example.com"})
+
+	result, err := alerter.Sweep(context.TODO(), "NA")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if result.Sent != 2 || result.Matched != 3 {
+		t.Errorf("expected 2 alerts for 3 matches, got %+v", result)
+	}
+
+	short := slices.Index(mailer.Sent, "short@example.com: 2 names are becoming available (NA)")
+	if len(mailer.Sent) != 2 || short == -1 || !slices.Contains(mailer.Sent, "xyz@example.com: Xyz (NA) is becoming available") {
+		t.Fatalf("unexpected alerts %v", mailer.Sent)
+	}
+
+	if body := mailer.Bodies[short]; !strings.Contains(body, "Abc: ") || !strings.Contains(body, "Abcd: ") || strings.Contains(body, "Xyz") {
+		t.Errorf("expected the digest to list the short names, got %s", body)
+	}
+
+	clock.Advance(time.Hour)
+	if _, err = alerter.Sweep(context.TODO(), "NA"); err != nil || len(mailer.Sent) != 2 {
+		t.Errorf("expected names already swept not to alert again, got %v, %v", err, mailer.Sent)
+	}
+}
+
+func TestPatternAlerter_SweepRetriesSubscribersWhoseAlertFailed(t *testing.T) {
+	summoners := &UpcomingSummonersMock{Summoners: []*SummonerDTO{upcoming("Abc", time.Hour)}}
+	alerter, mailer, _ := newTestPatternAlerter(summoners)
+	mailer.FailFor = "a@example.com"
+	subscribePattern(t, alerter, PatternSubscription{Region: "NA", Contact: "a@example.com"})
+
+	if _, err := alerter.Sweep(context.TODO(), "NA"); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	mailer.FailFor = ""
+	if _, err := alerter.Sweep(context.TODO(), "NA"); err != nil || len(mailer.Sent) != 1 {
+		t.Errorf("expected the alert to be sent on retry, got %v, %v", err, mailer.Sent)
+	}
+}
+
+func TestPatternAlerter_SweepCapsDigests(t *testing.T) {
+	summoners := &UpcomingSummonersMock{}
+	for i := 0; i < maxPatternDigestNames+5; i++ {
+		summoners.Summoners = append(summoners.Summoners, upcoming("Name"+strings.Repeat("a", i%10)+string(rune('a'+i/10)), time.Duration(i+1)*time.Minute))
+	}
+	alerter, mailer, _ := newTestPatternAlerter(summoners)
+	subscribePattern(t, alerter, PatternSubscription{Region: "NA", Contact: "a@example.com"})
+
+	if _, err := alerter.Sweep(context.TODO(), "NA"); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(mailer.Bodies) != 1 || !strings.Contains(mailer.Bodies[0], "and 5 more") {
+		t.Errorf("expected a capped digest, got %v", mailer.Bodies)
+	}
+}
+
+func TestPatternAlerter_SweepPagesThroughNamesSharingADate(t *testing.T) {
+	summoners := &UpcomingSummonersMock{Summoners: []*SummonerDTO{upcoming("Aaa", time.Hour), upcoming("Bbb", time.Hour), upcoming("Ccc", 2*time.Hour)}}
+	alerter, mailer, _ := newTestPatternAlerter(summoners)
+	alerter.pageSize = 2
+	subscribePattern(t, alerter, PatternSubscription{Region: "NA", MaxLength: 3, Contact: "a@example.com"})
+
+	result, err := alerter.Sweep(context.TODO(), "NA")
+	if err != nil || result.Matched != 3 || len(mailer.Sent) != 1 {
+		t.Errorf("expected one digest with 3 names, got %v, %+v, %v", err, result, mailer.Sent)
+	}
+}
+
+func TestPatternAlerter_PushesToBrowserSubscriptions(t *testing.T) {
+	summoners := &UpcomingSummonersMock{Summoners: []*SummonerDTO{upcoming("Abc", time.Hour)}}
+	alerter, _, _ := newTestPatternAlerter(summoners)
+	pusher := &PusherMock{GoneFor: "https://push.example.com/gone"}
+	alerter.UsePush(pusher)
+
+	subscribePattern(t, alerter, PatternSubscription{Region: "NA", Push: testPushSubscription("https://push.example.com/browser")})
+	subscribePattern(t, alerter, PatternSubscription{Region: "NA", Push: testPushSubscription("https://push.example.com/gone")})
+
+	result, err := alerter.Sweep(context.TODO(), "NA")
+	if err != nil || result.Sent != 1 || result.Dropped != 1 {
+		t.Fatalf("expected one push and one dropped subscription, got %v, %+v", err, result)
+	}
+
+	if !strings.Contains(pusher.Payloads["https://push.example.com/browser"], `"title":"Abc (NA) is becoming available"`) {
+		t.Errorf("unexpected payload %v", pusher.Payloads)
+	}
+}
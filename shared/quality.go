@@ -0,0 +1,189 @@
+package shared
+
+import (
+	"bufio"
+	"embed"
+	"golang.org/x/text/unicode/norm"
+	"log"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// maxLetterRun is the longest run of vowels or of consonants a pronounceable name has.
+const maxLetterRun = 2
+
+// minWordLength is the length of the shortest dictionary word counted in a name.
+const minWordLength = 3
+
+// maxFullLength is the length of the longest name not scored down for its length. Each character past
+// it costs lengthPenalty, up to half the score for the longest names.
+const maxFullLength = 6
+const lengthPenalty = 0.05
+
+// nonWordWeight is the share of the score a name made of no dictionary words keeps.
+const nonWordWeight = 0.7
+
+//go:embed wordlists/*.txt
+var wordLists embed.FS
+
+// dictionary holds the words of the English, Spanish, French, German and Portuguese word lists, folded
+// with foldWord. They cover the languages of the supported regions written in the alphabets their names
+// allow, see NewNameValidator.
+var dictionary map[string]bool
+var longestWord int
+
+func init() {
+	dictionary = make(map[string]bool)
+
+	files, err := wordLists.ReadDir("wordlists")
+	if err != nil {
+		log.Fatalf("Error reading word lists: %v\n", err)
+	}
+
+	for _, file := range files {
+		f, err := wordLists.Open("wordlists/" + file.Name())
+		if err != nil {
+			log.Fatalf("Error opening word list %s: %v\n", file.Name(), err)
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if word := foldWord(scanner.Text()); len([]rune(word)) >= minWordLength {
+				dictionary[word] = true
+				longestWord = max(longestWord, len([]rune(word)))
+			}
+		}
+		_ = f.Close()
+	}
+}
+
+// QualityScore rates from 0 to 100 how likely a name is to be wanted. Digits and symbols, runs of more
+// than two vowels or consonants, names without vowels and characters repeated three or more times in a
+// row all lower the score, as do names longer than six characters and names not made of dictionary words.
+// Letters outside the Latin alphabet, such as the Greek letters allowed in EUNE, count as pronounceable.
+func QualityScore(name string) int {
+	runes := []rune(NormalizeName(name))
+	if len(runes) == 0 {
+		return 0
+	}
+
+	letters := 0
+	for _, r := range runes {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+
+	coverage := wordCoverage(name, letters)
+
+	score := float64(letters) / float64(len(runes))
+	score *= coverage + (1-coverage)*pronounceability(runes, letters)
+	score *= 1 - float64(repeatedRunes(runes))/float64(len(runes))
+	score *= 1 - lengthPenalty*float64(min(max(len(runes)-maxFullLength, 0), 10))
+	score *= nonWordWeight + (1-nonWordWeight)*coverage
+
+	return int(math.Round(100 * score))
+}
+
+// foldWord normalizes a name or word and strips its diacritics, so "Corazón" and "corazon" match.
+func foldWord(word string) string {
+	decomposed := norm.NFD.String(strings.ReplaceAll(NormalizeName(word), "ß", "ss"))
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, decomposed)
+}
+
+// wordCoverage is the share of a name's letters covered by dictionary words, choosing the words that
+// cover the most, so "Darkwolf" and "dark_wolf" are fully covered and "Faker" is not at all.
+func wordCoverage(name string, letters int) float64 {
+	if letters == 0 {
+		return 0
+	}
+
+	runes := []rune(foldWord(name))
+	covered := make([]int, len(runes)+1)
+	for end := 1; end <= len(runes); end++ {
+		covered[end] = covered[end-1]
+		for start := max(end-longestWord, 0); start <= end-minWordLength; start++ {
+			if dictionary[string(runes[start:end])] {
+				covered[end] = max(covered[end], covered[start]+end-start)
+			}
+		}
+	}
+
+	return min(float64(covered[len(runes)])/float64(letters), 1)
+}
+
+// pronounceability is the share of letters not in excess of a vowel or consonant run, halved for names
+// of Latin letters without vowels.
+func pronounceability(runes []rune, letters int) float64 {
+	if letters == 0 {
+		return 1
+	}
+
+	excess, run, vowels, latin := 0, 0, 0, 0
+	previous := ' '
+	for _, r := range runes {
+		kind := latinKind(r)
+		if kind == ' ' {
+			run, previous = 0, ' '
+			continue
+		}
+
+		latin++
+		if kind == 'v' {
+			vowels++
+		}
+
+		if kind == previous {
+			run++
+		} else {
+			run, previous = 1, kind
+		}
+
+		if run > maxLetterRun {
+			excess++
+		}
+	}
+
+	score := 1 - float64(excess)/float64(letters)
+	if latin >= 3 && vowels == 0 {
+		score /= 2
+	}
+
+	return score
+}
+
+// latinKind classifies a folded Latin letter as a vowel (v) or consonant (c), and anything else as ' '.
+func latinKind(r rune) rune {
+	switch {
+	case strings.ContainsRune("aeiouy", r):
+		return 'v'
+	case r >= 'a' && r <= 'z':
+		return 'c'
+	default:
+		return ' '
+	}
+}
+
+// repeatedRunes counts the characters beyond the second in each run of one repeated character.
+func repeatedRunes(runes []rune) int {
+	repeated, run := 0, 1
+	for i := 1; i < len(runes); i++ {
+		if runes[i] == runes[i-1] {
+			run++
+		} else {
+			run = 1
+		}
+
+		if run > 2 {
+			repeated++
+		}
+	}
+
+	return repeated
+}
@@ -0,0 +1,69 @@
+package shared
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestQualityScore(t *testing.T) {
+	cases := map[string]int{
+		"Faker":      70,
+		"Doublelift": 80,
+		"Darkwolf":   90,
+		"Corazón":    95,
+		"xkcdq":      14,
+		"aaaa1":      17,
+		"12345":      0,
+		"Λύκος":      70,
+		"":           0,
+	}
+
+	for name, expected := range cases {
+		if score := QualityScore(name); score != expected {
+			t.Errorf("%s: expected %d, got %d", name, expected, score)
+		}
+	}
+}
+
+func TestQualityScore_PrefersPronounceableNames(t *testing.T) {
+	if QualityScore("Lumen") <= QualityScore("Lmnqr") {
+		t.Errorf("expected Lumen to score higher than Lmnqr")
+	}
+
+	if QualityScore("Sky") <= QualityScore("Sky9") {
+		t.Errorf("expected Sky to score higher than Sky9")
+	}
+}
+
+func TestQualityScore_PrefersDictionaryWords(t *testing.T) {
+	if QualityScore("Wolf") <= QualityScore("Wulf") {
+		t.Errorf("expected Wolf to score higher than Wulf")
+	}
+
+	if QualityScore("Feuer") != QualityScore("FEUER") || QualityScore("König") != QualityScore("Konig") {
+		t.Errorf("expected words to match regardless of case and diacritics")
+	}
+}
+
+func TestQualityScore_PrefersShortNames(t *testing.T) {
+	if QualityScore("Storm") <= QualityScore("Stormshadowblade") {
+		t.Errorf("expected Storm to score higher than Stormshadowblade")
+	}
+}
+
+func TestWordCoverage(t *testing.T) {
+	cases := map[string]float64{
+		"darkwolf":  1,
+		"dark_wolf": 1,
+		"darkxx":    4.0 / 6,
+		"faker":     0,
+		"luna":      1,
+	}
+
+	for name, expected := range cases {
+		if coverage := wordCoverage(name, len([]rune(strings.ReplaceAll(name, "_", "")))); math.Abs(coverage-expected) > 1e-9 {
+			t.Errorf("%s: expected %f, got %f", name, expected, coverage)
+		}
+	}
+}
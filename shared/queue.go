@@ -0,0 +1,106 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"strconv"
+	"time"
+)
+
+// sqsBatchSize is the most messages SendMessageBatch accepts at once.
+const sqsBatchSize = 10
+
+// NameUpdateMessage is the body of the messages the producer sends to the name update queue and the
+// consumer reads from it.
+type NameUpdateMessage struct {
+	Region string `json:"region"`
+	Name   string `json:"name"`
+}
+
+type sqsService interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+}
+
+type sqsBatchService interface {
+	sqsService
+	SendMessageBatch(ctx context.Context, params *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+}
+
+type NameUpdateQueue struct {
+	sqs      sqsBatchService
+	queueUrl string
+}
+
+func NewNameUpdateQueue(client sqsBatchService, queueUrl string) *NameUpdateQueue {
+	return &NameUpdateQueue{sqs: client, queueUrl: queueUrl}
+}
+
+func (q *NameUpdateQueue) Send(ctx context.Context, region string, name string) error {
+	body, err := json.Marshal(&NameUpdateMessage{Region: region, Name: name})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.sqs.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.queueUrl),
+		MessageBody: aws.String(string(body)),
+	})
+
+	return err
+}
+
+// SendBatch sends the names in batches of sqsBatchSize messages, stopping at the first batch that fails,
+// and returns how many names were sent.
+func (q *NameUpdateQueue) SendBatch(ctx context.Context, region string, names []string) (int, error) {
+	sent := 0
+	for start := 0; start < len(names); start += sqsBatchSize {
+		batch := names[start:min(start+sqsBatchSize, len(names))]
+
+		entries := make([]types.SendMessageBatchRequestEntry, len(batch))
+		for i, name := range batch {
+			body, err := json.Marshal(&NameUpdateMessage{Region: region, Name: name})
+			if err != nil {
+				return sent, err
+			}
+
+			entries[i] = types.SendMessageBatchRequestEntry{Id: aws.String(strconv.Itoa(i)), MessageBody: aws.String(string(body))}
+		}
+
+		output, err := q.sqs.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(q.queueUrl),
+			Entries:  entries,
+		})
+		if err != nil {
+			return sent, err
+		}
+
+		sent += len(output.Successful)
+		if len(output.Failed) > 0 {
+			return sent, fmt.Errorf("could not send %d of %d names: %s", len(output.Failed), len(batch), aws.ToString(output.Failed[0].Message))
+		}
+	}
+
+	return sent, nil
+}
+
+// RefreshWindow returns the availability date range the producer refreshes for a refresh type: names
+// becoming available within 3 days for "hourly", 30 days for "weekly" and 90 days for "monthly".
+func RefreshWindow(refreshType string, now time.Time) (int64, int64, error) {
+	var days time.Duration
+	switch refreshType {
+	case "hourly":
+		days = 3
+	case "weekly":
+		days = 30
+	case "monthly":
+		days = 90
+	default:
+		return 0, 0, fmt.Errorf("invalid refreshType '%s'", refreshType)
+	}
+
+	return now.Add(-days * 24 * time.Hour).UnixMilli(), now.Add(days * 24 * time.Hour).UnixMilli(), nil
+}
@@ -0,0 +1,127 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+type SQSServiceMock struct {
+	ShouldFail bool
+	FailName   string
+	Calls      []*sqs.SendMessageInput
+	Batches    []*sqs.SendMessageBatchInput
+}
+
+func (s *SQSServiceMock) SendMessage(_ context.Context, input *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	s.Calls = append(s.Calls, input)
+
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (s *SQSServiceMock) SendMessageBatch(_ context.Context, input *sqs.SendMessageBatchInput, _ ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	s.Batches = append(s.Batches, input)
+
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	output := &sqs.SendMessageBatchOutput{}
+	for _, entry := range input.Entries {
+		if s.FailName != "" && strings.Contains(*entry.MessageBody, `"name":"`+s.FailName+`"`) {
+			output.Failed = append(output.Failed, types.BatchResultErrorEntry{Id: entry.Id, Message: aws.String("throttled")})
+			continue
+		}
+
+		output.Successful = append(output.Successful, types.SendMessageBatchResultEntry{Id: entry.Id})
+	}
+
+	return output, nil
+}
+
+func TestNameUpdateQueue_SendsMessageToQueue(t *testing.T) {
+	mock := &SQSServiceMock{}
+
+	err := NewNameUpdateQueue(mock, "test.queue.url").Send(context.TODO(), "NA", "hide on bush")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if *mock.Calls[0].QueueUrl != "test.queue.url" {
+		t.Errorf("expected test.queue.url, got %s", *mock.Calls[0].QueueUrl)
+	}
+
+	if *mock.Calls[0].MessageBody != `{"region":"NA","name":"hide on bush"}` {
+		t.Errorf("unexpected body %s", *mock.Calls[0].MessageBody)
+	}
+}
+
+func TestNameUpdateQueue_ReturnsErrorWhenSendFails(t *testing.T) {
+	mock := &SQSServiceMock{ShouldFail: true}
+
+	if err := NewNameUpdateQueue(mock, "test.queue.url").Send(context.TODO(), "NA", "test"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestNameUpdateQueue_SendBatchSendsTenNamesPerBatch(t *testing.T) {
+	mock := &SQSServiceMock{}
+	names := make([]string, 23)
+	for i := range names {
+		names[i] = fmt.Sprintf("name%d", i)
+	}
+
+	sent, err := NewNameUpdateQueue(mock, "test.queue.url").SendBatch(context.TODO(), "NA", names)
+	if err != nil || sent != 23 {
+		t.Fatalf("expected 23 names to be sent, got %d, %v", sent, err)
+	}
+
+	if len(mock.Batches) != 3 || len(mock.Batches[0].Entries) != 10 || len(mock.Batches[2].Entries) != 3 {
+		t.Errorf("expected batches of 10, 10 and 3, got %d batches", len(mock.Batches))
+	}
+
+	if *mock.Batches[1].Entries[0].MessageBody != `{"region":"NA","name":"name10"}` || *mock.Batches[1].QueueUrl != "test.queue.url" {
+		t.Errorf("unexpected batch %+v", mock.Batches[1])
+	}
+}
+
+func TestNameUpdateQueue_SendBatchStopsAtFailedBatch(t *testing.T) {
+	mock := &SQSServiceMock{FailName: "name12"}
+	names := make([]string, 30)
+	for i := range names {
+		names[i] = fmt.Sprintf("name%d", i)
+	}
+
+	sent, err := NewNameUpdateQueue(mock, "test.queue.url").SendBatch(context.TODO(), "NA", names)
+	if err == nil || sent != 19 || len(mock.Batches) != 2 {
+		t.Errorf("expected the second batch to fail after 19 names, got %d, %v, %d batches", sent, err, len(mock.Batches))
+	}
+}
+
+func TestRefreshWindow_SpansRefreshTypeAroundNow(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+
+	start, end, err := RefreshWindow("weekly", now)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if start != now.Add(-30*24*time.Hour).UnixMilli() || end != now.Add(30*24*time.Hour).UnixMilli() {
+		t.Errorf("unexpected window %d - %d", start, end)
+	}
+}
+
+func TestRefreshWindow_ReturnsErrorForUnknownType(t *testing.T) {
+	if _, _, err := RefreshWindow("daily", time.Now()); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,367 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type tokenBucket struct {
+	Tokens    float64
+	UpdatedAt int64
+	Version   int64
+}
+
+type bucketStore interface {
+	Load(ctx context.Context, key string) (*tokenBucket, error)
+	Save(ctx context.Context, key string, bucket *tokenBucket, previousVersion int64) (bool, error)
+}
+
+type RateLimitedError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limit for '%s' reached, retry after %s", e.Key, e.RetryAfter)
+}
+
+// IsThrottled reports whether err was caused by the Riot API budget or rate limiter rather than a failure.
+func IsThrottled(err error) bool {
+	var budgetErr *BudgetExhaustedError
+	var rateLimitedErr *RateLimitedError
+	return errors.As(err, &budgetErr) || errors.As(err, &rateLimitedErr)
+}
+
+func RateLimitKey(platform string, method string) string {
+	return platform + "#" + method
+}
+
+type TokenBucketLimiter struct {
+	store       bucketStore
+	rate        float64
+	burst       float64
+	maxAttempts int
+	now         func() time.Time
+	sleep       func(time.Duration)
+}
+
+// NewTokenBucketLimiter allows limit requests per window for each key, refilling continuously.
+func NewTokenBucketLimiter(store bucketStore, limit int, window time.Duration) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		store:       store,
+		rate:        float64(limit) / window.Seconds(),
+		burst:       float64(limit),
+		maxAttempts: 5,
+		now:         time.Now,
+		sleep:       time.Sleep,
+	}
+}
+
+func (l *TokenBucketLimiter) Take(ctx context.Context, key string) error {
+	for attempt := 0; attempt < l.maxAttempts; attempt++ {
+		bucket, err := l.store.Load(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		now := l.now().UnixMilli()
+		if bucket == nil {
+			bucket = &tokenBucket{Tokens: l.burst, UpdatedAt: now}
+		}
+
+		elapsed := float64(now-bucket.UpdatedAt) / 1000
+		tokens := math.Min(l.burst, bucket.Tokens+math.Max(0, elapsed)*l.rate)
+		if tokens < 1 {
+			retryAfter := time.Duration((1 - tokens) / l.rate * float64(time.Second))
+			return &RateLimitedError{Key: key, RetryAfter: retryAfter}
+		}
+
+		saved, err := l.store.Save(ctx, key, &tokenBucket{Tokens: tokens - 1, UpdatedAt: now, Version: bucket.Version + 1}, bucket.Version)
+		if err != nil {
+			return err
+		}
+
+		if saved {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("could not take token for '%s' after %d conflicting attempts", key, l.maxAttempts)
+}
+
+// Wait takes a token, sleeping while the next token is due within maxWait.
+func (l *TokenBucketLimiter) Wait(ctx context.Context, key string, maxWait time.Duration) error {
+	deadline := l.now().Add(maxWait)
+
+	for {
+		err := l.Take(ctx, key)
+
+		var rateLimitedErr *RateLimitedError
+		if !errors.As(err, &rateLimitedErr) || l.now().Add(rateLimitedErr.RetryAfter).After(deadline) {
+			return err
+		}
+
+		l.sleep(rateLimitedErr.RetryAfter)
+	}
+}
+
+// NewRiotLimitersFromEnv builds the budget and method rate limiter. RIOT_RATE_LIMIT_STORE selects whether
+// their state is kept in "memory" (the default) or in "dynamodb" so it is shared across Lambda instances.
+// RIOT_METHOD_RATE_LIMIT and RIOT_METHOD_RATE_LIMIT_WINDOW set the per platform and method limit.
+func NewRiotLimitersFromEnv(client limiterDynamoDbService, tableName string) (*RiotBudget, *TokenBucketLimiter, error) {
+	var counter budgetCounter
+	var store bucketStore
+
+	switch storeType := os.Getenv("RIOT_RATE_LIMIT_STORE"); storeType {
+	case "", "memory":
+		counter = NewMemoryBudgetCounter()
+		store = NewMemoryBucketStore()
+	case "dynamodb":
+		dynamoDbStore := NewDynamoDBLimiterStore(client, tableName)
+		counter = dynamoDbStore
+		store = dynamoDbStore
+	default:
+		return nil, nil, fmt.Errorf("invalid RIOT_RATE_LIMIT_STORE '%s'", storeType)
+	}
+
+	budget, err := NewRiotBudgetFromEnv(counter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	limit := 1600
+	if limitStr := os.Getenv("RIOT_METHOD_RATE_LIMIT"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return nil, nil, fmt.Errorf("invalid RIOT_METHOD_RATE_LIMIT '%s'", limitStr)
+		}
+	}
+
+	window := time.Minute
+	if windowStr := os.Getenv("RIOT_METHOD_RATE_LIMIT_WINDOW"); windowStr != "" {
+		window, err = time.ParseDuration(windowStr)
+		if err != nil || window <= 0 {
+			return nil, nil, fmt.Errorf("invalid RIOT_METHOD_RATE_LIMIT_WINDOW '%s'", windowStr)
+		}
+	}
+
+	return budget, NewTokenBucketLimiter(store, limit, window), nil
+}
+
+type MemoryBucketStore struct {
+	mu      sync.Mutex
+	buckets map[string]tokenBucket
+}
+
+func NewMemoryBucketStore() *MemoryBucketStore {
+	return &MemoryBucketStore{buckets: make(map[string]tokenBucket)}
+}
+
+func (m *MemoryBucketStore) Load(_ context.Context, key string) (*tokenBucket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bucket, ok := m.buckets[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return &bucket, nil
+}
+
+func (m *MemoryBucketStore) Save(_ context.Context, key string, bucket *tokenBucket, previousVersion int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.buckets[key].Version != previousVersion {
+		return false, nil
+	}
+
+	m.buckets[key] = *bucket
+	return true, nil
+}
+
+type limiterDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+}
+
+// DynamoDBLimiterStore keeps token buckets and budget counters as items in the summoners table so that
+// every Lambda instance shares them. Items are written with a "ttl" attribute, which expires them once TTL
+// is enabled on the table, see infrastructure/README.md. Budget counters are also deleted as windows roll
+// over, see RiotBudget.
+type DynamoDBLimiterStore struct {
+	dynamodb  limiterDynamoDbService
+	tableName string
+	now       func() time.Time
+}
+
+func NewDynamoDBLimiterStore(client limiterDynamoDbService, tableName string) *DynamoDBLimiterStore {
+	return &DynamoDBLimiterStore{dynamodb: client, tableName: tableName, now: time.Now}
+}
+
+func (d *DynamoDBLimiterStore) Load(ctx context.Context, key string) (*tokenBucket, error) {
+	output, err := d.dynamodb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(d.tableName),
+		Key:            map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: "ratelimit#" + key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	tokens, err := numberAttribute(output.Item, "tk")
+	if err != nil {
+		return nil, err
+	}
+
+	updatedAt, err := numberAttribute(output.Item, "ua")
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := numberAttribute(output.Item, "ver")
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenBucket{Tokens: tokens, UpdatedAt: int64(updatedAt), Version: int64(version)}, nil
+}
+
+func (d *DynamoDBLimiterStore) Save(ctx context.Context, key string, bucket *tokenBucket, previousVersion int64) (bool, error) {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(d.tableName),
+		Item: map[string]types.AttributeValue{
+			"n":   &types.AttributeValueMemberS{Value: "ratelimit#" + key},
+			"tk":  &types.AttributeValueMemberN{Value: strconv.FormatFloat(bucket.Tokens, 'f', -1, 64)},
+			"ua":  &types.AttributeValueMemberN{Value: strconv.FormatInt(bucket.UpdatedAt, 10)},
+			"ver": &types.AttributeValueMemberN{Value: strconv.FormatInt(bucket.Version, 10)},
+			"ttl": &types.AttributeValueMemberN{Value: strconv.FormatInt(d.now().Add(24*time.Hour).Unix(), 10)},
+		},
+	}
+
+	if previousVersion == 0 {
+		input.ConditionExpression = aws.String("attribute_not_exists(n)")
+	} else {
+		input.ConditionExpression = aws.String("ver = :ver")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":ver": &types.AttributeValueMemberN{Value: strconv.FormatInt(previousVersion, 10)},
+		}
+	}
+
+	_, err := d.dynamodb.PutItem(ctx, input)
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Increment adds one to a counter unless it already reached limit, in which case it is left as it is.
+func (d *DynamoDBLimiterStore) Increment(ctx context.Context, key string, limit int64, expiresAt time.Time) (int64, bool, error) {
+	output, err := d.dynamodb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(d.tableName),
+		Key:                 map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: key}},
+		UpdateExpression:    aws.String("ADD c :one SET #ttl = :ttl"),
+		ConditionExpression: aws.String("attribute_not_exists(c) OR c < :limit"),
+		ExpressionAttributeNames: map[string]string{
+			"#ttl": "ttl",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+			":limit": &types.AttributeValueMemberN{Value: strconv.FormatInt(limit, 10)},
+			":ttl":   &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return limit, false, nil
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	count, err := numberAttribute(output.Attributes, "c")
+	return int64(count), err == nil, err
+}
+
+// Decrement takes one off a counter, giving back a request that was counted but not made.
+func (d *DynamoDBLimiterStore) Decrement(ctx context.Context, key string) error {
+	_, err := d.dynamodb.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(d.tableName),
+		Key:                 map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: key}},
+		UpdateExpression:    aws.String("ADD c :minusOne"),
+		ConditionExpression: aws.String("c > :zero"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":minusOne": &types.AttributeValueMemberN{Value: "-1"},
+			":zero":     &types.AttributeValueMemberN{Value: "0"},
+		},
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return nil
+	}
+
+	return err
+}
+
+func (d *DynamoDBLimiterStore) Get(ctx context.Context, key string) (int64, error) {
+	output, err := d.dynamodb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(d.tableName),
+		Key:            map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if output.Item == nil {
+		return 0, nil
+	}
+
+	count, err := numberAttribute(output.Item, "c")
+	return int64(count), err
+}
+
+func (d *DynamoDBLimiterStore) Delete(ctx context.Context, key string) error {
+	_, err := d.dynamodb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.tableName),
+		Key:       map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: key}},
+	})
+
+	return err
+}
+
+func numberAttribute(item map[string]types.AttributeValue, name string) (float64, error) {
+	attribute, ok := item[name].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("attribute '%s' is missing or not a number", name)
+	}
+
+	return strconv.ParseFloat(attribute.Value, 64)
+}
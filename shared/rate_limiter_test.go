@@ -0,0 +1,290 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"testing"
+	"time"
+)
+
+type LimiterDynamoDBServiceMock struct {
+	Items           map[string]map[string]types.AttributeValue
+	ConflictOnPut   bool
+	ShouldFail      bool
+	PutItemCalls    []*dynamodb.PutItemInput
+	UpdateItemCalls []*dynamodb.UpdateItemInput
+}
+
+func (d *LimiterDynamoDBServiceMock) GetItem(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if d.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &dynamodb.GetItemOutput{Item: d.Items[input.Key["n"].(*types.AttributeValueMemberS).Value]}, nil
+}
+
+func (d *LimiterDynamoDBServiceMock) PutItem(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	d.PutItemCalls = append(d.PutItemCalls, input)
+
+	if d.ConflictOnPut {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	d.Items[input.Item["n"].(*types.AttributeValueMemberS).Value] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (d *LimiterDynamoDBServiceMock) UpdateItem(_ context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	d.UpdateItemCalls = append(d.UpdateItemCalls, input)
+
+	key := input.Key["n"].(*types.AttributeValueMemberS).Value
+	count := 0
+	if item, ok := d.Items[key]; ok {
+		fmt.Sscanf(item["c"].(*types.AttributeValueMemberN).Value, "%d", &count)
+	}
+
+	if limit, ok := input.ExpressionAttributeValues[":limit"]; ok && count >= int(numberValue(limit)) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	if _, ok := input.ExpressionAttributeValues[":minusOne"]; ok {
+		if count <= 0 {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+		count--
+	} else {
+		count++
+	}
+
+	attributes := map[string]types.AttributeValue{"c": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", count)}}
+	d.Items[key] = attributes
+	return &dynamodb.UpdateItemOutput{Attributes: attributes}, nil
+}
+
+func (d *LimiterDynamoDBServiceMock) DeleteItem(_ context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	delete(d.Items, input.Key["n"].(*types.AttributeValueMemberS).Value)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+type ConflictingBucketStore struct {
+	*MemoryBucketStore
+	Conflicts int
+}
+
+func (c *ConflictingBucketStore) Save(ctx context.Context, key string, bucket *tokenBucket, previousVersion int64) (bool, error) {
+	if c.Conflicts > 0 {
+		c.Conflicts--
+		return false, nil
+	}
+
+	return c.MemoryBucketStore.Save(ctx, key, bucket, previousVersion)
+}
+
+func newTestLimiter(store bucketStore, limit int, window time.Duration) (*TokenBucketLimiter, *fakeClock) {
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	limiter := NewTokenBucketLimiter(store, limit, window)
+	limiter.now = clock.Now
+	limiter.sleep = clock.Advance
+	return limiter, clock
+}
+
+func TestTokenBucketLimiter_AllowsBurstThenLimits(t *testing.T) {
+	limiter, _ := newTestLimiter(NewMemoryBucketStore(), 3, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if err := limiter.Take(context.TODO(), "na1#method"); err != nil {
+			t.Errorf("expected nil, got %v", err)
+		}
+	}
+
+	err := limiter.Take(context.TODO(), "na1#method")
+
+	var rateLimitedErr *RateLimitedError
+	if !errors.As(err, &rateLimitedErr) {
+		t.Fatalf("expected RateLimitedError, got %v", err)
+	}
+
+	if rateLimitedErr.RetryAfter != 20*time.Second {
+		t.Errorf("expected 20s, got %s", rateLimitedErr.RetryAfter)
+	}
+}
+
+func TestTokenBucketLimiter_RefillsOverTime(t *testing.T) {
+	limiter, clock := newTestLimiter(NewMemoryBucketStore(), 3, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		_ = limiter.Take(context.TODO(), "na1#method")
+	}
+
+	clock.Advance(20 * time.Second)
+	if err := limiter.Take(context.TODO(), "na1#method"); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestTokenBucketLimiter_KeysAreIndependent(t *testing.T) {
+	limiter, _ := newTestLimiter(NewMemoryBucketStore(), 1, time.Minute)
+
+	_ = limiter.Take(context.TODO(), RateLimitKey("na1", "method"))
+	if err := limiter.Take(context.TODO(), RateLimitKey("euw1", "method")); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestTokenBucketLimiter_RetriesOnConflictingUpdate(t *testing.T) {
+	store := &ConflictingBucketStore{MemoryBucketStore: NewMemoryBucketStore(), Conflicts: 2}
+	limiter, _ := newTestLimiter(store, 1, time.Minute)
+
+	if err := limiter.Take(context.TODO(), "na1#method"); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestTokenBucketLimiter_GivesUpAfterTooManyConflicts(t *testing.T) {
+	store := &ConflictingBucketStore{MemoryBucketStore: NewMemoryBucketStore(), Conflicts: 10}
+	limiter, _ := newTestLimiter(store, 1, time.Minute)
+
+	if err := limiter.Take(context.TODO(), "na1#method"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestTokenBucketLimiter_WaitSleepsUntilTokenIsAvailable(t *testing.T) {
+	limiter, clock := newTestLimiter(NewMemoryBucketStore(), 60, time.Minute)
+	start := clock.Now()
+
+	for i := 0; i < 61; i++ {
+		if err := limiter.Wait(context.TODO(), "na1#method", 2*time.Second); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+	}
+
+	if clock.Now().Sub(start) != time.Second {
+		t.Errorf("expected to sleep 1s, slept %s", clock.Now().Sub(start))
+	}
+}
+
+func TestTokenBucketLimiter_WaitGivesUpWhenTokenIsTooFarAway(t *testing.T) {
+	limiter, _ := newTestLimiter(NewMemoryBucketStore(), 1, time.Hour)
+
+	_ = limiter.Take(context.TODO(), "na1#method")
+	if err := limiter.Wait(context.TODO(), "na1#method", time.Second); !IsThrottled(err) {
+		t.Errorf("expected throttled error, got %v", err)
+	}
+}
+
+func TestTokenBucketLimiter_WorksWithDynamoDBStore(t *testing.T) {
+	mock := &LimiterDynamoDBServiceMock{Items: make(map[string]map[string]types.AttributeValue)}
+	limiter, _ := newTestLimiter(NewDynamoDBLimiterStore(mock, "test-table"), 2, time.Minute)
+
+	_ = limiter.Take(context.TODO(), "na1#method")
+	_ = limiter.Take(context.TODO(), "na1#method")
+	err := limiter.Take(context.TODO(), "na1#method")
+	if !IsThrottled(err) {
+		t.Errorf("expected throttled error, got %v", err)
+	}
+
+	if *mock.PutItemCalls[0].ConditionExpression != "attribute_not_exists(n)" {
+		t.Errorf("expected attribute_not_exists(n), got %s", *mock.PutItemCalls[0].ConditionExpression)
+	}
+
+	if *mock.PutItemCalls[1].ConditionExpression != "ver = :ver" {
+		t.Errorf("expected ver = :ver, got %s", *mock.PutItemCalls[1].ConditionExpression)
+	}
+
+	if mock.PutItemCalls[1].Item["n"].(*types.AttributeValueMemberS).Value != "ratelimit#na1#method" {
+		t.Errorf("expected ratelimit#na1#method, got %s", mock.PutItemCalls[1].Item["n"].(*types.AttributeValueMemberS).Value)
+	}
+}
+
+func TestDynamoDBLimiterStore_ReturnsFalseOnConditionalCheckFailure(t *testing.T) {
+	mock := &LimiterDynamoDBServiceMock{Items: make(map[string]map[string]types.AttributeValue), ConflictOnPut: true}
+	store := NewDynamoDBLimiterStore(mock, "test-table")
+
+	saved, err := store.Save(context.TODO(), "key", &tokenBucket{Tokens: 1, Version: 2}, 1)
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if saved {
+		t.Errorf("expected save to be rejected")
+	}
+}
+
+func TestDynamoDBLimiterStore_IncrementsCounters(t *testing.T) {
+	mock := &LimiterDynamoDBServiceMock{Items: make(map[string]map[string]types.AttributeValue)}
+	store := NewDynamoDBLimiterStore(mock, "test-table")
+
+	_, _, _ = store.Increment(context.TODO(), "budget#1#interactive", 5, time.Now())
+	count, admitted, err := store.Increment(context.TODO(), "budget#1#interactive", 5, time.Now())
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if count != 2 || !admitted {
+		t.Errorf("expected 2, got %d", count)
+	}
+
+	stored, _ := store.Get(context.TODO(), "budget#1#interactive")
+	if stored != 2 {
+		t.Errorf("expected 2, got %d", stored)
+	}
+
+	if *mock.UpdateItemCalls[0].UpdateExpression != "ADD c :one SET #ttl = :ttl" {
+		t.Errorf("unexpected update expression %s", *mock.UpdateItemCalls[0].UpdateExpression)
+	}
+}
+
+func TestDynamoDBLimiterStore_DoesNotCountPastLimit(t *testing.T) {
+	mock := &LimiterDynamoDBServiceMock{Items: make(map[string]map[string]types.AttributeValue)}
+	store := NewDynamoDBLimiterStore(mock, "test-table")
+
+	_, _, _ = store.Increment(context.TODO(), "budget#1#interactive", 1, time.Now())
+	_, admitted, err := store.Increment(context.TODO(), "budget#1#interactive", 1, time.Now())
+	if err != nil || admitted {
+		t.Errorf("expected the increment to be rejected, got %v, %v", admitted, err)
+	}
+
+	if stored, _ := store.Get(context.TODO(), "budget#1#interactive"); stored != 1 {
+		t.Errorf("expected 1, got %d", stored)
+	}
+
+	if *mock.UpdateItemCalls[1].ConditionExpression != "attribute_not_exists(c) OR c < :limit" {
+		t.Errorf("unexpected condition %s", *mock.UpdateItemCalls[1].ConditionExpression)
+	}
+}
+
+func TestDynamoDBLimiterStore_DeletesCounters(t *testing.T) {
+	mock := &LimiterDynamoDBServiceMock{Items: make(map[string]map[string]types.AttributeValue)}
+	store := NewDynamoDBLimiterStore(mock, "test-table")
+
+	_, _, _ = store.Increment(context.TODO(), "budget#1#interactive", 5, time.Now())
+	if err := store.Delete(context.TODO(), "budget#1#interactive"); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if stored, _ := store.Get(context.TODO(), "budget#1#interactive"); stored != 0 {
+		t.Errorf("expected the counter to be deleted, got %d", stored)
+	}
+}
+
+func TestDynamoDBLimiterStore_ReturnsErrorWhenGetItemFails(t *testing.T) {
+	mock := &LimiterDynamoDBServiceMock{ShouldFail: true}
+	store := NewDynamoDBLimiterStore(mock, "test-table")
+
+	if _, err := store.Load(context.TODO(), "key"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestNewRiotLimitersFromEnv_RejectsUnknownStore(t *testing.T) {
+	t.Setenv("RIOT_RATE_LIMIT_STORE", "redis")
+
+	_, _, err := NewRiotLimitersFromEnv(&LimiterDynamoDBServiceMock{}, "test-table")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -1,6 +1,9 @@
 package shared
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 type Regions struct {
 	regions map[string]string
@@ -33,3 +36,14 @@ func (r *Regions) Get(region string) (string, error) {
 func (r *Regions) GetAll() map[string]string {
 	return r.regions
 }
+
+// Sorted returns the region names in alphabetical order, so regions are always processed in the same order.
+func (r *Regions) Sorted() []string {
+	regions := make([]string, 0, len(r.regions))
+	for region := range r.regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	return regions
+}
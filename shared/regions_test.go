@@ -1,6 +1,9 @@
 package shared
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestNewRegions(t *testing.T) {
 	r := NewRegions()
@@ -67,3 +70,10 @@ func TestRegions_GetAll(t *testing.T) {
 		t.Error("LAS should be la2")
 	}
 }
+
+func TestRegions_Sorted(t *testing.T) {
+	regions := NewRegions().Sorted()
+	if strings.Join(regions, ",") != "EUNE,EUW,LAS,NA,OCE" {
+		t.Errorf("unexpected regions %v", regions)
+	}
+}
@@ -0,0 +1,320 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type budgetCounter interface {
+	Increment(ctx context.Context, key string, limit int64, expiresAt time.Time) (int64, bool, error)
+	Decrement(ctx context.Context, key string) error
+	Get(ctx context.Context, key string) (int64, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type BudgetExhaustedError struct {
+	Workload   string
+	RetryAfter time.Duration
+}
+
+func (e *BudgetExhaustedError) Error() string {
+	return fmt.Sprintf("riot api budget for %s requests is exhausted, retry after %s", e.Workload, e.RetryAfter)
+}
+
+type BudgetUsage struct {
+	WindowStart         int64 `json:"windowStart"`
+	Limit               int64 `json:"limit"`
+	Reserved            int64 `json:"reserved"`
+	InteractiveUsed     int64 `json:"interactiveUsed"`
+	BackgroundUsed      int64 `json:"backgroundUsed"`
+	BackgroundAllowance int64 `json:"backgroundAllowance"`
+}
+
+type RiotBudget struct {
+	counter          budgetCounter
+	limit            int64
+	window           time.Duration
+	reservedShare    float64
+	suggestionsShare float64
+	now              func() time.Time
+}
+
+// NewRiotBudget splits limit requests per window between interactive lookups and background refreshes.
+// A reservedShare of every window is kept for interactive lookups, and background refreshes give up more
+// of the window whenever interactive demand in the current or previous window exceeds that reservation.
+// Suggestions count as interactive lookups, and may use a fifth of the reservation unless set otherwise
+// with SuggestionsShare.
+func NewRiotBudget(counter budgetCounter, limit int64, window time.Duration, reservedShare float64) *RiotBudget {
+	return &RiotBudget{
+		counter:          counter,
+		limit:            limit,
+		window:           window,
+		reservedShare:    reservedShare,
+		suggestionsShare: 0.2,
+		now:              time.Now,
+	}
+}
+
+// SuggestionsShare sets the share of the interactive reservation suggestions may use every window.
+func (b *RiotBudget) SuggestionsShare(share float64) {
+	b.suggestionsShare = share
+}
+
+// NewRiotBudgetFromEnv reads RIOT_RATE_LIMIT, RIOT_RATE_LIMIT_WINDOW, RIOT_INTERACTIVE_SHARE and
+// RIOT_SUGGESTIONS_SHARE, defaulting to the development key limit of 100 requests every 2 minutes with
+// half reserved.
+func NewRiotBudgetFromEnv(counter budgetCounter) (*RiotBudget, error) {
+	limit := int64(100)
+	if limitStr := os.Getenv("RIOT_RATE_LIMIT"); limitStr != "" {
+		var err error
+		limit, err = strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid RIOT_RATE_LIMIT '%s'", limitStr)
+		}
+	}
+
+	window := 2 * time.Minute
+	if windowStr := os.Getenv("RIOT_RATE_LIMIT_WINDOW"); windowStr != "" {
+		var err error
+		window, err = time.ParseDuration(windowStr)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid RIOT_RATE_LIMIT_WINDOW '%s'", windowStr)
+		}
+	}
+
+	share := 0.5
+	if shareStr := os.Getenv("RIOT_INTERACTIVE_SHARE"); shareStr != "" {
+		var err error
+		share, err = strconv.ParseFloat(shareStr, 64)
+		if err != nil || share < 0 || share > 1 {
+			return nil, fmt.Errorf("invalid RIOT_INTERACTIVE_SHARE '%s'", shareStr)
+		}
+	}
+
+	budget := NewRiotBudget(counter, limit, window, share)
+	if shareStr := os.Getenv("RIOT_SUGGESTIONS_SHARE"); shareStr != "" {
+		suggestionsShare, err := strconv.ParseFloat(shareStr, 64)
+		if err != nil || suggestionsShare < 0 || suggestionsShare > 1 {
+			return nil, fmt.Errorf("invalid RIOT_SUGGESTIONS_SHARE '%s'", shareStr)
+		}
+
+		budget.SuggestionsShare(suggestionsShare)
+	}
+
+	return budget, nil
+}
+
+func (b *RiotBudget) Acquire(ctx context.Context, workload string) error {
+	windowStart := b.windowStart()
+	expiresAt := windowStart.Add(2 * b.window)
+
+	if workload == WorkloadInteractive || workload == WorkloadSuggestions {
+		background, err := b.counter.Get(ctx, b.key(windowStart, WorkloadBackground))
+		if err != nil {
+			return err
+		}
+
+		admitted, err := b.increment(ctx, windowStart, WorkloadInteractive, b.limit-background, expiresAt)
+		if err != nil {
+			return err
+		}
+
+		if !admitted {
+			return b.exhausted(workload, windowStart)
+		}
+
+		if workload == WorkloadInteractive {
+			return nil
+		}
+
+		suggestionsLimit := int64(math.Floor(float64(b.limit) * b.reservedShare * b.suggestionsShare))
+		admitted, err = b.increment(ctx, windowStart, WorkloadSuggestions, suggestionsLimit, expiresAt)
+		if err == nil && admitted {
+			return nil
+		}
+
+		// The interactive request was counted before the suggestions share turned it away, so hand it back.
+		if decrementErr := b.counter.Decrement(ctx, b.key(windowStart, WorkloadInteractive)); decrementErr != nil {
+			log.Printf("could not release interactive budget of rejected suggestion: %v", decrementErr)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return b.exhausted(workload, windowStart)
+	}
+
+	usage, err := b.usage(ctx, windowStart)
+	if err != nil {
+		return err
+	}
+
+	admitted, err := b.increment(ctx, windowStart, WorkloadBackground, usage.BackgroundAllowance, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	if !admitted {
+		return b.exhausted(workload, windowStart)
+	}
+
+	return nil
+}
+
+// increment counts a request against a workload's counter for the window if fewer than limit requests
+// were counted, and reports whether it was. Rejected requests are not counted, so they do not use up the
+// window. The first request of a window deletes the workload's counter from two windows back, which is no
+// longer read, so counters do not pile up in the table when TTL is not enabled on it.
+func (b *RiotBudget) increment(ctx context.Context, windowStart time.Time, workload string, limit int64, expiresAt time.Time) (bool, error) {
+	if limit <= 0 {
+		return false, nil
+	}
+
+	used, admitted, err := b.counter.Increment(ctx, b.key(windowStart, workload), limit, expiresAt)
+	if err != nil || !admitted || used != 1 {
+		return admitted, err
+	}
+
+	if err = b.counter.Delete(ctx, b.key(windowStart.Add(-2*b.window), workload)); err != nil {
+		log.Printf("could not delete expired %s budget counter: %v", workload, err)
+	}
+
+	return true, nil
+}
+
+func (b *RiotBudget) Usage(ctx context.Context) (*BudgetUsage, error) {
+	return b.usage(ctx, b.windowStart())
+}
+
+func (b *RiotBudget) usage(ctx context.Context, windowStart time.Time) (*BudgetUsage, error) {
+	interactive, err := b.counter.Get(ctx, b.key(windowStart, WorkloadInteractive))
+	if err != nil {
+		return nil, err
+	}
+
+	previousInteractive, err := b.counter.Get(ctx, b.key(windowStart.Add(-b.window), WorkloadInteractive))
+	if err != nil {
+		return nil, err
+	}
+
+	background, err := b.counter.Get(ctx, b.key(windowStart, WorkloadBackground))
+	if err != nil {
+		return nil, err
+	}
+
+	reserved := int64(math.Ceil(float64(b.limit) * b.reservedShare))
+	demand := interactive
+	if previousInteractive > demand {
+		demand = previousInteractive
+	}
+
+	if demand > reserved {
+		reserved = demand
+	}
+
+	allowance := b.limit - reserved
+	if remaining := b.limit - interactive; remaining < allowance {
+		allowance = remaining
+	}
+
+	if allowance < 0 {
+		allowance = 0
+	}
+
+	return &BudgetUsage{
+		WindowStart:         windowStart.UnixMilli(),
+		Limit:               b.limit,
+		Reserved:            reserved,
+		InteractiveUsed:     interactive,
+		BackgroundUsed:      background,
+		BackgroundAllowance: allowance,
+	}, nil
+}
+
+func (b *RiotBudget) windowStart() time.Time {
+	return b.now().Truncate(b.window)
+}
+
+func (b *RiotBudget) key(windowStart time.Time, workload string) string {
+	return "budget#" + strconv.FormatInt(windowStart.UnixMilli(), 10) + "#" + workload
+}
+
+func (b *RiotBudget) exhausted(workload string, windowStart time.Time) error {
+	return &BudgetExhaustedError{Workload: workload, RetryAfter: windowStart.Add(b.window).Sub(b.now())}
+}
+
+// MemoryBudgetCounter keeps counters in process. It only coordinates callers within one Lambda instance.
+type MemoryBudgetCounter struct {
+	mu       sync.Mutex
+	counts   map[string]int64
+	expiries map[string]time.Time
+	now      func() time.Time
+}
+
+func NewMemoryBudgetCounter() *MemoryBudgetCounter {
+	return &MemoryBudgetCounter{
+		counts:   make(map[string]int64),
+		expiries: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func (m *MemoryBudgetCounter) Increment(_ context.Context, key string, limit int64, expiresAt time.Time) (int64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire()
+	if m.counts[key] >= limit {
+		return m.counts[key], false, nil
+	}
+
+	m.counts[key]++
+	m.expiries[key] = expiresAt
+	return m.counts[key], true, nil
+}
+
+func (m *MemoryBudgetCounter) Decrement(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire()
+	if m.counts[key] > 0 {
+		m.counts[key]--
+	}
+
+	return nil
+}
+
+func (m *MemoryBudgetCounter) Get(_ context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire()
+	return m.counts[key], nil
+}
+
+func (m *MemoryBudgetCounter) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.counts, key)
+	delete(m.expiries, key)
+	return nil
+}
+
+func (m *MemoryBudgetCounter) expire() {
+	now := m.now()
+	for key, expiresAt := range m.expiries {
+		if !now.Before(expiresAt) {
+			delete(m.counts, key)
+			delete(m.expiries, key)
+		}
+	}
+}
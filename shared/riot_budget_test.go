@@ -0,0 +1,201 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"testing"
+	"time"
+)
+
+func newTestRiotBudget(limit int64, share float64) (*RiotBudget, *fakeClock) {
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	counter := NewMemoryBudgetCounter()
+	counter.now = clock.Now
+	budget := NewRiotBudget(counter, limit, 2*time.Minute, share)
+	budget.now = clock.Now
+	return budget, clock
+}
+
+func acquireN(budget *RiotBudget, workload string, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		if budget.Acquire(context.TODO(), workload) == nil {
+			allowed++
+		}
+	}
+
+	return allowed
+}
+
+func TestRiotBudget_BackgroundIsLimitedToUnreservedShare(t *testing.T) {
+	budget, _ := newTestRiotBudget(10, 0.3)
+
+	allowed := acquireN(budget, WorkloadBackground, 20)
+	if allowed != 7 {
+		t.Errorf("expected 7, got %d", allowed)
+	}
+}
+
+func TestRiotBudget_InteractiveCanUseWholeWindow(t *testing.T) {
+	budget, _ := newTestRiotBudget(10, 0.3)
+
+	allowed := acquireN(budget, WorkloadInteractive, 20)
+	if allowed != 10 {
+		t.Errorf("expected 10, got %d", allowed)
+	}
+}
+
+func TestRiotBudget_InteractiveGetsReservedShareAfterBackgroundBurst(t *testing.T) {
+	budget, _ := newTestRiotBudget(10, 0.3)
+
+	acquireN(budget, WorkloadBackground, 20)
+	allowed := acquireN(budget, WorkloadInteractive, 20)
+	if allowed != 3 {
+		t.Errorf("expected 3, got %d", allowed)
+	}
+}
+
+func TestRiotBudget_BackgroundThrottlesWhenInteractiveDemandRises(t *testing.T) {
+	budget, clock := newTestRiotBudget(10, 0.3)
+
+	acquireN(budget, WorkloadInteractive, 6)
+	clock.Advance(2 * time.Minute)
+
+	allowed := acquireN(budget, WorkloadBackground, 20)
+	if allowed != 4 {
+		t.Errorf("expected 4, got %d", allowed)
+	}
+}
+
+func TestRiotBudget_SuggestionsAreLimitedToShareOfReservation(t *testing.T) {
+	budget, _ := newTestRiotBudget(20, 0.5)
+
+	if allowed := acquireN(budget, WorkloadSuggestions, 10); allowed != 2 {
+		t.Errorf("expected 2 suggestions, got %d", allowed)
+	}
+
+	if allowed := acquireN(budget, WorkloadInteractive, 20); allowed != 18 {
+		t.Errorf("expected suggestions to count toward interactive lookups, got %d", allowed)
+	}
+
+	budget, _ = newTestRiotBudget(20, 0.5)
+	budget.SuggestionsShare(0.5)
+	if allowed := acquireN(budget, WorkloadSuggestions, 10); allowed != 5 {
+		t.Errorf("expected 5 suggestions with a larger share, got %d", allowed)
+	}
+}
+
+func TestRiotBudget_DoesNotCountRejectedRequests(t *testing.T) {
+	budget, _ := newTestRiotBudget(10, 0.3)
+
+	acquireN(budget, WorkloadInteractive, 50)
+	acquireN(budget, WorkloadSuggestions, 50)
+
+	usage, _ := budget.Usage(context.TODO())
+	if usage.InteractiveUsed != 10 {
+		t.Errorf("expected only admitted requests to be counted, got %d", usage.InteractiveUsed)
+	}
+}
+
+func TestRiotBudget_RejectedSuggestionsDoNotUseInteractiveBudget(t *testing.T) {
+	budget, _ := newTestRiotBudget(20, 0.5)
+
+	acquireN(budget, WorkloadSuggestions, 50)
+
+	usage, _ := budget.Usage(context.TODO())
+	if usage.InteractiveUsed != 2 {
+		t.Errorf("expected 2, got %d", usage.InteractiveUsed)
+	}
+}
+
+func TestRiotBudget_ResetsEveryWindow(t *testing.T) {
+	budget, clock := newTestRiotBudget(10, 0.3)
+
+	acquireN(budget, WorkloadBackground, 20)
+	clock.Advance(2 * time.Minute)
+
+	if err := budget.Acquire(context.TODO(), WorkloadBackground); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestRiotBudget_DeletesCountersOfExpiredWindows(t *testing.T) {
+	mock := &LimiterDynamoDBServiceMock{Items: make(map[string]map[string]types.AttributeValue)}
+	budget := NewRiotBudget(NewDynamoDBLimiterStore(mock, "test-table"), 10, 2*time.Minute, 0.3)
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	budget.now = clock.Now
+
+	for i := 0; i < 4; i++ {
+		acquireN(budget, WorkloadInteractive, 2)
+		clock.Advance(2 * time.Minute)
+	}
+
+	if len(mock.Items) != 2 {
+		t.Errorf("expected only the counters of the last two windows to be kept, got %v", mock.Items)
+	}
+}
+
+func TestRiotBudget_ReturnsBudgetExhaustedErrorWithRetryAfter(t *testing.T) {
+	budget, clock := newTestRiotBudget(1, 1)
+	clock.Advance(30 * time.Second)
+
+	err := budget.Acquire(context.TODO(), WorkloadBackground)
+
+	var budgetErr *BudgetExhaustedError
+	if !errors.As(err, &budgetErr) {
+		t.Fatalf("expected BudgetExhaustedError, got %v", err)
+	}
+
+	if budgetErr.RetryAfter != 90*time.Second {
+		t.Errorf("expected 90s, got %s", budgetErr.RetryAfter)
+	}
+}
+
+func TestRiotBudget_UsageReportsAllocation(t *testing.T) {
+	budget, _ := newTestRiotBudget(10, 0.3)
+
+	acquireN(budget, WorkloadInteractive, 5)
+	acquireN(budget, WorkloadBackground, 2)
+
+	usage, err := budget.Usage(context.TODO())
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if usage.Limit != 10 || usage.Reserved != 5 || usage.InteractiveUsed != 5 || usage.BackgroundUsed != 2 || usage.BackgroundAllowance != 5 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+}
+
+func TestMemoryBudgetCounter_ExpiresCounters(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	counter := NewMemoryBudgetCounter()
+	counter.now = clock.Now
+
+	_, _, _ = counter.Increment(context.TODO(), "key", 5, clock.Now().Add(time.Minute))
+	clock.Advance(time.Minute)
+
+	count, _ := counter.Get(context.TODO(), "key")
+	if count != 0 {
+		t.Errorf("expected 0, got %d", count)
+	}
+}
+
+func TestNewRiotBudgetFromEnv_RejectsInvalidShare(t *testing.T) {
+	t.Setenv("RIOT_INTERACTIVE_SHARE", "2")
+
+	_, err := NewRiotBudgetFromEnv(NewMemoryBudgetCounter())
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestNewRiotBudgetFromEnv_RejectsInvalidSuggestionsShare(t *testing.T) {
+	t.Setenv("RIOT_SUGGESTIONS_SHARE", "-1")
+
+	_, err := NewRiotBudgetFromEnv(NewMemoryBudgetCounter())
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,228 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Workloads share the Riot API budget, see RiotBudget. Suggestions are interactive lookups capped to a
+// share of the interactive reservation, so name suggestions cannot crowd out summoner lookups.
+const (
+	WorkloadInteractive = "interactive"
+	WorkloadBackground  = "background"
+	WorkloadSuggestions = "suggestions"
+)
+
+type SecretsSource interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+type ssmService interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+type secretsManagerService interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+type SSMSecretsSource struct {
+	ssm ssmService
+}
+
+func NewSSMSecretsSource(cfg aws.Config) *SSMSecretsSource {
+	return &SSMSecretsSource{ssm: ssm.NewFromConfig(cfg)}
+}
+
+func (s *SSMSecretsSource) GetSecret(ctx context.Context, name string) (string, error) {
+	output, err := s.ssm.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if output.Parameter == nil || output.Parameter.Value == nil {
+		return "", fmt.Errorf("parameter '%s' has no value", name)
+	}
+
+	return *output.Parameter.Value, nil
+}
+
+type SecretsManagerSource struct {
+	secretsManager secretsManagerService
+}
+
+func NewSecretsManagerSource(cfg aws.Config) *SecretsManagerSource {
+	return &SecretsManagerSource{secretsManager: secretsmanager.NewFromConfig(cfg)}
+}
+
+func (s *SecretsManagerSource) GetSecret(ctx context.Context, name string) (string, error) {
+	output, err := s.secretsManager.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if output.SecretString == nil {
+		return "", fmt.Errorf("secret '%s' has no string value", name)
+	}
+
+	return *output.SecretString, nil
+}
+
+// StaticSecretsSource serves secrets from memory, for local development and tests.
+type StaticSecretsSource map[string]string
+
+func (s StaticSecretsSource) GetSecret(_ context.Context, name string) (string, error) {
+	if value, ok := s[name]; ok {
+		return value, nil
+	}
+
+	return "", fmt.Errorf("secret '%s' not found", name)
+}
+
+type RiotKeys struct {
+	mu          sync.Mutex
+	source      SecretsSource
+	names       []string
+	ttl         time.Duration
+	keys        []string
+	loadedAt    time.Time
+	next        int
+	assignments map[string]int
+	now         func() time.Time
+}
+
+// NewRiotKeys loads the Riot API keys stored under names from source. A secret may hold several keys
+// separated by commas or newlines. Keys are cached for ttl and reloaded after Invalidate.
+func NewRiotKeys(source SecretsSource, names []string, ttl time.Duration) *RiotKeys {
+	return &RiotKeys{
+		source:      source,
+		names:       names,
+		ttl:         ttl,
+		assignments: make(map[string]int),
+		now:         time.Now,
+	}
+}
+
+// NewRiotKeysFromEnv reads RIOT_API_KEY_SOURCE (ssm or secretsmanager), RIOT_API_KEY_NAMES,
+// RIOT_API_KEY_TTL and RIOT_API_KEY_ASSIGNMENTS (e.g. "interactive=0,background=1"). When no names
+// are configured it falls back to the RIOT_API_TOKEN variable.
+func NewRiotKeysFromEnv(cfg aws.Config) (*RiotKeys, error) {
+	ttl := 15 * time.Minute
+	if ttlStr := os.Getenv("RIOT_API_KEY_TTL"); ttlStr != "" {
+		var err error
+		ttl, err = time.ParseDuration(ttlStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RIOT_API_KEY_TTL '%s': %v", ttlStr, err)
+		}
+	}
+
+	var keys *RiotKeys
+	names := splitList(os.Getenv("RIOT_API_KEY_NAMES"))
+	if len(names) == 0 {
+		keys = NewRiotKeys(StaticSecretsSource{"RIOT_API_TOKEN": os.Getenv("RIOT_API_TOKEN")}, []string{"RIOT_API_TOKEN"}, ttl)
+	} else {
+		switch source := os.Getenv("RIOT_API_KEY_SOURCE"); source {
+		case "", "ssm":
+			keys = NewRiotKeys(NewSSMSecretsSource(cfg), names, ttl)
+		case "secretsmanager":
+			keys = NewRiotKeys(NewSecretsManagerSource(cfg), names, ttl)
+		default:
+			return nil, fmt.Errorf("invalid RIOT_API_KEY_SOURCE '%s'", source)
+		}
+	}
+
+	for _, assignment := range splitList(os.Getenv("RIOT_API_KEY_ASSIGNMENTS")) {
+		workload, indexStr, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid RIOT_API_KEY_ASSIGNMENTS entry '%s'", assignment)
+		}
+
+		index, err := strconv.Atoi(indexStr)
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("invalid RIOT_API_KEY_ASSIGNMENTS entry '%s'", assignment)
+		}
+
+		keys.Assign(workload, index)
+	}
+
+	return keys, nil
+}
+
+// Assign pins a workload to the key at index. Workloads without an assignment rotate through all keys.
+func (k *RiotKeys) Assign(workload string, index int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.assignments[workload] = index
+}
+
+func (k *RiotKeys) Get(ctx context.Context, workload string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.keys == nil || k.now().Sub(k.loadedAt) >= k.ttl {
+		err := k.load(ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if index, ok := k.assignments[workload]; ok {
+		return k.keys[index%len(k.keys)], nil
+	}
+
+	key := k.keys[k.next%len(k.keys)]
+	k.next = (k.next + 1) % len(k.keys)
+	return key, nil
+}
+
+func (k *RiotKeys) Invalidate() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys = nil
+}
+
+func (k *RiotKeys) load(ctx context.Context) error {
+	var keys []string
+	for _, name := range k.names {
+		value, err := k.source.GetSecret(ctx, name)
+		if err != nil {
+			return fmt.Errorf("could not load riot api key '%s': %v", name, err)
+		}
+
+		keys = append(keys, splitList(value)...)
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("no riot api keys configured")
+	}
+
+	k.keys = keys
+	k.loadedAt = k.now()
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
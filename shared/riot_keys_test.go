@@ -0,0 +1,221 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"testing"
+	"time"
+)
+
+type SecretsSourceMock struct {
+	Values     map[string]string
+	ShouldFail bool
+	Calls      []string
+}
+
+func (s *SecretsSourceMock) GetSecret(_ context.Context, name string) (string, error) {
+	s.Calls = append(s.Calls, name)
+
+	if s.ShouldFail {
+		return "", fmt.Errorf("error")
+	}
+
+	return s.Values[name], nil
+}
+
+type SSMServiceMock struct {
+	Calls []*ssm.GetParameterInput
+}
+
+func (s *SSMServiceMock) GetParameter(_ context.Context, input *ssm.GetParameterInput, _ ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	s.Calls = append(s.Calls, input)
+	value := "ssm-value"
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{Value: &value}}, nil
+}
+
+type SecretsManagerServiceMock struct {
+	Calls []*secretsmanager.GetSecretValueInput
+}
+
+func (s *SecretsManagerServiceMock) GetSecretValue(_ context.Context, input *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	s.Calls = append(s.Calls, input)
+	value := "secret-value"
+	return &secretsmanager.GetSecretValueOutput{SecretString: &value}, nil
+}
+
+func TestSSMSecretsSource_RequestsDecryptedParameter(t *testing.T) {
+	mock := &SSMServiceMock{}
+	source := &SSMSecretsSource{ssm: mock}
+
+	value, err := source.GetSecret(context.TODO(), "/riot-api-token")
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if value != "ssm-value" {
+		t.Errorf("expected ssm-value, got %s", value)
+	}
+
+	if *mock.Calls[0].Name != "/riot-api-token" || !*mock.Calls[0].WithDecryption {
+		t.Errorf("expected decrypted /riot-api-token, got %s", *mock.Calls[0].Name)
+	}
+}
+
+func TestSecretsManagerSource_ReturnsSecretString(t *testing.T) {
+	mock := &SecretsManagerServiceMock{}
+	source := &SecretsManagerSource{secretsManager: mock}
+
+	value, err := source.GetSecret(context.TODO(), "riot-api-keys")
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if value != "secret-value" {
+		t.Errorf("expected secret-value, got %s", value)
+	}
+
+	if *mock.Calls[0].SecretId != "riot-api-keys" {
+		t.Errorf("expected riot-api-keys, got %s", *mock.Calls[0].SecretId)
+	}
+}
+
+func TestStaticSecretsSource_ReturnsErrorForUnknownName(t *testing.T) {
+	_, err := StaticSecretsSource{}.GetSecret(context.TODO(), "missing")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestRiotKeys_CachesKeysUntilTtlExpires(t *testing.T) {
+	source := &SecretsSourceMock{Values: map[string]string{"key": "a"}}
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	keys := NewRiotKeys(source, []string{"key"}, time.Minute)
+	keys.now = clock.Now
+
+	_, _ = keys.Get(context.TODO(), WorkloadInteractive)
+	_, _ = keys.Get(context.TODO(), WorkloadInteractive)
+	if len(source.Calls) != 1 {
+		t.Errorf("expected 1, got %d", len(source.Calls))
+	}
+
+	clock.Advance(time.Minute)
+	_, _ = keys.Get(context.TODO(), WorkloadInteractive)
+	if len(source.Calls) != 2 {
+		t.Errorf("expected 2, got %d", len(source.Calls))
+	}
+}
+
+func TestRiotKeys_ReloadsAfterInvalidate(t *testing.T) {
+	source := &SecretsSourceMock{Values: map[string]string{"key": "a"}}
+	keys := NewRiotKeys(source, []string{"key"}, time.Hour)
+
+	_, _ = keys.Get(context.TODO(), WorkloadInteractive)
+	source.Values["key"] = "b"
+	keys.Invalidate()
+
+	key, err := keys.Get(context.TODO(), WorkloadInteractive)
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if key != "b" {
+		t.Errorf("expected b, got %s", key)
+	}
+}
+
+func TestRiotKeys_RoundRobinsAcrossKeys(t *testing.T) {
+	source := &SecretsSourceMock{Values: map[string]string{"first": "a, b", "second": "c"}}
+	keys := NewRiotKeys(source, []string{"first", "second"}, time.Hour)
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		key, _ := keys.Get(context.TODO(), WorkloadBackground)
+		got = append(got, key)
+	}
+
+	expected := []string{"a", "b", "c", "a"}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, got)
+			break
+		}
+	}
+}
+
+func TestRiotKeys_UsesAssignedKeyForWorkload(t *testing.T) {
+	source := &SecretsSourceMock{Values: map[string]string{"key": "a\nb"}}
+	keys := NewRiotKeys(source, []string{"key"}, time.Hour)
+	keys.Assign(WorkloadInteractive, 1)
+
+	for i := 0; i < 3; i++ {
+		key, _ := keys.Get(context.TODO(), WorkloadInteractive)
+		if key != "b" {
+			t.Errorf("expected b, got %s", key)
+		}
+	}
+}
+
+func TestRiotKeys_ReturnsErrorWhenSourceFails(t *testing.T) {
+	keys := NewRiotKeys(&SecretsSourceMock{ShouldFail: true}, []string{"key"}, time.Hour)
+
+	_, err := keys.Get(context.TODO(), WorkloadInteractive)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestRiotKeys_ReturnsErrorWhenNoKeysConfigured(t *testing.T) {
+	keys := NewRiotKeys(&SecretsSourceMock{Values: map[string]string{"key": ""}}, []string{"key"}, time.Hour)
+
+	_, err := keys.Get(context.TODO(), WorkloadInteractive)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestNewRiotKeysFromEnv_FallsBackToRiotApiToken(t *testing.T) {
+	t.Setenv("RIOT_API_KEY_NAMES", "")
+	t.Setenv("RIOT_API_TOKEN", "token")
+	t.Setenv("RIOT_API_KEY_ASSIGNMENTS", "interactive=0")
+
+	keys, err := NewRiotKeysFromEnv(aws.Config{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	key, err := keys.Get(context.TODO(), WorkloadInteractive)
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if key != "token" {
+		t.Errorf("expected token, got %s", key)
+	}
+}
+
+func TestNewRiotKeysFromEnv_RejectsUnknownSource(t *testing.T) {
+	t.Setenv("RIOT_API_KEY_NAMES", "/riot-api-token")
+	t.Setenv("RIOT_API_KEY_SOURCE", "vault")
+
+	_, err := NewRiotKeysFromEnv(aws.Config{})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestNewRiotKeysFromEnv_RejectsInvalidAssignments(t *testing.T) {
+	t.Setenv("RIOT_API_KEY_NAMES", "")
+
+	for _, assignments := range []string{"interactive", "interactive=one", "interactive=-1"} {
+		t.Setenv("RIOT_API_KEY_ASSIGNMENTS", assignments)
+
+		_, err := NewRiotKeysFromEnv(aws.Config{})
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", assignments)
+		}
+	}
+}
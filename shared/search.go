@@ -0,0 +1,570 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const searchPrefix = "search#"
+
+// Search modes. Prefix and contains match the query as is, wildcard matches the whole name against the
+// query with * matching any characters and ? any one character. Fuzzy searches rank look-alike names,
+// see FuzzyQuery.
+const (
+	SearchPrefix   = "prefix"
+	SearchContains = "contains"
+	SearchWildcard = "wildcard"
+	SearchFuzzy    = "fuzzy"
+)
+
+// searchIndexVersion is written to the "sx" attribute of summoner items whose postings are up to date.
+// Bump it when the postings change, so names are indexed again as they are saved.
+const searchIndexVersion = 2
+
+// searchGramSize is the length of the n-grams names are indexed by. Names are also indexed by their
+// prefixes up to this length, so prefix searches of any length have a gram to look up.
+const searchGramSize = 3
+
+// maxSearchQueryLength bounds the query, in characters.
+const maxSearchQueryLength = 32
+
+// searchScanSize is how many postings each query of the index reads.
+const searchScanSize = 100
+
+// searchWriteAttempts bounds how many times unprocessed postings are written again.
+const searchWriteAttempts = 5
+
+// maxFuzzyDistance is the most edits a fuzzy search allows between skeletons.
+const maxFuzzyDistance = 2
+
+// maxFuzzyCandidates bounds how many postings a fuzzy search reads.
+const maxFuzzyCandidates = 2000
+
+type searchDynamoDbService interface {
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+// searchPosting records that a name has a gram. Postings are ordered by availability date in the
+// search-gram-availability-date-index, and carry the normalized name so queries are checked against it
+// without reading the summoner.
+type searchPosting struct {
+	Key              string `dynamodbav:"n"`
+	Gram             string `dynamodbav:"sg"`
+	AvailabilityDate int64  `dynamodbav:"ad"`
+	Name             string `dynamodbav:"sn"`
+}
+
+// SearchQuery finds names in a region by prefix, substring or wildcard pattern.
+type SearchQuery struct {
+	Region  string
+	Mode    string
+	Query   string
+	gram    string
+	matcher func(name string) bool
+}
+
+// Validate normalizes the query like names are normalized, and picks the gram to look it up by.
+// Substring and wildcard queries need three consecutive characters, or for wildcards a leading one, to
+// use the index.
+func (q *SearchQuery) Validate() error {
+	if !NewRegions().Validate(q.Region) {
+		return fmt.Errorf("invalid region '%s'", q.Region)
+	}
+
+	query := NormalizeName(q.Query)
+	length := utf8.RuneCountInString(query)
+	if length == 0 {
+		return fmt.Errorf("query is required")
+	}
+	if length > maxSearchQueryLength {
+		return fmt.Errorf("query is longer than %d characters", maxSearchQueryLength)
+	}
+
+	switch q.Mode {
+	case SearchPrefix:
+		q.gram = "^" + firstRunes(query, searchGramSize)
+		q.matcher = func(name string) bool { return strings.HasPrefix(name, query) }
+	case SearchContains:
+		if length < searchGramSize {
+			return fmt.Errorf("contains searches need at least %d characters", searchGramSize)
+		}
+		q.gram = firstRunes(query, searchGramSize)
+		q.matcher = func(name string) bool { return strings.Contains(name, query) }
+	case SearchWildcard:
+		gram, err := wildcardGram(query)
+		if err != nil {
+			return err
+		}
+
+		glob := regexp.QuoteMeta(query)
+		compiled := regexp.MustCompile("^" + strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(glob) + "$")
+		q.gram = gram
+		q.matcher = compiled.MatchString
+	default:
+		return fmt.Errorf("invalid mode '%s', expected %s, %s or %s", q.Mode, SearchPrefix, SearchContains, SearchWildcard)
+	}
+
+	q.Query = query
+	return nil
+}
+
+// wildcardGram picks the leading gram when the pattern starts with enough literal characters, then the
+// first run of three literal characters, then the shorter leading gram.
+func wildcardGram(pattern string) (string, error) {
+	literals := strings.FieldsFunc(pattern, func(r rune) bool { return r == '*' || r == '?' })
+	leading := ""
+	if len(literals) > 0 && strings.HasPrefix(pattern, literals[0]) {
+		leading = firstRunes(literals[0], searchGramSize)
+	}
+
+	if utf8.RuneCountInString(leading) == searchGramSize {
+		return "^" + leading, nil
+	}
+
+	for _, literal := range literals {
+		if utf8.RuneCountInString(literal) >= searchGramSize {
+			return firstRunes(literal, searchGramSize), nil
+		}
+	}
+
+	if leading != "" {
+		return "^" + leading, nil
+	}
+
+	return "", fmt.Errorf("wildcard searches need a leading character or %d consecutive characters", searchGramSize)
+}
+
+func firstRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		runes = runes[:n]
+	}
+
+	return string(runes)
+}
+
+// indexGrams returns every gram a normalized name is indexed by.
+func indexGrams(name string) []string {
+	return append(searchGrams(name), fuzzyGrams(Skeleton(name))...)
+}
+
+// searchGrams returns the grams a normalized name is searched by: its leading one to three characters,
+// marked with ^, and every run of three characters.
+func searchGrams(name string) []string {
+	runes := []rune(name)
+	grams := make([]string, 0, 2*len(runes))
+	seen := make(map[string]bool)
+
+	for i := 1; i <= searchGramSize && i <= len(runes); i++ {
+		grams = append(grams, "^"+string(runes[:i]))
+	}
+
+	for i := 0; i+searchGramSize <= len(runes); i++ {
+		gram := string(runes[i : i+searchGramSize])
+		if !seen[gram] {
+			seen[gram] = true
+			grams = append(grams, gram)
+		}
+	}
+
+	return grams
+}
+
+// fuzzyGrams returns the grams a skeleton is found by in fuzzy searches: the skeleton itself and each
+// way of deleting one character from it, marked with ~. Two skeletons within one edit of each other,
+// and many within two, share one of them.
+func fuzzyGrams(skeleton string) []string {
+	runes := []rune(skeleton)
+	grams := []string{"~" + skeleton}
+	seen := map[string]bool{skeleton: true}
+
+	for i := range runes {
+		deleted := string(runes[:i]) + string(runes[i+1:])
+		if deleted != "" && !seen[deleted] {
+			seen[deleted] = true
+			grams = append(grams, "~"+deleted)
+		}
+	}
+
+	return grams
+}
+
+func searchPostingKey(region string, gram string, name string) string {
+	return searchPrefix + region + "#" + gram + "#" + strings.ToUpper(name)
+}
+
+type SearchPage struct {
+	Summoners []*SummonerDTO
+	Skipped   []SkippedItem
+	Filtered  int
+	// Next is the availability date to continue the search from, or 0 once every match was read.
+	Next int64
+}
+
+// NameSearch indexes names by n-gram and skeleton in the table, and answers prefix, substring and
+// wildcard searches in availability date order and fuzzy searches by closeness. Summoners keeps the index
+// in sync as names are saved and deleted.
+type NameSearch struct {
+	dynamodb  searchDynamoDbService
+	tableName string
+	filter    func(summoner *SummonerDTO) bool
+	scanSize  int32
+	sleep     func(time.Duration)
+}
+
+func NewNameSearch(client searchDynamoDbService, tableName string) *NameSearch {
+	return &NameSearch{
+		dynamodb:  client,
+		tableName: tableName,
+		scanSize:  searchScanSize,
+		sleep:     time.Sleep,
+	}
+}
+
+// FilterResults drops summoners the filter rejects from search results, like Summoners.FilterListings.
+func (n *NameSearch) FilterResults(filter func(summoner *SummonerDTO) bool) {
+	n.filter = filter
+}
+
+// Index writes the postings of a summoner's name at its availability date, replacing older ones.
+func (n *NameSearch) Index(ctx context.Context, summoner *SummonerDTO) error {
+	name := NormalizeName(summoner.Name)
+
+	var requests []types.WriteRequest
+	for _, gram := range indexGrams(name) {
+		item, err := attributevalue.MarshalMap(&searchPosting{
+			Key:              searchPostingKey(summoner.Region, gram, name),
+			Gram:             summoner.Region + "#" + gram,
+			AvailabilityDate: summoner.AvailabilityDate,
+			Name:             name,
+		})
+		if err != nil {
+			return err
+		}
+
+		requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+
+	return n.write(ctx, requests)
+}
+
+// Remove deletes the postings of a name.
+func (n *NameSearch) Remove(ctx context.Context, region string, name string) error {
+	name = NormalizeName(name)
+
+	var requests []types.WriteRequest
+	for _, gram := range indexGrams(name) {
+		requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{
+			Key: map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: searchPostingKey(region, gram, name)}},
+		}})
+	}
+
+	return n.write(ctx, requests)
+}
+
+func (n *NameSearch) write(ctx context.Context, requests []types.WriteRequest) error {
+	for start := 0; start < len(requests); start += batchWriteSize {
+		batch := requests[start:min(start+batchWriteSize, len(requests))]
+
+		for attempt := 0; len(batch) > 0; attempt++ {
+			if attempt == searchWriteAttempts {
+				return fmt.Errorf("%d postings were still unprocessed after %d attempts", len(batch), searchWriteAttempts)
+			}
+
+			if attempt > 0 {
+				n.sleep(time.Duration(1<<(attempt-1)) * 50 * time.Millisecond)
+			}
+
+			output, err := n.dynamodb.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]types.WriteRequest{n.tableName: batch},
+			})
+			if err != nil {
+				return err
+			}
+
+			batch = output.UnprocessedItems[n.tableName]
+		}
+	}
+
+	return nil
+}
+
+// Search returns up to limit summoners matching the query that become available after t1, or before it
+// when backwards, in availability date order. Postings of names that are hidden, filtered, deleted or
+// whose date has since changed are dropped, and the index is read from where the previous query stopped
+// until the page is full. Every posting up to Next was either returned or dropped.
+func (n *NameSearch) Search(ctx context.Context, query *SearchQuery, limit int32, t1 int64, backwards bool) (*SearchPage, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	keyConditionExpression := "sg = :gram and ad > :t1"
+	if backwards {
+		keyConditionExpression = "sg = :gram and ad < :t1"
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(n.tableName),
+		KeyConditionExpression: aws.String(keyConditionExpression),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":gram": &types.AttributeValueMemberS{Value: query.Region + "#" + query.gram},
+			":t1":   &types.AttributeValueMemberN{Value: strconv.FormatInt(t1, 10)},
+		},
+		IndexName:        aws.String("search-gram-availability-date-index"),
+		ScanIndexForward: aws.Bool(!backwards),
+		Limit:            aws.Int32(n.scanSize),
+	}
+
+	page := &SearchPage{Summoners: make([]*SummonerDTO, 0, limit), Next: t1}
+	for queries := 0; queries < maxListingQueries; queries++ {
+		output, err := n.dynamodb.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		var postings []searchPosting
+		if err = attributevalue.UnmarshalListOfMaps(output.Items, &postings); err != nil {
+			return nil, err
+		}
+
+		consumed := 0
+		var matches []searchPosting
+		for _, posting := range postings {
+			if len(page.Summoners)+len(matches) == int(limit) {
+				break
+			}
+
+			consumed++
+			if query.matcher(posting.Name) {
+				matches = append(matches, posting)
+			}
+		}
+
+		if err = n.resolve(ctx, query.Region, matches, page); err != nil {
+			return nil, err
+		}
+
+		if consumed > 0 {
+			page.Next = postings[consumed-1].AvailabilityDate
+		}
+
+		if consumed == len(postings) && len(output.LastEvaluatedKey) == 0 {
+			page.Next = 0
+			break
+		}
+
+		if len(page.Summoners) >= int(limit) {
+			break
+		}
+
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+		if consumed < len(postings) {
+			last := output.Items[consumed-1]
+			input.ExclusiveStartKey = map[string]types.AttributeValue{"n": last["n"], "sg": last["sg"], "ad": last["ad"]}
+		}
+	}
+
+	return page, nil
+}
+
+// FuzzyQuery finds names that look like Query, or are up to MaxDistance edits from it once both are
+// reduced to their skeletons, that are available or free by Until.
+type FuzzyQuery struct {
+	Region      string
+	Query       string
+	MaxDistance int
+	Until       int64
+	skeleton    string
+}
+
+func (q *FuzzyQuery) Validate() error {
+	if !NewRegions().Validate(q.Region) {
+		return fmt.Errorf("invalid region '%s'", q.Region)
+	}
+
+	q.skeleton = Skeleton(q.Query)
+	length := utf8.RuneCountInString(q.skeleton)
+	if length == 0 {
+		return fmt.Errorf("query is required")
+	}
+	if length > maxSearchQueryLength {
+		return fmt.Errorf("query is longer than %d characters", maxSearchQueryLength)
+	}
+
+	if q.MaxDistance < 0 || q.MaxDistance > maxFuzzyDistance {
+		return fmt.Errorf("invalid distance %d, expected at most %d", q.MaxDistance, maxFuzzyDistance)
+	}
+
+	if q.Until <= 0 {
+		return fmt.Errorf("invalid window, expected a date names free by")
+	}
+
+	return nil
+}
+
+type fuzzyMatch struct {
+	posting      searchPosting
+	distance     int
+	nameDistance int
+}
+
+// Fuzzy returns up to limit summoners matching a fuzzy query, closest first. Look-alikes rank by the
+// edit distance between skeletons, then between names, so "Faker" ranks above "Fɑker" and both above
+// "Fakerr", then by availability date. Candidates are read from the postings of the query's fuzzy grams
+// rather than by scanning names.
+func (n *NameSearch) Fuzzy(ctx context.Context, query *FuzzyQuery, limit int32) (*SearchPage, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[string]searchPosting)
+	read := 0
+	for _, gram := range fuzzyGrams(query.skeleton) {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(n.tableName),
+			KeyConditionExpression: aws.String("sg = :gram and ad <= :until"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":gram":  &types.AttributeValueMemberS{Value: query.Region + "#" + gram},
+				":until": &types.AttributeValueMemberN{Value: strconv.FormatInt(query.Until, 10)},
+			},
+			IndexName: aws.String("search-gram-availability-date-index"),
+			Limit:     aws.Int32(n.scanSize),
+		}
+
+		for read < maxFuzzyCandidates {
+			output, err := n.dynamodb.Query(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+
+			var postings []searchPosting
+			if err = attributevalue.UnmarshalListOfMaps(output.Items, &postings); err != nil {
+				return nil, err
+			}
+
+			read += len(postings)
+			for _, posting := range postings {
+				candidates[posting.Name] = posting
+			}
+
+			if len(output.LastEvaluatedKey) == 0 {
+				break
+			}
+
+			input.ExclusiveStartKey = output.LastEvaluatedKey
+		}
+	}
+
+	name := NormalizeName(query.Query)
+	matches := make([]fuzzyMatch, 0, len(candidates))
+	for _, posting := range candidates {
+		distance := EditDistance(query.skeleton, Skeleton(posting.Name))
+		if distance <= query.MaxDistance {
+			matches = append(matches, fuzzyMatch{posting: posting, distance: distance, nameDistance: EditDistance(name, posting.Name)})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.nameDistance != b.nameDistance {
+			return a.nameDistance < b.nameDistance
+		}
+		if a.posting.AvailabilityDate != b.posting.AvailabilityDate {
+			return a.posting.AvailabilityDate < b.posting.AvailabilityDate
+		}
+		return a.posting.Name < b.posting.Name
+	})
+
+	page := &SearchPage{Summoners: make([]*SummonerDTO, 0, limit)}
+	for start := 0; start < len(matches) && len(page.Summoners) < int(limit); {
+		end := min(start+int(limit)-len(page.Summoners), len(matches))
+
+		postings := make([]searchPosting, 0, end-start)
+		for _, match := range matches[start:end] {
+			postings = append(postings, match.posting)
+		}
+
+		if err := n.resolve(ctx, query.Region, postings, page); err != nil {
+			return nil, err
+		}
+
+		start = end
+	}
+
+	return page, nil
+}
+
+// resolve reads the summoners of matching postings and adds those still listed at the posting's date to
+// the page, in the postings' order.
+func (n *NameSearch) resolve(ctx context.Context, region string, postings []searchPosting, page *SearchPage) error {
+	if len(postings) == 0 {
+		return nil
+	}
+
+	keys := make([]map[string]types.AttributeValue, 0, len(postings))
+	for _, posting := range postings {
+		keys = append(keys, map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: NameKey(region, posting.Name)}})
+	}
+
+	items := make(map[string]map[string]types.AttributeValue)
+	for start := 0; start < len(keys); start += batchGetLimit {
+		batch := keys[start:min(start+batchGetLimit, len(keys))]
+
+		for len(batch) > 0 {
+			output, err := n.dynamodb.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: map[string]types.KeysAndAttributes{n.tableName: {Keys: batch}},
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, item := range output.Responses[n.tableName] {
+				if key, ok := item["n"].(*types.AttributeValueMemberS); ok {
+					items[key.Value] = item
+				}
+			}
+
+			batch = output.UnprocessedKeys[n.tableName].Keys
+		}
+	}
+
+	for _, posting := range postings {
+		item, ok := items[NameKey(region, posting.Name)]
+		if !ok {
+			continue
+		}
+
+		found := SummonersFromItems([]map[string]types.AttributeValue{item})
+		page.Skipped = append(page.Skipped, found.Skipped...)
+
+		for _, summoner := range found.Summoners {
+			if summoner.Hidden || summoner.AvailabilityDate != posting.AvailabilityDate {
+				continue
+			}
+
+			if n.filter != nil && !n.filter(summoner) {
+				page.Filtered++
+				continue
+			}
+
+			page.Summoners = append(page.Summoners, summoner)
+		}
+	}
+
+	return nil
+}
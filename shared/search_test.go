@@ -0,0 +1,310 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// SearchDynamoDBServiceMock keeps items in memory and queries postings by gram like the search index.
+type SearchDynamoDBServiceMock struct {
+	Items       map[string]map[string]types.AttributeValue
+	Unprocessed int
+	Writes      int
+}
+
+func (d *SearchDynamoDBServiceMock) Query(_ context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	gram := input.ExpressionAttributeValues[":gram"].(*types.AttributeValueMemberS).Value
+	forward := input.ScanIndexForward == nil || *input.ScanIndexForward
+	inRange := func(ad int64) bool {
+		if until, ok := input.ExpressionAttributeValues[":until"]; ok {
+			u, _ := strconv.ParseInt(until.(*types.AttributeValueMemberN).Value, 10, 64)
+			return ad <= u
+		}
+
+		t1, _ := strconv.ParseInt(input.ExpressionAttributeValues[":t1"].(*types.AttributeValueMemberN).Value, 10, 64)
+		return (forward && ad > t1) || (!forward && ad < t1)
+	}
+
+	var postings []searchPosting
+	for _, item := range d.Items {
+		var posting searchPosting
+		_ = attributevalue.UnmarshalMap(item, &posting)
+		if posting.Gram == gram && inRange(posting.AvailabilityDate) {
+			postings = append(postings, posting)
+		}
+	}
+
+	sort.Slice(postings, func(i, j int) bool {
+		if postings[i].AvailabilityDate != postings[j].AvailabilityDate {
+			return (postings[i].AvailabilityDate < postings[j].AvailabilityDate) == forward
+		}
+		return postings[i].Key < postings[j].Key
+	})
+
+	if input.ExclusiveStartKey != nil {
+		start := input.ExclusiveStartKey["n"].(*types.AttributeValueMemberS).Value
+		for i, posting := range postings {
+			if posting.Key == start {
+				postings = postings[i+1:]
+				break
+			}
+		}
+	}
+
+	output := &dynamodb.QueryOutput{}
+	for i, posting := range postings {
+		if i == int(*input.Limit) {
+			last := d.Items[postings[i-1].Key]
+			output.LastEvaluatedKey = map[string]types.AttributeValue{"n": last["n"], "sg": last["sg"], "ad": last["ad"]}
+			break
+		}
+
+		output.Items = append(output.Items, d.Items[posting.Key])
+	}
+
+	return output, nil
+}
+
+func (d *SearchDynamoDBServiceMock) BatchGetItem(_ context.Context, input *dynamodb.BatchGetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	output := &dynamodb.BatchGetItemOutput{Responses: make(map[string][]map[string]types.AttributeValue)}
+	for table, keys := range input.RequestItems {
+		for _, key := range keys.Keys {
+			if item, ok := d.Items[key["n"].(*types.AttributeValueMemberS).Value]; ok {
+				output.Responses[table] = append(output.Responses[table], item)
+			}
+		}
+	}
+
+	return output, nil
+}
+
+func (d *SearchDynamoDBServiceMock) BatchWriteItem(_ context.Context, input *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	d.Writes++
+	if d.Items == nil {
+		d.Items = make(map[string]map[string]types.AttributeValue)
+	}
+
+	output := &dynamodb.BatchWriteItemOutput{UnprocessedItems: make(map[string][]types.WriteRequest)}
+	for table, requests := range input.RequestItems {
+		if d.Unprocessed > 0 {
+			d.Unprocessed--
+			output.UnprocessedItems[table] = requests[len(requests)-1:]
+			requests = requests[:len(requests)-1]
+		}
+
+		for _, request := range requests {
+			if request.PutRequest != nil {
+				d.Items[request.PutRequest.Item["n"].(*types.AttributeValueMemberS).Value] = request.PutRequest.Item
+			} else {
+				delete(d.Items, request.DeleteRequest.Key["n"].(*types.AttributeValueMemberS).Value)
+			}
+		}
+	}
+
+	return output, nil
+}
+
+func newTestSearch(names ...string) (*NameSearch, *SearchDynamoDBServiceMock) {
+	mock := &SearchDynamoDBServiceMock{}
+	search := NewNameSearch(mock, "test-table")
+	search.sleep = func(time.Duration) {}
+
+	for i, name := range names {
+		summoner := &SummonerDTO{Name: name, Region: "NA", AccountID: "aid", AvailabilityDate: int64(i + 1)}
+		_ = search.Index(context.TODO(), summoner)
+		mock.Items[NameKey("NA", name)], _ = attributevalue.MarshalMap(newSummonerItem(summoner))
+	}
+
+	return search, mock
+}
+
+func searchNames(page *SearchPage) string {
+	names := make([]string, 0, len(page.Summoners))
+	for _, summoner := range page.Summoners {
+		names = append(names, summoner.Name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+func TestSearchGrams_IndexesLeadingCharactersAndTrigrams(t *testing.T) {
+	grams := searchGrams("wolfo")
+	if fmt.Sprint(grams) != "[^w ^wo ^wol wol olf lfo]" {
+		t.Errorf("unexpected grams %v", grams)
+	}
+}
+
+func TestSearchQuery_ValidatesModesAndPicksGrams(t *testing.T) {
+	cases := []struct {
+		mode  string
+		query string
+		gram  string
+	}{
+		{SearchPrefix, "X", "^x"},
+		{SearchPrefix, "Dark Wolf", "^dar"},
+		{SearchContains, "wolf", "wol"},
+		{SearchWildcard, "da*wolf", "wol"},
+		{SearchWildcard, "dar?", "^dar"},
+		{SearchWildcard, "d*f", "^d"},
+	}
+
+	for _, c := range cases {
+		query := &SearchQuery{Region: "NA", Mode: c.mode, Query: c.query}
+		if err := query.Validate(); err != nil || query.gram != c.gram {
+			t.Errorf("%s %s: expected gram %s, got %s, %v", c.mode, c.query, c.gram, query.gram, err)
+		}
+	}
+
+	invalid := []*SearchQuery{
+		{Region: "MARS", Mode: SearchPrefix, Query: "x"},
+		{Region: "NA", Mode: SearchPrefix, Query: " "},
+		{Region: "NA", Mode: SearchContains, Query: "wo"},
+		{Region: "NA", Mode: SearchWildcard, Query: "*o?f"},
+		{Region: "NA", Mode: "fuzzy", Query: "wolf"},
+		{Region: "NA", Mode: SearchPrefix, Query: strings.Repeat("a", 33)},
+	}
+
+	for _, query := range invalid {
+		if err := query.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", query)
+		}
+	}
+}
+
+func TestNameSearch_FindsNamesByModeInAvailabilityOrder(t *testing.T) {
+	search, _ := newTestSearch("Wolfy", "Darkwolf", "Xerath", "Xwolf", "Sheep")
+
+	cases := []struct {
+		mode     string
+		query    string
+		expected string
+	}{
+		{SearchContains, "WOLF", "Wolfy,Darkwolf,Xwolf"},
+		{SearchPrefix, "x", "Xerath,Xwolf"},
+		{SearchWildcard, "*wolf", "Darkwolf,Xwolf"},
+		{SearchWildcard, "x?????", "Xerath"},
+	}
+
+	for _, c := range cases {
+		page, err := search.Search(context.TODO(), &SearchQuery{Region: "NA", Mode: c.mode, Query: c.query}, 35, 0, false)
+		if err != nil || searchNames(page) != c.expected || page.Next != 0 {
+			t.Errorf("%s %s: expected %s, got %v, %+v", c.mode, c.query, c.expected, err, page)
+		}
+	}
+
+	page, _ := search.Search(context.TODO(), &SearchQuery{Region: "NA", Mode: SearchContains, Query: "wolf"}, 35, 4, true)
+	if searchNames(page) != "Darkwolf,Wolfy" {
+		t.Errorf("expected names before the timestamp in reverse order, got %s", searchNames(page))
+	}
+}
+
+func TestNameSearch_PaginatesWhenMatchesAreDropped(t *testing.T) {
+	var names []string
+	for i := 0; i < 30; i++ {
+		names = append(names, fmt.Sprintf("wolf%02d", i))
+	}
+	search, mock := newTestSearch(names...)
+	search.scanSize = 4
+
+	// Hide every other name, and move one to a later date without reindexing it.
+	for i := 0; i < 30; i += 2 {
+		mock.Items[NameKey("NA", names[i])]["h"] = &types.AttributeValueMemberBOOL{Value: true}
+	}
+	mock.Items[NameKey("NA", "wolf01")]["ad"] = &types.AttributeValueMemberN{Value: "1000"}
+	search.FilterResults(func(summoner *SummonerDTO) bool { return summoner.Name != "wolf03" })
+
+	query := &SearchQuery{Region: "NA", Mode: SearchPrefix, Query: "wolf"}
+	page, err := search.Search(context.TODO(), query, 3, 0, false)
+	if err != nil || searchNames(page) != "wolf05,wolf07,wolf09" || page.Next != 10 || page.Filtered != 1 {
+		t.Fatalf("expected the page to be refilled, got %v, %+v", err, page)
+	}
+
+	var all []string
+	for next := int64(0); ; {
+		page, err = search.Search(context.TODO(), query, 3, next, false)
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+
+		all = append(all, searchNames(page))
+		if next = page.Next; next == 0 {
+			break
+		}
+	}
+
+	if strings.Join(all, ",") != "wolf05,wolf07,wolf09,wolf11,wolf13,wolf15,wolf17,wolf19,wolf21,wolf23,wolf25,wolf27,wolf29" {
+		t.Errorf("expected every visible name once, got %v", all)
+	}
+}
+
+func TestNameSearch_RemovesPostingsAndRetriesUnprocessedWrites(t *testing.T) {
+	search, mock := newTestSearch("Wolfy")
+	mock.Unprocessed = 2
+
+	if err := search.Remove(context.TODO(), "NA", "Wolfy"); err != nil || mock.Writes != 4 {
+		t.Fatalf("expected unprocessed deletes to be retried, got %v after %d writes", err, mock.Writes)
+	}
+
+	for key := range mock.Items {
+		if strings.HasPrefix(key, searchPrefix) {
+			t.Errorf("expected every posting to be removed, found %s", key)
+		}
+	}
+}
+
+func TestNameSearch_FuzzyRanksLookAlikesAndCloseNames(t *testing.T) {
+	search, mock := newTestSearch("Fakerr", "Fɑker", "Faker", "Faked", "Taker", "Xerath", "Fkr")
+	mock.Items[NameKey("NA", "Faked")]["h"] = &types.AttributeValueMemberBOOL{Value: true}
+
+	page, err := search.Fuzzy(context.TODO(), &FuzzyQuery{Region: "NA", Query: "FaKer", MaxDistance: 1, Until: 100}, 35)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if names := searchNames(page); names != "Faker,Fɑker,Fakerr,Taker" {
+		t.Errorf("expected look-alikes first, then names one edit away, got %s", names)
+	}
+
+	page, _ = search.Fuzzy(context.TODO(), &FuzzyQuery{Region: "NA", Query: "faker", Until: 100}, 35)
+	if names := searchNames(page); names != "Faker,Fɑker" {
+		t.Errorf("expected only look-alikes at distance 0, got %s", names)
+	}
+
+	page, _ = search.Fuzzy(context.TODO(), &FuzzyQuery{Region: "NA", Query: "faker", MaxDistance: 1, Until: 2}, 35)
+	if names := searchNames(page); names != "Fɑker,Fakerr" {
+		t.Errorf("expected only names freeing within the window, got %s", names)
+	}
+}
+
+func TestNameSearch_FuzzyFillsTheLimitPastDroppedNames(t *testing.T) {
+	search, mock := newTestSearch("Faker", "Fakerr", "Fakers", "Fakex")
+	delete(mock.Items, NameKey("NA", "Faker"))
+
+	page, err := search.Fuzzy(context.TODO(), &FuzzyQuery{Region: "NA", Query: "faker", MaxDistance: 1, Until: 100}, 2)
+	if err != nil || searchNames(page) != "Fakerr,Fakers" {
+		t.Errorf("expected the limit to be filled past the deleted name, got %v, %s", err, searchNames(page))
+	}
+}
+
+func TestFuzzyQuery_Validates(t *testing.T) {
+	invalid := []*FuzzyQuery{
+		{Region: "MARS", Query: "faker", Until: 1},
+		{Region: "NA", Query: " ", Until: 1},
+		{Region: "NA", Query: "faker", MaxDistance: 3, Until: 1},
+		{Region: "NA", Query: "faker"},
+	}
+
+	for _, query := range invalid {
+		if err := query.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", query)
+		}
+	}
+}
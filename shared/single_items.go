@@ -0,0 +1,102 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strconv"
+	"time"
+)
+
+type singleItemDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// singleItem is an item that several writers read, change and write back whole, such as the blocklist or
+// a crawl frontier. Writes are guarded by the time the item was last updated, its "ua" attribute.
+type singleItem interface {
+	updatedAt() *int64
+}
+
+// ConcurrentModificationError is returned when a single item changed between being read and written back.
+type ConcurrentModificationError struct {
+	Key string
+}
+
+func (e *ConcurrentModificationError) Error() string {
+	return fmt.Sprintf("'%s' was concurrently modified, try again", e.Key)
+}
+
+// getSingleItem reads the item stored under key into item, leaving item as it is when none is stored.
+func getSingleItem(ctx context.Context, client singleItemDynamoDbService, tableName string, key string, item singleItem) error {
+	output, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(tableName),
+		Key:            map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil || output.Item == nil {
+		return err
+	}
+
+	return attributevalue.UnmarshalMap(output.Item, item)
+}
+
+// putSingleItem writes item if it is not stored yet, or if it was not written since it was read with
+// previous as its "ua" attribute. The item is stamped with now, moved past previous so writes within the
+// same millisecond are still told apart.
+func putSingleItem(ctx context.Context, client singleItemDynamoDbService, tableName string, item singleItem, previous int64, now time.Time) error {
+	*item.updatedAt() = max(now.UnixMilli(), previous+1)
+
+	marshalled, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		*item.updatedAt() = previous
+		return err
+	}
+
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(tableName),
+		Item:                marshalled,
+		ConditionExpression: aws.String("attribute_not_exists(n) OR ua = :ua"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ua": &types.AttributeValueMemberN{Value: strconv.FormatInt(previous, 10)},
+		},
+	})
+	if err == nil {
+		return nil
+	}
+
+	*item.updatedAt() = previous
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		key := ""
+		if n, ok := marshalled["n"].(*types.AttributeValueMemberS); ok {
+			key = n.Value
+		}
+
+		return &ConcurrentModificationError{Key: key}
+	}
+
+	return err
+}
+
+// updateSingleItem applies change to an item read with getSingleItem and writes it back with
+// putSingleItem.
+func updateSingleItem(ctx context.Context, client singleItemDynamoDbService, tableName string, item singleItem, now time.Time, change func() error) error {
+	previous := *item.updatedAt()
+	if err := change(); err != nil {
+		return err
+	}
+
+	return putSingleItem(ctx, client, tableName, item, previous, now)
+}
+
+func isConcurrentModification(err error) bool {
+	var modifiedErr *ConcurrentModificationError
+	return errors.As(err, &modifiedErr)
+}
@@ -0,0 +1,126 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"testing"
+	"time"
+)
+
+type testSingleItem struct {
+	Key       string `dynamodbav:"n"`
+	Value     string `dynamodbav:"v"`
+	UpdatedAt int64  `dynamodbav:"ua"`
+}
+
+func (i *testSingleItem) updatedAt() *int64 {
+	return &i.UpdatedAt
+}
+
+type SingleItemTableMock struct {
+	ShouldFail   bool
+	Items        map[string]map[string]types.AttributeValue
+	PutItemCalls []*dynamodb.PutItemInput
+}
+
+func (s *SingleItemTableMock) GetItem(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &dynamodb.GetItemOutput{Item: s.Items[input.Key["n"].(*types.AttributeValueMemberS).Value]}, nil
+}
+
+func (s *SingleItemTableMock) PutItem(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	s.PutItemCalls = append(s.PutItemCalls, input)
+
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	key := input.Item["n"].(*types.AttributeValueMemberS).Value
+	if existing, ok := s.Items[key]; ok && numberValue(existing["ua"]) != numberValue(input.ExpressionAttributeValues[":ua"]) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	s.Items[key] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestGetSingleItem_LeavesItemWhenNoneIsStored(t *testing.T) {
+	mock := &SingleItemTableMock{Items: map[string]map[string]types.AttributeValue{}}
+	item := &testSingleItem{Key: "test", Value: "default"}
+
+	if err := getSingleItem(context.TODO(), mock, "test-table", "test", item); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if item.Value != "default" || item.UpdatedAt != 0 {
+		t.Errorf("expected item to be unchanged, got %+v", item)
+	}
+}
+
+func TestUpdateSingleItem_WritesChangedItem(t *testing.T) {
+	mock := &SingleItemTableMock{Items: map[string]map[string]types.AttributeValue{}}
+	now := time.UnixMilli(1000)
+
+	item := &testSingleItem{Key: "test"}
+	err := updateSingleItem(context.TODO(), mock, "test-table", item, now, func() error {
+		item.Value = "first"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	// A second write within the same millisecond is still stamped past the first.
+	stored := &testSingleItem{Key: "test"}
+	_ = getSingleItem(context.TODO(), mock, "test-table", "test", stored)
+	err = updateSingleItem(context.TODO(), mock, "test-table", stored, now, func() error {
+		stored.Value = "second"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if *mock.PutItemCalls[1].ConditionExpression != "attribute_not_exists(n) OR ua = :ua" {
+		t.Errorf("unexpected condition %s", *mock.PutItemCalls[1].ConditionExpression)
+	}
+
+	if numberValue(mock.Items["test"]["ua"]) != 1001 || stored.UpdatedAt != 1001 {
+		t.Errorf("expected ua 1001, got %v", mock.Items["test"]["ua"])
+	}
+}
+
+func TestUpdateSingleItem_FailsWhenItemWasModified(t *testing.T) {
+	mock := &SingleItemTableMock{Items: map[string]map[string]types.AttributeValue{}}
+
+	first, second := &testSingleItem{Key: "test"}, &testSingleItem{Key: "test"}
+	_ = putSingleItem(context.TODO(), mock, "test-table", first, 0, time.UnixMilli(1000))
+
+	err := updateSingleItem(context.TODO(), mock, "test-table", second, time.UnixMilli(2000), func() error {
+		second.Value = "stale"
+		return nil
+	})
+	if !isConcurrentModification(err) {
+		t.Fatalf("expected concurrent modification error, got %v", err)
+	}
+
+	if second.UpdatedAt != 0 {
+		t.Errorf("expected ua to be restored, got %d", second.UpdatedAt)
+	}
+}
+
+func TestUpdateSingleItem_DoesNotWriteWhenChangeFails(t *testing.T) {
+	mock := &SingleItemTableMock{Items: map[string]map[string]types.AttributeValue{}}
+
+	err := updateSingleItem(context.TODO(), mock, "test-table", &testSingleItem{Key: "test"}, time.UnixMilli(1000), func() error {
+		return fmt.Errorf("invalid change")
+	})
+	if err == nil || len(mock.PutItemCalls) != 0 {
+		t.Errorf("expected error without writes, got %v and %d writes", err, len(mock.PutItemCalls))
+	}
+}
@@ -0,0 +1,239 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"io"
+	"strconv"
+	"time"
+)
+
+// batchWriteSize is the most items BatchWriteItem accepts in one request.
+const batchWriteSize = 25
+
+type snapshotDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+// SnapshotFilter limits an export to one region and/or an availability date range. Zero values match
+// everything.
+type SnapshotFilter struct {
+	Region string
+	From   int64
+	To     int64
+}
+
+type ExportResult struct {
+	Exported int           `json:"exported"`
+	Skipped  []SkippedItem `json:"skipped,omitempty"`
+}
+
+type ImportResult struct {
+	Imported   int `json:"imported"`
+	Suppressed int `json:"suppressed"`
+	Duplicates int `json:"duplicates"`
+	Retries    int `json:"retries"`
+}
+
+type Snapshots struct {
+	dynamodb    snapshotDynamoDbService
+	index       nameIndex
+	tableName   string
+	maxAttempts int
+	sleep       func(time.Duration)
+}
+
+func NewSnapshots(client snapshotDynamoDbService, tableName string) *Snapshots {
+	return &Snapshots{
+		dynamodb:    client,
+		tableName:   tableName,
+		maxAttempts: 8,
+		sleep:       time.Sleep,
+	}
+}
+
+// Export streams every summoner matching the filter to write, one page at a time. A region filter
+// queries the region index; otherwise the whole table is scanned.
+func (s *Snapshots) Export(ctx context.Context, filter SnapshotFilter, write func(*SummonerDTO) error) (*ExportResult, error) {
+	result := &ExportResult{}
+
+	to := filter.To
+	if to == 0 {
+		to = 1<<63 - 1
+	}
+
+	values := map[string]types.AttributeValue{
+		":from": &types.AttributeValueMemberN{Value: strconv.FormatInt(filter.From, 10)},
+		":to":   &types.AttributeValueMemberN{Value: strconv.FormatInt(to, 10)},
+	}
+
+	var startKey map[string]types.AttributeValue
+	for {
+		var items []map[string]types.AttributeValue
+		var lastKey map[string]types.AttributeValue
+
+		if filter.Region != "" {
+			values[":region"] = &types.AttributeValueMemberS{Value: filter.Region}
+			output, err := s.dynamodb.Query(ctx, &dynamodb.QueryInput{
+				TableName:                 aws.String(s.tableName),
+				IndexName:                 aws.String("region-availability-date-index"),
+				KeyConditionExpression:    aws.String("r = :region and ad between :from and :to"),
+				ExpressionAttributeValues: values,
+				ExclusiveStartKey:         startKey,
+			})
+			if err != nil {
+				return result, err
+			}
+
+			items, lastKey = output.Items, output.LastEvaluatedKey
+		} else {
+			output, err := s.dynamodb.Scan(ctx, &dynamodb.ScanInput{
+				TableName:                 aws.String(s.tableName),
+				FilterExpression:          aws.String("attribute_exists(r) and ad between :from and :to"),
+				ExpressionAttributeValues: values,
+				ExclusiveStartKey:         startKey,
+			})
+			if err != nil {
+				return result, err
+			}
+
+			items, lastKey = output.Items, output.LastEvaluatedKey
+		}
+
+		page := SummonersFromItems(items)
+		result.Skipped = append(result.Skipped, page.Skipped...)
+
+		for _, summoner := range page.Summoners {
+			if err := write(summoner); err != nil {
+				return result, err
+			}
+
+			result.Exported++
+		}
+
+		if len(lastKey) == 0 {
+			return result, nil
+		}
+
+		startKey = lastKey
+	}
+}
+
+// IndexNames sets the index imported names are written to, like Summoners.IndexNames.
+func (s *Snapshots) IndexNames(index nameIndex) {
+	s.index = index
+}
+
+// Import writes summoners returned by read in batches until read returns io.EOF. A name read twice within
+// a batch is written once, from the last row, since DynamoDB rejects batches that write a key twice. Items
+// DynamoDB leaves unprocessed are retried with exponential backoff. Summoners of erased accounts are
+// skipped.
+func (s *Snapshots) Import(ctx context.Context, read func() (*SummonerDTO, error)) (*ImportResult, error) {
+	result := &ImportResult{}
+	batch := make([]*SummonerDTO, 0, batchWriteSize)
+	positions := make(map[string]int, batchWriteSize)
+
+	for {
+		summoner, err := read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return result, err
+		}
+
+		suppressed, err := isSuppressed(ctx, s.dynamodb, s.tableName, summoner)
+		if err != nil {
+			return result, err
+		}
+
+		if suppressed {
+			result.Suppressed++
+			continue
+		}
+
+		key := NameKey(summoner.Region, summoner.Name)
+		if position, ok := positions[key]; ok {
+			batch[position] = summoner
+			result.Duplicates++
+			continue
+		}
+
+		positions[key] = len(batch)
+		batch = append(batch, summoner)
+		if len(batch) == batchWriteSize {
+			if err = s.importBatch(ctx, batch, result); err != nil {
+				return result, err
+			}
+
+			batch = make([]*SummonerDTO, 0, batchWriteSize)
+			positions = make(map[string]int, batchWriteSize)
+		}
+	}
+
+	if len(batch) > 0 {
+		return result, s.importBatch(ctx, batch, result)
+	}
+
+	return result, nil
+}
+
+// importBatch indexes the names of a batch when an index is set, then writes the batch with the items
+// marked indexed, the same way Summoners.Save does.
+func (s *Snapshots) importBatch(ctx context.Context, summoners []*SummonerDTO, result *ImportResult) error {
+	batch := make([]types.WriteRequest, 0, len(summoners))
+	for _, summoner := range summoners {
+		item, err := attributevalue.MarshalMap(newSummonerItem(summoner))
+		if err != nil {
+			return err
+		}
+
+		if s.index != nil {
+			if err = s.index.Index(ctx, summoner); err != nil {
+				return err
+			}
+
+			item["sx"] = &types.AttributeValueMemberN{Value: strconv.Itoa(searchIndexVersion)}
+		}
+
+		batch = append(batch, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+
+	return s.writeBatch(ctx, batch, result)
+}
+
+func (s *Snapshots) writeBatch(ctx context.Context, batch []types.WriteRequest, result *ImportResult) error {
+	requests := batch
+	for attempt := 0; attempt < s.maxAttempts; attempt++ {
+		if attempt > 0 {
+			result.Retries++
+			s.sleep(time.Duration(1<<(attempt-1)) * 100 * time.Millisecond)
+		}
+
+		output, err := s.dynamodb.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{s.tableName: requests},
+		})
+		if err != nil {
+			return err
+		}
+
+		unprocessed := output.UnprocessedItems[s.tableName]
+		result.Imported += len(requests) - len(unprocessed)
+		if len(unprocessed) == 0 {
+			return nil
+		}
+
+		requests = unprocessed
+	}
+
+	return fmt.Errorf("%d items were still unprocessed after %d attempts", len(requests), s.maxAttempts)
+}
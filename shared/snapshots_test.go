@@ -0,0 +1,300 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"io"
+	"testing"
+	"time"
+)
+
+type SnapshotsDynamoDBServiceMock struct {
+	Pages               []map[string]types.AttributeValue
+	SuppressedKeys      map[string]bool
+	ShouldFail          bool
+	UnprocessedAttempts int
+	QueryCalls          []*dynamodb.QueryInput
+	ScanCalls           []*dynamodb.ScanInput
+	BatchWriteItemCalls []*dynamodb.BatchWriteItemInput
+	Written             int
+}
+
+// page returns one item per call, continuing from the previous call's key.
+func (s *SnapshotsDynamoDBServiceMock) page(calls int) ([]map[string]types.AttributeValue, map[string]types.AttributeValue) {
+	if calls > len(s.Pages) {
+		return nil, nil
+	}
+
+	var lastKey map[string]types.AttributeValue
+	if calls < len(s.Pages) {
+		lastKey = map[string]types.AttributeValue{"n": s.Pages[calls-1]["n"]}
+	}
+
+	return []map[string]types.AttributeValue{s.Pages[calls-1]}, lastKey
+}
+
+func (s *SnapshotsDynamoDBServiceMock) GetItem(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if s.SuppressedKeys[input.Key["n"].(*types.AttributeValueMemberS).Value] {
+		return &dynamodb.GetItemOutput{Item: input.Key}, nil
+	}
+
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (s *SnapshotsDynamoDBServiceMock) Query(_ context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	s.QueryCalls = append(s.QueryCalls, input)
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	items, lastKey := s.page(len(s.QueryCalls))
+	return &dynamodb.QueryOutput{Items: items, LastEvaluatedKey: lastKey}, nil
+}
+
+func (s *SnapshotsDynamoDBServiceMock) Scan(_ context.Context, input *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	s.ScanCalls = append(s.ScanCalls, input)
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	items, lastKey := s.page(len(s.ScanCalls))
+	return &dynamodb.ScanOutput{Items: items, LastEvaluatedKey: lastKey}, nil
+}
+
+func (s *SnapshotsDynamoDBServiceMock) BatchWriteItem(_ context.Context, input *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	s.BatchWriteItemCalls = append(s.BatchWriteItemCalls, input)
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	requests := input.RequestItems["test-table"]
+	if s.UnprocessedAttempts > 0 {
+		s.UnprocessedAttempts--
+		s.Written += len(requests) - 1
+		return &dynamodb.BatchWriteItemOutput{
+			UnprocessedItems: map[string][]types.WriteRequest{"test-table": requests[len(requests)-1:]},
+		}, nil
+	}
+
+	s.Written += len(requests)
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func newTestSnapshots(mock *SnapshotsDynamoDBServiceMock) *Snapshots {
+	snapshots := NewSnapshots(mock, "test-table")
+	snapshots.sleep = func(time.Duration) {}
+	return snapshots
+}
+
+func testSummoners(count int) func() (*SummonerDTO, error) {
+	read := 0
+	return func() (*SummonerDTO, error) {
+		if read == count {
+			return nil, io.EOF
+		}
+
+		read++
+		return &SummonerDTO{Name: fmt.Sprintf("test%d", read), Region: "NA", AccountID: "aid"}, nil
+	}
+}
+
+func TestSnapshots_ExportScansEveryPage(t *testing.T) {
+	broken := newTestSummonerItem()
+	broken["n"] = &types.AttributeValueMemberS{Value: "NA#BROKEN"}
+	delete(broken, "rd")
+
+	mock := &SnapshotsDynamoDBServiceMock{Pages: []map[string]types.AttributeValue{newTestSummonerItem(), broken, newTestSummonerItem()}}
+
+	var exported []*SummonerDTO
+	result, err := newTestSnapshots(mock).Export(context.TODO(), SnapshotFilter{}, func(summoner *SummonerDTO) error {
+		exported = append(exported, summoner)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if result.Exported != 2 || len(exported) != 2 {
+		t.Errorf("expected 2 exported summoners, got %d", result.Exported)
+	}
+
+	if len(result.Skipped) != 1 || result.Skipped[0].Key != "NA#BROKEN" {
+		t.Errorf("expected NA#BROKEN to be skipped, got %v", result.Skipped)
+	}
+
+	if len(mock.ScanCalls) != 3 || mock.ScanCalls[2].ExclusiveStartKey == nil {
+		t.Errorf("expected 3 paginated scans, got %d", len(mock.ScanCalls))
+	}
+}
+
+func TestSnapshots_ExportQueriesRegionIndexForRegionFilter(t *testing.T) {
+	mock := &SnapshotsDynamoDBServiceMock{Pages: []map[string]types.AttributeValue{newTestSummonerItem()}}
+
+	_, err := newTestSnapshots(mock).Export(context.TODO(), SnapshotFilter{Region: "NA", From: 1, To: 2}, func(*SummonerDTO) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(mock.QueryCalls) != 1 || len(mock.ScanCalls) != 0 {
+		t.Fatalf("expected 1 query and no scans")
+	}
+
+	if *mock.QueryCalls[0].IndexName != "region-availability-date-index" {
+		t.Errorf("expected region-availability-date-index, got %s", *mock.QueryCalls[0].IndexName)
+	}
+
+	if mock.QueryCalls[0].ExpressionAttributeValues[":to"].(*types.AttributeValueMemberN).Value != "2" {
+		t.Errorf("expected :to to be 2")
+	}
+}
+
+func TestSnapshots_ExportStopsWhenWriteFails(t *testing.T) {
+	mock := &SnapshotsDynamoDBServiceMock{Pages: []map[string]types.AttributeValue{newTestSummonerItem(), newTestSummonerItem()}}
+
+	_, err := newTestSnapshots(mock).Export(context.TODO(), SnapshotFilter{}, func(*SummonerDTO) error {
+		return fmt.Errorf("disk full")
+	})
+	if err == nil || len(mock.ScanCalls) != 1 {
+		t.Errorf("expected export to stop after the first failed write, got %v", err)
+	}
+}
+
+func TestSnapshots_ImportWritesInBatchesOf25(t *testing.T) {
+	mock := &SnapshotsDynamoDBServiceMock{}
+
+	result, err := newTestSnapshots(mock).Import(context.TODO(), testSummoners(60))
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(mock.BatchWriteItemCalls) != 3 {
+		t.Errorf("expected 3 batches, got %d", len(mock.BatchWriteItemCalls))
+	}
+
+	if result.Imported != 60 || mock.Written != 60 {
+		t.Errorf("expected 60 imported items, got %d", result.Imported)
+	}
+
+	item := mock.BatchWriteItemCalls[0].RequestItems["test-table"][0].PutRequest.Item
+	if item["n"].(*types.AttributeValueMemberS).Value != "NA#TEST1" || item["v"] == nil {
+		t.Errorf("expected canonical item encoding, got %v", item)
+	}
+}
+
+func TestSnapshots_ImportKeepsLastRowOfNamesReadTwiceInABatch(t *testing.T) {
+	mock := &SnapshotsDynamoDBServiceMock{}
+	rows := []*SummonerDTO{
+		{Name: "Faker", Region: "NA", AccountID: "aid", Level: 1},
+		{Name: "other", Region: "NA", AccountID: "aid"},
+		{Name: "faker", Region: "NA", AccountID: "aid", Level: 2},
+	}
+
+	result, err := newTestSnapshots(mock).Import(context.TODO(), func() (*SummonerDTO, error) {
+		if len(rows) == 0 {
+			return nil, io.EOF
+		}
+
+		row := rows[0]
+		rows = rows[1:]
+		return row, nil
+	})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	requests := mock.BatchWriteItemCalls[0].RequestItems["test-table"]
+	if result.Imported != 2 || result.Duplicates != 1 || len(requests) != 2 {
+		t.Fatalf("expected the duplicate to be written once, got %+v", result)
+	}
+
+	if level := requests[0].PutRequest.Item["l"].(*types.AttributeValueMemberN).Value; level != "2" {
+		t.Errorf("expected the last row to be kept, got level %s", level)
+	}
+}
+
+func TestSnapshots_ImportIndexesNames(t *testing.T) {
+	mock := &SnapshotsDynamoDBServiceMock{}
+	index := &NameIndexMock{}
+	snapshots := newTestSnapshots(mock)
+	snapshots.IndexNames(index)
+
+	if _, err := snapshots.Import(context.TODO(), testSummoners(2)); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(index.Indexed) != 2 || index.Indexed[0] != "NA#test1@0" {
+		t.Errorf("expected both names to be indexed, got %v", index.Indexed)
+	}
+
+	if item := mock.BatchWriteItemCalls[0].RequestItems["test-table"][0].PutRequest.Item; item["sx"] == nil {
+		t.Errorf("expected imported items to be marked indexed, got %v", item)
+	}
+
+	index.ShouldFail = true
+	if _, err := snapshots.Import(context.TODO(), testSummoners(1)); err == nil || len(mock.BatchWriteItemCalls) != 1 {
+		t.Errorf("expected nothing to be written when indexing fails, got %v", err)
+	}
+}
+
+func TestSnapshots_ImportRetriesUnprocessedItems(t *testing.T) {
+	mock := &SnapshotsDynamoDBServiceMock{UnprocessedAttempts: 2}
+
+	result, err := newTestSnapshots(mock).Import(context.TODO(), testSummoners(3))
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if result.Imported != 3 || result.Retries != 2 {
+		t.Errorf("expected 3 imported items after 2 retries, got %+v", result)
+	}
+}
+
+func TestSnapshots_ImportGivesUpAfterMaxAttempts(t *testing.T) {
+	mock := &SnapshotsDynamoDBServiceMock{UnprocessedAttempts: 100}
+
+	_, err := newTestSnapshots(mock).Import(context.TODO(), testSummoners(2))
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestSnapshots_ImportSkipsSuppressedAccounts(t *testing.T) {
+	mock := &SnapshotsDynamoDBServiceMock{SuppressedKeys: map[string]bool{SuppressionKey(SuppressAccount, "erased"): true}}
+
+	rows := []*SummonerDTO{
+		{Name: "kept", Region: "NA", AccountID: "aid"},
+		{Name: "erased", Region: "NA", AccountID: "erased"},
+	}
+
+	result, err := newTestSnapshots(mock).Import(context.TODO(), func() (*SummonerDTO, error) {
+		if len(rows) == 0 {
+			return nil, io.EOF
+		}
+
+		row := rows[0]
+		rows = rows[1:]
+		return row, nil
+	})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if result.Imported != 1 || result.Suppressed != 1 || mock.Written != 1 {
+		t.Errorf("expected 1 imported and 1 suppressed summoner, got %+v", result)
+	}
+}
+
+func TestSnapshots_ImportReturnsReadErrors(t *testing.T) {
+	mock := &SnapshotsDynamoDBServiceMock{}
+
+	_, err := newTestSnapshots(mock).Import(context.TODO(), func() (*SummonerDTO, error) {
+		return nil, fmt.Errorf("invalid line")
+	})
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
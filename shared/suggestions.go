@@ -0,0 +1,274 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Kinds of name variants.
+const (
+	VariantPlural    = "plural"
+	VariantSeparator = "separator"
+	VariantLeet      = "leet"
+	VariantDoubled   = "doubled"
+	VariantSuffix    = "suffix"
+	VariantPrefix    = "prefix"
+)
+
+// Availability verdicts. A name is available when Riot has no summoner by it, expired when its holder
+// has been inactive past the name's availability date, taken when that date is still to come, and
+// unknown when it could not be checked.
+const (
+	VerdictAvailable = "available"
+	VerdictExpired   = "expired"
+	VerdictTaken     = "taken"
+	VerdictUnknown   = "unknown"
+)
+
+// maxVariantsPerKind keeps a single kind of variant from crowding out the others.
+const maxVariantsPerKind = 4
+
+// maxSuggestions bounds how many variants are suggested for a name.
+const maxSuggestions = 20
+
+var leetSubstitutions = map[rune]rune{'a': '4', 'e': '3', 'i': '1', 'o': '0', 's': '5', 't': '7'}
+
+var variantPrefixes = []string{"The", "Mr", "Its", "x"}
+
+var variantSuffixes = []string{"x", "1", "gg", "lol", "jr"}
+
+type suggestionSummonersService interface {
+	Get(region string, summonerName string) (*SummonerDTO, error)
+	Fetch(region string, summonerName string) (*SummonerDTO, error)
+	Save(summoner *SummonerDTO) error
+}
+
+type suggestionBlocklistService interface {
+	Match(ctx context.Context, region string, name string) (*BlockMatch, error)
+}
+
+type suggestionValidatorService interface {
+	Validate(region string, name string) ValidationErrors
+}
+
+type NameVariant struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// Suggestion is a variant with its verdict, where it came from (table or riot), and for expired and
+// taken names the availability date.
+type Suggestion struct {
+	NameVariant
+	Verdict          string `json:"verdict"`
+	Source           string `json:"source,omitempty"`
+	AvailabilityDate int64  `json:"availabilityDate,omitempty"`
+}
+
+// NameVariants generates alternatives to a name: its plural or singular, the name without separators,
+// leetspeak, doubled letters, and common suffixes and prefixes, in that order. Variants that normalize to
+// the name or to an earlier variant are left out.
+func NameVariants(name string) []NameVariant {
+	name = strings.TrimSpace(name)
+	seen := map[string]bool{NormalizeName(name): true}
+	variants := make([]NameVariant, 0, maxSuggestions)
+
+	add := func(kind string, candidates ...string) {
+		added := 0
+		for _, candidate := range candidates {
+			normalized := NormalizeName(candidate)
+			if added == maxVariantsPerKind || len(variants) == maxSuggestions || seen[normalized] {
+				continue
+			}
+
+			seen[normalized] = true
+			variants = append(variants, NameVariant{Name: candidate, Kind: kind})
+			added++
+		}
+	}
+
+	add(VariantPlural, pluralVariants(name)...)
+	add(VariantSeparator, strings.NewReplacer(".", "", "_", "").Replace(name))
+	add(VariantLeet, leetVariants(name)...)
+	add(VariantDoubled, doubledVariants(name)...)
+
+	var suffixed, prefixed []string
+	for _, suffix := range variantSuffixes {
+		suffixed = append(suffixed, name+suffix)
+	}
+	for _, prefix := range variantPrefixes {
+		prefixed = append(prefixed, prefix+name)
+	}
+
+	add(VariantSuffix, suffixed...)
+	add(VariantPrefix, prefixed...)
+	return variants
+}
+
+func pluralVariants(name string) []string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(lower) > 3:
+		return []string{name[:len(name)-3] + "y"}
+	case strings.HasSuffix(lower, "s"):
+		return []string{name[:len(name)-1]}
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return []string{name[:len(name)-1] + "ies", name + "s"}
+	case strings.HasSuffix(lower, "x") || strings.HasSuffix(lower, "z") || strings.HasSuffix(lower, "ch") || strings.HasSuffix(lower, "sh"):
+		return []string{name + "es"}
+	default:
+		return []string{name + "s", name + "z"}
+	}
+}
+
+// leetVariants substitutes every letter with a leetspeak digit, then one letter at a time.
+func leetVariants(name string) []string {
+	substitute := func(only rune) string {
+		return strings.Map(func(r rune) rune {
+			lower := unicode.ToLower(r)
+			if digit, ok := leetSubstitutions[lower]; ok && (only == 0 || lower == only) {
+				return digit
+			}
+			return r
+		}, name)
+	}
+
+	variants := []string{substitute(0)}
+	for _, r := range strings.ToLower(name) {
+		if _, ok := leetSubstitutions[r]; ok {
+			variants = append(variants, substitute(r))
+		}
+	}
+
+	return variants
+}
+
+// doubledVariants doubles the last letter, then each vowel.
+func doubledVariants(name string) []string {
+	runes := []rune(name)
+	double := func(i int) string {
+		return string(runes[:i+1]) + string(runes[i:])
+	}
+
+	var variants []string
+	if len(runes) > 0 && unicode.IsLetter(runes[len(runes)-1]) {
+		variants = append(variants, double(len(runes)-1))
+	}
+
+	for i, r := range runes {
+		if strings.ContainsRune("aeiouAEIOU", r) {
+			variants = append(variants, double(i))
+		}
+	}
+
+	return variants
+}
+
+// NameSuggester suggests variants of a name with their availability. Variants are checked against the
+// table first, and against Riot for names the table has no current holder of, up to a number of Riot
+// lookups per name so a suggestion costs a bounded share of the Riot budget.
+type NameSuggester struct {
+	summoners suggestionSummonersService
+	blocklist suggestionBlocklistService
+	validator suggestionValidatorService
+	lookups   int
+	now       func() time.Time
+}
+
+func NewNameSuggester(summoners suggestionSummonersService, blocklist suggestionBlocklistService, validator suggestionValidatorService, lookups int) *NameSuggester {
+	return &NameSuggester{
+		summoners: summoners,
+		blocklist: blocklist,
+		validator: validator,
+		lookups:   lookups,
+		now:       time.Now,
+	}
+}
+
+// Suggest returns the variants of a name valid in a region that are not blocked, each with a verdict.
+// Summoners found at Riot are saved so they are tracked from then on. Once the lookups are used up, or
+// Riot is throttled or unavailable, names the table does not know are left unknown.
+func (s *NameSuggester) Suggest(ctx context.Context, region string, name string) ([]Suggestion, error) {
+	suggestions := make([]Suggestion, 0, maxSuggestions)
+	lookups := s.lookups
+
+	for _, variant := range NameVariants(name) {
+		if len(s.validator.Validate(region, variant.Name)) > 0 {
+			continue
+		}
+
+		match, err := s.blocklist.Match(ctx, region, variant.Name)
+		if err != nil {
+			return nil, err
+		}
+		if match != nil {
+			continue
+		}
+
+		suggestion := Suggestion{NameVariant: variant, Verdict: VerdictUnknown}
+
+		summoner, err := s.summoners.Get(region, variant.Name)
+		if err != nil && err.Error() != "summoner not found" {
+			return nil, err
+		}
+
+		if summoner != nil {
+			s.judge(&suggestion, summoner, "table")
+		}
+
+		if suggestion.Verdict != VerdictTaken && lookups > 0 {
+			lookups--
+			if err = s.lookup(&suggestion, region); err != nil {
+				log.Printf("not checking more suggestions with riot: %v", err)
+				lookups = 0
+			}
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, nil
+}
+
+// lookup checks a suggestion with Riot. It returns an error when Riot should not be asked again.
+func (s *NameSuggester) lookup(suggestion *Suggestion, region string) error {
+	summoner, err := s.summoners.Fetch(region, suggestion.Name)
+	if err != nil && err.Error() == "summoner not found" {
+		suggestion.Verdict, suggestion.Source, suggestion.AvailabilityDate = VerdictAvailable, "riot", 0
+		return nil
+	}
+
+	var circuitErr *CircuitOpenError
+	if IsThrottled(err) || errors.As(err, &circuitErr) {
+		return err
+	}
+
+	if err != nil {
+		log.Printf("could not check suggestion '%s' with riot: %v", suggestion.Name, err)
+		return nil
+	}
+
+	if err = s.summoners.Save(summoner); IsSuppressed(err) {
+		suggestion.Verdict, suggestion.Source, suggestion.AvailabilityDate = VerdictUnknown, "", 0
+		return nil
+	} else if err != nil {
+		log.Printf("could not save suggestion '%s': %v", suggestion.Name, err)
+	}
+
+	s.judge(suggestion, summoner, "riot")
+	return nil
+}
+
+func (s *NameSuggester) judge(suggestion *Suggestion, summoner *SummonerDTO, source string) {
+	suggestion.Source = source
+	suggestion.AvailabilityDate = summoner.AvailabilityDate
+	if summoner.AvailabilityDate > s.now().UnixMilli() {
+		suggestion.Verdict = VerdictTaken
+	} else {
+		suggestion.Verdict = VerdictExpired
+	}
+}
@@ -0,0 +1,234 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// SuggestionSummonersServiceMock holds the table in Table and the names Riot knows in Riot.
+type SuggestionSummonersServiceMock struct {
+	Table      map[string]*SummonerDTO
+	Riot       map[string]*SummonerDTO
+	FetchErr   error
+	Suppressed string
+	Fetched    []string
+	Saved      []string
+}
+
+func (s *SuggestionSummonersServiceMock) Get(_ string, summonerName string) (*SummonerDTO, error) {
+	if summoner, ok := s.Table[NormalizeName(summonerName)]; ok {
+		return summoner, nil
+	}
+	return nil, fmt.Errorf("summoner not found")
+}
+
+func (s *SuggestionSummonersServiceMock) Fetch(_ string, summonerName string) (*SummonerDTO, error) {
+	s.Fetched = append(s.Fetched, summonerName)
+	if s.FetchErr != nil {
+		return nil, s.FetchErr
+	}
+
+	if summoner, ok := s.Riot[NormalizeName(summonerName)]; ok {
+		return summoner, nil
+	}
+	return nil, fmt.Errorf("summoner not found")
+}
+
+func (s *SuggestionSummonersServiceMock) Save(summoner *SummonerDTO) error {
+	if summoner.Name == s.Suppressed {
+		return &SuppressedError{Name: summoner.Name}
+	}
+
+	s.Saved = append(s.Saved, summoner.Name)
+	return nil
+}
+
+type SuggestionBlocklistMock struct {
+	Blocked    string
+	ShouldFail bool
+}
+
+func (b *SuggestionBlocklistMock) Match(_ context.Context, _ string, name string) (*BlockMatch, error) {
+	if b.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	if NormalizeName(name) == NormalizeName(b.Blocked) {
+		return &BlockMatch{Rule: BlockRule{Kind: BlockExact, Value: b.Blocked}, Term: name}, nil
+	}
+	return nil, nil
+}
+
+func newTestNameSuggester(summoners *SuggestionSummonersServiceMock, blocklist *SuggestionBlocklistMock, lookups int) *NameSuggester {
+	suggester := NewNameSuggester(summoners, blocklist, NewNameValidator(), lookups)
+	suggester.now = func() time.Time { return time.UnixMilli(1000) }
+	return suggester
+}
+
+func variantNames(variants []NameVariant, kind string) []string {
+	var names []string
+	for _, variant := range variants {
+		if variant.Kind == kind {
+			names = append(names, variant.Name)
+		}
+	}
+	return names
+}
+
+func TestNameVariants_GeneratesEachKind(t *testing.T) {
+	variants := NameVariants("Dark_Wolf")
+
+	expected := map[string]string{
+		VariantPlural:    "[Dark_Wolfs Dark_Wolfz]",
+		VariantSeparator: "[DarkWolf]",
+		VariantLeet:      "[D4rk_W0lf D4rk_Wolf Dark_W0lf]",
+		VariantDoubled:   "[Dark_Wolff Daark_Wolf Dark_Woolf]",
+		VariantSuffix:    "[Dark_Wolfx Dark_Wolf1 Dark_Wolfgg Dark_Wolflol]",
+		VariantPrefix:    "[TheDark_Wolf MrDark_Wolf ItsDark_Wolf xDark_Wolf]",
+	}
+
+	for kind, names := range expected {
+		if actual := fmt.Sprint(variantNames(variants, kind)); actual != names {
+			t.Errorf("Expected %s variants %s, got %s", kind, names, actual)
+		}
+	}
+}
+
+func TestNameVariants_Pluralizes(t *testing.T) {
+	cases := map[string]string{
+		"Wolves":  "[Wolve]",
+		"Berry":   "[Berries Berrys]",
+		"Puppies": "[Puppy]",
+		"Fox":     "[Foxes]",
+		"Day":     "[Days Dayz]",
+	}
+
+	for name, expected := range cases {
+		if actual := fmt.Sprint(variantNames(NameVariants(name), VariantPlural)); actual != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, actual)
+		}
+	}
+}
+
+func TestNameVariants_LeavesOutDuplicates(t *testing.T) {
+	variants := NameVariants("Faker")
+
+	seen := map[string]bool{"faker": true}
+	for _, variant := range variants {
+		normalized := NormalizeName(variant.Name)
+		if seen[normalized] {
+			t.Errorf("Expected no duplicate variants, got %s twice", variant.Name)
+		}
+		seen[normalized] = true
+	}
+
+	if len(variants) > maxSuggestions {
+		t.Errorf("Expected at most %d variants, got %d", maxSuggestions, len(variants))
+	}
+}
+
+func TestNameSuggester_JudgesVariantsByTableThenRiot(t *testing.T) {
+	summoners := &SuggestionSummonersServiceMock{
+		Table: map[string]*SummonerDTO{
+			"fakers": {Name: "Fakers", AvailabilityDate: 5000},
+			"fakerz": {Name: "Fakerz", AvailabilityDate: 500},
+		},
+		Riot: map[string]*SummonerDTO{
+			"f4k3r": {Name: "F4k3r", AvailabilityDate: 200},
+		},
+	}
+	suggester := newTestNameSuggester(summoners, &SuggestionBlocklistMock{}, 2)
+
+	suggestions, err := suggester.Suggest(context.TODO(), "NA", "Faker")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{
+		"Fakers plural taken table 5000",
+		"Fakerz plural available riot 0",
+		"F4k3r leet expired riot 200",
+		"F4ker leet unknown  0",
+	}
+
+	for i, e := range expected {
+		s := suggestions[i]
+		if actual := fmt.Sprintf("%s %s %s %s %d", s.Name, s.Kind, s.Verdict, s.Source, s.AvailabilityDate); actual != e {
+			t.Errorf("Expected suggestion %d to be %s, got %s", i, e, actual)
+		}
+	}
+
+	if fmt.Sprint(summoners.Fetched) != "[Fakerz F4k3r]" {
+		t.Errorf("Expected Riot to be asked only about names not taken in the table, got %v", summoners.Fetched)
+	}
+
+	if fmt.Sprint(summoners.Saved) != "[F4k3r]" {
+		t.Errorf("Expected the summoner found at Riot to be saved, got %v", summoners.Saved)
+	}
+}
+
+func TestNameSuggester_LeavesOutInvalidAndBlockedVariants(t *testing.T) {
+	suggester := newTestNameSuggester(&SuggestionSummonersServiceMock{}, &SuggestionBlocklistMock{Blocked: "ItsWolfy"}, 0)
+
+	suggestions, err := suggester.Suggest(context.TODO(), "NA", "Wolfy")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, suggestion := range suggestions {
+		if suggestion.Name == "ItsWolfy" {
+			t.Errorf("Expected the blocked variant to be left out")
+		}
+
+		if len(NewNameValidator().Validate("NA", suggestion.Name)) > 0 {
+			t.Errorf("Expected only valid variants, got %s", suggestion.Name)
+		}
+
+		if suggestion.Verdict != VerdictUnknown {
+			t.Errorf("Expected names unknown to the table to be unknown without lookups, got %s", suggestion.Verdict)
+		}
+	}
+}
+
+func TestNameSuggester_StopsAskingRiotWhenThrottled(t *testing.T) {
+	for _, fetchErr := range []error{&BudgetExhaustedError{Workload: WorkloadSuggestions}, &CircuitOpenError{Reason: "errors"}} {
+		summoners := &SuggestionSummonersServiceMock{FetchErr: fetchErr}
+		suggester := newTestNameSuggester(summoners, &SuggestionBlocklistMock{}, 5)
+
+		suggestions, err := suggester.Suggest(context.TODO(), "NA", "Faker")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if len(summoners.Fetched) != 1 {
+			t.Errorf("%v: expected a single Riot lookup, got %v", fetchErr, summoners.Fetched)
+		}
+
+		if len(suggestions) == 0 || suggestions[0].Verdict != VerdictUnknown {
+			t.Errorf("%v: expected the names to be unknown, got %v", fetchErr, suggestions)
+		}
+	}
+}
+
+func TestNameSuggester_LeavesSuppressedNamesUnknown(t *testing.T) {
+	summoners := &SuggestionSummonersServiceMock{
+		Riot:       map[string]*SummonerDTO{"fakers": {Name: "Fakers", AvailabilityDate: 200}},
+		Suppressed: "Fakers",
+	}
+	suggester := newTestNameSuggester(summoners, &SuggestionBlocklistMock{}, 1)
+
+	suggestions, _ := suggester.Suggest(context.TODO(), "NA", "Faker")
+	if s := suggestions[0]; s.Name != "Fakers" || s.Verdict != VerdictUnknown || s.AvailabilityDate != 0 {
+		t.Errorf("Expected the erased account's name to be unknown, got %v", s)
+	}
+}
+
+func TestNameSuggester_FailsWhenBlocklistFails(t *testing.T) {
+	suggester := newTestNameSuggester(&SuggestionSummonersServiceMock{}, &SuggestionBlocklistMock{ShouldFail: true}, 1)
+
+	if _, err := suggester.Suggest(context.TODO(), "NA", "Faker"); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
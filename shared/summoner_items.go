@@ -0,0 +1,281 @@
+package shared
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SummonerItemVersion is written to the "v" attribute of every summoner item. Items written before
+// versions were introduced have no "v" attribute and are read as version 0.
+const SummonerItemVersion = 1
+
+type summonerItem struct {
+	Key              string `dynamodbav:"n"`
+	DisplayName      string `dynamodbav:"dn,omitempty"`
+	Region           string `dynamodbav:"r"`
+	AccountID        string `dynamodbav:"aid"`
+	Puuid            string `dynamodbav:"pid,omitempty"`
+	RevisionDate     int64  `dynamodbav:"rd"`
+	AvailabilityDate int64  `dynamodbav:"ad"`
+	Level            int    `dynamodbav:"l"`
+	NameLengthKey    string `dynamodbav:"nl"`
+	LastUpdated      int64  `dynamodbav:"ld"`
+	SummonerIcon     int    `dynamodbav:"si"`
+	Skeleton         string `dynamodbav:"sk,omitempty"`
+	Score            *int   `dynamodbav:"q"`
+	Version          int    `dynamodbav:"v"`
+	Hidden           bool   `dynamodbav:"h,omitempty"`
+}
+
+// summonerItemSchemas lists the attributes each item version must have, and their DynamoDB types.
+var summonerItemSchemas = map[int]map[string]string{
+	0: {"n": "S", "r": "S", "aid": "S", "rd": "N", "ad": "N", "l": "N", "ld": "N"},
+	1: {"n": "S", "r": "S", "aid": "S", "rd": "N", "ad": "N", "l": "N", "ld": "N", "nl": "S", "si": "N", "v": "N"},
+}
+
+type SkippedItem struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+type SummonersPage struct {
+	Summoners []*SummonerDTO
+	Skipped   []SkippedItem
+	Filtered  int
+	Truncated bool
+	Next      int64
+}
+
+func newSummonerItem(summoner *SummonerDTO) *summonerItem {
+	return &summonerItem{
+		Key:              NameKey(summoner.Region, summoner.Name),
+		DisplayName:      summoner.Name,
+		Region:           summoner.Region,
+		AccountID:        summoner.AccountID,
+		Puuid:            summoner.Puuid,
+		RevisionDate:     summoner.RevisionDate,
+		AvailabilityDate: summoner.AvailabilityDate,
+		Level:            summoner.Level,
+		NameLengthKey:    NameLengthKey(summoner.Region, summoner.Name),
+		LastUpdated:      summoner.LastUpdated,
+		SummonerIcon:     summoner.SummonerIcon,
+		Skeleton:         Skeleton(summoner.Name),
+		Score:            aws.Int(QualityScore(summoner.Name)),
+		Version:          SummonerItemVersion,
+		Hidden:           summoner.Hidden,
+	}
+}
+
+// UpgradeSummonerItem returns a copy of a legacy item upgraded to the current item version, and whether
+// the item needed upgrading.
+func UpgradeSummonerItem(item map[string]types.AttributeValue) (map[string]types.AttributeValue, bool, error) {
+	if _, ok := item["v"]; ok {
+		return item, false, nil
+	}
+
+	key, ok := item["n"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, false, fmt.Errorf("item has no string 'n' attribute")
+	}
+
+	region, name, ok := strings.Cut(key.Value, "#")
+	if !ok {
+		return nil, false, fmt.Errorf("item key '%s' is not in the region#name format", key.Value)
+	}
+
+	upgraded := make(map[string]types.AttributeValue, len(item)+2)
+	for k, v := range item {
+		upgraded[k] = v
+	}
+
+	if _, ok := upgraded["si"]; !ok {
+		upgraded["si"] = &types.AttributeValueMemberN{Value: "0"}
+	}
+
+	upgraded["nl"] = &types.AttributeValueMemberS{Value: NameLengthKey(region, name)}
+	upgraded["v"] = &types.AttributeValueMemberN{Value: strconv.Itoa(SummonerItemVersion)}
+	return upgraded, true, nil
+}
+
+// AddNameSkeleton returns a copy of a summoner item with the skeleton of its name, see Skeleton, and
+// whether the item was missing it.
+func AddNameSkeleton(item map[string]types.AttributeValue) (map[string]types.AttributeValue, bool, error) {
+	key, ok := item["n"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, false, fmt.Errorf("item has no string 'n' attribute")
+	}
+
+	_, name, ok := strings.Cut(key.Value, "#")
+	if !ok {
+		return nil, false, fmt.Errorf("item key '%s' is not in the region#name format", key.Value)
+	}
+
+	skeleton := Skeleton(name)
+	if current, ok := item["sk"].(*types.AttributeValueMemberS); ok && current.Value == skeleton {
+		return item, false, nil
+	}
+
+	rewritten := make(map[string]types.AttributeValue, len(item)+1)
+	for k, v := range item {
+		rewritten[k] = v
+	}
+
+	rewritten["sk"] = &types.AttributeValueMemberS{Value: skeleton}
+	return rewritten, true, nil
+}
+
+// AddQualityScore returns a copy of a summoner item with the quality score of its name, see QualityScore,
+// and whether the item was missing it or had an outdated score.
+func AddQualityScore(item map[string]types.AttributeValue) (map[string]types.AttributeValue, bool, error) {
+	key, ok := item["n"].(*types.AttributeValueMemberS)
+	if !ok {
+		return nil, false, fmt.Errorf("item has no string 'n' attribute")
+	}
+
+	_, name, ok := strings.Cut(key.Value, "#")
+	if !ok {
+		return nil, false, fmt.Errorf("item key '%s' is not in the region#name format", key.Value)
+	}
+
+	score := strconv.Itoa(QualityScore(name))
+	if current, ok := item["q"].(*types.AttributeValueMemberN); ok && current.Value == score {
+		return item, false, nil
+	}
+
+	rewritten := make(map[string]types.AttributeValue, len(item)+1)
+	for k, v := range item {
+		rewritten[k] = v
+	}
+
+	rewritten["q"] = &types.AttributeValueMemberN{Value: score}
+	return rewritten, true, nil
+}
+
+// MarkNameIndexed returns a copy of a summoner item marked as indexed by the current version of the search
+// index, see NameSearch, and whether it was not marked yet.
+func MarkNameIndexed(item map[string]types.AttributeValue) (map[string]types.AttributeValue, bool, error) {
+	version := strconv.Itoa(searchIndexVersion)
+	if current, ok := item["sx"].(*types.AttributeValueMemberN); ok && current.Value == version {
+		return item, false, nil
+	}
+
+	rewritten := make(map[string]types.AttributeValue, len(item)+1)
+	for k, v := range item {
+		rewritten[k] = v
+	}
+
+	rewritten["sx"] = &types.AttributeValueMemberN{Value: version}
+	return rewritten, true, nil
+}
+
+func SummonerFromItem(item map[string]types.AttributeValue) (*SummonerDTO, error) {
+	version := 0
+	if v, ok := item["v"]; ok {
+		if err := attributevalue.Unmarshal(v, &version); err != nil {
+			return nil, fmt.Errorf("invalid item version: %v", err)
+		}
+	}
+
+	schema, ok := summonerItemSchemas[version]
+	if !ok {
+		return nil, fmt.Errorf("unsupported item version %d", version)
+	}
+
+	if err := validateItemSchema(item, schema); err != nil {
+		return nil, err
+	}
+
+	var decoded summonerItem
+	if err := attributevalue.UnmarshalMap(item, &decoded); err != nil {
+		return nil, err
+	}
+
+	_, name, ok := strings.Cut(decoded.Key, "#")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("key '%s' is not in the region#name format", decoded.Key)
+	}
+
+	// Items saved before names were scored are scored when read.
+	score := QualityScore(name)
+	if decoded.Score != nil {
+		score = *decoded.Score
+	}
+
+	// Items saved before display names were stored only have the normalized name of their key.
+	displayName := decoded.DisplayName
+	if displayName == "" {
+		displayName = strings.ToLower(name)
+	}
+
+	return &SummonerDTO{
+		Name:             displayName,
+		Region:           decoded.Region,
+		AccountID:        decoded.AccountID,
+		Puuid:            decoded.Puuid,
+		RevisionDate:     decoded.RevisionDate,
+		AvailabilityDate: decoded.AvailabilityDate,
+		Level:            decoded.Level,
+		LastUpdated:      decoded.LastUpdated,
+		SummonerIcon:     decoded.SummonerIcon,
+		Hidden:           decoded.Hidden,
+		Score:            score,
+	}, nil
+}
+
+func validateItemSchema(item map[string]types.AttributeValue, schema map[string]string) error {
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var actual string
+		switch item[name].(type) {
+		case nil:
+			return fmt.Errorf("missing attribute '%s'", name)
+		case *types.AttributeValueMemberS:
+			actual = "S"
+		case *types.AttributeValueMemberN:
+			actual = "N"
+		default:
+			actual = "other"
+		}
+
+		if actual != schema[name] {
+			return fmt.Errorf("attribute '%s' should be of type %s", name, schema[name])
+		}
+	}
+
+	return nil
+}
+
+// SummonersFromItems decodes summoner items, skipping and logging any that do not match their schema
+// instead of failing the whole page.
+func SummonersFromItems(items []map[string]types.AttributeValue) *SummonersPage {
+	page := &SummonersPage{Summoners: make([]*SummonerDTO, 0, len(items))}
+
+	for _, item := range items {
+		summoner, err := SummonerFromItem(item)
+		if err != nil {
+			key := "unknown"
+			if n, ok := item["n"].(*types.AttributeValueMemberS); ok {
+				key = n.Value
+			}
+
+			log.Printf("skipping malformed summoner item '%s': %v", key, err)
+			page.Skipped = append(page.Skipped, SkippedItem{Key: key, Reason: err.Error()})
+			continue
+		}
+
+		page.Summoners = append(page.Summoners, summoner)
+	}
+
+	return page
+}
@@ -0,0 +1,227 @@
+package shared
+
+import (
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+	"testing"
+)
+
+func newTestSummonerItem() map[string]types.AttributeValue {
+	item, _ := attributevalue.MarshalMap(newSummonerItem(&SummonerDTO{
+		Name:             "Test",
+		Region:           "NA",
+		AccountID:        "123",
+		RevisionDate:     1,
+		AvailabilityDate: 2,
+		Level:            30,
+		LastUpdated:      3,
+		SummonerIcon:     4,
+	}))
+
+	return item
+}
+
+func TestNewSummonerItem_WritesCurrentVersion(t *testing.T) {
+	item := newTestSummonerItem()
+
+	if item["v"].(*types.AttributeValueMemberN).Value != "1" {
+		t.Errorf("expected 1, got %s", item["v"].(*types.AttributeValueMemberN).Value)
+	}
+
+	if item["nl"].(*types.AttributeValueMemberS).Value != "NA#4" {
+		t.Errorf("expected NA#4, got %s", item["nl"].(*types.AttributeValueMemberS).Value)
+	}
+}
+
+func TestSummonerFromItem_RoundTripsCurrentVersion(t *testing.T) {
+	summoner, err := SummonerFromItem(newTestSummonerItem())
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if summoner.Name != "Test" || summoner.Region != "NA" || summoner.Level != 30 || summoner.SummonerIcon != 4 {
+		t.Errorf("unexpected summoner %+v", summoner)
+	}
+}
+
+func TestSummonerFromItem_KeepsDisplayNameSpacesAndCase(t *testing.T) {
+	item, _ := attributevalue.MarshalMap(newSummonerItem(&SummonerDTO{Name: "Hide on bush", Region: "NA", AccountID: "123"}))
+
+	summoner, err := SummonerFromItem(item)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if summoner.Name != "Hide on bush" {
+		t.Errorf("expected Hide on bush, got %s", summoner.Name)
+	}
+}
+
+func TestSummonerFromItem_FallsBackToKeyWithoutDisplayName(t *testing.T) {
+	item := newTestSummonerItem()
+	delete(item, "dn")
+
+	summoner, err := SummonerFromItem(item)
+	if err != nil || summoner.Name != "test" {
+		t.Errorf("expected test, got %v, %v", summoner, err)
+	}
+}
+
+func TestSummonerFromItem_DecodesLegacyItemWithoutIcon(t *testing.T) {
+	item := newTestSummonerItem()
+	delete(item, "v")
+	delete(item, "si")
+	delete(item, "nl")
+
+	summoner, err := SummonerFromItem(item)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if summoner.SummonerIcon != 0 {
+		t.Errorf("expected 0, got %d", summoner.SummonerIcon)
+	}
+}
+
+func TestSummonerFromItem_RejectsMissingAttribute(t *testing.T) {
+	item := newTestSummonerItem()
+	delete(item, "si")
+
+	_, err := SummonerFromItem(item)
+	if err == nil || !strings.Contains(err.Error(), "'si'") {
+		t.Errorf("expected missing 'si' error, got %v", err)
+	}
+}
+
+func TestSummonerFromItem_RejectsMistypedAttribute(t *testing.T) {
+	item := newTestSummonerItem()
+	item["rd"] = &types.AttributeValueMemberS{Value: "12345"}
+
+	_, err := SummonerFromItem(item)
+	if err == nil || !strings.Contains(err.Error(), "'rd'") {
+		t.Errorf("expected mistyped 'rd' error, got %v", err)
+	}
+}
+
+func TestSummonerFromItem_RejectsUnparseableNumber(t *testing.T) {
+	item := newTestSummonerItem()
+	item["l"] = &types.AttributeValueMemberN{Value: "thirty"}
+
+	if _, err := SummonerFromItem(item); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestSummonerFromItem_RejectsUnsupportedVersion(t *testing.T) {
+	item := newTestSummonerItem()
+	item["v"] = &types.AttributeValueMemberN{Value: "99"}
+
+	_, err := SummonerFromItem(item)
+	if err == nil || err.Error() != "unsupported item version 99" {
+		t.Errorf("expected unsupported version error, got %v", err)
+	}
+}
+
+func TestSummonerFromItem_RejectsMalformedKey(t *testing.T) {
+	item := newTestSummonerItem()
+	item["n"] = &types.AttributeValueMemberS{Value: "TEST"}
+
+	if _, err := SummonerFromItem(item); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestSummonersFromItems_SkipsMalformedItems(t *testing.T) {
+	malformed := newTestSummonerItem()
+	malformed["n"] = &types.AttributeValueMemberS{Value: "NA#BROKEN"}
+	delete(malformed, "aid")
+
+	page := SummonersFromItems([]map[string]types.AttributeValue{newTestSummonerItem(), malformed, {}})
+
+	if len(page.Summoners) != 1 {
+		t.Errorf("expected 1, got %d", len(page.Summoners))
+	}
+
+	if len(page.Skipped) != 2 {
+		t.Fatalf("expected 2, got %d", len(page.Skipped))
+	}
+
+	if page.Skipped[0].Key != "NA#BROKEN" {
+		t.Errorf("expected NA#BROKEN, got %s", page.Skipped[0].Key)
+	}
+
+	if page.Skipped[1].Key != "unknown" {
+		t.Errorf("expected unknown, got %s", page.Skipped[1].Key)
+	}
+}
+
+func TestUpgradeSummonerItem_AddsVersionAndDefaultIcon(t *testing.T) {
+	item := newTestSummonerItem()
+	delete(item, "v")
+	delete(item, "si")
+
+	upgraded, changed, err := UpgradeSummonerItem(item)
+	if err != nil || !changed {
+		t.Fatalf("expected changed item, got %v %v", changed, err)
+	}
+
+	if _, err = SummonerFromItem(upgraded); err != nil {
+		t.Errorf("expected upgraded item to decode, got %v", err)
+	}
+
+	if _, ok := item["v"]; ok {
+		t.Errorf("expected original item to be left untouched")
+	}
+}
+
+func TestUpgradeSummonerItem_LeavesCurrentItemsUnchanged(t *testing.T) {
+	_, changed, err := UpgradeSummonerItem(newTestSummonerItem())
+	if err != nil || changed {
+		t.Errorf("expected unchanged item, got %v %v", changed, err)
+	}
+}
+
+func TestAddNameSkeleton_AddsSkeletonOnce(t *testing.T) {
+	item := newTestSummonerItem()
+	item["n"] = &types.AttributeValueMemberS{Value: "NA#FΑKER"}
+
+	rewritten, changed, err := AddNameSkeleton(item)
+	if err != nil || !changed || rewritten["sk"].(*types.AttributeValueMemberS).Value != "faker" {
+		t.Fatalf("expected the skeleton to be added, got %v, %v, %v", rewritten["sk"], changed, err)
+	}
+
+	if _, changed, _ = AddNameSkeleton(rewritten); changed {
+		t.Errorf("expected an item with its skeleton to be left unchanged")
+	}
+}
+
+func TestAddQualityScore_AddsScoreOnce(t *testing.T) {
+	item := newTestSummonerItem()
+	item["n"] = &types.AttributeValueMemberS{Value: "NA#DARKWOLF"}
+
+	rewritten, changed, err := AddQualityScore(item)
+	if err != nil || !changed || rewritten["q"].(*types.AttributeValueMemberN).Value != "90" {
+		t.Fatalf("expected the score to be added, got %v, %v, %v", rewritten["q"], changed, err)
+	}
+
+	if _, changed, _ = AddQualityScore(rewritten); changed {
+		t.Errorf("expected an item with its score to be left unchanged")
+	}
+}
+
+func TestSummonerFromItem_ScoresItemsWithoutScore(t *testing.T) {
+	item := newTestSummonerItem()
+	item["n"] = &types.AttributeValueMemberS{Value: "NA#DARKWOLF"}
+	delete(item, "q")
+
+	summoner, err := SummonerFromItem(item)
+	if err != nil || summoner.Score != 90 {
+		t.Fatalf("expected the name to be scored, got %v, %v", summoner, err)
+	}
+
+	item["q"] = &types.AttributeValueMemberN{Value: "12"}
+	if summoner, _ = SummonerFromItem(item); summoner.Score != 12 {
+		t.Errorf("expected the stored score, got %d", summoner.Score)
+	}
+}
@@ -3,22 +3,31 @@ package shared
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"io"
+	"log"
 	"math"
 	"net/http"
+	"net/url"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type dynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
 	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
 	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
 	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
 }
 
@@ -32,14 +41,17 @@ type httpService interface {
 }
 
 type SummonerDTO struct {
-	Name             string `json:"name"`
-	Region           string `json:"region"`
-	AccountID        string `json:"accountId"`
-	RevisionDate     int64  `json:"revisionDate"`
-	AvailabilityDate int64  `json:"availabilityDate"`
-	Level            int    `json:"level"`
-	LastUpdated      int64  `json:"lastUpdated"`
-	SummonerIcon     int    `json:"summonerIcon"`
+	Name             string `json:"name" parquet:"name"`
+	Region           string `json:"region" parquet:"region"`
+	AccountID        string `json:"accountId" parquet:"accountId"`
+	Puuid            string `json:"puuid,omitempty" parquet:"puuid,optional"`
+	RevisionDate     int64  `json:"revisionDate" parquet:"revisionDate"`
+	AvailabilityDate int64  `json:"availabilityDate" parquet:"availabilityDate"`
+	Level            int    `json:"level" parquet:"level"`
+	LastUpdated      int64  `json:"lastUpdated" parquet:"lastUpdated"`
+	SummonerIcon     int    `json:"summonerIcon" parquet:"summonerIcon"`
+	Hidden           bool   `json:"hidden,omitempty" parquet:"hidden,optional"`
+	Score            int    `json:"score" parquet:"score,optional"`
 }
 
 type RiotSummonerDTO struct {
@@ -52,44 +64,118 @@ type RiotSummonerDTO struct {
 	SummonerLevel int    `json:"summonerLevel"`
 }
 
+// nameIndex is kept in sync with the names saved and deleted, see NameSearch.
+type nameIndex interface {
+	Index(ctx context.Context, summoner *SummonerDTO) error
+	Remove(ctx context.Context, region string, name string) error
+}
+
+type riotKeysService interface {
+	Get(ctx context.Context, workload string) (string, error)
+	Invalidate()
+}
+
+// maxListingQueries bounds how many queries refill a single listing page when many items are dropped.
+const maxListingQueries = 10
+
+// maxScoredItems bounds how many items of a date window GetByScore reads to rank.
+const maxScoredItems = 5000
+
+// ListingFilter narrows the summoners listings by name score, summoner level and revision date, which
+// are bounded by milliseconds since the epoch and exclusive. Zero fields do not filter. Items saved
+// before names were scored have no score and are left out when filtering by score until they are migrated.
+type ListingFilter struct {
+	MinScore       int
+	MinLevel       int
+	MaxLevel       int
+	RevisionBefore int64
+	RevisionAfter  int64
+}
+
+// expression builds the filter expression of a listing query, which always leaves hidden names out,
+// adding the values it refers to.
+func (f ListingFilter) expression(values map[string]types.AttributeValue) string {
+	conditions := []string{"attribute_not_exists(h)"}
+	add := func(condition string, name string, value int64) {
+		if value > 0 {
+			conditions = append(conditions, condition+" "+name)
+			values[name] = &types.AttributeValueMemberN{Value: strconv.FormatInt(value, 10)}
+		}
+	}
+
+	add("q >=", ":minScore", int64(f.MinScore))
+	add("l >=", ":minLevel", int64(f.MinLevel))
+	add("l <=", ":maxLevel", int64(f.MaxLevel))
+	add("rd <", ":revisionBefore", f.RevisionBefore)
+	add("rd >", ":revisionAfter", f.RevisionAfter)
+
+	return strings.Join(conditions, " and ")
+}
+
 type Summoners struct {
-	dynamodb   dynamoDbService
-	regions    regionsService
-	http       httpService
-	tableName  string
-	riotApiKey string
+	dynamodb      dynamoDbService
+	regions       regionsService
+	http          httpService
+	breaker       *CircuitBreaker
+	budget        *RiotBudget
+	limiter       *TokenBucketLimiter
+	keys          riotKeysService
+	workload      string
+	tableName     string
+	listingFilter func(summoner *SummonerDTO) bool
+	events        []eventPublisher
+	index         nameIndex
 }
 
-func NewSummoners(dynamoDbTableName string, riotApiKey string) (*Summoners, error) {
+func NewSummoners(dynamoDbTableName string, workload string) (*Summoners, error) {
 	cfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
 		return nil, err
 	}
 
+	keys, err := NewRiotKeysFromEnv(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewDynamoDbClient(cfg)
+
+	budget, limiter, err := NewRiotLimitersFromEnv(client, dynamoDbTableName)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Summoners{
-		dynamodb:   dynamodb.NewFromConfig(cfg),
-		regions:    NewRegions(),
-		http:       http.DefaultClient,
-		tableName:  dynamoDbTableName,
-		riotApiKey: riotApiKey,
+		dynamodb:  client,
+		regions:   NewRegions(),
+		http:      http.DefaultClient,
+		breaker:   NewCircuitBreaker(time.Minute, 10, 0.5, 30*time.Second),
+		budget:    budget,
+		limiter:   limiter,
+		keys:      keys,
+		workload:  workload,
+		tableName: dynamoDbTableName,
 	}, nil
 }
 
+// NewDynamoDbClient creates a DynamoDB client, sending requests to DYNAMODB_ENDPOINT instead of AWS when it
+// is set, e.g. to run against DynamoDB Local.
+func NewDynamoDbClient(cfg aws.Config) *dynamodb.Client {
+	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if endpoint := os.Getenv("DYNAMODB_ENDPOINT"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+}
+
 func (s *Summoners) Fetch(region string, summonerName string) (*SummonerDTO, error) {
 	riotRegion, err := s.regions.Get(region)
 	if err != nil {
 		return nil, err
 	}
 
-	url := fmt.Sprintf("https://%s.api.riotgames.com/lol/summoner/v4/summoners/by-name/%s", riotRegion, summonerName)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Add("X-Riot-Token", s.riotApiKey)
-
-	resp, err := s.http.Do(req)
+	requestUrl := fmt.Sprintf("https://%s.api.riotgames.com/lol/summoner/v4/summoners/by-name/%s", riotRegion, url.PathEscape(summonerName))
+	resp, err := s.riotGet(riotRegion, "summoner-v4-by-name", requestUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -124,37 +210,257 @@ func (s *Summoners) Fetch(region string, summonerName string) (*SummonerDTO, err
 	return s.summonerFromRiotSummoner(&riotSummoner, region)
 }
 
+func (s *Summoners) riotGet(platform string, method string, url string) (*http.Response, error) {
+	// The breaker is checked first so an open circuit neither uses up the budget nor waits on the limiter.
+	err := s.breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.budget.Acquire(context.TODO(), s.workload)
+	if err != nil {
+		s.breaker.Release()
+		return nil, err
+	}
+
+	err = s.limiter.Wait(context.TODO(), RateLimitKey(platform, method), time.Second)
+	if err != nil {
+		s.breaker.Release()
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		key, err := s.keys.Get(context.TODO(), s.workload)
+		if err != nil {
+			s.breaker.RecordFailure()
+			return nil, err
+		}
+
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			s.breaker.RecordFailure()
+			return nil, err
+		}
+
+		req.Header.Add("X-Riot-Token", key)
+
+		resp, err := s.http.Do(req)
+		if err != nil {
+			s.breaker.RecordFailure()
+			return nil, err
+		}
+
+		unauthorized := resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+		if unauthorized && attempt == 0 {
+			// The key may have been rotated since it was cached, so reload it and try once more.
+			if resp.Body != nil {
+				resp.Body.Close()
+			}
+			s.keys.Invalidate()
+			continue
+		}
+
+		if unauthorized {
+			s.breaker.Trip(fmt.Sprintf("riot api rejected the api key with status code %d", resp.StatusCode))
+		} else if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			s.breaker.RecordFailure()
+		} else {
+			s.breaker.RecordSuccess()
+		}
+
+		return resp, nil
+	}
+}
+
+func (s *Summoners) RiotHealth() CircuitHealth {
+	return s.breaker.Health()
+}
+
+func (s *Summoners) RiotBudgetUsage() (*BudgetUsage, error) {
+	return s.budget.Usage(context.TODO())
+}
+
+// Save writes a summoner, keeping the item hidden if an operator hid it. Only SetHidden unhides a name.
+// Summoners of erased accounts are never written, and a SuppressedError is returned instead. With an
+// index, the name is indexed when it is new, its availability date changed or it was saved unindexed.
 func (s *Summoners) Save(summoner *SummonerDTO) error {
-	_, err := s.dynamodb.PutItem(context.TODO(), &dynamodb.PutItemInput{
+	suppressed, err := isSuppressed(context.TODO(), s.dynamodb, s.tableName, summoner)
+	if err != nil {
+		return err
+	}
+
+	if suppressed {
+		return &SuppressedError{Name: summoner.Name}
+	}
+
+	item, err := attributevalue.MarshalMap(newSummonerItem(summoner))
+	if err != nil {
+		return err
+	}
+
+	if s.index != nil {
+		item["sx"] = &types.AttributeValueMemberN{Value: strconv.Itoa(searchIndexVersion)}
+	}
+
+	output, err := s.dynamodb.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName:           aws.String(s.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(h)"),
+		ReturnValues:        types.ReturnValueAllOld,
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		item["h"] = &types.AttributeValueMemberBOOL{Value: true}
+		output, err = s.dynamodb.PutItem(context.TODO(), &dynamodb.PutItemInput{
+			TableName:    aws.String(s.tableName),
+			Item:         item,
+			ReturnValues: types.ReturnValueAllOld,
+		})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.publish(nameEventFromSave(output.Attributes, summoner, time.Now()))
+
+	if s.index != nil && !isIndexed(output.Attributes, summoner) {
+		return s.reindex(summoner)
+	}
+
+	return nil
+}
+
+// isIndexed reports whether the item a save replaced was indexed by the current version of the index at
+// the summoner's availability date.
+func isIndexed(old map[string]types.AttributeValue, summoner *SummonerDTO) bool {
+	var previous summonerItem
+	if version, ok := old["sx"].(*types.AttributeValueMemberN); !ok || version.Value != strconv.Itoa(searchIndexVersion) {
+		return false
+	}
+
+	if attributevalue.UnmarshalMap(old, &previous) != nil {
+		return false
+	}
+
+	return previous.AvailabilityDate == summoner.AvailabilityDate
+}
+
+// reindex indexes a saved summoner. If that fails, the item is marked unindexed again so the next save
+// retries it.
+func (s *Summoners) reindex(summoner *SummonerDTO) error {
+	err := s.index.Index(context.TODO(), summoner)
+	if err == nil {
+		return nil
+	}
+
+	_, unmarkErr := s.dynamodb.UpdateItem(context.TODO(), &dynamodb.UpdateItemInput{
 		TableName: aws.String(s.tableName),
-		Item: map[string]types.AttributeValue{
-			"n":   &types.AttributeValueMemberS{Value: summoner.Region + "#" + strings.ToUpper(summoner.Name)},
-			"r":   &types.AttributeValueMemberS{Value: summoner.Region},
-			"ad":  &types.AttributeValueMemberN{Value: strconv.FormatInt(summoner.AvailabilityDate, 10)},
-			"aid": &types.AttributeValueMemberS{Value: summoner.AccountID},
-			"rd":  &types.AttributeValueMemberN{Value: strconv.FormatInt(summoner.RevisionDate, 10)},
-			"l":   &types.AttributeValueMemberN{Value: strconv.Itoa(summoner.Level)},
-			"nl":  &types.AttributeValueMemberS{Value: summoner.Region + "#" + strconv.Itoa(len(summoner.Name))},
-			"ld":  &types.AttributeValueMemberN{Value: strconv.FormatInt(summoner.LastUpdated, 10)},
-			"si":  &types.AttributeValueMemberN{Value: strconv.Itoa(summoner.SummonerIcon)},
+		Key: map[string]types.AttributeValue{
+			"n": &types.AttributeValueMemberS{Value: NameKey(summoner.Region, summoner.Name)},
 		},
+		UpdateExpression: aws.String("REMOVE sx"),
 	})
+	if unmarkErr != nil {
+		log.Printf("could not mark '%s' unindexed: %v", summoner.Name, unmarkErr)
+	}
+
+	return fmt.Errorf("could not index '%s': %v", summoner.Name, err)
+}
+
+// IndexNames keeps index in sync with the names this service saves and deletes.
+func (s *Summoners) IndexNames(index nameIndex) {
+	s.index = index
+}
+
+// PublishEvents publishes the name events saves observe to publisher, as well as to any publishers added
+// before.
+func (s *Summoners) PublishEvents(publisher eventPublisher) {
+	s.events = append(s.events, publisher)
+}
+
+// publish hands an event to each publisher. Saves do not fail when publishing does.
+func (s *Summoners) publish(event *NameEvent) {
+	if event == nil {
+		return
+	}
+
+	for _, publisher := range s.events {
+		if err := publisher.Publish(context.TODO(), event); err != nil {
+			log.Printf("could not publish %s event for '%s': %v", event.Type, event.Name, err)
+		}
+	}
+}
+
+// SetHidden hides a summoner from the summoners listings, or shows it again.
+func (s *Summoners) SetHidden(region string, summonerName string, hidden bool) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"n": &types.AttributeValueMemberS{Value: NameKey(region, summonerName)},
+		},
+		ConditionExpression: aws.String("attribute_exists(n)"),
+		UpdateExpression:    aws.String("REMOVE h"),
+	}
+
+	if hidden {
+		input.UpdateExpression = aws.String("SET h = :hidden")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":hidden": &types.AttributeValueMemberBOOL{Value: true},
+		}
+	}
+
+	_, err := s.dynamodb.UpdateItem(context.TODO(), input)
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return fmt.Errorf("summoner not found")
+	}
 
 	return err
 }
 
-func (s *Summoners) Delete(region string, summonerName string) error {
-	_, err := s.dynamodb.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
+func (s *Summoners) Get(region string, summonerName string) (*SummonerDTO, error) {
+	output, err := s.dynamodb.GetItem(context.TODO(), &dynamodb.GetItemInput{
 		TableName: aws.String(s.tableName),
 		Key: map[string]types.AttributeValue{
-			"n": &types.AttributeValueMemberS{Value: region + "#" + strings.ToUpper(summonerName)},
+			"n": &types.AttributeValueMemberS{Value: NameKey(region, summonerName)},
 		},
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return err
+	if output.Item == nil {
+		return nil, fmt.Errorf("summoner not found")
+	}
+
+	return SummonerFromItem(output.Item)
 }
 
-func (s *Summoners) GetByNameLength(region string, limit int32, nameLength int32, t1 int64, backwards bool) ([]*SummonerDTO, error) {
+// Delete deletes a summoner and the postings of its name, and reports whether the summoner was stored.
+func (s *Summoners) Delete(region string, summonerName string) (bool, error) {
+	output, err := s.dynamodb.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"n": &types.AttributeValueMemberS{Value: NameKey(region, summonerName)},
+		},
+		ReturnValues: types.ReturnValueAllOld,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	existed := len(output.Attributes) > 0
+	if s.index == nil {
+		return existed, nil
+	}
+
+	return existed, s.index.Remove(context.TODO(), region, summonerName)
+}
+
+func (s *Summoners) GetByNameLength(region string, limit int32, nameLength int32, t1 int64, backwards bool, filter ListingFilter) (*SummonersPage, error) {
 	valid := s.regions.Validate(region)
 	if !valid {
 		return nil, fmt.Errorf("invalid region '%s'", region)
@@ -167,26 +473,22 @@ func (s *Summoners) GetByNameLength(region string, limit int32, nameLength int32
 		keyConditionExpression = "nl = :nameLength and ad > :t1"
 	}
 
-	output, err := s.dynamodb.Query(context.TODO(), &dynamodb.QueryInput{
-		TableName:              aws.String(s.tableName),
-		Limit:                  aws.Int32(limit),
-		KeyConditionExpression: aws.String(keyConditionExpression),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":nameLength": &types.AttributeValueMemberS{Value: region + "#" + strconv.Itoa(int(nameLength))},
-			":t1":         &types.AttributeValueMemberN{Value: strconv.FormatInt(t1, 10)},
-		},
-		IndexName:        aws.String("name-length-availability-date-index"),
-		ScanIndexForward: aws.Bool(!backwards),
-	})
-
-	if err != nil {
-		return nil, err
+	values := map[string]types.AttributeValue{
+		":nameLength": &types.AttributeValueMemberS{Value: region + "#" + strconv.Itoa(int(nameLength))},
+		":t1":         &types.AttributeValueMemberN{Value: strconv.FormatInt(t1, 10)},
 	}
 
-	return SummonersFromQueryOutput(output)
+	return s.queryListing(limit, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		KeyConditionExpression:    aws.String(keyConditionExpression),
+		ExpressionAttributeValues: values,
+		IndexName:                 aws.String("name-length-availability-date-index"),
+		FilterExpression:          aws.String(filter.expression(values)),
+		ScanIndexForward:          aws.Bool(!backwards),
+	})
 }
 
-func (s *Summoners) GetAfter(region string, limit int32, t1 int64, backwards bool) ([]*SummonerDTO, error) {
+func (s *Summoners) GetAfter(region string, limit int32, t1 int64, backwards bool, filter ListingFilter) (*SummonersPage, error) {
 	valid := s.regions.Validate(region)
 	if !valid {
 		return nil, fmt.Errorf("invalid region '%s'", region)
@@ -199,26 +501,241 @@ func (s *Summoners) GetAfter(region string, limit int32, t1 int64, backwards boo
 		keyConditionExpression = "r = :region and ad > :t1"
 	}
 
-	output, err := s.dynamodb.Query(context.TODO(), &dynamodb.QueryInput{
-		TableName:              aws.String(s.tableName),
-		Limit:                  aws.Int32(limit),
-		KeyConditionExpression: aws.String(keyConditionExpression),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":region": &types.AttributeValueMemberS{Value: region},
-			":t1":     &types.AttributeValueMemberN{Value: strconv.FormatInt(t1, 10)},
-		},
-		IndexName:        aws.String("region-availability-date-index"),
-		ScanIndexForward: aws.Bool(!backwards),
+	values := map[string]types.AttributeValue{
+		":region": &types.AttributeValueMemberS{Value: region},
+		":t1":     &types.AttributeValueMemberN{Value: strconv.FormatInt(t1, 10)},
+	}
+
+	return s.queryListing(limit, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		KeyConditionExpression:    aws.String(keyConditionExpression),
+		ExpressionAttributeValues: values,
+		IndexName:                 aws.String("region-availability-date-index"),
+		FilterExpression:          aws.String(filter.expression(values)),
+		ScanIndexForward:          aws.Bool(!backwards),
 	})
+}
 
-	if err != nil {
+// GetByScore returns the best scored summoners in a region who become available between t1 and t2, with
+// names minLength to maxLength characters long unless both are 0. Ties are broken by availability date.
+// At most maxScoredItems items of the window are read; the page is marked truncated when the window has
+// more.
+func (s *Summoners) GetByScore(region string, limit int32, minLength int32, maxLength int32, t1 int64, t2 int64, filter ListingFilter) (*SummonersPage, error) {
+	valid := s.regions.Validate(region)
+	if !valid {
+		return nil, fmt.Errorf("invalid region '%s'", region)
+	}
+
+	values := map[string]types.AttributeValue{
+		":t1": &types.AttributeValueMemberN{Value: strconv.FormatInt(t1, 10)},
+		":t2": &types.AttributeValueMemberN{Value: strconv.FormatInt(t2, 10)},
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(s.tableName),
+		KeyConditionExpression:    aws.String("r = :region and ad between :t1 and :t2"),
+		ExpressionAttributeValues: values,
+		IndexName:                 aws.String("region-availability-date-index"),
+		FilterExpression:          aws.String(filter.expression(values)),
+	}
+
+	if minLength == 0 || minLength != maxLength {
+		values[":region"] = &types.AttributeValueMemberS{Value: region}
+	} else {
+		values[":nameLength"] = &types.AttributeValueMemberS{Value: region + "#" + strconv.Itoa(int(minLength))}
+		input.KeyConditionExpression = aws.String("nl = :nameLength and ad between :t1 and :t2")
+		input.IndexName = aws.String("name-length-availability-date-index")
+	}
+
+	page := &SummonersPage{}
+	scanned := 0
+	for {
+		input.Limit = aws.Int32(int32(min(maxScoredItems-scanned, 1000)))
+
+		output, err := s.dynamodb.Query(context.TODO(), input)
+		if err != nil {
+			return nil, err
+		}
+
+		items := SummonersFromItems(output.Items)
+		page.Skipped = append(page.Skipped, items.Skipped...)
+
+		for _, summoner := range items.Summoners {
+			if length := NameLength(summoner.Name); minLength > 0 && (length < int(minLength) || length > int(maxLength)) {
+				continue
+			}
+
+			if s.listingFilter != nil && !s.listingFilter(summoner) {
+				page.Filtered++
+				continue
+			}
+
+			page.Summoners = append(page.Summoners, summoner)
+		}
+
+		scanned += int(output.ScannedCount)
+		if len(output.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		if scanned >= maxScoredItems {
+			page.Truncated = true
+			break
+		}
+
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+
+	sort.SliceStable(page.Summoners, func(i, j int) bool {
+		a, b := page.Summoners[i], page.Summoners[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.AvailabilityDate < b.AvailabilityDate
+	})
+
+	if len(page.Summoners) > int(limit) {
+		page.Summoners = page.Summoners[:limit]
+	}
+
+	return page, nil
+}
+
+// queryListing fills a page of up to limit summoners for the public listings. Hidden, malformed and
+// filtered items are dropped, so it keeps reading from where the previous query stopped until the page
+// is full. Each query reads no more items than the page still needs, so every item before the last one
+// returned was either returned or dropped. When it stops before the end of the index, even with a short
+// or empty page, Next holds the availability date of the last item read for the client to continue from.
+func (s *Summoners) queryListing(limit int32, input *dynamodb.QueryInput) (*SummonersPage, error) {
+	page := &SummonersPage{Summoners: make([]*SummonerDTO, 0, limit)}
+
+	for queries := 0; queries < maxListingQueries; queries++ {
+		input.Limit = aws.Int32(limit - int32(len(page.Summoners)))
+
+		output, err := s.dynamodb.Query(context.TODO(), input)
+		if err != nil {
+			return nil, err
+		}
+
+		items := SummonersFromItems(output.Items)
+		page.Skipped = append(page.Skipped, items.Skipped...)
+
+		for _, summoner := range items.Summoners {
+			if s.listingFilter != nil && !s.listingFilter(summoner) {
+				page.Filtered++
+				continue
+			}
+
+			page.Summoners = append(page.Summoners, summoner)
+		}
+
+		page.Next = listingCursor(output.LastEvaluatedKey, page)
+		if len(page.Summoners) >= int(limit) || len(output.LastEvaluatedKey) == 0 {
+			break
+		}
+
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+
+	return page, nil
+}
+
+// listingCursor is the availability date in the key a listing query stopped at, or 0 at the end of the
+// index. Keys without one fall back to the last summoner of the page.
+func listingCursor(lastEvaluatedKey map[string]types.AttributeValue, page *SummonersPage) int64 {
+	if len(lastEvaluatedKey) == 0 {
+		return 0
+	}
+
+	if ad, ok := lastEvaluatedKey["ad"].(*types.AttributeValueMemberN); ok {
+		if cursor, err := strconv.ParseInt(ad.Value, 10, 64); err == nil {
+			return cursor
+		}
+	}
+
+	if len(page.Summoners) == 0 {
+		return 0
+	}
+
+	return page.Summoners[len(page.Summoners)-1].AvailabilityDate
+}
+
+// GetByNameLengths lists the summoners whose normalized name is minLength to maxLength characters long.
+// The name length index is queried for each length in parallel and the pages are merged by availability
+// date, see mergeListings.
+func (s *Summoners) GetByNameLengths(region string, limit int32, minLength int32, maxLength int32, t1 int64, backwards bool, filter ListingFilter) (*SummonersPage, error) {
+	if minLength > maxLength {
+		return nil, fmt.Errorf("invalid name length range %d to %d", minLength, maxLength)
+	}
+
+	pages := make([]*SummonersPage, maxLength-minLength+1)
+	errs := make([]error, len(pages))
+
+	var wg sync.WaitGroup
+	for i := range pages {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pages[i], errs[i] = s.GetByNameLength(region, limit, minLength+int32(i), t1, backwards, filter)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
 		return nil, err
 	}
 
-	return SummonersFromQueryOutput(output)
+	return mergeListings(pages, limit, backwards), nil
+}
+
+// mergeListings merges listing pages into one of up to limit summoners in availability date order. A page
+// that stopped before the end of its index may not have read names due before others in the merged page,
+// so the merged page ends at the earliest such cursor, and continues from it.
+func mergeListings(pages []*SummonersPage, limit int32, backwards bool) *SummonersPage {
+	before := func(a int64, b int64) bool {
+		if backwards {
+			return a > b
+		}
+		return a < b
+	}
+
+	merged := &SummonersPage{}
+	for _, page := range pages {
+		merged.Summoners = append(merged.Summoners, page.Summoners...)
+		merged.Skipped = append(merged.Skipped, page.Skipped...)
+		merged.Filtered += page.Filtered
+
+		if page.Next != 0 && (merged.Next == 0 || before(page.Next, merged.Next)) {
+			merged.Next = page.Next
+		}
+	}
+
+	sort.SliceStable(merged.Summoners, func(i, j int) bool {
+		return before(merged.Summoners[i].AvailabilityDate, merged.Summoners[j].AvailabilityDate)
+	})
+
+	if merged.Next != 0 {
+		end := sort.Search(len(merged.Summoners), func(i int) bool {
+			return before(merged.Next, merged.Summoners[i].AvailabilityDate)
+		})
+		merged.Summoners = merged.Summoners[:end]
+	}
+
+	if len(merged.Summoners) > int(limit) {
+		merged.Summoners = merged.Summoners[:limit]
+		merged.Next = merged.Summoners[limit-1].AvailabilityDate
+	}
+
+	return merged
 }
 
-func (s *Summoners) GetBetweenDate(region string, limit int32, t1 int64, t2 int64) ([]*SummonerDTO, error) {
+// FilterListings drops summoners the filter rejects from the listing pages. They are still stored and
+// refreshed as usual.
+func (s *Summoners) FilterListings(filter func(summoner *SummonerDTO) bool) {
+	s.listingFilter = filter
+}
+
+func (s *Summoners) GetBetweenDate(region string, limit int32, t1 int64, t2 int64) (*SummonersPage, error) {
 	valid := s.regions.Validate(region)
 	if !valid {
 		return nil, fmt.Errorf("invalid region '%s'", region)
@@ -240,60 +757,74 @@ func (s *Summoners) GetBetweenDate(region string, limit int32, t1 int64, t2 int6
 		return nil, err
 	}
 
-	return SummonersFromQueryOutput(output)
+	return SummonersFromItems(output.Items), nil
 }
 
-func CalcAvailabilityDate(revisionDate int64, level int32) int64 {
-	monthsToAdd := math.Min(30, math.Max(6, float64(level)))
-	return time.UnixMilli(revisionDate).UTC().AddDate(0, int(monthsToAdd), 0).UnixMilli()
-}
+// GetBetweenDateByNameLength returns summoners in a region whose normalized name has the given length and
+// who become available between t1 and t2.
+func (s *Summoners) GetBetweenDateByNameLength(region string, limit int32, nameLength int, t1 int64, t2 int64) (*SummonersPage, error) {
+	valid := s.regions.Validate(region)
+	if !valid {
+		return nil, fmt.Errorf("invalid region '%s'", region)
+	}
 
-func SummonersFromQueryOutput(output *dynamodb.QueryOutput) ([]*SummonerDTO, error) {
-	summoners := make([]*SummonerDTO, len(output.Items))
+	output, err := s.dynamodb.Query(context.TODO(), &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		Limit:                  aws.Int32(limit),
+		KeyConditionExpression: aws.String("nl = :nameLength and ad between :t1 and :t2"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":nameLength": &types.AttributeValueMemberS{Value: region + "#" + strconv.Itoa(nameLength)},
+			":t1":         &types.AttributeValueMemberN{Value: strconv.FormatInt(t1, 10)},
+			":t2":         &types.AttributeValueMemberN{Value: strconv.FormatInt(t2, 10)},
+		},
+		IndexName: aws.String("name-length-availability-date-index"),
+	})
 
-	for i, item := range output.Items {
-		revisionDate, err := strconv.ParseInt(item["rd"].(*types.AttributeValueMemberN).Value, 10, 64)
-		if err != nil {
-			return nil, err
-		}
+	if err != nil {
+		return nil, err
+	}
 
-		availabilityDate, err := strconv.ParseInt(item["ad"].(*types.AttributeValueMemberN).Value, 10, 64)
-		if err != nil {
-			return nil, err
-		}
+	return SummonersFromItems(output.Items), nil
+}
 
-		level, err := strconv.Atoi(item["l"].(*types.AttributeValueMemberN).Value)
-		if err != nil {
-			return nil, err
-		}
+// Count returns how many summoners in a region become available between t1 and t2.
+func (s *Summoners) Count(region string, t1 int64, t2 int64) (int, error) {
+	valid := s.regions.Validate(region)
+	if !valid {
+		return 0, fmt.Errorf("invalid region '%s'", region)
+	}
 
-		lastUpdated, err := strconv.ParseInt(item["ld"].(*types.AttributeValueMemberN).Value, 10, 64)
+	count := 0
+	var startKey map[string]types.AttributeValue
+	for {
+		output, err := s.dynamodb.Query(context.TODO(), &dynamodb.QueryInput{
+			TableName:              aws.String(s.tableName),
+			KeyConditionExpression: aws.String("r = :region and ad between :t1 and :t2"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":region": &types.AttributeValueMemberS{Value: region},
+				":t1":     &types.AttributeValueMemberN{Value: strconv.FormatInt(t1, 10)},
+				":t2":     &types.AttributeValueMemberN{Value: strconv.FormatInt(t2, 10)},
+			},
+			IndexName:         aws.String("region-availability-date-index"),
+			Select:            types.SelectCount,
+			ExclusiveStartKey: startKey,
+		})
 		if err != nil {
-			return nil, err
+			return count, err
 		}
 
-		var summonerIcon int
-		summonerIconStr := item["si"]
-		if item["si"] != nil {
-			summonerIcon, err = strconv.Atoi(summonerIconStr.(*types.AttributeValueMemberN).Value)
-			if err != nil {
-				return nil, err
-			}
+		count += int(output.Count)
+		if len(output.LastEvaluatedKey) == 0 {
+			return count, nil
 		}
 
-		summoners[i] = &SummonerDTO{
-			Name:             strings.ToLower(strings.SplitAfter(item["n"].(*types.AttributeValueMemberS).Value, "#")[1]),
-			Region:           item["r"].(*types.AttributeValueMemberS).Value,
-			AccountID:        item["aid"].(*types.AttributeValueMemberS).Value,
-			RevisionDate:     revisionDate,
-			AvailabilityDate: availabilityDate,
-			Level:            level,
-			LastUpdated:      lastUpdated,
-			SummonerIcon:     summonerIcon,
-		}
+		startKey = output.LastEvaluatedKey
 	}
+}
 
-	return summoners, nil
+func CalcAvailabilityDate(revisionDate int64, level int32) int64 {
+	monthsToAdd := math.Min(30, math.Max(6, float64(level)))
+	return time.UnixMilli(revisionDate).UTC().AddDate(0, int(monthsToAdd), 0).UnixMilli()
 }
 
 func (s *Summoners) summonerFromRiotSummoner(riotSummoner *RiotSummonerDTO, region string) (*SummonerDTO, error) {
@@ -306,10 +837,12 @@ func (s *Summoners) summonerFromRiotSummoner(riotSummoner *RiotSummonerDTO, regi
 		Name:             riotSummoner.Name,
 		Region:           region,
 		AccountID:        riotSummoner.AccountId,
+		Puuid:            riotSummoner.Puuid,
 		RevisionDate:     riotSummoner.RevisionDate,
 		AvailabilityDate: CalcAvailabilityDate(riotSummoner.RevisionDate, int32(riotSummoner.SummonerLevel)),
 		Level:            riotSummoner.SummonerLevel,
 		LastUpdated:      time.Now().UnixMilli(),
 		SummonerIcon:     riotSummoner.ProfileIconId,
+		Score:            QualityScore(riotSummoner.Name),
 	}, nil
 }
@@ -3,21 +3,32 @@ package shared
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
 type DynamoDBServiceMock struct {
-	ShouldReturnError bool
-	QueryCalls        []struct {
+	ShouldReturnError  bool
+	ShouldReturnNoItem bool
+	StoredItemHidden   bool
+	Suppressed         bool
+	OldItem            map[string]types.AttributeValue
+	QueryOutputs       []*dynamodb.QueryOutput
+	GetItemCalls       []*dynamodb.GetItemInput
+	UpdateItemCalls    []*dynamodb.UpdateItemInput
+	QueryCalls         []struct {
 		Input *dynamodb.QueryInput
 	}
 	PutItemCalls []struct {
@@ -28,6 +39,28 @@ type DynamoDBServiceMock struct {
 	}
 }
 
+func (d *DynamoDBServiceMock) GetItem(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	d.GetItemCalls = append(d.GetItemCalls, input)
+
+	if d.ShouldReturnError {
+		return nil, fmt.Errorf("error")
+	}
+
+	if key := input.Key["n"].(*types.AttributeValueMemberS).Value; strings.HasPrefix(key, "suppressed#") {
+		if d.Suppressed {
+			return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{"n": input.Key["n"]}}, nil
+		}
+
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	if d.ShouldReturnNoItem {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	return &dynamodb.GetItemOutput{Item: newTestSummonerItem()}, nil
+}
+
 func (d *DynamoDBServiceMock) Query(_ context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
 	d.QueryCalls = append(d.QueryCalls, struct {
 		Input *dynamodb.QueryInput
@@ -37,6 +70,10 @@ func (d *DynamoDBServiceMock) Query(_ context.Context, input *dynamodb.QueryInpu
 		return nil, fmt.Errorf("error")
 	}
 
+	if d.QueryOutputs != nil {
+		return d.QueryOutputs[len(d.QueryCalls)-1], nil
+	}
+
 	return &dynamodb.QueryOutput{
 		Items: []map[string]types.AttributeValue{
 			{
@@ -50,6 +87,7 @@ func (d *DynamoDBServiceMock) Query(_ context.Context, input *dynamodb.QueryInpu
 				"si":  &types.AttributeValueMemberN{Value: "123"},
 			},
 		},
+		Count: 1,
 	}, nil
 }
 
@@ -62,7 +100,25 @@ func (d *DynamoDBServiceMock) PutItem(_ context.Context, input *dynamodb.PutItem
 		return nil, fmt.Errorf("error")
 	}
 
-	return &dynamodb.PutItemOutput{}, nil
+	if d.StoredItemHidden && input.ConditionExpression != nil {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	return &dynamodb.PutItemOutput{Attributes: d.OldItem}, nil
+}
+
+func (d *DynamoDBServiceMock) UpdateItem(_ context.Context, input *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	d.UpdateItemCalls = append(d.UpdateItemCalls, input)
+
+	if d.ShouldReturnError {
+		return nil, fmt.Errorf("error")
+	}
+
+	if d.ShouldReturnNoItem {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	return &dynamodb.UpdateItemOutput{}, nil
 }
 
 func (d *DynamoDBServiceMock) DeleteItem(_ context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
@@ -74,7 +130,11 @@ func (d *DynamoDBServiceMock) DeleteItem(_ context.Context, input *dynamodb.Dele
 		return nil, fmt.Errorf("error")
 	}
 
-	return &dynamodb.DeleteItemOutput{}, nil
+	if d.ShouldReturnNoItem {
+		return &dynamodb.DeleteItemOutput{}, nil
+	}
+
+	return &dynamodb.DeleteItemOutput{Attributes: map[string]types.AttributeValue{"n": input.Key["n"]}}, nil
 }
 
 type RegionsServiceMock struct {
@@ -97,6 +157,7 @@ type MockHttpClient struct {
 	ShouldFail      bool
 	ShouldReturn404 bool
 	ShouldReturn500 bool
+	ShouldReturn401 bool
 	Calls           []struct {
 		Request *http.Request
 	}
@@ -127,6 +188,16 @@ func (m *MockHttpClient) Do(req *http.Request) (*http.Response, error) {
 		}, nil
 	}
 
+	if m.ShouldReturn401 {
+		return &http.Response{
+			StatusCode: 401,
+			Body: &MockReadCloser{
+				Reader: strings.NewReader("unauthorized"),
+				closed: false,
+			},
+		}, nil
+	}
+
 	if m.ShouldReturn500 {
 		return &http.Response{
 			StatusCode: 500,
@@ -170,11 +241,15 @@ func setup() {
 	riotApiKey = "riot-api-key"
 
 	summoners = &Summoners{
-		dynamodb:   &DynamoDBServiceMock{},
-		regions:    &RegionsServiceMock{},
-		http:       &MockHttpClient{},
-		tableName:  tableName,
-		riotApiKey: riotApiKey,
+		dynamodb:  &DynamoDBServiceMock{},
+		regions:   &RegionsServiceMock{},
+		http:      &MockHttpClient{},
+		breaker:   NewCircuitBreaker(time.Minute, 10, 0.5, 30*time.Second),
+		budget:    NewRiotBudget(NewMemoryBudgetCounter(), 100, 2*time.Minute, 0.5),
+		limiter:   NewTokenBucketLimiter(NewMemoryBucketStore(), 100, time.Minute),
+		keys:      NewRiotKeys(StaticSecretsSource{"riot-api-key": riotApiKey}, []string{"riot-api-key"}, time.Minute),
+		workload:  WorkloadInteractive,
+		tableName: tableName,
 	}
 }
 
@@ -254,6 +329,19 @@ func TestFetch_CallsHttpClientWithCorrectUrl(t *testing.T) {
 	}
 }
 
+func TestFetch_EscapesNameInUrl(t *testing.T) {
+	setup()
+
+	_, _ = summoners.Fetch("na1", "Hide on/bush?#")
+
+	expectedUrl := "https://na1.api.riotgames.com/lol/summoner/v4/summoners/by-name/Hide%20on%2Fbush%3F%23"
+	actualUrl := summoners.http.(*MockHttpClient).Calls[0].Request.URL.String()
+
+	if actualUrl != expectedUrl {
+		t.Errorf("expected %s, got %s", expectedUrl, actualUrl)
+	}
+}
+
 func TestFetch_CallsHttpClientWithCorrectAuthToken(t *testing.T) {
 	setup()
 
@@ -333,6 +421,133 @@ func TestFetch_ReturnsCorrectSummonerWhenResponseSuccessful(t *testing.T) {
 	}
 }
 
+func TestFetch_WhenHttpClientReturns401_OpensCircuit(t *testing.T) {
+	setup()
+	summoners.http.(*MockHttpClient).ShouldReturn401 = true
+	_, err := summoners.Fetch("na1", "test")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if summoners.RiotHealth().State != CircuitOpen {
+		t.Errorf("expected %s, got %s", CircuitOpen, summoners.RiotHealth().State)
+	}
+}
+
+func TestFetch_WhenHttpClientReturns401_ReloadsKeyAndRetriesOnce(t *testing.T) {
+	setup()
+	summoners.http.(*MockHttpClient).ShouldReturn401 = true
+	_, _ = summoners.Fetch("na1", "test")
+
+	if len(summoners.http.(*MockHttpClient).Calls) != 2 {
+		t.Errorf("expected 2, got %d", len(summoners.http.(*MockHttpClient).Calls))
+	}
+}
+
+func TestFetch_WhenRiotKeyCannotBeLoaded_ReturnsError(t *testing.T) {
+	setup()
+	summoners.keys = NewRiotKeys(StaticSecretsSource{}, []string{"missing"}, time.Minute)
+
+	_, err := summoners.Fetch("na1", "test")
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if len(summoners.http.(*MockHttpClient).Calls) != 0 {
+		t.Errorf("expected 0, got %d", len(summoners.http.(*MockHttpClient).Calls))
+	}
+}
+
+func TestFetch_WhenBudgetIsExhausted_ReturnsErrorWithoutCallingHttpClient(t *testing.T) {
+	setup()
+	summoners.budget = NewRiotBudget(NewMemoryBudgetCounter(), 0, 2*time.Minute, 0.5)
+
+	_, err := summoners.Fetch("na1", "test")
+
+	var budgetErr *BudgetExhaustedError
+	if !errors.As(err, &budgetErr) {
+		t.Errorf("expected BudgetExhaustedError, got %v", err)
+	}
+
+	if len(summoners.http.(*MockHttpClient).Calls) != 0 {
+		t.Errorf("expected 0, got %d", len(summoners.http.(*MockHttpClient).Calls))
+	}
+}
+
+func TestFetch_WhenMethodRateLimitIsReached_ReturnsErrorWithoutCallingHttpClient(t *testing.T) {
+	setup()
+	summoners.limiter = NewTokenBucketLimiter(NewMemoryBucketStore(), 1, time.Hour)
+
+	_, _ = summoners.Fetch("na1", "test")
+	_, err := summoners.Fetch("na1", "test")
+
+	var rateLimitedErr *RateLimitedError
+	if !errors.As(err, &rateLimitedErr) {
+		t.Errorf("expected RateLimitedError, got %v", err)
+	}
+
+	if rateLimitedErr.Key != "na1#summoner-v4-by-name" {
+		t.Errorf("expected na1#summoner-v4-by-name, got %s", rateLimitedErr.Key)
+	}
+
+	if len(summoners.http.(*MockHttpClient).Calls) != 1 {
+		t.Errorf("expected 1, got %d", len(summoners.http.(*MockHttpClient).Calls))
+	}
+}
+
+func TestFetch_WhenCircuitIsOpen_FailsFastWithoutCallingHttpClient(t *testing.T) {
+	setup()
+	summoners.breaker.Trip("test")
+
+	_, err := summoners.Fetch("na1", "test")
+
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Errorf("expected CircuitOpenError, got %v", err)
+	}
+
+	if len(summoners.http.(*MockHttpClient).Calls) != 0 {
+		t.Errorf("expected 0, got %d", len(summoners.http.(*MockHttpClient).Calls))
+	}
+}
+
+func TestFetch_WhenCircuitIsOpen_DoesNotUseBudget(t *testing.T) {
+	setup()
+	summoners.breaker.Trip("test")
+
+	_, _ = summoners.Fetch("na1", "test")
+
+	usage, _ := summoners.RiotBudgetUsage()
+	if usage.InteractiveUsed != 0 {
+		t.Errorf("expected 0, got %d", usage.InteractiveUsed)
+	}
+}
+
+func TestFetch_WhenBudgetIsExhausted_ReleasesCircuitProbe(t *testing.T) {
+	setup()
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	summoners.breaker.now = clock.Now
+	summoners.breaker.Trip("test")
+	clock.Advance(time.Minute)
+
+	summoners.budget = NewRiotBudget(NewMemoryBudgetCounter(), 0, 2*time.Minute, 0.5)
+	_, _ = summoners.Fetch("na1", "test")
+
+	if err := summoners.breaker.Allow(); err != nil {
+		t.Errorf("expected the probe to be released, got %v", err)
+	}
+}
+
+func TestFetch_WhenHttpClientReturns404_DoesNotCountAsFailure(t *testing.T) {
+	setup()
+	summoners.http.(*MockHttpClient).ShouldReturn404 = true
+	_, _ = summoners.Fetch("na1", "test")
+
+	if summoners.RiotHealth().Failures != 0 {
+		t.Errorf("expected 0, got %d", summoners.RiotHealth().Failures)
+	}
+}
+
 func TestGetBetweenDate_ReturnsErrorIfRegionValidationFails(t *testing.T) {
 	setup()
 	summoners.regions.(*RegionsServiceMock).IsInvalid = true
@@ -402,12 +617,45 @@ func TestGetBetweenDate_ReturnsCorrectSummonerDTOs(t *testing.T) {
 		t.Errorf("expected nil, got %v", err)
 	}
 
-	if len(result) != 1 {
-		t.Errorf("expected 1, got %d", len(result))
+	if len(result.Summoners) != 1 {
+		t.Errorf("expected 1, got %d", len(result.Summoners))
+	}
+}
+
+func TestGetBetweenDateByNameLength_QueriesNameLengthIndexBetweenDates(t *testing.T) {
+	setup()
+
+	_, err := summoners.GetBetweenDateByNameLength("region", 25, 4, 100, 200)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	input := summoners.dynamodb.(*DynamoDBServiceMock).QueryCalls[0].Input
+	if *input.IndexName != "name-length-availability-date-index" || *input.Limit != 25 {
+		t.Errorf("unexpected index %s or limit %d", *input.IndexName, *input.Limit)
+	}
+
+	if *input.KeyConditionExpression != "nl = :nameLength and ad between :t1 and :t2" {
+		t.Errorf("unexpected key condition %s", *input.KeyConditionExpression)
+	}
+
+	values := input.ExpressionAttributeValues
+	if values[":nameLength"].(*types.AttributeValueMemberS).Value != "region#4" ||
+		values[":t1"].(*types.AttributeValueMemberN).Value != "100" ||
+		values[":t2"].(*types.AttributeValueMemberN).Value != "200" {
+		t.Errorf("unexpected expression attribute values %v", values)
 	}
 }
 
-func TestSummonersFromQueryOutput(t *testing.T) {
+func TestGetBetweenDateByNameLength_ReturnsErrorIfRegionValidationFails(t *testing.T) {
+	setup()
+	summoners.regions.(*RegionsServiceMock).IsInvalid = true
+	if _, err := summoners.GetBetweenDateByNameLength("invalid", 10, 4, 0, 0); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestSummonersFromItems(t *testing.T) {
 	output := dynamodb.QueryOutput{
 		Items: []map[string]types.AttributeValue{
 			{
@@ -433,9 +681,11 @@ func TestSummonersFromQueryOutput(t *testing.T) {
 		},
 	}
 
-	result, err := SummonersFromQueryOutput(&output)
-	if err != nil {
-		t.Errorf("Error: %v", err)
+	page := SummonersFromItems(output.Items)
+	result := page.Summoners
+
+	if len(page.Skipped) != 0 {
+		t.Errorf("Expected no skipped items, got %v", page.Skipped)
 	}
 
 	if len(result) != 2 {
@@ -595,21 +845,274 @@ func TestSave_CallsDynamoDBPutItemWithCorrectInput(t *testing.T) {
 	}
 }
 
+func TestSave_UsesNormalizedKeyAndCharacterLength(t *testing.T) {
+	setup()
+
+	err := summoners.Save(&SummonerDTO{Name: "Ébène Noir", Region: "EUW"})
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	item := summoners.dynamodb.(*DynamoDBServiceMock).PutItemCalls[0].Input.Item
+
+	if item["n"].(*types.AttributeValueMemberS).Value != "EUW#ÉBÈNENOIR" {
+		t.Errorf("expected EUW#ÉBÈNENOIR, got %s", item["n"].(*types.AttributeValueMemberS).Value)
+	}
+
+	if item["nl"].(*types.AttributeValueMemberS).Value != "EUW#9" {
+		t.Errorf("expected EUW#9, got %s", item["nl"].(*types.AttributeValueMemberS).Value)
+	}
+}
+
+func TestSave_KeepsHiddenSummonerHidden(t *testing.T) {
+	setup()
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	mock.StoredItemHidden = true
+
+	err := summoners.Save(&SummonerDTO{Name: "test", Region: "NA"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(mock.PutItemCalls) != 2 {
+		t.Fatalf("expected a second put for the hidden item, got %d puts", len(mock.PutItemCalls))
+	}
+
+	hidden, ok := mock.PutItemCalls[1].Input.Item["h"].(*types.AttributeValueMemberBOOL)
+	if !ok || !hidden.Value {
+		t.Errorf("expected item to stay hidden, got %v", mock.PutItemCalls[1].Input.Item["h"])
+	}
+}
+
+func TestSave_DoesNotWriteHiddenAttributeForVisibleSummoner(t *testing.T) {
+	setup()
+
+	err := summoners.Save(&SummonerDTO{Name: "test", Region: "NA"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	if len(mock.PutItemCalls) != 1 || mock.PutItemCalls[0].Input.Item["h"] != nil {
+		t.Errorf("expected a single put without 'h', got %v", mock.PutItemCalls)
+	}
+}
+
+type EventPublisherMock struct {
+	ShouldFail bool
+	Events     []*NameEvent
+}
+
+func (m *EventPublisherMock) Publish(_ context.Context, event *NameEvent) error {
+	m.Events = append(m.Events, event)
+	if m.ShouldFail {
+		return fmt.Errorf("error")
+	}
+	return nil
+}
+
+func TestSave_PublishesEventObservedBySave(t *testing.T) {
+	setup()
+	publisher := &EventPublisherMock{ShouldFail: true}
+	summoners.PublishEvents(publisher)
+
+	later := time.Now().Add(24 * time.Hour).UnixMilli()
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	mock.OldItem, _ = attributevalue.MarshalMap(&summonerItem{Key: "NA#TEST", Region: "NA", AccountID: "aid", AvailabilityDate: 1})
+
+	err := summoners.Save(&SummonerDTO{Name: "test", Region: "NA", AccountID: "aid", AvailabilityDate: later})
+	if err != nil {
+		t.Fatalf("expected a failing publisher not to fail the save, got %v", err)
+	}
+
+	if mock.PutItemCalls[0].Input.ReturnValues != types.ReturnValueAllOld {
+		t.Errorf("expected the save to return the replaced item")
+	}
+
+	if len(publisher.Events) != 1 || publisher.Events[0].Type != EventNameTaken || publisher.Events[0].AvailabilityDate != later {
+		t.Errorf("expected a %s event, got %+v", EventNameTaken, publisher.Events)
+	}
+}
+
+type NameIndexMock struct {
+	ShouldFail bool
+	Indexed    []string
+	Removed    []string
+}
+
+func (m *NameIndexMock) Index(_ context.Context, summoner *SummonerDTO) error {
+	if m.ShouldFail {
+		return fmt.Errorf("error")
+	}
+
+	m.Indexed = append(m.Indexed, fmt.Sprintf("%s#%s@%d", summoner.Region, summoner.Name, summoner.AvailabilityDate))
+	return nil
+}
+
+func (m *NameIndexMock) Remove(_ context.Context, region string, name string) error {
+	if m.ShouldFail {
+		return fmt.Errorf("error")
+	}
+
+	m.Removed = append(m.Removed, region+"#"+name)
+	return nil
+}
+
+func TestSave_IndexesNewChangedAndUnindexedNames(t *testing.T) {
+	setup()
+	index := &NameIndexMock{}
+	summoners.IndexNames(index)
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+
+	_ = summoners.Save(&SummonerDTO{Name: "test", Region: "NA", AccountID: "aid", AvailabilityDate: 5})
+	if _, ok := mock.PutItemCalls[0].Input.Item["sx"]; !ok || len(index.Indexed) != 1 || index.Indexed[0] != "NA#test@5" {
+		t.Fatalf("expected a new name to be indexed and marked, got %v", index.Indexed)
+	}
+
+	mock.OldItem, _ = attributevalue.MarshalMap(&summonerItem{Key: "NA#TEST", Region: "NA", AvailabilityDate: 5})
+	mock.OldItem["sx"] = &types.AttributeValueMemberN{Value: "2"}
+	_ = summoners.Save(&SummonerDTO{Name: "test", Region: "NA", AccountID: "aid", AvailabilityDate: 5})
+	if len(index.Indexed) != 1 {
+		t.Errorf("expected an unchanged indexed name not to be indexed again, got %v", index.Indexed)
+	}
+
+	_ = summoners.Save(&SummonerDTO{Name: "test", Region: "NA", AccountID: "aid", AvailabilityDate: 6})
+	mock.OldItem["sx"] = &types.AttributeValueMemberN{Value: "1"}
+	_ = summoners.Save(&SummonerDTO{Name: "test", Region: "NA", AccountID: "aid", AvailabilityDate: 5})
+	delete(mock.OldItem, "sx")
+	_ = summoners.Save(&SummonerDTO{Name: "test", Region: "NA", AccountID: "aid", AvailabilityDate: 5})
+	if len(index.Indexed) != 4 {
+		t.Errorf("expected changed, outdated and unindexed names to be indexed, got %v", index.Indexed)
+	}
+}
+
+func TestSave_MarksNameUnindexedWhenIndexingFails(t *testing.T) {
+	setup()
+	summoners.IndexNames(&NameIndexMock{ShouldFail: true})
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+
+	err := summoners.Save(&SummonerDTO{Name: "test", Region: "NA", AccountID: "aid"})
+	if err == nil || len(mock.PutItemCalls) != 1 {
+		t.Fatalf("expected the saved name to fail indexing, got %v", err)
+	}
+
+	if len(mock.UpdateItemCalls) != 1 || *mock.UpdateItemCalls[0].UpdateExpression != "REMOVE sx" {
+		t.Errorf("expected the name to be marked unindexed, got %v", mock.UpdateItemCalls)
+	}
+}
+
+func TestSave_DoesNotMarkNamesWithoutIndex(t *testing.T) {
+	setup()
+
+	_ = summoners.Save(&SummonerDTO{Name: "test", Region: "NA", AccountID: "aid"})
+	if _, ok := summoners.dynamodb.(*DynamoDBServiceMock).PutItemCalls[0].Input.Item["sx"]; ok {
+		t.Errorf("expected a name saved without an index not to be marked indexed")
+	}
+}
+
+func TestSave_StoresPuuidAndChecksSuppressionEntries(t *testing.T) {
+	setup()
+
+	err := summoners.Save(&SummonerDTO{Name: "test", Region: "NA", AccountID: "123", Puuid: "abc"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	if len(mock.GetItemCalls) != 2 || mock.GetItemCalls[0].Key["n"].(*types.AttributeValueMemberS).Value != SuppressionKey(SuppressPuuid, "abc") {
+		t.Errorf("expected puuid and account suppression entries to be checked, got %v", mock.GetItemCalls)
+	}
+
+	if pid := mock.PutItemCalls[0].Input.Item["pid"].(*types.AttributeValueMemberS).Value; pid != "abc" {
+		t.Errorf("expected abc, got %s", pid)
+	}
+}
+
+func TestSave_DoesNotWriteSuppressedSummoner(t *testing.T) {
+	setup()
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	mock.Suppressed = true
+
+	err := summoners.Save(&SummonerDTO{Name: "test", Region: "NA", AccountID: "123"})
+	if !IsSuppressed(err) {
+		t.Fatalf("expected SuppressedError, got %v", err)
+	}
+
+	if len(mock.PutItemCalls) != 0 {
+		t.Errorf("expected nothing to be written, got %d puts", len(mock.PutItemCalls))
+	}
+}
+
+func TestSetHidden_SetsAndRemovesHiddenAttribute(t *testing.T) {
+	setup()
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+
+	if err := summoners.SetHidden("NA", "Te st", true); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if err := summoners.SetHidden("NA", "Te st", false); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if *mock.UpdateItemCalls[0].UpdateExpression != "SET h = :hidden" || *mock.UpdateItemCalls[1].UpdateExpression != "REMOVE h" {
+		t.Errorf("unexpected update expressions")
+	}
+
+	if mock.UpdateItemCalls[0].Key["n"].(*types.AttributeValueMemberS).Value != "NA#TEST" {
+		t.Errorf("expected NA#TEST, got %v", mock.UpdateItemCalls[0].Key["n"])
+	}
+}
+
+func TestSetHidden_ReturnsNotFoundForMissingSummoner(t *testing.T) {
+	setup()
+	summoners.dynamodb.(*DynamoDBServiceMock).ShouldReturnNoItem = true
+
+	err := summoners.SetHidden("NA", "test", true)
+	if err == nil || err.Error() != "summoner not found" {
+		t.Errorf("expected summoner not found, got %v", err)
+	}
+}
+
+func TestGetAfter_FiltersHiddenSummoners(t *testing.T) {
+	setup()
+
+	_, err := summoners.GetAfter("region", 10, 0, false, ListingFilter{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	input := summoners.dynamodb.(*DynamoDBServiceMock).QueryCalls[0].Input
+	if input.FilterExpression == nil || *input.FilterExpression != "attribute_not_exists(h)" {
+		t.Errorf("expected hidden summoners to be filtered, got %v", input.FilterExpression)
+	}
+}
+
 func TestDelete_ReturnsErrorWhenDynamoDBDeleteItemFails(t *testing.T) {
 	setup()
 	summoners.dynamodb.(*DynamoDBServiceMock).ShouldReturnError = true
-	err := summoners.Delete("region", "test")
+	_, err := summoners.Delete("region", "test")
 	if err == nil {
 		t.Errorf("expected error, got nil")
 	}
 }
 
+func TestDelete_RemovesNameFromIndex(t *testing.T) {
+	setup()
+	index := &NameIndexMock{}
+	summoners.IndexNames(index)
+
+	if _, err := summoners.Delete("NA", "test"); err != nil || len(index.Removed) != 1 || index.Removed[0] != "NA#test" {
+		t.Errorf("expected the name to be removed from the index, got %v, %v", err, index.Removed)
+	}
+}
+
 func TestDelete_CallsDynamoDBDeleteItemWithCorrectInput(t *testing.T) {
 	setup()
 
-	err := summoners.Delete("NA", "test")
-	if err != nil {
-		t.Errorf("expected nil, got %v", err)
+	existed, err := summoners.Delete("NA", "test")
+	if err != nil || !existed {
+		t.Errorf("expected the summoner to be deleted, got %v, %v", existed, err)
 	}
 
 	expectedInput := &dynamodb.DeleteItemInput{
@@ -630,10 +1133,25 @@ func TestDelete_CallsDynamoDBDeleteItemWithCorrectInput(t *testing.T) {
 	}
 }
 
+func TestDelete_ReportsWhenNoSummonerWasStored(t *testing.T) {
+	setup()
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	mock.ShouldReturnNoItem = true
+
+	existed, err := summoners.Delete("NA", "test")
+	if err != nil || existed {
+		t.Errorf("expected no summoner to be deleted, got %v, %v", existed, err)
+	}
+
+	if mock.DeleteItemCalls[0].Input.ReturnValues != types.ReturnValueAllOld {
+		t.Errorf("expected the deleted item to be returned, got %v", mock.DeleteItemCalls[0].Input.ReturnValues)
+	}
+}
+
 func TestGetAfter_ReturnsErrorIfRegionValidationFails(t *testing.T) {
 	setup()
 	summoners.regions.(*RegionsServiceMock).IsInvalid = true
-	_, err := summoners.GetAfter("invalid", 10, 0, false)
+	_, err := summoners.GetAfter("invalid", 10, 0, false, ListingFilter{})
 	if err == nil {
 		t.Errorf("expected error, got nil")
 	}
@@ -642,7 +1160,7 @@ func TestGetAfter_ReturnsErrorIfRegionValidationFails(t *testing.T) {
 func TestGetAfter_ReturnsErrorIfDynamoDBQueryFails(t *testing.T) {
 	setup()
 	summoners.dynamodb.(*DynamoDBServiceMock).ShouldReturnError = true
-	_, err := summoners.GetAfter("region", 10, 0, false)
+	_, err := summoners.GetAfter("region", 10, 0, false, ListingFilter{})
 	if err == nil {
 		t.Errorf("expected error, got nil")
 	}
@@ -650,7 +1168,7 @@ func TestGetAfter_ReturnsErrorIfDynamoDBQueryFails(t *testing.T) {
 
 func TestGetAfter_UsesCorrectTableName(t *testing.T) {
 	setup()
-	_, err := summoners.GetAfter("region", 10, 0, false)
+	_, err := summoners.GetAfter("region", 10, 0, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -668,7 +1186,7 @@ func TestGetAfter_UsesCorrectTableName(t *testing.T) {
 
 func TestGetAfter_UsesCorrectLimit(t *testing.T) {
 	setup()
-	_, err := summoners.GetAfter("region", 10, 0, false)
+	_, err := summoners.GetAfter("region", 10, 0, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -686,7 +1204,7 @@ func TestGetAfter_UsesCorrectLimit(t *testing.T) {
 
 func TestGetAfter_UsesCorrectExpressionAttributeValues(t *testing.T) {
 	setup()
-	_, err := summoners.GetAfter("region", 10, 12345, false)
+	_, err := summoners.GetAfter("region", 10, 12345, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -708,7 +1226,7 @@ func TestGetAfter_UsesCorrectExpressionAttributeValues(t *testing.T) {
 
 func TestGetAfter_UsesCorrectIndexName(t *testing.T) {
 	setup()
-	_, err := summoners.GetAfter("region", 10, 0, false)
+	_, err := summoners.GetAfter("region", 10, 0, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -726,7 +1244,7 @@ func TestGetAfter_UsesCorrectIndexName(t *testing.T) {
 
 func TestGetAfter_UsesCorrectScanIndexForward(t *testing.T) {
 	setup()
-	_, err := summoners.GetAfter("region", 10, 0, false)
+	_, err := summoners.GetAfter("region", 10, 0, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -743,7 +1261,7 @@ func TestGetAfter_UsesCorrectScanIndexForward(t *testing.T) {
 
 func TestGetAfter_UsesCorrectKeyConditionExpression_WhenBackwardsFalse(t *testing.T) {
 	setup()
-	_, err := summoners.GetAfter("region", 10, 0, false)
+	_, err := summoners.GetAfter("region", 10, 0, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -761,7 +1279,7 @@ func TestGetAfter_UsesCorrectKeyConditionExpression_WhenBackwardsFalse(t *testin
 
 func TestGetAfter_UsesCorrectKeyConditionExpression_WhenBackwardsTrue(t *testing.T) {
 	setup()
-	_, err := summoners.GetAfter("region", 10, 0, true)
+	_, err := summoners.GetAfter("region", 10, 0, true, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -780,20 +1298,20 @@ func TestGetAfter_UsesCorrectKeyConditionExpression_WhenBackwardsTrue(t *testing
 func TestGetAfter_MapsSummonersFromQueryOutput(t *testing.T) {
 	setup()
 
-	result, err := summoners.GetAfter("region", 10, 0, false)
+	result, err := summoners.GetAfter("region", 10, 0, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
 
-	if len(result) != 1 {
-		t.Errorf("expected 1, got %d", len(result))
+	if len(result.Summoners) != 1 {
+		t.Errorf("expected 1, got %d", len(result.Summoners))
 	}
 }
 
 func TestGetByNameLength_ReturnsErrorIfRegionValidationFails(t *testing.T) {
 	setup()
 	summoners.regions.(*RegionsServiceMock).IsInvalid = true
-	_, err := summoners.GetByNameLength("invalid", 10, 12, 123, false)
+	_, err := summoners.GetByNameLength("invalid", 10, 12, 123, false, ListingFilter{})
 	if err == nil {
 		t.Errorf("expected error, got nil")
 	}
@@ -802,7 +1320,7 @@ func TestGetByNameLength_ReturnsErrorIfRegionValidationFails(t *testing.T) {
 func TestGetByNameLength_ReturnsErrorIfDynamoDBQueryFails(t *testing.T) {
 	setup()
 	summoners.dynamodb.(*DynamoDBServiceMock).ShouldReturnError = true
-	_, err := summoners.GetByNameLength("region", 10, 12, 123, false)
+	_, err := summoners.GetByNameLength("region", 10, 12, 123, false, ListingFilter{})
 	if err == nil {
 		t.Errorf("expected error, got nil")
 	}
@@ -810,7 +1328,7 @@ func TestGetByNameLength_ReturnsErrorIfDynamoDBQueryFails(t *testing.T) {
 
 func TestGetByNameLength_UsesCorrectTableName(t *testing.T) {
 	setup()
-	_, err := summoners.GetByNameLength("region", 10, 12, 123, false)
+	_, err := summoners.GetByNameLength("region", 10, 12, 123, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -828,7 +1346,7 @@ func TestGetByNameLength_UsesCorrectTableName(t *testing.T) {
 
 func TestGetByNameLength_UsesCorrectLimit(t *testing.T) {
 	setup()
-	_, err := summoners.GetByNameLength("region", 10, 12, 123, false)
+	_, err := summoners.GetByNameLength("region", 10, 12, 123, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -846,7 +1364,7 @@ func TestGetByNameLength_UsesCorrectLimit(t *testing.T) {
 
 func TestGetByNameLength_UsesCorrectKeyConditionExpression_WhenBackwardsTrue(t *testing.T) {
 	setup()
-	_, err := summoners.GetByNameLength("region", 10, 12, 123, true)
+	_, err := summoners.GetByNameLength("region", 10, 12, 123, true, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -864,7 +1382,7 @@ func TestGetByNameLength_UsesCorrectKeyConditionExpression_WhenBackwardsTrue(t *
 
 func TestGetByNameLength_UsesCorrectKeyConditionExpression_WhenBackwardsFalse(t *testing.T) {
 	setup()
-	_, err := summoners.GetByNameLength("region", 10, 12, 123, false)
+	_, err := summoners.GetByNameLength("region", 10, 12, 123, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -882,7 +1400,7 @@ func TestGetByNameLength_UsesCorrectKeyConditionExpression_WhenBackwardsFalse(t
 
 func TestGetByNameLength_UsesCorrectExpressionAttributeValues(t *testing.T) {
 	setup()
-	_, err := summoners.GetByNameLength("region", 10, 12, 123, false)
+	_, err := summoners.GetByNameLength("region", 10, 12, 123, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -904,7 +1422,7 @@ func TestGetByNameLength_UsesCorrectExpressionAttributeValues(t *testing.T) {
 
 func TestGetByNameLength_UsesCorrectIndexName(t *testing.T) {
 	setup()
-	_, err := summoners.GetByNameLength("region", 10, 12, 123, false)
+	_, err := summoners.GetByNameLength("region", 10, 12, 123, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -922,7 +1440,7 @@ func TestGetByNameLength_UsesCorrectIndexName(t *testing.T) {
 
 func TestGetByNameLength_UsesCorrectScanIndexForward(t *testing.T) {
 	setup()
-	_, err := summoners.GetByNameLength("region", 10, 12, 123, false)
+	_, err := summoners.GetByNameLength("region", 10, 12, 123, false, ListingFilter{})
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
@@ -936,3 +1454,407 @@ func TestGetByNameLength_UsesCorrectScanIndexForward(t *testing.T) {
 		t.Errorf("expected %t, got %t", true, actualScanIndexForward)
 	}
 }
+
+func TestGet_UsesNormalizedKey(t *testing.T) {
+	setup()
+
+	summoner, err := summoners.Get("NA", "Te st")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	key := summoners.dynamodb.(*DynamoDBServiceMock).GetItemCalls[0].Key["n"].(*types.AttributeValueMemberS).Value
+	if key != "NA#TEST" {
+		t.Errorf("expected NA#TEST, got %s", key)
+	}
+
+	if summoner.Name != "Test" {
+		t.Errorf("expected Test, got %s", summoner.Name)
+	}
+}
+
+func TestGet_ReturnsNotFound(t *testing.T) {
+	setup()
+	summoners.dynamodb.(*DynamoDBServiceMock).ShouldReturnNoItem = true
+
+	_, err := summoners.Get("NA", "test")
+	if err == nil || err.Error() != "summoner not found" {
+		t.Errorf("expected summoner not found, got %v", err)
+	}
+}
+
+func TestCount_QueriesRegionIndexForCount(t *testing.T) {
+	setup()
+
+	count, err := summoners.Count("NA", 1, 2)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if count != 1 {
+		t.Errorf("expected 1, got %d", count)
+	}
+
+	input := summoners.dynamodb.(*DynamoDBServiceMock).QueryCalls[0].Input
+	if input.Select != types.SelectCount || *input.IndexName != "region-availability-date-index" {
+		t.Errorf("expected a count query on the region index, got %v", input)
+	}
+}
+
+func TestCount_ReturnsErrorIfRegionValidationFails(t *testing.T) {
+	setup()
+	summoners.regions.(*RegionsServiceMock).IsInvalid = true
+
+	if _, err := summoners.Count("NA", 1, 2); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func testListingItem(name string) map[string]types.AttributeValue {
+	item := newTestSummonerItem()
+	item["n"] = &types.AttributeValueMemberS{Value: "NA#" + strings.ToUpper(name)}
+	item["dn"] = &types.AttributeValueMemberS{Value: name}
+	return item
+}
+
+func TestGetAfter_RefillsPageWhenSummonersAreFiltered(t *testing.T) {
+	setup()
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	mock.QueryOutputs = []*dynamodb.QueryOutput{
+		{
+			Items:            []map[string]types.AttributeValue{testListingItem("blocked"), testListingItem("first")},
+			LastEvaluatedKey: map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: "NA#FIRST"}},
+		},
+		{
+			Items:            []map[string]types.AttributeValue{testListingItem("second")},
+			LastEvaluatedKey: map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: "NA#SECOND"}},
+		},
+	}
+	summoners.FilterListings(func(summoner *SummonerDTO) bool {
+		return summoner.Name != "blocked"
+	})
+
+	page, err := summoners.GetAfter("NA", 2, 0, false, ListingFilter{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(page.Summoners) != 2 || page.Summoners[1].Name != "second" || page.Filtered != 1 {
+		t.Fatalf("expected a refilled page of 2, got %+v", page)
+	}
+
+	if len(mock.QueryCalls) != 2 {
+		t.Fatalf("expected 2 queries, got %d", len(mock.QueryCalls))
+	}
+
+	if start := mock.QueryCalls[1].Input.ExclusiveStartKey["n"].(*types.AttributeValueMemberS).Value; start != "NA#FIRST" {
+		t.Errorf("expected second query to continue from NA#FIRST, got %s", start)
+	}
+
+	if *mock.QueryCalls[1].Input.Limit != 1 {
+		t.Errorf("expected second query to read only the missing item, got %d", *mock.QueryCalls[1].Input.Limit)
+	}
+}
+
+func TestGetByNameLength_StopsWhenIndexIsExhausted(t *testing.T) {
+	setup()
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	mock.QueryOutputs = []*dynamodb.QueryOutput{
+		{Items: []map[string]types.AttributeValue{testListingItem("blocked")}},
+	}
+	summoners.FilterListings(func(*SummonerDTO) bool {
+		return false
+	})
+
+	page, err := summoners.GetByNameLength("NA", 10, 7, 0, false, ListingFilter{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(page.Summoners) != 0 || len(mock.QueryCalls) != 1 {
+		t.Errorf("expected an empty page after one query, got %d summoners", len(page.Summoners))
+	}
+}
+
+func TestGetAfter_FiltersByMinScore(t *testing.T) {
+	setup()
+
+	_, err := summoners.GetAfter("NA", 10, 0, false, ListingFilter{MinScore: 60})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	input := summoners.dynamodb.(*DynamoDBServiceMock).QueryCalls[0].Input
+	if *input.FilterExpression != "attribute_not_exists(h) and q >= :minScore" || input.ExpressionAttributeValues[":minScore"].(*types.AttributeValueMemberN).Value != "60" {
+		t.Errorf("expected low scored summoners to be filtered, got %s %v", *input.FilterExpression, input.ExpressionAttributeValues)
+	}
+}
+
+func scoredListingItem(name string, score string, availabilityDate string) map[string]types.AttributeValue {
+	item := testListingItem(name)
+	item["q"] = &types.AttributeValueMemberN{Value: score}
+	item["ad"] = &types.AttributeValueMemberN{Value: availabilityDate}
+	return item
+}
+
+func TestGetByScore_RanksTheWholeWindowByScore(t *testing.T) {
+	setup()
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	mock.QueryOutputs = []*dynamodb.QueryOutput{
+		{
+			Items:            []map[string]types.AttributeValue{scoredListingItem("low", "20", "1"), scoredListingItem("later", "90", "5")},
+			LastEvaluatedKey: map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: "NA#LATER"}},
+			ScannedCount:     2,
+		},
+		{
+			Items:        []map[string]types.AttributeValue{scoredListingItem("sooner", "90", "3"), scoredListingItem("mid", "50", "4")},
+			ScannedCount: 2,
+		},
+	}
+
+	page, err := summoners.GetByScore("NA", 3, 0, 0, 1, 10, ListingFilter{MinScore: 10})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	var names []string
+	for _, summoner := range page.Summoners {
+		names = append(names, summoner.Name)
+	}
+
+	if fmt.Sprint(names) != "[sooner later mid]" || page.Truncated {
+		t.Errorf("expected the best scored summoners, got %v, truncated %t", names, page.Truncated)
+	}
+
+	input := mock.QueryCalls[1].Input
+	if *input.KeyConditionExpression != "r = :region and ad between :t1 and :t2" || *input.FilterExpression != "attribute_not_exists(h) and q >= :minScore" {
+		t.Errorf("expected the region window to be queried, got %s, %s", *input.KeyConditionExpression, *input.FilterExpression)
+	}
+
+	if input.ExclusiveStartKey["n"].(*types.AttributeValueMemberS).Value != "NA#LATER" {
+		t.Errorf("expected the window to be read to its end")
+	}
+}
+
+func TestGetByScore_QueriesNameLengthIndex(t *testing.T) {
+	setup()
+
+	_, err := summoners.GetByScore("NA", 3, 5, 5, 1, 10, ListingFilter{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	input := summoners.dynamodb.(*DynamoDBServiceMock).QueryCalls[0].Input
+	if *input.IndexName != "name-length-availability-date-index" || input.ExpressionAttributeValues[":nameLength"].(*types.AttributeValueMemberS).Value != "NA#5" {
+		t.Errorf("expected the name length index to be queried, got %s %v", *input.IndexName, input.ExpressionAttributeValues)
+	}
+}
+
+func TestGetByScore_StopsReadingLargeWindows(t *testing.T) {
+	setup()
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	for i := 0; i < maxScoredItems/1000+1; i++ {
+		mock.QueryOutputs = append(mock.QueryOutputs, &dynamodb.QueryOutput{
+			Items:            []map[string]types.AttributeValue{scoredListingItem(fmt.Sprintf("name%d", i), "50", "2")},
+			LastEvaluatedKey: map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: "NA#NEXT"}},
+			ScannedCount:     1000,
+		})
+	}
+
+	page, err := summoners.GetByScore("NA", 35, 0, 0, 1, 10, ListingFilter{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !page.Truncated || len(mock.QueryCalls) != maxScoredItems/1000 {
+		t.Errorf("expected the read to stop after %d items, got %d queries, truncated %t", maxScoredItems, len(mock.QueryCalls), page.Truncated)
+	}
+}
+
+func TestGetByScore_ReturnsErrorIfRegionValidationFails(t *testing.T) {
+	setup()
+	summoners.regions.(*RegionsServiceMock).IsInvalid = true
+
+	if _, err := summoners.GetByScore("NA", 3, 0, 0, 1, 10, ListingFilter{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestGetAfter_FiltersByLevelAndRevisionDate(t *testing.T) {
+	setup()
+
+	filter := ListingFilter{MinLevel: 1, MaxLevel: 10, RevisionBefore: 1640995200000, RevisionAfter: 5}
+	if _, err := summoners.GetAfter("NA", 10, 0, false, filter); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	input := summoners.dynamodb.(*DynamoDBServiceMock).QueryCalls[0].Input
+	expected := "attribute_not_exists(h) and l >= :minLevel and l <= :maxLevel and rd < :revisionBefore and rd > :revisionAfter"
+	if *input.FilterExpression != expected {
+		t.Errorf("expected %s, got %s", expected, *input.FilterExpression)
+	}
+
+	if input.ExpressionAttributeValues[":maxLevel"].(*types.AttributeValueMemberN).Value != "10" || input.ExpressionAttributeValues[":revisionBefore"].(*types.AttributeValueMemberN).Value != "1640995200000" {
+		t.Errorf("expected the filter values, got %v", input.ExpressionAttributeValues)
+	}
+}
+
+func TestGetAfter_ContinuesFromLastItemReadWhenFiltersDropEverything(t *testing.T) {
+	setup()
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	for i := 0; i < maxListingQueries; i++ {
+		mock.QueryOutputs = append(mock.QueryOutputs, &dynamodb.QueryOutput{
+			LastEvaluatedKey: map[string]types.AttributeValue{
+				"n":  &types.AttributeValueMemberS{Value: "NA#DROPPED"},
+				"ad": &types.AttributeValueMemberN{Value: strconv.Itoa(100 + i)},
+			},
+		})
+	}
+
+	page, err := summoners.GetAfter("NA", 35, 0, false, ListingFilter{MaxLevel: 10})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(page.Summoners) != 0 || page.Next != 109 {
+		t.Errorf("expected an empty page continuing from 109, got %d summoners and %d", len(page.Summoners), page.Next)
+	}
+}
+
+func TestGetAfter_HasNoNextPageAtEndOfIndex(t *testing.T) {
+	setup()
+
+	page, err := summoners.GetAfter("NA", 35, 0, false, ListingFilter{})
+	if err != nil || page.Next != 0 {
+		t.Errorf("expected no next page, got %d, %v", page.Next, err)
+	}
+}
+
+// NameLengthIndexMock serves the pages of each name length index partition in order, and is safe for
+// the parallel queries of GetByNameLengths.
+type NameLengthIndexMock struct {
+	DynamoDBServiceMock
+	mu      sync.Mutex
+	Outputs map[string][]*dynamodb.QueryOutput
+	FailKey string
+	Queried []string
+}
+
+func (m *NameLengthIndexMock) Query(_ context.Context, input *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := input.ExpressionAttributeValues[":nameLength"].(*types.AttributeValueMemberS).Value
+	m.Queried = append(m.Queried, key)
+	if key == m.FailKey {
+		return nil, fmt.Errorf("error")
+	}
+
+	outputs := m.Outputs[key]
+	if len(outputs) == 0 {
+		return &dynamodb.QueryOutput{}, nil
+	}
+
+	m.Outputs[key] = outputs[1:]
+	return outputs[0], nil
+}
+
+func datedListingItem(name string, availabilityDate string) map[string]types.AttributeValue {
+	item := testListingItem(name)
+	item["ad"] = &types.AttributeValueMemberN{Value: availabilityDate}
+	return item
+}
+
+func TestGetByNameLengths_MergesLengthsByAvailabilityDate(t *testing.T) {
+	setup()
+	mock := &NameLengthIndexMock{Outputs: map[string][]*dynamodb.QueryOutput{
+		"NA#3": {{Items: []map[string]types.AttributeValue{datedListingItem("abc", "10"), datedListingItem("xyz", "40")}}},
+		"NA#4": {{Items: []map[string]types.AttributeValue{datedListingItem("abcd", "20")}}},
+		"NA#5": {{Items: []map[string]types.AttributeValue{datedListingItem("abcde", "5"), datedListingItem("vwxyz", "30")}}},
+	}}
+	summoners.dynamodb = mock
+
+	page, err := summoners.GetByNameLengths("NA", 4, 3, 5, 0, false, ListingFilter{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	var names []string
+	for _, summoner := range page.Summoners {
+		names = append(names, summoner.Name)
+	}
+
+	if fmt.Sprint(names) != "[abcde abc abcd vwxyz]" || page.Next != 30 {
+		t.Errorf("expected the first 4 names by date continuing from 30, got %v and %d", names, page.Next)
+	}
+
+	if len(mock.Queried) != 3 {
+		t.Errorf("expected a query per length, got %v", mock.Queried)
+	}
+}
+
+func TestGetByNameLengths_StopsAtLengthThatWasNotReadToTheEnd(t *testing.T) {
+	setup()
+	dropped := &dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{datedListingItem("blocked", "15")},
+		LastEvaluatedKey: map[string]types.AttributeValue{
+			"n":  &types.AttributeValueMemberS{Value: "NA#BLOCKED"},
+			"ad": &types.AttributeValueMemberN{Value: "15"},
+		},
+	}
+	outputs := make([]*dynamodb.QueryOutput, maxListingQueries)
+	for i := range outputs {
+		outputs[i] = dropped
+	}
+
+	summoners.dynamodb = &NameLengthIndexMock{Outputs: map[string][]*dynamodb.QueryOutput{
+		"NA#3": {{Items: []map[string]types.AttributeValue{datedListingItem("abc", "10"), datedListingItem("xyz", "40")}}},
+		"NA#7": outputs,
+	}}
+	summoners.FilterListings(func(summoner *SummonerDTO) bool {
+		return summoner.Name != "blocked"
+	})
+
+	page, err := summoners.GetByNameLengths("NA", 35, 3, 7, 50, true, ListingFilter{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(page.Summoners) != 1 || page.Summoners[0].Name != "xyz" || page.Next != 15 {
+		t.Errorf("expected the names before 15 and to continue from it, got %+v", page)
+	}
+}
+
+func TestGetByNameLengths_ReturnsErrorIfAnyQueryFails(t *testing.T) {
+	setup()
+	summoners.dynamodb = &NameLengthIndexMock{FailKey: "NA#4"}
+
+	if _, err := summoners.GetByNameLengths("NA", 35, 3, 5, 0, false, ListingFilter{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if _, err := summoners.GetByNameLengths("NA", 35, 5, 4, 0, false, ListingFilter{}); err == nil {
+		t.Errorf("expected an inverted range to be rejected")
+	}
+}
+
+func TestGetByScore_KeepsNameLengthRange(t *testing.T) {
+	setup()
+	mock := summoners.dynamodb.(*DynamoDBServiceMock)
+	mock.QueryOutputs = []*dynamodb.QueryOutput{
+		{Items: []map[string]types.AttributeValue{scoredListingItem("ab", "90", "2"), scoredListingItem("abcd", "50", "3"), scoredListingItem("abcdefgh", "70", "4")}},
+	}
+
+	page, err := summoners.GetByScore("NA", 35, 3, 5, 1, 10, ListingFilter{})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(page.Summoners) != 1 || page.Summoners[0].Name != "abcd" {
+		t.Errorf("expected only names of 3 to 5 characters, got %+v", page.Summoners)
+	}
+
+	if *mock.QueryCalls[0].Input.IndexName != "region-availability-date-index" {
+		t.Errorf("expected a length range to be read from the region index")
+	}
+}
@@ -0,0 +1,293 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+)
+
+const watchCursorPrefix = "watch#cursor#"
+
+// watchPageSize is how many upcoming summoners are checked against the watchlist per query.
+const watchPageSize = 1000
+
+type watchSummonersService interface {
+	GetBetweenDate(region string, limit int32, t1 int64, t2 int64) (*SummonersPage, error)
+}
+
+// watchCursorItem records the availability date up to which a region's approaching alerts have been sent.
+type watchCursorItem struct {
+	Key       string `dynamodbav:"n"`
+	Through   int64  `dynamodbav:"th"`
+	UpdatedAt int64  `dynamodbav:"ua"`
+}
+
+func (i *watchCursorItem) updatedAt() *int64 {
+	return &i.UpdatedAt
+}
+
+type WatchAlertResult struct {
+	Region  string `json:"region"`
+	Checked int    `json:"checked"`
+	Watched int    `json:"watched"`
+	Sent    int    `json:"sent"`
+	Dropped int    `json:"dropped"`
+}
+
+// WatchAlerter emails watchers when a name's availability date approaches and again once it is freed, or
+// sends them a push notification if they subscribed from a browser.
+type WatchAlerter struct {
+	watchlist *Watchlist
+	dynamodb  watchlistDynamoDbService
+	summoners watchSummonersService
+	alertChannels
+	lead           time.Duration
+	unsubscribeUrl string
+	tableName      string
+	pageSize       int32
+	now            func() time.Time
+}
+
+// NewWatchAlerter alerts watchers lead ahead of a name's availability date. Alerts link to unsubscribeUrl,
+// when set, with the subscription's region, name, contact and token as query parameters. mailer may be
+// nil when only push notifications are sent.
+func NewWatchAlerter(client watchlistDynamoDbService, tableName string, summoners watchSummonersService, mailer mailerService, lead time.Duration, unsubscribeUrl string) *WatchAlerter {
+	return &WatchAlerter{
+		watchlist:      NewWatchlist(client, tableName),
+		dynamodb:       client,
+		summoners:      summoners,
+		alertChannels:  alertChannels{mailer: mailer},
+		lead:           lead,
+		unsubscribeUrl: unsubscribeUrl,
+		tableName:      tableName,
+		pageSize:       watchPageSize,
+		now:            time.Now,
+	}
+}
+
+// NewWatchAlerterFromEnv reads the lead time from WATCH_ALERT_LEAD, defaulting to 24 hours, and the
+// unsubscribe link from WATCH_UNSUBSCRIBE_URL, and configures its channels with alertChannelsFromEnv.
+func NewWatchAlerterFromEnv(ctx context.Context, client watchlistDynamoDbService, tableName string, summoners watchSummonersService, secrets SecretsSource) (*WatchAlerter, error) {
+	lead := 24 * time.Hour
+	if value := os.Getenv("WATCH_ALERT_LEAD"); value != "" {
+		var err error
+		lead, err = time.ParseDuration(value)
+		if err != nil || lead <= 0 {
+			return nil, fmt.Errorf("invalid WATCH_ALERT_LEAD '%s'", value)
+		}
+	}
+
+	channels, err := alertChannelsFromEnv(ctx, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	alerter := NewWatchAlerter(client, tableName, summoners, nil, lead, os.Getenv("WATCH_UNSUBSCRIBE_URL"))
+	alerter.alertChannels = channels
+	return alerter, nil
+}
+
+// AlertApproaching alerts the watchers of every name in a region whose availability date came within the
+// lead time since the last run. If sending fails, the run stops without moving its cursor, and watchers
+// already alerted are not alerted twice when it is retried.
+func (a *WatchAlerter) AlertApproaching(ctx context.Context, region string) (*WatchAlertResult, error) {
+	cursor, err := a.loadCursor(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := cursor.UpdatedAt
+	result := &WatchAlertResult{Region: region}
+
+	now := a.now()
+	from, to := cursor.Through+1, now.Add(a.lead).UnixMilli()
+	if from <= now.UnixMilli() {
+		from = now.UnixMilli()
+	}
+
+	for from <= to {
+		page, err := a.summoners.GetBetweenDate(region, a.pageSize, from, to)
+		if err != nil {
+			return result, err
+		}
+
+		if err = a.alertPage(ctx, region, page.Summoners, result); err != nil {
+			return result, err
+		}
+
+		if len(page.Summoners)+len(page.Skipped)+page.Filtered < int(a.pageSize) || len(page.Summoners) == 0 {
+			break
+		}
+
+		// Summoners sharing the last availability date may continue on the next page, so it starts from
+		// that date again and those already alerted are recognised by NotifiedFor. A page sharing a single
+		// date moves past it instead, which can only skip names when a full page becomes available at once.
+		from = page.Summoners[len(page.Summoners)-1].AvailabilityDate
+		if from == page.Summoners[0].AvailabilityDate {
+			from++
+		}
+	}
+
+	cursor.Through = to
+	return result, putSingleItem(ctx, a.dynamodb, a.tableName, cursor, previous, a.now())
+}
+
+func (a *WatchAlerter) alertPage(ctx context.Context, region string, summoners []*SummonerDTO, result *WatchAlertResult) error {
+	result.Checked += len(summoners)
+
+	availability := make(map[string]*SummonerDTO)
+	names := make([]string, 0, len(summoners))
+	for _, summoner := range summoners {
+		availability[WatchKey(region, summoner.Name)] = summoner
+		names = append(names, summoner.Name)
+	}
+
+	watches, err := a.watchlist.GetMany(ctx, region, names)
+	if err != nil {
+		return err
+	}
+
+	for _, watch := range watches {
+		summoner, ok := availability[watch.Key]
+		if !ok {
+			continue
+		}
+
+		result.Watched++
+		subject := fmt.Sprintf("%s (%s) is becoming available", summoner.Name, region)
+		date := time.UnixMilli(summoner.AvailabilityDate).UTC().Format("Monday, January 2 at 15:04 MST")
+
+		sendErr := a.notify(ctx, watch, subject, false, result, func(subscription *WatchSubscription) (string, bool) {
+			if subscription.NotifiedFor == summoner.AvailabilityDate {
+				return "", false
+			}
+
+			subscription.NotifiedFor = summoner.AvailabilityDate
+			return fmt.Sprintf("The summoner name %s in %s is expected to become available on %s.\n\n"+
+				"We will email you again once the name has been freed.\n%s", summoner.Name, region, date, a.unsubscribeText(watch, subscription)), true
+		})
+		if sendErr != nil {
+			return sendErr
+		}
+	}
+
+	return nil
+}
+
+// AlertFreed alerts every watcher of a name that Riot no longer knows it, then removes the watch.
+func (a *WatchAlerter) AlertFreed(ctx context.Context, region string, name string) (int, error) {
+	watch, err := a.watchlist.Get(ctx, region, name)
+	if err != nil || watch == nil {
+		return 0, err
+	}
+
+	result := &WatchAlertResult{Region: region}
+	subject := fmt.Sprintf("%s (%s) is now available", watch.Name, region)
+
+	err = a.notify(ctx, watch, subject, true, result, func(_ *WatchSubscription) (string, bool) {
+		return fmt.Sprintf("The summoner name %s in %s has been freed. Claim it in the League of Legends client "+
+			"before someone else does.\n\nThis was your last alert for this name.\n", watch.Name, region), true
+	})
+
+	return result.Sent, err
+}
+
+// notify sends each of a watch's subscriptions the body compose returns, skipping those it declines,
+// those still pending confirmation and those whose channel is not configured. Subscriptions whose address
+// the mail server permanently rejects, or whose push subscription expired or is gone, are dropped, as are
+// all notified subscriptions when remove is set. The watch is saved even when sending fails part way.
+func (a *WatchAlerter) notify(ctx context.Context, watch *Watch, subject string, remove bool, result *WatchAlertResult, compose func(subscription *WatchSubscription) (string, bool)) error {
+	ids := make([]string, 0, len(watch.Subscriptions))
+	for id := range watch.Subscriptions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	changed := false
+	var sendErr error
+	for _, id := range ids {
+		subscription := watch.Subscriptions[id]
+		notifiedFor := subscription.NotifiedFor
+
+		if subscription.Push != nil && subscription.Push.Expired(a.now()) {
+			delete(watch.Subscriptions, id)
+			result.Dropped++
+			changed = true
+			continue
+		}
+
+		if subscription.Pending || !a.handles(subscription.Push) {
+			continue
+		}
+
+		body, ok := compose(subscription)
+		if !ok {
+			continue
+		}
+
+		err := a.send(ctx, subscription.Contact, subscription.Push, subject, body, alertPushPayload{
+			Region:         watch.Region,
+			Name:           watch.Name,
+			UnsubscribeUrl: a.unsubscribeLink(watch, subscription),
+		})
+		switch {
+		case isPermanentAlertError(err):
+			delete(watch.Subscriptions, id)
+			result.Dropped++
+		case err != nil:
+			subscription.NotifiedFor = notifiedFor
+			sendErr = fmt.Errorf("could not alert watcher of '%s': %v", watch.Name, err)
+		case remove:
+			delete(watch.Subscriptions, id)
+			result.Sent++
+		default:
+			result.Sent++
+		}
+
+		changed = true
+		if sendErr != nil {
+			break
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := a.watchlist.Save(ctx, watch); err != nil {
+		return err
+	}
+
+	return sendErr
+}
+
+func (a *WatchAlerter) unsubscribeText(watch *Watch, subscription *WatchSubscription) string {
+	if a.unsubscribeUrl == "" {
+		return ""
+	}
+
+	return "\nTo stop alerts for this name, visit " + a.unsubscribeLink(watch, subscription) + "\n"
+}
+
+func (a *WatchAlerter) unsubscribeLink(watch *Watch, subscription *WatchSubscription) string {
+	if a.unsubscribeUrl == "" {
+		return ""
+	}
+
+	query := url.Values{
+		"region":  {watch.Region},
+		"name":    {watch.Name},
+		"contact": {subscription.Contact},
+		"token":   {subscription.Token},
+	}
+
+	return a.unsubscribeUrl + "?" + query.Encode()
+}
+
+func (a *WatchAlerter) loadCursor(ctx context.Context, region string) (*watchCursorItem, error) {
+	cursor := &watchCursorItem{Key: watchCursorPrefix + region}
+	return cursor, getSingleItem(ctx, a.dynamodb, a.tableName, cursor.Key, cursor)
+}
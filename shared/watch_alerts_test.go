@@ -0,0 +1,311 @@
+package shared
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MailerMock struct {
+	FailFor   string
+	RejectFor string
+	Sent      []string
+	Bodies    []string
+}
+
+func (m *MailerMock) Send(_ context.Context, to string, subject string, body string) error {
+	if to == m.FailFor {
+		return fmt.Errorf("connection refused")
+	}
+
+	if to == m.RejectFor {
+		return &textproto.Error{Code: 550, Msg: "No such user"}
+	}
+
+	m.Sent = append(m.Sent, to+": "+subject)
+	m.Bodies = append(m.Bodies, body)
+	return nil
+}
+
+// UpcomingSummonersMock serves summoners sorted by availability date, like the availability date index.
+type UpcomingSummonersMock struct {
+	Summoners []*SummonerDTO
+	Queries   int
+}
+
+func (u *UpcomingSummonersMock) GetBetweenDate(region string, limit int32, t1 int64, t2 int64) (*SummonersPage, error) {
+	u.Queries++
+
+	page := &SummonersPage{}
+	for _, summoner := range u.Summoners {
+		if summoner.Region == region && summoner.AvailabilityDate >= t1 && summoner.AvailabilityDate <= t2 && int32(len(page.Summoners)) < limit {
+			page.Summoners = append(page.Summoners, summoner)
+		}
+	}
+
+	return page, nil
+}
+
+func (u *UpcomingSummonersMock) GetBetweenDateByNameLength(region string, limit int32, nameLength int, t1 int64, t2 int64) (*SummonersPage, error) {
+	u.Queries++
+
+	page := &SummonersPage{}
+	for _, summoner := range u.Summoners {
+		if summoner.Region == region && NameLength(summoner.Name) == nameLength && summoner.AvailabilityDate >= t1 && summoner.AvailabilityDate <= t2 && int32(len(page.Summoners)) < limit {
+			page.Summoners = append(page.Summoners, summoner)
+		}
+	}
+
+	return page, nil
+}
+
+var watchTestNow = time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)
+
+func newTestWatchAlerter(upcoming *UpcomingSummonersMock) (*WatchAlerter, *MailerMock, *fakeClock) {
+	mailer := &MailerMock{}
+	clock := &fakeClock{current: watchTestNow}
+	alerter := NewWatchAlerter(newWatchlistDynamoDBServiceMock(), "test-table", upcoming, mailer, 24*time.Hour, "https://names.lol/unsubscribe")
+	alerter.now = clock.Now
+	confirmations, _, _ := newTestConfirmations()
+	alerter.watchlist.ConfirmWith(confirmations, "https://names.lol/confirm")
+	alerter.watchlist.now = func() time.Time {
+		clock.Advance(time.Millisecond)
+		return clock.Now()
+	}
+
+	return alerter, mailer, clock
+}
+
+func upcoming(name string, in time.Duration) *SummonerDTO {
+	return &SummonerDTO{Name: name, Region: "NA", AvailabilityDate: watchTestNow.Add(in).UnixMilli()}
+}
+
+func TestWatchAlerter_AlertsApproachingNamesOnce(t *testing.T) {
+	summoners := &UpcomingSummonersMock{Summoners: []*SummonerDTO{upcoming("Soon", time.Hour), upcoming("Later", 48*time.Hour)}}
+	alerter, mailer, clock := newTestWatchAlerter(summoners)
+	_, _ = subscribeConfirmed(alerter.watchlist, "NA", "Soon", "a@example.com")
+	_, _ = subscribeConfirmed(alerter.watchlist, "NA", "Later", "b@example.com")
+
+	result, err := alerter.AlertApproaching(context.TODO(), "NA")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(mailer.Sent) != 1 || mailer.Sent[0] != "a@example.com: Soon (NA) is becoming available" {
+		t.Errorf("expected only the name within the lead time to alert, got %v", mailer.Sent)
+	}
+
+	if !strings.Contains(mailer.Bodies[0], "https://names.lol/unsubscribe?contact=a%40example.com&name=Soon&region=NA&token=") {
+		t.Errorf("expected an unsubscribe link, got %q", mailer.Bodies[0])
+	}
+
+	if result.Checked != 1 || result.Watched != 1 || result.Sent != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	clock.Advance(25 * time.Hour)
+	if _, err = alerter.AlertApproaching(context.TODO(), "NA"); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(mailer.Sent) != 2 || mailer.Sent[1] != "b@example.com: Later (NA) is becoming available" {
+		t.Errorf("expected the second run to alert only the newly approaching name, got %v", mailer.Sent)
+	}
+}
+
+func TestWatchAlerter_SkipsPendingSubscriptions(t *testing.T) {
+	alerter, mailer, _ := newTestWatchAlerter(&UpcomingSummonersMock{Summoners: []*SummonerDTO{upcoming("Soon", time.Hour)}})
+	_, _ = subscribeConfirmed(alerter.watchlist, "NA", "Soon", "a@example.com")
+	_, _ = alerter.watchlist.Subscribe(context.TODO(), "NA", "Soon", "b@example.com")
+
+	if _, err := alerter.AlertApproaching(context.TODO(), "NA"); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(mailer.Sent) != 1 || !strings.HasPrefix(mailer.Sent[0], "a@example.com") {
+		t.Errorf("expected only the confirmed subscription to be alerted, got %v", mailer.Sent)
+	}
+}
+
+func TestWatchAlerter_RetriesOnlyUnsentAfterFailure(t *testing.T) {
+	alerter, mailer, _ := newTestWatchAlerter(&UpcomingSummonersMock{Summoners: []*SummonerDTO{upcoming("Soon", time.Hour)}})
+	_, _ = subscribeConfirmed(alerter.watchlist, "NA", "Soon", "a@example.com")
+	_, _ = subscribeConfirmed(alerter.watchlist, "NA", "Soon", "b@example.com")
+	mailer.FailFor = "b@example.com"
+
+	if _, err := alerter.AlertApproaching(context.TODO(), "NA"); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	mailer.FailFor = ""
+	if _, err := alerter.AlertApproaching(context.TODO(), "NA"); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(mailer.Sent) != 2 || mailer.Sent[0] == mailer.Sent[1] {
+		t.Errorf("expected each watcher to be alerted once, got %v", mailer.Sent)
+	}
+}
+
+func TestWatchAlerter_PagesThroughUpcomingSummoners(t *testing.T) {
+	summoners := &UpcomingSummonersMock{Summoners: []*SummonerDTO{
+		upcoming("a", time.Hour), upcoming("b", time.Hour), upcoming("c", 2*time.Hour), upcoming("d", 3*time.Hour),
+	}}
+	alerter, mailer, _ := newTestWatchAlerter(summoners)
+	alerter.pageSize = 2
+	_, _ = subscribeConfirmed(alerter.watchlist, "NA", "c", "c@example.com")
+	_, _ = subscribeConfirmed(alerter.watchlist, "NA", "d", "d@example.com")
+
+	if _, err := alerter.AlertApproaching(context.TODO(), "NA"); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	// a and b fill the first page with one date, so the second page starts after it. The second page ends
+	// on d's date, so the third starts from it again.
+	if len(mailer.Sent) != 2 || summoners.Queries != 3 {
+		t.Errorf("expected c and d to be alerted over 3 pages, got %v in %d queries", mailer.Sent, summoners.Queries)
+	}
+}
+
+func TestWatchAlerter_AlertFreedRemovesWatch(t *testing.T) {
+	alerter, mailer, _ := newTestWatchAlerter(&UpcomingSummonersMock{})
+	_, _ = subscribeConfirmed(alerter.watchlist, "NA", "Freed", "a@example.com")
+	_, _ = subscribeConfirmed(alerter.watchlist, "NA", "Freed", "gone@example.com")
+	mailer.RejectFor = "gone@example.com"
+
+	sent, err := alerter.AlertFreed(context.TODO(), "NA", "freed")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if sent != 1 || mailer.Sent[0] != "a@example.com: Freed (NA) is now available" {
+		t.Errorf("expected one freed alert, got %d: %v", sent, mailer.Sent)
+	}
+
+	if watch, _ := alerter.watchlist.Get(context.TODO(), "NA", "Freed"); watch != nil {
+		t.Errorf("expected the watch to be removed, got %+v", watch)
+	}
+}
+
+func TestWatchAlerter_AlertFreedWithoutWatchers(t *testing.T) {
+	alerter, mailer, _ := newTestWatchAlerter(&UpcomingSummonersMock{})
+
+	sent, err := alerter.AlertFreed(context.TODO(), "NA", "nobody")
+	if err != nil || sent != 0 || len(mailer.Sent) != 0 {
+		t.Errorf("expected nothing to be sent, got %d, %v", sent, err)
+	}
+}
+
+type PusherMock struct {
+	GoneFor  string
+	Payloads map[string]string
+}
+
+func (p *PusherMock) Push(_ context.Context, subscription *PushSubscription, payload []byte) error {
+	if subscription.Endpoint == p.GoneFor {
+		return &PushGoneError{StatusCode: 410}
+	}
+
+	if p.Payloads == nil {
+		p.Payloads = make(map[string]string)
+	}
+
+	p.Payloads[subscription.Endpoint] = string(payload)
+	return nil
+}
+
+func testPushSubscription(endpoint string) *PushSubscription {
+	browserKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+	return &PushSubscription{Endpoint: endpoint, Keys: PushKeys{P256dh: encodeBase64Url(browserKey.PublicKey().Bytes()), Auth: encodeBase64Url(make([]byte, 16))}}
+}
+
+func TestWatchAlerter_PushesToBrowserSubscriptions(t *testing.T) {
+	summoners := &UpcomingSummonersMock{Summoners: []*SummonerDTO{upcoming("Soon", time.Hour)}}
+	alerter, mailer, _ := newTestWatchAlerter(summoners)
+	pusher := &PusherMock{}
+	alerter.UsePush(pusher)
+
+	_, _ = subscribeConfirmed(alerter.watchlist, "NA", "Soon", "a@example.com")
+	_, _ = alerter.watchlist.SubscribePush(context.TODO(), "NA", "Soon", testPushSubscription("https://push.example.com/browser"))
+
+	result, err := alerter.AlertApproaching(context.TODO(), "NA")
+	if err != nil || result.Sent != 2 || len(mailer.Sent) != 1 {
+		t.Fatalf("expected an email and a push, got %+v, %v", result, err)
+	}
+
+	var payload alertPushPayload
+	_ = json.Unmarshal([]byte(pusher.Payloads["https://push.example.com/browser"]), &payload)
+	if payload.Title != "Soon (NA) is becoming available" || !strings.HasPrefix(payload.Body, "The summoner name Soon in NA is expected") || strings.Contains(payload.Body, "\n") {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+
+	if !strings.HasPrefix(payload.UnsubscribeUrl, "https://names.lol/unsubscribe?") || !strings.Contains(payload.UnsubscribeUrl, "contact=https%3A%2F%2Fpush.example.com%2Fbrowser") {
+		t.Errorf("expected an unsubscribe link for the push subscription, got %s", payload.UnsubscribeUrl)
+	}
+}
+
+func TestWatchAlerter_DropsGoneAndExpiredPushSubscriptions(t *testing.T) {
+	alerter, _, _ := newTestWatchAlerter(&UpcomingSummonersMock{})
+	alerter.UsePush(&PusherMock{GoneFor: "https://push.example.com/gone"})
+
+	expired := testPushSubscription("https://push.example.com/expired")
+	expired.ExpirationTime = watchTestNow.Add(-time.Minute).UnixMilli()
+	_, _ = alerter.watchlist.SubscribePush(context.TODO(), "NA", "Freed", testPushSubscription("https://push.example.com/gone"))
+	_, _ = alerter.watchlist.SubscribePush(context.TODO(), "NA", "Freed", expired)
+	_, _ = alerter.watchlist.SubscribePush(context.TODO(), "NA", "Freed", testPushSubscription("https://push.example.com/ok"))
+
+	watch, _ := alerter.watchlist.Get(context.TODO(), "NA", "Freed")
+	result := &WatchAlertResult{}
+	err := alerter.notify(context.TODO(), watch, "subject", false, result, func(_ *WatchSubscription) (string, bool) {
+		return "body", true
+	})
+	if err != nil || result.Sent != 1 || result.Dropped != 2 {
+		t.Fatalf("expected 1 sent and 2 dropped, got %+v, %v", result, err)
+	}
+
+	if watch, _ = alerter.watchlist.Get(context.TODO(), "NA", "Freed"); len(watch.Subscriptions) != 1 {
+		t.Errorf("expected only the working subscription to remain, got %+v", watch.Subscriptions)
+	}
+}
+
+func TestWatchAlerter_SkipsPushSubscriptionsWithoutPusher(t *testing.T) {
+	alerter, mailer, _ := newTestWatchAlerter(&UpcomingSummonersMock{})
+	_, _ = alerter.watchlist.SubscribePush(context.TODO(), "NA", "Freed", testPushSubscription("https://push.example.com/browser"))
+	_, _ = subscribeConfirmed(alerter.watchlist, "NA", "Freed", "a@example.com")
+
+	sent, err := alerter.AlertFreed(context.TODO(), "NA", "Freed")
+	if err != nil || sent != 1 || len(mailer.Sent) != 1 {
+		t.Fatalf("expected only the email to be sent, got %d, %v", sent, err)
+	}
+
+	if watch, _ := alerter.watchlist.Get(context.TODO(), "NA", "Freed"); watch == nil || len(watch.Subscriptions) != 1 {
+		t.Errorf("expected the push subscription to be kept for when push is configured, got %+v", watch)
+	}
+}
+
+func TestNewWatchAlerterFromEnv_ConfiguresChannels(t *testing.T) {
+	t.Setenv("SMTP_ADDR", "")
+	t.Setenv("WEBPUSH_VAPID_PRIVATE_KEY", "")
+	if _, err := NewWatchAlerterFromEnv(context.TODO(), newWatchlistDynamoDBServiceMock(), "test-table", &UpcomingSummonersMock{}, StaticSecretsSource{}); err == nil {
+		t.Errorf("expected error without channels, got nil")
+	}
+
+	privateKey, _, _ := GenerateVAPIDKeys()
+	t.Setenv("WEBPUSH_VAPID_PRIVATE_KEY_NAME", "/vapid-private-key")
+	t.Setenv("WEBPUSH_SUBJECT", "mailto:alerts@names.lol")
+
+	alerter, err := NewWatchAlerterFromEnv(context.TODO(), newWatchlistDynamoDBServiceMock(), "test-table", &UpcomingSummonersMock{}, StaticSecretsSource{"/vapid-private-key": privateKey})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if alerter.mailer != nil || alerter.pusher == nil {
+		t.Errorf("expected only push to be configured, got mailer %v and pusher %v", alerter.mailer, alerter.pusher)
+	}
+}
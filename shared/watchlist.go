@@ -0,0 +1,309 @@
+package shared
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Watches are stored next to the summoner they watch, keyed by the summoner's name key.
+const watchPrefix = "watch#"
+
+// maxWatchSubscriptions bounds the size of a watch item.
+const maxWatchSubscriptions = 100
+
+// batchGetLimit is the most keys DynamoDB reads in one BatchGetItem request.
+const batchGetLimit = 100
+
+type watchlistDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+}
+
+// WatchSubscription is one contact waiting for a name. Token authorizes confirming and unsubscribing and is
+// only ever sent to the contact. Email subscriptions stay Pending, and are not alerted, until the contact
+// confirms them. NotifiedFor is the availability date the contact was last alerted about, so a changed
+// date alerts again. Contacts are email addresses, except for push subscriptions, whose contact is their
+// endpoint.
+type WatchSubscription struct {
+	Contact     string            `dynamodbav:"c" json:"contact"`
+	Push        *PushSubscription `dynamodbav:"p,omitempty" json:"-"`
+	Token       string            `dynamodbav:"t" json:"-"`
+	CreatedAt   int64             `dynamodbav:"ca" json:"createdAt"`
+	Pending     bool              `dynamodbav:"pd,omitempty" json:"pending,omitempty"`
+	NotifiedFor int64             `dynamodbav:"nf,omitempty" json:"notifiedFor,omitempty"`
+}
+
+type Watch struct {
+	Key           string                        `dynamodbav:"n" json:"-"`
+	Region        string                        `dynamodbav:"wr" json:"region"`
+	Name          string                        `dynamodbav:"wn" json:"name"`
+	Subscriptions map[string]*WatchSubscription `dynamodbav:"s" json:"subscriptions"`
+	UpdatedAt     int64                         `dynamodbav:"ua" json:"-"`
+}
+
+func (w *Watch) updatedAt() *int64 {
+	return &w.UpdatedAt
+}
+
+func WatchKey(region string, name string) string {
+	return watchPrefix + NameKey(region, name)
+}
+
+// subscriptionId identifies a contact within a watch, so subscribing twice keeps a single subscription.
+func subscriptionId(contact string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(contact))))
+	return hex.EncodeToString(sum[:8])
+}
+
+type Watchlist struct {
+	dynamodb      watchlistDynamoDbService
+	confirmations *Confirmations
+	tableName     string
+	confirmUrl    string
+	now           func() time.Time
+}
+
+func NewWatchlist(client watchlistDynamoDbService, tableName string) *Watchlist {
+	return &Watchlist{
+		dynamodb:  client,
+		tableName: tableName,
+		now:       time.Now,
+	}
+}
+
+// ConfirmWith rate limits subscribes and sends new email subscribers a link to confirmUrl, with the
+// subscription's region, name, contact and token as query parameters. Email subscriptions are only
+// accepted once it is set.
+func (w *Watchlist) ConfirmWith(confirmations *Confirmations, confirmUrl string) {
+	w.confirmations = confirmations
+	w.confirmUrl = confirmUrl
+}
+
+// Subscribe adds a pending contact to a name's watch and emails them a link to confirm it. Subscribing
+// again returns the existing subscription, and sends the link again while it is pending.
+func (w *Watchlist) Subscribe(ctx context.Context, region string, name string, contact string) (*WatchSubscription, error) {
+	if w.confirmations == nil {
+		return nil, errors.New("email subscriptions need confirmations, see ConfirmWith")
+	}
+
+	if err := w.confirmations.Allow(ctx, contact); err != nil {
+		return nil, err
+	}
+
+	subscription, err := w.subscribe(ctx, region, name, contact, nil)
+	if err != nil || !subscription.Pending {
+		return subscription, err
+	}
+
+	query := url.Values{"region": {region}, "name": {name}, "contact": {subscription.Contact}, "token": {subscription.Token}}
+	err = w.confirmations.Send(ctx, subscription.Contact, fmt.Sprintf("the summoner name %s in %s", name, region), w.confirmUrl, query)
+	return subscription, err
+}
+
+// SubscribePush adds a browser's push subscription to a name's watch. Subscribing again with renewed
+// keys replaces the stored keys. Push subscriptions need no confirmation, since only the browser knows
+// its endpoint, but they share the subscribe rate limit when one is set.
+func (w *Watchlist) SubscribePush(ctx context.Context, region string, name string, push *PushSubscription) (*WatchSubscription, error) {
+	if err := push.Validate(); err != nil {
+		return nil, err
+	}
+
+	if w.confirmations != nil {
+		if err := w.confirmations.Allow(ctx, push.Endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	return w.subscribe(ctx, region, name, push.Endpoint, push)
+}
+
+func (w *Watchlist) subscribe(ctx context.Context, region string, name string, contact string, push *PushSubscription) (*WatchSubscription, error) {
+	var subscription *WatchSubscription
+
+	err := w.update(ctx, region, name, func(watch *Watch) error {
+		id := subscriptionId(contact)
+		if existing, ok := watch.Subscriptions[id]; ok {
+			subscription = existing
+			if push != nil {
+				existing.Push = push
+			}
+			return nil
+		}
+
+		if len(watch.Subscriptions) >= maxWatchSubscriptions {
+			return fmt.Errorf("watchlist is full")
+		}
+
+		token := make([]byte, 16)
+		if _, err := rand.Read(token); err != nil {
+			return err
+		}
+
+		subscription = &WatchSubscription{
+			Contact:   strings.TrimSpace(contact),
+			Push:      push,
+			Token:     hex.EncodeToString(token),
+			CreatedAt: w.now().UnixMilli(),
+			Pending:   push == nil,
+		}
+		watch.Subscriptions[id] = subscription
+		return nil
+	})
+
+	return subscription, err
+}
+
+// Confirm activates a pending subscription, provided the token matches the one the contact was sent.
+func (w *Watchlist) Confirm(ctx context.Context, region string, name string, contact string, token string) error {
+	return w.update(ctx, region, name, func(watch *Watch) error {
+		subscription, ok := watch.Subscriptions[subscriptionId(contact)]
+		if !ok || token == "" || subscription.Token != token {
+			return fmt.Errorf("subscription not found")
+		}
+
+		subscription.Pending = false
+		return nil
+	})
+}
+
+// Unsubscribe removes a contact from a name's watch, provided the token matches the one the contact was sent.
+func (w *Watchlist) Unsubscribe(ctx context.Context, region string, name string, contact string, token string) error {
+	return w.update(ctx, region, name, func(watch *Watch) error {
+		id := subscriptionId(contact)
+		if subscription, ok := watch.Subscriptions[id]; !ok || token == "" || subscription.Token != token {
+			return fmt.Errorf("subscription not found")
+		}
+
+		delete(watch.Subscriptions, id)
+		return nil
+	})
+}
+
+// Get returns a name's watch, or nil when nobody watches it.
+func (w *Watchlist) Get(ctx context.Context, region string, name string) (*Watch, error) {
+	output, err := w.dynamodb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(w.tableName),
+		Key:            map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: WatchKey(region, name)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	watch := &Watch{}
+	if err = attributevalue.UnmarshalMap(output.Item, watch); err != nil {
+		return nil, err
+	}
+
+	return watch, nil
+}
+
+// GetMany returns the watches of whichever of the names are watched.
+func (w *Watchlist) GetMany(ctx context.Context, region string, names []string) ([]*Watch, error) {
+	watches := make([]*Watch, 0)
+	seen := make(map[string]bool)
+
+	var keys []map[string]types.AttributeValue
+	for i, name := range names {
+		key := WatchKey(region, name)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: key}})
+		}
+
+		if len(keys) < batchGetLimit && i < len(names)-1 {
+			continue
+		}
+
+		for len(keys) > 0 {
+			output, err := w.dynamodb.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: map[string]types.KeysAndAttributes{w.tableName: {Keys: keys}},
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, item := range output.Responses[w.tableName] {
+				watch := &Watch{}
+				if err = attributevalue.UnmarshalMap(item, watch); err != nil {
+					return nil, err
+				}
+
+				watches = append(watches, watch)
+			}
+
+			keys = output.UnprocessedKeys[w.tableName].Keys
+		}
+	}
+
+	return watches, nil
+}
+
+// Save writes back changes to a watch returned by Get or GetMany, failing if it changed in the meantime.
+// A watch left without subscriptions is deleted.
+func (w *Watchlist) Save(ctx context.Context, watch *Watch) error {
+	if len(watch.Subscriptions) > 0 {
+		return putSingleItem(ctx, w.dynamodb, w.tableName, watch, watch.UpdatedAt, w.now())
+	}
+
+	_, err := w.dynamodb.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(w.tableName),
+		Key:                 map[string]types.AttributeValue{"n": &types.AttributeValueMemberS{Value: watch.Key}},
+		ConditionExpression: aws.String("attribute_not_exists(n) OR ua = :ua"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ua": &types.AttributeValueMemberN{Value: strconv.FormatInt(watch.UpdatedAt, 10)},
+		},
+	})
+
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return &ConcurrentModificationError{Key: watch.Key}
+	}
+
+	return err
+}
+
+func (w *Watchlist) update(ctx context.Context, region string, name string, change func(watch *Watch) error) error {
+	watch, err := w.Get(ctx, region, name)
+	if err != nil {
+		return err
+	}
+
+	if watch == nil {
+		watch = &Watch{Key: WatchKey(region, name), Region: region, Name: name}
+	}
+
+	if watch.Subscriptions == nil {
+		watch.Subscriptions = make(map[string]*WatchSubscription)
+	}
+
+	for id, subscription := range watch.Subscriptions {
+		if subscription.Pending && confirmationExpired(subscription.CreatedAt, w.now()) {
+			delete(watch.Subscriptions, id)
+		}
+	}
+
+	if err = change(watch); err != nil {
+		return err
+	}
+
+	return w.Save(ctx, watch)
+}
@@ -0,0 +1,272 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+// WatchlistDynamoDBServiceMock stores items in memory and enforces the "ua" optimistic lock.
+type WatchlistDynamoDBServiceMock struct {
+	Items          map[string]map[string]types.AttributeValue
+	BatchGetCalls  int
+	UnprocessedKey string
+}
+
+func newWatchlistDynamoDBServiceMock() *WatchlistDynamoDBServiceMock {
+	return &WatchlistDynamoDBServiceMock{Items: make(map[string]map[string]types.AttributeValue)}
+}
+
+func (w *WatchlistDynamoDBServiceMock) locked(key string, values map[string]types.AttributeValue) bool {
+	item, ok := w.Items[key]
+	if !ok {
+		return false
+	}
+
+	ua, _ := item["ua"].(*types.AttributeValueMemberN)
+	return ua == nil || ua.Value != values[":ua"].(*types.AttributeValueMemberN).Value
+}
+
+func (w *WatchlistDynamoDBServiceMock) GetItem(_ context.Context, input *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: w.Items[input.Key["n"].(*types.AttributeValueMemberS).Value]}, nil
+}
+
+func (w *WatchlistDynamoDBServiceMock) PutItem(_ context.Context, input *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	key := input.Item["n"].(*types.AttributeValueMemberS).Value
+	if w.locked(key, input.ExpressionAttributeValues) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	w.Items[key] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (w *WatchlistDynamoDBServiceMock) DeleteItem(_ context.Context, input *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	key := input.Key["n"].(*types.AttributeValueMemberS).Value
+	if w.locked(key, input.ExpressionAttributeValues) {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+
+	delete(w.Items, key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+// BatchGetItem returns UnprocessedKey as unprocessed the first time it is requested.
+func (w *WatchlistDynamoDBServiceMock) BatchGetItem(_ context.Context, input *dynamodb.BatchGetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	w.BatchGetCalls++
+	output := &dynamodb.BatchGetItemOutput{
+		Responses:       make(map[string][]map[string]types.AttributeValue),
+		UnprocessedKeys: make(map[string]types.KeysAndAttributes),
+	}
+
+	for table, request := range input.RequestItems {
+		for _, key := range request.Keys {
+			name := key["n"].(*types.AttributeValueMemberS).Value
+			if name == w.UnprocessedKey {
+				w.UnprocessedKey = ""
+				output.UnprocessedKeys[table] = types.KeysAndAttributes{Keys: append(output.UnprocessedKeys[table].Keys, key)}
+				continue
+			}
+
+			if item, ok := w.Items[name]; ok {
+				output.Responses[table] = append(output.Responses[table], item)
+			}
+		}
+	}
+
+	return output, nil
+}
+
+func newTestWatchlist() (*Watchlist, *WatchlistDynamoDBServiceMock) {
+	watchlist, client, _ := newTestWatchlistWithClock()
+	return watchlist, client
+}
+
+func newTestWatchlistWithClock() (*Watchlist, *WatchlistDynamoDBServiceMock, *fakeClock) {
+	client := newWatchlistDynamoDBServiceMock()
+	watchlist := NewWatchlist(client, "test-table")
+	confirmations, _, _ := newTestConfirmations()
+	watchlist.ConfirmWith(confirmations, "https://names.lol/watch/confirm")
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	watchlist.now = func() time.Time {
+		clock.Advance(time.Millisecond)
+		return clock.Now()
+	}
+
+	return watchlist, client, clock
+}
+
+func TestWatchlist_SubscribeIsIdempotentPerContact(t *testing.T) {
+	watchlist, client := newTestWatchlist()
+
+	first, err := watchlist.Subscribe(context.TODO(), "NA", "Some Name", "player@example.com")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	second, _ := watchlist.Subscribe(context.TODO(), "NA", "somename", " Player@Example.com")
+	if first.Token == "" || second.Token != first.Token {
+		t.Errorf("expected the existing subscription to be returned, got %+v and %+v", first, second)
+	}
+
+	if _, ok := client.Items["watch#NA#SOMENAME"]; !ok {
+		t.Errorf("expected the watch to be stored next to the summoner, got %v", client.Items)
+	}
+
+	watch, _ := watchlist.Get(context.TODO(), "NA", "SOMENAME")
+	if watch == nil || len(watch.Subscriptions) != 1 || watch.Name != "Some Name" {
+		t.Errorf("unexpected watch %+v", watch)
+	}
+}
+
+func TestWatchlist_UnsubscribeRequiresToken(t *testing.T) {
+	watchlist, client := newTestWatchlist()
+	subscription, _ := watchlist.Subscribe(context.TODO(), "NA", "name", "player@example.com")
+
+	if err := watchlist.Unsubscribe(context.TODO(), "NA", "name", "player@example.com", "wrong"); err == nil || err.Error() != "subscription not found" {
+		t.Errorf("expected subscription not found, got %v", err)
+	}
+
+	if err := watchlist.Unsubscribe(context.TODO(), "NA", "name", "player@example.com", subscription.Token); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(client.Items) != 0 {
+		t.Errorf("expected the empty watch to be deleted, got %v", client.Items)
+	}
+}
+
+func TestWatchlist_SaveFailsWhenChangedConcurrently(t *testing.T) {
+	watchlist, _ := newTestWatchlist()
+	_, _ = watchlist.Subscribe(context.TODO(), "NA", "name", "a@example.com")
+
+	stale, _ := watchlist.Get(context.TODO(), "NA", "name")
+	_, _ = watchlist.Subscribe(context.TODO(), "NA", "name", "b@example.com")
+
+	if err := watchlist.Save(context.TODO(), stale); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestWatchlist_GetManyRetriesUnprocessedKeys(t *testing.T) {
+	watchlist, client := newTestWatchlist()
+	_, _ = watchlist.Subscribe(context.TODO(), "NA", "a", "player@example.com")
+	_, _ = watchlist.Subscribe(context.TODO(), "NA", "b", "player@example.com")
+	client.UnprocessedKey = "watch#NA#B"
+
+	watches, err := watchlist.GetMany(context.TODO(), "NA", []string{"a", "b", "c", "a"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(watches) != 2 || client.BatchGetCalls != 2 {
+		t.Errorf("expected both watches after a retry, got %d watches in %d calls", len(watches), client.BatchGetCalls)
+	}
+}
+
+func TestWatchlist_SubscribeLimitsSubscriptions(t *testing.T) {
+	watchlist, _ := newTestWatchlist()
+	for i := 0; i < maxWatchSubscriptions; i++ {
+		if _, err := watchlist.Subscribe(context.TODO(), "NA", "name", fmt.Sprintf("player%d@example.com", i)); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+	}
+
+	if _, err := watchlist.Subscribe(context.TODO(), "NA", "name", "late@example.com"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestWatchlist_SubscribePushValidatesAndRenewsKeys(t *testing.T) {
+	watchlist, _ := newTestWatchlist()
+
+	if _, err := watchlist.SubscribePush(context.TODO(), "NA", "name", &PushSubscription{Endpoint: "https://push.example.com/browser"}); err == nil {
+		t.Errorf("expected error for missing keys, got nil")
+	}
+
+	first, err := watchlist.SubscribePush(context.TODO(), "NA", "name", testPushSubscription("https://push.example.com/browser"))
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	renewed := testPushSubscription("https://push.example.com/browser")
+	second, _ := watchlist.SubscribePush(context.TODO(), "NA", "name", renewed)
+	if second.Token != first.Token || second.Contact != "https://push.example.com/browser" {
+		t.Errorf("expected the existing subscription to be returned, got %+v and %+v", first, second)
+	}
+
+	watch, _ := watchlist.Get(context.TODO(), "NA", "name")
+	for _, subscription := range watch.Subscriptions {
+		if subscription.Push == nil || subscription.Push.Keys != renewed.Keys {
+			t.Errorf("expected the renewed keys to be stored, got %+v", subscription.Push)
+		}
+	}
+}
+
+func TestWatchlist_SubscribeStaysPendingUntilConfirmed(t *testing.T) {
+	watchlist, _ := newTestWatchlist()
+	mailer := watchlist.confirmations.mailer.(*MailerMock)
+
+	subscription, err := watchlist.Subscribe(context.TODO(), "NA", "Some Name", "player@example.com")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !subscription.Pending || len(mailer.Bodies) != 1 || !strings.Contains(mailer.Bodies[0], "token="+subscription.Token) {
+		t.Fatalf("expected a pending subscription and a confirmation link, got %+v and %v", subscription, mailer.Bodies)
+	}
+
+	if err = watchlist.Confirm(context.TODO(), "NA", "Some Name", "player@example.com", "wrong"); err == nil || err.Error() != "subscription not found" {
+		t.Errorf("expected subscription not found, got %v", err)
+	}
+
+	if err = watchlist.Confirm(context.TODO(), "NA", "Some Name", "player@example.com", subscription.Token); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	watch, _ := watchlist.Get(context.TODO(), "NA", "Some Name")
+	if watch.Subscriptions[subscriptionId("player@example.com")].Pending {
+		t.Errorf("expected the subscription to be confirmed")
+	}
+
+	_, _ = watchlist.Subscribe(context.TODO(), "NA", "Some Name", "player@example.com")
+	if len(mailer.Bodies) != 1 {
+		t.Errorf("expected no confirmation for a confirmed subscription, got %d", len(mailer.Bodies))
+	}
+}
+
+func TestWatchlist_SubscribeIsRateLimitedPerContact(t *testing.T) {
+	watchlist, client := newTestWatchlist()
+	watchlist.confirmations.limiter.(*SubscribeLimiterMock).Limited = true
+
+	_, err := watchlist.Subscribe(context.TODO(), "NA", "name", "player@example.com")
+
+	var rateLimitedErr *RateLimitedError
+	if !errors.As(err, &rateLimitedErr) || len(client.Items) != 0 {
+		t.Errorf("expected RateLimitedError without writes, got %v and %v", err, client.Items)
+	}
+}
+
+func TestWatchlist_SubscribeRequiresConfirmations(t *testing.T) {
+	watchlist := NewWatchlist(newWatchlistDynamoDBServiceMock(), "test-table")
+
+	if _, err := watchlist.Subscribe(context.TODO(), "NA", "name", "player@example.com"); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestWatchlist_DropsExpiredPendingSubscriptions(t *testing.T) {
+	watchlist, _, clock := newTestWatchlistWithClock()
+	subscription, _ := watchlist.Subscribe(context.TODO(), "NA", "name", "a@example.com")
+	clock.Advance(confirmationTtl + time.Minute)
+
+	_, _ = watchlist.Subscribe(context.TODO(), "NA", "name", "b@example.com")
+	if err := watchlist.Confirm(context.TODO(), "NA", "name", "a@example.com", subscription.Token); err == nil {
+		t.Errorf("expected the expired subscription to be dropped")
+	}
+}
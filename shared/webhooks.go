@@ -0,0 +1,526 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// webhookEndpointsKey holds every endpoint in a single item, like the blocklist rules.
+	webhookEndpointsKey = "webhook#endpoints"
+	webhookLogPrefix    = "webhook#log#"
+
+	// maxWebhookLogEntries and maxWebhookDeadLetters bound the per-endpoint items, oldest entries first out.
+	maxWebhookLogEntries  = 100
+	maxWebhookDeadLetters = 100
+
+	// DefaultWebhookAttempts is how many times a delivery is tried before it is dead-lettered.
+	DefaultWebhookAttempts = 8
+
+	webhookBaseDelay = 30 * time.Second
+	// webhookMaxDelay is the longest delay SQS allows on a message.
+	webhookMaxDelay  = 15 * time.Minute
+	webhookTimeout   = 10 * time.Second
+	webhookSaveTries = 3
+)
+
+// Headers sent with every delivery. The signature header is "t=<unix seconds>,v1=<hex HMAC-SHA256>", the
+// HMAC being of "<unix seconds>.<body>" keyed by the endpoint's secret.
+const (
+	WebhookEventHeader     = "X-Nameslol-Event"
+	WebhookDeliveryHeader  = "X-Nameslol-Delivery"
+	WebhookSignatureHeader = "X-Nameslol-Signature"
+)
+
+type webhooksDynamoDbService interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// WebhookEndpoint receives the events it subscribes to, from every region unless Regions is set.
+type WebhookEndpoint struct {
+	ID          string   `dynamodbav:"id" json:"id"`
+	URL         string   `dynamodbav:"u" json:"url"`
+	Secret      string   `dynamodbav:"s" json:"secret,omitempty"`
+	Events      []string `dynamodbav:"e" json:"events"`
+	Regions     []string `dynamodbav:"rg,omitempty" json:"regions,omitempty"`
+	Description string   `dynamodbav:"d,omitempty" json:"description,omitempty"`
+	CreatedAt   int64    `dynamodbav:"at" json:"createdAt"`
+}
+
+func (e *WebhookEndpoint) Accepts(event *NameEvent) bool {
+	return slices.Contains(e.Events, event.Type) && (len(e.Regions) == 0 || slices.Contains(e.Regions, event.Region))
+}
+
+// WebhookDelivery is the body of the messages on the webhook delivery queue. Attempt counts the attempts
+// already made.
+type WebhookDelivery struct {
+	ID         string     `json:"id"`
+	EndpointID string     `json:"endpointId"`
+	Event      *NameEvent `json:"event"`
+	Attempt    int        `json:"attempt"`
+}
+
+type WebhookLogEntry struct {
+	DeliveryID string `dynamodbav:"d" json:"deliveryId"`
+	EventType  string `dynamodbav:"t" json:"eventType"`
+	Attempt    int    `dynamodbav:"a" json:"attempt"`
+	StatusCode int    `dynamodbav:"sc,omitempty" json:"statusCode,omitempty"`
+	Error      string `dynamodbav:"er,omitempty" json:"error,omitempty"`
+	Outcome    string `dynamodbav:"o" json:"outcome"`
+	At         int64  `dynamodbav:"at" json:"at"`
+}
+
+// Delivery outcomes recorded in the log.
+const (
+	WebhookDelivered = "delivered"
+	WebhookRetrying  = "retrying"
+	WebhookDead      = "dead"
+)
+
+type WebhookDeadLetter struct {
+	Delivery WebhookDelivery `dynamodbav:"dl" json:"delivery"`
+	Error    string          `dynamodbav:"er" json:"error"`
+	At       int64           `dynamodbav:"at" json:"at"`
+}
+
+type webhookEndpointsItem struct {
+	Key       string            `dynamodbav:"n"`
+	Endpoints []WebhookEndpoint `dynamodbav:"ep"`
+	UpdatedAt int64             `dynamodbav:"ua"`
+}
+
+func (i *webhookEndpointsItem) updatedAt() *int64 {
+	return &i.UpdatedAt
+}
+
+type webhookLogItem struct {
+	Key         string              `dynamodbav:"n"`
+	Entries     []WebhookLogEntry   `dynamodbav:"l"`
+	DeadLetters []WebhookDeadLetter `dynamodbav:"dl"`
+	UpdatedAt   int64               `dynamodbav:"ua"`
+}
+
+func (l *webhookLogItem) updatedAt() *int64 {
+	return &l.UpdatedAt
+}
+
+type Webhooks struct {
+	mu          sync.Mutex
+	dynamodb    webhooksDynamoDbService
+	sqs         sqsService
+	http        httpService
+	queueUrl    string
+	tableName   string
+	maxAttempts int
+	ttl         time.Duration
+	endpoints   []WebhookEndpoint
+	loadedAt    time.Time
+	now         func() time.Time
+}
+
+// NewWebhooks publishes events by queueing one delivery per accepting endpoint on queueUrl, and delivers
+// them from there. Endpoints are cached for a minute.
+func NewWebhooks(client webhooksDynamoDbService, tableName string, queue sqsService, queueUrl string) *Webhooks {
+	return &Webhooks{
+		dynamodb:    client,
+		sqs:         queue,
+		http:        &http.Client{Timeout: webhookTimeout},
+		queueUrl:    queueUrl,
+		tableName:   tableName,
+		maxAttempts: DefaultWebhookAttempts,
+		ttl:         time.Minute,
+		now:         time.Now,
+	}
+}
+
+// Register adds an endpoint and returns it with the secret its deliveries are signed with.
+func (w *Webhooks) Register(ctx context.Context, endpoint WebhookEndpoint) (*WebhookEndpoint, error) {
+	parsed, err := url.Parse(endpoint.URL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && !(parsed.Scheme == "http" && parsed.Hostname() == "localhost")) {
+		return nil, fmt.Errorf("invalid url '%s', expected https", endpoint.URL)
+	}
+
+	if len(endpoint.Events) == 0 {
+		endpoint.Events = nameEventTypes
+	}
+
+	for _, event := range endpoint.Events {
+		if !slices.Contains(nameEventTypes, event) {
+			return nil, fmt.Errorf("invalid event '%s'", event)
+		}
+	}
+
+	regions := NewRegions()
+	for _, region := range endpoint.Regions {
+		if !regions.Validate(region) {
+			return nil, fmt.Errorf("invalid region '%s'", region)
+		}
+	}
+
+	endpoint.ID = randomId()[:12]
+	endpoint.Secret = "whsec_" + randomId()
+	endpoint.CreatedAt = w.now().UnixMilli()
+
+	err = w.updateEndpoints(ctx, func(item *webhookEndpointsItem) error {
+		item.Endpoints = append(item.Endpoints, endpoint)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &endpoint, nil
+}
+
+func (w *Webhooks) Remove(ctx context.Context, id string) error {
+	return w.updateEndpoints(ctx, func(item *webhookEndpointsItem) error {
+		for i, endpoint := range item.Endpoints {
+			if endpoint.ID == id {
+				item.Endpoints = append(item.Endpoints[:i], item.Endpoints[i+1:]...)
+				return nil
+			}
+		}
+
+		return fmt.Errorf("webhook not found")
+	})
+}
+
+// Endpoints returns every registered endpoint, without their secrets.
+func (w *Webhooks) Endpoints(ctx context.Context) ([]WebhookEndpoint, error) {
+	item, err := w.getEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range item.Endpoints {
+		item.Endpoints[i].Secret = ""
+	}
+
+	return item.Endpoints, nil
+}
+
+// Log returns an endpoint's most recent delivery attempts, newest last.
+func (w *Webhooks) Log(ctx context.Context, id string) ([]WebhookLogEntry, error) {
+	item, err := w.getLog(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return item.Entries, nil
+}
+
+func (w *Webhooks) DeadLetters(ctx context.Context, id string) ([]WebhookDeadLetter, error) {
+	item, err := w.getLog(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return item.DeadLetters, nil
+}
+
+// Redrive queues an endpoint's dead-lettered deliveries again, with their attempts reset.
+func (w *Webhooks) Redrive(ctx context.Context, id string) (int, error) {
+	if w.queueUrl == "" {
+		return 0, fmt.Errorf("webhook queue is not configured")
+	}
+
+	item, err := w.getLog(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	redriven := make(map[string]bool)
+	for _, deadLetter := range item.DeadLetters {
+		delivery := deadLetter.Delivery
+		delivery.Attempt = 0
+		if err = w.enqueue(ctx, &delivery, 0); err != nil {
+			break
+		}
+
+		redriven[delivery.ID] = true
+	}
+
+	updateErr := w.updateLog(ctx, id, func(item *webhookLogItem) {
+		item.DeadLetters = slices.DeleteFunc(item.DeadLetters, func(deadLetter WebhookDeadLetter) bool {
+			return redriven[deadLetter.Delivery.ID]
+		})
+	})
+
+	return len(redriven), errors.Join(err, updateErr)
+}
+
+// Publish queues a delivery of the event to every endpoint accepting it.
+func (w *Webhooks) Publish(ctx context.Context, event *NameEvent) error {
+	endpoints, err := w.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		if !endpoint.Accepts(event) {
+			continue
+		}
+
+		if err = w.enqueue(ctx, &WebhookDelivery{ID: randomId(), EndpointID: endpoint.ID, Event: event}, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deliver makes one attempt at a delivery. A failed attempt is queued again with exponential backoff until
+// the endpoint has failed maxAttempts times, when the delivery is dead-lettered. Every attempt is logged.
+// Deliver only returns an error when the delivery could be lost, so its message should be retried.
+func (w *Webhooks) Deliver(ctx context.Context, delivery *WebhookDelivery) error {
+	endpoint, err := w.endpoint(ctx, delivery.EndpointID)
+	if err != nil || endpoint == nil {
+		return err
+	}
+
+	delivery.Attempt++
+	entry := WebhookLogEntry{DeliveryID: delivery.ID, EventType: delivery.Event.Type, Attempt: delivery.Attempt, At: w.now().UnixMilli()}
+
+	entry.StatusCode, err = w.send(ctx, endpoint, delivery)
+	switch {
+	case err == nil:
+		entry.Outcome = WebhookDelivered
+	case delivery.Attempt >= w.maxAttempts:
+		entry.Outcome, entry.Error = WebhookDead, err.Error()
+		return w.updateLog(ctx, endpoint.ID, func(item *webhookLogItem) {
+			item.appendEntry(entry)
+			item.DeadLetters = append(item.DeadLetters, WebhookDeadLetter{Delivery: *delivery, Error: entry.Error, At: entry.At})
+			if len(item.DeadLetters) > maxWebhookDeadLetters {
+				item.DeadLetters = item.DeadLetters[len(item.DeadLetters)-maxWebhookDeadLetters:]
+			}
+		})
+	default:
+		entry.Outcome, entry.Error = WebhookRetrying, err.Error()
+		if err = w.enqueue(ctx, delivery, WebhookBackoff(delivery.Attempt)); err != nil {
+			return err
+		}
+	}
+
+	// The delivery is settled, and failing here would only send it twice.
+	if err = w.updateLog(ctx, endpoint.ID, func(item *webhookLogItem) { item.appendEntry(entry) }); err != nil {
+		log.Printf("could not log delivery '%s' to webhook '%s': %v", delivery.ID, endpoint.ID, err)
+	}
+
+	return nil
+}
+
+// WebhookBackoff returns how long to wait before retrying after the given number of failed attempts.
+func WebhookBackoff(attempt int) time.Duration {
+	delay := webhookBaseDelay
+	for i := 1; i < attempt && delay < webhookMaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, webhookMaxDelay)
+}
+
+func (w *Webhooks) send(ctx context.Context, endpoint *WebhookEndpoint, delivery *WebhookDelivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "nameslol-webhooks")
+	req.Header.Set(WebhookEventHeader, delivery.Event.Type)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(endpoint.Secret, w.now().Unix(), body))
+
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	if resp.Body != nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned status code %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the signature header value for a body sent at timestamp (unix seconds).
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	t := strconv.FormatInt(timestamp, 10)
+	return "t=" + t + ",v1=" + webhookHMAC(secret, t, body)
+}
+
+// VerifyWebhookSignature checks a signature header against the body, rejecting signatures older than
+// tolerance so captured deliveries cannot be replayed.
+func VerifyWebhookSignature(secret string, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return fmt.Errorf("malformed signature header")
+	}
+
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp is outside the tolerance")
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(webhookHMAC(secret, timestamp, body))) {
+		return fmt.Errorf("signature does not match")
+	}
+
+	return nil
+}
+
+func webhookHMAC(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *Webhooks) enqueue(ctx context.Context, delivery *WebhookDelivery, delay time.Duration) error {
+	body, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.sqs.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:     aws.String(w.queueUrl),
+		MessageBody:  aws.String(string(body)),
+		DelaySeconds: int32(delay.Seconds()),
+	})
+
+	return err
+}
+
+// load returns the endpoints, cached for the ttl.
+func (w *Webhooks) load(ctx context.Context) ([]WebhookEndpoint, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.loadedAt.IsZero() && w.now().Sub(w.loadedAt) < w.ttl {
+		return w.endpoints, nil
+	}
+
+	item, err := w.getEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	w.endpoints = item.Endpoints
+	w.loadedAt = w.now()
+	return w.endpoints, nil
+}
+
+// endpoint returns a registered endpoint, or nil if it has been removed.
+func (w *Webhooks) endpoint(ctx context.Context, id string) (*WebhookEndpoint, error) {
+	endpoints, err := w.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range endpoints {
+		if endpoints[i].ID == id {
+			return &endpoints[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (w *Webhooks) getEndpoints(ctx context.Context) (*webhookEndpointsItem, error) {
+	item := &webhookEndpointsItem{Key: webhookEndpointsKey}
+	return item, getSingleItem(ctx, w.dynamodb, w.tableName, webhookEndpointsKey, item)
+}
+
+func (w *Webhooks) getLog(ctx context.Context, id string) (*webhookLogItem, error) {
+	item := &webhookLogItem{Key: webhookLogPrefix + id}
+	return item, getSingleItem(ctx, w.dynamodb, w.tableName, item.Key, item)
+}
+
+func (w *Webhooks) updateEndpoints(ctx context.Context, change func(item *webhookEndpointsItem) error) error {
+	item, err := w.getEndpoints(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = updateSingleItem(ctx, w.dynamodb, w.tableName, item, w.now(), func() error {
+		return change(item)
+	})
+
+	if err == nil {
+		w.mu.Lock()
+		w.loadedAt = time.Time{}
+		w.mu.Unlock()
+	}
+
+	return err
+}
+
+// updateLog applies change to an endpoint's log, retrying when deliveries running at the same time
+// changed it first.
+func (w *Webhooks) updateLog(ctx context.Context, id string, change func(item *webhookLogItem)) error {
+	var err error
+	for try := 0; try < webhookSaveTries; try++ {
+		var item *webhookLogItem
+		item, err = w.getLog(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		err = updateSingleItem(ctx, w.dynamodb, w.tableName, item, w.now(), func() error {
+			change(item)
+			return nil
+		})
+
+		if !isConcurrentModification(err) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("could not update log of webhook '%s': %v", id, err)
+}
+
+func (l *webhookLogItem) appendEntry(entry WebhookLogEntry) {
+	l.Entries = append(l.Entries, entry)
+	if len(l.Entries) > maxWebhookLogEntries {
+		l.Entries = l.Entries[len(l.Entries)-maxWebhookLogEntries:]
+	}
+}
@@ -0,0 +1,209 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type WebhookHttpMock struct {
+	StatusCode int
+	ShouldFail bool
+	Requests   []*http.Request
+	Bodies     []string
+}
+
+func (m *WebhookHttpMock) Do(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	m.Requests = append(m.Requests, req)
+	m.Bodies = append(m.Bodies, string(body))
+
+	if m.ShouldFail {
+		return nil, fmt.Errorf("connection refused")
+	}
+
+	return &http.Response{StatusCode: m.StatusCode, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func newTestWebhooks() (*Webhooks, *SQSServiceMock, *WebhookHttpMock) {
+	queue := &SQSServiceMock{}
+	client := &WebhookHttpMock{StatusCode: 200}
+	webhooks := NewWebhooks(newWatchlistDynamoDBServiceMock(), "test-table", queue, "test.queue.url")
+	webhooks.http = client
+	clock := &fakeClock{current: time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)}
+	webhooks.now = func() time.Time {
+		clock.Advance(time.Millisecond)
+		return clock.Now()
+	}
+
+	return webhooks, queue, client
+}
+
+func queuedDeliveries(t *testing.T, queue *SQSServiceMock) []*WebhookDelivery {
+	deliveries := make([]*WebhookDelivery, 0, len(queue.Calls))
+	for _, call := range queue.Calls {
+		var delivery WebhookDelivery
+		if err := json.Unmarshal([]byte(*call.MessageBody), &delivery); err != nil {
+			t.Fatalf("could not read queued delivery, %v", err)
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries
+}
+
+func TestWebhooks_RegisterValidatesEndpoint(t *testing.T) {
+	webhooks, _, _ := newTestWebhooks()
+
+	invalid := []WebhookEndpoint{
+		{URL: "http://example.com/hook"},
+		{URL: "not a url"},
+		{URL: "https://example.com/hook", Events: []string{"name.renamed"}},
+		{URL: "https://example.com/hook", Regions: []string{"BR"}},
+	}
+	for _, endpoint := range invalid {
+		if _, err := webhooks.Register(context.TODO(), endpoint); err == nil {
+			t.Errorf("%+v: expected error, got nil", endpoint)
+		}
+	}
+
+	endpoint, err := webhooks.Register(context.TODO(), WebhookEndpoint{URL: "https://example.com/hook"})
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !strings.HasPrefix(endpoint.Secret, "whsec_") || len(endpoint.Events) != 3 {
+		t.Errorf("expected a secret and every event, got %+v", endpoint)
+	}
+
+	endpoints, _ := webhooks.Endpoints(context.TODO())
+	if len(endpoints) != 1 || endpoints[0].Secret != "" {
+		t.Errorf("expected the endpoint to be listed without its secret, got %+v", endpoints)
+	}
+}
+
+func TestWebhooks_PublishQueuesDeliveryPerAcceptingEndpoint(t *testing.T) {
+	webhooks, queue, _ := newTestWebhooks()
+	all, _ := webhooks.Register(context.TODO(), WebhookEndpoint{URL: "https://example.com/all"})
+	_, _ = webhooks.Register(context.TODO(), WebhookEndpoint{URL: "https://example.com/euw", Regions: []string{"EUW"}})
+	_, _ = webhooks.Register(context.TODO(), WebhookEndpoint{URL: "https://example.com/taken", Events: []string{EventNameTaken}})
+
+	if err := webhooks.Publish(context.TODO(), NewNameEvent(EventNameAvailable, "NA", "name")); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	deliveries := queuedDeliveries(t, queue)
+	if len(deliveries) != 1 || deliveries[0].EndpointID != all.ID || deliveries[0].Event.Name != "name" {
+		t.Errorf("expected a single delivery to the catch-all endpoint, got %+v", deliveries)
+	}
+}
+
+func TestWebhooks_DeliverSignsRequest(t *testing.T) {
+	webhooks, _, client := newTestWebhooks()
+	endpoint, _ := webhooks.Register(context.TODO(), WebhookEndpoint{URL: "https://example.com/hook"})
+
+	event := NewNameEvent(EventNameDateChanged, "NA", "name")
+	if err := webhooks.Deliver(context.TODO(), &WebhookDelivery{ID: "delivery-1", EndpointID: endpoint.ID, Event: event}); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	req := client.Requests[0]
+	if req.Header.Get(WebhookEventHeader) != EventNameDateChanged || req.Header.Get(WebhookDeliveryHeader) != "delivery-1" {
+		t.Errorf("unexpected headers %v", req.Header)
+	}
+
+	signature := req.Header.Get(WebhookSignatureHeader)
+	if err := VerifyWebhookSignature(endpoint.Secret, signature, []byte(client.Bodies[0]), 5*time.Minute, webhooks.now()); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+
+	if err := VerifyWebhookSignature("whsec_other", signature, []byte(client.Bodies[0]), 5*time.Minute, webhooks.now()); err == nil {
+		t.Errorf("expected the signature not to match another secret")
+	}
+
+	entries, _ := webhooks.Log(context.TODO(), endpoint.ID)
+	if len(entries) != 1 || entries[0].Outcome != WebhookDelivered || entries[0].StatusCode != 200 {
+		t.Errorf("expected the delivery to be logged, got %+v", entries)
+	}
+}
+
+func TestWebhooks_DeliverRetriesWithBackoffThenDeadLetters(t *testing.T) {
+	webhooks, queue, client := newTestWebhooks()
+	webhooks.maxAttempts = 3
+	client.StatusCode = 500
+	endpoint, _ := webhooks.Register(context.TODO(), WebhookEndpoint{URL: "https://example.com/hook"})
+
+	delivery := &WebhookDelivery{ID: "delivery-1", EndpointID: endpoint.ID, Event: NewNameEvent(EventNameTaken, "NA", "name")}
+	for i := 0; i < 3; i++ {
+		if err := webhooks.Deliver(context.TODO(), delivery); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+	}
+
+	if len(queue.Calls) != 2 || queue.Calls[0].DelaySeconds != 30 || queue.Calls[1].DelaySeconds != 60 {
+		t.Errorf("expected two retries with backoff, got %d", len(queue.Calls))
+	}
+
+	deadLetters, _ := webhooks.DeadLetters(context.TODO(), endpoint.ID)
+	if len(deadLetters) != 1 || deadLetters[0].Delivery.Attempt != 3 || deadLetters[0].Error != "endpoint returned status code 500" {
+		t.Errorf("expected the delivery to be dead-lettered, got %+v", deadLetters)
+	}
+
+	entries, _ := webhooks.Log(context.TODO(), endpoint.ID)
+	if len(entries) != 3 || entries[0].Outcome != WebhookRetrying || entries[2].Outcome != WebhookDead {
+		t.Errorf("expected every attempt to be logged, got %+v", entries)
+	}
+
+	redriven, err := webhooks.Redrive(context.TODO(), endpoint.ID)
+	if err != nil || redriven != 1 {
+		t.Fatalf("expected 1 redriven delivery, got %d, %v", redriven, err)
+	}
+
+	if deliveries := queuedDeliveries(t, queue); deliveries[2].Attempt != 0 {
+		t.Errorf("expected the redriven delivery to start over, got %+v", deliveries[2])
+	}
+
+	if deadLetters, _ = webhooks.DeadLetters(context.TODO(), endpoint.ID); len(deadLetters) != 0 {
+		t.Errorf("expected the dead letters to be cleared, got %+v", deadLetters)
+	}
+}
+
+func TestWebhooks_DeliverDropsDeliveriesOfRemovedEndpoints(t *testing.T) {
+	webhooks, _, client := newTestWebhooks()
+	endpoint, _ := webhooks.Register(context.TODO(), WebhookEndpoint{URL: "https://example.com/hook"})
+	_ = webhooks.Remove(context.TODO(), endpoint.ID)
+
+	err := webhooks.Deliver(context.TODO(), &WebhookDelivery{ID: "delivery-1", EndpointID: endpoint.ID, Event: NewNameEvent(EventNameTaken, "NA", "name")})
+	if err != nil || len(client.Requests) != 0 {
+		t.Errorf("expected the delivery to be dropped, got %v and %d requests", err, len(client.Requests))
+	}
+}
+
+func TestWebhookBackoff_DoublesUpToSQSMaximum(t *testing.T) {
+	expected := map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 6: webhookMaxDelay, 20: webhookMaxDelay}
+	for attempt, delay := range expected {
+		if actual := WebhookBackoff(attempt); actual != delay {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, delay, actual)
+		}
+	}
+}
+
+func TestVerifyWebhookSignature_RejectsStaleTimestamps(t *testing.T) {
+	now := time.Date(2024, time.February, 12, 8, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"name.taken"}`)
+	header := SignWebhook("whsec_test", now.Add(-10*time.Minute).Unix(), body)
+
+	if err := VerifyWebhookSignature("whsec_test", header, body, 5*time.Minute, now); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if err := VerifyWebhookSignature("whsec_test", "garbage", body, 5*time.Minute, now); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,362 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// webPushRecordSize is the aes128gcm record size. Payloads are sent as a single record.
+	webPushRecordSize = 4096
+	// MaxWebPushPayload is the largest payload push services must accept: 4096 bytes of body, less the
+	// 86 byte aes128gcm header, the padding delimiter and the 16 byte authentication tag.
+	MaxWebPushPayload = 3993
+
+	webPushTimeout = 10 * time.Second
+	// webPushTTL is how long a push service keeps a message for a device that is offline.
+	webPushTTL = 24 * time.Hour
+	// vapidExpiry is how long a VAPID token is valid for, at most the 24 hours RFC 8292 allows.
+	vapidExpiry = 12 * time.Hour
+)
+
+// PushSubscription is a browser's push subscription, in the shape PushSubscription.toJSON() returns.
+// ExpirationTime is in unix milliseconds, or 0 when the subscription does not expire.
+type PushSubscription struct {
+	Endpoint       string   `dynamodbav:"e" json:"endpoint"`
+	ExpirationTime int64    `dynamodbav:"x,omitempty" json:"expirationTime,omitempty"`
+	Keys           PushKeys `dynamodbav:"k" json:"keys"`
+}
+
+type PushKeys struct {
+	P256dh string `dynamodbav:"p" json:"p256dh"`
+	Auth   string `dynamodbav:"a" json:"auth"`
+}
+
+// Validate checks the subscription can be delivered to: an https endpoint and keys decoding to a P-256
+// public key and a 16 byte authentication secret.
+func (p *PushSubscription) Validate() error {
+	endpoint, err := url.Parse(p.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return fmt.Errorf("invalid push endpoint, expected https")
+	}
+
+	if _, err = p.publicKey(); err != nil {
+		return err
+	}
+
+	if _, err = p.authSecret(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Expired reports whether the browser said the subscription would have expired by now.
+func (p *PushSubscription) Expired(now time.Time) bool {
+	return p.ExpirationTime > 0 && p.ExpirationTime <= now.UnixMilli()
+}
+
+func (p *PushSubscription) publicKey() (*ecdh.PublicKey, error) {
+	key, err := decodeBase64Url(p.Keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push key 'p256dh'")
+	}
+
+	publicKey, err := ecdh.P256().NewPublicKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push key 'p256dh'")
+	}
+
+	return publicKey, nil
+}
+
+func (p *PushSubscription) authSecret() ([]byte, error) {
+	secret, err := decodeBase64Url(p.Keys.Auth)
+	if err != nil || len(secret) != 16 {
+		return nil, fmt.Errorf("invalid push key 'auth'")
+	}
+
+	return secret, nil
+}
+
+// PushGoneError is returned when the push service no longer knows a subscription, so it should be removed.
+type PushGoneError struct {
+	StatusCode int
+}
+
+func (e *PushGoneError) Error() string {
+	return fmt.Sprintf("push subscription is gone (status code %d)", e.StatusCode)
+}
+
+func IsPushGone(err error) bool {
+	var goneErr *PushGoneError
+	return errors.As(err, &goneErr)
+}
+
+// WebPush sends notifications to browsers' push services, encrypting payloads as RFC 8291 describes and
+// identifying itself with VAPID (RFC 8292). Locally it can be pointed at any push service stand-in, as
+// it posts to whichever endpoint a subscription names.
+type WebPush struct {
+	key     *ecdsa.PrivateKey
+	public  []byte
+	subject string
+	http    httpService
+	now     func() time.Time
+}
+
+// NewWebPush signs with the VAPID private key, the base64url encoded P-256 scalar GenerateVAPIDKeys
+// returns. subject is a mailto: or https: URL push services can contact about the sender.
+func NewWebPush(privateKey string, subject string) (*WebPush, error) {
+	scalar, err := decodeBase64Url(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key")
+	}
+
+	key, err := ecdh.P256().NewPrivateKey(scalar)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key")
+	}
+
+	if !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https:") {
+		return nil, fmt.Errorf("invalid VAPID subject '%s', expected a mailto: or https: URL", subject)
+	}
+
+	public := key.PublicKey().Bytes()
+	return &WebPush{
+		key: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(public[1:33]), Y: new(big.Int).SetBytes(public[33:])},
+			D:         new(big.Int).SetBytes(scalar),
+		},
+		public:  public,
+		subject: subject,
+		http:    &http.Client{Timeout: webPushTimeout},
+		now:     time.Now,
+	}, nil
+}
+
+// WebPushFromEnv reads the VAPID subject from WEBPUSH_SUBJECT and the private key from the secret named by
+// WEBPUSH_VAPID_PRIVATE_KEY_NAME, or from WEBPUSH_VAPID_PRIVATE_KEY.
+func WebPushFromEnv(ctx context.Context, secrets SecretsSource) (*WebPush, error) {
+	privateKey := os.Getenv("WEBPUSH_VAPID_PRIVATE_KEY")
+	if name := os.Getenv("WEBPUSH_VAPID_PRIVATE_KEY_NAME"); name != "" {
+		var err error
+		privateKey, err = secrets.GetSecret(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if privateKey == "" {
+		return nil, fmt.Errorf("WEBPUSH_VAPID_PRIVATE_KEY or WEBPUSH_VAPID_PRIVATE_KEY_NAME is required")
+	}
+
+	return NewWebPush(privateKey, os.Getenv("WEBPUSH_SUBJECT"))
+}
+
+func webPushConfigured() bool {
+	return os.Getenv("WEBPUSH_VAPID_PRIVATE_KEY") != "" || os.Getenv("WEBPUSH_VAPID_PRIVATE_KEY_NAME") != ""
+}
+
+// GenerateVAPIDKeys returns a new base64url encoded VAPID key pair. The public key is the
+// applicationServerKey browsers subscribe with.
+func GenerateVAPIDKeys() (privateKey string, publicKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	return encodeBase64Url(key.Bytes()), encodeBase64Url(key.PublicKey().Bytes()), nil
+}
+
+// PublicKey returns the base64url encoded VAPID public key.
+func (w *WebPush) PublicKey() string {
+	return encodeBase64Url(w.public)
+}
+
+// Push encrypts the payload for the subscription and posts it to its push service. A subscription the push
+// service no longer knows returns a PushGoneError.
+func (w *WebPush) Push(ctx context.Context, subscription *PushSubscription, payload []byte) error {
+	if len(payload) > MaxWebPushPayload {
+		return fmt.Errorf("push payload of %d bytes is larger than %d", len(payload), MaxWebPushPayload)
+	}
+
+	body, err := encryptWebPush(subscription, payload, rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	authorization, err := w.authorization(subscription.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(webPushTTL.Seconds())))
+	req.Header.Set("Urgency", "high")
+
+	res, err := w.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return &PushGoneError{StatusCode: res.StatusCode}
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return fmt.Errorf("push service returned status code %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// authorization returns the VAPID header for the endpoint's push service: an ES256 JWT whose audience is
+// the service's origin, and the public key to verify it with.
+func (w *WebPush) authorization(endpoint string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("invalid push endpoint '%s'", endpoint)
+	}
+
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims, _ := json.Marshal(map[string]any{
+		"aud": parsed.Scheme + "://" + parsed.Host,
+		"exp": w.now().Add(vapidExpiry).Unix(),
+		"sub": w.subject,
+	})
+
+	unsigned := encodeBase64Url(header) + "." + encodeBase64Url(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, w.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// JWS signatures are the fixed size concatenation of r and s rather than ASN.1.
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return "vapid t=" + unsigned + "." + encodeBase64Url(signature) + ", k=" + w.PublicKey(), nil
+}
+
+// encryptWebPush encrypts the payload as a single aes128gcm record (RFC 8188) with the keys RFC 8291
+// derives from an ephemeral ECDH key pair and the subscription's keys.
+func encryptWebPush(subscription *PushSubscription, payload []byte, random io.Reader) ([]byte, error) {
+	uaPublic, err := subscription.publicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	authSecret, err := subscription.authSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(random)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err = io.ReadFull(random, salt); err != nil {
+		return nil, err
+	}
+
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	asPublic := asPrivate.PublicKey().Bytes()
+	cek, nonce := webPushKeys(sharedSecret, authSecret, salt, uaPublic.Bytes(), asPublic)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, 21+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	// 0x02 marks the last record, and is followed by no padding.
+	plaintext := append(append(make([]byte, 0, len(payload)+1), payload...), 0x02)
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// webPushKeys derives the content encryption key and nonce from the ECDH shared secret, as RFC 8291
+// section 3.4 describes.
+func webPushKeys(sharedSecret []byte, authSecret []byte, salt []byte, uaPublic []byte, asPublic []byte) ([]byte, []byte) {
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublic...), asPublic...)
+	ikm := hkdfExpand(hkdfExtract(authSecret, sharedSecret), keyInfo, 32)
+
+	prk := hkdfExtract(salt, ikm)
+	return hkdfExpand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16), hkdfExpand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+}
+
+// hkdfExtract and hkdfExpand are HKDF with SHA-256 (RFC 5869).
+func hkdfExtract(salt []byte, ikm []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+	return mac.Sum(nil)
+}
+
+func hkdfExpand(prk []byte, info []byte, length int) []byte {
+	var okm, block []byte
+	for counter := byte(1); len(okm) < length; counter++ {
+		mac := hmac.New(sha256.New, prk)
+		mac.Write(block)
+		mac.Write(info)
+		mac.Write([]byte{counter})
+		block = mac.Sum(nil)
+		okm = append(okm, block...)
+	}
+
+	return okm[:length]
+}
+
+func encodeBase64Url(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// decodeBase64Url accepts base64url with or without padding, as browsers and libraries differ.
+func decodeBase64Url(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
@@ -0,0 +1,268 @@
+package shared
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pushServiceStandIn is a local push service. It checks each message's VAPID token and decrypts it with
+// the browser's keys, answering with StatusCode.
+type pushServiceStandIn struct {
+	server     *httptest.Server
+	browserKey *ecdh.PrivateKey
+	authSecret []byte
+	StatusCode int
+	Payloads   []string
+	Claims     []map[string]any
+	Errors     []error
+}
+
+func newPushServiceStandIn(t *testing.T) *pushServiceStandIn {
+	browserKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+	authSecret := make([]byte, 16)
+	_, _ = rand.Read(authSecret)
+
+	standIn := &pushServiceStandIn{browserKey: browserKey, authSecret: authSecret, StatusCode: http.StatusCreated}
+	standIn.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		claims, err := verifyVapid(r.Header.Get("Authorization"))
+		if err != nil {
+			standIn.Errors = append(standIn.Errors, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		payload, err := standIn.decrypt(r.Header.Get("Content-Encoding"), body)
+		if err != nil {
+			standIn.Errors = append(standIn.Errors, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		standIn.Claims = append(standIn.Claims, claims)
+		standIn.Payloads = append(standIn.Payloads, payload)
+		w.WriteHeader(standIn.StatusCode)
+	}))
+	t.Cleanup(standIn.server.Close)
+
+	return standIn
+}
+
+func (p *pushServiceStandIn) Subscription(path string) *PushSubscription {
+	return &PushSubscription{
+		Endpoint: p.server.URL + path,
+		Keys:     PushKeys{P256dh: encodeBase64Url(p.browserKey.PublicKey().Bytes()), Auth: encodeBase64Url(p.authSecret)},
+	}
+}
+
+func (p *pushServiceStandIn) decrypt(encoding string, body []byte) (string, error) {
+	if encoding != "aes128gcm" || len(body) < 21 {
+		return "", fmt.Errorf("unexpected content encoding '%s'", encoding)
+	}
+
+	salt, recordSize, idLength := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	if recordSize != webPushRecordSize || len(body) < 21+idLength {
+		return "", fmt.Errorf("unexpected header")
+	}
+
+	senderKey, err := ecdh.P256().NewPublicKey(body[21 : 21+idLength])
+	if err != nil {
+		return "", err
+	}
+
+	sharedSecret, err := p.browserKey.ECDH(senderKey)
+	if err != nil {
+		return "", err
+	}
+
+	cek, nonce := webPushKeys(sharedSecret, p.authSecret, salt, p.browserKey.PublicKey().Bytes(), senderKey.Bytes())
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, body[21+idLength:], nil)
+	if err != nil {
+		return "", err
+	}
+
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		return "", fmt.Errorf("missing last record delimiter")
+	}
+
+	return string(plaintext[:len(plaintext)-1]), nil
+}
+
+func verifyVapid(authorization string) (map[string]any, error) {
+	var token, key string
+	for _, part := range strings.Split(strings.TrimPrefix(authorization, "vapid "), ", ") {
+		if value, ok := strings.CutPrefix(part, "t="); ok {
+			token = value
+		} else if value, ok := strings.CutPrefix(part, "k="); ok {
+			key = value
+		}
+	}
+
+	parts := strings.Split(token, ".")
+	public, err := decodeBase64Url(key)
+	if len(parts) != 3 || err != nil || len(public) != 65 {
+		return nil, fmt.Errorf("malformed authorization '%s'", authorization)
+	}
+
+	signature, err := decodeBase64Url(parts[2])
+	if err != nil || len(signature) != 64 {
+		return nil, fmt.Errorf("malformed signature")
+	}
+
+	verifier := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(public[1:33]), Y: new(big.Int).SetBytes(public[33:])}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(verifier, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		return nil, fmt.Errorf("invalid signature")
+	}
+
+	claimsJSON, _ := decodeBase64Url(parts[1])
+	var claims map[string]any
+	if err = json.Unmarshal(claimsJSON, &claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func newTestWebPush(t *testing.T) *WebPush {
+	privateKey, _, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	pusher, err := NewWebPush(privateKey, "mailto:alerts@names.lol")
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	pusher.now = func() time.Time { return watchTestNow }
+	return pusher
+}
+
+func TestWebPush_DeliversEncryptedPayloadWithVapid(t *testing.T) {
+	standIn := newPushServiceStandIn(t)
+	pusher := newTestWebPush(t)
+
+	err := pusher.Push(context.TODO(), standIn.Subscription("/push/abc"), []byte(`{"title":"Name (NA) is now available"}`))
+	if err != nil {
+		t.Fatalf("expected nil, got %v, stand-in saw %v", err, standIn.Errors)
+	}
+
+	if len(standIn.Payloads) != 1 || standIn.Payloads[0] != `{"title":"Name (NA) is now available"}` {
+		t.Fatalf("expected the payload to decrypt, got %v", standIn.Payloads)
+	}
+
+	claims := standIn.Claims[0]
+	if claims["aud"] != standIn.server.URL || claims["sub"] != "mailto:alerts@names.lol" || claims["exp"] != float64(watchTestNow.Add(vapidExpiry).Unix()) {
+		t.Errorf("unexpected VAPID claims %v", claims)
+	}
+}
+
+func TestWebPush_ReturnsGoneErrorForExpiredSubscriptions(t *testing.T) {
+	for _, statusCode := range []int{http.StatusNotFound, http.StatusGone} {
+		standIn := newPushServiceStandIn(t)
+		standIn.StatusCode = statusCode
+
+		err := newTestWebPush(t).Push(context.TODO(), standIn.Subscription("/push/abc"), []byte("{}"))
+		if !IsPushGone(err) {
+			t.Errorf("%d: expected a gone error, got %v", statusCode, err)
+		}
+	}
+
+	standIn := newPushServiceStandIn(t)
+	standIn.StatusCode = http.StatusTooManyRequests
+	if err := newTestWebPush(t).Push(context.TODO(), standIn.Subscription("/push/abc"), []byte("{}")); err == nil || IsPushGone(err) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+}
+
+func TestWebPush_RejectsOversizedPayload(t *testing.T) {
+	standIn := newPushServiceStandIn(t)
+
+	err := newTestWebPush(t).Push(context.TODO(), standIn.Subscription("/push/abc"), make([]byte, MaxWebPushPayload+1))
+	if err == nil || len(standIn.Payloads) != 0 {
+		t.Errorf("expected error, got %v", err)
+	}
+}
+
+func TestNewWebPush_ValidatesKeyAndSubject(t *testing.T) {
+	privateKey, _, _ := GenerateVAPIDKeys()
+
+	if _, err := NewWebPush("not-a-key", "mailto:alerts@names.lol"); err == nil {
+		t.Errorf("expected error for invalid key, got nil")
+	}
+
+	if _, err := NewWebPush(privateKey, "alerts@names.lol"); err == nil {
+		t.Errorf("expected error for invalid subject, got nil")
+	}
+}
+
+func TestPushSubscription_Validate(t *testing.T) {
+	standIn := newPushServiceStandIn(t)
+	valid := standIn.Subscription("/push/abc")
+	valid.Endpoint = "https://push.example.com/abc"
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	invalid := []PushSubscription{
+		{Endpoint: "http://push.example.com/abc", Keys: valid.Keys},
+		{Endpoint: valid.Endpoint, Keys: PushKeys{P256dh: "AAAA", Auth: valid.Keys.Auth}},
+		{Endpoint: valid.Endpoint, Keys: PushKeys{P256dh: valid.Keys.P256dh, Auth: "AAAA"}},
+	}
+	for _, subscription := range invalid {
+		if err := subscription.Validate(); err == nil {
+			t.Errorf("%+v: expected error, got nil", subscription)
+		}
+	}
+}
+
+func TestHkdf_MatchesRFC5869TestCase1(t *testing.T) {
+	ikm := make([]byte, 22)
+	for i := range ikm {
+		ikm[i] = 0x0b
+	}
+
+	salt := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c}
+	info := []byte{0xf0, 0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8, 0xf9}
+
+	okm := fmt.Sprintf("%x", hkdfExpand(hkdfExtract(salt, ikm), info, 42))
+	if okm != "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865" {
+		t.Errorf("unexpected okm %s", okm)
+	}
+}
+
+func TestWebPushKeys_MatchRFC8291Example(t *testing.T) {
+	senderPrivate, _ := decodeBase64Url("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw")
+	browserPublic, _ := decodeBase64Url("BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4")
+	authSecret, _ := decodeBase64Url("BTBZMqHH6r4Tts7J_aSIgg")
+	salt, _ := decodeBase64Url("DGv6ra1nlYgDCS1FRnbzlw")
+
+	sender, _ := ecdh.P256().NewPrivateKey(senderPrivate)
+	browser, _ := ecdh.P256().NewPublicKey(browserPublic)
+	sharedSecret, _ := sender.ECDH(browser)
+
+	cek, nonce := webPushKeys(sharedSecret, authSecret, salt, browserPublic, sender.PublicKey().Bytes())
+	if encodeBase64Url(cek) != "oIhVW04MRdy2XN9CiKLxTg" || encodeBase64Url(nonce) != "4h_95klXJ5E_qnoN" {
+		t.Errorf("unexpected key %s and nonce %s", encodeBase64Url(cek), encodeBase64Url(nonce))
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"io"
+	"log"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+type enqueueResult struct {
+	Region   string `json:"region"`
+	Enqueued int    `json:"enqueued"`
+	Skipped  int    `json:"skipped"`
+	DryRun   bool   `json:"dryRun,omitempty"`
+}
+
+type regionStats struct {
+	Region   string `json:"region"`
+	Total    int    `json:"total"`
+	Upcoming int    `json:"upcoming"`
+}
+
+type stats struct {
+	Regions []regionStats       `json:"regions"`
+	Budget  *shared.BudgetUsage `json:"budget"`
+}
+
+func lookup(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("lookup", flag.ContinueOnError)
+	region := flags.String("region", "", "region of the summoner")
+	live := flags.Bool("live", false, "fetch the summoner from the Riot API instead of the table")
+	output := flags.String("output", outputTable, "output format, table or json")
+	name, err := parseNameArgs(flags, args)
+	if err != nil {
+		return err
+	}
+
+	r, err := parseRegion(*region)
+	if err != nil {
+		return err
+	}
+
+	var summoner *shared.SummonerDTO
+	if *live {
+		summoner, err = summoners.Fetch(r, name)
+	} else {
+		summoner, err = summoners.Get(r, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeSummoners(stdout, *output, summoner)
+}
+
+// refresh fetches a summoner from the Riot API and saves it, deleting it when Riot no longer knows the
+// name, the same way the name update consumer does.
+func refresh(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("refresh", flag.ContinueOnError)
+	region := flags.String("region", "", "region of the summoner")
+	output := flags.String("output", outputTable, "output format, table or json")
+	name, err := parseNameArgs(flags, args)
+	if err != nil {
+		return err
+	}
+
+	r, err := parseRegion(*region)
+	if err != nil {
+		return err
+	}
+
+	summoner, err := summoners.Fetch(r, name)
+	if err != nil {
+		if err.Error() == "summoner not found" {
+			log.Printf("summoner '%s' was not found in region '%s', deleting...", name, r)
+			_, err = summoners.Delete(r, name)
+			return err
+		}
+
+		return err
+	}
+
+	if err = summoners.Save(summoner); err != nil {
+		return err
+	}
+
+	return writeSummoners(stdout, *output, summoner)
+}
+
+func deleteSummoner(args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	region := flags.String("region", "", "region of the summoner")
+	name, err := parseNameArgs(flags, args)
+	if err != nil {
+		return err
+	}
+
+	r, err := parseRegion(*region)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := summoners.Delete(r, name)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		log.Printf("summoner '%s' in region '%s' was not stored", name, r)
+		return nil
+	}
+
+	log.Printf("deleted summoner '%s' in region '%s'", name, r)
+	return nil
+}
+
+// enqueue sends the summoners becoming available between -from and -to to the name update queue, the
+// same way the producer does for its scheduled refreshes.
+func enqueue(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("enqueue", flag.ContinueOnError)
+	region := flags.String("region", "", "only enqueue this region")
+	from := flags.String("from", "", "enqueue summoners available from this date")
+	to := flags.String("to", "", "enqueue summoners available until this date")
+	limit := flags.Int("limit", 8000, "most summoners to enqueue per region")
+	dryRun := flags.Bool("dry-run", false, "count the summoners without sending them")
+	output := flags.String("output", outputTable, "output format, table or json")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *from == "" || *to == "" {
+		return fmt.Errorf("-from and -to are required")
+	}
+
+	start, err := parseTimestamp(*from)
+	if err != nil {
+		return err
+	}
+
+	end, err := parseTimestamp(*to)
+	if err != nil {
+		return err
+	}
+
+	if queue == nil && !*dryRun {
+		return fmt.Errorf("QUEUE_URL is not set")
+	}
+
+	regionsToEnqueue := shared.NewRegions().Sorted()
+	if *region != "" {
+		r, err := parseRegion(*region)
+		if err != nil {
+			return err
+		}
+
+		regionsToEnqueue = []string{r}
+	}
+
+	var results []*enqueueResult
+	for _, r := range regionsToEnqueue {
+		page, err := summoners.GetBetweenDate(r, int32(*limit), start, end)
+		if err != nil {
+			return err
+		}
+
+		for _, skipped := range page.Skipped {
+			log.Printf("skipped malformed summoner '%s': %s", skipped.Key, skipped.Reason)
+		}
+
+		result := &enqueueResult{Region: r, Skipped: len(page.Skipped), DryRun: *dryRun}
+		results = append(results, result)
+
+		for _, s := range page.Summoners {
+			if !*dryRun {
+				if err = queue.Send(ctx, r, s.Name); err != nil {
+					return err
+				}
+			}
+
+			result.Enqueued++
+		}
+	}
+
+	return writeOutput(stdout, *output, results, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "REGION\tENQUEUED\tSKIPPED")
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", result.Region, result.Enqueued, result.Skipped)
+		}
+	})
+}
+
+func showStats(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	days := flags.Int("days", 7, "count summoners becoming available within this many days as upcoming")
+	output := flags.String("output", outputTable, "output format, table or json")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	upcomingEnd := now.Add(time.Duration(*days) * 24 * time.Hour).UnixMilli()
+
+	result := &stats{}
+	for _, r := range shared.NewRegions().Sorted() {
+		total, err := summoners.Count(r, 0, math.MaxInt64)
+		if err != nil {
+			return err
+		}
+
+		upcoming, err := summoners.Count(r, now.UnixMilli(), upcomingEnd)
+		if err != nil {
+			return err
+		}
+
+		result.Regions = append(result.Regions, regionStats{Region: r, Total: total, Upcoming: upcoming})
+	}
+
+	result.Budget, err = summoners.RiotBudgetUsage()
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(stdout, *output, result, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "REGION\tTOTAL\tUPCOMING")
+		for _, s := range result.Regions {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", s.Region, s.Total, s.Upcoming)
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "BUDGET\tUSED\tLIMIT")
+		fmt.Fprintf(w, "interactive\t%d\t%d\n", result.Budget.InteractiveUsed, result.Budget.Limit)
+		fmt.Fprintf(w, "background\t%d\t%d\n", result.Budget.BackgroundUsed, result.Budget.BackgroundAllowance)
+	})
+}
+
+// parseNameArgs parses flags and returns the summoner name, which may be given before or after them and
+// may contain spaces.
+func parseNameArgs(flags *flag.FlagSet, args []string) (string, error) {
+	var nameArgs []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return "", err
+		}
+
+		if flags.NArg() == 0 {
+			break
+		}
+
+		nameArgs = append(nameArgs, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	name := strings.Join(nameArgs, " ")
+	if name == "" {
+		return "", fmt.Errorf("a summoner name is required")
+	}
+
+	return name, nil
+}
+
+func parseRegion(value string) (string, error) {
+	region := strings.ToUpper(value)
+	if region == "" {
+		return "", fmt.Errorf("-region is required")
+	}
+
+	if !shared.NewRegions().Validate(region) {
+		return "", fmt.Errorf("invalid region '%s'", value)
+	}
+
+	return region, nil
+}
+
+func writeSummoners(w io.Writer, format string, summoner *shared.SummonerDTO) error {
+	return writeOutput(w, format, summoner, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "NAME\tREGION\tLEVEL\tAVAILABLE\tLAST UPDATED")
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", summoner.Name, summoner.Region, summoner.Level,
+			formatMillis(summoner.AvailabilityDate), formatMillis(summoner.LastUpdated))
+	})
+}
+
+func writeOutput(w io.Writer, format string, v any, table func(*tabwriter.Writer)) error {
+	switch format {
+	case outputJSON:
+		return writeJSON(w, v)
+	case outputTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		table(tw)
+		return tw.Flush()
+	}
+
+	return fmt.Errorf("unknown output '%s', expected %s or %s", format, outputTable, outputJSON)
+}
+
+func formatMillis(millis int64) string {
+	return time.UnixMilli(millis).UTC().Format(time.DateTime)
+}
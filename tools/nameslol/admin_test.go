@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"strings"
+	"testing"
+)
+
+type SummonersServiceMock struct {
+	ShouldFail     bool
+	NotFound       bool
+	GetCalls       []string
+	FetchCalls     []string
+	SaveCalls      []*shared.SummonerDTO
+	DeleteCalls    []string
+	GetBetweenArgs []int64
+}
+
+func (s *SummonersServiceMock) summoner(region string, name string) (*shared.SummonerDTO, error) {
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	if s.NotFound {
+		return nil, fmt.Errorf("summoner not found")
+	}
+
+	return &shared.SummonerDTO{Name: strings.ToLower(name), Region: region, AccountID: "aid", Puuid: "puuid", Level: 30}, nil
+}
+
+func (s *SummonersServiceMock) Get(region string, name string) (*shared.SummonerDTO, error) {
+	s.GetCalls = append(s.GetCalls, region+"#"+name)
+	return s.summoner(region, name)
+}
+
+func (s *SummonersServiceMock) Fetch(region string, name string) (*shared.SummonerDTO, error) {
+	s.FetchCalls = append(s.FetchCalls, region+"#"+name)
+	return s.summoner(region, name)
+}
+
+func (s *SummonersServiceMock) Save(summoner *shared.SummonerDTO) error {
+	s.SaveCalls = append(s.SaveCalls, summoner)
+	return nil
+}
+
+func (s *SummonersServiceMock) Delete(region string, name string) (bool, error) {
+	s.DeleteCalls = append(s.DeleteCalls, region+"#"+name)
+	return true, nil
+}
+
+func (s *SummonersServiceMock) GetBetweenDate(region string, _ int32, start int64, end int64) (*shared.SummonersPage, error) {
+	s.GetBetweenArgs = append(s.GetBetweenArgs, start, end)
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &shared.SummonersPage{Summoners: []*shared.SummonerDTO{{Name: "test", Region: region}}}, nil
+}
+
+func (s *SummonersServiceMock) Count(_ string, _ int64, _ int64) (int, error) {
+	if s.ShouldFail {
+		return 0, fmt.Errorf("error")
+	}
+
+	return 2, nil
+}
+
+func (s *SummonersServiceMock) RiotBudgetUsage() (*shared.BudgetUsage, error) {
+	return &shared.BudgetUsage{Limit: 100, InteractiveUsed: 3, BackgroundUsed: 4, BackgroundAllowance: 50}, nil
+}
+
+type QueueServiceMock struct {
+	Sent []string
+}
+
+func (q *QueueServiceMock) Send(_ context.Context, region string, name string) error {
+	q.Sent = append(q.Sent, region+"#"+name)
+	return nil
+}
+
+func TestLookup_ReadsSummonerFromTable(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"lookup", "-region", "na", "hide", "on", "bush"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	mock := summoners.(*SummonersServiceMock)
+	if len(mock.GetCalls) != 1 || mock.GetCalls[0] != "NA#hide on bush" || len(mock.FetchCalls) != 0 {
+		t.Errorf("expected one table lookup, got %v", mock.GetCalls)
+	}
+
+	if !strings.Contains(stdout.String(), "NAME") || !strings.Contains(stdout.String(), "hide on bush  NA") {
+		t.Errorf("expected summoner table, got %s", stdout.String())
+	}
+}
+
+func TestLookup_FetchesLiveSummonerAsJSON(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"lookup", "test", "-region", "euw", "-live", "-output", "json"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(summoners.(*SummonersServiceMock).FetchCalls) != 1 {
+		t.Errorf("expected a live lookup")
+	}
+
+	if !strings.Contains(stdout.String(), `"region": "EUW"`) {
+		t.Errorf("expected summoner JSON, got %s", stdout.String())
+	}
+}
+
+func TestLookup_RequiresRegionAndName(t *testing.T) {
+	stdout := setup()
+
+	if err := run(context.TODO(), []string{"lookup", "test"}, stdout); err == nil {
+		t.Errorf("expected error without region, got nil")
+	}
+
+	if err := run(context.TODO(), []string{"lookup", "-region", "NA"}, stdout); err == nil {
+		t.Errorf("expected error without name, got nil")
+	}
+}
+
+func TestRefresh_SavesFetchedSummoner(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"refresh", "-region", "NA", "test"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(summoners.(*SummonersServiceMock).SaveCalls) != 1 {
+		t.Errorf("expected summoner to be saved")
+	}
+}
+
+func TestRefresh_DeletesSummonerRiotDoesNotKnow(t *testing.T) {
+	stdout := setup()
+	summoners.(*SummonersServiceMock).NotFound = true
+
+	err := run(context.TODO(), []string{"refresh", "-region", "NA", "test"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	mock := summoners.(*SummonersServiceMock)
+	if len(mock.DeleteCalls) != 1 || len(mock.SaveCalls) != 0 {
+		t.Errorf("expected summoner to be deleted, got %v", mock.DeleteCalls)
+	}
+}
+
+func TestDelete_DeletesSummoner(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"delete", "-region", "NA", "test"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if calls := summoners.(*SummonersServiceMock).DeleteCalls; len(calls) != 1 || calls[0] != "NA#test" {
+		t.Errorf("expected NA#test to be deleted, got %v", calls)
+	}
+}
+
+func TestEnqueue_SendsEveryRegion(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"enqueue", "-from", "1", "-to", "2"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	sent := queue.(*QueueServiceMock).Sent
+	if len(sent) != len(shared.NewRegions().GetAll()) || sent[0] != "EUNE#test" {
+		t.Errorf("expected one message per region, got %v", sent)
+	}
+
+	if !strings.Contains(stdout.String(), "REGION") {
+		t.Errorf("expected table output, got %s", stdout.String())
+	}
+}
+
+func TestEnqueue_DryRunForOneRegion(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"enqueue", "-region", "na", "-from", "2024-01-01", "-to", "2024-01-02", "-dry-run", "-output", "json"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	args := summoners.(*SummonersServiceMock).GetBetweenArgs
+	if len(args) != 2 || args[0] != 1704067200000 || args[1] != 1704153600000 {
+		t.Errorf("expected one query for the date range, got %v", args)
+	}
+
+	if len(queue.(*QueueServiceMock).Sent) != 0 {
+		t.Errorf("expected nothing to be sent on dry run")
+	}
+
+	if !strings.Contains(stdout.String(), `"enqueued": 1`) {
+		t.Errorf("expected result JSON, got %s", stdout.String())
+	}
+}
+
+func TestEnqueue_RequiresDateRangeAndQueue(t *testing.T) {
+	stdout := setup()
+
+	if err := run(context.TODO(), []string{"enqueue", "-from", "1"}, stdout); err == nil {
+		t.Errorf("expected error without -to, got nil")
+	}
+
+	queue = nil
+	if err := run(context.TODO(), []string{"enqueue", "-from", "1", "-to", "2"}, stdout); err == nil {
+		t.Errorf("expected error without a queue, got nil")
+	}
+}
+
+func TestStats_PrintsCountsAndBudget(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"stats", "-output", "json"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !strings.Contains(stdout.String(), `"total": 2`) || !strings.Contains(stdout.String(), `"backgroundUsed": 4`) {
+		t.Errorf("expected counts and budget, got %s", stdout.String())
+	}
+}
+
+func TestStats_ReturnsErrorForUnknownOutput(t *testing.T) {
+	stdout := setup()
+
+	if err := run(context.TODO(), []string{"stats", "-output", "xml"}, stdout); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"io"
+	"log"
+	"text/tabwriter"
+)
+
+func manageBlocklist(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+
+	switch args[0] {
+	case "list":
+		return listBlockRules(ctx, args[1:], stdout)
+	case "add", "remove":
+		return changeBlockRule(ctx, args[0], args[1:])
+	case "check":
+		return checkBlocklist(ctx, args[1:], stdout)
+	}
+
+	return fmt.Errorf("unknown blocklist command '%s'\n%s", args[0], usage)
+}
+
+func listBlockRules(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("blocklist list", flag.ContinueOnError)
+	output := flags.String("output", outputTable, "output format, table or json")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	rules, err := blocklist.Rules(ctx)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(stdout, *output, rules, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "KIND\tVALUE\tREGION\tREASON\tADDED")
+		for _, rule := range rules {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", rule.Kind, rule.Value, rule.Region, rule.Reason, formatMillis(rule.AddedAt))
+		}
+	})
+}
+
+func changeBlockRule(ctx context.Context, command string, args []string) error {
+	flags := flag.NewFlagSet("blocklist "+command, flag.ContinueOnError)
+	kind := flags.String("kind", shared.BlockNormalized, "rule kind: exact, normalized, pattern or word")
+	region := flags.String("region", "", "only apply the rule to this region")
+	reason := flags.String("reason", "", "why the rule was added")
+	value, err := parseNameArgs(flags, args)
+	if err != nil {
+		return err
+	}
+
+	rule := shared.BlockRule{Kind: *kind, Value: value, Reason: *reason}
+	if *region != "" {
+		if rule.Region, err = parseRegion(*region); err != nil {
+			return err
+		}
+	}
+
+	if command == "add" {
+		if err = blocklist.AddRule(ctx, rule); err != nil {
+			return err
+		}
+
+		log.Printf("added %s rule '%s'", rule.Kind, rule.Value)
+		return nil
+	}
+
+	if err = blocklist.RemoveRule(ctx, rule); err != nil {
+		return err
+	}
+
+	log.Printf("removed %s rule '%s'", rule.Kind, rule.Value)
+	return nil
+}
+
+func checkBlocklist(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("blocklist check", flag.ContinueOnError)
+	region := flags.String("region", "", "region of the summoner")
+	output := flags.String("output", outputTable, "output format, table or json")
+	name, err := parseNameArgs(flags, args)
+	if err != nil {
+		return err
+	}
+
+	r, err := parseRegion(*region)
+	if err != nil {
+		return err
+	}
+
+	match, err := blocklist.Match(ctx, r, name)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(stdout, *output, match, func(w *tabwriter.Writer) {
+		if match == nil {
+			fmt.Fprintf(w, "'%s' is not blocked\n", name)
+			return
+		}
+
+		fmt.Fprintf(w, "'%s' is blocked by %s rule '%s'\n", name, match.Rule.Kind, match.Term)
+	})
+}
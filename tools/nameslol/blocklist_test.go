@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"strings"
+	"testing"
+)
+
+type BlocklistServiceMock struct {
+	ShouldFail bool
+	Added      []shared.BlockRule
+	Removed    []shared.BlockRule
+}
+
+func (b *BlocklistServiceMock) Rules(_ context.Context) ([]shared.BlockRule, error) {
+	if b.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return []shared.BlockRule{{Kind: shared.BlockPattern, Value: "^bad", Reason: "spam"}}, nil
+}
+
+func (b *BlocklistServiceMock) AddRule(_ context.Context, rule shared.BlockRule) error {
+	b.Added = append(b.Added, rule)
+	return nil
+}
+
+func (b *BlocklistServiceMock) RemoveRule(_ context.Context, rule shared.BlockRule) error {
+	b.Removed = append(b.Removed, rule)
+	return nil
+}
+
+func (b *BlocklistServiceMock) Match(_ context.Context, _ string, name string) (*shared.BlockMatch, error) {
+	if strings.HasPrefix(name, "bad") {
+		return &shared.BlockMatch{Rule: shared.BlockRule{Kind: shared.BlockPattern, Value: "^bad"}, Term: "^bad"}, nil
+	}
+
+	return nil, nil
+}
+
+func TestBlocklist_ListsRules(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"blocklist", "list"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !strings.Contains(stdout.String(), "pattern") || !strings.Contains(stdout.String(), "spam") {
+		t.Errorf("expected rule table, got %s", stdout.String())
+	}
+}
+
+func TestBlocklist_AddsRuleWithFlags(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"blocklist", "add", "-kind", "exact", "-region", "euw", "-reason", "harassment", "some", "name"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	added := blocklist.(*BlocklistServiceMock).Added
+	expected := shared.BlockRule{Kind: shared.BlockExact, Value: "some name", Region: "EUW", Reason: "harassment"}
+	if len(added) != 1 || added[0] != expected {
+		t.Errorf("expected %v, got %v", expected, added)
+	}
+}
+
+func TestBlocklist_RemovesRule(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"blocklist", "remove", "-kind", "word", "rude"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if removed := blocklist.(*BlocklistServiceMock).Removed; len(removed) != 1 || removed[0].Value != "rude" {
+		t.Errorf("expected rule to be removed, got %v", removed)
+	}
+}
+
+func TestBlocklist_ChecksName(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"blocklist", "check", "-region", "NA", "badname"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !strings.Contains(stdout.String(), "is blocked by pattern rule") {
+		t.Errorf("expected match in output, got %s", stdout.String())
+	}
+}
+
+func TestBlocklist_ReturnsErrorForUnknownSubcommand(t *testing.T) {
+	stdout := setup()
+
+	if err := run(context.TODO(), []string{"blocklist", "purge"}, stdout); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
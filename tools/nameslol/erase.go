@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"io"
+	"log"
+)
+
+// erase removes a player's data at their request. The player can be identified by PUUID, account ID, or
+// by a name still in the table, whose identifiers are then used.
+func erase(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("erase", flag.ContinueOnError)
+	puuid := flags.String("puuid", "", "PUUID of the player")
+	accountId := flags.String("account", "", "account ID of the player")
+	region := flags.String("region", "", "region of the name to look the player up by")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	request := shared.ErasureRequest{Puuid: *puuid, AccountID: *accountId}
+	if flags.NArg() > 0 {
+		name, err := parseNameArgs(flags, flags.Args())
+		if err != nil {
+			return err
+		}
+
+		r, err := parseRegion(*region)
+		if err != nil {
+			return err
+		}
+
+		summoner, err := summoners.Get(r, name)
+		if err != nil {
+			return fmt.Errorf("could not look up '%s' in region '%s': %v", name, r, err)
+		}
+
+		if request.Puuid == "" {
+			request.Puuid = summoner.Puuid
+		}
+
+		if request.AccountID == "" {
+			request.AccountID = summoner.AccountID
+		}
+	}
+
+	if request.Puuid == "" && request.AccountID == "" {
+		return fmt.Errorf("usage: nameslol erase -puuid P | -account A | -region R name")
+	}
+
+	result, err := erasure.Erase(ctx, request)
+	if result != nil {
+		if writeErr := writeJSON(stdout, result); writeErr != nil {
+			return writeErr
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Printf("erased %d summoners, recorded %d suppression entries and scrubbed %d audit entries", len(result.Deleted), result.Suppressed, result.Scrubbed)
+	return nil
+}
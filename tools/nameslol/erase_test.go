@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"github.com/bricefrisco/nameslol/shared"
+	"strings"
+	"testing"
+)
+
+type ErasureServiceMock struct {
+	Requests []shared.ErasureRequest
+}
+
+func (e *ErasureServiceMock) Erase(_ context.Context, request shared.ErasureRequest) (*shared.ErasureResult, error) {
+	e.Requests = append(e.Requests, request)
+	return &shared.ErasureResult{Deleted: []string{"NA#TEST"}, Suppressed: 2}, nil
+}
+
+func TestErase_ErasesByIdentifiers(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"erase", "-puuid", "p", "-account", "a"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	requests := erasure.(*ErasureServiceMock).Requests
+	if len(requests) != 1 || requests[0] != (shared.ErasureRequest{Puuid: "p", AccountID: "a"}) {
+		t.Errorf("unexpected requests %v", requests)
+	}
+
+	if !strings.Contains(stdout.String(), "NA#TEST") {
+		t.Errorf("expected result in output, got %s", stdout.String())
+	}
+}
+
+func TestErase_LooksUpIdentifiersByName(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"erase", "-region", "na", "some", "name"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if calls := summoners.(*SummonersServiceMock).GetCalls; len(calls) != 1 || calls[0] != "NA#some name" {
+		t.Errorf("expected name to be looked up, got %v", calls)
+	}
+
+	requests := erasure.(*ErasureServiceMock).Requests
+	if len(requests) != 1 || requests[0] != (shared.ErasureRequest{Puuid: "puuid", AccountID: "aid"}) {
+		t.Errorf("unexpected requests %v", requests)
+	}
+}
+
+func TestErase_FailsWhenNameIsUnknown(t *testing.T) {
+	stdout := setup()
+	summoners.(*SummonersServiceMock).NotFound = true
+
+	if err := run(context.TODO(), []string{"erase", "-region", "NA", "test"}, stdout); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if len(erasure.(*ErasureServiceMock).Requests) != 0 {
+		t.Errorf("expected nothing to be erased")
+	}
+}
+
+func TestErase_RequiresAnIdentifier(t *testing.T) {
+	stdout := setup()
+
+	if err := run(context.TODO(), []string{"erase"}, stdout); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
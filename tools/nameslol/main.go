@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/bricefrisco/nameslol/shared"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type migratorService interface {
+	Status(ctx context.Context) (*shared.MigrationState, error)
+	Plan(ctx context.Context) ([]shared.Migration, error)
+	Apply(ctx context.Context, target int, dryRun bool) ([]*shared.MigrationResult, error)
+}
+
+type snapshotsService interface {
+	Export(ctx context.Context, filter shared.SnapshotFilter, write func(*shared.SummonerDTO) error) (*shared.ExportResult, error)
+	Import(ctx context.Context, read func() (*shared.SummonerDTO, error)) (*shared.ImportResult, error)
+}
+
+type summonersService interface {
+	Get(region string, name string) (*shared.SummonerDTO, error)
+	Fetch(region string, name string) (*shared.SummonerDTO, error)
+	Save(summoner *shared.SummonerDTO) error
+	Delete(region string, name string) (bool, error)
+	GetBetweenDate(region string, limit int32, start int64, end int64) (*shared.SummonersPage, error)
+	Count(region string, start int64, end int64) (int, error)
+	RiotBudgetUsage() (*shared.BudgetUsage, error)
+}
+
+type blocklistService interface {
+	Rules(ctx context.Context) ([]shared.BlockRule, error)
+	AddRule(ctx context.Context, rule shared.BlockRule) error
+	RemoveRule(ctx context.Context, rule shared.BlockRule) error
+	Match(ctx context.Context, region string, name string) (*shared.BlockMatch, error)
+}
+
+type erasureService interface {
+	Erase(ctx context.Context, request shared.ErasureRequest) (*shared.ErasureResult, error)
+}
+
+type webhooksService interface {
+	Endpoints(ctx context.Context) ([]shared.WebhookEndpoint, error)
+	Register(ctx context.Context, endpoint shared.WebhookEndpoint) (*shared.WebhookEndpoint, error)
+	Remove(ctx context.Context, id string) error
+	Log(ctx context.Context, id string) ([]shared.WebhookLogEntry, error)
+	DeadLetters(ctx context.Context, id string) ([]shared.WebhookDeadLetter, error)
+	Redrive(ctx context.Context, id string) (int, error)
+}
+
+type revocationsService interface {
+	Revoke(ctx context.Context, key string) error
+}
+
+type queueService interface {
+	Send(ctx context.Context, region string, name string) error
+}
+
+var migrator migratorService
+var snapshots snapshotsService
+var summoners summonersService
+var queue queueService
+var blocklist blocklistService
+var erasure erasureService
+var webhooks webhooksService
+var revocations revocationsService
+
+const usage = `usage: nameslol <command> [flags]
+
+commands:
+  migrate plan                       list the migrations that have not been applied
+  migrate apply [-to N] [-dry-run]   apply pending migrations, resuming an interrupted run
+  migrate status                     show the migration state stored in the table
+  export [-region R] [-from T] [-to T] [-format jsonl|parquet] [-o file]
+                                     write summoners to a snapshot, optionally limited to a region
+                                     and availability date range (YYYY-MM-DD or unix milliseconds)
+  import [-format jsonl|parquet] [-i file]
+                                     write a snapshot's summoners into the table
+  lookup -region R [-live] name      show a summoner from the table, or from the Riot API with -live
+  refresh -region R name             fetch a summoner from the Riot API and save it, deleting it if
+                                     Riot no longer knows the name
+  delete -region R name              delete a summoner from the table
+  enqueue [-region R] -from T -to T [-limit N] [-dry-run]
+                                     send summoners available in the date range to the name update
+                                     queue, for every region unless one is given
+  stats [-days N]                    show summoner counts per region and the Riot API budget usage
+  blocklist list                     list the rules hiding names from the public listings
+  blocklist add|remove [-kind exact|normalized|pattern|word] [-region R] [-reason S] value
+                                     add or remove a blocklist rule (default kind normalized)
+  blocklist check -region R name     show which rule, if any, blocks a name
+  erase -puuid P | -account A | -region R name
+                                     delete every name a player has held and stop them from being
+                                     saved again, e.g. when the player asks to be removed
+  webhooks list                      list the endpoints name events are delivered to
+  webhooks add [-events E] [-regions R] [-description S] url
+                                     register an endpoint and print its signing secret, delivering
+                                     every event from every region unless limited
+  webhooks remove id                 stop delivering events to an endpoint
+  webhooks log|dead id               show an endpoint's recent delivery attempts or dead letters
+  webhooks redrive id                queue an endpoint's dead-lettered deliveries again
+  admin-key [-days N] operator       sign an API key for the admin API with ADMIN_AUTH_SECRET, valid
+                                     for N days (default 90)
+  admin-key -revoke key              stop an admin API key from authenticating before it expires
+  vapid-keys                         generate a VAPID key pair for push notifications: the private
+                                     key belongs in /vapid-private-key, the public key in the site
+
+  lookup, refresh, enqueue, stats, blocklist list, blocklist check and webhooks list, add, log
+  and dead take -output table|json (default table).
+
+environment:
+  DYNAMODB_TABLE      table name (default "nameslol")
+  DYNAMODB_ENDPOINT   endpoint override, e.g. http://localhost:8000 for DynamoDB Local
+  QUEUE_URL           name update queue used by enqueue
+  WEBHOOK_QUEUE_URL   webhook delivery queue used by webhooks redrive
+  ADMIN_AUTH_SECRET   admin API signing secret used by admin-key`
+
+type migrationPlan struct {
+	State   *shared.MigrationState `json:"state"`
+	Pending []shared.Migration     `json:"pending"`
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+
+	switch args[0] {
+	case "migrate":
+		return migrate(ctx, args[1:], stdout)
+	case "export":
+		return export(ctx, args[1:], stdout)
+	case "import":
+		return importSnapshot(ctx, args[1:], os.Stdin, stdout)
+	case "lookup":
+		return lookup(args[1:], stdout)
+	case "refresh":
+		return refresh(args[1:], stdout)
+	case "delete":
+		return deleteSummoner(args[1:])
+	case "enqueue":
+		return enqueue(ctx, args[1:], stdout)
+	case "stats":
+		return showStats(args[1:], stdout)
+	case "blocklist":
+		return manageBlocklist(ctx, args[1:], stdout)
+	case "webhooks":
+		return manageWebhooks(ctx, args[1:], stdout)
+	case "erase":
+		return erase(ctx, args[1:], stdout)
+	case "admin-key":
+		return adminKey(ctx, args[1:], stdout)
+	case "vapid-keys":
+		return vapidKeys(stdout)
+	}
+
+	return fmt.Errorf("unknown command '%s'\n%s", args[0], usage)
+}
+
+func migrate(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+
+	switch args[0] {
+	case "plan":
+		return migratePlan(ctx, stdout)
+	case "apply":
+		return migrateApply(ctx, args[1:], stdout)
+	case "status":
+		state, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		return writeJSON(stdout, state)
+	}
+
+	return fmt.Errorf("unknown migrate command '%s'\n%s", args[0], usage)
+}
+
+func migratePlan(ctx context.Context, stdout io.Writer) error {
+	state, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending, err := migrator.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(stdout, &migrationPlan{State: state, Pending: pending})
+}
+
+func migrateApply(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("migrate apply", flag.ContinueOnError)
+	target := flags.Int("to", 0, "apply migrations up to and including this version")
+	dryRun := flags.Bool("dry-run", false, "scan and count the items the next migration would rewrite")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	results, err := migrator.Apply(ctx, *target, *dryRun)
+	if writeErr := writeJSON(stdout, results); writeErr != nil {
+		return writeErr
+	}
+
+	return err
+}
+
+func export(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	region := flags.String("region", "", "only export this region")
+	from := flags.String("from", "", "only export summoners available from this date")
+	to := flags.String("to", "", "only export summoners available until this date")
+	format := flags.String("format", formatJSONL, "snapshot format, jsonl or parquet")
+	output := flags.String("o", "", "write the snapshot to this file instead of stdout")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	filter := shared.SnapshotFilter{Region: strings.ToUpper(*region)}
+	if filter.Region != "" && !shared.NewRegions().Validate(filter.Region) {
+		return fmt.Errorf("invalid region '%s'", *region)
+	}
+
+	if filter.From, err = parseTimestamp(*from); err != nil {
+		return err
+	}
+
+	if filter.To, err = parseTimestamp(*to); err != nil {
+		return err
+	}
+
+	w := stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	writer, err := newSummonerWriter(*format, w)
+	if err != nil {
+		return err
+	}
+
+	result, err := snapshots.Export(ctx, filter, writer.Write)
+	if err != nil {
+		return err
+	}
+
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	for _, skipped := range result.Skipped {
+		log.Printf("skipped malformed summoner '%s': %s", skipped.Key, skipped.Reason)
+	}
+
+	log.Printf("exported %d summoners", result.Exported)
+	return nil
+}
+
+func importSnapshot(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", formatJSONL, "snapshot format, jsonl or parquet")
+	input := flags.String("i", "", "read the snapshot from this file instead of stdin")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	r := stdin
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	read, err := newSummonerReader(*format, r)
+	if err != nil {
+		return err
+	}
+
+	result, err := snapshots.Import(ctx, read)
+	if result != nil {
+		if writeErr := writeJSON(stdout, result); writeErr != nil {
+			return writeErr
+		}
+	}
+
+	return err
+}
+
+func adminKey(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("admin-key", flag.ContinueOnError)
+	days := flags.Int("days", 90, "number of days the key is valid for")
+	revoke := flags.String("revoke", "", "key to revoke")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *revoke != "" {
+		if err := revocations.Revoke(ctx, *revoke); err != nil {
+			return err
+		}
+
+		_, err := fmt.Fprintln(stdout, "key revoked")
+		return err
+	}
+
+	if flags.NArg() != 1 || *days <= 0 {
+		return fmt.Errorf("usage: nameslol admin-key [-days N] operator")
+	}
+
+	secret := os.Getenv("ADMIN_AUTH_SECRET")
+	if secret == "" {
+		return fmt.Errorf("ADMIN_AUTH_SECRET is not set")
+	}
+
+	key, err := shared.NewAdminApiKey(secret, flags.Arg(0), time.Now().AddDate(0, 0, *days))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(stdout, key)
+	return err
+}
+
+func vapidKeys(stdout io.Writer) error {
+	privateKey, publicKey, err := shared.GenerateVAPIDKeys()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(stdout, "private key: %s\npublic key:  %s\n", privateKey, publicKey)
+	return err
+}
+
+// parseTimestamp accepts a YYYY-MM-DD date in UTC or unix milliseconds. An empty value is 0.
+func parseTimestamp(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date.UnixMilli(), nil
+	}
+
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date '%s', expected YYYY-MM-DD or unix milliseconds", value)
+	}
+
+	return millis, nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func main() {
+	log.SetFlags(0)
+
+	tableName := os.Getenv("DYNAMODB_TABLE")
+	if tableName == "" {
+		tableName = "nameslol"
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		log.Fatalf("could not load AWS config, %v", err)
+	}
+
+	client := shared.NewDynamoDbClient(cfg)
+	search := shared.NewNameSearch(client, tableName)
+
+	m := shared.NewMigrator(client, tableName, shared.Migrations)
+	m.IndexNames(search)
+	migrator = m
+	sn := shared.NewSnapshots(client, tableName)
+	sn.IndexNames(search)
+	snapshots = sn
+	blocklist = shared.NewBlocklist(client, tableName, nil)
+	e := shared.NewErasure(client, tableName)
+	e.IndexNames(search)
+	erasure = e
+	webhooks = shared.NewWebhooks(client, tableName, sqs.NewFromConfig(cfg), os.Getenv("WEBHOOK_QUEUE_URL"))
+	revocations = shared.NewAdminKeyRevocations(client, tableName)
+
+	s, err := shared.NewSummoners(tableName, shared.WorkloadBackground)
+	if err != nil {
+		log.Fatalf("could not create summoners service, %v", err)
+	}
+	s.IndexNames(search)
+	summoners = s
+
+	if queueUrl := os.Getenv("QUEUE_URL"); queueUrl != "" {
+		queue = shared.NewNameUpdateQueue(sqs.NewFromConfig(cfg), queueUrl)
+	}
+
+	err = run(context.Background(), os.Args[1:], os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
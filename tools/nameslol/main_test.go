@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type MigratorServiceMock struct {
+	ShouldFail bool
+	ApplyCalls []struct {
+		Target int
+		DryRun bool
+	}
+}
+
+func (m *MigratorServiceMock) Status(_ context.Context) (*shared.MigrationState, error) {
+	if m.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return &shared.MigrationState{Version: 1}, nil
+}
+
+func (m *MigratorServiceMock) Plan(_ context.Context) ([]shared.Migration, error) {
+	if m.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return []shared.Migration{{Version: 2, Name: "upgrade-items-to-v1"}}, nil
+}
+
+func (m *MigratorServiceMock) Apply(_ context.Context, target int, dryRun bool) ([]*shared.MigrationResult, error) {
+	m.ApplyCalls = append(m.ApplyCalls, struct {
+		Target int
+		DryRun bool
+	}{target, dryRun})
+
+	if m.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	return []*shared.MigrationResult{{Version: 2, Name: "upgrade-items-to-v1", Scanned: 2, Rewritten: 1, Unchanged: 1}}, nil
+}
+
+type SnapshotsServiceMock struct {
+	ShouldFail  bool
+	ExportCalls []shared.SnapshotFilter
+	Imported    []*shared.SummonerDTO
+}
+
+func (s *SnapshotsServiceMock) Export(_ context.Context, filter shared.SnapshotFilter, write func(*shared.SummonerDTO) error) (*shared.ExportResult, error) {
+	s.ExportCalls = append(s.ExportCalls, filter)
+
+	if s.ShouldFail {
+		return nil, fmt.Errorf("error")
+	}
+
+	err := write(&shared.SummonerDTO{Name: "test", Region: "NA"})
+	return &shared.ExportResult{Exported: 1}, err
+}
+
+func (s *SnapshotsServiceMock) Import(_ context.Context, read func() (*shared.SummonerDTO, error)) (*shared.ImportResult, error) {
+	for {
+		summoner, err := read()
+		if errors.Is(err, io.EOF) {
+			return &shared.ImportResult{Imported: len(s.Imported)}, nil
+		}
+
+		if err != nil {
+			return &shared.ImportResult{Imported: len(s.Imported)}, err
+		}
+
+		s.Imported = append(s.Imported, summoner)
+	}
+}
+
+type RevocationsServiceMock struct {
+	Revoked []string
+}
+
+func (r *RevocationsServiceMock) Revoke(_ context.Context, key string) error {
+	if strings.Count(key, ".") != 2 {
+		return fmt.Errorf("malformed api key")
+	}
+
+	r.Revoked = append(r.Revoked, key)
+	return nil
+}
+
+func setup() *bytes.Buffer {
+	migrator = &MigratorServiceMock{}
+	snapshots = &SnapshotsServiceMock{}
+	summoners = &SummonersServiceMock{}
+	queue = &QueueServiceMock{}
+	blocklist = &BlocklistServiceMock{}
+	erasure = &ErasureServiceMock{}
+	webhooks = &WebhooksServiceMock{}
+	revocations = &RevocationsServiceMock{}
+	return &bytes.Buffer{}
+}
+
+func TestRun_ReturnsUsageWithoutCommand(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{}, stdout)
+	if err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("expected usage error, got %v", err)
+	}
+}
+
+func TestRun_ReturnsErrorForUnknownCommand(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"unknown"}, stdout)
+	if err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("expected unknown command error, got %v", err)
+	}
+}
+
+func TestMigrate_ReturnsErrorForUnknownSubcommand(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"migrate", "unknown"}, stdout)
+	if err == nil || !strings.Contains(err.Error(), "unknown migrate command") {
+		t.Errorf("expected unknown migrate command error, got %v", err)
+	}
+}
+
+func TestMigratePlan_PrintsStateAndPendingMigrations(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"migrate", "plan"}, stdout)
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if !strings.Contains(stdout.String(), `"version": 1`) || !strings.Contains(stdout.String(), `"name": "upgrade-items-to-v1"`) {
+		t.Errorf("expected state and pending migrations in output, got %s", stdout.String())
+	}
+}
+
+func TestMigrateApply_PassesFlags(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"migrate", "apply", "-to", "2", "-dry-run"}, stdout)
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	calls := migrator.(*MigratorServiceMock).ApplyCalls
+	if len(calls) != 1 || calls[0].Target != 2 || !calls[0].DryRun {
+		t.Errorf("expected one dry run call up to version 2, got %v", calls)
+	}
+}
+
+func TestMigrateApply_PrintsResults(t *testing.T) {
+	stdout := setup()
+
+	_ = run(context.TODO(), []string{"migrate", "apply"}, stdout)
+
+	if !strings.Contains(stdout.String(), `"rewritten": 1`) {
+		t.Errorf("expected result in output, got %s", stdout.String())
+	}
+}
+
+func TestMigrateApply_ReturnsErrorWhenApplyFails(t *testing.T) {
+	stdout := setup()
+	migrator.(*MigratorServiceMock).ShouldFail = true
+
+	err := run(context.TODO(), []string{"migrate", "apply"}, stdout)
+	if err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestMigrateStatus_PrintsState(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"migrate", "status"}, stdout)
+	if err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	if !strings.Contains(stdout.String(), `"version": 1`) {
+		t.Errorf("expected state in output, got %s", stdout.String())
+	}
+}
+
+func TestExport_PassesFilter(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"export", "-region", "euw", "-from", "2024-01-01", "-to", "1704153600000"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	calls := snapshots.(*SnapshotsServiceMock).ExportCalls
+	expected := shared.SnapshotFilter{Region: "EUW", From: 1704067200000, To: 1704153600000}
+	if len(calls) != 1 || calls[0] != expected {
+		t.Errorf("expected %v, got %v", expected, calls)
+	}
+
+	if stdout.String() != "{\"name\":\"test\",\"region\":\"NA\",\"accountId\":\"\",\"revisionDate\":0,\"availabilityDate\":0,\"level\":0,\"lastUpdated\":0,\"summonerIcon\":0,\"score\":0}\n" {
+		t.Errorf("expected summoner on stdout, got %s", stdout.String())
+	}
+}
+
+func TestExport_RejectsInvalidRegionAndDates(t *testing.T) {
+	stdout := setup()
+
+	if err := run(context.TODO(), []string{"export", "-region", "mars"}, stdout); err == nil {
+		t.Errorf("expected error for invalid region, got nil")
+	}
+
+	if err := run(context.TODO(), []string{"export", "-from", "yesterday"}, stdout); err == nil {
+		t.Errorf("expected error for invalid date, got nil")
+	}
+}
+
+func TestExportAndImport_RoundTripParquetFile(t *testing.T) {
+	stdout := setup()
+	path := filepath.Join(t.TempDir(), "snapshot.parquet")
+
+	err := run(context.TODO(), []string{"export", "-format", "parquet", "-o", path}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	err = run(context.TODO(), []string{"import", "-format", "parquet", "-i", path}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	imported := snapshots.(*SnapshotsServiceMock).Imported
+	if len(imported) != 1 || imported[0].Name != "test" {
+		t.Errorf("expected exported summoner to be imported, got %v", imported)
+	}
+
+	if !strings.Contains(stdout.String(), `"imported": 1`) {
+		t.Errorf("expected import result in output, got %s", stdout.String())
+	}
+}
+
+func TestImport_ReadsJSONLFromStdin(t *testing.T) {
+	stdout := setup()
+
+	err := importSnapshot(context.TODO(), []string{}, strings.NewReader("{\"name\":\"test\",\"region\":\"NA\"}\n"), stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if len(snapshots.(*SnapshotsServiceMock).Imported) != 1 {
+		t.Errorf("expected 1 imported summoner")
+	}
+}
+
+func TestAdminKey_SignsKeyForOperator(t *testing.T) {
+	stdout := setup()
+	t.Setenv("ADMIN_AUTH_SECRET", "test-secret")
+
+	err := run(context.TODO(), []string{"admin-key", "brice"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	auth := shared.NewAdminAuth(shared.StaticSecretsSource{"secret": "test-secret"}, "secret", "")
+	principal, err := auth.Authenticate(context.TODO(), map[string]string{"x-api-key": strings.TrimSpace(stdout.String())})
+	if err != nil || principal.Subject != "brice" {
+		t.Errorf("expected a valid key for brice, got %v, %v", principal, err)
+	}
+}
+
+func TestAdminKey_RejectsNonPositiveDays(t *testing.T) {
+	stdout := setup()
+	t.Setenv("ADMIN_AUTH_SECRET", "test-secret")
+
+	if err := run(context.TODO(), []string{"admin-key", "-days", "0", "brice"}, stdout); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestAdminKey_RevokesKey(t *testing.T) {
+	stdout := setup()
+
+	if err := run(context.TODO(), []string{"admin-key", "-revoke", "brice.1700000060.signature"}, stdout); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if revoked := revocations.(*RevocationsServiceMock).Revoked; len(revoked) != 1 || revoked[0] != "brice.1700000060.signature" {
+		t.Errorf("expected key to be revoked, got %v", revoked)
+	}
+}
+
+func TestAdminKey_RequiresSecret(t *testing.T) {
+	stdout := setup()
+	t.Setenv("ADMIN_AUTH_SECRET", "")
+
+	if err := run(context.TODO(), []string{"admin-key", "brice"}, stdout); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestVapidKeys_PrintsUsableKeyPair(t *testing.T) {
+	stdout := setup()
+
+	if err := run(context.TODO(), []string{"vapid-keys"}, stdout); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	privateKey := strings.TrimSpace(strings.TrimPrefix(lines[0], "private key:"))
+	publicKey := strings.TrimSpace(strings.TrimPrefix(lines[1], "public key:"))
+
+	pusher, err := shared.NewWebPush(privateKey, "mailto:alerts@names.lol")
+	if err != nil || pusher.PublicKey() != publicKey {
+		t.Errorf("expected the printed keys to be a pair, got %s", stdout.String())
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"github.com/parquet-go/parquet-go"
+	"io"
+	"strings"
+)
+
+// Snapshots are written one summoner per row using the SummonerDTO encoding, either as JSON lines or
+// as a Parquet file for loading into analytics tools.
+const (
+	formatJSONL   = "jsonl"
+	formatParquet = "parquet"
+)
+
+type summonerWriter interface {
+	Write(summoner *shared.SummonerDTO) error
+	Close() error
+}
+
+func newSummonerWriter(format string, w io.Writer) (summonerWriter, error) {
+	switch format {
+	case formatJSONL:
+		return &jsonlWriter{encoder: json.NewEncoder(w)}, nil
+	case formatParquet:
+		return &parquetWriter{writer: parquet.NewGenericWriter[shared.SummonerDTO](w)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown format '%s', expected %s or %s", format, formatJSONL, formatParquet)
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (j *jsonlWriter) Write(summoner *shared.SummonerDTO) error {
+	return j.encoder.Encode(summoner)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
+
+// parquetWriter buffers rows so they are written to the file in row groups rather than one at a time.
+type parquetWriter struct {
+	writer *parquet.GenericWriter[shared.SummonerDTO]
+	rows   []shared.SummonerDTO
+}
+
+func (p *parquetWriter) Write(summoner *shared.SummonerDTO) error {
+	p.rows = append(p.rows, *summoner)
+	if len(p.rows) < 10000 {
+		return nil
+	}
+
+	return p.flush()
+}
+
+func (p *parquetWriter) flush() error {
+	_, err := p.writer.Write(p.rows)
+	p.rows = p.rows[:0]
+	return err
+}
+
+func (p *parquetWriter) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+
+	return p.writer.Close()
+}
+
+func newSummonerReader(format string, r io.Reader) (func() (*shared.SummonerDTO, error), error) {
+	switch format {
+	case formatJSONL:
+		return jsonlReader(r), nil
+	case formatParquet:
+		return parquetReader(r)
+	}
+
+	return nil, fmt.Errorf("unknown format '%s', expected %s or %s", format, formatJSONL, formatParquet)
+}
+
+func jsonlReader(r io.Reader) func() (*shared.SummonerDTO, error) {
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	return func() (*shared.SummonerDTO, error) {
+		for scanner.Scan() {
+			line++
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+
+			decoder := json.NewDecoder(strings.NewReader(scanner.Text()))
+			decoder.DisallowUnknownFields()
+
+			summoner := &shared.SummonerDTO{}
+			if err := decoder.Decode(summoner); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+
+			if summoner.Name == "" || summoner.Region == "" {
+				return nil, fmt.Errorf("line %d: name and region are required", line)
+			}
+
+			return summoner, nil
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, io.EOF
+	}
+}
+
+func parquetReader(r io.Reader) (func() (*shared.SummonerDTO, error), error) {
+	readerAt, ok := r.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+
+		readerAt = bytes.NewReader(data)
+	}
+
+	reader := parquet.NewGenericReader[shared.SummonerDTO](readerAt)
+	rows := make([]shared.SummonerDTO, 1)
+
+	return func() (*shared.SummonerDTO, error) {
+		n, err := reader.Read(rows)
+		if n == 1 {
+			summoner := rows[0]
+			return &summoner, nil
+		}
+
+		if err == nil {
+			err = io.EOF
+		}
+
+		return nil, err
+	}, nil
+}
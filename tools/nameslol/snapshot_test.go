@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/bricefrisco/nameslol/shared"
+	"io"
+	"strings"
+	"testing"
+)
+
+var snapshotSummoners = []*shared.SummonerDTO{
+	{Name: "hide on bush", Region: "KR", AccountID: "a1", RevisionDate: 1, AvailabilityDate: 2, Level: 30, LastUpdated: 3, SummonerIcon: 4},
+	{Name: "ëlodie", Region: "EUW", AccountID: "a2", RevisionDate: 5, AvailabilityDate: 6, Level: 7, LastUpdated: 8, SummonerIcon: 9},
+}
+
+func roundTrip(t *testing.T, format string) []*shared.SummonerDTO {
+	buffer := &bytes.Buffer{}
+
+	writer, err := newSummonerWriter(format, buffer)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	for _, summoner := range snapshotSummoners {
+		if err = writer.Write(summoner); err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	read, err := newSummonerReader(format, buffer)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	var summoners []*shared.SummonerDTO
+	for {
+		summoner, err := read()
+		if errors.Is(err, io.EOF) {
+			return summoners
+		}
+
+		if err != nil {
+			t.Fatalf("expected nil, got %v", err)
+		}
+
+		summoners = append(summoners, summoner)
+	}
+}
+
+func TestSnapshot_RoundTripsJSONL(t *testing.T) {
+	summoners := roundTrip(t, formatJSONL)
+
+	if len(summoners) != 2 || *summoners[0] != *snapshotSummoners[0] || *summoners[1] != *snapshotSummoners[1] {
+		t.Errorf("expected summoners to round trip, got %v", summoners)
+	}
+}
+
+func TestSnapshot_RoundTripsParquet(t *testing.T) {
+	summoners := roundTrip(t, formatParquet)
+
+	if len(summoners) != 2 || *summoners[0] != *snapshotSummoners[0] || *summoners[1] != *snapshotSummoners[1] {
+		t.Errorf("expected summoners to round trip, got %v", summoners)
+	}
+}
+
+func TestSnapshot_UsesDTOFieldNames(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	writer, _ := newSummonerWriter(formatJSONL, buffer)
+	_ = writer.Write(snapshotSummoners[0])
+
+	if !strings.HasPrefix(buffer.String(), `{"name":"hide on bush","region":"KR","accountId":"a1"`) {
+		t.Errorf("expected SummonerDTO encoding, got %s", buffer.String())
+	}
+}
+
+func TestSnapshot_RejectsUnknownFormat(t *testing.T) {
+	if _, err := newSummonerWriter("csv", &bytes.Buffer{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	if _, err := newSummonerReader("csv", &bytes.Buffer{}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestJSONLReader_ReportsLineOfInvalidRow(t *testing.T) {
+	read := jsonlReader(strings.NewReader("{\"name\":\"test\",\"region\":\"NA\"}\n\n{\"name\":\"test\",\"levle\":1}\n"))
+
+	if _, err := read(); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	_, err := read()
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected error on line 3, got %v", err)
+	}
+}
+
+func TestJSONLReader_RequiresNameAndRegion(t *testing.T) {
+	read := jsonlReader(strings.NewReader("{\"name\":\"test\"}\n"))
+
+	if _, err := read(); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
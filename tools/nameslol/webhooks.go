@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"io"
+	"log"
+	"strings"
+	"text/tabwriter"
+)
+
+func manageWebhooks(ctx context.Context, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+
+	switch args[0] {
+	case "list":
+		return listWebhooks(ctx, args[1:], stdout)
+	case "add":
+		return addWebhook(ctx, args[1:], stdout)
+	case "remove":
+		return removeWebhook(ctx, args[1:])
+	case "log":
+		return showWebhookLog(ctx, args[1:], stdout)
+	case "dead":
+		return showDeadLetters(ctx, args[1:], stdout)
+	case "redrive":
+		return redriveWebhook(ctx, args[1:])
+	}
+
+	return fmt.Errorf("unknown webhooks command '%s'\n%s", args[0], usage)
+}
+
+func listWebhooks(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("webhooks list", flag.ContinueOnError)
+	output := flags.String("output", outputTable, "output format, table or json")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := webhooks.Endpoints(ctx)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(stdout, *output, endpoints, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tURL\tEVENTS\tREGIONS\tDESCRIPTION\tCREATED")
+		for _, endpoint := range endpoints {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", endpoint.ID, endpoint.URL, strings.Join(endpoint.Events, ","), strings.Join(endpoint.Regions, ","), endpoint.Description, formatMillis(endpoint.CreatedAt))
+		}
+	})
+}
+
+func addWebhook(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("webhooks add", flag.ContinueOnError)
+	eventTypes := flags.String("events", "", "comma separated events to deliver (default every event)")
+	regions := flags.String("regions", "", "comma separated regions to deliver events from (default every region)")
+	description := flags.String("description", "", "what the endpoint is for")
+	output := flags.String("output", outputTable, "output format, table or json")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: nameslol webhooks add [-events E] [-regions R] [-description S] url")
+	}
+
+	endpoint := shared.WebhookEndpoint{URL: flags.Arg(0), Events: splitFlag(*eventTypes), Description: *description}
+	for _, region := range splitFlag(*regions) {
+		r, err := parseRegion(region)
+		if err != nil {
+			return err
+		}
+
+		endpoint.Regions = append(endpoint.Regions, r)
+	}
+
+	registered, err := webhooks.Register(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(stdout, *output, registered, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "added webhook '%s' for %s\n", registered.ID, registered.URL)
+		fmt.Fprintf(w, "signing secret, shown only once: %s\n", registered.Secret)
+	})
+}
+
+func removeWebhook(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: nameslol webhooks remove id")
+	}
+
+	if err := webhooks.Remove(ctx, args[0]); err != nil {
+		return err
+	}
+
+	log.Printf("removed webhook '%s'", args[0])
+	return nil
+}
+
+func showWebhookLog(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("webhooks log", flag.ContinueOnError)
+	output := flags.String("output", outputTable, "output format, table or json")
+	id, err := parseWebhookArgs(flags, args)
+	if err != nil {
+		return err
+	}
+
+	entries, err := webhooks.Log(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(stdout, *output, entries, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "AT\tDELIVERY\tEVENT\tATTEMPT\tSTATUS\tOUTCOME\tERROR")
+		for _, entry := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n", formatMillis(entry.At), entry.DeliveryID, entry.EventType, entry.Attempt, entry.StatusCode, entry.Outcome, entry.Error)
+		}
+	})
+}
+
+func showDeadLetters(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("webhooks dead", flag.ContinueOnError)
+	output := flags.String("output", outputTable, "output format, table or json")
+	id, err := parseWebhookArgs(flags, args)
+	if err != nil {
+		return err
+	}
+
+	deadLetters, err := webhooks.DeadLetters(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(stdout, *output, deadLetters, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "AT\tDELIVERY\tEVENT\tREGION\tNAME\tATTEMPTS\tERROR")
+		for _, deadLetter := range deadLetters {
+			delivery := deadLetter.Delivery
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", formatMillis(deadLetter.At), delivery.ID, delivery.Event.Type, delivery.Event.Region, delivery.Event.Name, delivery.Attempt, deadLetter.Error)
+		}
+	})
+}
+
+func redriveWebhook(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: nameslol webhooks redrive id")
+	}
+
+	redriven, err := webhooks.Redrive(ctx, args[0])
+	log.Printf("queued %d dead-lettered deliveries to webhook '%s' again", redriven, args[0])
+	return err
+}
+
+func parseWebhookArgs(flags *flag.FlagSet, args []string) (string, error) {
+	if err := flags.Parse(args); err != nil {
+		return "", err
+	}
+
+	if flags.NArg() != 1 {
+		return "", fmt.Errorf("expected a webhook id")
+	}
+
+	return flags.Arg(0), nil
+}
+
+func splitFlag(value string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
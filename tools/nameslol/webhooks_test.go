@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/bricefrisco/nameslol/shared"
+	"strings"
+	"testing"
+)
+
+type WebhooksServiceMock struct {
+	Registered []shared.WebhookEndpoint
+	Removed    []string
+	Redriven   []string
+}
+
+func (w *WebhooksServiceMock) Endpoints(_ context.Context) ([]shared.WebhookEndpoint, error) {
+	return []shared.WebhookEndpoint{{ID: "abc123", URL: "https://example.com/hook", Events: []string{shared.EventNameAvailable}}}, nil
+}
+
+func (w *WebhooksServiceMock) Register(_ context.Context, endpoint shared.WebhookEndpoint) (*shared.WebhookEndpoint, error) {
+	w.Registered = append(w.Registered, endpoint)
+	endpoint.ID, endpoint.Secret = "abc123", "whsec_secret"
+	return &endpoint, nil
+}
+
+func (w *WebhooksServiceMock) Remove(_ context.Context, id string) error {
+	if id != "abc123" {
+		return fmt.Errorf("webhook not found")
+	}
+
+	w.Removed = append(w.Removed, id)
+	return nil
+}
+
+func (w *WebhooksServiceMock) Log(_ context.Context, id string) ([]shared.WebhookLogEntry, error) {
+	return []shared.WebhookLogEntry{{DeliveryID: "d1", EventType: shared.EventNameTaken, Attempt: 2, StatusCode: 500, Outcome: shared.WebhookRetrying}}, nil
+}
+
+func (w *WebhooksServiceMock) DeadLetters(_ context.Context, id string) ([]shared.WebhookDeadLetter, error) {
+	event := &shared.NameEvent{Type: shared.EventNameTaken, Region: "NA", Name: "name"}
+	return []shared.WebhookDeadLetter{{Delivery: shared.WebhookDelivery{ID: "d1", EndpointID: id, Event: event, Attempt: 8}, Error: "timeout"}}, nil
+}
+
+func (w *WebhooksServiceMock) Redrive(_ context.Context, id string) (int, error) {
+	w.Redriven = append(w.Redriven, id)
+	return 1, nil
+}
+
+func TestWebhooks_AddsEndpointWithFlagsAndPrintsSecret(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"webhooks", "add", "-events", "name.available, name.taken", "-regions", "na,euw", "https://example.com/hook"}, stdout)
+	if err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	registered := webhooks.(*WebhooksServiceMock).Registered
+	if len(registered) != 1 || fmt.Sprint(registered[0].Events) != "[name.available name.taken]" || fmt.Sprint(registered[0].Regions) != "[NA EUW]" {
+		t.Errorf("expected the endpoint to be registered with its filters, got %v", registered)
+	}
+
+	if !strings.Contains(stdout.String(), "whsec_secret") {
+		t.Errorf("expected the secret to be printed, got %s", stdout.String())
+	}
+}
+
+func TestWebhooks_RejectsInvalidRegion(t *testing.T) {
+	stdout := setup()
+
+	err := run(context.TODO(), []string{"webhooks", "add", "-regions", "xx", "https://example.com/hook"}, stdout)
+	if err == nil || len(webhooks.(*WebhooksServiceMock).Registered) != 0 {
+		t.Errorf("expected error, got %v", err)
+	}
+}
+
+func TestWebhooks_ListsEndpointsAndDeadLetters(t *testing.T) {
+	stdout := setup()
+
+	if err := run(context.TODO(), []string{"webhooks", "list"}, stdout); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if err := run(context.TODO(), []string{"webhooks", "dead", "abc123"}, stdout); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if !strings.Contains(stdout.String(), "https://example.com/hook") || !strings.Contains(stdout.String(), "timeout") {
+		t.Errorf("expected endpoints and dead letters, got %s", stdout.String())
+	}
+}
+
+func TestWebhooks_RemovesAndRedrivesEndpoint(t *testing.T) {
+	stdout := setup()
+
+	if err := run(context.TODO(), []string{"webhooks", "redrive", "abc123"}, stdout); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	if err := run(context.TODO(), []string{"webhooks", "remove", "abc123"}, stdout); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+
+	mock := webhooks.(*WebhooksServiceMock)
+	if len(mock.Redriven) != 1 || len(mock.Removed) != 1 {
+		t.Errorf("expected the endpoint to be redriven and removed, got %v and %v", mock.Redriven, mock.Removed)
+	}
+
+	if err := run(context.TODO(), []string{"webhooks", "remove", "missing"}, stdout); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}